@@ -185,19 +185,42 @@ func NewAgent(config *Config, configFile string) *Agent {
 
 // AgentHandler 实现 executor.SchedulerEventHandler
 type AgentHandler struct {
-	agent *Agent
+	agent   *Agent
+	mu      sync.Mutex
+	writers map[string]*RealTimeLogWriter // LogID -> 执行中任务的实时日志写入器
 }
 
 func (h *AgentHandler) OnTaskScheduled(req *executor.ExecutionRequest) {}
 
 func (h *AgentHandler) OnTaskExecuting(req *executor.ExecutionRequest) (io.Writer, io.Writer, error) {
 	if req.LogID != "" {
-		writer := &RealTimeLogWriter{agent: h.agent, logID: req.LogID}
+		writer := &RealTimeLogWriter{
+			agent:  h.agent,
+			logID:  req.LogID,
+			masker: utils.NewMaskStream(utils.NewSecretMasker(req.Secrets)),
+		}
+		h.mu.Lock()
+		if h.writers == nil {
+			h.writers = make(map[string]*RealTimeLogWriter)
+		}
+		h.writers[req.LogID] = writer
+		h.mu.Unlock()
 		return writer, writer, nil
 	}
 	return nil, nil, nil
 }
 
+// flushWriter 任务结束时发送实时日志写入器中暂存的内容
+func (h *AgentHandler) flushWriter(logID string) {
+	h.mu.Lock()
+	writer := h.writers[logID]
+	delete(h.writers, logID)
+	h.mu.Unlock()
+	if writer != nil {
+		writer.Flush()
+	}
+}
+
 func (h *AgentHandler) OnTaskHeartbeat(req *executor.ExecutionRequest, duration int64) {
 	if req.LogID != "" {
 		h.agent.sendWSMessage(WSTypeTaskHeartbeat, map[string]interface{}{
@@ -216,6 +239,7 @@ func (h *AgentHandler) OnTaskHeartbeat(req *executor.ExecutionRequest, duration
 func (h *AgentHandler) OnTaskStarted(req *executor.ExecutionRequest) {}
 
 func (h *AgentHandler) OnTaskCompleted(req *executor.ExecutionRequest, result *executor.ExecutionResult) {
+	h.flushWriter(req.LogID)
 	h.agent.sendTaskResult(&TaskResult{
 		TaskID:    req.TaskID,
 		LogID:     result.LogID,
@@ -236,6 +260,7 @@ func (h *AgentHandler) OnTaskCompleted(req *executor.ExecutionRequest, result *e
 }
 
 func (h *AgentHandler) OnTaskFailed(req *executor.ExecutionRequest, err error) {
+	h.flushWriter(req.LogID)
 	errMsg := fmt.Sprintf("任务执行失败: %v", err)
 	// 先发送日志，确保服务端能收到错误信息
 	h.agent.sendWSMessage(WSTypeTaskLog, map[string]interface{}{
@@ -610,9 +635,12 @@ func (a *Agent) handleStop(data json.RawMessage) {
 }
 
 // RealTimeLogWriter 实时日志写入器，通过 WebSocket 发送日志
+// 发送前先脱敏，机密不会以明文离开 Agent
 type RealTimeLogWriter struct {
-	agent *Agent
-	logID string
+	agent  *Agent
+	logID  string
+	mu     sync.Mutex
+	masker *utils.MaskStream
 }
 
 func (w *RealTimeLogWriter) Write(p []byte) (n int, err error) {
@@ -620,6 +648,26 @@ func (w *RealTimeLogWriter) Write(p []byte) (n int, err error) {
 		return 0, nil
 	}
 
+	w.mu.Lock()
+	masked := w.masker.Write(p)
+	w.mu.Unlock()
+	w.send(masked)
+	return len(p), nil
+}
+
+// Flush 发送脱敏器中暂存的剩余内容
+func (w *RealTimeLogWriter) Flush() {
+	w.mu.Lock()
+	rest := w.masker.Flush()
+	w.mu.Unlock()
+	w.send(rest)
+}
+
+func (w *RealTimeLogWriter) send(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+
 	// 记录到本地缓存，用于失败时显示
 	w.agent.addTaskLog(w.logID, p)
 
@@ -629,13 +677,8 @@ func (w *RealTimeLogWriter) Write(p []byte) (n int, err error) {
 		"content": string(p),
 	}
 
-	// 发送消息
-	if err := w.agent.sendWSMessage(WSTypeTaskLog, msg); err != nil {
-		// 如果发送失败，不阻塞程序执行，只记录日志
-		return len(p), nil
-	}
-
-	return len(p), nil
+	// 发送消息，失败时不阻塞程序执行
+	w.agent.sendWSMessage(WSTypeTaskLog, msg)
 }
 
 func (a *Agent) sendWSMessage(msgType string, data interface{}) error {
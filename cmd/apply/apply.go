@@ -0,0 +1,160 @@
+package apply
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/engigu/baihu-panel/cmd/clibase"
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/services"
+)
+
+var (
+	dir    string
+	dryRun bool
+	prune  bool
+)
+
+// actionMarks 计划中各动作的标记
+var actionMarks = map[string]string{
+	services.GitOpsActionCreate:    "+",
+	services.GitOpsActionUpdate:    "~",
+	services.GitOpsActionDelete:    "-",
+	services.GitOpsActionOrphan:    "?",
+	services.GitOpsActionUnchanged: "=",
+	services.GitOpsActionError:     "!",
+}
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	fs.StringVar(&dir, "dir", "", "直接读取本地目录中的 YAML，不拉取设置中的配置仓库")
+	fs.BoolVar(&dryRun, "dry-run", false, "只列出差异，不写入任何数据")
+	fs.BoolVar(&prune, "prune", false, "删除仓库中已移除的、由同步创建或接管的资源（默认跟随面板设置）")
+	return fs
+}
+
+func printHelp() {
+	clibase.PrintSubCommandUsage("白虎面板声明式配置同步工具", "baihu apply [--dir <目录>] [--dry-run] [--prune]",
+		"  baihu apply --dry-run\n  baihu apply --prune\n  baihu apply --dir ./baihu-config --dry-run", newFlagSet())
+}
+
+func Run(args []string) {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		printHelp()
+		return
+	}
+
+	fs := newFlagSet()
+	fs.Usage = printHelp
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+	pruneSet := false
+	fs.Visit(func(f *flag.Flag) { pruneSet = pruneSet || f.Name == "prune" })
+
+	if err := clibase.InitContext(true); err != nil {
+		fmt.Printf("初始化失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	svc := services.GetGitOpsService()
+	cfg := services.NewSettingsService().GetSection(constant.SectionGitOps)
+	if !pruneSet {
+		prune = cfg[constant.KeyGitOpsPrune] == "true"
+	}
+
+	specDir, commit := dir, ""
+	if specDir != "" {
+		abs, err := filepath.Abs(specDir)
+		if err != nil {
+			fmt.Printf("目录解析失败: %v\n", err)
+			os.Exit(1)
+		}
+		specDir = abs
+	} else {
+		fmt.Println("正在拉取配置仓库...")
+		var err error
+		specDir, commit, err = svc.Checkout(cfg)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	plan, err := svc.Plan(specDir, prune)
+	if err != nil {
+		fmt.Printf("计算差异失败: %v\n", err)
+		os.Exit(1)
+	}
+	plan.Commit = commit
+	printPlan(plan)
+
+	if dryRun {
+		fmt.Println("以上为预演结果，未写入任何数据。")
+		return
+	}
+
+	report := &services.GitOpsReport{Plan: plan, Result: svc.Apply(plan)}
+	if dir == "" {
+		svc.RecordRun(report, nil)
+	}
+	result := report.Result
+	fmt.Printf("应用完成: 新增 %d，更新 %d，删除 %d\n", result.Created, result.Updated, result.Deleted)
+	for _, e := range result.Errors {
+		fmt.Printf("错误: %s\n", e)
+	}
+
+	// 通知后台服务刷新本机调度与 Agent 任务列表
+	if _, err := clibase.CallInternalAPI("POST", "/internal/gitops/applied", result); err != nil {
+		fmt.Printf("提示: 未能通知后台服务 (%v)，调度变更将在服务重启后生效\n", err)
+	}
+	if len(result.Errors) > 0 || plan.Counts[services.GitOpsActionError] > 0 {
+		os.Exit(1)
+	}
+}
+
+func printPlan(plan *services.GitOpsPlan) {
+	if plan.Commit != "" {
+		fmt.Printf("配置仓库提交: %s\n", plan.Commit)
+	}
+	fmt.Println("--------------------------------------------------")
+	for _, item := range plan.Items {
+		line := fmt.Sprintf("%s [%s] %s", actionMarks[item.Action], item.Kind, item.Name)
+		if item.File != "" {
+			line += "  (" + item.File + ")"
+		}
+		if item.Adopt {
+			line += "  接管面板中的同名资源"
+		}
+		if item.Drift {
+			line += "  面板中已被修改"
+		}
+		fmt.Println(line)
+		if item.Error != "" {
+			fmt.Printf("    %s\n", item.Error)
+		}
+		for _, ch := range item.Changes {
+			fmt.Printf("    %s: %s -> %s\n", ch.Field, brief(ch.Panel), brief(ch.Repo))
+		}
+	}
+	fmt.Println("--------------------------------------------------")
+	fmt.Println((&services.GitOpsReport{Plan: plan}).Summary())
+	if n := plan.Counts[services.GitOpsActionOrphan]; n > 0 {
+		fmt.Printf("有 %d 个资源已从仓库移除，使用 --prune 删除\n", n)
+	}
+}
+
+// brief 将多行或过长的值压缩为一行
+func brief(s string) string {
+	s = strings.ReplaceAll(strings.TrimSpace(s), "\n", "⏎")
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	if s == "" {
+		return `""`
+	}
+	return s
+}
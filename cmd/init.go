@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"github.com/engigu/baihu-panel/cmd/apply"
 	"github.com/engigu/baihu-panel/cmd/builtininstall"
 	"github.com/engigu/baihu-panel/cmd/completion"
 	"github.com/engigu/baihu-panel/cmd/depinstall"
+	"github.com/engigu/baihu-panel/cmd/qlimport"
 	"github.com/engigu/baihu-panel/cmd/reposync"
 	"github.com/engigu/baihu-panel/cmd/resetpwd"
 	"github.com/engigu/baihu-panel/cmd/restore"
+	"github.com/engigu/baihu-panel/cmd/rotatekey"
 	"github.com/engigu/baihu-panel/cmd/task"
 	"github.com/engigu/baihu-panel/cmd/version"
 	"github.com/engigu/baihu-panel/cmd/webui"
@@ -21,12 +24,15 @@ func InitHandlers() {
 	}, false)
 
 	// 注册普通 CLI 工具子命令
+	RegisterHandler("apply", apply.Run)
 	RegisterHandler("builtininstall", builtininstall.Run)
 	RegisterHandler("completion", completion.Run)
 	RegisterHandler("depinstall", depinstall.Run)
+	RegisterHandler("ql-import", qlimport.Run)
 	RegisterHandler("reposync", reposync.Run)
 	RegisterHandler("resetpwd", resetpwd.Run)
 	RegisterHandler("restore", restore.Run)
+	RegisterHandler("rotate-key", rotatekey.Run)
 	RegisterHandler("task", task.Run)
 	RegisterHandler("webui", webui.Run)
 
@@ -0,0 +1,130 @@
+package qlimport
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/engigu/baihu-panel/cmd/clibase"
+	"github.com/engigu/baihu-panel/internal/services"
+)
+
+var (
+	dryRun      bool
+	copyScripts bool
+)
+
+// actionMarks 报告中各动作的标记
+var actionMarks = map[string]string{
+	services.QLImportActionCreate:   "+",
+	services.QLImportActionExists:   "=",
+	services.QLImportActionSkip:     "-",
+	services.QLImportActionUnmapped: "!",
+}
+
+// kindLabels 报告中各资源类型的名称
+var kindLabels = map[string]string{
+	services.QLImportKindTask:       "任务",
+	services.QLImportKindRepo:       "同步",
+	services.QLImportKindEnv:        "变量",
+	services.QLImportKindDependency: "依赖",
+	services.QLImportKindScript:     "脚本",
+	services.QLImportKindHook:       "钩子",
+}
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("ql-import", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "只列出导入结果，不写入任何数据")
+	fs.BoolVar(&copyScripts, "copy-scripts", false, "将青龙 scripts 目录中的脚本复制到面板 scripts 目录，已存在的文件不覆盖")
+	return fs
+}
+
+func printHelp() {
+	clibase.PrintSubCommandUsage("青龙面板数据迁移工具", "baihu ql-import [--dry-run] [--copy-scripts] <青龙 data 目录或 database.sqlite>",
+		"  baihu ql-import --dry-run /ql/data\n  baihu ql-import --copy-scripts /ql/data\n  baihu ql-import /backup/ql/db/database.sqlite", newFlagSet())
+}
+
+func Run(args []string) {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		printHelp()
+		return
+	}
+
+	fs := newFlagSet()
+	fs.Usage = printHelp
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintf(os.Stderr, "错误: 必须提供青龙数据目录或数据库文件路径\n")
+		fs.Usage()
+		return
+	}
+	source, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Printf("路径解析失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, err := services.LoadQLData(source)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	if err := clibase.InitContext(true); err != nil {
+		fmt.Printf("初始化失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("读取青龙数据: %s (%s)\n", data.Source, data.Format)
+	fmt.Printf("定时任务 %d，环境变量 %d，依赖 %d，订阅 %d\n", len(data.Crons), len(data.Envs), len(data.Dependencies), len(data.Subscriptions))
+	if copyScripts && data.ScriptsDir != "" {
+		fmt.Printf("脚本目录: %s\n", data.ScriptsDir)
+	}
+
+	report := services.NewQLImportService().Import(data, services.QLImportOptions{DryRun: dryRun, CopyScripts: copyScripts})
+	printReport(report)
+
+	if dryRun {
+		fmt.Println("以上为预演结果，未写入任何数据。")
+		return
+	}
+	if len(report.TaskIDs) > 0 {
+		// 通知后台服务将新建的任务加入调度
+		payload := map[string]any{"upserted_ids": report.TaskIDs}
+		if _, err := clibase.CallInternalAPI("POST", "/internal/tasks/sync-repo-status", payload); err != nil {
+			fmt.Printf("提示: 未能通知后台服务 (%v)，新任务将在服务重启后开始调度\n", err)
+		}
+	}
+	created := map[string]bool{}
+	for _, item := range report.Items {
+		if item.Action == services.QLImportActionCreate {
+			created[item.Kind] = true
+		}
+	}
+	if created[services.QLImportKindDependency] {
+		fmt.Println("依赖只导入了记录，请在依赖管理中安装。")
+	}
+	if created[services.QLImportKindRepo] {
+		fmt.Println("仓库同步任务运行一次后，会按脚本注释重新生成订阅中的定时任务。")
+	}
+}
+
+func printReport(report *services.QLImportReport) {
+	fmt.Println("--------------------------------------------------")
+	for _, item := range report.Items {
+		fmt.Printf("%s [%s] %s\n", actionMarks[item.Action], kindLabels[item.Kind], item.Name)
+		for _, note := range item.Notes {
+			fmt.Printf("    %s\n", note)
+		}
+	}
+	fmt.Println("--------------------------------------------------")
+	verb := "导入"
+	if report.DryRun {
+		verb = "将导入"
+	}
+	fmt.Printf("%s %d，已存在 %d，跳过 %d，无法映射 %d\n", verb,
+		report.Counts[services.QLImportActionCreate], report.Counts[services.QLImportActionExists],
+		report.Counts[services.QLImportActionSkip], report.Counts[services.QLImportActionUnmapped])
+}
@@ -74,6 +74,21 @@ func Run(args []string) {
 		return
 	}
 
+	// 两步验证手段全部丢失时，可一并清除 TOTP、通行密钥与恢复码
+	mfaService := services.NewMFAService(nil)
+	if mfaService.HasSecondFactor(user) {
+		fmt.Printf("用户 [%s] 已开启两步验证，是否同时清除 TOTP、通行密钥与恢复码? (y/N): ", username)
+		answer, _ = reader.ReadString('\n')
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if answer == "y" || answer == "yes" {
+			if err := mfaService.ResetSecondFactors(user.ID); err != nil {
+				fmt.Printf("清除两步验证失败: %v\n", err)
+			} else {
+				fmt.Println("两步验证已清除，请登录后重新配置。")
+			}
+		}
+	}
+
 	fmt.Println("--------------------------------------------------")
 	fmt.Printf("用户 [%s] 密码已重置成功:\n", username)
 	fmt.Printf("新密码: %s\n", newPassword)
@@ -5,13 +5,40 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/engigu/baihu-panel/cmd/clibase"
 	"github.com/engigu/baihu-panel/internal/services"
 )
 
+var (
+	dryRun      bool
+	passphrase  string
+	tables      string
+	taskIDs     string
+	scriptPaths string
+	noScripts   bool
+	mode        string
+	conflict    string
+)
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "只校验备份并列出恢复后的数据变化，不写入任何数据")
+	fs.StringVar(&passphrase, "passphrase", "", "加密备份的口令，也可通过环境变量 BAIHU_BACKUP_PASSPHRASE 提供")
+	fs.StringVar(&tables, "tables", "", "只恢复这些数据，逗号分隔，如 tasks,envs；传空字符串表示不恢复任何表")
+	fs.StringVar(&taskIDs, "tasks", "", "只恢复这些任务及其日志、推送统计与关联数据，逗号分隔的任务 ID")
+	fs.StringVar(&scriptPaths, "script-paths", "", "只恢复 scripts 下的这些子路径，逗号分隔")
+	fs.BoolVar(&noScripts, "no-scripts", false, "不恢复 scripts 文件夹")
+	fs.StringVar(&mode, "mode", "", "恢复方式: replace 清空所选数据后写入，merge 保留现有数据（指定以上任一选项时默认 merge）")
+	fs.StringVar(&conflict, "conflict", "", "merge 时与现有记录冲突的处理: skip、overwrite 或 rename（默认 skip）")
+	return fs
+}
+
 func printHelp() {
-	clibase.PrintSubCommandUsage("白虎面板系统数据恢复工具", "baihu restore <备份文件.zip>", "  baihu restore backup_20231027.zip", nil)
+	clibase.PrintSubCommandUsage("白虎面板系统数据恢复工具", "baihu restore [--dry-run] [--passphrase <口令>] [--tables <数据>] [--tasks <任务ID>] [--mode merge|replace] [--conflict skip|overwrite|rename] <备份文件.zip>",
+		"  baihu restore backup_20231027.zip\n  BAIHU_BACKUP_PASSPHRASE=xxx baihu restore --dry-run baihu_backup_20231027_030000.zip\n"+
+			"  baihu restore --tables envs --no-scripts --conflict rename backup_20231027.zip\n  baihu restore --tasks <任务ID> --mode replace backup_20231027.zip", newFlagSet())
 }
 
 func Run(args []string) {
@@ -20,7 +47,7 @@ func Run(args []string) {
 		return
 	}
 
-	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fs := newFlagSet()
 	fs.Usage = printHelp
 
 	if err := fs.Parse(args); err != nil {
@@ -46,12 +73,26 @@ func Run(args []string) {
 		os.Exit(1)
 	}
 
+	if passphrase == "" {
+		passphrase = os.Getenv("BAIHU_BACKUP_PASSPHRASE")
+	}
+	plan := buildPlan(fs)
+
 	// 必须初始化环境与数据库才能恢复数据
 	clibase.InitContext(false)
 
 	backupService := services.NewBackupService()
+	if dryRun {
+		preview, err := backupService.PreviewRestore(absPath, passphrase, plan)
+		if err != nil {
+			fmt.Printf("校验备份失败: %v\n", err)
+			os.Exit(1)
+		}
+		printPreview(preview)
+		return
+	}
 	fmt.Printf("正在从 '%s' 恢复系统数据，请勿强制中断...\n", absPath)
-	err = backupService.Restore(absPath)
+	err = backupService.Restore(absPath, passphrase, plan)
 	if err != nil {
 		fmt.Printf("恢复备份失败: %v\n", err)
 		os.Exit(1)
@@ -62,3 +103,53 @@ func Run(args []string) {
 	fmt.Println("注意：部分设定可能需要重启后台服务后才能完全生效。")
 	fmt.Println("--------------------------------------------------")
 }
+
+// buildPlan 根据命令行选项生成恢复方案，未指定任何选项时返回 nil，即全量恢复
+func buildPlan(fs *flag.FlagSet) *services.RestorePlan {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["tables"] && !set["tasks"] && !set["script-paths"] && !set["no-scripts"] && !set["mode"] && !set["conflict"] {
+		return nil
+	}
+	plan := &services.RestorePlan{
+		TaskIDs:     splitList(taskIDs),
+		Scripts:     !noScripts,
+		ScriptPaths: splitList(scriptPaths),
+		Mode:        mode,
+		Conflict:    conflict,
+	}
+	if set["tables"] {
+		plan.Tables = append([]string{}, splitList(tables)...)
+	}
+	return plan
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func printPreview(p *services.RestorePreview) {
+	fmt.Printf("备份版本: %s  创建时间: %s\n", p.Version, p.CreatedAt)
+	fmt.Printf("加密: %v  完整性校验: %v  恢复方式: %s  冲突处理: %s\n", p.Encrypted, p.Verified, p.Mode, p.Conflict)
+	for _, w := range p.Warnings {
+		fmt.Printf("警告: %s\n", w)
+	}
+	fmt.Println("--------------------------------------------------")
+	fmt.Printf("%s %8s %8s %8s %8s %8s %8s %8s\n", clibase.VisualFormat("数据", 22), "当前", "备份", "新增", "覆盖", "删除", "跳过", "另存")
+	for _, t := range p.Tables {
+		if t.Kept {
+			fmt.Printf("%s %8d %s\n", clibase.VisualFormat(t.File, 22), t.Current, "  备份时已排除，保留现有记录")
+			continue
+		}
+		fmt.Printf("%s %8d %8d %8d %8d %8d %8d %8d\n", clibase.VisualFormat(t.File, 22), t.Current, t.Incoming, t.Added, t.Replaced, t.Removed, t.Skipped, t.Renamed)
+	}
+	fmt.Println("--------------------------------------------------")
+	fmt.Printf("脚本文件: 新增 %d，覆盖 %d，跳过 %d，另存 %d（恢复不会删除现有脚本）\n", p.ScriptsAdded, p.ScriptsUpdated, p.ScriptsSkipped, p.ScriptsRenamed)
+	fmt.Println("以上为预演结果，未写入任何数据。")
+}
@@ -0,0 +1,123 @@
+package rotatekey
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/engigu/baihu-panel/cmd/clibase"
+	"github.com/engigu/baihu-panel/internal/services"
+)
+
+var (
+	dryRun           bool
+	skipBackups      bool
+	encryptPlaintext bool
+	assumeYes        bool
+)
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	fs.BoolVar(&dryRun, "dry-run", false, "只检查并统计，不写入任何数据")
+	fs.BoolVar(&skipBackups, "skip-backups", false, "不处理备份目录中的 zip 备份")
+	fs.BoolVar(&encryptPlaintext, "encrypt-plaintext", false, "将无法解密的旧格式值视为未加密的明文并加密")
+	fs.BoolVar(&assumeYes, "yes", false, "跳过确认提示")
+	return fs
+}
+
+func printHelp() {
+	clibase.PrintSubCommandUsage("白虎面板主密钥轮换工具",
+		"BAIHU_SECRET_KEY=<新密钥> BAIHU_SECRET_KEY_PREVIOUS=<旧密钥> baihu rotate-key [--dry-run]",
+		"  BAIHU_SECRET_KEY=new-key BAIHU_SECRET_KEY_PREVIOUS=old-key baihu rotate-key --dry-run",
+		newFlagSet())
+}
+
+func Run(args []string) {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		printHelp()
+		return
+	}
+
+	fs := newFlagSet()
+	fs.Usage = printHelp
+	if err := fs.Parse(args); err != nil {
+		return
+	}
+
+	if err := clibase.InitContext(false); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if !dryRun && !assumeYes {
+		fmt.Print("此操作将使用 BAIHU_SECRET_KEY 重新加密全部机密及备份中的机密，请确认已备份数据库，是否继续? (y/N): ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("操作已取消。")
+			return
+		}
+	}
+
+	report, err := services.RotateSecretKey(services.RotateKeyOptions{
+		DryRun:           dryRun,
+		SkipBackups:      skipBackups,
+		EncryptPlaintext: encryptPlaintext,
+	})
+	if report != nil {
+		printReport(report)
+	}
+	if err != nil {
+		fmt.Printf("轮换失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("--------------------------------------------------")
+	if dryRun {
+		fmt.Println("检查通过（dry-run 未写入任何数据），去掉 --dry-run 即可执行轮换。")
+	} else {
+		fmt.Println("主密钥轮换完成！")
+		fmt.Println("请使用新的 BAIHU_SECRET_KEY 重启服务，确认无误后即可移除 BAIHU_SECRET_KEY_PREVIOUS。")
+	}
+	fmt.Println("--------------------------------------------------")
+}
+
+func printReport(r *services.RotateKeyReport) {
+	fmt.Printf("当前主密钥 ID: %s\n", r.KeyID)
+	fmt.Printf("机密总数: %d（已是当前密钥 %d，重新加密 %d，按明文加密 %d，失败 %d）\n",
+		r.Total, r.Current, r.Rotated, r.Plaintext, len(r.Failed))
+
+	ids := make([]string, 0, len(r.ByKey))
+	for id := range r.ByKey {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("  密钥 %-8s %d 个\n", id, r.ByKey[id])
+	}
+	if r.Versions > 0 {
+		fmt.Printf("机密历史版本: 重新加密 %d\n", r.Versions)
+	}
+	for _, f := range r.Failed {
+		fmt.Printf("  [失败] %s\n", f)
+	}
+
+	if r.BackupsSkipped {
+		fmt.Println("备份: 已跳过")
+		return
+	}
+	fmt.Printf("备份: %d 个\n", len(r.Backups))
+	for _, b := range r.Backups {
+		if b.Encrypted {
+			fmt.Printf("  %s: 已用口令加密，跳过（仍需旧密钥才能解密其中的机密）\n", b.Path)
+			continue
+		}
+		fmt.Printf("  %s: 重新加密 %d，失败 %d\n", b.Path, b.Rotated, len(b.Failed))
+		for _, f := range b.Failed {
+			fmt.Printf("    [失败] %s\n", f)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package backupstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 加密备份格式：
+//
+//	magic(8) | version(1) | scrypt logN(1) | salt(16) | nonce 前缀(7) | 分块...
+//
+// 明文按 64KiB 分块，每块独立以 AES-256-GCM 加密，nonce 为 前缀 + 4 字节块序号 + 1 字节末块标记，
+// 文件头作为每块的附加数据。末块总是短于完整分块（明文恰好整除时追加一个空末块），
+// 因此截断、重排、替换文件头都会在解密时被发现。
+const (
+	cryptVersion    = 1
+	cryptLogN       = 15
+	cryptChunkSize  = 64 << 10
+	cryptSaltSize   = 16
+	cryptPrefixSize = 7
+	cryptMagicText  = "BAIHUENC"
+	cryptHeaderSize = len(cryptMagicText) + 2 + cryptSaltSize + cryptPrefixSize
+)
+
+var cryptMagic = []byte(cryptMagicText)
+
+var (
+	// ErrPassphraseRequired 备份已加密但未提供口令
+	ErrPassphraseRequired = errors.New("备份已加密，请提供备份口令")
+	// ErrBadPassphrase 口令错误或备份内容被篡改
+	ErrBadPassphrase = errors.New("备份口令错误或备份文件已损坏")
+)
+
+// IsEncrypted 判断文件头是否为加密备份
+func IsEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, cryptMagic)
+}
+
+func deriveCryptKey(passphrase string, salt []byte, logN int) (cipher.AEAD, error) {
+	if logN < 10 || logN > 22 {
+		return nil, fmt.Errorf("不支持的加密参数 logN=%d", logN)
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<logN, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, seq uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[cryptPrefixSize:], seq)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	seq    uint32
+	buf    []byte
+	closed bool
+}
+
+// Encrypt 返回以口令加密写入 w 的 WriteCloser，必须调用 Close 写出末块
+func Encrypt(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	if passphrase == "" {
+		return nil, errors.New("备份口令不能为空")
+	}
+	header := make([]byte, 0, cryptHeaderSize)
+	header = append(header, cryptMagic...)
+	header = append(header, cryptVersion, cryptLogN)
+	random := make([]byte, cryptSaltSize+cryptPrefixSize)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	header = append(header, random...)
+
+	aead, err := deriveCryptKey(passphrase, random[:cryptSaltSize], cryptLogN)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: random[cryptSaltSize:],
+		buf:    make([]byte, 0, cryptChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("写入已关闭的加密流")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// 缓冲区满后不立即写出，留到下一次写入时确认不是末块
+		if len(e.buf) == cryptChunkSize {
+			if err := e.flush(false); err != nil {
+				return 0, err
+			}
+		}
+		c := copy(e.buf[len(e.buf):cryptChunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) flush(last bool) error {
+	if e.seq == ^uint32(0) {
+		return errors.New("备份过大，超出加密分块上限")
+	}
+	out := e.aead.Seal(nil, chunkNonce(e.prefix, e.seq, last), e.buf, e.header)
+	e.seq++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(out)
+	return err
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if len(e.buf) == cryptChunkSize {
+		if err := e.flush(false); err != nil {
+			return err
+		}
+	}
+	return e.flush(true)
+}
+
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	prefix []byte
+	seq    uint32
+	chunk  []byte
+	plain  []byte
+	done   bool
+}
+
+// Decrypt 返回解密 r 的 Reader，读到末块前遇到 EOF 视为文件被截断
+func Decrypt(r io.Reader, passphrase string) (io.Reader, error) {
+	header := make([]byte, cryptHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || !IsEncrypted(header) {
+		return nil, errors.New("不是有效的加密备份")
+	}
+	if header[len(cryptMagic)] != cryptVersion {
+		return nil, fmt.Errorf("不支持的加密备份版本 %d", header[len(cryptMagic)])
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	salt := header[len(cryptMagic)+2 : len(cryptMagic)+2+cryptSaltSize]
+	aead, err := deriveCryptKey(passphrase, salt, int(header[len(cryptMagic)+1]))
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		prefix: header[cryptHeaderSize-cryptPrefixSize:],
+		chunk:  make([]byte, cryptChunkSize+aead.Overhead()),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	last := false
+	switch {
+	case err == io.EOF:
+		return errors.New("加密备份不完整")
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return err
+	}
+	plain, err := d.aead.Open(d.chunk[:0], chunkNonce(d.prefix, d.seq, last), d.chunk[:n], d.header)
+	if err != nil {
+		if d.seq == 0 {
+			return ErrBadPassphrase
+		}
+		return errors.New("加密备份已损坏")
+	}
+	d.seq++
+	d.plain = plain
+	d.done = last
+	return nil
+}
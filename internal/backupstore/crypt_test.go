@@ -0,0 +1,77 @@
+package backupstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encryptBytes(t *testing.T, data []byte, passphrase string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 分多次写入，覆盖跨分块的缓冲逻辑
+	for len(data) > 0 {
+		n := min(len(data), 10000)
+		if _, err := w.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 100, cryptChunkSize, 2*cryptChunkSize + 7} {
+		data := bytes.Repeat([]byte("baihu"), size/5+1)[:size]
+		enc := encryptBytes(t, data, "correct horse")
+		if !IsEncrypted(enc) {
+			t.Fatal("encrypted output should start with magic")
+		}
+		r, err := Decrypt(bytes.NewReader(enc), "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || !bytes.Equal(got, data) {
+			t.Fatalf("size %d: round trip mismatch, err=%v", size, err)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, 2*cryptChunkSize)
+	enc := encryptBytes(t, data, "pw")
+
+	read := func(b []byte, passphrase string) error {
+		r, err := Decrypt(bytes.NewReader(b), passphrase)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadAll(r)
+		return err
+	}
+	if err := read(enc, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Errorf("empty passphrase: %v", err)
+	}
+	if err := read(enc, "wrong"); !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("wrong passphrase: %v", err)
+	}
+	// 截掉末块，剩下的都是完整分块
+	chunk := cryptChunkSize + 16
+	if err := read(enc[:cryptHeaderSize+2*chunk], "pw"); err == nil {
+		t.Error("truncated stream should fail")
+	}
+	flipped := bytes.Clone(enc)
+	flipped[len(flipped)-1] ^= 1
+	if err := read(flipped, "pw"); err == nil {
+		t.Error("modified stream should fail")
+	}
+}
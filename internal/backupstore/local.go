@@ -0,0 +1,98 @@
+package backupstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// LocalTarget 本地目录，可指向挂载的 NAS 或网络盘
+type LocalTarget struct {
+	dir string
+}
+
+// NewLocalTarget 创建本地目录目标
+func NewLocalTarget(dir string) *LocalTarget {
+	return &LocalTarget{dir: filepath.Clean(dir)}
+}
+
+func (t *LocalTarget) Name() string { return constant.BackupTargetLocal }
+
+// Dir 返回备份目录
+func (t *LocalTarget) Dir() string { return t.dir }
+
+func (t *LocalTarget) Upload(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免留下不完整的备份
+	tmp, err := os.CreateTemp(t.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(t.dir, name))
+}
+
+func (t *LocalTarget) Download(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	f, err := os.Open(filepath.Join(t.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (t *LocalTarget) List(ctx context.Context) ([]RemoteFile, error) {
+	entries, err := os.ReadDir(t.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []RemoteFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, RemoteFile{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+func (t *LocalTarget) Delete(ctx context.Context, name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(t.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,656 @@
+package backupstore
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrSFTPHostKeyRequired 未填写服务器公钥指纹且未显式关闭校验
+var ErrSFTPHostKeyRequired = errors.New("请填写 SFTP 服务器公钥指纹，可通过「测试连接」获取后核对")
+
+// SFTPConfig SFTP 配置，密码与私钥至少填写一项
+type SFTPConfig struct {
+	Address         string // host:port，未写端口时使用 22
+	Username        string
+	Password        string
+	PrivateKey      string
+	HostKey         string // 服务器公钥指纹，形如 SHA256:xxxx，必须填写
+	InsecureHostKey bool   // 显式跳过服务器身份校验，仅在 HostKey 为空时生效
+	Dir             string // 远程目录
+}
+
+// SFTPTarget 通过 SSH 的 sftp 子系统读写远程目录，每次操作单独建立连接
+type SFTPTarget struct {
+	cfg    SFTPConfig
+	client *ssh.ClientConfig
+}
+
+// NewSFTPTarget 创建 SFTP 目标
+func NewSFTPTarget(cfg SFTPConfig) (*SFTPTarget, error) {
+	cfg.Address = strings.TrimSpace(cfg.Address)
+	cfg.HostKey = strings.TrimSpace(cfg.HostKey)
+	cfg.Dir = strings.TrimRight(strings.TrimSpace(cfg.Dir), "/")
+	if cfg.Address == "" || cfg.Username == "" {
+		return nil, errors.New("请填写 SFTP 地址和用户名")
+	}
+	cfg.Address = sftpAddress(cfg.Address)
+	if cfg.HostKey == "" && !cfg.InsecureHostKey {
+		return nil, ErrSFTPHostKeyRequired
+	}
+	if cfg.HostKey != "" && !strings.HasPrefix(cfg.HostKey, "SHA256:") {
+		return nil, errors.New("SFTP 服务器公钥指纹应以 SHA256: 开头")
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+
+	var auth []ssh.AuthMethod
+	if strings.TrimSpace(cfg.PrivateKey) != "" {
+		var signer ssh.Signer
+		var err error
+		if cfg.Password != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(cfg.PrivateKey), []byte(cfg.Password))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(cfg.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("无法解析 SFTP 私钥: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	} else {
+		return nil, errors.New("请填写 SFTP 密码或私钥")
+	}
+
+	want := cfg.HostKey
+	hostKeyCallback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if got := ssh.FingerprintSHA256(key); got != want {
+			return fmt.Errorf("服务器公钥指纹不匹配: %s", got)
+		}
+		return nil
+	}
+	if want == "" {
+		// 仅在显式开启 InsecureHostKey 时到达此处
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+	return &SFTPTarget{
+		cfg: cfg,
+		client: &ssh.ClientConfig{
+			User:            cfg.Username,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         15 * time.Second,
+		},
+	}, nil
+}
+
+// sftpAddress 未写端口时补全默认的 22 端口
+func sftpAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, "22")
+	}
+	return address
+}
+
+// errHostKeyCaptured 获取指纹后中断握手
+var errHostKeyCaptured = errors.New("host key captured")
+
+// SFTPHostKey 连接服务器并返回其公钥的 SHA256 指纹，不进行登录
+func SFTPHostKey(ctx context.Context, address string) (string, error) {
+	address = sftpAddress(strings.TrimSpace(address))
+	dialer := net.Dialer{Timeout: 15 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var fingerprint string
+	_, _, _, err = ssh.NewClientConn(conn, address, &ssh.ClientConfig{
+		User: "baihu",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint = ssh.FingerprintSHA256(key)
+			return errHostKeyCaptured
+		},
+	})
+	if fingerprint != "" {
+		return fingerprint, nil
+	}
+	return "", err
+}
+
+func (t *SFTPTarget) Name() string { return constant.BackupTargetSFTP }
+
+func (t *SFTPTarget) remotePath(name string) string {
+	return path.Join(t.cfg.Dir, name)
+}
+
+// session 建立连接并在 fn 返回后关闭，ctx 取消时中断连接
+func (t *SFTPTarget) session(ctx context.Context, fn func(c *sftpConn) error) error {
+	dialer := net.Dialer{Timeout: t.client.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", t.cfg.Address)
+	if err != nil {
+		return err
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.cfg.Address, t.client)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+	w, err := sess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	r, err := sess.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := sess.RequestSubsystem("sftp"); err != nil {
+		return fmt.Errorf("服务器未启用 sftp 子系统: %v", err)
+	}
+	c := &sftpConn{r: r, w: w}
+	if err := c.init(); err != nil {
+		return err
+	}
+	err = fn(c)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (t *SFTPTarget) Upload(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	return t.session(ctx, func(c *sftpConn) error {
+		if t.cfg.Dir != "." {
+			c.mkdirAll(t.cfg.Dir)
+		}
+		// 先写临时文件再重命名，避免留下不完整的备份
+		tmp := t.remotePath(".tmp-" + name)
+		handle, err := c.open(tmp, sftpFlagWrite|sftpFlagCreate|sftpFlagTrunc)
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, sftpChunkSize)
+		var offset uint64
+		for {
+			n, rerr := r.Read(buf)
+			if n > 0 {
+				if err := c.write(handle, offset, buf[:n]); err != nil {
+					c.close(handle)
+					c.remove(tmp)
+					return err
+				}
+				offset += uint64(n)
+			}
+			if rerr == io.EOF {
+				break
+			}
+			if rerr != nil {
+				c.close(handle)
+				c.remove(tmp)
+				return rerr
+			}
+		}
+		if err := c.close(handle); err != nil {
+			return err
+		}
+		target := t.remotePath(name)
+		c.remove(target)
+		return c.rename(tmp, target)
+	})
+}
+
+func (t *SFTPTarget) Download(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	return t.session(ctx, func(c *sftpConn) error {
+		handle, err := c.open(t.remotePath(name), sftpFlagRead)
+		if err != nil {
+			return err
+		}
+		defer c.close(handle)
+		var offset uint64
+		for {
+			data, err := c.read(handle, offset, sftpChunkSize)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+			offset += uint64(len(data))
+		}
+	})
+}
+
+func (t *SFTPTarget) List(ctx context.Context) ([]RemoteFile, error) {
+	var files []RemoteFile
+	err := t.session(ctx, func(c *sftpConn) error {
+		entries, err := c.readDir(t.cfg.Dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.dir || ValidName(e.name) != nil {
+				continue
+			}
+			files = append(files, RemoteFile{Name: e.name, Size: e.size, ModTime: e.mtime})
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	return files, err
+}
+
+func (t *SFTPTarget) Delete(ctx context.Context, name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	return t.session(ctx, func(c *sftpConn) error {
+		err := c.remove(t.remotePath(name))
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// SFTP v3 协议（draft-ietf-secsh-filexfer-02）中用到的报文类型
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpWrite   = 6
+	sftpOpendir = 11
+	sftpReaddir = 12
+	sftpRemove  = 13
+	sftpMkdir   = 14
+	sftpRename  = 18
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+	sftpName    = 104
+
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+
+	sftpAttrSize     = 0x01
+	sftpAttrUIDGID   = 0x02
+	sftpAttrPerm     = 0x04
+	sftpAttrTime     = 0x08
+	sftpAttrExtended = 0x80000000
+
+	sftpStatusOK     = 0
+	sftpStatusEOF    = 1
+	sftpStatusNoFile = 2
+
+	sftpChunkSize    = 32 * 1024
+	sftpMaxPacket    = 256 * 1024
+	sftpModeDirBit   = 0040000
+	sftpModeTypeBits = 0170000
+)
+
+// sftpConn 最小的同步 SFTP 客户端，一次只发送一个请求
+type sftpConn struct {
+	mu     sync.Mutex
+	r      io.Reader
+	w      io.Writer
+	nextID uint32
+}
+
+type sftpEntry struct {
+	name  string
+	size  int64
+	mtime time.Time
+	dir   bool
+}
+
+// sftpStatusError 服务器返回的非成功状态
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	if e.msg != "" {
+		return fmt.Sprintf("SFTP 错误 %d: %s", e.code, e.msg)
+	}
+	return fmt.Sprintf("SFTP 错误 %d", e.code)
+}
+
+func (c *sftpConn) writePacket(typ byte, payload []byte) error {
+	buf := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)+1))
+	buf[4] = typ
+	_, err := c.w.Write(append(buf, payload...))
+	return err
+}
+
+func (c *sftpConn) readPacket() (byte, []byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(head[:4])
+	if length < 1 || length > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("SFTP 报文长度异常: %d", length)
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return head[4], payload, nil
+}
+
+func (c *sftpConn) init() error {
+	if err := c.writePacket(sftpInit, appendU32(nil, 3)); err != nil {
+		return err
+	}
+	typ, _, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if typ != sftpVersion {
+		return fmt.Errorf("SFTP 握手失败，收到报文类型 %d", typ)
+	}
+	return nil
+}
+
+// request 发送请求并读取响应，返回响应类型与去掉请求 ID 后的内容
+func (c *sftpConn) request(typ byte, body []byte) (byte, []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	id := c.nextID
+	if err := c.writePacket(typ, append(appendU32(nil, id), body...)); err != nil {
+		return 0, nil, err
+	}
+	rtyp, payload, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < 4 || binary.BigEndian.Uint32(payload) != id {
+		return 0, nil, errors.New("SFTP 响应与请求不匹配")
+	}
+	return rtyp, payload[4:], nil
+}
+
+// statusError 将 STATUS 响应转换为错误，OK 返回 nil，EOF 返回 io.EOF，文件不存在返回 ErrNotFound
+func statusError(payload []byte) error {
+	code, rest, ok := readU32(payload)
+	if !ok {
+		return errors.New("SFTP 状态报文格式错误")
+	}
+	msg, _, _ := readString(rest)
+	switch code {
+	case sftpStatusOK:
+		return nil
+	case sftpStatusEOF:
+		return io.EOF
+	case sftpStatusNoFile:
+		return ErrNotFound
+	}
+	return &sftpStatusError{code: code, msg: string(msg)}
+}
+
+func (c *sftpConn) expectStatus(typ byte, body []byte) error {
+	rtyp, payload, err := c.request(typ, body)
+	if err != nil {
+		return err
+	}
+	if rtyp != sftpStatus {
+		return fmt.Errorf("SFTP 意外的响应类型 %d", rtyp)
+	}
+	return statusError(payload)
+}
+
+func (c *sftpConn) expectHandle(typ byte, body []byte) ([]byte, error) {
+	rtyp, payload, err := c.request(typ, body)
+	if err != nil {
+		return nil, err
+	}
+	switch rtyp {
+	case sftpHandle:
+		handle, _, ok := readString(payload)
+		if !ok {
+			return nil, errors.New("SFTP 句柄报文格式错误")
+		}
+		return handle, nil
+	case sftpStatus:
+		if err := statusError(payload); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("SFTP 意外的响应类型 %d", rtyp)
+}
+
+func (c *sftpConn) open(p string, flags uint32) ([]byte, error) {
+	body := appendString(nil, []byte(p))
+	body = appendU32(body, flags)
+	body = appendU32(body, 0) // 不设置属性
+	return c.expectHandle(sftpOpen, body)
+}
+
+func (c *sftpConn) close(handle []byte) error {
+	return c.expectStatus(sftpClose, appendString(nil, handle))
+}
+
+func (c *sftpConn) write(handle []byte, offset uint64, data []byte) error {
+	body := appendString(nil, handle)
+	body = binary.BigEndian.AppendUint64(body, offset)
+	body = appendString(body, data)
+	return c.expectStatus(sftpWrite, body)
+}
+
+func (c *sftpConn) read(handle []byte, offset uint64, n uint32) ([]byte, error) {
+	body := appendString(nil, handle)
+	body = binary.BigEndian.AppendUint64(body, offset)
+	body = appendU32(body, n)
+	rtyp, payload, err := c.request(sftpRead, body)
+	if err != nil {
+		return nil, err
+	}
+	switch rtyp {
+	case sftpData:
+		data, _, ok := readString(payload)
+		if !ok {
+			return nil, errors.New("SFTP 数据报文格式错误")
+		}
+		return data, nil
+	case sftpStatus:
+		if err := statusError(payload); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("SFTP 意外的响应类型 %d", rtyp)
+}
+
+func (c *sftpConn) remove(p string) error {
+	return c.expectStatus(sftpRemove, appendString(nil, []byte(p)))
+}
+
+func (c *sftpConn) rename(from, to string) error {
+	body := appendString(nil, []byte(from))
+	body = appendString(body, []byte(to))
+	return c.expectStatus(sftpRename, body)
+}
+
+// mkdirAll 逐级创建目录，已存在时服务器返回失败状态，忽略即可
+func (c *sftpConn) mkdirAll(dir string) {
+	cur := ""
+	if strings.HasPrefix(dir, "/") {
+		cur = "/"
+	}
+	for _, part := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		cur = path.Join(cur, part)
+		body := appendString(nil, []byte(cur))
+		body = appendU32(body, 0)
+		c.expectStatus(sftpMkdir, body)
+	}
+}
+
+func (c *sftpConn) readDir(dir string) ([]sftpEntry, error) {
+	handle, err := c.expectHandle(sftpOpendir, appendString(nil, []byte(dir)))
+	if err != nil {
+		return nil, err
+	}
+	defer c.close(handle)
+
+	var entries []sftpEntry
+	for {
+		rtyp, payload, err := c.request(sftpReaddir, appendString(nil, handle))
+		if err != nil {
+			return nil, err
+		}
+		if rtyp == sftpStatus {
+			if err := statusError(payload); err != nil && err != io.EOF {
+				return nil, err
+			}
+			return entries, nil
+		}
+		if rtyp != sftpName {
+			return nil, fmt.Errorf("SFTP 意外的响应类型 %d", rtyp)
+		}
+		batch, err := parseNames(payload)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, batch...)
+	}
+}
+
+// parseNames 解析 NAME 报文：count 个 (filename, longname, attrs)
+func parseNames(payload []byte) ([]sftpEntry, error) {
+	bad := errors.New("SFTP 目录报文格式错误")
+	count, rest, ok := readU32(payload)
+	if !ok {
+		return nil, bad
+	}
+	entries := make([]sftpEntry, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var name []byte
+		if name, rest, ok = readString(rest); !ok {
+			return nil, bad
+		}
+		if _, rest, ok = readString(rest); !ok {
+			return nil, bad
+		}
+		e := sftpEntry{name: string(name)}
+		if rest, ok = parseAttrs(rest, &e); !ok {
+			return nil, bad
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseAttrs(b []byte, e *sftpEntry) ([]byte, bool) {
+	flags, b, ok := readU32(b)
+	if !ok {
+		return nil, false
+	}
+	if flags&sftpAttrSize != 0 {
+		if len(b) < 8 {
+			return nil, false
+		}
+		e.size = int64(binary.BigEndian.Uint64(b))
+		b = b[8:]
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		if len(b) < 8 {
+			return nil, false
+		}
+		b = b[8:]
+	}
+	if flags&sftpAttrPerm != 0 {
+		var perm uint32
+		if perm, b, ok = readU32(b); !ok {
+			return nil, false
+		}
+		e.dir = perm&sftpModeTypeBits == sftpModeDirBit
+	}
+	if flags&sftpAttrTime != 0 {
+		if len(b) < 8 {
+			return nil, false
+		}
+		e.mtime = time.Unix(int64(binary.BigEndian.Uint32(b[4:])), 0)
+		b = b[8:]
+	}
+	if flags&sftpAttrExtended != 0 {
+		var n uint32
+		if n, b, ok = readU32(b); !ok {
+			return nil, false
+		}
+		for i := uint32(0); i < n*2; i++ {
+			if _, b, ok = readString(b); !ok {
+				return nil, false
+			}
+		}
+	}
+	return b, true
+}
+
+func appendU32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func appendString(b []byte, s []byte) []byte {
+	b = appendU32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func readU32(b []byte) (uint32, []byte, bool) {
+	if len(b) < 4 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(b), b[4:], true
+}
+
+func readString(b []byte) ([]byte, []byte, bool) {
+	n, rest, ok := readU32(b)
+	if !ok || uint32(len(rest)) < n {
+		return nil, nil, false
+	}
+	return rest[:n], rest[n:], true
+}
@@ -0,0 +1,55 @@
+package backupstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 备份文件在目标中不存在
+var ErrNotFound = errors.New("备份文件不存在")
+
+// RemoteFile 目标中的一个备份文件
+type RemoteFile struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Target 备份上传目标，文件名不含目录，所有文件位于目标配置的同一目录下
+type Target interface {
+	Name() string
+	Upload(ctx context.Context, name string, r io.Reader, size int64) error
+	Download(ctx context.Context, name string, w io.Writer) error
+	List(ctx context.Context) ([]RemoteFile, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// ValidName 校验备份文件名，禁止路径分隔符与相对路径
+func ValidName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("无效的备份文件名: %s", name)
+	}
+	return nil
+}
+
+// Probe 向目标写入、列出并删除一个探测文件，确认配置可用
+func Probe(ctx context.Context, t Target) error {
+	name := fmt.Sprintf(".baihu-probe-%d", time.Now().UnixNano())
+	data := []byte("baihu backup probe")
+	if err := t.Upload(ctx, name, bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("写入失败: %w", err)
+	}
+	if _, err := t.List(ctx); err != nil {
+		t.Delete(ctx, name)
+		return fmt.Errorf("列出文件失败: %w", err)
+	}
+	if err := t.Delete(ctx, name); err != nil {
+		return fmt.Errorf("删除失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package backupstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// exerciseTarget 依次上传、列出、下载、删除，检查目标的基本行为
+func exerciseTarget(t *testing.T, target Target) {
+	t.Helper()
+	ctx := context.Background()
+	data := []byte("PK backup content")
+	for _, name := range []string{"baihu_backup_20260101_030000.zip", "baihu_backup_20260102_030000.zip"} {
+		if err := target.Upload(ctx, name, bytes.NewReader(data), int64(len(data))); err != nil {
+			t.Fatalf("Upload %s: %v", name, err)
+		}
+	}
+
+	files, err := target.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+		if f.Size != int64(len(data)) {
+			t.Errorf("%s size = %d", f.Name, f.Size)
+		}
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "baihu_backup_20260101_030000.zip,baihu_backup_20260102_030000.zip" {
+		t.Fatalf("List = %v", names)
+	}
+
+	var buf bytes.Buffer
+	if err := target.Download(ctx, "baihu_backup_20260101_030000.zip", &buf); err != nil || buf.String() != string(data) {
+		t.Fatalf("Download = %q, %v", buf.String(), err)
+	}
+	if err := target.Delete(ctx, "baihu_backup_20260101_030000.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Download(ctx, "baihu_backup_20260101_030000.zip", io.Discard); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted file should be not found, got %v", err)
+	}
+	if err := target.Upload(ctx, "../escape.zip", bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("path traversal name should be rejected")
+	}
+	if err := Probe(ctx, target); err != nil {
+		t.Errorf("Probe: %v", err)
+	}
+}
+
+func TestLocalTarget(t *testing.T) {
+	exerciseTarget(t, NewLocalTarget(t.TempDir()+"/nested"))
+}
+
+// fakeWebDAV 只支持单层目录的最小 WebDAV 服务
+type fakeWebDAV struct {
+	mu      sync.Mutex
+	root    string
+	created bool
+	files   map[string][]byte
+}
+
+func (f *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, _ := r.BasicAuth(); user != "dav" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := strings.TrimPrefix(r.URL.Path, f.root)
+	switch r.Method {
+	case "MKCOL":
+		if f.created {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		f.created = true
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		if !f.created {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.files[name] = data
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		data, ok := f.files[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.files, name)
+		w.WriteHeader(http.StatusNoContent)
+	case "PROPFIND":
+		if r.Header.Get("Depth") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`)
+		fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:resourcetype><d:collection/></d:resourcetype></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, f.root)
+		for name, data := range f.files {
+			fmt.Fprintf(&b, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getcontentlength>%d</d:getcontentlength>`+
+				`<d:getlastmodified>Thu, 01 Jan 2026 03:00:00 GMT</d:getlastmodified><d:resourcetype/></d:prop>`+
+				`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, path.Join(f.root, name), len(data))
+		}
+		b.WriteString(`</d:multistatus>`)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(b.String()))
+	}
+}
+
+func TestWebDAVTarget(t *testing.T) {
+	srv := httptest.NewServer(&fakeWebDAV{root: "/dav/backups/", files: map[string][]byte{}})
+	defer srv.Close()
+
+	target, err := NewWebDAVTarget(srv.URL+"/dav/backups", "dav", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseTarget(t, target)
+
+	if _, err := NewWebDAVTarget("ftp://example.com", "", ""); err == nil {
+		t.Error("non-http url should be rejected")
+	}
+}
+
+// fakeS3 最小对象存储，支持路径风格的对象读写与 ListObjectsV2
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[key] = data
+	case http.MethodGet:
+		if r.URL.Query().Get("list-type") == "2" {
+			prefix := r.URL.Query().Get("prefix")
+			var b strings.Builder
+			b.WriteString(`<ListBucketResult>`)
+			for k, data := range f.objects {
+				if rest, ok := strings.CutPrefix(k, prefix); ok && !strings.Contains(rest, "/") {
+					fmt.Fprintf(&b, `<Contents><Key>%s</Key><Size>%d</Size><LastModified>2026-01-01T03:00:00.000Z</LastModified></Contents>`, k, len(data))
+				}
+			}
+			b.WriteString(`<IsTruncated>false</IsTruncated></ListBucketResult>`)
+			w.Write([]byte(b.String()))
+			return
+		}
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Target(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{"baihu/backups/nested/other.zip": nil}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	target, err := NewS3Target(S3Config{Endpoint: srv.URL, Bucket: "bucket", Prefix: "/baihu/backups/", AccessKey: "minio", SecretKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseTarget(t, target)
+	if _, ok := fake.objects["baihu/backups/baihu_backup_20260102_030000.zip"]; !ok {
+		t.Errorf("unexpected object keys: %v", fake.objects)
+	}
+}
+
+func TestParseSFTPNames(t *testing.T) {
+	entry := func(name string, size uint64, perm uint32, mtime uint32) []byte {
+		b := appendString(nil, []byte(name))
+		b = appendString(b, []byte("-rw-r--r-- 1 u g "+name))
+		b = appendU32(b, sftpAttrSize|sftpAttrUIDGID|sftpAttrPerm|sftpAttrTime)
+		b = binary.BigEndian.AppendUint64(b, size)
+		b = appendU32(appendU32(b, 1000), 1000)
+		b = appendU32(b, perm)
+		b = appendU32(appendU32(b, mtime), mtime)
+		return b
+	}
+	payload := appendU32(nil, 2)
+	payload = append(payload, entry("baihu_backup_20260101_030000.zip", 1234, 0100644, 1767236400)...)
+	payload = append(payload, entry("old", 4096, 0040755, 1767236400)...)
+
+	entries, err := parseNames(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].size != 1234 || entries[0].dir || !entries[1].dir {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].mtime.Unix() != 1767236400 {
+		t.Errorf("mtime = %v", entries[0].mtime)
+	}
+	if _, err := parseNames(payload[:len(payload)-3]); err == nil {
+		t.Error("truncated payload should fail")
+	}
+}
+
+func TestSFTPHostKeyRequired(t *testing.T) {
+	base := SFTPConfig{Address: "127.0.0.1", Username: "u", Password: "p"}
+	if _, err := NewSFTPTarget(base); !errors.Is(err, ErrSFTPHostKeyRequired) {
+		t.Errorf("missing host key: got %v, want ErrSFTPHostKeyRequired", err)
+	}
+	bad := base
+	bad.HostKey = "MD5:aa:bb"
+	if _, err := NewSFTPTarget(bad); err == nil {
+		t.Error("non-SHA256 fingerprint should be rejected")
+	}
+	for _, cfg := range []SFTPConfig{
+		{Address: "127.0.0.1", Username: "u", Password: "p", HostKey: "SHA256:abc"},
+		{Address: "127.0.0.1", Username: "u", Password: "p", InsecureHostKey: true},
+	} {
+		if _, err := NewSFTPTarget(cfg); err != nil {
+			t.Errorf("NewSFTPTarget(%+v): %v", cfg, err)
+		}
+	}
+}
+
+func TestSFTPHostKey(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &ssh.ServerConfig{NoClientAuth: true}
+	server.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ssh.NewServerConn(conn, server)
+	}()
+
+	got, err := SFTPHostKey(context.Background(), ln.Addr().String())
+	if want := ssh.FingerprintSHA256(signer.PublicKey()); err != nil || got != want {
+		t.Errorf("SFTPHostKey = %q, %v; want %q", got, err, want)
+	}
+}
@@ -0,0 +1,220 @@
+package backupstore
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// WebDAVTarget WebDAV 目录，可对接坚果云、Nextcloud、Alist 等
+type WebDAVTarget struct {
+	base     *url.URL // 以 / 结尾的目录地址
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAVTarget 创建 WebDAV 目标，rawURL 为存放备份的目录地址
+func NewWebDAVTarget(rawURL, username, password string) (*WebDAVTarget, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的 WebDAV 地址: %s", rawURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	return &WebDAVTarget{base: u, username: username, password: password, client: &http.Client{}}, nil
+}
+
+func (t *WebDAVTarget) Name() string { return constant.BackupTargetWebDAV }
+
+func (t *WebDAVTarget) fileURL(name string) string {
+	u := *t.base
+	u.Path += name
+	return u.String()
+}
+
+func (t *WebDAVTarget) do(ctx context.Context, method, target string, body io.Reader, size int64, header map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return t.client.Do(req)
+}
+
+// mkcol 创建备份目录，目录已存在时服务端返回 405
+func (t *WebDAVTarget) mkcol(ctx context.Context) error {
+	resp, err := t.do(ctx, "MKCOL", t.base.String(), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusMethodNotAllowed {
+		return webdavError(resp)
+	}
+	return nil
+}
+
+func (t *WebDAVTarget) Upload(ctx context.Context, name string, r io.Reader, size int64) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodPut, t.fileURL(name), r, size, map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 目录不存在时创建后重试，只能重试可回读的内容
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusNotFound {
+		seeker, ok := r.(io.Seeker)
+		if !ok {
+			return webdavError(resp)
+		}
+		if err := t.mkcol(ctx); err != nil {
+			return err
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		resp2, err := t.do(ctx, http.MethodPut, t.fileURL(name), r, size, map[string]string{"Content-Type": "application/octet-stream"})
+		if err != nil {
+			return err
+		}
+		defer resp2.Body.Close()
+		resp = resp2
+	}
+	if resp.StatusCode/100 != 2 {
+		return webdavError(resp)
+	}
+	return nil
+}
+
+func (t *WebDAVTarget) Download(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodGet, t.fileURL(name), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode/100 != 2 {
+		return webdavError(resp)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const davPropfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop></d:propfind>`
+
+func (t *WebDAVTarget) List(ctx context.Context) ([]RemoteFile, error) {
+	resp, err := t.do(ctx, "PROPFIND", t.base.String(), strings.NewReader(davPropfindBody), int64(len(davPropfindBody)),
+		map[string]string{"Depth": "1", "Content-Type": "application/xml; charset=utf-8"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode/100 != 2 {
+		return nil, webdavError(resp)
+	}
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("解析 WebDAV 目录失败: %v", err)
+	}
+
+	var files []RemoteFile
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			href = r.Href
+		}
+		if u, err := url.Parse(href); err == nil && u.Path != "" {
+			href = u.Path
+		}
+		name := path.Base(strings.TrimSuffix(href, "/"))
+		if strings.HasSuffix(href, "/") || ValidName(name) != nil {
+			continue
+		}
+		file := RemoteFile{Name: name}
+		isDir := false
+		for _, ps := range r.Propstat {
+			if ps.Status != "" && !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				isDir = true
+			}
+			if n, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err == nil {
+				file.Size = n
+			}
+			if tm, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+				file.ModTime = tm
+			}
+		}
+		if !isDir {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func (t *WebDAVTarget) Delete(ctx context.Context, name string) error {
+	if err := ValidName(name); err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodDelete, t.fileURL(name), nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 && resp.StatusCode != http.StatusNotFound {
+		return webdavError(resp)
+	}
+	return nil
+}
+
+func webdavError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("WebDAV 返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,44 @@
+package constant
+
+const (
+	// SectionAccess 访问控制设置分组
+	SectionAccess = "access"
+
+	// 各入口的 IP 允许列表，每行或逗号分隔一个 IP / CIDR，留空表示不限制
+	KeyAccessUIAllowlist           = "ui_allowlist"
+	KeyAccessOpenapiAllowlist      = "openapi_allowlist" // 同时作用于 /open2api/v1 与通知发送接口
+	KeyAccessAgentAllowlist        = "agent_allowlist"
+	KeyAccessInterconnectAllowlist = "interconnect_allowlist"
+
+	// 各入口每个 IP 每分钟允许的请求数，0 表示不限制
+	KeyAccessRateLogin        = "rate_login"
+	KeyAccessRateOpenapi      = "rate_openapi"
+	KeyAccessRateNotify       = "rate_notify"
+	KeyAccessRateAgent        = "rate_agent"
+	KeyAccessRateInterconnect = "rate_interconnect"
+
+	KeyAccessBanThreshold = "ban_threshold" // 一分钟内被限流拒绝的次数达到该值后封禁 IP，0 表示不封禁
+	KeyAccessBanMinutes   = "ban_minutes"   // 封禁时长（分钟）
+
+	// KeyAccessTrustedProxies 受信反向代理的 IP / CIDR，仅直连对端在其中时才采信 X-Forwarded-For / X-Real-IP，留空表示不信任任何代理
+	KeyAccessTrustedProxies = "trusted_proxies"
+
+	// KeyAccessLocalBreakGlass 为 true 时，关闭密码登录后仍允许本机直连（未经代理转发）使用本地账号应急登录，默认关闭
+	KeyAccessLocalBreakGlass = "local_break_glass"
+
+	// 访问入口
+	AccessSurfaceUI           = "ui"
+	AccessSurfaceOpenapi      = "openapi"
+	AccessSurfaceAgent        = "agent"
+	AccessSurfaceInterconnect = "interconnect"
+
+	// 限流范围，同一 IP 在不同范围内分别计数
+	RateScopeLogin        = "login"
+	RateScopeOpenapi      = "openapi"
+	RateScopeNotify       = "notify"
+	RateScopeAgent        = "agent"
+	RateScopeInterconnect = "interconnect"
+
+	// EventIPBanned IP 因频繁触发限流或暴力破解被封禁
+	EventIPBanned = "ip_banned"
+)
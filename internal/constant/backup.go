@@ -0,0 +1,48 @@
+package constant
+
+const (
+	// SectionBackup 备份设置分组，该分组不会被导出到备份包中
+	SectionBackup = "backup"
+
+	// 定时备份设置相关 Key
+	KeyBackupFile           = "backup_file"            // 最近一次手动备份的文件路径
+	KeyBackupScheduleEnable = "schedule_enabled"       // 是否开启定时备份
+	KeyBackupScheduleCron   = "schedule_cron"          // 定时备份的 cron 表达式，支持 5 位或 6 位
+	KeyBackupKeepLast       = "keep_last"              // 保留最近 N 份
+	KeyBackupKeepDaily      = "keep_daily"             // 额外保留最近 N 天每天最新的一份
+	KeyBackupKeepWeekly     = "keep_weekly"            // 额外保留最近 N 周每周最新的一份
+	KeyBackupExclude        = "exclude_tables"         // 不备份的数据，逗号分隔：task_logs、app_logs
+	KeyBackupPassphrase     = "passphrase"             // 定时备份的加密口令，留空时不加密
+	KeyBackupTarget         = "target"                 // 备份上传目标：local、webdav、s3、sftp
+	KeyBackupLocalDir       = "local_dir"              // 本地目录，留空时使用 data/backups
+	KeyBackupWebDAVURL      = "webdav_url"             // WebDAV 目录地址
+	KeyBackupWebDAVUser     = "webdav_username"        // WebDAV 用户名
+	KeyBackupWebDAVPassword = "webdav_password"        // WebDAV 密码
+	KeyBackupS3Endpoint     = "s3_endpoint"            // S3 兼容服务地址
+	KeyBackupS3Region       = "s3_region"              // 签名使用的区域
+	KeyBackupS3Bucket       = "s3_bucket"              // 存储桶
+	KeyBackupS3Prefix       = "s3_prefix"              // 对象键前缀
+	KeyBackupS3AccessKey    = "s3_access_key"          // Access Key
+	KeyBackupS3SecretKey    = "s3_secret_key"          // Secret Key
+	KeyBackupS3PathStyle    = "s3_path_style"          // 是否使用路径风格访问
+	KeyBackupSFTPAddress    = "sftp_address"           // SFTP 地址 host:port
+	KeyBackupSFTPUser       = "sftp_username"          // SFTP 用户名
+	KeyBackupSFTPPassword   = "sftp_password"          // SFTP 密码，与私钥二选一
+	KeyBackupSFTPKey        = "sftp_private_key"       // SFTP 私钥（PEM / OpenSSH 格式）
+	KeyBackupSFTPHostKey    = "sftp_host_key"          // 服务器公钥指纹（SHA256:...），必须填写
+	KeyBackupSFTPInsecure   = "sftp_insecure_host_key" // 为 true 时允许不填写指纹、不校验服务器身份
+	KeyBackupSFTPDir        = "sftp_dir"               // SFTP 远程目录
+	KeyBackupLastRunAt      = "last_run_at"            // 最近一次定时备份时间
+	KeyBackupLastFile       = "last_run_file"          // 最近一次定时备份的文件名
+	KeyBackupLastError      = "last_run_error"         // 最近一次定时备份的错误，成功时为空
+
+	// 备份上传目标
+	BackupTargetLocal  = "local"
+	BackupTargetWebDAV = "webdav"
+	BackupTargetS3     = "s3"
+	BackupTargetSFTP   = "sftp"
+
+	// 可排除的数据
+	BackupExcludeTaskLogs = "task_logs"
+	BackupExcludeAppLogs  = "app_logs"
+)
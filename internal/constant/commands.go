@@ -47,7 +47,23 @@ var Commands = []CommandSpec{
 	},
 	{
 		Name:        "restore",
-		Description: "从本地 zip 备份包全量恢复系统数据",
+		Description: "从本地 zip 备份包全量或选择性恢复系统数据",
+		Flags:       []string{"--dry-run", "--passphrase", "--tables", "--tasks", "--script-paths", "--no-scripts", "--mode", "--conflict"},
+	},
+	{
+		Name:        "apply",
+		Description: "按 Git 仓库中的 YAML 声明同步任务、变量、通知绑定与 Agent 分配",
+		Flags:       []string{"--dir", "--dry-run", "--prune"},
+	},
+	{
+		Name:        "ql-import",
+		Description: "从青龙面板的 data 目录或 database.sqlite 导入定时任务、环境变量、依赖与订阅",
+		Flags:       []string{"--dry-run", "--copy-scripts"},
+	},
+	{
+		Name:        "rotate-key",
+		Description: "轮换机密加密主密钥并重新加密全部机密与备份",
+		Flags:       []string{"--dry-run", "--skip-backups", "--encrypt-plaintext", "--yes"},
 	},
 	{
 		Name:        "builtininstall",
@@ -5,11 +5,42 @@ import "time"
 const (
 
 	// DefaultRole 默认用户角色
-	DefaultRole = "user"
+	DefaultRole = RoleViewer
 
 	// AdminRole 管理员角色
 	AdminRole = "admin"
 
+	// 用户角色（权限由低到高：viewer < operator < editor < admin）
+	RoleViewer   = "viewer"   // 只读：查看任务、日志
+	RoleOperator = "operator" // 运维：可运行/停止任务
+	RoleEditor   = "editor"   // 编辑：可编辑任务、脚本、环境变量
+	// RoleLegacyUser 旧版普通用户角色，按 viewer 处理
+	RoleLegacyUser = "user"
+
+	// OpenAPI 令牌权限范围
+	TokenScopeTasksRead    = "tasks:read"    // 查看任务
+	TokenScopeTasksWrite   = "tasks:write"   // 创建、修改、删除任务
+	TokenScopeTasksRun     = "tasks:run"     // 运行、停止任务
+	TokenScopeEnvRead      = "env:read"      // 查看环境变量
+	TokenScopeEnvWrite     = "env:write"     // 创建、修改、删除环境变量
+	TokenScopeScriptsRead  = "scripts:read"  // 查看脚本
+	TokenScopeScriptsWrite = "scripts:write" // 创建、修改、删除脚本
+	TokenScopeLogsRead     = "logs:read"     // 查看执行日志
+
+	// 请求认证方式，记录于审计日志
+	AuthMethodCookie       = "cookie"       // 面板登录会话
+	AuthMethodOpenapi      = "openapi"      // 站点全局 OpenAPI Token
+	AuthMethodApiToken     = "api_token"    // 命名 API 令牌
+	AuthMethodInterconnect = "interconnect" // 互联 Token
+
+	// 登录方式，记录于登录会话
+	LoginMethodPassword = "password" // 密码（含受信任设备免验证）
+	LoginMethodTOTP     = "totp"     // 密码 + 动态验证码
+	LoginMethodRecovery = "recovery" // 密码 + 恢复码
+	LoginMethodWebAuthn = "webauthn" // 密码 + 通行密钥
+	LoginMethodPasskey  = "passkey"  // 通行密钥免密码登录
+	LoginMethodSSO      = "sso"      // OIDC 单点登录
+
 	// DefaultTaskTimeout 默认任务超时时间（分钟）
 	DefaultTaskTimeout = 30
 
@@ -21,13 +52,17 @@ const (
 	SectionNotify       = "notify"
 
 	// Site Settings Key 常量
-	KeyTitle        = "title"
-	KeySubtitle     = "subtitle"
-	KeyIcon         = "icon"
-	KeyPageSize          = "page_size"
-	KeyCookieDays        = "cookie_days"
-	KeyOpenapiToken      = "openapi_token"
-	KeyActiveWebUI       = "active_webui"
+	KeyTitle               = "title"
+	KeySubtitle            = "subtitle"
+	KeyIcon                = "icon"
+	KeyPageSize            = "page_size"
+	KeyCookieDays          = "cookie_days"
+	KeyOpenapiToken        = "openapi_token"
+	KeyActiveWebUI         = "active_webui"
+	KeyTrustedDeviceDays   = "trusted_device_days"    // 受信任设备免两步验证天数，0 表示关闭
+	KeySessionIdleMinutes  = "session_idle_minutes"   // 会话空闲超时分钟数，0 表示不限制
+	KeyEnvExpiryRemindDays = "env_expiry_remind_days" // 机密到期前提前提醒的天数
+	KeyPublicURL           = "public_url"             // 面板对外访问地址（含 URL 前缀），用于通行密钥、SSO 回调与日志分享链接
 
 	// Security Settings Key 常量
 	KeySecret = "secret"
@@ -45,11 +80,14 @@ const (
 	KeyLoginLogMaxCount     = "login_log_max_count"
 	KeySchedulerLogDays     = "scheduler_log_days"
 	KeySchedulerLogMaxCount = "scheduler_log_max_count"
+	KeyAuditLogDays         = "audit_log_days"
 
 	// Scheduler Settings Key 常量
 	KeyWorkerCount  = "worker_count"
 	KeyQueueSize    = "queue_size"
 	KeyRateInterval = "rate_interval"
+	// KeySeparateStderr 分离标准错误输出，开启后不再使用 PTY，日志可区分 stdout 与 stderr
+	KeySeparateStderr = "separate_stderr"
 
 	// Notify Settings Key 常量
 	KeyNotifyChannels = "channels"
@@ -71,10 +109,19 @@ const (
 	KeyNotifyTemplateTaskTimeoutTitle     = "notify_template_task_timeout_title"
 	KeyNotifyTemplateTaskTimeoutText      = "notify_template_task_timeout_text"
 
+	// 通知模板格式变体后缀，由正文模板 Key 去掉 _text 后拼接，如 notify_template_task_success_markdown
+	NotifyTemplateSuffixMarkdown = "_markdown"
+	NotifyTemplateSuffixHTML     = "_html"
+
 	// 事件绑定类型
 	BindingTypeSystem = "system"
 	BindingTypeTask   = "task"
 
+	// 任务通知中执行日志的推送方式
+	NotifyLogModeInline = "inline" // 在正文末尾追加日志尾部（默认）
+	NotifyLogModeAttach = "attach" // 以附件发送完整日志，渠道不支持时回退为分享链接或正文
+	NotifyLogModeLink   = "link"   // 附带限时有效的日志分享链接
+
 	// 系统事件类型
 	EventUserLogin       = "user_login"
 	EventBruteForceLogin = "brute_force_login"
@@ -87,6 +134,7 @@ const (
 	EventTaskRunning   = "task_running"
 	EventTaskQueued    = "task_queued"
 	EventTaskCancelled = "task_cancelled"
+	EventTaskFinished  = "task_finished" // 任务日志已保存（含 Agent 任务），载荷为 *models.TaskLog
 
 	// 其他事件类型
 	EventSystemNotice = "system_notice"
@@ -179,6 +227,9 @@ const (
 // CookieName Cookie 名称
 var CookieName = "BHToken"
 
+// CookieTrustedDevice 受信任设备凭证的 Cookie 键名
+var CookieTrustedDevice = "BHTrustedDevice"
+
 // TablePrefix 表前缀，从配置文件读取
 var TablePrefix string
 
@@ -209,17 +260,21 @@ var DefaultIcon = `<svg t="1766107903919" class="icon" viewBox="0 0 1024 1024" v
 // DefaultSettings 默认系统设置
 var DefaultSettings = map[string]map[string]string{
 	SectionSite: {
-		KeyTitle:       "白虎面板",
-		KeySubtitle:    "极致轻量、高性能的自动化任务调度平台",
-		KeyIcon:        DefaultIcon,
-		KeyPageSize:    "10",
-		KeyCookieDays:  "7",
-		KeyActiveWebUI: "default",
+		KeyTitle:               "白虎面板",
+		KeySubtitle:            "极致轻量、高性能的自动化任务调度平台",
+		KeyIcon:                DefaultIcon,
+		KeyPageSize:            "10",
+		KeyCookieDays:          "7",
+		KeyActiveWebUI:         "default",
+		KeyTrustedDeviceDays:   "30",
+		KeySessionIdleMinutes:  "0",
+		KeyEnvExpiryRemindDays: "7",
 	},
 	SectionScheduler: {
-		KeyWorkerCount:  "4",
-		KeyQueueSize:    "100",
-		KeyRateInterval: "200",
+		KeyWorkerCount:    "4",
+		KeyQueueSize:      "100",
+		KeyRateInterval:   "200",
+		KeySeparateStderr: "false",
 	},
 	SectionNotify: {
 		KeyNotifyPrefix: "[白虎面板]",
@@ -238,4 +293,48 @@ var DefaultSettings = map[string]map[string]string{
 		KeyNotifyTemplateTaskTimeoutTitle: "任务[{{task_name}}] 超时",
 		KeyNotifyTemplateTaskTimeoutText:  "任务 #{{task_id}} {{task_name}}\n状态: 超时\n耗时: {{duration}}ms\n最后输出: {{output}}",
 	},
+	SectionOIDC: {
+		KeyOidcScopes:        "openid profile email",
+		KeyOidcUsernameClaim: "preferred_username",
+		KeyOidcRoleClaim:     "groups",
+		KeyOidcButtonText:    "使用 SSO 登录",
+	},
+	SectionSecretBackend: {
+		KeySecretCacheSeconds: "300",
+		KeySecretFileRoots:    "/run/secrets",
+	},
+	SectionAccess: {
+		KeyAccessRateLogin:        "10",
+		KeyAccessRateOpenapi:      "120",
+		KeyAccessRateNotify:       "60",
+		KeyAccessRateAgent:        "600",
+		KeyAccessRateInterconnect: "300",
+		KeyAccessBanThreshold:     "30",
+		KeyAccessBanMinutes:       "15",
+		KeyAccessLocalBreakGlass:  "false",
+	},
+	SectionLogStore: {
+		KeyLogStoreBackend:     LogStoreFS,
+		KeyLogStoreS3Region:    "us-east-1",
+		KeyLogStoreS3Prefix:    "baihu/logs",
+		KeyLogStoreS3PathStyle: "true",
+	},
+	SectionBackup: {
+		KeyBackupScheduleEnable: "false",
+		KeyBackupScheduleCron:   "0 3 * * *",
+		KeyBackupKeepLast:       "7",
+		KeyBackupKeepDaily:      "0",
+		KeyBackupKeepWeekly:     "0",
+		KeyBackupTarget:         BackupTargetLocal,
+		KeyBackupS3Region:       "us-east-1",
+		KeyBackupS3Prefix:       "baihu/backups",
+		KeyBackupS3PathStyle:    "true",
+	},
+	SectionGitOps: {
+		KeyGitOpsEnabled:   "false",
+		KeyGitOpsProxy:     "none",
+		KeyGitOpsSchedule:  "*/30 * * * *",
+		KeyGitOpsAutoApply: "false",
+		KeyGitOpsPrune:     "false",
+	},
 }
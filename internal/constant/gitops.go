@@ -0,0 +1,25 @@
+package constant
+
+const (
+	// SectionGitOps 声明式配置同步设置分组
+	SectionGitOps = "gitops"
+
+	KeyGitOpsEnabled     = "enabled"        // 是否开启定时同步
+	KeyGitOpsRepoURL     = "repo_url"       // Git 仓库地址
+	KeyGitOpsBranch      = "branch"         // 分支，留空时使用仓库默认分支
+	KeyGitOpsPath        = "path"           // 仓库内存放 YAML 的目录，留空时为仓库根目录
+	KeyGitOpsAuthToken   = "auth_token"     // 私有仓库的访问 Token
+	KeyGitOpsProxy       = "proxy"          // 代理类型：none、ghproxy、mirror、custom
+	KeyGitOpsProxyURL    = "proxy_url"      // 自定义代理地址
+	KeyGitOpsSchedule    = "schedule"       // 定时同步的 cron 表达式，支持 5 位或 6 位
+	KeyGitOpsAutoApply   = "auto_apply"     // 定时同步时是否自动应用差异，关闭时只检测差异
+	KeyGitOpsPrune       = "prune"          // 是否删除仓库中已移除的、由同步创建的资源
+	KeyGitOpsLastRunAt   = "last_run_at"    // 最近一次同步时间
+	KeyGitOpsLastCommit  = "last_commit"    // 最近一次同步的提交
+	KeyGitOpsLastSummary = "last_summary"   // 最近一次同步的差异摘要
+	KeyGitOpsLastError   = "last_run_error" // 最近一次同步的错误，成功时为空
+
+	// GitOps 管理的资源类型
+	GitOpsKindTask = "task"
+	GitOpsKindEnv  = "env"
+)
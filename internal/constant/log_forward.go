@@ -0,0 +1,12 @@
+package constant
+
+const (
+	// 日志转发目标类型
+	LogForwardSyslog = "syslog" // RFC5424 syslog，经 UDP 或 TCP 发送
+	LogForwardLoki   = "loki"   // Grafana Loki push API
+	LogForwardHTTP   = "http"   // 通用 HTTP 接收端，请求体为 JSON Lines
+
+	// 日志转发来源
+	LogForwardSourceTask   = "task"    // 任务执行日志
+	LogForwardSourceAppLog = "app_log" // 系统通知、调度日志等应用日志
+)
@@ -0,0 +1,22 @@
+package constant
+
+const (
+	// SectionLogStore 任务日志正文存储设置分组
+	SectionLogStore = "log_store"
+
+	// 日志存储设置相关 Key
+	KeyLogStoreBackend     = "backend"       // 正文存储后端：db 保留在数据库，fs 本地文件，s3 对象存储
+	KeyLogStoreFSDir       = "fs_dir"        // 本地文件存储目录，留空时使用 data/logs
+	KeyLogStoreS3Endpoint  = "s3_endpoint"   // S3 兼容服务地址，如 https://s3.amazonaws.com 或 http://minio:9000
+	KeyLogStoreS3Region    = "s3_region"     // 签名使用的区域，MinIO 等通常为 us-east-1
+	KeyLogStoreS3Bucket    = "s3_bucket"     // 存储桶
+	KeyLogStoreS3Prefix    = "s3_prefix"     // 对象键前缀
+	KeyLogStoreS3AccessKey = "s3_access_key" // Access Key
+	KeyLogStoreS3SecretKey = "s3_secret_key" // Secret Key
+	KeyLogStoreS3PathStyle = "s3_path_style" // 是否使用路径风格访问（endpoint/bucket/key），MinIO 需开启
+
+	// 日志存储后端
+	LogStoreDB = "db"
+	LogStoreFS = "fs"
+	LogStoreS3 = "s3"
+)
@@ -0,0 +1,24 @@
+package constant
+
+const (
+	// SectionOIDC OIDC 单点登录设置分组
+	SectionOIDC = "oidc"
+
+	// OIDC 设置相关 Key
+	KeyOidcEnabled         = "enabled"
+	KeyOidcIssuer          = "issuer"        // Issuer 或 Discovery 地址（.well-known/openid-configuration 可省略）
+	KeyOidcClientID        = "client_id"     // 客户端 ID
+	KeyOidcClientSecret    = "client_secret" // 客户端密钥
+	KeyOidcScopes          = "scopes"        // 申请的 scope，空格或逗号分隔
+	KeyOidcRedirectURL     = "redirect_url"  // 回调地址，留空时按面板对外地址或请求地址推导
+	KeyOidcUsernameClaim   = "username_claim"
+	KeyOidcRoleClaim       = "role_claim"   // 用于映射角色的 claim，如 groups、email
+	KeyOidcRoleMapping     = "role_mapping" // 每行一条 "claim 值=角色"，支持通配符，如 admins=admin、*@corp.com=viewer
+	KeyOidcDefaultRole     = "default_role" // 未匹配任何映射时的角色，留空表示拒绝登录
+	KeyOidcAutoProvision   = "auto_provision"
+	KeyOidcDisablePassword = "disable_password_login"
+	KeyOidcButtonText      = "button_text"
+
+	// CookieOidcState 登录跳转期间保存 state/nonce/PKCE 的 Cookie 键名
+	CookieOidcState = "BHOidcState"
+)
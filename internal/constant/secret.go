@@ -0,0 +1,15 @@
+package constant
+
+const (
+	// SectionSecretBackend 外部机密后端设置分组
+	SectionSecretBackend = "secret_backend"
+
+	// 外部机密后端设置相关 Key
+	KeySecretCacheSeconds   = "cache_seconds"   // 解析结果缓存秒数，0 表示不缓存
+	KeySecretFileRoots      = "file_roots"      // file:// 引用允许读取的目录，每行一个
+	KeySecretVaultAddr      = "vault_addr"      // Vault 地址，留空时读取 VAULT_ADDR
+	KeySecretVaultToken     = "vault_token"     // Vault Token，留空时读取 VAULT_TOKEN
+	KeySecretVaultNamespace = "vault_namespace" // Vault 企业版命名空间
+	KeySecretExecHelpers    = "exec_helpers"    // exec:// 引用可调用的解密命令，每行一条「名称=命令」
+	KeySecretEnvAllow       = "env_allow"       // env:// 引用允许读取的变量名，每行一个，末尾 * 表示前缀；留空不允许读取任何变量
+)
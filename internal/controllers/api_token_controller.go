@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/middleware"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiTokenController struct {
+	tokenService *services.ApiTokenService
+}
+
+func NewApiTokenController(tokenService *services.ApiTokenService) *ApiTokenController {
+	return &ApiTokenController{tokenService: tokenService}
+}
+
+// ListTokens 获取 OpenAPI 令牌列表
+// @Summary 获取 OpenAPI 令牌列表
+// @Description 获取全部命名令牌及可分配的权限范围，不包含令牌明文
+// @Tags API 令牌
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api-tokens [get]
+func (tc *ApiTokenController) ListTokens(c *gin.Context) {
+	utils.Success(c, gin.H{"tokens": tc.tokenService.ListTokens(), "scopes": services.ApiTokenScopes})
+}
+
+// CreateToken 创建 OpenAPI 令牌
+// @Summary 创建 OpenAPI 令牌
+// @Description 创建命名令牌，明文仅在本次响应中返回
+// @Tags API 令牌
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api-tokens [post]
+func (tc *ApiTokenController) CreateToken(c *gin.Context) {
+	if constant.DemoMode {
+		utils.BadRequest(c, "演示模式下不能创建令牌")
+		return
+	}
+
+	var req struct {
+		Name      string   `json:"name" binding:"required"`
+		Scopes    []string `json:"scopes"`
+		Tags      string   `json:"tags"`
+		TaskIDs   string   `json:"task_ids"`
+		ExpiresAt string   `json:"expires_at"` // 格式: 2006-01-02，当天结束时过期
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.ExpiresAt, time.Local)
+		if err != nil {
+			utils.BadRequest(c, "过期时间格式错误")
+			return
+		}
+		t = t.Add(24*time.Hour - time.Second)
+		expiresAt = &t
+	}
+
+	// 令牌的标签与任务范围不能超出创建者自身的范围
+	if !middleware.GetAccessScope(c).Covers(req.Tags, req.TaskIDs) {
+		utils.Forbidden(c, "令牌的标签或任务范围超出了您的权限范围")
+		return
+	}
+
+	plain, token, err := tc.tokenService.CreateToken(services.ApiTokenCreate{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Tags:      req.Tags,
+		TaskIDs:   req.TaskIDs,
+		ExpiresAt: expiresAt,
+		CreatedBy: c.GetString("userID"),
+	})
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	recordAudit(c, services.AuditEntry{Action: "api_token.create", ResourceType: "api_token", ResourceID: token.ID, ResourceName: token.Name, After: token})
+	utils.Success(c, gin.H{"token": plain, "item": token})
+}
+
+// UpdateToken 启用或吊销 OpenAPI 令牌
+// @Summary 启用或吊销 OpenAPI 令牌
+// @Tags API 令牌
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "令牌ID"
+// @Success 200 {object} utils.Response
+// @Router /api-tokens/{id} [put]
+func (tc *ApiTokenController) UpdateToken(c *gin.Context) {
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+
+	if err := tc.tokenService.SetEnabled(c.Param("id"), req.Enabled); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "api_token.update", ResourceType: "api_token", ResourceID: c.Param("id"), After: gin.H{"enabled": req.Enabled}})
+	utils.SuccessMsg(c, "已保存")
+}
+
+// DeleteToken 删除 OpenAPI 令牌
+// @Summary 删除 OpenAPI 令牌
+// @Tags API 令牌
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "令牌ID"
+// @Success 200 {object} utils.Response
+// @Router /api-tokens/{id} [delete]
+func (tc *ApiTokenController) DeleteToken(c *gin.Context) {
+	if err := tc.tokenService.DeleteToken(c.Param("id")); err != nil {
+		utils.ServerError(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "api_token.delete", ResourceType: "api_token", ResourceID: c.Param("id")})
+	utils.SuccessMsg(c, "删除成功")
+}
+
+// GetTokenUsage 获取令牌调用记录
+// @Summary 获取令牌调用记录
+// @Description 分页获取指定令牌的调用记录（保留 30 天）
+// @Tags API 令牌
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "令牌ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} utils.Response{data=utils.PaginationData{data=[]models.ApiTokenLog}}
+// @Router /api-tokens/{id}/usage [get]
+func (tc *ApiTokenController) GetTokenUsage(c *gin.Context) {
+	p := utils.ParsePagination(c)
+	logs, total := tc.tokenService.ListUsage(c.Param("id"), p.Page, p.PageSize)
+	utils.PaginatedResponse(c, logs, total, p)
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/middleware"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditExportLimit 单次导出的最大条数
+const auditExportLimit = 100000
+
+// auditService 各控制器共用的审计日志服务
+var auditService = services.NewAuditService()
+
+// recordAudit 以当前请求的操作者身份追加一条审计日志
+func recordAudit(c *gin.Context, e services.AuditEntry) {
+	auditService.Record(middleware.GetAuditActor(c), e)
+}
+
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+func NewAuditController() *AuditController {
+	return &AuditController{auditService: auditService}
+}
+
+func parseAuditQuery(c *gin.Context) services.AuditQuery {
+	return services.AuditQuery{
+		Keyword:      c.Query("keyword"),
+		Username:     c.Query("username"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resource_type"),
+		AuthMethod:   c.Query("auth_method"),
+		Start:        c.Query("start"),
+		End:          c.Query("end"),
+	}
+}
+
+// GetAuditLogs 查询审计日志
+// @Summary 查询审计日志
+// @Description 按操作者、操作、资源类型、认证方式、时间范围分页查询审计日志
+// @Tags 审计日志
+// @Produce json
+// @Security BearerAuth
+// @Param keyword query string false "关键字（用户、资源、操作、IP）"
+// @Param username query string false "操作者"
+// @Param action query string false "操作前缀，如 task 或 task.update"
+// @Param resource_type query string false "资源类型"
+// @Param auth_method query string false "认证方式"
+// @Param start query string false "开始日期 YYYY-MM-DD"
+// @Param end query string false "结束日期 YYYY-MM-DD"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} utils.Response{data=utils.PaginationData{data=[]models.AuditLog}}
+// @Router /audit-logs [get]
+func (ac *AuditController) GetAuditLogs(c *gin.Context) {
+	p := utils.ParsePagination(c)
+	logs, total, err := ac.auditService.List(parseAuditQuery(c), p.Page, p.PageSize)
+	if err != nil {
+		utils.ServerError(c, "查询审计日志失败")
+		return
+	}
+	utils.PaginatedResponse(c, logs, total, p)
+}
+
+// ExportAuditLogs 导出审计日志
+// @Summary 导出审计日志
+// @Description 按查询条件导出审计日志，format 为 csv（默认）或 json
+// @Tags 审计日志
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param format query string false "导出格式 csv/json"
+// @Router /audit-logs/export [get]
+func (ac *AuditController) ExportAuditLogs(c *gin.Context) {
+	logs, err := ac.auditService.Export(parseAuditQuery(c), auditExportLimit)
+	if err != nil {
+		utils.ServerError(c, "导出审计日志失败")
+		return
+	}
+
+	filename := fmt.Sprintf("audit_logs_%s", time.Now().Format("20060102_150405"))
+	if c.Query("format") == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.json", filename))
+		c.Header("Content-Type", "application/json")
+		_ = json.NewEncoder(c.Writer).Encode(logs)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", filename))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	// 写入 BOM，便于 Excel 识别 UTF-8
+	_, _ = c.Writer.Write([]byte("\xEF\xBB\xBF"))
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"时间", "用户", "认证方式", "令牌", "IP", "操作", "资源类型", "资源ID", "资源名称", "变更"})
+	for _, l := range logs {
+		_ = w.Write([]string{
+			time.Time(l.CreatedAt).Format("2006-01-02 15:04:05"),
+			l.Username, l.AuthMethod, l.TokenName, l.IP, l.Action,
+			l.ResourceType, l.ResourceID, l.ResourceName, string(l.Diff),
+		})
+	}
+	w.Flush()
+}
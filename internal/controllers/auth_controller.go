@@ -2,13 +2,17 @@ package controllers
 
 import (
 	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/eventbus"
 	"github.com/engigu/baihu-panel/internal/middleware"
+	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/services"
 	"github.com/engigu/baihu-panel/internal/utils"
 
@@ -20,6 +24,9 @@ type AuthController struct {
 	userService     *services.UserService
 	settingsService *services.SettingsService
 	loginLogService *services.LoginLogService
+	oidcService     *services.OIDCService
+	mfaService      *services.MFAService
+	sessionService  *services.SessionService
 }
 
 type loginAttempt struct {
@@ -45,11 +52,14 @@ func init() {
 	}()
 }
 
-func NewAuthController(userService *services.UserService, settingsService *services.SettingsService, loginLogService *services.LoginLogService) *AuthController {
+func NewAuthController(userService *services.UserService, settingsService *services.SettingsService, loginLogService *services.LoginLogService, oidcService *services.OIDCService, mfaService *services.MFAService, sessionService *services.SessionService) *AuthController {
 	return &AuthController{
 		userService:     userService,
 		settingsService: settingsService,
 		loginLogService: loginLogService,
+		oidcService:     oidcService,
+		mfaService:      mfaService,
+		sessionService:  sessionService,
 	}
 }
 
@@ -67,6 +77,12 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	// 开启 SSO 并关闭密码登录后，仅在开启本机应急登录时保留本机直连（不经代理转发）的密码登录
+	if ac.oidcService.PasswordLoginDisabled() && !services.GetAccessService().LocalBreakGlass(c.GetBool("localRequest")) {
+		utils.Forbidden(c, "已关闭密码登录，请使用 SSO 登录")
+		return
+	}
+
 	// 暴力破解防御
 	if val, ok := loginAttempts.Load(ip); ok {
 		attempt := val.(*loginAttempt)
@@ -114,31 +130,7 @@ func (ac *AuthController) Login(c *gin.Context) {
 	// 登录成功，清除尝试记录
 	loginAttempts.Delete(ip)
 
-	// 检查是否开启了两步验证
-	if user.OtpEnabled {
-		// 生成临时待验证 OTP 的 token，有效期 5 分钟
-		pendingToken, err := utils.GenerateOtpPendingToken(user.ID, constant.Secret)
-		if err != nil {
-			utils.ServerError(c, "生成临时凭证失败")
-			return
-		}
-		utils.Success(c, gin.H{
-			"require_otp":       true,
-			"otp_pending_token": pendingToken,
-		})
-		return
-	}
-
-	expireDays := 7
-	if days := ac.settingsService.Get(constant.SectionSite, constant.KeyCookieDays); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			expireDays = d
-		}
-	}
-
-	// 生成 token
-	token, err := utils.GenerateToken(user.ID, user.Username, user.TokenVersion, expireDays, constant.Secret)
-	if err != nil {
+	if user.Disabled {
 		eventbus.DefaultBus.Publish(eventbus.Event{
 			Type: constant.EventUserLogin,
 			Payload: map[string]interface{}{
@@ -146,9 +138,41 @@ func (ac *AuthController) Login(c *gin.Context) {
 				"username":  req.Username,
 				"userAgent": userAgent,
 				"status":    "failed",
-				"message":   "Token生成失败",
+				"message":   "账户已被禁用",
 			},
 		})
+		utils.Forbidden(c, "账户已被禁用")
+		return
+	}
+
+	// 检查是否开启了两步验证，受信任设备在有效期内免验证
+	if ac.mfaService.HasSecondFactor(user) {
+		trusted, _ := c.Cookie(constant.CookieTrustedDevice)
+		if !ac.mfaService.IsTrustedDevice(user, trusted) {
+			// 生成临时待验证 OTP 的 token，有效期 5 分钟
+			pendingToken, err := utils.GenerateOtpPendingToken(user.ID, constant.Secret)
+			if err != nil {
+				utils.ServerError(c, "生成临时凭证失败")
+				return
+			}
+			utils.Success(c, gin.H{
+				"require_otp":       true,
+				"otp_pending_token": pendingToken,
+				"methods":           ac.mfaService.Methods(user),
+				"trust_device_days": ac.mfaService.TrustedDeviceDays(),
+			})
+			return
+		}
+	}
+
+	ac.completeLogin(c, user, constant.LoginMethodPassword, "登录成功")
+}
+
+// completeLogin 创建会话、签发登录凭证、记录登录日志并返回当前用户
+func (ac *AuthController) completeLogin(c *gin.Context, user *models.User, method, message string) {
+	token, expireDays, err := ac.issueToken(c, user, method)
+	if err != nil {
+		ac.publishLogin(c, user.Username, "failed", "Token生成失败")
 		utils.ServerError(c, "登录失败")
 		return
 	}
@@ -157,25 +181,228 @@ func (ac *AuthController) Login(c *gin.Context) {
 	middleware.SetAuthCookie(c, token, expireDays)
 
 	// 记录登录成功日志
+	ac.publishLogin(c, user.Username, "success", message)
+
+	utils.Success(c, gin.H{
+		"user": user.Username,
+	})
+}
+
+// issueToken 创建服务端会话并签发携带会话 ID 的登录凭证
+func (ac *AuthController) issueToken(c *gin.Context, user *models.User, method string) (string, int, error) {
+	expireDays := ac.cookieDays()
+	session, err := ac.sessionService.Create(user.ID, method, c.ClientIP(), c.GetHeader("User-Agent"), expireDays)
+	if err != nil {
+		return "", 0, err
+	}
+	token, err := utils.GenerateToken(user.ID, user.Username, user.TokenVersion, session.ID, expireDays, constant.Secret)
+	return token, expireDays, err
+}
+
+// publishLogin 发布登录事件，由登录日志与通知订阅
+func (ac *AuthController) publishLogin(c *gin.Context, username, status, message string) {
 	eventbus.DefaultBus.Publish(eventbus.Event{
 		Type: constant.EventUserLogin,
 		Payload: map[string]interface{}{
-			"ip":        ip,
-			"username":  req.Username,
-			"userAgent": userAgent,
-			"status":    "success",
-			"message":   "登录成功",
+			"ip":        c.ClientIP(),
+			"username":  username,
+			"userAgent": c.GetHeader("User-Agent"),
+			"status":    status,
+			"message":   message,
 		},
 	})
+}
 
-	utils.Success(c, gin.H{
-		"user": user.Username,
-	})
+// cookieDays 登录凭证有效天数
+func (ac *AuthController) cookieDays() int {
+	expireDays := 7
+	if days := ac.settingsService.Get(constant.SectionSite, constant.KeyCookieDays); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			expireDays = d
+		}
+	}
+	return expireDays
+}
+
+// trustDevice 两步验证通过后按需将当前浏览器记为受信任设备
+func (ac *AuthController) trustDevice(c *gin.Context, user *models.User, enabled bool) {
+	days := ac.mfaService.TrustedDeviceDays()
+	if !enabled || days == 0 {
+		return
+	}
+	token, err := ac.mfaService.IssueTrustedDevice(user, days)
+	if err != nil {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(constant.CookieTrustedDevice, token, days*86400, "/", "", false, true)
+}
+
+// OIDCLogin 跳转到身份提供方进行单点登录
+func (ac *AuthController) OIDCLogin(c *gin.Context) {
+	cfg := ac.oidcService.Config()
+	if !cfg.Ready() {
+		ac.oidcFail(c, "", "未启用 SSO 登录")
+		return
+	}
+
+	authURL, stateToken, err := ac.oidcService.AuthURL(cfg, ac.oidcRedirectURL(c, cfg), "")
+	if err != nil {
+		ac.oidcFail(c, "", err.Error())
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(constant.CookieOidcState, stateToken, int(services.OIDCStateTTL.Seconds()), "/", "", false, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// GetOIDCLink 获取当前用户的 SSO 绑定状态
+func (ac *AuthController) GetOIDCLink(c *gin.Context) {
+	user, err := ac.userService.GetUserByID(c.GetString("userID"))
+	if err != nil {
+		utils.Unauthorized(c, "用户不存在")
+		return
+	}
+	utils.Success(c, gin.H{"enabled": ac.oidcService.Config().Ready(), "linked": user.OidcSubject != ""})
+}
+
+// OIDCLink 已登录用户发起 SSO 绑定，返回身份提供方的授权地址，由前端跳转
+// 使用 POST 以经过来源校验，避免他人诱导当前用户绑定其 SSO 身份
+func (ac *AuthController) OIDCLink(c *gin.Context) {
+	cfg := ac.oidcService.Config()
+	if !cfg.Ready() {
+		utils.BadRequest(c, "未启用 SSO 登录")
+		return
+	}
+	authURL, stateToken, err := ac.oidcService.AuthURL(cfg, ac.oidcRedirectURL(c, cfg), c.GetString("userID"))
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(constant.CookieOidcState, stateToken, int(services.OIDCStateTTL.Seconds()), "/", "", false, true)
+	utils.Success(c, gin.H{"url": authURL})
+}
+
+// OIDCUnlink 解除当前用户绑定的 SSO 身份
+func (ac *AuthController) OIDCUnlink(c *gin.Context) {
+	if ac.oidcService.PasswordLoginDisabled() {
+		utils.BadRequest(c, "已关闭密码登录，解除绑定后将无法登录")
+		return
+	}
+	userID := c.GetString("userID")
+	if err := ac.oidcService.Unlink(userID); err != nil {
+		utils.ServerError(c, "解除绑定失败")
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.sso_unlink", ResourceType: "user", ResourceID: userID, ResourceName: c.GetString("username")})
+	utils.SuccessMsg(c, "已解除 SSO 绑定")
+}
+
+// OIDCCallback 身份提供方登录完成后的回调，校验身份并签发面板登录凭证
+func (ac *AuthController) OIDCCallback(c *gin.Context) {
+	stateToken, _ := c.Cookie(constant.CookieOidcState)
+	c.SetCookie(constant.CookieOidcState, "", -1, "/", "", false, true)
+
+	if errCode := c.Query("error"); errCode != "" {
+		ac.oidcFail(c, "", strings.TrimSpace(errCode+" "+c.Query("error_description")))
+		return
+	}
+
+	cfg := ac.oidcService.Config()
+	if !cfg.Ready() {
+		ac.oidcFail(c, "", "未启用 SSO 登录")
+		return
+	}
+
+	identity, err := ac.oidcService.Exchange(cfg, ac.oidcRedirectURL(c, cfg), c.Query("code"), c.Query("state"), stateToken)
+	if err != nil {
+		ac.oidcFail(c, "", err.Error())
+		return
+	}
+	if identity.LinkUserID != "" {
+		ac.oidcLinkDone(c, identity)
+		return
+	}
+	user, err := ac.oidcService.Login(cfg, identity)
+	if err != nil {
+		ac.oidcFail(c, identity.Username, err.Error())
+		return
+	}
+
+	token, expireDays, err := ac.issueToken(c, user, constant.LoginMethodSSO)
+	if err != nil {
+		ac.oidcFail(c, user.Username, "Token生成失败")
+		return
+	}
+	middleware.SetAuthCookie(c, token, expireDays)
+
+	ac.publishLogin(c, user.Username, "success", "SSO 登录成功")
+	c.Redirect(http.StatusFound, oidcPagePrefix()+"/")
+}
+
+// TestOIDC 检测 OIDC 配置能否正常获取 Discovery 文档与签名公钥
+func (ac *AuthController) TestOIDC(c *gin.Context) {
+	cfg := ac.oidcService.Config()
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		utils.BadRequest(c, "请先填写 Issuer 与 Client ID")
+		return
+	}
+	if _, err := ac.oidcService.Discover(cfg.Issuer); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"redirect_url": ac.oidcRedirectURL(c, cfg)})
+}
+
+// oidcFail 记录 SSO 登录失败并带着错误信息跳回登录页
+func (ac *AuthController) oidcFail(c *gin.Context, username, message string) {
+	ac.publishLogin(c, username, "failed", "SSO 登录失败: "+message)
+	c.Redirect(http.StatusFound, oidcPagePrefix()+"/login?sso_error="+url.QueryEscape(message))
+}
+
+// oidcLinkDone 完成 SSO 绑定并跳回设置页，结果通过 sso_link / sso_error 参数告知前端
+func (ac *AuthController) oidcLinkDone(c *gin.Context, identity *services.OIDCIdentity) {
+	settingsPage := oidcPagePrefix() + "/settings"
+	user, err := ac.oidcService.Link(identity.LinkUserID, identity)
+	if err != nil {
+		c.Redirect(http.StatusFound, settingsPage+"?sso_error="+url.QueryEscape(err.Error()))
+		return
+	}
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	recordAudit(c, services.AuditEntry{Action: "user.sso_link", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username,
+		After: gin.H{"issuer": identity.Issuer, "subject": identity.Subject}})
+	c.Redirect(http.StatusFound, settingsPage+"?sso_link=ok")
+}
+
+// oidcRedirectURL 回调地址：优先使用配置值，其次为面板对外地址，最后按当前请求推导
+func (ac *AuthController) oidcRedirectURL(c *gin.Context, cfg services.OIDCConfig) string {
+	if cfg.RedirectURL != "" {
+		return cfg.RedirectURL
+	}
+	base := ac.settingsService.PublicURL()
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host + oidcPagePrefix()
+	}
+	return base + "/api/v1/auth/oidc/callback"
+}
+
+// oidcPagePrefix 前端页面的 URL 前缀
+func oidcPagePrefix() string {
+	return strings.TrimSuffix(services.GetConfig().Server.URLPrefix, "/")
 }
 
 func (ac *AuthController) Logout(c *gin.Context) {
-	if userID, exists := c.Get("userID"); exists {
-		ac.userService.InvalidateUserTokens(userID.(string))
+	// 只吊销当前会话，其他设备保持登录
+	if token, err := c.Cookie(constant.CookieName); err == nil && token != "" {
+		if claims, err := utils.ParseToken(token, constant.Secret); err == nil && claims.SessionID != "" {
+			ac.sessionService.Revoke(claims.UserID, claims.SessionID)
+		}
 	}
 	middleware.ClearAuthCookie(c)
 	utils.SuccessMsg(c, "退出成功")
@@ -191,6 +418,7 @@ func (ac *AuthController) GetCurrentUser(c *gin.Context) {
 	utils.Success(c, gin.H{
 		"username": user.Username,
 		"role":     user.Role,
+		"scopes":   user.Scopes,
 	})
 }
 
@@ -217,69 +445,68 @@ func (ac *AuthController) Register(c *gin.Context) {
 func (ac *AuthController) VerifyOTP(c *gin.Context) {
 	var req struct {
 		OtpPendingToken string `json:"otp_pending_token" binding:"required"`
-		Code            string `json:"code" binding:"required"`
+		Code            string `json:"code"`
+		RecoveryCode    string `json:"recovery_code"`
+		TrustDevice     bool   `json:"trust_device"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, err.Error())
 		return
 	}
 
-	userID, err := utils.ParseOtpPendingToken(req.OtpPendingToken, constant.Secret)
-	if err != nil {
-		utils.Unauthorized(c, "临时凭证无效或已过期")
+	user := ac.pendingUser(c, req.OtpPendingToken)
+	if user == nil {
 		return
 	}
 
-	user, err := ac.userService.GetUserByID(userID)
-	if err != nil || user == nil {
-		utils.Unauthorized(c, "用户不存在")
-		return
-	}
-
-	if !user.OtpEnabled || user.OtpSecret == "" {
-		utils.BadRequest(c, "未开启两步验证")
-		return
-	}
-
-	// 验证 OTP 验证码
-	if !totp.Validate(req.Code, user.OtpSecret) {
-		utils.Unauthorized(c, "验证码错误")
+	method, message := constant.LoginMethodTOTP, "两步验证登录成功"
+	switch {
+	case req.RecoveryCode != "":
+		// 恢复码一次性有效，用于手机丢失等无法获取验证码的情况
+		if !ac.mfaService.UseRecoveryCode(user.ID, req.RecoveryCode) {
+			ac.publishLogin(c, user.Username, "failed", "恢复码无效或已使用")
+			utils.Unauthorized(c, "恢复码无效或已使用")
+			return
+		}
+		method, message = constant.LoginMethodRecovery, "恢复码登录成功"
+	case req.Code != "":
+		if !user.OtpEnabled || user.OtpSecret == "" {
+			utils.BadRequest(c, "未开启两步验证")
+			return
+		}
+		// 验证 OTP 验证码
+		if !totp.Validate(req.Code, user.OtpSecret) {
+			utils.Unauthorized(c, "验证码错误")
+			return
+		}
+	default:
+		utils.BadRequest(c, "请输入验证码或恢复码")
 		return
 	}
 
 	// 校验通过，生成正式 Token 并登录
-	expireDays := 7
-	if days := ac.settingsService.Get(constant.SectionSite, constant.KeyCookieDays); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			expireDays = d
-		}
-	}
+	ac.trustDevice(c, user, req.TrustDevice)
+	ac.completeLogin(c, user, method, message)
+}
 
-	token, err := utils.GenerateToken(user.ID, user.Username, user.TokenVersion, expireDays, constant.Secret)
+// pendingUser 解析密码校验通过后的临时凭证，失败时直接写入响应
+func (ac *AuthController) pendingUser(c *gin.Context, pendingToken string) *models.User {
+	userID, err := utils.ParseOtpPendingToken(pendingToken, constant.Secret)
 	if err != nil {
-		utils.ServerError(c, "登录失败")
-		return
+		utils.Unauthorized(c, "临时凭证无效或已过期")
+		return nil
 	}
 
-	middleware.SetAuthCookie(c, token, expireDays)
-
-	// 记录登录成功日志
-	ip := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-	eventbus.DefaultBus.Publish(eventbus.Event{
-		Type: constant.EventUserLogin,
-		Payload: map[string]interface{}{
-			"ip":        ip,
-			"username":  user.Username,
-			"userAgent": userAgent,
-			"status":    "success",
-			"message":   "两步验证登录成功",
-		},
-	})
-
-	utils.Success(c, gin.H{
-		"user": user.Username,
-	})
+	user, err := ac.userService.GetUserByID(userID)
+	if err != nil || user == nil {
+		utils.Unauthorized(c, "用户不存在")
+		return nil
+	}
+	if user.Disabled {
+		utils.Forbidden(c, "账户已被禁用")
+		return nil
+	}
+	return user
 }
 
 func (ac *AuthController) GetOTPStatus(c *gin.Context) {
@@ -290,7 +517,10 @@ func (ac *AuthController) GetOTPStatus(c *gin.Context) {
 		return
 	}
 	utils.Success(c, gin.H{
-		"otp_enabled": user.OtpEnabled,
+		"otp_enabled":              user.OtpEnabled,
+		"webauthn_count":           ac.mfaService.CountCredentials(user.ID),
+		"recovery_codes_remaining": ac.mfaService.RemainingRecoveryCodes(user.ID),
+		"trusted_device_days":      ac.mfaService.TrustedDeviceDays(),
 	})
 }
 
@@ -349,13 +579,23 @@ func (ac *AuthController) EnableOTP(c *gin.Context) {
 		return
 	}
 
-	utils.SuccessMsg(c, "开启两步验证成功")
+	// 同时生成一次性恢复码，明文仅在此返回一次
+	codes, err := ac.mfaService.GenerateRecoveryCodes(userID)
+	if err != nil {
+		utils.ServerError(c, "生成恢复码失败")
+		return
+	}
+
+	utils.Success(c, gin.H{
+		"recovery_codes": codes,
+	})
 }
 
 func (ac *AuthController) DisableOTP(c *gin.Context) {
 	userID := c.GetString("userID")
 	var req struct {
-		Code string `json:"code" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, err.Error())
@@ -373,17 +613,24 @@ func (ac *AuthController) DisableOTP(c *gin.Context) {
 		return
 	}
 
-	// 验证验证码
-	if !totp.Validate(req.Code, user.OtpSecret) {
+	// 验证验证码，验证器丢失时可使用恢复码
+	if req.RecoveryCode != "" {
+		if !ac.mfaService.UseRecoveryCode(userID, req.RecoveryCode) {
+			utils.BadRequest(c, "恢复码无效或已使用")
+			return
+		}
+	} else if !totp.Validate(req.Code, user.OtpSecret) {
 		utils.BadRequest(c, "验证码错误")
 		return
 	}
 
-	// 禁用并清除 secret
+	// 禁用并清除 secret，受信任设备随之失效
 	if err := ac.userService.UpdateOTP(userID, "", false); err != nil {
 		utils.ServerError(c, "关闭两步验证失败")
 		return
 	}
+	ac.mfaService.CleanupRecoveryCodes(userID)
+	ac.mfaService.RevokeTrustedDevices(userID)
 
 	utils.SuccessMsg(c, "关闭两步验证成功")
 }
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"io"
+
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type ChatOpsController struct {
+	chatOpsService *services.ChatOpsService
+}
+
+func NewChatOpsController(chatOpsService *services.ChatOpsService) *ChatOpsController {
+	return &ChatOpsController{chatOpsService: chatOpsService}
+}
+
+// TelegramWebhook 接收 Telegram Bot 的 Webhook 推送
+func (cc *ChatOpsController) TelegramWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.BadRequest(c, "读取请求失败")
+		return
+	}
+
+	secretToken := c.GetHeader("X-Telegram-Bot-Api-Secret-Token")
+	if err := cc.chatOpsService.HandleTelegramWebhook(c.Param("id"), secretToken, body); err != nil {
+		logger.Warnf("[ChatOps] Telegram Webhook 处理失败: %v", err)
+		utils.Forbidden(c, err.Error())
+		return
+	}
+	utils.SuccessMsg(c, "ok")
+}
+
+// FeishuWebhook 接收飞书开放平台的事件订阅回调
+func (cc *ChatOpsController) FeishuWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		utils.BadRequest(c, "读取请求失败")
+		return
+	}
+
+	resp, err := cc.chatOpsService.HandleFeishuWebhook(c.Param("id"), body)
+	if err != nil {
+		logger.Warnf("[ChatOps] 飞书事件处理失败: %v", err)
+		utils.Forbidden(c, err.Error())
+		return
+	}
+	// 飞书要求原样返回 challenge 等字段，不能包裹统一响应结构
+	c.JSON(200, resp)
+}
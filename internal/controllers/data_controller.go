@@ -77,5 +77,8 @@ func (dc *DataController) ImportBusinessData(c *gin.Context) {
 		}
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "data.import", ResourceType: "data", After: gin.H{
+		"tasks": len(req.Tasks), "envs": len(req.Envs), "tags": len(req.Tags), "bindings": len(req.Bindings),
+	}})
 	utils.SuccessMsg(c, "导入成功")
 }
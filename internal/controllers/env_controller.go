@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/models/vo"
 	"github.com/engigu/baihu-panel/internal/services"
@@ -20,6 +24,41 @@ func NewEnvController(envService *services.EnvService) *EnvController {
 	return &EnvController{envService: envService}
 }
 
+// canAccessEnv 非管理员只能访问自己创建的环境变量
+func canAccessEnv(c *gin.Context, env *models.EnvironmentVariable) bool {
+	return middleware.GetAccessScope(c).Can(constant.AdminRole) || env.UserID == c.GetString("userID")
+}
+
+// envAuditView 审计用的变量视图，值取存储的原始内容（密文变量为密文），以便识别值是否变更
+func envAuditView(env *models.EnvironmentVariable) *vo.EnvVO {
+	view := vo.ToEnvVO(env)
+	view.Value = string(env.Value)
+	return view
+}
+
+// parseEnvExpiry 解析过期时间，日期格式（2006-01-02）在当天结束时过期，也接受完整时间；空字符串表示不过期
+func parseEnvExpiry(value string) (*models.LocalTime, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err == nil {
+		t = t.Add(24*time.Hour - time.Second)
+	} else if t, err = time.ParseInLocation(models.TimeFormat, value, time.Local); err != nil {
+		return nil, err
+	}
+	expiresAt := models.LocalTime(t)
+	return &expiresAt, nil
+}
+
+// sameEnvExpiry 判断过期时间是否未变化，未变化时保留已发送的提醒状态
+func sameEnvExpiry(a, b *models.LocalTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Time().Equal(b.Time())
+}
+
 // GetSecretStatus 获取加密秘钥状态
 // @Summary 获取加密秘钥状态
 // @Description 返回系统是否已配置加密秘钥
@@ -46,13 +85,14 @@ func (ec *EnvController) CreateEnvVar(c *gin.Context) {
 	userID := c.GetString("userID")
 
 	var req struct {
-		Name    string `json:"name" binding:"required"`
-		Value   string `json:"value" binding:"required"`
-		Remark  string `json:"remark"`
-		Type    string `json:"type"`
-		Hidden  *bool  `json:"hidden"`
-		Enabled *bool  `json:"enabled"`
-		Tags    string `json:"tags"`
+		Name      string `json:"name" binding:"required"`
+		Value     string `json:"value" binding:"required"`
+		Remark    string `json:"remark"`
+		Type      string `json:"type"`
+		Hidden    *bool  `json:"hidden"`
+		Enabled   *bool  `json:"enabled"`
+		Tags      string `json:"tags"`
+		ExpiresAt string `json:"expires_at"` // 格式: 2006-01-02，当天结束时过期
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -64,6 +104,12 @@ func (ec *EnvController) CreateEnvVar(c *gin.Context) {
 		req.Type = constant.EnvTypeNormal
 	}
 
+	expiresAt, err := parseEnvExpiry(req.ExpiresAt)
+	if err != nil {
+		utils.BadRequest(c, "过期时间格式错误")
+		return
+	}
+
 	hidden := true
 	if req.Hidden != nil {
 		hidden = *req.Hidden
@@ -78,11 +124,19 @@ func (ec *EnvController) CreateEnvVar(c *gin.Context) {
 	if envVar != nil {
 		relation.DataRelation.SaveTags(envVar.ID, constant.RelationTypeEnvTag, req.Tags)
 		envVar.Tags = req.Tags
+		if expiresAt != nil {
+			ec.envService.SetEnvExpiry(envVar.ID, expiresAt)
+			envVar.ExpiresAt = expiresAt
+		}
+		ec.envService.RecordEnvVersion(envVar.ID, services.EnvVersionCreate, 0, userID, c.GetString("username"))
 	}
 	
 	// Broadcast tasks to all agents because global envs changed
 	services.GetAgentWSManager().BroadcastTasksToAll()
-	
+
+	if envVar != nil {
+		recordAudit(c, services.AuditEntry{Action: "env.create", ResourceType: "env", ResourceID: envVar.ID, ResourceName: envVar.Name, After: envAuditView(envVar), Redact: []string{"value"}})
+	}
 	utils.Success(c, vo.ToEnvVO(envVar))
 }
 
@@ -144,11 +198,15 @@ func (ec *EnvController) GetEnvVar(c *gin.Context) {
 	}
 
 	envVar := ec.envService.GetEnvVarByID(id)
-	if envVar == nil {
+	if envVar == nil || !canAccessEnv(c, envVar) {
 		utils.NotFound(c, "环境变量不存在")
 		return
 	}
 
+	// 隐藏的普通变量会返回明文，视为查看敏感值
+	if envVar.Type != constant.EnvTypeSecret && utils.DerefBool(envVar.Hidden, true) {
+		recordAudit(c, services.AuditEntry{Action: "env.reveal", ResourceType: "env", ResourceID: envVar.ID, ResourceName: envVar.Name})
+	}
 	utils.Success(c, vo.ToEnvVO(envVar))
 }
 
@@ -172,13 +230,14 @@ func (ec *EnvController) UpdateEnvVar(c *gin.Context) {
 	}
 
 	var req struct {
-		Name    string `json:"name"`
-		Value   string `json:"value"`
-		Remark  string `json:"remark"`
-		Type    string `json:"type"`
-		Hidden  *bool  `json:"hidden"`
-		Enabled *bool  `json:"enabled"`
-		Tags    string `json:"tags"`
+		Name      string  `json:"name"`
+		Value     string  `json:"value"`
+		Remark    string  `json:"remark"`
+		Type      string  `json:"type"`
+		Hidden    *bool   `json:"hidden"`
+		Enabled   *bool   `json:"enabled"`
+		Tags      string  `json:"tags"`
+		ExpiresAt *string `json:"expires_at"` // 不传则不修改，空字符串表示清除
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -190,13 +249,25 @@ func (ec *EnvController) UpdateEnvVar(c *gin.Context) {
 		req.Type = constant.EnvTypeNormal
 	}
 
+	var expiresAt *models.LocalTime
+	if req.ExpiresAt != nil {
+		var err error
+		if expiresAt, err = parseEnvExpiry(*req.ExpiresAt); err != nil {
+			utils.BadRequest(c, "过期时间格式错误")
+			return
+		}
+	}
+
 	// 对于更新，获取现有数据
 	existing := ec.envService.GetEnvVarByID(id)
-	if existing == nil {
+	if existing == nil || !canAccessEnv(c, existing) {
 		utils.NotFound(c, "环境变量不存在")
 		return
 	}
 
+	before := envAuditView(existing)
+	ec.envService.EnsureEnvBaseline(id)
+
 	hidden := existing.Hidden
 	if req.Hidden != nil {
 		hidden = req.Hidden
@@ -215,10 +286,16 @@ func (ec *EnvController) UpdateEnvVar(c *gin.Context) {
 
 	relation.DataRelation.SaveTags(envVar.ID, constant.RelationTypeEnvTag, req.Tags)
 	envVar.Tags = req.Tags
+	if req.ExpiresAt != nil && !sameEnvExpiry(existing.ExpiresAt, expiresAt) {
+		ec.envService.SetEnvExpiry(envVar.ID, expiresAt)
+		envVar.ExpiresAt = expiresAt
+	}
+	ec.envService.RecordEnvVersion(envVar.ID, services.EnvVersionUpdate, 0, c.GetString("userID"), c.GetString("username"))
 
 	// Broadcast tasks to all agents because global envs changed
 	services.GetAgentWSManager().BroadcastTasksToAll()
 
+	recordAudit(c, services.AuditEntry{Action: "env.update", ResourceType: "env", ResourceID: envVar.ID, ResourceName: envVar.Name, Before: before, After: envAuditView(envVar), Redact: []string{"value"}})
 	utils.Success(c, vo.ToEnvVO(envVar))
 }
 
@@ -242,6 +319,12 @@ func (ec *EnvController) DeleteEnvVar(c *gin.Context) {
 		return
 	}
 
+	existing := ec.envService.GetEnvVarByID(id)
+	if existing == nil || !canAccessEnv(c, existing) {
+		utils.NotFound(c, "环境变量不存在")
+		return
+	}
+
 	force := c.Query("force") == "true"
 	success, associatedTasks := ec.envService.DeleteEnvVar(id, force)
 
@@ -262,6 +345,7 @@ func (ec *EnvController) DeleteEnvVar(c *gin.Context) {
 	// Broadcast tasks to all agents because global envs changed
 	services.GetAgentWSManager().BroadcastTasksToAll()
 
+	recordAudit(c, services.AuditEntry{Action: "env.delete", ResourceType: "env", ResourceID: existing.ID, ResourceName: existing.Name, Before: envAuditView(existing), Redact: []string{"value"}})
 	utils.SuccessMsg(c, "删除成功")
 }
 
@@ -281,6 +365,10 @@ func (ec *EnvController) GetAssociatedTasks(c *gin.Context) {
 		utils.BadRequest(c, "无效的环境变量ID")
 		return
 	}
+	if existing := ec.envService.GetEnvVarByID(id); existing == nil || !canAccessEnv(c, existing) {
+		utils.NotFound(c, "环境变量不存在")
+		return
+	}
 	tasks := ec.envService.GetAssociatedTasks(id)
 	utils.Success(c, vo.ToTaskVOListFromModels(tasks))
 }
@@ -321,6 +409,7 @@ func (ec *EnvController) BulkSaveEnv(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
+	username := c.GetString("username")
 
 	for _, req := range reqs {
 		if req.Type == constant.EnvTypeSecret {
@@ -353,6 +442,7 @@ func (ec *EnvController) BulkSaveEnv(c *gin.Context) {
 		}
 
 		if existingEnv != nil {
+			ec.envService.EnsureEnvBaseline(existingEnv.ID)
 			existingEnv.Name = req.Name
 			existingEnv.Value = models.BigText(req.Value)
 			existingEnv.Remark = req.Remark
@@ -363,7 +453,9 @@ func (ec *EnvController) BulkSaveEnv(c *gin.Context) {
 			
 			if req.ID != "" && existingEnv.ID != req.ID {
 				database.DB.Model(existingEnv).Update("id", req.ID)
+				existingEnv.ID = req.ID
 			}
+			ec.envService.RecordEnvVersion(existingEnv.ID, services.EnvVersionBulk, 0, userID, username)
 		} else {
 			envVar := &models.EnvironmentVariable{
 				ID:        req.ID,
@@ -381,9 +473,85 @@ func (ec *EnvController) BulkSaveEnv(c *gin.Context) {
 				envVar.ID = utils.GenerateID()
 			}
 			database.DB.Create(envVar)
+			ec.envService.RecordEnvVersion(envVar.ID, services.EnvVersionBulk, 0, userID, username)
 		}
 	}
 
 	services.GetAgentWSManager().BroadcastTasksToAll()
+	recordAudit(c, services.AuditEntry{Action: "env.bulk_save", ResourceType: "env", ResourceName: fmt.Sprintf("%d 个变量", len(reqs))})
 	utils.Success(c, nil)
 }
+
+// GetEnvVersions 获取变量历史版本
+// @Summary 获取变量历史版本
+// @Description 获取环境变量的修改历史，机密的值不返回
+// @Tags 环境变量
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "环境变量ID"
+// @Success 200 {object} utils.Response{data=[]models.EnvVersion}
+// @Router /env/{id}/versions [get]
+func (ec *EnvController) GetEnvVersions(c *gin.Context) {
+	id := c.Param("id")
+	if existing := ec.envService.GetEnvVarByID(id); existing == nil || !canAccessEnv(c, existing) {
+		utils.NotFound(c, "环境变量不存在")
+		return
+	}
+	versions := ec.envService.ListEnvVersions(id)
+	for i := range versions {
+		if versions[i].Type == constant.EnvTypeSecret {
+			versions[i].Value = "********"
+		}
+	}
+	utils.Success(c, versions)
+}
+
+// RevertEnvVersion 回滚到历史版本
+// @Summary 回滚到历史版本
+// @Description 将环境变量恢复为指定历史版本的内容
+// @Tags 环境变量
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "环境变量ID"
+// @Param versionId path string true "历史版本ID"
+// @Success 200 {object} utils.Response{data=vo.EnvVO}
+// @Failure 404 {object} utils.Response
+// @Router /env/{id}/versions/{versionId}/revert [post]
+func (ec *EnvController) RevertEnvVersion(c *gin.Context) {
+	id := c.Param("id")
+	existing := ec.envService.GetEnvVarByID(id)
+	if existing == nil || !canAccessEnv(c, existing) {
+		utils.NotFound(c, "环境变量不存在")
+		return
+	}
+
+	envVar, err := ec.envService.RevertEnvVersion(id, c.Param("versionId"), c.GetString("userID"), c.GetString("username"))
+	if err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+
+	// Broadcast tasks to all agents because global envs changed
+	services.GetAgentWSManager().BroadcastTasksToAll()
+
+	recordAudit(c, services.AuditEntry{Action: "env.revert", ResourceType: "env", ResourceID: envVar.ID, ResourceName: envVar.Name, Before: envAuditView(existing), After: envAuditView(envVar), Redact: []string{"value"}})
+	utils.Success(c, vo.ToEnvVO(envVar))
+}
+
+// GetEnvUsage 获取机密使用记录
+// @Summary 获取机密使用记录
+// @Description 获取机密最近一次被注入到各任务的时间
+// @Tags 环境变量
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "环境变量ID"
+// @Success 200 {object} utils.Response{data=[]models.EnvUsage}
+// @Router /env/{id}/usage [get]
+func (ec *EnvController) GetEnvUsage(c *gin.Context) {
+	id := c.Param("id")
+	if existing := ec.envService.GetEnvVarByID(id); existing == nil || !canAccessEnv(c, existing) {
+		utils.NotFound(c, "环境变量不存在")
+		return
+	}
+	utils.Success(c, ec.envService.ListEnvUsage(id))
+}
@@ -3,7 +3,10 @@ package controllers
 import (
 	"strconv"
 
+	"github.com/engigu/baihu-panel/internal/executor"
+	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/engigu/baihu-panel/internal/models/vo"
+	"github.com/engigu/baihu-panel/internal/services"
 	"github.com/engigu/baihu-panel/internal/services/tasks"
 	"github.com/engigu/baihu-panel/internal/utils"
 
@@ -36,6 +39,10 @@ func (ec *ExecutorController) ExecuteTask(c *gin.Context) {
 		utils.BadRequest(c, "无效的任务ID")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowTask(id) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
 
 	var req struct {
 		Envs map[string]string `json:"envs"`
@@ -51,6 +58,7 @@ func (ec *ExecutorController) ExecuteTask(c *gin.Context) {
 	}
 
 	result := ec.executorService.ExecuteTask(id, extraEnvs)
+	recordAudit(c, services.AuditEntry{Action: "task.run", ResourceType: "task", ResourceID: id})
 	utils.Success(c, vo.ToExecutionResultVO(result))
 }
 
@@ -66,6 +74,7 @@ func (ec *ExecutorController) ExecuteCommand(c *gin.Context) {
 	}
 
 	result := ec.executorService.ExecuteCommand(req.Command)
+	recordAudit(c, services.AuditEntry{Action: "command.execute", ResourceType: "command", After: req})
 	utils.Success(c, vo.ToExecutionResultVO(result))
 }
 
@@ -88,5 +97,14 @@ func (ec *ExecutorController) GetLastResults(c *gin.Context) {
 	}
 
 	results := ec.executorService.GetLastResults(count)
+	if scope := middleware.GetAccessScope(c); scope.Scoped() {
+		visible := make([]executor.ExecutionResult, 0, len(results))
+		for _, r := range results {
+			if r.TaskID != "" && scope.AllowTask(r.TaskID) {
+				visible = append(visible, r)
+			}
+		}
+		results = visible
+	}
 	utils.Success(c, vo.ToExecutionResultVOList(results))
 }
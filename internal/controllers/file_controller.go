@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/engigu/baihu-panel/internal/services"
 	"github.com/engigu/baihu-panel/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -61,6 +62,11 @@ func (fc *FileController) checkPath(path string, allowRoot bool) (string, bool)
 	return fullPath, true
 }
 
+// recordFileAudit 记录文件变更，文件内容不写入审计日志
+func recordFileAudit(c *gin.Context, action, path string, after interface{}) {
+	recordAudit(c, services.AuditEntry{Action: action, ResourceType: "file", ResourceID: path, ResourceName: filepath.Base(path), After: after})
+}
+
 func (fc *FileController) GetFileTree(c *gin.Context) {
 	root := &FileNode{
 		Name:     filepath.Base(fc.workDir),
@@ -177,6 +183,7 @@ func (fc *FileController) SaveFileContent(c *gin.Context) {
 		return
 	}
 
+	recordFileAudit(c, "file.save", req.Path, gin.H{"size": len(req.Content)})
 	utils.SuccessMsg(c, "保存成功")
 }
 
@@ -210,6 +217,7 @@ func (fc *FileController) CreateFile(c *gin.Context) {
 		}
 	}
 
+	recordFileAudit(c, "file.create", req.Path, gin.H{"is_dir": req.IsDir})
 	utils.SuccessMsg(c, "创建成功")
 }
 
@@ -234,6 +242,7 @@ func (fc *FileController) DeleteFile(c *gin.Context) {
 		return
 	}
 
+	recordFileAudit(c, "file.delete", req.Path, nil)
 	utils.SuccessMsg(c, "删除成功")
 }
 
@@ -275,6 +284,7 @@ func (fc *FileController) MoveFile(c *gin.Context) {
 		return
 	}
 
+	recordFileAudit(c, "file.move", req.OldPath, gin.H{"path": req.NewPath})
 	utils.Success(c, nil)
 }
 
@@ -323,6 +333,7 @@ func (fc *FileController) CopyFile(c *gin.Context) {
 		return
 	}
 
+	recordFileAudit(c, "file.copy", req.SourcePath, gin.H{"path": req.TargetPath})
 	utils.Success(c, nil)
 }
 
@@ -367,6 +378,7 @@ func (fc *FileController) RenameFile(c *gin.Context) {
 		return
 	}
 
+	recordFileAudit(c, "file.rename", req.OldPath, gin.H{"path": req.NewPath})
 	utils.Success(c, nil)
 }
 
@@ -420,6 +432,7 @@ func (fc *FileController) UploadArchive(c *gin.Context) {
 		return
 	}
 
+	recordFileAudit(c, "file.upload", targetDir, gin.H{"archive": file.Filename})
 	utils.SuccessMsg(c, "导入成功")
 }
 
@@ -473,6 +486,7 @@ func (fc *FileController) UploadFiles(c *gin.Context) {
 		}
 	}
 
+	recordFileAudit(c, "file.upload", targetDir, gin.H{"files": len(files)})
 	utils.SuccessMsg(c, "上传成功")
 }
 
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GitOpsController struct {
+	gitopsService *services.GitOpsService
+}
+
+func NewGitOpsController(gitopsService *services.GitOpsService) *GitOpsController {
+	return &GitOpsController{gitopsService: gitopsService}
+}
+
+// Plan 拉取配置仓库并计算与面板的差异，不做修改
+// @Summary 检测配置差异
+// @Description 拉取 GitOps 配置仓库，列出新增、更新、删除的资源以及面板中的漂移
+// @Tags GitOps
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=services.GitOpsReport}
+// @Router /settings/gitops/plan [post]
+func (gc *GitOpsController) Plan(c *gin.Context) {
+	report, err := gc.gitopsService.Reconcile(false)
+	if err != nil {
+		gitopsError(c, err)
+		return
+	}
+	utils.Success(c, report)
+}
+
+// Apply 拉取配置仓库并应用差异，按设置决定是否清理仓库中已移除的资源
+// @Summary 应用配置
+// @Tags GitOps
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=services.GitOpsReport}
+// @Router /settings/gitops/apply [post]
+func (gc *GitOpsController) Apply(c *gin.Context) {
+	report, err := gc.gitopsService.Reconcile(true)
+	if err != nil {
+		gitopsError(c, err)
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "gitops.apply", ResourceType: "settings", ResourceID: "gitops",
+		ResourceName: report.Plan.Commit, After: report.Result})
+	utils.Success(c, report)
+}
+
+// Applied 同步 baihu apply 的应用结果到调度器与 Agent（供本地 CLI 进程调用）
+func (gc *GitOpsController) Applied(c *gin.Context) {
+	var result services.GitOpsApplyResult
+	if err := c.ShouldBindJSON(&result); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	gc.gitopsService.Propagate(&result)
+	utils.SuccessMsg(c, "同步成功")
+}
+
+func gitopsError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrGitOpsSpec) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.ServerError(c, err.Error())
+}
@@ -43,6 +43,14 @@ func (ic *InterconnectController) GetNodes(c *gin.Context) {
 	utils.Success(c, nodes)
 }
 
+// nodeAuditView 审计日志中的节点字段，不含运行状态
+func nodeAuditView(node *models.InterconnectNode) gin.H {
+	if node == nil {
+		return nil
+	}
+	return gin.H{"name": node.Name, "url": node.URL, "token": node.Token, "remark": node.Remark}
+}
+
 // CreateNode 创建互联节点
 func (ic *InterconnectController) CreateNode(c *gin.Context) {
 	var req struct {
@@ -63,6 +71,7 @@ func (ic *InterconnectController) CreateNode(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "node.create", ResourceType: "node", ResourceID: node.ID, ResourceName: node.Name, After: nodeAuditView(node)})
 	utils.Success(c, node)
 }
 
@@ -86,12 +95,14 @@ func (ic *InterconnectController) UpdateNode(c *gin.Context) {
 		return
 	}
 
+	before, _ := ic.interconnectService.GetNodeByID(id)
 	node, err := ic.interconnectService.UpdateNode(id, req.Name, req.URL, req.Token, req.Remark)
 	if err != nil {
 		utils.ServerError(c, "更新互联节点失败")
 		return
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "node.update", ResourceType: "node", ResourceID: node.ID, ResourceName: node.Name, Before: nodeAuditView(before), After: nodeAuditView(node)})
 	utils.Success(c, node)
 }
 
@@ -109,6 +120,7 @@ func (ic *InterconnectController) DeleteNode(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "node.delete", ResourceType: "node", ResourceID: id})
 	utils.Success(c, nil)
 }
 
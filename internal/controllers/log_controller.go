@@ -1,18 +1,30 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
 	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/models/vo"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/services/tasks"
 	"github.com/engigu/baihu-panel/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-type LogController struct{}
+type LogController struct {
+	logSearch *tasks.LogSearchService
+}
 
-func NewLogController() *LogController {
-	return &LogController{}
+func NewLogController(logSearch *tasks.LogSearchService) *LogController {
+	return &LogController{logSearch: logSearch}
 }
 
 // GetLogs 获取任务日志列表
@@ -42,6 +54,10 @@ func (lc *LogController) GetLogs(c *gin.Context) {
 	if taskID != "" {
 		query = query.Where("task_id = ?", taskID)
 	}
+	// 受标签范围限制的账户只能查看范围内任务的日志
+	if scope := middleware.GetAccessScope(c); scope.Scoped() {
+		query = query.Where("task_id IN ?", append(scope.TaskIDs(), ""))
+	}
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -98,6 +114,81 @@ func (lc *LogController) GetLogs(c *gin.Context) {
 	utils.PaginatedResponse(c, result, total, p)
 }
 
+// SearchLogs 全文检索日志输出
+// @Summary 全文检索日志输出
+// @Description 按关键词检索任务日志输出（不区分大小写，按完整单词建立索引），返回命中的日志及高亮的行摘要，结果按时间倒序
+// @Tags 日志管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "关键词"
+// @Param task_id query string false "任务 ID"
+// @Param status query string false "状态"
+// @Param since query string false "开始日期，如 2026-01-01"
+// @Param until query string false "结束日期（含当天），如 2026-01-07"
+// @Param before query string false "游标，传入上次返回的 next_cursor 继续检索更早的日志"
+// @Param limit query int false "返回数量，默认 20，最大 100"
+// @Success 200 {object} utils.Response{data=tasks.LogSearchResult}
+// @Router /logs/search [get]
+func (lc *LogController) SearchLogs(c *gin.Context) {
+	query := tasks.LogSearchQuery{
+		Keyword: c.Query("q"),
+		TaskID:  c.Query("task_id"),
+		Status:  c.Query("status"),
+		Before:  c.Query("before"),
+		Limit:   utils.ToInt(c.Query("limit"), 20),
+	}
+	if scope := middleware.GetAccessScope(c); scope.Scoped() {
+		query.TaskIDs = scope.TaskIDs()
+	}
+	var err error
+	if query.Since, err = parseLogSearchDate(c.Query("since"), false); err != nil {
+		utils.BadRequest(c, "开始日期格式错误")
+		return
+	}
+	if query.Until, err = parseLogSearchDate(c.Query("until"), true); err != nil {
+		utils.BadRequest(c, "结束日期格式错误")
+		return
+	}
+
+	result, err := lc.logSearch.Search(query)
+	if errors.Is(err, tasks.ErrLogSearchKeyword) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ServerError(c, "检索失败: "+err.Error())
+		return
+	}
+	utils.Success(c, result)
+}
+
+// parseLogSearchDate 解析检索的日期范围，支持日期或完整时间；结束日期只给出日期时包含当天
+func parseLogSearchDate(value string, end bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+	} else if t, err = time.ParseInLocation(models.TimeFormat, value, time.Local); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ReindexLogs 在后台为全部历史日志重建全文索引
+func (lc *LogController) ReindexLogs(c *gin.Context) {
+	if !lc.logSearch.Reindex() {
+		utils.BadRequest(c, "索引正在重建中，请稍后")
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "logs.reindex", ResourceType: "task_log"})
+	utils.SuccessMsg(c, "已开始重建日志索引")
+}
+
 // GetLogDetail 获取日志详情
 // @Summary 获取日志详情
 // @Description 根据 ID 获取任务日志详细内容（包含输出）
@@ -106,6 +197,7 @@ func (lc *LogController) GetLogs(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path string true "日志ID"
+// @Param lines query string false "为 1 时额外返回带相对时间与来源的逐行日志"
 // @Success 200 {object} utils.Response{data=vo.TaskLogVO}
 // @Failure 404 {object} utils.Response
 // @Router /logs/{id} [get]
@@ -122,8 +214,146 @@ func (lc *LogController) GetLogDetail(c *gin.Context) {
 		utils.NotFound(c, "日志不存在")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowTask(log.TaskID) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
+	output, err := logstore.ReadCompressed(&log)
+	if err != nil {
+		utils.ServerError(c, "读取日志正文失败: "+err.Error())
+		return
+	}
+	log.Output = models.BigText(output)
 
-	utils.Success(c, vo.ToTaskLogVO(&log))
+	result := vo.ToTaskLogVO(&log)
+	if c.Query("lines") == "1" {
+		result.Lines = taskLogLines(&log)
+	}
+	utils.Success(c, result)
+}
+
+// taskLogLines 按行元数据拆分日志正文，升级前的日志没有元数据时返回 nil
+func taskLogLines(log *models.TaskLog) []utils.LogLine {
+	metas, err := utils.DecodeLineMeta(string(log.LineMeta))
+	if err != nil || len(metas) == 0 {
+		return nil
+	}
+	output, err := logstore.ReadOutput(log)
+	if err != nil {
+		return nil
+	}
+	return utils.SplitLogLines(output, metas)
+}
+
+// DiffLogs 比对同一任务的两次运行
+// @Summary 比对两次运行
+// @Description 比对同一任务两次运行的输出（按行，忽略时间戳等易变内容）、命令、环境变量名与语言版本
+// @Tags 日志管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param base query string true "基准日志 ID"
+// @Param target query string true "对比日志 ID"
+// @Param ignore query []string false "比对前从每行删除的正则，可重复；不传时使用默认规则，传空值表示不忽略任何内容" collectionFormat(multi)
+// @Param ansi query string false "为 1 时保留 ANSI 颜色码参与比对"
+// @Param context query int false "差异块上下文行数，默认 3"
+// @Success 200 {object} utils.Response{data=tasks.LogDiffResult}
+// @Failure 404 {object} utils.Response
+// @Router /logs/diff [get]
+func (lc *LogController) DiffLogs(c *gin.Context) {
+	baseID, targetID := c.Query("base"), c.Query("target")
+	if baseID == "" || targetID == "" {
+		utils.BadRequest(c, "请指定要比对的两次运行")
+		return
+	}
+
+	var logs []models.TaskLog
+	if err := database.DB.Where("id IN ?", []string{baseID, targetID}).Find(&logs).Error; err != nil {
+		utils.ServerError(c, "查询日志失败")
+		return
+	}
+	var base, target *models.TaskLog
+	for i := range logs {
+		if logs[i].ID == baseID {
+			base = &logs[i]
+		}
+		if logs[i].ID == targetID {
+			target = &logs[i]
+		}
+	}
+	if base == nil || target == nil {
+		utils.NotFound(c, "日志不存在")
+		return
+	}
+	if base.TaskID != target.TaskID {
+		utils.BadRequest(c, "只能比对同一任务的两次运行")
+		return
+	}
+	if !middleware.GetAccessScope(c).AllowTask(base.TaskID) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
+
+	opts := tasks.LogDiffOptions{
+		KeepANSI: c.Query("ansi") == "1",
+		Context:  utils.ToInt(c.Query("context"), 0),
+	}
+	if values, ok := c.GetQueryArray("ignore"); ok {
+		opts.Ignore = []string{}
+		for _, v := range values {
+			if v != "" {
+				opts.Ignore = append(opts.Ignore, v)
+			}
+		}
+	}
+	result, err := tasks.DiffTaskLogs(base, target, opts)
+	if errors.Is(err, tasks.ErrLogDiffPattern) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ServerError(c, "比对失败: "+err.Error())
+		return
+	}
+	utils.Success(c, result)
+}
+
+// GetSharedLog 通过通知中的限时签名链接查看完整日志（无需登录）
+func (lc *LogController) GetSharedLog(c *gin.Context) {
+	id := c.Param("id")
+	if !services.VerifyLogLink(id, c.Query("expires"), c.Query("sig")) {
+		c.String(http.StatusForbidden, "链接无效或已过期")
+		return
+	}
+
+	var log models.TaskLog
+	res := database.DB.Where("id = ?", id).Limit(1).Find(&log)
+	if res.Error != nil || res.RowsAffected == 0 {
+		c.String(http.StatusNotFound, "日志不存在")
+		return
+	}
+
+	var taskName string
+	database.DB.Model(&models.Task{}).Where("id = ?", log.TaskID).Limit(1).Pluck("name", &taskName)
+	output, _ := logstore.ReadOutput(&log)
+
+	var startTime string
+	if log.StartTime != nil {
+		startTime = log.StartTime.Time().Format(models.TimeFormat)
+	}
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width,initial-scale=1"><title>%s</title>
+<style>body{font-family:sans-serif;margin:16px}pre{background:#1e1e1e;color:#ddd;padding:12px;overflow:auto;white-space:pre-wrap;word-break:break-all}</style></head>
+<body><h3>%s</h3><p>状态: %s &nbsp; 开始时间: %s &nbsp; 耗时: %dms &nbsp; 退出码: %d</p>%s<pre>%s</pre></body></html>`,
+		html.EscapeString(taskName), html.EscapeString(taskName), html.EscapeString(log.Status), startTime, log.Duration, log.ExitCode,
+		sharedLogError(string(log.Error)), html.EscapeString(utils.StripAnsi(output)))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+func sharedLogError(errMsg string) string {
+	if errMsg == "" {
+		return ""
+	}
+	return "<p>错误: " + html.EscapeString(errMsg) + "</p>"
 }
 
 // ClearLogs 清空日志
@@ -137,17 +367,29 @@ func (lc *LogController) ClearLogs(c *gin.Context) {
 		return
 	}
 
+	scope := middleware.GetAccessScope(c)
 	query := database.DB.Model(&models.TaskLog{})
 	if req.TaskID != nil && *req.TaskID != "" {
+		if !scope.AllowTask(*req.TaskID) {
+			utils.Forbidden(c, "无权访问该任务")
+			return
+		}
 		query = query.Where("task_id = ?", *req.TaskID)
+	} else if scope.Scoped() {
+		query = query.Where("task_id IN ?", append(scope.TaskIDs(), ""))
 	} else {
 		query = query.Where("1 = 1") // Allow delete all without GORM safety block
 	}
 
+	refs := logstore.CollectRefs(query)
 	if err := query.Delete(&models.TaskLog{}).Error; err != nil {
 		utils.ServerError(c, "清空日志失败")
 		return
 	}
+	go func() {
+		lc.logSearch.CleanIndex("")
+		logstore.Release(refs)
+	}()
 
 	utils.SuccessMsg(c, "日志清空成功")
 }
@@ -159,11 +401,19 @@ func (lc *LogController) DeleteLog(c *gin.Context) {
 		utils.BadRequest(c, "无效的日志ID")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowLog(id) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
 
-	if err := database.DB.Where("id = ?", id).Delete(&models.TaskLog{}).Error; err != nil {
+	query := database.DB.Where("id = ?", id)
+	refs := logstore.CollectRefs(query)
+	if err := query.Delete(&models.TaskLog{}).Error; err != nil {
 		utils.ServerError(c, "删除日志失败")
 		return
 	}
+	lc.logSearch.DeleteIndex(id)
+	logstore.Release(refs)
 
 	utils.SuccessMsg(c, "日志已删除")
 }
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LogForwardController struct {
+	forwardService *services.LogForwardService
+}
+
+func NewLogForwardController(forwardService *services.LogForwardService) *LogForwardController {
+	return &LogForwardController{forwardService: forwardService}
+}
+
+// ListForwarders 获取日志转发目标列表
+// @Summary 获取日志转发目标列表
+// @Tags 日志转发
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]models.LogForwarder}
+// @Router /log-forwarders [get]
+func (fc *LogForwardController) ListForwarders(c *gin.Context) {
+	utils.Success(c, fc.forwardService.List())
+}
+
+// CreateForwarder 创建日志转发目标
+// @Summary 创建日志转发目标
+// @Description 支持 RFC5424 syslog（UDP/TCP）、Loki push API 与通用 HTTP（JSON Lines）
+// @Tags 日志转发
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body services.LogForwarderInput true "转发目标配置"
+// @Success 200 {object} utils.Response{data=models.LogForwarder}
+// @Router /log-forwarders [post]
+func (fc *LogForwardController) CreateForwarder(c *gin.Context) {
+	var req services.LogForwarderInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	f, err := fc.forwardService.Create(req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "log_forwarder.create", ResourceType: "log_forwarder", ResourceID: f.ID, ResourceName: f.Name, After: f})
+	utils.Success(c, f)
+}
+
+// UpdateForwarder 修改日志转发目标
+// @Summary 修改日志转发目标
+// @Tags 日志转发
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "转发目标ID"
+// @Param body body services.LogForwarderInput true "转发目标配置"
+// @Success 200 {object} utils.Response{data=models.LogForwarder}
+// @Router /log-forwarders/{id} [put]
+func (fc *LogForwardController) UpdateForwarder(c *gin.Context) {
+	var req services.LogForwarderInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	f, err := fc.forwardService.Update(c.Param("id"), req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "log_forwarder.update", ResourceType: "log_forwarder", ResourceID: f.ID, ResourceName: f.Name, After: f})
+	utils.Success(c, f)
+}
+
+// DeleteForwarder 删除日志转发目标
+// @Summary 删除日志转发目标
+// @Tags 日志转发
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "转发目标ID"
+// @Success 200 {object} utils.Response
+// @Router /log-forwarders/{id} [delete]
+func (fc *LogForwardController) DeleteForwarder(c *gin.Context) {
+	if err := fc.forwardService.Delete(c.Param("id")); err != nil {
+		utils.ServerError(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "log_forwarder.delete", ResourceType: "log_forwarder", ResourceID: c.Param("id")})
+	utils.SuccessMsg(c, "删除成功")
+}
+
+// TestForwarder 发送测试日志
+// @Summary 发送测试日志
+// @Description 立即向转发目标发送一条测试日志，返回发送结果
+// @Tags 日志转发
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "转发目标ID"
+// @Success 200 {object} utils.Response
+// @Router /log-forwarders/{id}/test [post]
+func (fc *LogForwardController) TestForwarder(c *gin.Context) {
+	if err := fc.forwardService.Test(c.Param("id")); err != nil {
+		utils.BadRequest(c, "发送失败: "+err.Error())
+		return
+	}
+	utils.SuccessMsg(c, "测试日志已发送")
+}
@@ -3,8 +3,11 @@ package controllers
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/services/tasks"
 	"github.com/engigu/baihu-panel/internal/utils"
@@ -18,6 +21,7 @@ func NewLogSSEController() *LogSSEController {
 	return &LogSSEController{}
 }
 
+// StreamLog 推送任务日志，lines=1 时以 lines 事件推送带相对时间与来源的逐行日志
 func (lc *LogSSEController) StreamLog(c *gin.Context) {
 	logIDStr := c.Query("log_id")
 	if logIDStr == "" {
@@ -26,6 +30,10 @@ func (lc *LogSSEController) StreamLog(c *gin.Context) {
 	}
 
 	logID := logIDStr
+	if !middleware.GetAccessScope(c).AllowLog(logID) {
+		c.JSON(403, gin.H{"error": "无权访问该任务"})
+		return
+	}
 
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -33,15 +41,24 @@ func (lc *LogSSEController) StreamLog(c *gin.Context) {
 	c.Header("Transfer-Encoding", "chunked")
 	// c.Header("Access-Control-Allow-Origin", "*")
 
+	withLines := c.Query("lines") == "1"
+
 	// 1. 检查数据库中是否已结束
 	var taskLog models.TaskLog
 	res := database.DB.Where("id = ?", logID).Limit(1).Find(&taskLog)
 	if res.Error == nil && res.RowsAffected > 0 {
 		if taskLog.Status != "running" {
-			// 已结束，读取库内日志
-			content, err := utils.DecompressFromBase64(string(taskLog.Output))
+			if withLines {
+				if lines := taskLogLines(&taskLog); lines != nil {
+					c.SSEvent("lines", lines)
+					c.Writer.Flush()
+					return
+				}
+			}
+			// 已结束，读取库内或日志存储中的正文
+			content, err := logstore.ReadOutput(&taskLog)
 			if err != nil {
-				c.SSEvent("message", gin.H{"text": "解压日志失败: " + err.Error()})
+				c.SSEvent("message", gin.H{"text": "读取日志失败: " + err.Error()})
 				c.Writer.Flush()
 				return
 			}
@@ -63,6 +80,11 @@ func (lc *LogSSEController) StreamLog(c *gin.Context) {
 	c.SSEvent("message", gin.H{"text": fmt.Sprintf("[System] 连接成功，正在监听日志... (LogID: %s)\n", logID)})
 	c.Writer.Flush()
 
+	if withLines {
+		lc.streamLines(c, tl)
+		return
+	}
+
 	// 发送最后 100 行
 	lastLines, err := tl.ReadLastLines(100)
 	if err == nil && len(lastLines) > 0 {
@@ -83,8 +105,7 @@ func (lc *LogSSEController) StreamLog(c *gin.Context) {
 				var finalLog models.TaskLog
 				res := database.DB.Where("id = ?", logID).Limit(1).Find(&finalLog)
 				if res.Error == nil && res.RowsAffected > 0 {
-					content, _ := utils.DecompressFromBase64(string(finalLog.Output))
-					if content != "" {
+					if finalLog.Output != "" || finalLog.OutputRef != "" {
 						c.SSEvent("message", gin.H{"text": "\n--- 任务已结束 ---\n"})
 					}
 				}
@@ -97,3 +118,38 @@ func (lc *LogSSEController) StreamLog(c *gin.Context) {
 		}
 	})
 }
+
+// streamLines 推送运行中任务的最后 100 行及后续的逐行日志
+func (lc *LogSSEController) streamLines(c *gin.Context, tl *tasks.TinyLog) {
+	if lines, err := tl.LastLines(100); err == nil && len(lines) > 0 {
+		c.SSEvent("lines", lines)
+		c.Writer.Flush()
+	}
+
+	sub := tl.SubscribeChunks()
+	defer tl.UnsubscribeChunks(sub)
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case chunk, ok := <-sub:
+			if !ok {
+				return false
+			}
+			c.SSEvent("lines", chunkLines(chunk))
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// chunkLines 将一次写入的片段拆成行，同一片段内的行共享时间与来源
+func chunkLines(chunk tasks.LogChunk) []utils.LogLine {
+	text := strings.TrimSuffix(string(chunk.Data), "\n")
+	parts := strings.Split(text, "\n")
+	lines := make([]utils.LogLine, len(parts))
+	for i, part := range parts {
+		lines[i] = utils.LogLine{Offset: chunk.Offset, Stream: chunk.Stream, Text: strings.TrimSuffix(part, "\r")}
+	}
+	return lines
+}
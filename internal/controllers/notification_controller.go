@@ -43,11 +43,17 @@ func (nc *NotificationController) SaveChannel(c *gin.Context) {
 		utils.BadRequest(c, "渠道名称和类型不能为空")
 		return
 	}
+	if err := services.ValidateChatOpsConfig(req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
 
 	if err := nc.notifyService.SaveChannel(req); err != nil {
 		utils.ServerError(c, err.Error())
 		return
 	}
+	// 渠道配置中多为 webhook、密钥等凭据，只记录是否变更
+	recordAudit(c, services.AuditEntry{Action: "notify.channel_save", ResourceType: "notify_channel", ResourceID: req.ID, ResourceName: req.Name, After: req, Redact: []string{"config"}})
 
 	utils.SuccessMsg(c, "保存成功")
 }
@@ -64,6 +70,7 @@ func (nc *NotificationController) DeleteChannel(c *gin.Context) {
 		utils.ServerError(c, err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "notify.channel_delete", ResourceType: "notify_channel", ResourceID: id})
 
 	utils.SuccessMsg(c, "删除成功")
 }
@@ -123,6 +130,7 @@ func (nc *NotificationController) SaveBinding(c *gin.Context) {
 		utils.ServerError(c, err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "notify.binding_save", ResourceType: "notify_binding", ResourceID: binding.ID, ResourceName: binding.Event, After: binding})
 
 	utils.Success(c, binding)
 }
@@ -139,6 +147,7 @@ func (nc *NotificationController) DeleteBinding(c *gin.Context) {
 		utils.ServerError(c, err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "notify.binding_delete", ResourceType: "notify_binding", ResourceID: id})
 
 	utils.SuccessMsg(c, "删除成功")
 }
@@ -164,6 +173,7 @@ func (nc *NotificationController) BatchSaveBindings(c *gin.Context) {
 		utils.ServerError(c, err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "notify.binding_save", ResourceType: "notify_binding", ResourceID: req.DataID, ResourceName: req.Type, After: req.Bindings})
 
 	utils.SuccessMsg(c, "保存成功")
 }
@@ -192,3 +202,25 @@ func (nc *NotificationController) SendNotification(c *gin.Context) {
 
 	utils.Success(c, result)
 }
+
+// PreviewTemplate 预览通知模板渲染结果（默认基于最近一次真实执行记录）
+func (nc *NotificationController) PreviewTemplate(c *gin.Context) {
+	var req services.TemplatePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+
+	if req.Event == "" {
+		utils.BadRequest(c, "事件类型不能为空")
+		return
+	}
+
+	result, err := nc.notifyService.PreviewTemplate(req)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	utils.Success(c, result)
+}
@@ -40,6 +40,9 @@ func (sc *ScriptController) CreateScript(c *gin.Context) {
 	}
 
 	script := sc.scriptService.CreateScript(req.Name, req.Content, userID)
+	if script != nil {
+		recordAudit(c, services.AuditEntry{Action: "script.create", ResourceType: "script", ResourceID: script.ID, ResourceName: script.Name, After: vo.ToScriptVO(script)})
+	}
 	utils.Success(c, vo.ToScriptVO(script))
 }
 
@@ -118,11 +121,13 @@ func (sc *ScriptController) UpdateScript(c *gin.Context) {
 		return
 	}
 
+	before := vo.ToScriptVO(sc.scriptService.GetScriptByID(id))
 	script := sc.scriptService.UpdateScript(id, req.Name, req.Content)
 	if script == nil {
 		utils.NotFound(c, "脚本不存在")
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "script.update", ResourceType: "script", ResourceID: script.ID, ResourceName: script.Name, Before: before, After: vo.ToScriptVO(script)})
 
 	utils.Success(c, vo.ToScriptVO(script))
 }
@@ -145,11 +150,13 @@ func (sc *ScriptController) DeleteScript(c *gin.Context) {
 		return
 	}
 
+	before := vo.ToScriptVO(sc.scriptService.GetScriptByID(id))
 	success := sc.scriptService.DeleteScript(id)
 	if !success {
 		utils.NotFound(c, "脚本不存在")
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "script.delete", ResourceType: "script", ResourceID: id, ResourceName: before.Name, Before: before})
 
 	utils.SuccessMsg(c, "删除成功")
 }
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionItem 会话列表项，标记是否为当前请求所用的会话
+type sessionItem struct {
+	models.UserSession
+	Current bool `json:"current"`
+}
+
+func toSessionItems(sessions []models.UserSession, currentID string) []sessionItem {
+	items := make([]sessionItem, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, sessionItem{UserSession: s, Current: s.ID == currentID})
+	}
+	return items
+}
+
+// ListSessions 当前用户的登录会话
+func (ac *AuthController) ListSessions(c *gin.Context) {
+	utils.Success(c, toSessionItems(ac.sessionService.ListByUser(c.GetString("userID")), c.GetString("sessionID")))
+}
+
+// RevokeSession 吊销当前用户的某个会话，吊销当前会话等同退出登录
+func (ac *AuthController) RevokeSession(c *gin.Context) {
+	if err := ac.sessionService.Revoke(c.GetString("userID"), c.Param("id")); err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+	utils.SuccessMsg(c, "会话已吊销")
+}
+
+// RevokeOtherSessions 吊销当前用户除本会话外的全部会话
+func (ac *AuthController) RevokeOtherSessions(c *gin.Context) {
+	count := ac.sessionService.RevokeAll(c.GetString("userID"), c.GetString("sessionID"))
+	utils.Success(c, gin.H{"count": count})
+}
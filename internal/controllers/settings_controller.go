@@ -6,12 +6,15 @@ import (
 	"strconv"
 
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/engigu/baihu-panel/internal/backupstore"
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/database"
 	"github.com/engigu/baihu-panel/internal/eventbus"
@@ -122,6 +125,13 @@ func (sc *SettingsController) ChangePassword(c *gin.Context) {
 		},
 	})
 
+	after := gin.H{"username": user.Username, "pwd_changed": req.NewPassword != ""}
+	if req.Username != "" {
+		after["username"] = req.Username
+	}
+	recordAudit(c, services.AuditEntry{Action: "account.update", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username,
+		Before: gin.H{"username": user.Username}, After: after})
+
 	msg := "保存成功"
 	if logoutRequired {
 		msg += "，请重新登录"
@@ -172,6 +182,7 @@ func (sc *SettingsController) GetSiteSettings(c *gin.Context) {
 	settings["login_log_max_count"] = sc.settingsService.Get(constant.SectionSystem, constant.KeyLoginLogMaxCount)
 	settings["scheduler_log_days"] = sc.settingsService.Get(constant.SectionSystem, constant.KeySchedulerLogDays)
 	settings["scheduler_log_max_count"] = sc.settingsService.Get(constant.SectionSystem, constant.KeySchedulerLogMaxCount)
+	settings["audit_log_days"] = sc.settingsService.Get(constant.SectionSystem, constant.KeyAuditLogDays)
 
 	utils.Success(c, settings)
 }
@@ -193,40 +204,62 @@ func (sc *SettingsController) GetPublicSiteSettings(c *gin.Context) {
 		icon = constant.DefaultIcon
 	}
 
+	oidc := services.ParseOIDCConfig(sc.settingsService.GetSection(constant.SectionOIDC))
+	// 本机应急登录时仍展示密码登录表单
+	passwordDisabled := oidc.Ready() && oidc.DisablePassword && !services.GetAccessService().LocalBreakGlass(c.GetBool("localRequest"))
+
 	// 只返回公开信息
 	utils.Success(c, gin.H{
-		constant.KeyTitle:    title,
-		constant.KeySubtitle: subtitle,
-		constant.KeyIcon:     icon,
-		"demo_mode":          constant.DemoMode,
+		constant.KeyTitle:         title,
+		constant.KeySubtitle:      subtitle,
+		constant.KeyIcon:          icon,
+		"demo_mode":               constant.DemoMode,
+		"sso_enabled":             oidc.Ready(),
+		"sso_button_text":         oidc.ButtonText,
+		"password_login_disabled": passwordDisabled,
 	})
 }
 
 // UpdateSiteSettings 更新站点设置
 func (sc *SettingsController) UpdateSiteSettings(c *gin.Context) {
 	var req struct {
-		Title                string `json:"title"`
-		Subtitle             string `json:"subtitle"`
-		Icon                 string `json:"icon"`
-		PageSize             string `json:"page_size"`
-		CookieDays           string `json:"cookie_days"`
-		OpenapiEnabled       bool   `json:"openapi_enabled"`
-		OpenapiToken         string `json:"openapi_token"`
-		OpenapiTokenExpire   string `json:"openapi_token_expire"`
-		SystemNoticeDays     string `json:"system_notice_days"`
-		SystemNoticeMaxCount string `json:"system_notice_max_count"`
-		PushLogDays          string `json:"push_log_days"`
-		PushLogMaxCount      string `json:"push_log_max_count"`
-		LoginLogDays         string `json:"login_log_days"`
-		LoginLogMaxCount     string `json:"login_log_max_count"`
-		SchedulerLogDays     string `json:"scheduler_log_days"`
-		SchedulerLogMaxCount string `json:"scheduler_log_max_count"`
+		Title                string  `json:"title"`
+		Subtitle             string  `json:"subtitle"`
+		Icon                 string  `json:"icon"`
+		PageSize             string  `json:"page_size"`
+		CookieDays           string  `json:"cookie_days"`
+		OpenapiEnabled       bool    `json:"openapi_enabled"`
+		OpenapiToken         string  `json:"openapi_token"`
+		OpenapiTokenExpire   string  `json:"openapi_token_expire"`
+		SystemNoticeDays     string  `json:"system_notice_days"`
+		SystemNoticeMaxCount string  `json:"system_notice_max_count"`
+		PushLogDays          string  `json:"push_log_days"`
+		PushLogMaxCount      string  `json:"push_log_max_count"`
+		LoginLogDays         string  `json:"login_log_days"`
+		LoginLogMaxCount     string  `json:"login_log_max_count"`
+		SchedulerLogDays     string  `json:"scheduler_log_days"`
+		SchedulerLogMaxCount string  `json:"scheduler_log_max_count"`
+		AuditLogDays         string  `json:"audit_log_days"`
+		TrustedDeviceDays    string  `json:"trusted_device_days"`
+		SessionIdleMinutes   string  `json:"session_idle_minutes"`
+		EnvExpiryRemindDays  string  `json:"env_expiry_remind_days"`
+		PublicURL            *string `json:"public_url"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.BadRequest(c, "参数错误")
 		return
 	}
+	if req.PublicURL != nil {
+		*req.PublicURL = strings.TrimSuffix(strings.TrimSpace(*req.PublicURL), "/")
+		if *req.PublicURL != "" {
+			if err := services.ValidatePublicURL(*req.PublicURL); err != nil {
+				utils.BadRequest(c, err.Error())
+				return
+			}
+		}
+	}
+	before := sc.settingsSnapshot(constant.SectionSite, constant.SectionSystem)
 
 	openapiTokenJson := ""
 	if req.OpenapiToken != "" || req.OpenapiTokenExpire != "" || req.OpenapiEnabled {
@@ -248,6 +281,18 @@ func (sc *SettingsController) UpdateSiteSettings(c *gin.Context) {
 		constant.KeyCookieDays:   req.CookieDays,
 		constant.KeyOpenapiToken: openapiTokenJson,
 	}
+	if req.TrustedDeviceDays != "" {
+		values[constant.KeyTrustedDeviceDays] = req.TrustedDeviceDays
+	}
+	if req.SessionIdleMinutes != "" {
+		values[constant.KeySessionIdleMinutes] = req.SessionIdleMinutes
+	}
+	if req.EnvExpiryRemindDays != "" {
+		values[constant.KeyEnvExpiryRemindDays] = req.EnvExpiryRemindDays
+	}
+	if req.PublicURL != nil {
+		values[constant.KeyPublicURL] = *req.PublicURL
+	}
 
 	if err := sc.settingsService.SetSection(constant.SectionSite, values); err != nil {
 		utils.ServerError(c, "保存失败")
@@ -263,7 +308,12 @@ func (sc *SettingsController) UpdateSiteSettings(c *gin.Context) {
 	sc.settingsService.Set(constant.SectionSystem, constant.KeyLoginLogMaxCount, req.LoginLogMaxCount)
 	sc.settingsService.Set(constant.SectionSystem, constant.KeySchedulerLogDays, req.SchedulerLogDays)
 	sc.settingsService.Set(constant.SectionSystem, constant.KeySchedulerLogMaxCount, req.SchedulerLogMaxCount)
+	if req.AuditLogDays != "" {
+		sc.settingsService.Set(constant.SectionSystem, constant.KeyAuditLogDays, req.AuditLogDays)
+	}
 
+	recordAudit(c, services.AuditEntry{Action: "settings.update", ResourceType: "settings", ResourceID: constant.SectionSite,
+		Before: before, After: sc.settingsSnapshot(constant.SectionSite, constant.SectionSystem)})
 	utils.SuccessMsg(c, "保存成功")
 }
 
@@ -283,9 +333,10 @@ func (sc *SettingsController) GetSchedulerSettings(c *gin.Context) {
 // UpdateSchedulerSettings 更新调度设置
 func (sc *SettingsController) UpdateSchedulerSettings(c *gin.Context) {
 	var req struct {
-		WorkerCount  string `json:"worker_count"`
-		QueueSize    string `json:"queue_size"`
-		RateInterval string `json:"rate_interval"`
+		WorkerCount    string `json:"worker_count"`
+		QueueSize      string `json:"queue_size"`
+		RateInterval   string `json:"rate_interval"`
+		SeparateStderr string `json:"separate_stderr"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -316,11 +367,17 @@ func (sc *SettingsController) UpdateSchedulerSettings(c *gin.Context) {
 		constant.KeyQueueSize:    req.QueueSize,
 		constant.KeyRateInterval: req.RateInterval,
 	}
+	if req.SeparateStderr != "" {
+		values[constant.KeySeparateStderr] = strconv.FormatBool(req.SeparateStderr == "true")
+	}
 
+	before := sc.settingsSnapshot(constant.SectionScheduler)
 	if err := sc.settingsService.SetSection(constant.SectionScheduler, values); err != nil {
 		utils.ServerError(c, "保存失败")
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "settings.update", ResourceType: "settings", ResourceID: constant.SectionScheduler,
+		Before: before, After: sc.settingsSnapshot(constant.SectionScheduler)})
 
 	// 重新加载 executor service
 	if sc.executorService != nil {
@@ -470,13 +527,21 @@ func (sc *SettingsController) GetLoginLogs(c *gin.Context) {
 	})
 }
 
-// CreateBackup 创建备份
+// CreateBackup 创建备份，请求体可选 passphrase 用于加密备份包
 func (sc *SettingsController) CreateBackup(c *gin.Context) {
-	_, err := sc.backupService.CreateBackup()
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	_, err := sc.backupService.CreateBackup(req.Passphrase)
 	if err != nil {
 		utils.ServerError(c, "创建备份失败: "+err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "backup.create", ResourceType: "backup", After: gin.H{"encrypted": req.Passphrase != ""}})
 	utils.SuccessMsg(c, "备份创建成功")
 }
 
@@ -510,6 +575,8 @@ func (sc *SettingsController) DownloadBackup(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "backup.download", ResourceType: "backup", ResourceName: filepath.Base(filePath)})
+
 	// 设置响应头
 	c.Header("Content-Disposition", "attachment; filename="+filepath.Base(filePath))
 	c.Header("Content-Type", "application/zip")
@@ -522,7 +589,8 @@ func (sc *SettingsController) DownloadBackup(c *gin.Context) {
 	}()
 }
 
-// RestoreBackup 恢复备份
+// RestoreBackup 恢复备份，表单字段 passphrase 为加密备份的口令，plan 为 JSON 格式的恢复方案（留空时全量恢复），
+// dry_run=1 时只返回恢复预演结果
 func (sc *SettingsController) RestoreBackup(c *gin.Context) {
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -531,22 +599,129 @@ func (sc *SettingsController) RestoreBackup(c *gin.Context) {
 	}
 
 	// 保存上传的文件
-	tempPath := filepath.Join(os.TempDir(), file.Filename)
-	if err := c.SaveUploadedFile(file, tempPath); err != nil {
+	tempFile, err := os.CreateTemp("", "baihu-upload-*.zip")
+	if err != nil {
 		utils.ServerError(c, "保存文件失败")
 		return
 	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
 	defer os.Remove(tempPath)
+	if err := c.SaveUploadedFile(file, tempPath); err != nil {
+		utils.ServerError(c, "保存文件失败")
+		return
+	}
+
+	passphrase := c.PostForm("passphrase")
+	var plan *services.RestorePlan
+	if raw := c.PostForm("plan"); raw != "" {
+		plan = &services.RestorePlan{}
+		if err := json.Unmarshal([]byte(raw), plan); err != nil {
+			utils.BadRequest(c, "恢复方案格式错误")
+			return
+		}
+	}
+	if c.PostForm("dry_run") == "1" {
+		preview, err := sc.backupService.PreviewRestore(tempPath, passphrase, plan)
+		if err != nil {
+			restoreError(c, err)
+			return
+		}
+		utils.Success(c, preview)
+		return
+	}
 
 	// 恢复备份
-	if err := sc.backupService.Restore(tempPath); err != nil {
-		utils.ServerError(c, "恢复失败: "+err.Error())
+	if err := sc.backupService.Restore(tempPath, passphrase, plan); err != nil {
+		restoreError(c, err)
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "backup.restore", ResourceType: "backup", ResourceName: file.Filename, After: plan})
 
 	utils.SuccessMsg(c, "恢复成功")
 }
 
+// RunBackupNow 按定时备份设置立即执行一次备份并上传
+func (sc *SettingsController) RunBackupNow(c *gin.Context) {
+	result, err := sc.backupService.RunScheduledBackup()
+	if err != nil {
+		utils.ServerError(c, "备份失败: "+err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "backup.run", ResourceType: "backup", ResourceName: result.File})
+	utils.Success(c, result)
+}
+
+// TestBackupTarget 测试备份目标连接，请求体为尚未保存的备份设置
+func (sc *SettingsController) TestBackupTarget(c *gin.Context) {
+	var values map[string]string
+	if err := c.ShouldBindJSON(&values); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	utils.Success(c, services.TestBackupTarget(values))
+}
+
+// ListRemoteBackups 列出备份目标中的备份文件
+func (sc *SettingsController) ListRemoteBackups(c *gin.Context) {
+	files, err := sc.backupService.ListRemoteBackups()
+	if err != nil {
+		utils.ServerError(c, "获取远程备份失败: "+err.Error())
+		return
+	}
+	utils.Success(c, files)
+}
+
+// RestoreRemoteBackup 从备份目标下载并按恢复方案恢复指定备份，未提供 plan 时全量恢复
+func (sc *SettingsController) RestoreRemoteBackup(c *gin.Context) {
+	var req struct {
+		Name       string                `json:"name" binding:"required"`
+		Passphrase string                `json:"passphrase"`
+		Plan       *services.RestorePlan `json:"plan"`
+		DryRun     bool                  `json:"dry_run"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误")
+		return
+	}
+	preview, err := sc.backupService.RestoreRemote(req.Name, req.Passphrase, req.Plan, req.DryRun)
+	if err != nil {
+		if errors.Is(err, backupstore.ErrNotFound) {
+			utils.NotFound(c, "备份文件不存在")
+			return
+		}
+		restoreError(c, err)
+		return
+	}
+	if req.DryRun {
+		utils.Success(c, preview)
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "backup.restore", ResourceType: "backup", ResourceName: req.Name, After: req.Plan})
+	utils.SuccessMsg(c, "恢复成功")
+}
+
+// DeleteRemoteBackup 删除备份目标中的指定备份
+func (sc *SettingsController) DeleteRemoteBackup(c *gin.Context) {
+	name := c.Param("name")
+	if err := sc.backupService.DeleteRemote(name); err != nil {
+		utils.ServerError(c, "删除失败: "+err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "backup.delete", ResourceType: "backup", ResourceName: name})
+	utils.SuccessMsg(c, "删除成功")
+}
+
+// restoreError 口令与完整性错误属于请求问题，其余按服务端错误返回
+func restoreError(c *gin.Context, err error) {
+	if errors.Is(err, backupstore.ErrPassphraseRequired) || errors.Is(err, backupstore.ErrBadPassphrase) ||
+		errors.Is(err, services.ErrBackupIntegrity) || errors.Is(err, services.ErrRestorePlan) {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.ServerError(c, "恢复失败: "+err.Error())
+}
+
 // GetSectionSettings 获取指定 section 的所有设置
 func (sc *SettingsController) GetSectionSettings(c *gin.Context) {
 	section := c.Param("section")
@@ -572,10 +747,82 @@ func (sc *SettingsController) UpdateSectionSettings(c *gin.Context) {
 		return
 	}
 
+	if section == constant.SectionAccess {
+		if err := services.ValidateAccessSettings(values); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+		// 防止管理员把自己锁在面板外
+		if list, ok := values[constant.KeyAccessUIAllowlist]; ok {
+			nets, _ := services.ParseCIDRList(list)
+			ip := c.ClientIP()
+			if len(nets) > 0 && !services.IPInList(ip, nets) {
+				utils.BadRequest(c, fmt.Sprintf("当前 IP %s 不在管理界面允许列表中，保存后将无法访问面板", ip))
+				return
+			}
+		}
+	}
+
+	if section == constant.SectionLogStore {
+		if err := services.ValidateLogStoreSettings(values); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	if section == constant.SectionBackup {
+		// 运行状态由定时备份自身维护，不接受前端写入
+		for _, key := range []string{constant.KeyBackupFile, constant.KeyBackupLastRunAt, constant.KeyBackupLastFile, constant.KeyBackupLastError} {
+			delete(values, key)
+		}
+		if err := services.ValidateBackupSettings(values); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	if section == constant.SectionGitOps {
+		// 运行状态由同步自身维护，不接受前端写入
+		for _, key := range []string{constant.KeyGitOpsLastRunAt, constant.KeyGitOpsLastCommit, constant.KeyGitOpsLastSummary, constant.KeyGitOpsLastError} {
+			delete(values, key)
+		}
+		if err := services.ValidateGitOpsSettings(values); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+
+	before := sc.settingsSnapshot(section)
 	if err := sc.settingsService.SetSection(section, values); err != nil {
 		utils.ServerError(c, "更新失败")
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "settings.update", ResourceType: "settings", ResourceID: section,
+		Before: before, After: sc.settingsSnapshot(section)})
+
+	// 外部机密后端配置变更后丢弃旧的解析缓存
+	if section == constant.SectionSecretBackend {
+		services.ClearSecretCache()
+	}
+	if section == constant.SectionAccess {
+		services.GetAccessService().Reload()
+	}
+	if section == constant.SectionLogStore {
+		if err := services.ApplyLogStoreSettings(); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	if section == constant.SectionBackup {
+		if err := services.ApplyBackupSchedule(); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
+	if section == constant.SectionGitOps {
+		if err := services.ApplyGitOpsSchedule(); err != nil {
+			utils.BadRequest(c, err.Error())
+			return
+		}
+	}
 
 	// 当互联配置发生改变时，通知 tunnel 模块立刻应用新角色，启动或停止相关的后台协程
 	if section == constant.SectionInterconnect {
@@ -587,6 +834,23 @@ func (sc *SettingsController) UpdateSectionSettings(c *gin.Context) {
 	utils.SuccessMsg(c, "保存成功")
 }
 
+// TestSecretRef 按当前外部机密后端配置试解析一个引用，只返回长度不回显明文
+func (sc *SettingsController) TestSecretRef(c *gin.Context) {
+	var req struct {
+		Ref string `json:"ref" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "请填写要检测的引用")
+		return
+	}
+	value, err := services.TestSecretRef(req.Ref)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"length": len(value)})
+}
+
 // GetSetting 获取单个设置值
 func (sc *SettingsController) GetSetting(c *gin.Context) {
 	section := c.Param("section")
@@ -619,6 +883,34 @@ func (sc *SettingsController) GenerateSettingToken(c *gin.Context) {
 		utils.ServerError(c, "保存失败")
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "settings.generate_token", ResourceType: "settings", ResourceID: section + "." + key})
 
 	utils.Success(c, token)
 }
+
+// settingsSnapshot 以 section.key 平铺指定分组的设置，用于审计差异
+func (sc *SettingsController) settingsSnapshot(sections ...string) map[string]string {
+	snapshot := map[string]string{}
+	for _, section := range sections {
+		for k, v := range sc.settingsService.GetSection(section) {
+			snapshot[section+"."+k] = v
+		}
+	}
+	return snapshot
+}
+
+// GetBannedIPs 获取当前被封禁的 IP
+func (sc *SettingsController) GetBannedIPs(c *gin.Context) {
+	utils.Success(c, services.GetAccessService().ListBans())
+}
+
+// UnbanIP 解除 IP 封禁
+func (sc *SettingsController) UnbanIP(c *gin.Context) {
+	ip := c.Param("ip")
+	if !services.GetAccessService().Unban(ip) {
+		utils.NotFound(c, "该 IP 未被封禁")
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "access.unban", ResourceType: "ip", ResourceID: ip, ResourceName: ip})
+	utils.SuccessMsg(c, "已解除封禁")
+}
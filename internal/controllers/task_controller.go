@@ -2,12 +2,14 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 	"strings"
 
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/database"
 	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/models/vo"
 	"github.com/engigu/baihu-panel/internal/services"
@@ -100,6 +102,38 @@ func getRepoPhysicalPath(targetPath, dirName, sourceURL, branch string) string {
 	}
 	return absPath
 }
+// taskAllEnvs 判断任务配置是否开启了注入全部环境变量
+func taskAllEnvs(config string) bool {
+	var cfg models.TaskConfig
+	return config != "" && json.Unmarshal([]byte(config), &cfg) == nil && cfg.AllEnvs
+}
+
+// checkTaskEnvs 校验任务引用的环境变量：非管理员只能引用自己创建的变量，且不能开启注入全部环境变量
+// 任务上原有的引用与配置不受限制，避免无法编辑管理员配置过的任务；返回空字符串表示校验通过
+func checkTaskEnvs(c *gin.Context, envIDs, config string, oldTask *models.Task, getEnv func(id string) *models.EnvironmentVariable) string {
+	if middleware.GetAccessScope(c).Can(constant.AdminRole) {
+		return ""
+	}
+	if taskAllEnvs(config) && (oldTask == nil || !taskAllEnvs(string(oldTask.Config))) {
+		return "仅管理员可以开启注入全部环境变量"
+	}
+	existing := make(map[string]bool)
+	if oldTask != nil {
+		for _, id := range strings.Split(string(oldTask.Envs), ",") {
+			existing[strings.TrimSpace(id)] = true
+		}
+	}
+	for _, id := range strings.Split(envIDs, ",") {
+		if id = strings.TrimSpace(id); id == "" || existing[id] {
+			continue
+		}
+		if env := getEnv(id); env == nil || !canAccessEnv(c, env) {
+			return fmt.Sprintf("无权引用环境变量 %s", id)
+		}
+	}
+	return ""
+}
+
 // CreateTask 创建任务
 // @Summary 创建任务
 // @Description 创建一个新的任务
@@ -119,6 +153,16 @@ func (tc *TaskController) CreateTask(c *gin.Context) {
 		return
 	}
 
+	// 受标签范围限制的账户创建的任务必须带有可访问的标签，否则创建后将无法管理
+	if scope := middleware.GetAccessScope(c); scope.TaskLimited() || !scope.AllowTags(req.Tags) {
+		utils.Forbidden(c, "任务标签需包含当前账户可访问的标签")
+		return
+	}
+	if msg := checkTaskEnvs(c, req.Envs, req.Config, nil, services.NewEnvService().GetEnvVarByID); msg != "" {
+		utils.Forbidden(c, msg)
+		return
+	}
+
 	// 普通任务需要命令
 	if req.Type != constant.TaskTypeRepo && req.Command == "" {
 		utils.BadRequest(c, "命令不能为空")
@@ -225,6 +269,7 @@ func (tc *TaskController) CreateTask(c *gin.Context) {
 		tc.executorService.AddCronTask(task)
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "task.create", ResourceType: "task", ResourceID: task.ID, ResourceName: task.Name, After: vo.ToTaskVO(task)})
 	utils.Success(c, vo.ToTaskVO(task))
 }
 
@@ -243,6 +288,7 @@ func (tc *TaskController) BulkSaveTask(c *gin.Context) {
 		utils.BadRequest(c, err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "task.bulk_save", ResourceType: "task", ResourceName: fmt.Sprintf("%d 个任务", len(reqs))})
 
 	for _, req := range reqs {
 		param := tasks.TaskParam{
@@ -329,7 +375,12 @@ func (tc *TaskController) GetTasks(c *gin.Context) {
 	name := c.DefaultQuery("name", "")
 	agentIDStr := c.DefaultQuery("agent_id", "")
 
-	tags := c.DefaultQuery("tags", "")
+	scope := middleware.GetAccessScope(c)
+	tags, ok := scope.FilterTags(c.DefaultQuery("tags", ""))
+	if !ok {
+		utils.PaginatedResponse(c, []vo.TaskVO{}, 0, p)
+		return
+	}
 	taskType := c.DefaultQuery("type", "")
 
 	var agentID *string
@@ -340,7 +391,7 @@ func (tc *TaskController) GetTasks(c *gin.Context) {
 	sortBy := c.DefaultQuery("sort_by", "")
 	order := c.DefaultQuery("order", "")
 
-	tasks, total := tc.taskService.GetTasksWithPagination(p.Page, p.PageSize, name, agentID, tags, taskType, sortBy, order)
+	tasks, total := tc.taskService.GetTasksWithPagination(p.Page, p.PageSize, name, agentID, tags, scope.LimitedTaskIDs(), taskType, sortBy, order)
 	utils.PaginatedResponse(c, vo.ToTaskVOListFromModels(tasks), total, p)
 }
 
@@ -367,6 +418,10 @@ func (tc *TaskController) GetTask(c *gin.Context) {
 		utils.NotFound(c, "任务不存在")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowTaskTags(task.ID, task.Tags) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
 
 	utils.Success(c, vo.ToTaskVO(task))
 }
@@ -404,6 +459,20 @@ func (tc *TaskController) UpdateTask(c *gin.Context) {
 		return
 	}
 
+	scope := middleware.GetAccessScope(c)
+	if oldTask != nil && !scope.AllowTaskTags(oldTask.ID, oldTask.Tags) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
+	if !scope.AllowTags(req.Tags) {
+		utils.Forbidden(c, "任务标签需包含当前账户可访问的标签")
+		return
+	}
+	if msg := checkTaskEnvs(c, req.Envs, req.Config, oldTask, services.NewEnvService().GetEnvVarByID); msg != "" {
+		utils.Forbidden(c, msg)
+		return
+	}
+
 	if req.Schedule != "" {
 		if err := tc.executorService.ValidateCron(req.Schedule); err != nil {
 			utils.BadRequest(c, "无效的cron表达式: "+err.Error())
@@ -530,6 +599,7 @@ func (tc *TaskController) UpdateTask(c *gin.Context) {
 		}
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "task.update", ResourceType: "task", ResourceID: task.ID, ResourceName: task.Name, Before: vo.ToTaskVO(oldTask), After: vo.ToTaskVO(task)})
 	utils.Success(c, vo.ToTaskVO(task))
 }
 
@@ -557,6 +627,10 @@ func (tc *TaskController) DeleteTask(c *gin.Context) {
 		utils.NotFound(c, "任务不存在")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowTaskTags(task.ID, task.Tags) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
 
 	agentID := task.AgentID
 	deleteFiles := c.Query("delete_files") == "true"
@@ -580,6 +654,7 @@ func (tc *TaskController) DeleteTask(c *gin.Context) {
 		tc.agentWSManager.BroadcastTasks(*agentID)
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "task.delete", ResourceType: "task", ResourceID: task.ID, ResourceName: task.Name, Before: vo.ToTaskVO(task)})
 	utils.SuccessMsg(c, "删除成功")
 }
 
@@ -654,15 +729,22 @@ func (tc *TaskController) BatchDeleteTasks(c *gin.Context) {
 	}
 
 	// 收集涉及到的 AgentID
+	scope := middleware.GetAccessScope(c)
 	agentIDs := make(map[string]struct{})
+	ids := make([]string, 0, len(req.IDs))
 	for _, id := range req.IDs {
 		// 获取任务信息
 		task := tc.taskService.GetTaskByID(id)
 		if task != nil {
+			// 跳过不在访问范围内的任务
+			if !scope.AllowTaskTags(task.ID, task.Tags) {
+				continue
+			}
 			if task.AgentID != nil && *task.AgentID != "" {
 				agentIDs[*task.AgentID] = struct{}{}
 			}
 		}
+		ids = append(ids, id)
 
 		// 移除 cron 调度
 		tc.executorService.RemoveCronTask(id)
@@ -670,13 +752,14 @@ func (tc *TaskController) BatchDeleteTasks(c *gin.Context) {
 	}
 
 	// 执行批量删除
-	count := tc.taskService.BatchDeleteTasks(req.IDs)
+	count := tc.taskService.BatchDeleteTasks(ids)
 
 	// 通知受影响的 Agent
 	for agentID := range agentIDs {
 		tc.agentWSManager.BroadcastTasks(agentID)
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "task.batch_delete", ResourceType: "task", ResourceID: strings.Join(ids, ","), ResourceName: fmt.Sprintf("%d 个任务", count)})
 	utils.Success(c, gin.H{"count": count})
 }
 
@@ -697,15 +780,20 @@ func (tc *TaskController) BatchDeleteTasks(c *gin.Context) {
 func (tc *TaskController) BatchDeleteByQuery(c *gin.Context) {
 	name := c.Query("name")
 	agentIDStr := c.Query("agent_id")
-	tags := c.Query("tags")
 	taskType := c.Query("type")
+	scope := middleware.GetAccessScope(c)
+	tags, ok := scope.FilterTags(c.Query("tags"))
+	if !ok {
+		utils.Success(c, gin.H{"count": 0})
+		return
+	}
 
 	var agentID *string
 	if agentIDStr != "" {
 		agentID = &agentIDStr
 	}
 
-	tasks, _ := tc.taskService.GetTasksWithPagination(1, 999999, name, agentID, tags, taskType, "", "")
+	tasks, _ := tc.taskService.GetTasksWithPagination(1, 999999, name, agentID, tags, scope.LimitedTaskIDs(), taskType, "", "")
 	if len(tasks) == 0 {
 		utils.Success(c, gin.H{"count": 0})
 		return
@@ -731,6 +819,7 @@ func (tc *TaskController) BatchDeleteByQuery(c *gin.Context) {
 		tc.agentWSManager.BroadcastTasks(aID)
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "task.batch_delete", ResourceType: "task", ResourceID: strings.Join(ids, ","), ResourceName: fmt.Sprintf("%d 个任务", count)})
 	utils.Success(c, gin.H{"count": count})
 }
 
@@ -751,12 +840,17 @@ func (tc *TaskController) StopTask(c *gin.Context) {
 		utils.BadRequest(c, "无效的日志ID")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowLog(logID) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
 
 	err := tc.executorService.StopTaskExecution(logID)
 	if err != nil {
 		utils.BadRequest(c, err.Error())
 		return
 	}
+	recordAudit(c, services.AuditEntry{Action: "task.stop", ResourceType: "task_log", ResourceID: logID})
 
 	utils.SuccessMsg(c, "停止请求已发送")
 }
@@ -775,6 +869,15 @@ func (tc *TaskController) GetTags(c *gin.Context) {
 		utils.ServerError(c, err.Error())
 		return
 	}
+	if scope := middleware.GetAccessScope(c); scope.Scoped() {
+		visible := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if scope.AllowTags(tag) {
+				visible = append(visible, tag)
+			}
+		}
+		tags = visible
+	}
 	utils.Success(c, tags)
 }
 
@@ -816,6 +919,10 @@ func (tc *TaskController) ToggleTask(c *gin.Context) {
 		utils.NotFound(c, "任务不存在")
 		return
 	}
+	if !middleware.GetAccessScope(c).AllowTaskTags(task.ID, task.Tags) {
+		utils.Forbidden(c, "无权访问该任务")
+		return
+	}
 
 	// 获取旧 AgentID
 	var oldAgentID *string
@@ -868,5 +975,6 @@ func (tc *TaskController) ToggleTask(c *gin.Context) {
 		}
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "task.toggle", ResourceType: "task", ResourceID: task.ID, ResourceName: task.Name, Before: vo.ToTaskVO(task), After: vo.ToTaskVO(updatedTask)})
 	utils.Success(c, vo.ToTaskVO(updatedTask))
 }
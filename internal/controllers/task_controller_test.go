@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheckTaskEnvs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	envs := map[string]*models.EnvironmentVariable{
+		"own":   {ID: "own", UserID: "u1"},
+		"admin": {ID: "admin", UserID: "root"},
+	}
+	getEnv := func(id string) *models.EnvironmentVariable { return envs[id] }
+	newCtx := func(role, tags string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set("userID", "u1")
+		c.Set("scope", services.NewAccessScope(role, tags))
+		return c
+	}
+
+	editor := newCtx(constant.RoleEditor, "team")
+	if msg := checkTaskEnvs(editor, "own", "", nil, getEnv); msg != "" {
+		t.Errorf("own env should be allowed: %s", msg)
+	}
+	// 引用范围外的变量
+	for _, ids := range []string{"admin", "own,admin", "missing"} {
+		if msg := checkTaskEnvs(editor, ids, "", nil, getEnv); msg == "" {
+			t.Errorf("envs %q outside scope should be rejected", ids)
+		}
+	}
+	// 非管理员开启注入全部环境变量
+	if msg := checkTaskEnvs(editor, "", `{"$task_all_envs":true}`, nil, getEnv); msg == "" {
+		t.Error("non-admin must not enable all envs")
+	}
+
+	// 任务上原有的引用与配置保持不变时允许编辑
+	old := &models.Task{Envs: "admin", Config: `{"$task_all_envs":true}`}
+	if msg := checkTaskEnvs(editor, "admin,own", `{"$task_all_envs":true}`, old, getEnv); msg != "" {
+		t.Errorf("unchanged references should be kept: %s", msg)
+	}
+
+	admin := newCtx(constant.AdminRole, "")
+	if msg := checkTaskEnvs(admin, "admin,missing", `{"$task_all_envs":true}`, nil, getEnv); msg != "" {
+		t.Errorf("admin is not restricted: %s", msg)
+	}
+}
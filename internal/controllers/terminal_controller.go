@@ -88,6 +88,7 @@ func (tc *TerminalController) HandleWebSocket(c *gin.Context) {
 	if userID == "" {
 		userID = "1" // 兜底
 	}
+	recordAudit(c, services.AuditEntry{Action: "terminal.open", ResourceType: "terminal"})
 	if windows.IsWindows() {
 		if windows.HasConPTYSupport() {
 			tc.handleConPtyMode(conn, userID)
@@ -518,6 +519,7 @@ func (tc *TerminalController) ExecuteShellCommand(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, services.AuditEntry{Action: "terminal.exec", ResourceType: "terminal", After: req})
 	cmd := utils.NewShellCommandCmd(req.Command)
 	userID := c.GetString("userID")
 	if userID == "" {
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/models/vo"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UserController struct {
+	userService    *services.UserService
+	sessionService *services.SessionService
+}
+
+func NewUserController(userService *services.UserService, sessionService *services.SessionService) *UserController {
+	return &UserController{userService: userService, sessionService: sessionService}
+}
+
+// ListUsers 获取用户列表
+// @Summary 获取用户列表
+// @Description 获取全部用户及其角色、标签范围
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=[]vo.UserVO}
+// @Router /users [get]
+func (uc *UserController) ListUsers(c *gin.Context) {
+	users := uc.userService.ListUsers()
+	result := make([]*vo.UserVO, 0, len(users))
+	for i := range users {
+		result = append(result, vo.ToUserVO(&users[i]))
+	}
+	utils.Success(c, gin.H{"users": result, "roles": services.AssignableRoles})
+}
+
+// CreateUser 创建用户
+// @Summary 创建用户
+// @Description 创建新用户并指定角色与可访问的任务标签
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response{data=vo.UserVO}
+// @Router /users [post]
+func (uc *UserController) CreateUser(c *gin.Context) {
+	if constant.DemoMode {
+		utils.BadRequest(c, "演示模式下不能管理用户")
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+		Email    string `json:"email"`
+		Role     string `json:"role"`
+		Scopes   string `json:"scopes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Role == "" {
+		req.Role = constant.DefaultRole
+	}
+	if !services.IsValidRole(req.Role) {
+		utils.BadRequest(c, "无效的角色")
+		return
+	}
+	if len(req.Password) < 6 {
+		utils.BadRequest(c, "密码长度不能少于 6 位")
+		return
+	}
+	if uc.userService.GetUserByUsername(req.Username) != nil {
+		utils.BadRequest(c, "用户名已存在")
+		return
+	}
+
+	user := uc.userService.CreateUser(req.Username, req.Password, strings.TrimSpace(req.Email), req.Role)
+	if scopes := strings.Join(services.SplitScopes(req.Scopes), ","); scopes != "" {
+		user, _ = uc.userService.UpdateUser(user.ID, services.UserUpdate{Scopes: &scopes})
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.create", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username, After: vo.ToUserVO(user)})
+	utils.Success(c, vo.ToUserVO(user))
+}
+
+// UpdateUser 更新用户
+// @Summary 更新用户
+// @Description 修改用户角色、标签范围、邮箱或禁用状态
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Success 200 {object} utils.Response{data=vo.UserVO}
+// @Router /users/{id} [put]
+func (uc *UserController) UpdateUser(c *gin.Context) {
+	if constant.DemoMode {
+		utils.BadRequest(c, "演示模式下不能管理用户")
+		return
+	}
+
+	var req struct {
+		Email    *string `json:"email"`
+		Role     *string `json:"role"`
+		Scopes   *string `json:"scopes"`
+		Disabled *bool   `json:"disabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	id := c.Param("id")
+	if req.Role != nil && !services.IsValidRole(*req.Role) {
+		utils.BadRequest(c, "无效的角色")
+		return
+	}
+	if id == c.GetString("userID") && ((req.Disabled != nil && *req.Disabled) || (req.Role != nil && *req.Role != constant.AdminRole)) {
+		utils.BadRequest(c, "不能禁用或降级当前登录的账户")
+		return
+	}
+
+	before, _ := uc.userService.GetUserByID(id)
+	user, err := uc.userService.UpdateUser(id, services.UserUpdate{
+		Email:    req.Email,
+		Role:     req.Role,
+		Scopes:   req.Scopes,
+		Disabled: req.Disabled,
+	})
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.update", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username, Before: vo.ToUserVO(before), After: vo.ToUserVO(user)})
+	utils.Success(c, vo.ToUserVO(user))
+}
+
+// ResetPassword 重置用户密码
+// @Summary 重置用户密码
+// @Description 为用户设置新密码，未指定时随机生成；重置后该用户需重新登录
+// @Tags 用户管理
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Success 200 {object} utils.Response{data=map[string]string}
+// @Router /users/{id}/reset-password [post]
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	if constant.DemoMode {
+		utils.BadRequest(c, "演示模式下不能管理用户")
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	id := c.Param("id")
+	user, _ := uc.userService.GetUserByID(id)
+	if user == nil {
+		utils.NotFound(c, "用户不存在")
+		return
+	}
+
+	password := req.Password
+	if password == "" {
+		password = utils.RandomString(12)
+	} else if len(password) < 6 {
+		utils.BadRequest(c, "密码长度不能少于 6 位")
+		return
+	}
+
+	if err := uc.userService.UpdatePassword(id, password); err != nil {
+		utils.ServerError(c, "重置密码失败")
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.reset_password", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username})
+	utils.Success(c, gin.H{"password": password})
+}
+
+// ListUserSessions 获取用户的登录会话
+// @Summary 获取用户的登录会话
+// @Description 查看指定用户当前有效的登录会话（设备、IP、最近活跃时间）
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Success 200 {object} utils.Response{data=[]sessionItem}
+// @Router /users/{id}/sessions [get]
+func (uc *UserController) ListUserSessions(c *gin.Context) {
+	utils.Success(c, toSessionItems(uc.sessionService.ListByUser(c.Param("id")), c.GetString("sessionID")))
+}
+
+// RevokeUserSessions 吊销用户的登录会话
+// @Summary 吊销用户的登录会话
+// @Description 指定 session_id 时吊销单个会话，否则吊销该用户的全部会话
+// @Tags 用户管理
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "用户ID"
+// @Param session_id query string false "会话ID"
+// @Success 200 {object} utils.Response
+// @Router /users/{id}/sessions [delete]
+func (uc *UserController) RevokeUserSessions(c *gin.Context) {
+	if constant.DemoMode {
+		utils.BadRequest(c, "演示模式下不能管理用户")
+		return
+	}
+	id := c.Param("id")
+	user, _ := uc.userService.GetUserByID(id)
+	if user == nil {
+		utils.NotFound(c, "用户不存在")
+		return
+	}
+
+	if sid := c.Query("session_id"); sid != "" {
+		if err := uc.sessionService.Revoke(id, sid); err != nil {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		recordAudit(c, services.AuditEntry{Action: "user.session_revoke", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username,
+			Before: gin.H{"session_id": sid}})
+		utils.SuccessMsg(c, "会话已吊销")
+		return
+	}
+
+	count := uc.sessionService.RevokeAll(id, "")
+	recordAudit(c, services.AuditEntry{Action: "user.session_revoke_all", ResourceType: "user", ResourceID: user.ID, ResourceName: user.Username,
+		After: gin.H{"count": count}})
+	utils.SuccessMsg(c, "已吊销该用户的全部会话")
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebAuthnLoginBegin 生成通行密钥登录挑战
+// 携带 otp_pending_token 时作为密码之后的第二因素，否则为无密码登录
+func (ac *AuthController) WebAuthnLoginBegin(c *gin.Context) {
+	var req struct {
+		OtpPendingToken string `json:"otp_pending_token"`
+	}
+	c.ShouldBindJSON(&req)
+
+	userID := ""
+	if req.OtpPendingToken != "" {
+		user := ac.pendingUser(c, req.OtpPendingToken)
+		if user == nil {
+			return
+		}
+		userID = user.ID
+	} else if !ac.localLoginAllowed(c) {
+		return
+	}
+
+	rp, err := ac.webauthnRP()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	options, session, err := ac.mfaService.BeginLogin(rp, userID)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	utils.Success(c, gin.H{"options": options, "session": session})
+}
+
+// WebAuthnLoginFinish 校验通行密钥断言并完成登录
+func (ac *AuthController) WebAuthnLoginFinish(c *gin.Context) {
+	var req struct {
+		OtpPendingToken string                              `json:"otp_pending_token"`
+		Session         string                              `json:"session" binding:"required"`
+		Credential      services.WebAuthnCredentialResponse `json:"credential" binding:"required"`
+		TrustDevice     bool                                `json:"trust_device"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	passwordless := req.OtpPendingToken == ""
+	if passwordless && !ac.localLoginAllowed(c) {
+		return
+	}
+	rp, err := ac.webauthnRP()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	cred, err := ac.mfaService.FinishLogin(rp, req.Session, &req.Credential)
+	if err != nil {
+		ac.publishLogin(c, "", "failed", "通行密钥验证失败: "+err.Error())
+		utils.Unauthorized(c, err.Error())
+		return
+	}
+
+	user, err := ac.userService.GetUserByID(cred.UserID)
+	if err != nil || user == nil {
+		utils.Unauthorized(c, "用户不存在")
+		return
+	}
+	if user.Disabled {
+		ac.publishLogin(c, user.Username, "failed", "账户已被禁用")
+		utils.Forbidden(c, "账户已被禁用")
+		return
+	}
+
+	// 第二因素场景下，通行密钥必须属于刚通过密码校验的用户
+	if !passwordless {
+		pending := ac.pendingUser(c, req.OtpPendingToken)
+		if pending == nil {
+			return
+		}
+		if pending.ID != user.ID {
+			utils.Unauthorized(c, "通行密钥不属于当前用户")
+			return
+		}
+		ac.trustDevice(c, user, req.TrustDevice)
+		ac.completeLogin(c, user, constant.LoginMethodWebAuthn, "通行密钥两步验证登录成功")
+		return
+	}
+	ac.completeLogin(c, user, constant.LoginMethodPasskey, "通行密钥登录成功")
+}
+
+// ListWebAuthnCredentials 当前用户已注册的通行密钥
+func (ac *AuthController) ListWebAuthnCredentials(c *gin.Context) {
+	utils.Success(c, ac.mfaService.ListCredentials(c.GetString("userID")))
+}
+
+// WebAuthnRegisterBegin 生成通行密钥注册挑战
+func (ac *AuthController) WebAuthnRegisterBegin(c *gin.Context) {
+	user, err := ac.userService.GetUserByID(c.GetString("userID"))
+	if err != nil {
+		utils.Unauthorized(c, "用户不存在")
+		return
+	}
+	rp, err := ac.webauthnRP()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	options, session, err := ac.mfaService.BeginRegistration(rp, user)
+	if err != nil {
+		utils.ServerError(c, "生成注册挑战失败")
+		return
+	}
+	utils.Success(c, gin.H{"options": options, "session": session})
+}
+
+// WebAuthnRegisterFinish 校验并保存通行密钥
+// 首次添加两步验证方式且还没有恢复码时一并生成
+func (ac *AuthController) WebAuthnRegisterFinish(c *gin.Context) {
+	userID := c.GetString("userID")
+	var req struct {
+		Session    string                              `json:"session" binding:"required"`
+		Name       string                              `json:"name"`
+		Credential services.WebAuthnCredentialResponse `json:"credential" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+
+	rp, err := ac.webauthnRP()
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	cred, err := ac.mfaService.FinishRegistration(rp, userID, req.Session, req.Name, &req.Credential)
+	if err != nil {
+		utils.BadRequest(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.webauthn_add", ResourceType: "user", ResourceID: userID,
+		After: gin.H{"name": cred.Name, "credential_id": cred.CredentialID}})
+
+	var codes []string
+	if ac.mfaService.RemainingRecoveryCodes(userID) == 0 {
+		codes, _ = ac.mfaService.GenerateRecoveryCodes(userID)
+	}
+	utils.Success(c, gin.H{"credential": cred, "recovery_codes": codes})
+}
+
+// DeleteWebAuthnCredential 删除通行密钥
+func (ac *AuthController) DeleteWebAuthnCredential(c *gin.Context) {
+	userID := c.GetString("userID")
+	if err := ac.mfaService.DeleteCredential(userID, c.Param("id")); err != nil {
+		utils.NotFound(c, err.Error())
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.webauthn_delete", ResourceType: "user", ResourceID: userID,
+		Before: gin.H{"id": c.Param("id")}})
+	utils.SuccessMsg(c, "删除成功")
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码立即失效
+func (ac *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetString("userID")
+	user, err := ac.userService.GetUserByID(userID)
+	if err != nil {
+		utils.Unauthorized(c, "用户不存在")
+		return
+	}
+	if !ac.mfaService.HasSecondFactor(user) {
+		utils.BadRequest(c, "请先开启两步验证或添加通行密钥")
+		return
+	}
+	codes, err := ac.mfaService.GenerateRecoveryCodes(userID)
+	if err != nil {
+		utils.ServerError(c, "生成恢复码失败")
+		return
+	}
+	recordAudit(c, services.AuditEntry{Action: "user.recovery_codes_regenerate", ResourceType: "user", ResourceID: userID})
+	utils.Success(c, gin.H{"recovery_codes": codes})
+}
+
+// RevokeTrustedDevices 取消所有受信任设备，下次登录重新进行两步验证
+func (ac *AuthController) RevokeTrustedDevices(c *gin.Context) {
+	userID := c.GetString("userID")
+	if err := ac.mfaService.RevokeTrustedDevices(userID); err != nil {
+		utils.ServerError(c, "操作失败")
+		return
+	}
+	c.SetCookie(constant.CookieTrustedDevice, "", -1, "/", "", false, true)
+	recordAudit(c, services.AuditEntry{Action: "user.trusted_devices_revoke", ResourceType: "user", ResourceID: userID})
+	utils.SuccessMsg(c, "已取消所有受信任设备")
+}
+
+// localLoginAllowed 无密码登录等同本地账号登录，关闭密码登录后同样仅允许本机应急登录
+func (ac *AuthController) localLoginAllowed(c *gin.Context) bool {
+	if ac.oidcService.PasswordLoginDisabled() && !services.GetAccessService().LocalBreakGlass(c.GetBool("localRequest")) {
+		utils.Forbidden(c, "已关闭本地账号登录，请使用 SSO 登录")
+		return false
+	}
+	return true
+}
+
+// webauthnRP 依赖方信息：RP ID 与 origin 均取自配置的面板对外地址，不信任请求中的 Host 等头部
+// 通行密钥与域名绑定，更换对外地址后需重新注册
+func (ac *AuthController) webauthnRP() (services.WebAuthnRP, error) {
+	public := ac.settingsService.PublicURL()
+	if public == "" || services.ValidatePublicURL(public) != nil {
+		return services.WebAuthnRP{}, errors.New("未配置面板对外地址，无法使用通行密钥，请在「系统设置 → 站点设置」中填写")
+	}
+	u, _ := url.Parse(public)
+	name := ac.settingsService.Get(constant.SectionSite, constant.KeyTitle)
+	if name == "" {
+		name = "白虎面板"
+	}
+	return services.WebAuthnRP{ID: u.Hostname(), Origin: u.Scheme + "://" + u.Host, Name: name}, nil
+}
@@ -30,6 +30,17 @@ var allModels = []interface{}{
 	&models.DataRelation{},
 	&models.DataStorage{},
 	&models.InterconnectNode{},
+	&models.ApiToken{},
+	&models.ApiTokenLog{},
+	&models.WebAuthnCredential{},
+	&models.UserRecoveryCode{},
+	&models.UserSession{},
+	&models.EnvVersion{},
+	&models.EnvUsage{},
+	&models.TaskLogTerm{},
+	&models.AuditLog{},
+	&models.LogForwarder{},
+	&models.GitOpsResource{},
 }
 
 func Migrate() error {
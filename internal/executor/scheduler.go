@@ -82,8 +82,9 @@ type ExecutionRequest struct {
 
 // ExecutionMetadata 执行额外元数据
 type ExecutionMetadata struct {
-	GoID       int64 // 关联的 goroutine ID
-	RetryIndex int   // 当前重试索引
+	GoID        int64  // 关联的 goroutine ID
+	RetryIndex  int    // 当前重试索引
+	ExecutionID string // 手动触发时预先分配的执行 ID，创建日志时作为日志 ID
 }
 
 // ExecutionResult 执行结果（标准接口）
@@ -0,0 +1,200 @@
+package logforward
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 日志级别，与应用日志的级别保持一致
+const (
+	LevelInfo    = "info"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+// Record 一条待转发的日志
+type Record struct {
+	Time    time.Time
+	Source  string // 来源：task / app_log
+	Level   string
+	Message string
+	Labels  map[string]string // 任务 ID、名称、Agent、状态等附加标签
+}
+
+// Sink 日志转发目标，Send 一次发送一批日志，返回错误时整批重试
+type Sink interface {
+	Send(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// Options 批量发送与重试参数
+type Options struct {
+	BatchSize     int           // 单批最多条数
+	FlushInterval time.Duration // 未攒满一批时的最长等待时间
+	QueueSize     int           // 内存队列容量，写满后丢弃新日志
+	MaxRetries    int           // 发送失败后的最大重试次数
+	RetryBackoff  time.Duration // 首次重试间隔，之后逐次翻倍
+	Timeout       time.Duration // 单次发送超时
+}
+
+// DefaultOptions 默认参数
+func DefaultOptions() Options {
+	return Options{
+		BatchSize:     200,
+		FlushInterval: 2 * time.Second,
+		QueueSize:     10000,
+		MaxRetries:    3,
+		RetryBackoff:  time.Second,
+		Timeout:       10 * time.Second,
+	}
+}
+
+// Forwarder 在后台协程中将日志攒批发送到 Sink，失败时按指数退避重试
+type Forwarder struct {
+	sink     Sink
+	opts     Options
+	queue    chan Record
+	stop     chan struct{}
+	done     chan struct{}
+	onResult func(sent int, err error)
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	dropped   int64
+}
+
+// New 创建并启动转发器，onResult 在每批发送结束（含重试）后回调，可为 nil
+func New(sink Sink, opts Options, onResult func(sent int, err error)) *Forwarder {
+	def := DefaultOptions()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = def.BatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = def.FlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = def.QueueSize
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = def.RetryBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = def.Timeout
+	}
+	f := &Forwarder{
+		sink:     sink,
+		opts:     opts,
+		queue:    make(chan Record, opts.QueueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		onResult: onResult,
+	}
+	go f.run()
+	return f
+}
+
+// Enqueue 将日志放入队列，不阻塞调用方；队列已满或转发器已关闭时丢弃并返回 false
+func (f *Forwarder) Enqueue(records ...Record) bool {
+	for i, r := range records {
+		select {
+		case <-f.stop:
+			f.addDropped(len(records) - i)
+			return false
+		default:
+		}
+		select {
+		case f.queue <- r:
+		default:
+			f.addDropped(len(records) - i)
+			return false
+		}
+	}
+	return true
+}
+
+// Dropped 返回因队列已满或转发器已关闭而丢弃的日志条数
+func (f *Forwarder) Dropped() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dropped
+}
+
+func (f *Forwarder) addDropped(n int) {
+	f.mu.Lock()
+	f.dropped += int64(n)
+	f.mu.Unlock()
+}
+
+// Close 发送队列中剩余的日志（含重试）后停止，并关闭 Sink；目标不可用时可能阻塞数秒
+func (f *Forwarder) Close() {
+	f.closeOnce.Do(func() {
+		close(f.stop)
+		<-f.done
+		f.sink.Close()
+	})
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, f.opts.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			f.send(batch)
+			batch = make([]Record, 0, f.opts.BatchSize)
+		}
+	}
+	for {
+		select {
+		case r := <-f.queue:
+			batch = append(batch, r)
+			if len(batch) >= f.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-f.stop:
+			// 排空队列中剩余的日志后退出
+			for {
+				select {
+				case r := <-f.queue:
+					batch = append(batch, r)
+					if len(batch) >= f.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (f *Forwarder) send(batch []Record) {
+	var err error
+	backoff := f.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), f.opts.Timeout)
+		err = f.sink.Send(ctx, batch)
+		cancel()
+		if err == nil || attempt >= f.opts.MaxRetries {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	f.report(len(batch), err)
+}
+
+func (f *Forwarder) report(n int, err error) {
+	if f.onResult != nil {
+		f.onResult(n, err)
+	}
+}
@@ -0,0 +1,186 @@
+package logforward
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	fails   int
+	calls   int
+	batches [][]Record
+}
+
+func (s *fakeSink) Send(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("unavailable")
+	}
+	s.batches = append(s.batches, append([]Record(nil), records...))
+	return nil
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func testRecord(msg string) Record {
+	return Record{Time: time.Date(2024, 5, 1, 8, 0, 0, 123000000, time.UTC), Source: "task", Level: LevelInfo, Message: msg,
+		Labels: map[string]string{"task_id": "t1", "task_name": "签到", "log_id": "l1"}}
+}
+
+func TestForwarder_BatchAndRetry(t *testing.T) {
+	sink := &fakeSink{fails: 2}
+	var results []error
+	var mu sync.Mutex
+	f := New(sink, Options{BatchSize: 2, FlushInterval: time.Hour, MaxRetries: 3, RetryBackoff: time.Millisecond}, func(sent int, err error) {
+		mu.Lock()
+		results = append(results, err)
+		mu.Unlock()
+	})
+	f.Enqueue(testRecord("a"), testRecord("b"), testRecord("c"))
+	f.Close()
+
+	if len(sink.batches) != 2 || len(sink.batches[0]) != 2 || len(sink.batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %+v", sink.batches)
+	}
+	if sink.calls != 4 {
+		t.Fatalf("expected 2 failed attempts plus 2 successful sends, got %d calls", sink.calls)
+	}
+	if len(results) != 2 || results[0] != nil || results[1] != nil {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	if f.Enqueue(testRecord("d")) || f.Dropped() != 1 {
+		t.Fatalf("enqueue after close should be dropped")
+	}
+}
+
+func TestForwarder_GiveUpAfterRetries(t *testing.T) {
+	sink := &fakeSink{fails: 10}
+	done := make(chan error, 1)
+	f := New(sink, Options{BatchSize: 1, MaxRetries: 2, RetryBackoff: time.Millisecond}, func(sent int, err error) { done <- err })
+	defer f.Close()
+	f.Enqueue(testRecord("a"))
+	if err := <-done; err == nil {
+		t.Fatal("expected error after retries exhausted")
+	}
+	if sink.calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", sink.calls)
+	}
+}
+
+func TestFormatRFC5424(t *testing.T) {
+	r := testRecord("hello world")
+	r.Level = LevelError
+	r.Labels["note"] = `a"b]c\`
+	got := FormatRFC5424(r, "host one")
+	want := `<131>1 2024-05-01T08:00:00.123Z hostone baihu - task [baihu@32473 log_id="l1" note="a\"b\]c\\" task_id="t1" task_name="签到"] hello world`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestSyslogSink_TCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var frames []string
+		for len(frames) < 2 {
+			var n int
+			var msg strings.Builder
+			size, _ := r.ReadString(' ')
+			for _, c := range strings.TrimSpace(size) {
+				n = n*10 + int(c-'0')
+			}
+			for i := 0; i < n; i++ {
+				b, _ := r.ReadByte()
+				msg.WriteByte(b)
+			}
+			frames = append(frames, msg.String())
+		}
+		received <- strings.Join(frames, "|")
+	}()
+
+	sink, err := NewSyslogSink("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if err := sink.Send(context.Background(), []Record{testRecord("第一行"), testRecord("second")}); err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	if !strings.HasSuffix(got, "] second") || !strings.Contains(got, "] 第一行|") {
+		t.Fatalf("unexpected frames: %s", got)
+	}
+}
+
+func TestLokiStreams(t *testing.T) {
+	a, b, c := testRecord("a"), testRecord("b"), testRecord("c")
+	b.Labels = map[string]string{"task_id": "t2", "log_id": "l2"}
+	c.Labels = map[string]string{"task_id": "t1", "task_name": "签到", "log_id": "l3"}
+	streams := lokiStreams([]Record{a, b, c})
+	if len(streams) != 2 {
+		t.Fatalf("expected 2 streams (log_id is not a stream label), got %d", len(streams))
+	}
+	if len(streams[0].Values) != 2 || streams[0].Values[1][1] != "c" || streams[0].Values[0][0] != "1714550400123000000" {
+		t.Fatalf("unexpected first stream: %+v", streams[0])
+	}
+	if streams[1].Stream["task_id"] != "t2" || streams[1].Stream["job"] != "baihu" || streams[1].Stream["source"] != "task" {
+		t.Fatalf("unexpected labels: %+v", streams[1].Stream)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var body, auth, contentType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := new(strings.Builder)
+		bufio.NewReader(r.Body).WriteTo(data)
+		body, auth, contentType = data.String(), r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	headers, err := ParseHeaders("Authorization: Bearer abc\n\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink, err := NewHTTPSink(srv.URL+"/ingest", headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), []Record{testRecord("<a>"), testRecord("b")}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer abc" || contentType != "application/x-ndjson" {
+		t.Fatalf("unexpected headers: %q %q", auth, contentType)
+	}
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	var first httpLine
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &first) != nil || first.Message != "<a>" || first.Labels["task_name"] != "签到" {
+		t.Fatalf("unexpected body: %s", body)
+	}
+	if _, err := ParseHeaders("no colon"); err == nil {
+		t.Fatal("expected header parse error")
+	}
+}
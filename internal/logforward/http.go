@@ -0,0 +1,108 @@
+package logforward
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPSink 以 JSON Lines 格式将日志 POST 到任意 HTTP 接收端，如 Vector、Fluent Bit、Logstash 的 http 输入
+type HTTPSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+// NewHTTPSink 创建通用 HTTP 目标，headers 为附加的请求头（如鉴权）
+func NewHTTPSink(rawURL string, headers http.Header) (*HTTPSink, error) {
+	u, err := parseHTTPURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPSink{url: u.String(), headers: headers, client: &http.Client{}}, nil
+}
+
+// httpLine JSON Lines 中的一行
+type httpLine struct {
+	Time    string            `json:"time"`
+	Source  string            `json:"source"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+func (s *HTTPSink) Send(ctx context.Context, records []Record) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for _, r := range records {
+		if err := enc.Encode(httpLine{
+			Time:    r.Time.Format(time.RFC3339Nano),
+			Source:  r.Source,
+			Level:   r.Level,
+			Message: r.Message,
+			Labels:  r.Labels,
+		}); err != nil {
+			return err
+		}
+	}
+	return post(ctx, s.client, s.url, s.headers, "application/x-ndjson", buf.Bytes())
+}
+
+func (s *HTTPSink) Close() error { return nil }
+
+// ParseHeaders 解析每行一个的「名称: 值」形式的请求头，忽略空行
+func ParseHeaders(text string) (http.Header, error) {
+	headers := http.Header{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("第 %d 行请求头格式错误，应为「名称: 值」", i+1)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("无效的地址: %s", rawURL)
+	}
+	return u, nil
+}
+
+func post(ctx context.Context, client *http.Client, target string, headers http.Header, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range headers {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("接收端返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,97 @@
+package logforward
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lokiPushPath 地址未包含路径时使用的默认推送路径
+const lokiPushPath = "/loki/api/v1/push"
+
+// lokiSkipLabels 取值过多的标签不作为 Loki 流标签，避免每次运行都产生新的流
+var lokiSkipLabels = map[string]bool{"log_id": true, "ref_id": true}
+
+// LokiSink 调用 Loki push API，相同标签的日志合并为一个流
+type LokiSink struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+// NewLokiSink 创建 Loki 目标，rawURL 可只填写服务地址，如 http://loki:3100
+func NewLokiSink(rawURL string, headers http.Header) (*LokiSink, error) {
+	u, err := parseHTTPURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = lokiPushPath
+	}
+	return &LokiSink{url: u.String(), headers: headers, client: &http.Client{}}, nil
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *LokiSink) Send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(map[string]any{"streams": lokiStreams(records)})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.client, s.url, s.headers, "application/json", body)
+}
+
+func (s *LokiSink) Close() error { return nil }
+
+// lokiStreams 按标签将日志分组为 Loki 流，流内按原有顺序排列
+func lokiStreams(records []Record) []lokiStream {
+	var streams []lokiStream
+	index := map[string]int{}
+	for _, r := range records {
+		labels := map[string]string{"job": syslogAppName, "source": r.Source, "level": r.Level}
+		for k, v := range r.Labels {
+			if v != "" && !lokiSkipLabels[k] {
+				labels[lokiLabelName(k)] = v
+			}
+		}
+		key := lokiStreamKey(labels)
+		i, ok := index[key]
+		if !ok {
+			i = len(streams)
+			index[key] = i
+			streams = append(streams, lokiStream{Stream: labels})
+		}
+		streams[i].Values = append(streams[i].Values, [2]string{strconv.FormatInt(r.Time.UnixNano(), 10), r.Message})
+	}
+	return streams
+}
+
+func lokiStreamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + strconv.Quote(labels[k]) + ",")
+	}
+	return b.String()
+}
+
+// lokiLabelName 标签名只能包含字母、数字和下划线，且不能以数字开头
+func lokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		if !(c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
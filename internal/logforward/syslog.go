@@ -0,0 +1,181 @@
+package logforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	syslogFacility = 16      // local0
+	syslogAppName  = "baihu" // APP-NAME
+	syslogSDID     = "baihu@32473"
+	syslogUDPMax   = 2048 // 单个 UDP 报文的最大字节数，超出时截断消息
+)
+
+// SyslogSink 按 RFC5424 格式发送 syslog，TCP 使用 RFC6587 的长度前缀分帧
+type SyslogSink struct {
+	network  string
+	address  string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink 创建 syslog 目标，network 为 udp 或 tcp
+func NewSyslogSink(network, address string) (*SyslogSink, error) {
+	network = strings.ToLower(strings.TrimSpace(network))
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, fmt.Errorf("不支持的 syslog 协议: %s", network)
+	}
+	address = strings.TrimSpace(address)
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return nil, fmt.Errorf("syslog 地址应为 host:port 格式: %s", address)
+	}
+	hostname, _ := os.Hostname()
+	return &SyslogSink{network: network, address: address, hostname: hostname}, nil
+}
+
+func (s *SyslogSink) Send(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+
+	var err error
+	if s.network == "tcp" {
+		var buf strings.Builder
+		for _, r := range records {
+			msg := FormatRFC5424(r, s.hostname)
+			buf.WriteString(strconv.Itoa(len(msg)))
+			buf.WriteByte(' ')
+			buf.WriteString(msg)
+		}
+		_, err = s.conn.Write([]byte(buf.String()))
+	} else {
+		for _, r := range records {
+			msg := truncateUTF8(FormatRFC5424(r, s.hostname), syslogUDPMax)
+			if _, err = s.conn.Write([]byte(msg)); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		// 连接可能已断开，下次发送时重新建立
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// FormatRFC5424 将日志格式化为一条 RFC5424 消息，标签写入结构化数据
+func FormatRFC5424(r Record, hostname string) string {
+	if hostname == "" {
+		hostname = "-"
+	}
+	msgID := r.Source
+	if msgID == "" {
+		msgID = "-"
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s - %s %s %s",
+		syslogFacility*8+syslogSeverity(r.Level),
+		r.Time.UTC().Format(time.RFC3339Nano),
+		syslogHeaderField(hostname, 255),
+		syslogAppName,
+		syslogHeaderField(msgID, 32),
+		structuredData(r.Labels),
+		r.Message,
+	)
+}
+
+func syslogSeverity(level string) int {
+	switch level {
+	case LevelError:
+		return 3
+	case LevelWarning:
+		return 4
+	default:
+		return 6
+	}
+}
+
+// syslogHeaderField 头部字段只能包含可打印 ASCII 且不含空格
+func syslogHeaderField(s string, limit int) string {
+	var b strings.Builder
+	for i := 0; i < len(s) && b.Len() < limit; i++ {
+		if c := s[i]; c > 32 && c < 127 {
+			b.WriteByte(c)
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+func structuredData(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("[" + syslogSDID)
+	for _, k := range keys {
+		name := syslogHeaderField(strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(k), 32)
+		b.WriteString(" " + name + `="`)
+		b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(labels[k]))
+		b.WriteByte('"')
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// truncateUTF8 按字节截断，不拆开多字节字符
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for i := 0; i < utf8.UTFMax && len(s) > 0; i++ {
+		if r, size := utf8.DecodeLastRuneInString(s); r != utf8.RuneError || size != 1 {
+			break
+		}
+		s = s[:len(s)-1]
+	}
+	return s
+}
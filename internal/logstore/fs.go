@@ -0,0 +1,75 @@
+package logstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// FSStore 本地文件存储，按内容地址的前两级分目录存放，避免单目录文件过多
+type FSStore struct {
+	root string
+}
+
+// NewFSStore 创建本地文件存储
+func NewFSStore(root string) *FSStore {
+	return &FSStore{root: filepath.Clean(root)}
+}
+
+func (s *FSStore) Name() string { return constant.LogStoreFS }
+
+// Root 返回存储根目录
+func (s *FSStore) Root() string { return s.root }
+
+func (s *FSStore) path(key string) string {
+	return filepath.Join(s.root, key[:2], key[2:4], key)
+}
+
+func (s *FSStore) Put(ctx context.Context, key string, data []byte) error {
+	target := s.path(key)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，保证读取方不会看到写了一半的内容
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (s *FSStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *FSStore) Delete(ctx context.Context, key string) error {
+	target := s.path(key)
+	if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// 顺带清理空的分片目录，非空时删除会失败并被忽略
+	dir := filepath.Dir(target)
+	if os.Remove(dir) == nil {
+		os.Remove(filepath.Dir(dir))
+	}
+	return nil
+}
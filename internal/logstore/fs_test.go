@@ -0,0 +1,60 @@
+package logstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFSStore(t *testing.T) {
+	s := NewFSStore(t.TempDir())
+	ctx := context.Background()
+	data := []byte("zstd:KLUv/QBYAQAAaGVsbG8=")
+	key := Key(data)
+
+	if err := s.Put(ctx, key, data); err != nil {
+		t.Fatal(err)
+	}
+	// 分片目录 ab/cd/<key>
+	if _, err := os.Stat(filepath.Join(s.Root(), key[:2], key[2:4], key)); err != nil {
+		t.Fatalf("blob not sharded: %v", err)
+	}
+	// 相同内容重复写入直接复用
+	if err := s.Put(ctx, key, data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(ctx, key)
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted blob should be not found, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(s.Root(), key[:2])); !os.IsNotExist(err) {
+		t.Error("empty shard directories should be removed")
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing blob should succeed, got %v", err)
+	}
+	if err := Probe(s); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseRef(t *testing.T) {
+	key := Key([]byte("x"))
+	if backend, k, err := ParseRef("fs:" + key); err != nil || backend != "fs" || k != key {
+		t.Errorf("ParseRef = %q %q %v", backend, k, err)
+	}
+	for _, ref := range []string{"", key, "fs:../../etc/passwd", "fs:" + key[:10], ":" + key} {
+		if _, _, err := ParseRef(ref); err == nil {
+			t.Errorf("ParseRef(%q) should fail", ref)
+		}
+	}
+}
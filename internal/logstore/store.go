@@ -0,0 +1,232 @@
+package logstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"gorm.io/gorm"
+)
+
+// opTimeout 单次读写存储后端的超时时间
+const opTimeout = 30 * time.Second
+
+// ErrNotFound 引用的正文在存储后端中不存在
+var ErrNotFound = errors.New("日志正文不存在")
+
+// Store 日志正文存储后端，正文按内容的 sha256 寻址，相同内容只存一份
+type Store interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	mu     sync.RWMutex
+	stores = map[string]Store{}
+	active Store
+)
+
+// Configure 替换已注册的存储后端，activeName 为新日志写入的后端，为空或未注册时正文保留在数据库
+// 未激活的后端仍用于读取和删除历史引用
+func Configure(activeName string, list ...Store) {
+	next := make(map[string]Store, len(list))
+	for _, s := range list {
+		next[s.Name()] = s
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	stores = next
+	active = next[activeName]
+}
+
+// Active 返回当前写入的存储后端，nil 表示正文保留在数据库
+func Active() Store {
+	mu.RLock()
+	defer mu.RUnlock()
+	return active
+}
+
+func lookup(name string) Store {
+	mu.RLock()
+	defer mu.RUnlock()
+	return stores[name]
+}
+
+// Key 计算正文的内容地址
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseRef 解析 backend:key 形式的引用
+func ParseRef(ref string) (backend, key string, err error) {
+	backend, key, ok := strings.Cut(ref, ":")
+	if !ok || backend == "" || !validKey(key) {
+		return "", "", fmt.Errorf("无效的日志引用: %s", ref)
+	}
+	return backend, key, nil
+}
+
+func validKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil && strings.ToLower(key) == key
+}
+
+// Probe 写入、读回并删除一段探测内容，用于保存配置前检查后端是否可用
+func Probe(s Store) error {
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	data := []byte("baihu log store probe " + time.Now().String())
+	key := Key(data)
+	if err := s.Put(ctx, key, data); err != nil {
+		return fmt.Errorf("写入失败: %w", err)
+	}
+	got, err := s.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("读取失败: %w", err)
+	}
+	if string(got) != string(data) {
+		return errors.New("读回的内容与写入不一致")
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		return fmt.Errorf("删除失败: %w", err)
+	}
+	return nil
+}
+
+// Put 将正文写入当前后端并返回引用
+func Put(data []byte) (string, error) {
+	s := Active()
+	if s == nil {
+		return "", errors.New("未启用日志存储")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	key := Key(data)
+	if err := s.Put(ctx, key, data); err != nil {
+		return "", err
+	}
+	return s.Name() + ":" + key, nil
+}
+
+// Load 按引用读取正文
+func Load(ref string) ([]byte, error) {
+	backend, key, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	s := lookup(backend)
+	if s == nil {
+		return nil, fmt.Errorf("日志存储后端 %s 未配置", backend)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+	defer cancel()
+	return s.Get(ctx, key)
+}
+
+// ReadCompressed 返回日志的压缩正文，正文可能内联在数据库中或位于存储后端
+func ReadCompressed(log *models.TaskLog) (string, error) {
+	if log.Output != "" || log.OutputRef == "" {
+		return string(log.Output), nil
+	}
+	data, err := Load(log.OutputRef)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// ReadOutput 返回解压后的日志正文
+func ReadOutput(log *models.TaskLog) (string, error) {
+	compressed, err := ReadCompressed(log)
+	if err != nil {
+		return "", err
+	}
+	return utils.DecompressFromBase64(compressed)
+}
+
+// Offload 将内联在数据库中的正文转存到当前后端，数据库只保留引用
+// 内存中的 log.Output 保持不变，供后续的通知、索引等流程继续使用
+func Offload(log *models.TaskLog) error {
+	if Active() == nil || log.ID == "" || log.Output == "" || log.OutputRef != "" {
+		return nil
+	}
+	ref, err := Put([]byte(log.Output))
+	if err != nil {
+		return err
+	}
+	err = database.DB.Model(&models.TaskLog{}).Where("id = ?", log.ID).
+		Updates(map[string]any{"output": models.BigText(""), "output_ref": ref}).Error
+	if err != nil {
+		return err
+	}
+	log.OutputRef = ref
+	return nil
+}
+
+// CollectRefs 在删除日志前收集即将被删除的正文引用，query 为删除时使用的查询条件
+func CollectRefs(query *gorm.DB) []string {
+	var refs []string
+	query.Session(&gorm.Session{}).Model(&models.TaskLog{}).Where("output_ref <> ''").Distinct().Pluck("output_ref", &refs)
+	return refs
+}
+
+// Release 删除不再被任何日志引用的正文，应在日志记录删除之后调用
+func Release(refs []string) {
+	for _, ref := range refs {
+		var count int64
+		database.DB.Model(&models.TaskLog{}).Where("output_ref = ?", ref).Count(&count)
+		if count > 0 {
+			continue
+		}
+		backend, key, err := ParseRef(ref)
+		if err != nil {
+			continue
+		}
+		s := lookup(backend)
+		if s == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), opTimeout)
+		if err := s.Delete(ctx, key); err != nil {
+			logger.Warnf("[LogStore] 删除日志正文失败 %s: %v", ref, err)
+		}
+		cancel()
+	}
+}
+
+// MigrateInline 将最多 limit 条已结束且正文仍内联在数据库中的日志转存到当前后端，返回转存条数
+func MigrateInline(limit int) (int, error) {
+	if Active() == nil {
+		return 0, nil
+	}
+	var logs []models.TaskLog
+	err := database.DB.Select("id", "output").
+		Where("(output_ref IS NULL OR output_ref = '') AND status <> ? AND output IS NOT NULL AND output <> ''", "running").
+		Order("id").Limit(limit).Find(&logs).Error
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for i := range logs {
+		if err := Offload(&logs[i]); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/services"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RealIP 解析真实客户端 IP 并写回 RemoteAddr，之后的 c.ClientIP() 均以此为准
+// 需注册在所有中间件之前；是否为本机直连记录在上下文的 localRequest 中，仅供本机应急登录与内部接口使用
+func RealIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip, local := services.GetAccessService().ClientIP(c.Request)
+		if _, port, err := net.SplitHostPort(c.Request.RemoteAddr); err == nil {
+			c.Request.RemoteAddr = net.JoinHostPort(ip, port)
+		}
+		c.Set("localRequest", local)
+		c.Next()
+	}
+}
+
+// AccessControl 访问控制中间件：依次校验入口的 IP 允许列表、封禁状态与限流
+// rateScope 为空时只做允许列表与封禁校验
+// 挂在 AuthRequired 之后时，互联 Token 认证的请求按互联入口校验
+// 本机直连的请求同样受允许列表、封禁与限流约束
+func AccessControl(surface, rateScope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		access := services.GetAccessService()
+		ip := c.ClientIP()
+
+		effective := surface
+		if surface == constant.AccessSurfaceUI && c.GetString("authMethod") == constant.AuthMethodInterconnect {
+			effective = constant.AccessSurfaceInterconnect
+		}
+		if !access.IPAllowed(effective, ip) {
+			utils.Forbidden(c, fmt.Sprintf("IP %s 不在允许访问的范围内", ip))
+			c.Abort()
+			return
+		}
+
+		if until, banned := access.BannedUntil(ip); banned {
+			c.Header("Retry-After", fmt.Sprintf("%d", int(time.Until(until).Seconds())+1))
+			utils.TooManyRequests(c, "请求过于频繁，IP 已被暂时封禁")
+			c.Abort()
+			return
+		}
+
+		if rateScope != "" && !access.Allow(rateScope, effective, ip) {
+			c.Header("Retry-After", "60")
+			utils.TooManyRequests(c, "请求过于频繁，请稍后再试")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -66,9 +66,7 @@ func AuthRequired() gin.HandlerFunc {
 					var adminUser models.User
 					res := database.DB.Where("role = ?", constant.AdminRole).Limit(1).Find(&adminUser)
 					if res.Error == nil && res.RowsAffected > 0 {
-						c.Set("userID", adminUser.ID)
-						c.Set("username", adminUser.Username)
-						c.Set("role", adminUser.Role)
+						setUserContext(c, &adminUser, constant.AuthMethodInterconnect)
 						c.Next()
 						return
 					}
@@ -84,7 +82,7 @@ func AuthRequired() gin.HandlerFunc {
 		}
 
 		// 验证 token
-		userID, username, tokenVersion, err := utils.ParseToken(token, constant.Secret)
+		claims, err := utils.ParseToken(token, constant.Secret)
 		if err != nil {
 			utils.Unauthorized(c, "登录已过期，请重新登录")
 			c.Abort()
@@ -93,28 +91,97 @@ func AuthRequired() gin.HandlerFunc {
 
 		// 安全增强：校验数据库中该用户的 ID 是否与 Token 一致，并验证 TokenVersion
 		var user models.User
-		res := database.DB.Where("username = ?", username).Limit(1).Find(&user)
-		if res.Error != nil || res.RowsAffected == 0 || user.ID != userID || user.TokenVersion != tokenVersion {
+		res := database.DB.Where("username = ?", claims.Username).Limit(1).Find(&user)
+		if res.Error != nil || res.RowsAffected == 0 || user.ID != claims.UserID || user.TokenVersion != claims.TokenVersion {
 			utils.Unauthorized(c, "会话失效，请重新登录")
 			ClearAuthCookie(c)
 			c.Abort()
 			return
 		}
+		if user.Disabled {
+			utils.Unauthorized(c, "账户已被禁用")
+			ClearAuthCookie(c)
+			c.Abort()
+			return
+		}
+
+		// 校验服务端会话，会话被吊销或空闲超时后凭证随即失效
+		if err := services.NewSessionService(services.NewSettingsService()).Validate(claims.SessionID, user.ID, c.ClientIP()); err != nil {
+			utils.Unauthorized(c, err.Error())
+			ClearAuthCookie(c)
+			c.Abort()
+			return
+		}
 
 		// 将用户信息存入上下文 (必须使用数据库中的最新 ID)
-		c.Set("userID", user.ID)
-		c.Set("username", user.Username)
-		c.Set("role", user.Role)
+		setUserContext(c, &user, constant.AuthMethodCookie)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
 }
 
+// setUserContext 将用户身份、访问范围及认证方式写入上下文
+func setUserContext(c *gin.Context, user *models.User, authMethod string) {
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", user.Role)
+	c.Set("scope", services.NewAccessScope(user.Role, user.Scopes))
+	c.Set("authMethod", authMethod)
+}
+
+// GetAuditActor 获取当前请求的操作者信息，用于写入审计日志
+func GetAuditActor(c *gin.Context) services.AuditActor {
+	actor := services.AuditActor{
+		UserID:     c.GetString("userID"),
+		Username:   c.GetString("username"),
+		AuthMethod: c.GetString("authMethod"),
+		IP:         c.ClientIP(),
+	}
+	if v, ok := c.Get("apiToken"); ok {
+		if token, ok := v.(*models.ApiToken); ok {
+			actor.TokenName = token.Name
+		}
+	}
+	return actor
+}
+
+// GetAccessScope 获取当前请求的访问范围，未经认证的内部调用返回 nil（不受限制）
+func GetAccessScope(c *gin.Context) *services.AccessScope {
+	if v, ok := c.Get("scope"); ok {
+		if scope, ok := v.(*services.AccessScope); ok {
+			return scope
+		}
+	}
+	return nil
+}
+
 // AdminRequired 管理员权限认证中间件
 func AdminRequired() gin.HandlerFunc {
+	return RoleRequired(constant.AdminRole)
+}
+
+// RoleRequired 角色权限中间件，要求当前用户至少具备指定角色
+func RoleRequired(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRole, exists := c.Get("role")
+		if !exists || !services.HasRole(userRole.(string), role) {
+			if role == constant.AdminRole {
+				utils.Forbidden(c, "需要管理员权限")
+			} else {
+				utils.Forbidden(c, "当前角色无权执行此操作")
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// UnscopedRequired 要求账户不受标签范围限制（用于脚本、文件等无法按标签隔离的全局资源）
+func UnscopedRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != constant.AdminRole {
-			utils.Forbidden(c, "需要管理员权限")
+		if GetAccessScope(c).Scoped() {
+			utils.Forbidden(c, "受标签范围限制的账户无权访问该资源")
 			c.Abort()
 			return
 		}
@@ -123,9 +190,14 @@ func AdminRequired() gin.HandlerFunc {
 }
 
 // OpenapiRequired OpenAPI 认证中间件
+// 优先校验命名令牌，其次兼容站点设置中的旧版全局令牌
 func OpenapiRequired() gin.HandlerFunc {
 	settingsSvc := services.NewSettingsService()
+	tokenSvc := services.NewApiTokenService()
 	return func(c *gin.Context) {
+		if checkApiToken(c, tokenSvc) {
+			return
+		}
 		if checkOpenapiToken(c, settingsSvc) {
 			return
 		}
@@ -134,24 +206,79 @@ func OpenapiRequired() gin.HandlerFunc {
 	}
 }
 
-// checkOpenapiToken 校验 OpenAPI Token
-// 返回 true 表示校验通过并已放行请求
-func checkOpenapiToken(c *gin.Context, settingsSvc *services.SettingsService) bool {
+// bearerToken 提取请求头中的 token：支持 "Bearer <token>" 和直接 "<token>" 两种格式
+func bearerToken(c *gin.Context) string {
 	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		// 标准格式：Bearer <token>
+		return authHeader[7:]
+	}
+	// 直接使用 token
+	return authHeader
+}
+
+// checkApiToken 校验命名令牌，以创建者身份访问，权限受令牌范围约束
+// 返回 true 表示已处理请求（放行或拒绝）
+func checkApiToken(c *gin.Context, tokenSvc *services.ApiTokenService) bool {
+	plain := bearerToken(c)
+	if !services.IsNamedApiToken(plain) {
 		return false
 	}
 
-	// 提取 token：支持 "Bearer <token>" 和直接 "<token>" 两种格式
-	var openapiToken string
-	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
-		// 标准格式：Bearer <token>
-		openapiToken = authHeader[7:]
-	} else {
-		// 直接使用 token
-		openapiToken = authHeader
+	token, err := tokenSvc.ValidateToken(plain)
+	if err != nil {
+		utils.Unauthorized(c, err.Error())
+		c.Abort()
+		return true
 	}
 
+	var user models.User
+	res := database.DB.Where("id = ?", token.CreatedBy).Limit(1).Find(&user)
+	if res.Error != nil || res.RowsAffected == 0 || user.Disabled {
+		utils.Unauthorized(c, "令牌创建者不存在或已被禁用")
+		c.Abort()
+		return true
+	}
+
+	// 令牌权限不超过创建者当前的角色与标签范围，两者取交集
+	scope, ok := services.NewTokenAccessScope(token).LimitTo(services.NewAccessScope(user.Role, user.Scopes))
+	if !ok {
+		utils.Forbidden(c, "令牌的标签范围已不在创建者当前的权限范围内")
+		c.Abort()
+		return true
+	}
+
+	c.Set("userID", user.ID)
+	c.Set("username", user.Username)
+	c.Set("role", scope.Role)
+	c.Set("scope", scope)
+	c.Set("apiToken", token)
+	c.Set("authMethod", constant.AuthMethodApiToken)
+	c.Next()
+
+	tokenSvc.RecordUsage(token.ID, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), c.ClientIP())
+	return true
+}
+
+// TokenScopeRequired 要求命名令牌具备指定权限范围，会话与旧版全局令牌不受影响
+func TokenScopeRequired(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if v, ok := c.Get("apiToken"); ok {
+			if token, ok := v.(*models.ApiToken); ok && !services.TokenHasScope(token.Scopes, scope) {
+				utils.Forbidden(c, "令牌缺少权限范围: "+scope)
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// checkOpenapiToken 校验 OpenAPI Token
+// 返回 true 表示校验通过并已放行请求
+func checkOpenapiToken(c *gin.Context, settingsSvc *services.SettingsService) bool {
+	openapiToken := bearerToken(c)
+
 	// Token 不能为空
 	if openapiToken == "" {
 		return false
@@ -204,9 +331,7 @@ func checkOpenapiToken(c *gin.Context, settingsSvc *services.SettingsService) bo
 		return true
 	}
 
-	c.Set("userID", adminUser.ID)
-	c.Set("username", adminUser.Username)
-	c.Set("role", adminUser.Role)
+	setUserContext(c, &adminUser, constant.AuthMethodOpenapi)
 	c.Next()
 	return true
 }
@@ -309,8 +434,7 @@ func SwaggerAuth() gin.HandlerFunc {
 // LocalhostOnly 仅允许本地回环地址访问，并进行简单的内部凭证校验
 func LocalhostOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		if ip != "127.0.0.1" && ip != "::1" {
+		if !c.GetBool("localRequest") {
 			utils.BadRequest(c, "仅允许本地访问")
 			c.Abort()
 			return
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// ApiToken 命名的 OpenAPI 访问令牌
+type ApiToken struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:20"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16"`                 // 令牌明文前缀，仅用于识别
+	TokenHash  string     `json:"-" gorm:"size:64;uniqueIndex;not null"` // 令牌 SHA-256 摘要，明文仅在创建时返回一次
+	Scopes     string     `json:"scopes" gorm:"size:500"`                // 权限范围，逗号分隔，如 tasks:read,tasks:run
+	Tags       string     `json:"tags" gorm:"size:500;default:''"`       // 可访问的任务标签，逗号分隔，为空表示不限制
+	TaskIDs    string     `json:"task_ids" gorm:"size:1000;default:''"`  // 可访问的任务 ID，逗号分隔，为空表示不限制
+	ExpiresAt  *LocalTime `json:"expires_at"`                            // 过期时间，null 表示永不过期
+	Enabled    *bool      `json:"enabled" gorm:"default:true"`           // 是否启用，吊销后为 false
+	LastUsedAt *LocalTime `json:"last_used_at"`                          // 最近使用时间
+	LastUsedIP string     `json:"last_used_ip" gorm:"size:64"`           // 最近使用 IP
+	UseCount   int        `json:"use_count" gorm:"default:0"`            // 累计调用次数
+	CreatedBy  string     `json:"created_by" gorm:"size:20;index"`       // 创建者用户 ID，令牌以该用户身份访问
+	CreatedAt  LocalTime  `json:"created_at"`
+	UpdatedAt  LocalTime  `json:"updated_at"`
+}
+
+func (ApiToken) TableName() string {
+	return constant.TablePrefix + "api_tokens"
+}
+
+// ApiTokenLog 令牌调用记录
+type ApiTokenLog struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:20"`
+	TokenID   string    `json:"token_id" gorm:"size:20;index"`
+	Method    string    `json:"method" gorm:"size:10"`
+	Path      string    `json:"path" gorm:"size:255"`
+	Status    int       `json:"status"`
+	IP        string    `json:"ip" gorm:"size:64"`
+	CreatedAt LocalTime `json:"created_at" gorm:"index"`
+}
+
+func (ApiTokenLog) TableName() string {
+	return constant.TablePrefix + "api_token_logs"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// AuditLog 审计日志，只追加写入，仅由保留策略清理
+type AuditLog struct {
+	ID           string    `json:"id" gorm:"primaryKey;size:20"`
+	UserID       string    `json:"user_id" gorm:"size:20;index"`
+	Username     string    `json:"username" gorm:"size:100;index"`
+	AuthMethod   string    `json:"auth_method" gorm:"size:20"` // cookie / openapi / api_token / interconnect
+	TokenName    string    `json:"token_name" gorm:"size:100"` // 使用命名令牌时的令牌名称
+	IP           string    `json:"ip" gorm:"size:64"`
+	Action       string    `json:"action" gorm:"size:50;index"` // 操作，如 task.update、terminal.open
+	ResourceType string    `json:"resource_type" gorm:"size:30;index"`
+	ResourceID   string    `json:"resource_id" gorm:"size:100;index"`
+	ResourceName string    `json:"resource_name" gorm:"size:255"`
+	Diff         BigText   `json:"diff"` // 变更前后差异（JSON，敏感字段已脱敏）
+	CreatedAt    LocalTime `json:"created_at" gorm:"index"`
+}
+
+func (AuditLog) TableName() string {
+	return constant.TablePrefix + "audit_logs"
+}
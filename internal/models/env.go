@@ -6,17 +6,20 @@ import (
 
 // EnvironmentVariable represents an environment variable
 type EnvironmentVariable struct {
-	ID        string    `json:"id" gorm:"primaryKey;size:20"`
-	Name      string    `json:"name" gorm:"size:255;not null"`
-	Value     BigText   `json:"value"`
-	Remark    string    `json:"remark" gorm:"size:500"`
-	Type      string    `json:"type" gorm:"size:20;default:'normal'"`
-	Hidden    *bool     `json:"hidden" gorm:"default:true"`
-	Enabled   *bool     `json:"enabled" gorm:"default:true"`
-	UserID    string    `json:"user_id" gorm:"size:20;index"`
-	Tags      string    `json:"-" gorm:"-"`
-	CreatedAt LocalTime `json:"created_at"`
-	UpdatedAt LocalTime `json:"updated_at"`
+	ID        string     `json:"id" gorm:"primaryKey;size:20"`
+	Name      string     `json:"name" gorm:"size:255;not null"`
+	Value     BigText    `json:"value"`
+	Remark    string     `json:"remark" gorm:"size:500"`
+	Type      string     `json:"type" gorm:"size:20;default:'normal'"`
+	Hidden    *bool      `json:"hidden" gorm:"default:true"`
+	Enabled   *bool      `json:"enabled" gorm:"default:true"`
+	UserID    string     `json:"user_id" gorm:"size:20;index"`
+	Tags      string     `json:"-" gorm:"-"`
+	ExpiresAt *LocalTime `json:"expires_at" gorm:"index"` // 过期时间，null 表示不过期，仅用于提醒
+	// ExpiryNotice 已发送的过期提醒阶段：空 / soon / expired，修改过期时间后重置
+	ExpiryNotice string    `json:"-" gorm:"size:20"`
+	CreatedAt    LocalTime `json:"created_at"`
+	UpdatedAt    LocalTime `json:"updated_at"`
 }
 
 func (EnvironmentVariable) TableName() string {
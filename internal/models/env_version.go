@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// EnvVersion 环境变量/机密的历史版本，每次创建、修改或回滚都会记录一份快照
+// 机密的 Value 与变量表一致保存为密文
+type EnvVersion struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:20"`
+	EnvID      string     `json:"env_id" gorm:"size:20;index;not null"`
+	Version    int        `json:"version"`
+	Action     string     `json:"action" gorm:"size:20"` // create / update / revert / bulk
+	Name       string     `json:"name" gorm:"size:255"`
+	Value      BigText    `json:"value"`
+	Remark     string     `json:"remark" gorm:"size:500"`
+	Type       string     `json:"type" gorm:"size:20"`
+	Hidden     *bool      `json:"hidden"`
+	Enabled    *bool      `json:"enabled"`
+	ExpiresAt  *LocalTime `json:"expires_at"`
+	RevertedTo int        `json:"reverted_to"` // 回滚时记录回滚到的版本号
+	UserID     string     `json:"user_id" gorm:"size:20"`
+	Username   string     `json:"username" gorm:"size:100"`
+	CreatedAt  LocalTime  `json:"created_at"`
+}
+
+func (EnvVersion) TableName() string {
+	return constant.TablePrefix + "env_versions"
+}
+
+// EnvUsage 机密最近一次被注入到某个任务的记录，每个机密与任务组合只保留一条
+type EnvUsage struct {
+	ID         string    `json:"id" gorm:"primaryKey;size:20"`
+	EnvID      string    `json:"env_id" gorm:"size:20;index;not null"`
+	TaskID     string    `json:"task_id" gorm:"size:20;index"`
+	TaskName   string    `json:"task_name" gorm:"size:255"`
+	Count      int64     `json:"count"`
+	LastUsedAt LocalTime `json:"last_used_at"`
+}
+
+func (EnvUsage) TableName() string {
+	return constant.TablePrefix + "env_usages"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// GitOpsResource 记录由声明式配置同步创建或接管的资源，用于检测漂移与清理
+type GitOpsResource struct {
+	ID         string    `json:"id" gorm:"primaryKey;size:20"`
+	Kind       string    `json:"kind" gorm:"size:20;not null;uniqueIndex:idx_gitops_kind_name"`  // 资源类型：constant.GitOpsKindTask / GitOpsKindEnv
+	Name       string    `json:"name" gorm:"size:255;not null;uniqueIndex:idx_gitops_kind_name"` // 仓库中声明的资源名称
+	ResourceID string    `json:"resource_id" gorm:"size:20;index"`                               // 面板中对应的资源 ID
+	SpecHash   string    `json:"spec_hash" gorm:"size:64"`                                       // 最近一次应用时的配置摘要
+	AppliedAt  LocalTime `json:"applied_at"`                                                     // 最近一次应用时间
+	CreatedAt  LocalTime `json:"created_at"`
+	UpdatedAt  LocalTime `json:"updated_at"`
+}
+
+func (GitOpsResource) TableName() string {
+	return constant.TablePrefix + "gitops_resources"
+}
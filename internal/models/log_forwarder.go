@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// LogForwarder 日志转发目标，将任务执行日志与应用日志推送到外部日志平台
+type LogForwarder struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:20"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	Type       string     `json:"type" gorm:"size:20;not null"`     // 目标类型：constant.LogForwardSyslog / LogForwardLoki / LogForwardHTTP
+	Address    string     `json:"address" gorm:"size:500;not null"` // syslog 为 host:port，Loki 与 HTTP 为接收地址
+	Protocol   string     `json:"protocol" gorm:"size:10"`          // syslog 传输协议：udp / tcp
+	Headers    BigText    `json:"headers"`                          // Loki 与 HTTP 附加的请求头，每行一个「名称: 值」
+	Labels     string     `json:"labels" gorm:"size:500"`           // 附加的固定标签，逗号分隔的 key=value，如 env=prod
+	Sources    string     `json:"sources" gorm:"size:100"`          // 转发来源，逗号分隔：task / app_log
+	Enabled    *bool      `json:"enabled" gorm:"default:true"`      // 是否启用
+	LastError  string     `json:"last_error" gorm:"size:500"`       // 最近一次发送失败的原因，成功后清空
+	LastSentAt *LocalTime `json:"last_sent_at"`                     // 最近一次成功发送时间
+	SentCount  int64      `json:"sent_count" gorm:"default:0"`      // 累计成功发送条数
+	CreatedAt  LocalTime  `json:"created_at"`
+	UpdatedAt  LocalTime  `json:"updated_at"`
+}
+
+func (LogForwarder) TableName() string {
+	return constant.TablePrefix + "log_forwarders"
+}
@@ -20,6 +20,19 @@ type NotifyBinding struct {
 type BindingExtra struct {
 	EnableLog bool `json:"enable_log"`
 	LogLimit  int  `json:"log_limit"` // 日志字数限制，默认 1000
+
+	// 日志推送方式：inline（默认）/ attach / link，见 constant.NotifyLogMode*
+	LogMode string `json:"log_mode,omitempty"`
+	// 随日志一并作为附件发送的产物文件，glob 语法，相对于任务工作目录
+	Artifacts []string `json:"artifacts,omitempty"`
+	// 分享链接有效期（小时），默认 24
+	LinkExpireHours int `json:"link_expire_hours,omitempty"`
+
+	// 绑定级覆盖模板（text/template 语法），为空时使用 notify 设置中的全局模板
+	TitleTemplate    string `json:"title_template,omitempty"`
+	TextTemplate     string `json:"text_template,omitempty"`
+	MarkdownTemplate string `json:"markdown_template,omitempty"`
+	HTMLTemplate     string `json:"html_template,omitempty"`
 }
 
 func (NotifyBinding) TableName() string {
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// UserSession 服务端登录会话，登录凭证中只携带会话 ID，吊销会话即可使对应浏览器退出
+type UserSession struct {
+	ID         string    `json:"id" gorm:"primaryKey;size:20"`
+	UserID     string    `json:"user_id" gorm:"size:20;index;not null"`
+	Method     string    `json:"method" gorm:"size:20"` // 登录方式：password / totp / recovery / webauthn / passkey / sso
+	IP         string    `json:"ip" gorm:"size:64"`
+	UserAgent  string    `json:"user_agent" gorm:"size:500"`
+	Device     string    `json:"device" gorm:"size:100"` // 由 User-Agent 解析出的浏览器与系统
+	LastSeenAt LocalTime `json:"last_seen_at"`
+	ExpiresAt  LocalTime `json:"expires_at" gorm:"index"`
+	CreatedAt  LocalTime `json:"created_at"`
+}
+
+func (UserSession) TableName() string {
+	return constant.TablePrefix + "user_sessions"
+}
@@ -181,10 +181,13 @@ type TaskLog struct {
 	TaskID    string     `json:"task_id" gorm:"size:20;index"`
 	AgentID   *string    `json:"agent_id" gorm:"size:20;index"` // Agent ID，为空表示本地执行
 	Command   BigText    `json:"command"`
-	Output    BigText    `json:"-"`                           // gzip+base64 压缩后的日志
-	Error     BigText    `json:"error"`                       // 额外的系统错误信息
-	Status    string     `json:"status" gorm:"size:20;index"` // success, failed
-	Duration  int64      `json:"duration"`                    // 执行耗时（毫秒）
+	Output    BigText    `json:"-"`                               // gzip+base64 压缩后的日志，已转存到日志存储时为空
+	OutputRef string     `json:"output_ref" gorm:"size:80;index"` // 日志存储中的正文引用，形如 fs:<sha256>
+	LineMeta  BigText    `json:"-"`                               // 每行的相对时间与来源（stdout/stderr/system），压缩后的紧凑帧
+	RunEnv    BigText    `json:"-"`                               // 运行环境快照 JSON（RunEnv），用于对比两次运行
+	Error     BigText    `json:"error"`                           // 额外的系统错误信息
+	Status    string     `json:"status" gorm:"size:20;index"`     // success, failed
+	Duration  int64      `json:"duration"`                        // 执行耗时（毫秒）
 	ExitCode  int        `json:"exit_code"`
 	StartTime *LocalTime `json:"start_time"`
 	EndTime   *LocalTime `json:"end_time"`
@@ -194,3 +197,17 @@ type TaskLog struct {
 func (TaskLog) TableName() string {
 	return constant.TablePrefix + "task_logs"
 }
+
+// RunEnv 一次运行实际使用的环境，只记录变量名，不记录变量值
+type RunEnv struct {
+	EnvNames  []string      `json:"env_names"`
+	Languages []RunLanguage `json:"languages"`
+	WorkDir   string        `json:"work_dir"`
+}
+
+// RunLanguage 运行时使用的语言版本，Resolved 为 latest 等版本别名解析后的实际版本
+type RunLanguage struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Resolved string `json:"resolved,omitempty"`
+}
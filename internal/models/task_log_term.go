@@ -0,0 +1,18 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// TaskLogTerm 任务日志输出的全文索引（倒排表），每条日志中出现的每个词记录一行
+// 冗余保存任务 ID 与日志时间，按任务或时间范围检索时无需关联日志表
+type TaskLogTerm struct {
+	Term      string    `json:"term" gorm:"primaryKey;size:64"`
+	LogID     string    `json:"log_id" gorm:"primaryKey;size:20;index"`
+	TaskID    string    `json:"task_id" gorm:"size:20;index"`
+	CreatedAt LocalTime `json:"created_at"`
+}
+
+func (TaskLogTerm) TableName() string {
+	return constant.TablePrefix + "task_log_terms"
+}
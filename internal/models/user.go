@@ -10,10 +10,14 @@ type User struct {
 	Username     string    `json:"username" gorm:"size:100;uniqueIndex;not null"`
 	Password     string    `json:"password" gorm:"size:255;not null"`
 	Email        string    `json:"email" gorm:"size:255"`
-	Role         string    `json:"role" gorm:"size:20;default:user"` // admin, user
-	TokenVersion int       `json:"-" gorm:"default:1"`               // 用于 JWT 失效校验
+	Role         string    `json:"role" gorm:"size:20;default:viewer"` // viewer, operator, editor, admin
+	Scopes       string    `json:"scopes" gorm:"size:500;default:''"`  // 可访问的任务标签，逗号分隔，为空表示不限制
+	Disabled     bool      `json:"disabled" gorm:"default:false"`
+	TokenVersion int       `json:"-" gorm:"default:1"` // 用于 JWT 失效校验
 	OtpSecret    string    `json:"-" gorm:"size:255"`
 	OtpEnabled   bool      `json:"otp_enabled" gorm:"default:false"`
+	OidcSubject  string    `json:"-" gorm:"size:255;index"` // 绑定的 SSO 身份，格式 issuer#sub
+	TrustVersion int       `json:"-" gorm:"default:1"`      // 受信任设备凭证版本，递增即撤销全部受信任设备
 	CreatedAt    LocalTime `json:"created_at"`
 	UpdatedAt    LocalTime `json:"updated_at"`
 }
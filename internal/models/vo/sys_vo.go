@@ -8,12 +8,16 @@ import (
 
 // UserVO 用户视图对象
 type UserVO struct {
-	ID        string           `json:"id"`
-	Username  string           `json:"username"`
-	Email     string           `json:"email"`
-	Role      string           `json:"role"`
-	CreatedAt models.LocalTime `json:"created_at"`
-	UpdatedAt models.LocalTime `json:"updated_at"`
+	ID         string           `json:"id"`
+	Username   string           `json:"username"`
+	Email      string           `json:"email"`
+	Role       string           `json:"role"`
+	Scopes     string           `json:"scopes"`
+	Disabled   bool             `json:"disabled"`
+	OtpEnabled bool             `json:"otp_enabled"`
+	SSO        bool             `json:"sso"`
+	CreatedAt  models.LocalTime `json:"created_at"`
+	UpdatedAt  models.LocalTime `json:"updated_at"`
 }
 
 // ToUserVO 将 User 模型转换为 UserVO
@@ -22,27 +26,32 @@ func ToUserVO(user *models.User) *UserVO {
 		return nil
 	}
 	return &UserVO{
-		ID:        user.ID,
-		Username:  user.Username,
-		Email:     user.Email,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
-		UpdatedAt: user.UpdatedAt,
+		ID:         user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		Role:       user.Role,
+		Scopes:     user.Scopes,
+		Disabled:   user.Disabled,
+		OtpEnabled: user.OtpEnabled,
+		SSO:        user.OidcSubject != "",
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
 	}
 }
 
 // EnvVO 环境变量视图对象
 type EnvVO struct {
-	ID        string           `json:"id"`
-	Name      string           `json:"name"`
-	Value     string           `json:"value"`
-	Remark    string           `json:"remark"`
-	Type      string           `json:"type"`
-	Tags      string           `json:"tags"`
-	Hidden    bool             `json:"hidden"`
-	Enabled   bool             `json:"enabled"`
-	CreatedAt models.LocalTime `json:"created_at"`
-	UpdatedAt models.LocalTime `json:"updated_at"`
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Value     string            `json:"value"`
+	Remark    string            `json:"remark"`
+	Type      string            `json:"type"`
+	Tags      string            `json:"tags"`
+	Hidden    bool              `json:"hidden"`
+	Enabled   bool              `json:"enabled"`
+	ExpiresAt *models.LocalTime `json:"expires_at"`
+	CreatedAt models.LocalTime  `json:"created_at"`
+	UpdatedAt models.LocalTime  `json:"updated_at"`
 }
 
 // ToEnvVO 将 Env 模型转换为 EnvVO
@@ -63,6 +72,7 @@ func ToEnvVO(env *models.EnvironmentVariable) *EnvVO {
 		Tags:      env.Tags,
 		Hidden:    utils.DerefBool(env.Hidden, true),
 		Enabled:   utils.DerefBool(env.Enabled, true),
+		ExpiresAt: env.ExpiresAt,
 		CreatedAt: env.CreatedAt,
 		UpdatedAt: env.UpdatedAt,
 	}
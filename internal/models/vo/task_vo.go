@@ -166,6 +166,7 @@ type TaskLogVO struct {
 	EndTime   *models.LocalTime `json:"end_time"`
 	CreatedAt models.LocalTime  `json:"created_at"`
 	Output    string            `json:"output,omitempty"`
+	Lines     []utils.LogLine   `json:"lines,omitempty"` // 带相对时间与来源的逐行日志，按需返回
 }
 
 // ToTaskLogVO 将 TaskLog 模型转换为 TaskLogVO
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+// WebAuthnCredential 用户注册的通行密钥 / 安全密钥
+type WebAuthnCredential struct {
+	ID           string     `json:"id" gorm:"primaryKey;size:20"`
+	UserID       string     `json:"user_id" gorm:"size:20;index;not null"`
+	Name         string     `json:"name" gorm:"size:100"`
+	CredentialID string     `json:"credential_id" gorm:"size:512;uniqueIndex;not null"` // base64url 编码的凭证 ID
+	PublicKey    string     `json:"-" gorm:"type:text;not null"`                        // base64url 编码的 COSE 公钥
+	SignCount    uint32     `json:"sign_count" gorm:"default:0"`
+	Transports   string     `json:"transports" gorm:"size:100"` // 逗号分隔，如 usb,nfc,internal
+	LastUsedAt   *LocalTime `json:"last_used_at"`
+	CreatedAt    LocalTime  `json:"created_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return constant.TablePrefix + "webauthn_credentials"
+}
+
+// UserRecoveryCode 两步验证的一次性恢复码
+type UserRecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey;size:20"`
+	UserID    string     `json:"user_id" gorm:"size:20;index;not null"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"` // 恢复码 SHA-256 摘要
+	UsedAt    *LocalTime `json:"used_at"`
+	CreatedAt LocalTime  `json:"created_at"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return constant.TablePrefix + "user_recovery_codes"
+}
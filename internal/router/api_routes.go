@@ -1,6 +1,7 @@
 package router
 
 import (
+	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
@@ -19,20 +20,37 @@ func initPublicAPIRoutes(api *gin.RouterGroup, c *Controllers) {
 
 	// Authentication routes (无需认证)
 	auth := api.Group("/auth")
+	auth.Use(middleware.AccessControl(constant.AccessSurfaceUI, constant.RateScopeLogin))
 	{
 		auth.POST("/login", c.Auth.Login)
 		auth.POST("/login/otp", c.Auth.VerifyOTP)
 		auth.POST("/logout", c.Auth.Logout)
+		auth.GET("/oidc/login", c.Auth.OIDCLogin)
+		auth.GET("/oidc/callback", c.Auth.OIDCCallback)
+		auth.POST("/webauthn/login/begin", c.Auth.WebAuthnLoginBegin)
+		auth.POST("/webauthn/login/finish", c.Auth.WebAuthnLoginFinish)
 		// auth.POST("/register", c.Auth.Register)
 	}
 
 	// 公开的站点设置（无需认证）
-	api.GET("/settings/public", c.Settings.GetPublicSiteSettings)
+	api.GET("/settings/public", middleware.AccessControl(constant.AccessSurfaceUI, ""), c.Settings.GetPublicSiteSettings)
 
 	// 隧道模式 (被控端反向连入，使用独立 Token 做 WebSocket 鉴权)
-	api.GET("/interconnect/tunnel", c.Interconnect.HandleTunnel)
+	interconnectAccess := middleware.AccessControl(constant.AccessSurfaceInterconnect, constant.RateScopeInterconnect)
+	api.GET("/interconnect/tunnel", interconnectAccess, c.Interconnect.HandleTunnel)
 	// 子节点主动上报监控数据 (无中间件鉴权，内部鉴权)
-	api.POST("/interconnect/report", c.Interconnect.ReportMonitorData)
+	api.POST("/interconnect/report", interconnectAccess, c.Interconnect.ReportMonitorData)
+
+	// 机器人指令回调 (由渠道配置中的 secret / verification token 鉴权，按 OpenAPI 入口做访问控制)
+	chatops := api.Group("/chatops")
+	chatops.Use(middleware.AccessControl(constant.AccessSurfaceOpenapi, constant.RateScopeOpenapi))
+	{
+		chatops.POST("/telegram/:id", c.ChatOps.TelegramWebhook)
+		chatops.POST("/feishu/:id", c.ChatOps.FeishuWebhook)
+	}
+
+	// 通知中的限时日志分享链接 (由签名参数鉴权)
+	api.GET("/share/logs/:id", c.Log.GetSharedLog)
 
 	// 内部使用的 API（仅限本地调用，无需 Bearer 认证）
 	internalAPI := api.Group("/internal")
@@ -41,12 +59,14 @@ func initPublicAPIRoutes(api *gin.RouterGroup, c *Controllers) {
 		internalAPI.POST("/tasks/sync-repo-status", c.Task.SyncRepoTasks)
 		internalAPI.POST("/tasks/execute/:id", c.Executor.ExecuteTask)
 		internalAPI.POST("/tasks/toggle/:id", c.Task.ToggleTask)
+		internalAPI.POST("/gitops/applied", c.GitOps.Applied)
 	}
 }
 
 func initAuthorizedAPIRoutes(api *gin.RouterGroup, c *Controllers) {
 	authorized := api.Group("")
-	authorized.Use(middleware.AuthRequired())
+	// 认证后再按入口校验允许列表，互联 Token 请求适用互联入口的规则
+	authorized.Use(middleware.AuthRequired(), middleware.AccessControl(constant.AccessSurfaceUI, ""))
 	{
 		// 获取当前用户 (普通用户即可访问)
 		authorized.GET("/auth/me", c.Auth.GetCurrentUser)
@@ -58,18 +78,47 @@ func initAuthorizedAPIRoutes(api *gin.RouterGroup, c *Controllers) {
 			otp.POST("/generate", c.Auth.GenerateOTP)
 			otp.POST("/enable", c.Auth.EnableOTP)
 			otp.POST("/disable", c.Auth.DisableOTP)
+			otp.POST("/recovery-codes", c.Auth.RegenerateRecoveryCodes)
+		}
+
+		// 通行密钥与受信任设备管理 (普通用户即可访问)
+		webauthn := authorized.Group("/auth/webauthn")
+		{
+			webauthn.GET("/credentials", c.Auth.ListWebAuthnCredentials)
+			webauthn.DELETE("/credentials/:id", c.Auth.DeleteWebAuthnCredential)
+			webauthn.POST("/register/begin", c.Auth.WebAuthnRegisterBegin)
+			webauthn.POST("/register/finish", c.Auth.WebAuthnRegisterFinish)
 		}
+		authorized.POST("/auth/trusted-devices/revoke", c.Auth.RevokeTrustedDevices)
+
+		// 当前用户的 SSO 身份绑定
+		authorized.GET("/auth/oidc/link", c.Auth.GetOIDCLink)
+		authorized.POST("/auth/oidc/link", c.Auth.OIDCLink)
+		authorized.DELETE("/auth/oidc/link", c.Auth.OIDCUnlink)
+
+		// 当前用户的登录会话管理
+		authorized.GET("/auth/sessions", c.Auth.ListSessions)
+		authorized.DELETE("/auth/sessions/:id", c.Auth.RevokeSession)
+		authorized.POST("/auth/sessions/revoke-others", c.Auth.RevokeOtherSessions)
+
+		// 修改自己的密码 (普通用户即可访问)
+		authorized.POST("/settings/password", c.Settings.ChangePassword)
+
+		// 以下接口按角色分级授权，任务标签范围由控制器校验
+		registerDashboardRoutes(authorized, c)
+		registerTaskRoutes(authorized, c)
+		registerEnvRoutes(authorized, c)
+		registerScriptRoutes(authorized, c)
+		registerFileRoutes(authorized, c)
+		registerLogRoutes(authorized, c)
+		registerSystemWSRoutes(authorized, c)
+		registerMonitorRoutes(authorized, c)
+		registerTagRoutes(authorized, c)
 
 		// 以下管理接口需要管理员权限
 		adminOnly := authorized.Group("")
 		adminOnly.Use(middleware.AdminRequired())
 		{
-			registerDashboardRoutes(adminOnly, c)
-			registerTaskRoutes(adminOnly, c)
-			registerEnvRoutes(adminOnly, c)
-			registerScriptRoutes(adminOnly, c)
-			registerFileRoutes(adminOnly, c)
-			registerLogRoutes(adminOnly, c)
 			registerTerminalRoutes(adminOnly, c)
 			registerSettingsRoutes(adminOnly, c)
 			registerDependencyRoutes(adminOnly, c)
@@ -77,18 +126,19 @@ func initAuthorizedAPIRoutes(api *gin.RouterGroup, c *Controllers) {
 			registerMiseRoutes(adminOnly, c)
 			registerNotificationRoutes(adminOnly, c)
 			registerAppLogRoutes(adminOnly, c)
-			registerSystemWSRoutes(adminOnly, c)
 			registerWebUIRoutes(adminOnly, c)
-			registerMonitorRoutes(adminOnly, c)
 			registerInterconnectRoutes(adminOnly, c)
 			registerSystemRoutes(adminOnly, c)
-			registerTagRoutes(adminOnly, c)
+			registerUserRoutes(adminOnly, c)
+			registerApiTokenRoutes(adminOnly, c)
+			registerAuditRoutes(adminOnly, c)
+			registerLogForwardRoutes(adminOnly, c)
 		}
 	}
 
 	// 通知发送 API（使用通知 Token 认证，供脚本调用）
 	notifyAPI := api.Group("/notify")
-	notifyAPI.Use(middleware.NotifyTokenAuth())
+	notifyAPI.Use(middleware.AccessControl(constant.AccessSurfaceOpenapi, constant.RateScopeNotify), middleware.NotifyTokenAuth())
 	{
 		notifyAPI.POST("/send", c.Notification.SendNotification)
 	}
@@ -102,46 +152,52 @@ func registerDashboardRoutes(g *gin.RouterGroup, c *Controllers) {
 }
 
 func registerTaskRoutes(g *gin.RouterGroup, c *Controllers) {
+	operator := middleware.RoleRequired(constant.RoleOperator)
+	editor := middleware.RoleRequired(constant.RoleEditor)
+
 	tasks := g.Group("/tasks")
 	{
-		tasks.POST("", c.Task.CreateTask)
+		tasks.POST("", editor, c.Task.CreateTask)
 		tasks.GET("", c.Task.GetTasks)
 		tasks.GET("/:id", c.Task.GetTask)
-		tasks.POST("/bulk_save", c.Task.BulkSaveTask)
-		tasks.PUT("/:id", c.Task.UpdateTask)
-		tasks.DELETE("/:id", c.Task.DeleteTask)
-		tasks.POST("/batch-delete", c.Task.BatchDeleteTasks)
-		tasks.DELETE("/batch-by-query", c.Task.BatchDeleteByQuery)
-		tasks.POST("/stop/:logID", c.Task.StopTask)
+		tasks.POST("/bulk_save", middleware.AdminRequired(), c.Task.BulkSaveTask)
+		tasks.PUT("/:id", editor, c.Task.UpdateTask)
+		tasks.DELETE("/:id", editor, c.Task.DeleteTask)
+		tasks.POST("/batch-delete", editor, c.Task.BatchDeleteTasks)
+		tasks.DELETE("/batch-by-query", editor, c.Task.BatchDeleteByQuery)
+		tasks.POST("/stop/:logID", operator, c.Task.StopTask)
 		tasks.GET("/tags", c.Task.GetTags)
 	}
 
 	execution := g.Group("/execute")
 	{
-		execution.POST("/task/:id", c.Executor.ExecuteTask)
-		execution.POST("/command", c.Executor.ExecuteCommand)
+		execution.POST("/task/:id", operator, c.Executor.ExecuteTask)
+		execution.POST("/command", middleware.AdminRequired(), c.Executor.ExecuteCommand)
 		execution.GET("/results", c.Executor.GetLastResults)
 	}
 }
 
 func registerEnvRoutes(g *gin.RouterGroup, c *Controllers) {
-	env := g.Group("/env")
+	env := g.Group("/env", middleware.RoleRequired(constant.RoleEditor))
 	{
 		env.GET("/secret-status", c.Env.GetSecretStatus)
 		env.GET("/tags", c.Env.GetTags)
 		env.POST("", c.Env.CreateEnvVar)
-		env.POST("/bulk_save", c.Env.BulkSaveEnv)
+		env.POST("/bulk_save", middleware.AdminRequired(), c.Env.BulkSaveEnv)
 		env.GET("", c.Env.GetEnvVars)
 		env.GET("/all", c.Env.GetAllEnvVars)
 		env.GET("/:id", c.Env.GetEnvVar)
 		env.GET("/:id/tasks", c.Env.GetAssociatedTasks)
+		env.GET("/:id/versions", c.Env.GetEnvVersions)
+		env.POST("/:id/versions/:versionId/revert", c.Env.RevertEnvVersion)
+		env.GET("/:id/usage", c.Env.GetEnvUsage)
 		env.PUT("/:id", c.Env.UpdateEnvVar)
 		env.DELETE("/:id", c.Env.DeleteEnvVar)
 	}
 }
 
 func registerScriptRoutes(g *gin.RouterGroup, c *Controllers) {
-	scripts := g.Group("/scripts")
+	scripts := g.Group("/scripts", middleware.RoleRequired(constant.RoleEditor), middleware.UnscopedRequired())
 	{
 		scripts.POST("", c.Script.CreateScript)
 		scripts.GET("", c.Script.GetScripts)
@@ -152,7 +208,7 @@ func registerScriptRoutes(g *gin.RouterGroup, c *Controllers) {
 }
 
 func registerFileRoutes(g *gin.RouterGroup, c *Controllers) {
-	files := g.Group("/files")
+	files := g.Group("/files", middleware.RoleRequired(constant.RoleEditor), middleware.UnscopedRequired())
 	{
 		files.GET("/tree", c.File.GetFileTree)
 		files.GET("/content", c.File.GetFileContent)
@@ -173,10 +229,13 @@ func registerLogRoutes(g *gin.RouterGroup, c *Controllers) {
 	logs := g.Group("/logs")
 	{
 		logs.GET("", c.Log.GetLogs)
-		logs.POST("/clear", c.Log.ClearLogs)
+		logs.POST("/clear", middleware.RoleRequired(constant.RoleEditor), c.Log.ClearLogs)
 		logs.GET("/sse", c.LogSSE.StreamLog)
+		logs.GET("/search", c.Log.SearchLogs)
+		logs.POST("/search/reindex", middleware.AdminRequired(), c.Log.ReindexLogs)
+		logs.GET("/diff", c.Log.DiffLogs)
 		logs.GET("/:id", c.Log.GetLogDetail)
-		logs.DELETE("/:id", c.Log.DeleteLog)
+		logs.DELETE("/:id", middleware.RoleRequired(constant.RoleEditor), c.Log.DeleteLog)
 	}
 }
 
@@ -189,7 +248,6 @@ func registerTerminalRoutes(g *gin.RouterGroup, c *Controllers) {
 func registerSettingsRoutes(g *gin.RouterGroup, c *Controllers) {
 	settings := g.Group("/settings")
 	{
-		settings.POST("/password", c.Settings.ChangePassword)
 		settings.GET("/site", c.Settings.GetSiteSettings)
 		settings.PUT("/site", c.Settings.UpdateSiteSettings)
 		settings.POST("/site/openapi-token/generate", c.Settings.GenerateOpenapiToken)
@@ -203,6 +261,17 @@ func registerSettingsRoutes(g *gin.RouterGroup, c *Controllers) {
 		settings.GET("/backup/status", c.Settings.GetBackupStatus)
 		settings.GET("/backup/download", c.Settings.DownloadBackup)
 		settings.POST("/restore", c.Settings.RestoreBackup)
+		settings.POST("/backup/run", c.Settings.RunBackupNow)
+		settings.POST("/backup/test", c.Settings.TestBackupTarget)
+		settings.GET("/backup/remote", c.Settings.ListRemoteBackups)
+		settings.POST("/backup/remote/restore", c.Settings.RestoreRemoteBackup)
+		settings.DELETE("/backup/remote/:name", c.Settings.DeleteRemoteBackup)
+		settings.POST("/oidc/test", c.Auth.TestOIDC)
+		settings.POST("/secret_backend/test", c.Settings.TestSecretRef)
+		settings.GET("/access/bans", c.Settings.GetBannedIPs)
+		settings.DELETE("/access/bans/:ip", c.Settings.UnbanIP)
+		settings.POST("/gitops/plan", c.GitOps.Plan)
+		settings.POST("/gitops/apply", c.GitOps.Apply)
 		// 通用设置接口
 		settings.GET("/:section", c.Settings.GetSectionSettings)
 		settings.PUT("/:section", c.Settings.UpdateSectionSettings)
@@ -280,6 +349,7 @@ func registerNotificationRoutes(g *gin.RouterGroup, c *Controllers) {
 		notify.POST("/bindings", c.Notification.SaveBinding)
 		notify.POST("/bindings/batch", c.Notification.BatchSaveBindings)
 		notify.DELETE("/bindings/:id", c.Notification.DeleteBinding)
+		notify.POST("/templates/preview", c.Notification.PreviewTemplate)
 	}
 }
 
@@ -307,6 +377,7 @@ func registerMonitorRoutes(g *gin.RouterGroup, c *Controllers) {
 func initAgentAPIRoutes(root *gin.RouterGroup, c *Controllers) {
 	// Agent API（供远程 Agent 调用，不使用 /v1 版本号）
 	agentAPI := root.Group("/api/agent")
+	agentAPI.Use(middleware.AccessControl(constant.AccessSurfaceAgent, constant.RateScopeAgent))
 	{
 		agentAPI.POST("/heartbeat", c.Agent.Heartbeat)
 		agentAPI.GET("/tasks", c.Agent.GetTasks)
@@ -354,12 +425,55 @@ func registerSystemRoutes(g *gin.RouterGroup, c *Controllers) {
 }
 
 func registerTagRoutes(g *gin.RouterGroup, c *Controllers) {
+	manage := []gin.HandlerFunc{middleware.RoleRequired(constant.RoleEditor), middleware.UnscopedRequired()}
 	tags := g.Group("/tags")
 	{
 		tags.GET("", c.Tag.GetTags)
-		tags.POST("", c.Tag.CreateTag)
-		tags.PUT("/:id", c.Tag.UpdateTag)
-		tags.DELETE("/:id", c.Tag.DeleteTag)
+		tags.POST("", append(manage, c.Tag.CreateTag)...)
+		tags.PUT("/:id", append(manage, c.Tag.UpdateTag)...)
+		tags.DELETE("/:id", append(manage, c.Tag.DeleteTag)...)
+	}
+}
+
+func registerUserRoutes(g *gin.RouterGroup, c *Controllers) {
+	users := g.Group("/users")
+	{
+		users.GET("", c.User.ListUsers)
+		users.POST("", c.User.CreateUser)
+		users.PUT("/:id", c.User.UpdateUser)
+		users.POST("/:id/reset-password", c.User.ResetPassword)
+		users.GET("/:id/sessions", c.User.ListUserSessions)
+		users.DELETE("/:id/sessions", c.User.RevokeUserSessions)
+	}
+}
+
+func registerApiTokenRoutes(g *gin.RouterGroup, c *Controllers) {
+	tokens := g.Group("/api-tokens")
+	{
+		tokens.GET("", c.ApiToken.ListTokens)
+		tokens.POST("", c.ApiToken.CreateToken)
+		tokens.PUT("/:id", c.ApiToken.UpdateToken)
+		tokens.DELETE("/:id", c.ApiToken.DeleteToken)
+		tokens.GET("/:id/usage", c.ApiToken.GetTokenUsage)
+	}
+}
+
+func registerAuditRoutes(g *gin.RouterGroup, c *Controllers) {
+	audit := g.Group("/audit-logs")
+	{
+		audit.GET("", c.Audit.GetAuditLogs)
+		audit.GET("/export", c.Audit.ExportAuditLogs)
+	}
+}
+
+func registerLogForwardRoutes(g *gin.RouterGroup, c *Controllers) {
+	forwarders := g.Group("/log-forwarders")
+	{
+		forwarders.GET("", c.LogForward.ListForwarders)
+		forwarders.POST("", c.LogForward.CreateForwarder)
+		forwarders.PUT("/:id", c.LogForward.UpdateForwarder)
+		forwarders.DELETE("/:id", c.LogForward.DeleteForwarder)
+		forwarders.POST("/:id/test", c.LogForward.TestForwarder)
 	}
 }
 
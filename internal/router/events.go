@@ -9,6 +9,7 @@ import (
 	// "github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/services"
 	"github.com/engigu/baihu-panel/internal/executor"
+	"github.com/engigu/baihu-panel/internal/services/tasks"
 )
 
 func setupEventHandlers(subscribers ...eventbus.Subscriber) {
@@ -26,3 +27,45 @@ func startAppLogCleanup(appLogSvc *services.AppLogService) {
 		appLogSvc.CleanUp()
 	})
 }
+
+func startApiTokenLogCleanup(apiTokenSvc *services.ApiTokenService) {
+	executor.GetSysCron().AddJobWithRun("@every 1h", func() {
+		apiTokenSvc.CleanUp()
+	})
+}
+
+func startAuditLogCleanup(auditSvc *services.AuditService) {
+	executor.GetSysCron().AddJobWithRun("@every 1h", func() {
+		auditSvc.CleanUp()
+	})
+}
+
+func startSessionCleanup(sessionSvc *services.SessionService) {
+	executor.GetSysCron().AddJobWithRun("@every 1h", func() {
+		sessionSvc.CleanUp()
+	})
+}
+
+func startEnvExpiryCheck(envSvc *services.EnvService) {
+	executor.GetSysCron().AddJobWithRun("@every 1h", func() {
+		envSvc.CheckEnvExpiry()
+	})
+}
+
+func startAccessCleanup(accessSvc *services.AccessService) {
+	executor.GetSysCron().AddJobWithRun("@every 10m", func() {
+		accessSvc.CleanUp()
+	})
+}
+
+func startLogIndexCleanup(logSearchSvc *tasks.LogSearchService) {
+	executor.GetSysCron().AddJobWithRun("@every 1h", func() {
+		logSearchSvc.CleanIndex("")
+	})
+}
+
+func startLogStoreMigration() {
+	executor.GetSysCron().AddJobWithRun("@every 10m", func() {
+		services.MigrateInlineLogs()
+	})
+}
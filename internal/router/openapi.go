@@ -1,16 +1,18 @@
 package router
 
 import (
+	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 // initOpenAPIV1Routes 初始化 OpenAPI v1 路由
-// 只注册有 @Tags OpenAPI 注释的接口
+// 只注册有 @Tags OpenAPI 注释的接口，角色要求与面板接口一致，标签范围由控制器校验
+// 命名令牌额外按权限范围（如 tasks:read）逐项校验
 func initOpenAPIV1Routes(root *gin.RouterGroup, c *Controllers) {
 	// OpenAPI v1 路由组 (使用 Bearer Token)
 	open := root.Group("/open2api/v1")
-	open.Use(middleware.OpenapiRequired())
+	open.Use(middleware.AccessControl(constant.AccessSurfaceOpenapi, constant.RateScopeOpenapi), middleware.OpenapiRequired())
 	{
 		// 任务相关接口
 		registerOpenAPITaskRoutes(open, c)
@@ -27,37 +29,44 @@ func initOpenAPIV1Routes(root *gin.RouterGroup, c *Controllers) {
 
 // registerOpenAPITaskRoutes 注册 OpenAPI 任务路由（只包含有 @Tags OpenAPI 注释的接口）
 func registerOpenAPITaskRoutes(g *gin.RouterGroup, c *Controllers) {
+	read := middleware.TokenScopeRequired(constant.TokenScopeTasksRead)
+	editor := middleware.RoleRequired(constant.RoleEditor)
+	write := middleware.TokenScopeRequired(constant.TokenScopeTasksWrite)
 	tasks := g.Group("/tasks")
 	{
-		tasks.POST("", c.Task.CreateTask)
-		tasks.GET("", c.Task.GetTasks)
-		tasks.GET("/:id", c.Task.GetTask)
-		tasks.PUT("/:id", c.Task.UpdateTask)
-		tasks.DELETE("/:id", c.Task.DeleteTask)
-		tasks.POST("/stop/:logID", c.Task.StopTask)
-		tasks.GET("/tags", c.Task.GetTags)
+		tasks.POST("", editor, write, c.Task.CreateTask)
+		tasks.GET("", read, c.Task.GetTasks)
+		tasks.GET("/:id", read, c.Task.GetTask)
+		tasks.PUT("/:id", editor, write, c.Task.UpdateTask)
+		tasks.DELETE("/:id", editor, write, c.Task.DeleteTask)
+		tasks.POST("/stop/:logID", middleware.RoleRequired(constant.RoleOperator), middleware.TokenScopeRequired(constant.TokenScopeTasksRun), c.Task.StopTask)
+		tasks.GET("/tags", read, c.Task.GetTags)
 	}
 }
 
 // registerOpenAPIEnvRoutes 注册 OpenAPI 环境变量路由（只包含有 @Tags OpenAPI 注释的接口）
 func registerOpenAPIEnvRoutes(g *gin.RouterGroup, c *Controllers) {
-	env := g.Group("/env")
+	read := middleware.TokenScopeRequired(constant.TokenScopeEnvRead)
+	write := middleware.TokenScopeRequired(constant.TokenScopeEnvWrite)
+	env := g.Group("/env", middleware.RoleRequired(constant.RoleEditor))
 	{
-		env.POST("", c.Env.CreateEnvVar)
-		env.GET("", c.Env.GetEnvVars)
-		env.GET("/all", c.Env.GetAllEnvVars)
-		env.GET("/:id", c.Env.GetEnvVar)
-		env.GET("/:id/tasks", c.Env.GetAssociatedTasks)
-		env.PUT("/:id", c.Env.UpdateEnvVar)
-		env.DELETE("/:id", c.Env.DeleteEnvVar)
+		env.POST("", write, c.Env.CreateEnvVar)
+		env.GET("", read, c.Env.GetEnvVars)
+		env.GET("/all", read, c.Env.GetAllEnvVars)
+		env.GET("/:id", read, c.Env.GetEnvVar)
+		env.GET("/:id/tasks", read, c.Env.GetAssociatedTasks)
+		env.PUT("/:id", write, c.Env.UpdateEnvVar)
+		env.DELETE("/:id", write, c.Env.DeleteEnvVar)
 	}
 }
 
 // registerOpenAPILogRoutes 注册 OpenAPI 日志路由（只包含有 @Tags OpenAPI 注释的接口）
 func registerOpenAPILogRoutes(g *gin.RouterGroup, c *Controllers) {
-	logs := g.Group("/logs")
+	logs := g.Group("/logs", middleware.TokenScopeRequired(constant.TokenScopeLogsRead))
 	{
 		logs.GET("", c.Log.GetLogs)
+		logs.GET("/search", c.Log.SearchLogs)
+		logs.GET("/diff", c.Log.DiffLogs)
 		logs.GET("/:id", c.Log.GetLogDetail)
 	}
 }
@@ -66,19 +75,21 @@ func registerOpenAPILogRoutes(g *gin.RouterGroup, c *Controllers) {
 func registerOpenAPIExecutorRoutes(g *gin.RouterGroup, c *Controllers) {
 	execution := g.Group("/execute")
 	{
-		execution.POST("/task/:id", c.Executor.ExecuteTask)
-		execution.GET("/results", c.Executor.GetLastResults)
+		execution.POST("/task/:id", middleware.RoleRequired(constant.RoleOperator), middleware.TokenScopeRequired(constant.TokenScopeTasksRun), c.Executor.ExecuteTask)
+		execution.GET("/results", middleware.TokenScopeRequired(constant.TokenScopeTasksRead), c.Executor.GetLastResults)
 	}
 }
 
 // registerOpenAPIScriptRoutes 注册 OpenAPI 脚本路由
 func registerOpenAPIScriptRoutes(g *gin.RouterGroup, c *Controllers) {
-	scripts := g.Group("/scripts")
+	read := middleware.TokenScopeRequired(constant.TokenScopeScriptsRead)
+	write := middleware.TokenScopeRequired(constant.TokenScopeScriptsWrite)
+	scripts := g.Group("/scripts", middleware.RoleRequired(constant.RoleEditor), middleware.UnscopedRequired())
 	{
-		scripts.POST("", c.Script.CreateScript)
-		scripts.GET("", c.Script.GetScripts)
-		scripts.GET("/:id", c.Script.GetScript)
-		scripts.PUT("/:id", c.Script.UpdateScript)
-		scripts.DELETE("/:id", c.Script.DeleteScript)
+		scripts.POST("", write, c.Script.CreateScript)
+		scripts.GET("", read, c.Script.GetScripts)
+		scripts.GET("/:id", read, c.Script.GetScript)
+		scripts.PUT("/:id", write, c.Script.UpdateScript)
+		scripts.DELETE("/:id", write, c.Script.DeleteScript)
 	}
 }
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/controllers"
+	"github.com/engigu/baihu-panel/internal/logger"
 	"github.com/engigu/baihu-panel/internal/services"
 	"github.com/engigu/baihu-panel/internal/services/tasks"
 )
@@ -18,6 +19,14 @@ func RegisterControllers() *Controllers {
 	initService := services.NewInitService(settingsService)
 	userService := initService.Initialize()
 
+	// 配置任务日志正文存储，需在执行任务前完成
+	if err := services.ApplyLogStoreSettings(); err != nil {
+		logger.Warnf("[LogStore] 日志存储配置无效，新日志将保留在数据库: %v", err)
+	}
+	if err := services.ApplyBackupSchedule(); err != nil {
+		logger.Warnf("[Backup] 定时备份配置无效，未启用: %v", err)
+	}
+
 	taskService := tasks.NewTaskService()
 	envService := services.NewEnvService()
 	scriptService := services.NewScriptService()
@@ -41,9 +50,34 @@ func RegisterControllers() *Controllers {
 	// 启动计划任务
 	executorService.StartCron()
 
+	// 声明式配置同步，应用后需要同步本机调度器
+	gitopsService := services.GetGitOpsService()
+	gitopsService.SetScheduler(executorService)
+	if err := services.ApplyGitOpsSchedule(); err != nil {
+		logger.Warnf("[GitOps] 定时同步配置无效，未启用: %v", err)
+	}
+
+	// 机器人指令（Telegram / 飞书）
+	chatOpsService := services.NewChatOpsService(executorService)
+	chatOpsService.Start()
+
 	// 初始化所有关注系统总线的服务
-	setupEventHandlers(appLogService, notifyService, loginLogService, systemWSManager)
+	accessService := services.GetAccessService()
+	logForwardService := services.NewLogForwardService()
+	logForwardService.Reload()
+	setupEventHandlers(appLogService, notifyService, loginLogService, systemWSManager, chatOpsService, accessService, logForwardService)
 	startAppLogCleanup(appLogService)
+	startAccessCleanup(accessService)
+
+	apiTokenService := services.NewApiTokenService()
+	startApiTokenLogCleanup(apiTokenService)
+	startAuditLogCleanup(services.NewAuditService())
+	sessionService := services.NewSessionService(settingsService)
+	startSessionCleanup(sessionService)
+	startEnvExpiryCheck(envService)
+	logSearchService := tasks.NewLogSearchService()
+	startLogIndexCleanup(logSearchService)
+	startLogStoreMigration()
 
 	taskController := controllers.NewTaskController(taskService, executorService)
 	envController := controllers.NewEnvController(envService)
@@ -51,13 +85,13 @@ func RegisterControllers() *Controllers {
 	// 初始化并返回控制器
 	return &Controllers{
 		Task:         taskController,
-		Auth:         controllers.NewAuthController(userService, settingsService, loginLogService),
+		Auth:         controllers.NewAuthController(userService, settingsService, loginLogService, services.NewOIDCService(settingsService, userService), services.NewMFAService(settingsService), sessionService),
 		Env:          envController,
 		Script:       controllers.NewScriptController(scriptService),
 		Executor:     controllers.NewExecutorController(executorService),
 		File:         controllers.NewFileController(constant.ScriptsWorkDir),
 		Dashboard:    controllers.NewDashboardController(executorService),
-		Log:          controllers.NewLogController(),
+		Log:          controllers.NewLogController(logSearchService),
 		LogSSE:       controllers.NewLogSSEController(),
 		Terminal:     controllers.NewTerminalController(envService),
 		Settings:     controllers.NewSettingsController(userService, loginLogService, executorService),
@@ -72,6 +106,12 @@ func RegisterControllers() *Controllers {
 		Interconnect: controllers.NewInterconnectController(interconnectService),
 		Data:         controllers.NewDataController(taskController, envController),
 		Tag:          controllers.NewTagController(services.NewTagService()),
+		ChatOps:      controllers.NewChatOpsController(chatOpsService),
+		User:         controllers.NewUserController(userService, sessionService),
+		ApiToken:     controllers.NewApiTokenController(apiTokenService),
+		Audit:        controllers.NewAuditController(),
+		LogForward:   controllers.NewLogForwardController(logForwardService),
+		GitOps:       controllers.NewGitOpsController(gitopsService),
 	}
 }
 
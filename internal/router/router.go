@@ -35,6 +35,12 @@ type Controllers struct {
 	Interconnect *controllers.InterconnectController
 	Data         *controllers.DataController
 	Tag          *controllers.TagController
+	ChatOps      *controllers.ChatOpsController
+	User         *controllers.UserController
+	ApiToken     *controllers.ApiTokenController
+	Audit        *controllers.AuditController
+	LogForward   *controllers.LogForwardController
+	GitOps       *controllers.GitOpsController
 }
 
 func Setup(c *Controllers) *gin.Engine {
@@ -42,6 +48,9 @@ func Setup(c *Controllers) *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.New()
+	// 不让 gin 直接采信转发头，真实 IP 由 RealIP 按访问控制中的受信代理解析
+	_ = router.SetTrustedProxies(nil)
+	router.Use(middleware.RealIP())
 	router.Use(middleware.GinLogger(), middleware.GinRecovery())
 	router.Use(middleware.TravelProxyMiddleware())
 
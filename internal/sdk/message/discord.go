@@ -0,0 +1,40 @@
+package message
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// discordMaxContent Discord 单条消息 content 的长度上限
+const discordMaxContent = 2000
+
+// Discord Webhook 推送
+type Discord struct {
+	Webhook   string
+	Username  string // 可选，覆盖 Webhook 默认显示名称
+	AvatarURL string // 可选，覆盖 Webhook 默认头像
+}
+
+func (d *Discord) Request(content string) ([]byte, error) {
+	if runes := []rune(content); len(runes) > discordMaxContent {
+		content = string(runes[:discordMaxContent-3]) + "..."
+	}
+	payload := map[string]interface{}{
+		"content": content,
+	}
+	if d.Username != "" {
+		payload["username"] = d.Username
+	}
+	if d.AvatarURL != "" {
+		payload["avatar_url"] = d.AvatarURL
+	}
+	// Webhook 成功时返回 204 No Content
+	return postJSON("POST", d.Webhook, payload, nil)
+}
@@ -2,6 +2,7 @@ package message
 
 import (
 	"fmt"
+	"io"
 
 	"gopkg.in/gomail.v2"
 )
@@ -36,7 +37,13 @@ func (e *EmailMessage) Init(host string, port int, account string, passwd string
 	e.GM = gomail.NewDialer(host, port, account, passwd)
 }
 
-func (e *EmailMessage) sendMessage(toEmail string, title string, content string, contentType string) string {
+// EmailAttachment 邮件附件
+type EmailAttachment struct {
+	Name    string
+	Content []byte
+}
+
+func (e *EmailMessage) sendMessage(toEmail string, title string, content string, contentType string, attachments ...EmailAttachment) string {
 	m := gomail.NewMessage()
 	if e.FromName != "" {
 		m.SetAddressHeader("From", e.Account, e.FromName)
@@ -46,6 +53,13 @@ func (e *EmailMessage) sendMessage(toEmail string, title string, content string,
 	m.SetHeader("To", toEmail)
 	m.SetHeader("Subject", title)
 	m.SetBody(contentType, content)
+	for _, a := range attachments {
+		data := a.Content
+		m.Attach(a.Name, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}))
+	}
 
 	if err := e.GM.DialAndSend(m); err != nil {
 		return fmt.Sprintf("邮件发送失败: %s", err)
@@ -53,10 +67,10 @@ func (e *EmailMessage) sendMessage(toEmail string, title string, content string,
 	return ""
 }
 
-func (e *EmailMessage) SendTextMessage(toEmail string, title string, content string) string {
-	return e.sendMessage(toEmail, title, content, "text/plain")
+func (e *EmailMessage) SendTextMessage(toEmail string, title string, content string, attachments ...EmailAttachment) string {
+	return e.sendMessage(toEmail, title, content, "text/plain", attachments...)
 }
 
-func (e *EmailMessage) SendHtmlMessage(toEmail string, title string, content string) string {
-	return e.sendMessage(toEmail, title, content, "text/html")
+func (e *EmailMessage) SendHtmlMessage(toEmail string, title string, content string, attachments ...EmailAttachment) string {
+	return e.sendMessage(toEmail, title, content, "text/html", attachments...)
 }
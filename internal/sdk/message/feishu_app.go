@@ -0,0 +1,174 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+type feishuAppResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+type feishuTenantTokenResponse struct {
+	Code              int    `json:"code"`
+	Msg               string `json:"msg"`
+	TenantAccessToken string `json:"tenant_access_token"`
+	Expire            int64  `json:"expire"`
+}
+
+type feishuTokenCache struct {
+	token     string
+	expiresAt time.Time
+}
+
+var (
+	feishuTokens   = map[string]feishuTokenCache{}
+	feishuTokensMu sync.Mutex
+)
+
+// FeishuApp 飞书自建应用机器人，通过开放平台 API 收发消息（与自定义机器人 Webhook 不同，可接收指令）
+type FeishuApp struct {
+	AppID     string
+	AppSecret string
+	ApiHost   string // 可选的自定义 API 地址，默认 https://open.feishu.cn
+}
+
+func (f *FeishuApp) apiHost() string {
+	if f.ApiHost != "" {
+		return strings.TrimSuffix(f.ApiHost, "/")
+	}
+	return "https://open.feishu.cn"
+}
+
+// getTenantAccessToken 获取 tenant_access_token，按应用缓存至过期前 5 分钟
+func (f *FeishuApp) getTenantAccessToken() (string, error) {
+	cacheKey := f.apiHost() + "|" + f.AppID
+	feishuTokensMu.Lock()
+	defer feishuTokensMu.Unlock()
+
+	if c, ok := feishuTokens[cacheKey]; ok && time.Now().Before(c.expiresAt) {
+		return c.token, nil
+	}
+
+	res, err := postJSON("POST", f.apiHost()+"/open-apis/auth/v3/tenant_access_token/internal", map[string]string{
+		"app_id":     f.AppID,
+		"app_secret": f.AppSecret,
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var r feishuTenantTokenResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return "", err
+	}
+	if r.Code != 0 {
+		return "", fmt.Errorf("feishu token error: %s", r.Msg)
+	}
+
+	feishuTokens[cacheKey] = feishuTokenCache{
+		token:     r.TenantAccessToken,
+		expiresAt: time.Now().Add(time.Duration(r.Expire)*time.Second - 5*time.Minute),
+	}
+	return r.TenantAccessToken, nil
+}
+
+func (f *FeishuApp) call(apiURL string, payload interface{}) ([]byte, error) {
+	token, err := f.getTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := postJSON("POST", apiURL, payload, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	if err != nil {
+		return res, err
+	}
+
+	var r feishuAppResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return res, err
+	}
+	if r.Code != 0 {
+		return res, fmt.Errorf("feishu api error: %s", r.Msg)
+	}
+	return res, nil
+}
+
+func feishuTextContent(text string) string {
+	data, _ := json.Marshal(map[string]string{"text": text})
+	return string(data)
+}
+
+// SendText 向会话发送文本消息
+func (f *FeishuApp) SendText(chatID, text string) ([]byte, error) {
+	return f.call(f.apiHost()+"/open-apis/im/v1/messages?receive_id_type=chat_id", map[string]string{
+		"receive_id": chatID,
+		"msg_type":   "text",
+		"content":    feishuTextContent(text),
+	})
+}
+
+// ReplyText 回复指定消息
+func (f *FeishuApp) ReplyText(messageID, text string) ([]byte, error) {
+	return f.call(fmt.Sprintf("%s/open-apis/im/v1/messages/%s/reply", f.apiHost(), messageID), map[string]string{
+		"msg_type": "text",
+		"content":  feishuTextContent(text),
+	})
+}
+
+type feishuUploadResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		FileKey string `json:"file_key"`
+	} `json:"data"`
+}
+
+// SendFile 上传文件并以文件消息发送到会话
+func (f *FeishuApp) SendFile(chatID, fileName string, data []byte) ([]byte, error) {
+	token, err := f.getTenantAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	res, err := postMultipart(f.apiHost()+"/open-apis/im/v1/files", map[string]string{
+		"file_type": "stream",
+		"file_name": fileName,
+	}, "file", fileName, data, map[string]string{
+		"Authorization": "Bearer " + token,
+	})
+	if err != nil {
+		return res, err
+	}
+
+	var r feishuUploadResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return res, err
+	}
+	if r.Code != 0 {
+		return res, fmt.Errorf("feishu upload error: %s", r.Msg)
+	}
+
+	content, _ := json.Marshal(map[string]string{"file_key": r.Data.FileKey})
+	return f.call(f.apiHost()+"/open-apis/im/v1/messages?receive_id_type=chat_id", map[string]string{
+		"receive_id": chatID,
+		"msg_type":   "file",
+		"content":    string(content),
+	})
+}
@@ -0,0 +1,109 @@
+package message
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// postJSON 以 JSON 格式提交请求，返回响应体；非 2xx 状态码视为错误
+func postJSON(method, apiURL string, payload interface{}, headers map[string]string) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, apiURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return doRequest(req)
+}
+
+// postForm 以表单格式提交请求，返回响应体；非 2xx 状态码视为错误
+func postForm(apiURL string, values url.Values) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, apiURL, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doRequest(req)
+}
+
+// uploadClient 文件上传使用更长的超时时间
+var uploadClient = &http.Client{
+	Timeout: 60 * time.Second,
+}
+
+// postMultipart 以 multipart/form-data 上传单个文件，fields 为附加的表单字段
+func postMultipart(apiURL string, fields map[string]string, fileField, fileName string, data []byte, headers map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, err
+		}
+	}
+	part, err := w.CreateFormFile(fileField, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, apiURL, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return doRequestWith(uploadClient, req)
+}
+
+func doRequest(req *http.Request) ([]byte, error) {
+	return doRequestWith(Client, req)
+}
+
+func doRequestWith(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return body, fmt.Errorf("http status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,52 @@
+package message
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// Matrix 通过 Client-Server API 向房间发送 m.room.message
+type Matrix struct {
+	Homeserver  string // 如 https://matrix.org
+	AccessToken string
+	RoomID      string // 如 !abcdef:matrix.org
+	MsgType     string // m.text（默认）或 m.notice
+}
+
+// Send 发送消息，formattedBody 不为空时附带 org.matrix.custom.html 格式内容
+func (m *Matrix) Send(body, formattedBody string) ([]byte, error) {
+	msgType := m.MsgType
+	if msgType == "" {
+		msgType = "m.text"
+	}
+	payload := map[string]interface{}{
+		"msgtype": msgType,
+		"body":    body,
+	}
+	if formattedBody != "" {
+		payload["format"] = "org.matrix.custom.html"
+		payload["formatted_body"] = formattedBody
+	}
+
+	txnID := fmt.Sprintf("baihu%d", time.Now().UnixNano())
+	apiURL := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		strings.TrimSuffix(m.Homeserver, "/"), url.PathEscape(m.RoomID), txnID)
+
+	return postJSON("PUT", apiURL, payload, map[string]string{
+		"Authorization": "Bearer " + m.AccessToken,
+	})
+}
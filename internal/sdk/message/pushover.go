@@ -0,0 +1,73 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+type pushoverResponse struct {
+	Status int      `json:"status"`
+	Errors []string `json:"errors"`
+}
+
+// Pushover 推送
+type Pushover struct {
+	Token    string // 应用 API Token
+	User     string // 用户或分组 Key
+	Device   string // 可选，指定设备
+	Priority string // 可选，-2 ~ 2
+	Sound    string // 可选
+	ApiHost  string // 可选的自定义 API 地址，默认 https://api.pushover.net
+}
+
+func (p *Pushover) Request(title, message string, isHTML bool) ([]byte, error) {
+	values := url.Values{}
+	values.Set("token", p.Token)
+	values.Set("user", p.User)
+	values.Set("title", title)
+	values.Set("message", message)
+	if isHTML {
+		values.Set("html", "1")
+	}
+	if p.Device != "" {
+		values.Set("device", p.Device)
+	}
+	if p.Priority != "" {
+		values.Set("priority", p.Priority)
+	}
+	if p.Sound != "" {
+		values.Set("sound", p.Sound)
+	}
+
+	apiHost := strings.TrimSuffix(p.ApiHost, "/")
+	if apiHost == "" {
+		apiHost = "https://api.pushover.net"
+	}
+	res, err := postForm(apiHost+"/1/messages.json", values)
+	if err != nil {
+		return res, err
+	}
+
+	var r pushoverResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return res, err
+	}
+	if r.Status != 1 {
+		return res, fmt.Errorf("pushover error: %s", strings.Join(r.Errors, "; "))
+	}
+	return res, nil
+}
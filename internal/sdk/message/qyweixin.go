@@ -117,6 +117,36 @@ func (t *QyWeiXin) SendMessageMarkdown(title, text string, at ...string) ([]byte
 	return resp, err
 }
 
+type qywxUploadResponse struct {
+	Code    int    `json:"errcode"`
+	Msg     string `json:"errmsg"`
+	MediaID string `json:"media_id"`
+}
+
+// SendFile 上传文件到群机器人临时素材并以文件消息发送
+func (t *QyWeiXin) SendFile(fileName string, data []byte) ([]byte, error) {
+	uploadURL := "https://qyapi.weixin.qq.com/cgi-bin/webhook/upload_media?type=file&key=" + t.AccessToken
+	res, err := postMultipart(uploadURL, nil, "media", fileName, data, nil)
+	if err != nil {
+		return res, err
+	}
+
+	var r qywxUploadResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return res, err
+	}
+	if r.Code != 0 {
+		return res, fmt.Errorf("upload media error: %s", r.Msg)
+	}
+
+	return t.Request(map[string]interface{}{
+		"msgtype": "file",
+		"file": map[string]interface{}{
+			"media_id": r.MediaID,
+		},
+	})
+}
+
 func (t *QyWeiXin) getURL() string {
 	url := "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=" + t.AccessToken
 	return url
@@ -0,0 +1,65 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+type serverChanResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var serverChan3KeyRegexp = regexp.MustCompile(`^sctp(\d+)t`)
+
+// ServerChan Server酱 推送，同时兼容 Turbo 版（SCT 开头）与 Server酱³（sctp 开头）的 SendKey
+type ServerChan struct {
+	SendKey string
+	ApiHost string // 可选的自定义 API 地址，配置后请求 {ApiHost}/{SendKey}.send
+}
+
+func (s *ServerChan) getURL() string {
+	if s.ApiHost != "" {
+		return fmt.Sprintf("%s/%s.send", strings.TrimSuffix(s.ApiHost, "/"), s.SendKey)
+	}
+	if m := serverChan3KeyRegexp.FindStringSubmatch(s.SendKey); m != nil {
+		return fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", m[1], s.SendKey)
+	}
+	return fmt.Sprintf("https://sctapi.ftqq.com/%s.send", s.SendKey)
+}
+
+// Request 发送消息，desp 支持 Markdown
+func (s *ServerChan) Request(title, desp string) ([]byte, error) {
+	values := url.Values{}
+	values.Set("title", title)
+	values.Set("desp", desp)
+
+	res, err := postForm(s.getURL(), values)
+	if err != nil {
+		return res, err
+	}
+
+	var r serverChanResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return res, err
+	}
+	if r.Code != 0 {
+		return res, fmt.Errorf("serverchan error: %s", r.Message)
+	}
+	return res, nil
+}
@@ -0,0 +1,73 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+type slackResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// Slack 支持 Incoming Webhook 与 Bot Token (chat.postMessage) 两种发送方式
+type Slack struct {
+	Webhook  string // Incoming Webhook 地址，配置后优先使用
+	BotToken string // xoxb- 开头的 Bot Token
+	Channel  string // Bot 模式下的目标频道 ID 或 #名称
+	ApiHost  string // 可选的自定义 API 地址，默认 https://slack.com/api
+}
+
+// Send 发送消息，mrkdwn 为 true 时按 Slack mrkdwn 语法渲染
+func (s *Slack) Send(text string, mrkdwn bool) ([]byte, error) {
+	payload := map[string]interface{}{
+		"text":   text,
+		"mrkdwn": mrkdwn,
+	}
+
+	if s.Webhook != "" {
+		res, err := postJSON("POST", s.Webhook, payload, nil)
+		if err != nil {
+			return res, err
+		}
+		// Incoming Webhook 成功时返回纯文本 ok
+		if body := strings.TrimSpace(string(res)); body != "" && body != "ok" {
+			return res, fmt.Errorf("slack webhook error: %s", body)
+		}
+		return res, nil
+	}
+
+	payload["channel"] = s.Channel
+	apiHost := strings.TrimSuffix(s.ApiHost, "/")
+	if apiHost == "" {
+		apiHost = "https://slack.com/api"
+	}
+	res, err := postJSON("POST", apiHost+"/chat.postMessage", payload, map[string]string{
+		"Authorization": "Bearer " + s.BotToken,
+	})
+	if err != nil {
+		return res, err
+	}
+
+	var r slackResponse
+	if err := json.Unmarshal(res, &r); err != nil {
+		return res, err
+	}
+	if !r.Ok {
+		return res, fmt.Errorf("slack api error: %s", r.Error)
+	}
+	return res, nil
+}
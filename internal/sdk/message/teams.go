@@ -0,0 +1,35 @@
+package message
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// Teams Microsoft Teams Incoming Webhook（MessageCard 格式，Workflows Webhook 同样兼容）
+type Teams struct {
+	Webhook    string
+	ThemeColor string
+}
+
+func (t *Teams) Request(title, text string) ([]byte, error) {
+	themeColor := t.ThemeColor
+	if themeColor == "" {
+		themeColor = "0076D7"
+	}
+	payload := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"themeColor": themeColor,
+		"summary":    title,
+		"title":      title,
+		"text":       text,
+	}
+	return postJSON("POST", t.Webhook, payload, nil)
+}
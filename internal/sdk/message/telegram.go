@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
@@ -117,11 +118,115 @@ func (t *Telegram) SendMessageHTML(text string) ([]byte, error) {
 }
 
 func (t *Telegram) getAPIURL() string {
+	return t.getMethodURL("sendMessage")
+}
+
+func (t *Telegram) getMethodURL(method string) string {
 	// 自定义 API 地址优先级最高
 	if t.ApiHost != "" {
-		return fmt.Sprintf("%s/bot%s/sendMessage", t.ApiHost, t.BotToken)
+		return fmt.Sprintf("%s/bot%s/%s", t.ApiHost, t.BotToken, method)
+	}
+	return fmt.Sprintf("https://api.telegram.org/bot%s/%s", t.BotToken, method)
+}
+
+// SendDocument 以文件形式发送内容（如完整日志），caption 为可选说明
+func (t *Telegram) SendDocument(fileName string, data []byte, caption string) ([]byte, error) {
+	fields := map[string]string{"chat_id": t.ChatID}
+	if caption != "" {
+		fields["caption"] = caption
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		_ = w.WriteField(k, v)
+	}
+	part, err := w.CreateFormFile("document", fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	client := t.getHTTPClient()
+	client.Timeout = 120 * time.Second
+	resp, err := client.Post(t.getMethodURL("sendDocument"), w.FormDataContentType(), &buf)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r telegramResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return body, err
+	}
+	if !r.Ok {
+		return body, fmt.Errorf("telegram api error: %s", r.Description)
+	}
+	return body, nil
+}
+
+// TelegramUpdate getUpdates / Webhook 推送的更新（仅包含指令处理所需字段）
+type TelegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *TelegramMessage `json:"message"`
+}
+
+type TelegramMessage struct {
+	MessageID int64  `json:"message_id"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+	From      *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"`
+	} `json:"chat"`
+}
+
+type telegramUpdatesResponse struct {
+	Ok          bool             `json:"ok"`
+	Description string           `json:"description"`
+	Result      []TelegramUpdate `json:"result"`
+}
+
+// GetUpdates 长轮询拉取更新，timeout 为服务端挂起的秒数，需小于 HTTP 客户端超时
+func (t *Telegram) GetUpdates(offset int64, timeout int) ([]TelegramUpdate, error) {
+	data := url.Values{}
+	data.Set("offset", fmt.Sprintf("%d", offset))
+	data.Set("timeout", fmt.Sprintf("%d", timeout))
+	data.Set("allowed_updates", `["message"]`)
+
+	resp, err := t.getHTTPClient().Post(t.getMethodURL("getUpdates"), "application/x-www-form-urlencoded", bytes.NewBufferString(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var r telegramUpdatesResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, err
+	}
+	if !r.Ok {
+		return nil, fmt.Errorf("telegram api error: %s", r.Description)
 	}
-	return fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.BotToken)
+	return r.Result, nil
 }
 
 // getHTTPClient 获取配置了代理的 HTTP 客户端
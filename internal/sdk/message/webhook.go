@@ -0,0 +1,52 @@
+package message
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// 通用 Webhook 的请求体格式
+const (
+	WebhookFormatJSON = "json"
+	WebhookFormatForm = "form"
+)
+
+// GenericWebhook 通用 Webhook，以固定字段 {title, body, type} 推送，兼容 Apprise 的 json:// 与 form:// 语义
+type GenericWebhook struct {
+	URL     string
+	Format  string            // json（默认）或 form
+	Method  string            // 仅 json 格式生效，默认 POST
+	Headers map[string]string // 仅 json 格式生效
+}
+
+func (w *GenericWebhook) Request(title, body, msgType string) ([]byte, error) {
+	if strings.ToLower(w.Format) == WebhookFormatForm {
+		values := url.Values{}
+		values.Set("title", title)
+		values.Set("body", body)
+		values.Set("type", msgType)
+		return postForm(w.URL, values)
+	}
+
+	method := strings.ToUpper(w.Method)
+	if method == "" {
+		method = "POST"
+	}
+	return postJSON(method, w.URL, map[string]string{
+		"title": title,
+		"body":  body,
+		"type":  msgType,
+	}, w.Headers)
+}
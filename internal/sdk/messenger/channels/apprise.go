@@ -0,0 +1,301 @@
+package channels
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// AppriseChannel 使用 Apprise 风格的 URL 描述一个或多个目标，逐个解析后转发给对应的内置渠道
+// 同一配置中可填写多个 URL（换行、空格或逗号分隔），全部发送成功才视为成功
+type AppriseChannel struct{ *BaseChannel }
+
+func NewAppriseChannel() Channel {
+	return &AppriseChannel{NewBaseChannel(ChannelApprise, []string{FormatTypeMarkdown, FormatTypeHTML, FormatTypeText})}
+}
+
+// appriseTargetFactories Apprise 可转发到的渠道，直接引用构造函数以避免依赖 messenger 的注册表
+var appriseTargetFactories = map[string]func() Channel{
+	ChannelSlack:      NewSlackChannel,
+	ChannelDiscord:    NewDiscordChannel,
+	ChannelTelegram:   NewTelegramChannel,
+	ChannelPushover:   NewPushoverChannel,
+	ChannelServerChan: NewServerChanChannel,
+	ChannelMatrix:     NewMatrixChannel,
+	ChannelTeams:      NewTeamsChannel,
+	ChannelGotify:     NewGotifyChannel,
+	ChannelNtfy:       NewNtfyChannel,
+	ChannelBark:       NewBarkChannel,
+	ChannelDtalk:      NewDtalkChannel,
+	ChannelFeishu:     NewFeishuChannel,
+	ChannelQyWeiXin:   NewQyWeiXinChannel,
+	ChannelWebhook:    NewWebhookChannel,
+}
+
+func (c *AppriseChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
+	urls := SplitAppriseURLs(config.GetString("urls"))
+	if len(urls) == 0 {
+		return SendError("apprise config missing: urls is required"), nil
+	}
+
+	var responses, errs []string
+	for _, raw := range urls {
+		channelType, targetConfig, err := ParseAppriseURL(raw)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		result, err := appriseTargetFactories[channelType]().Send(targetConfig, msg)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", channelType, err.Error()))
+			continue
+		}
+		if !result.Success {
+			errs = append(errs, fmt.Sprintf("%s: %s", channelType, result.Error))
+		}
+		if result.Response != "" {
+			responses = append(responses, result.Response)
+		}
+	}
+
+	if len(errs) > 0 {
+		return ErrorResultStr(strings.Join(responses, "\n"), strings.Join(errs, "; ")), nil
+	}
+	return SuccessResult(strings.Join(responses, "\n")), nil
+}
+
+// SplitAppriseURLs 拆分多个 Apprise URL
+func SplitAppriseURLs(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ' ' || r == '\t' || r == ','
+	})
+	urls := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			urls = append(urls, f)
+		}
+	}
+	return urls
+}
+
+// ParseAppriseURL 将 Apprise URL 解析为内置渠道类型及其配置
+// URL 中的查询参数会原样合并进渠道配置，可用于设置 priority、sound 等可选项
+func ParseAppriseURL(raw string) (string, ChannelConfig, error) {
+	raw = strings.TrimSpace(raw)
+	// Telegram Bot Token 形如 123:abc，会被 url.Parse 误判为端口，需单独解析
+	if rest, ok := strings.CutPrefix(raw, "tgram://"); ok {
+		return parseAppriseTelegram(raw, rest)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("无效的 Apprise URL: %s", err.Error())
+	}
+	scheme := strings.ToLower(u.Scheme)
+	// 以 s 结尾的协议（如 gotifys、ntfys）表示使用 https
+	secure := strings.HasSuffix(scheme, "s") && scheme != "msteams"
+	httpScheme := "http"
+	if secure {
+		httpScheme = "https"
+	}
+	segs := appriseSegments(u)
+	cfg := ChannelConfig{}
+
+	var channelType string
+	switch scheme {
+	case "slack":
+		channelType = ChannelSlack
+		if strings.HasPrefix(u.Host, "xox") {
+			// slack://xoxb-token/#channel
+			if len(segs) < 1 {
+				return "", nil, fmt.Errorf("slack URL 缺少频道: %s", raw)
+			}
+			cfg["bot_token"] = u.Host
+			cfg["channel"] = segs[0]
+		} else {
+			// slack://TokenA/TokenB/TokenC
+			if len(segs) < 2 {
+				return "", nil, fmt.Errorf("slack URL 格式应为 slack://TokenA/TokenB/TokenC: %s", raw)
+			}
+			cfg["webhook"] = fmt.Sprintf("https://hooks.slack.com/services/%s/%s/%s", u.Host, segs[0], segs[1])
+		}
+	case "discord":
+		// discord://WebhookID/WebhookToken
+		if len(segs) < 1 {
+			return "", nil, fmt.Errorf("discord URL 格式应为 discord://WebhookID/WebhookToken: %s", raw)
+		}
+		channelType = ChannelDiscord
+		cfg["webhook"] = fmt.Sprintf("https://discord.com/api/webhooks/%s/%s", u.Host, segs[0])
+	case "pover":
+		// pover://UserKey@AppToken/[Device]
+		if u.User == nil {
+			return "", nil, fmt.Errorf("pover URL 格式应为 pover://UserKey@AppToken: %s", raw)
+		}
+		channelType = ChannelPushover
+		cfg["user"] = u.User.Username()
+		cfg["token"] = u.Host
+		if len(segs) > 0 {
+			cfg["device"] = segs[0]
+		}
+	case "schan":
+		// schan://SendKey
+		channelType = ChannelServerChan
+		cfg["send_key"] = u.Host
+	case "matrix", "matrixs":
+		// matrixs://AccessToken@Host[:Port]/!RoomID:Server
+		if u.User == nil || len(segs) < 1 {
+			return "", nil, fmt.Errorf("matrix URL 格式应为 matrixs://AccessToken@Host/RoomID: %s", raw)
+		}
+		channelType = ChannelMatrix
+		cfg["access_token"] = u.User.Username()
+		cfg["homeserver"] = fmt.Sprintf("%s://%s", httpScheme, u.Host)
+		cfg["room_id"] = segs[0]
+	case "msteams":
+		channelType = ChannelTeams
+		switch len(segs) {
+		case 2:
+			// msteams://TokenA/TokenB/TokenC（旧版 outlook.office.com 地址）
+			cfg["webhook"] = fmt.Sprintf("https://outlook.office.com/webhook/%s/IncomingWebhook/%s/%s", u.Host, segs[0], segs[1])
+		case 3:
+			// msteams://Team/TokenA/TokenB/TokenC
+			cfg["webhook"] = fmt.Sprintf("https://%s.webhook.office.com/webhookb2/%s/IncomingWebhook/%s/%s", u.Host, segs[0], segs[1], segs[2])
+		default:
+			return "", nil, fmt.Errorf("msteams URL 格式应为 msteams://Team/TokenA/TokenB/TokenC: %s", raw)
+		}
+	case "gotify", "gotifys":
+		// gotifys://Host[/Path]/Token
+		if len(segs) < 1 {
+			return "", nil, fmt.Errorf("gotify URL 格式应为 gotifys://Host/Token: %s", raw)
+		}
+		channelType = ChannelGotify
+		cfg["url"] = fmt.Sprintf("%s://%s%s", httpScheme, u.Host, appriseJoinPath(segs[:len(segs)-1]))
+		cfg["token"] = segs[len(segs)-1]
+	case "ntfy", "ntfys":
+		// ntfy://Topic 使用 ntfy.sh；ntfys://[User:Pass@]Host/Topic 使用自建服务
+		channelType = ChannelNtfy
+		if len(segs) == 0 {
+			cfg["topic"] = u.Host
+		} else {
+			cfg["url"] = fmt.Sprintf("%s://%s", httpScheme, u.Host)
+			cfg["topic"] = segs[0]
+		}
+		if u.User != nil {
+			cfg["username"] = u.User.Username()
+			cfg["password"], _ = u.User.Password()
+		}
+	case "bark", "barks":
+		// barks://Host/DeviceKey
+		if len(segs) < 1 {
+			return "", nil, fmt.Errorf("bark URL 格式应为 barks://Host/DeviceKey: %s", raw)
+		}
+		channelType = ChannelBark
+		cfg["server"] = fmt.Sprintf("%s://%s", httpScheme, u.Host)
+		cfg["push_key"] = segs[0]
+	case "dingtalk":
+		// dingtalk://[Secret@]AccessToken
+		channelType = ChannelDtalk
+		cfg["access_token"] = u.Host
+		if u.User != nil {
+			cfg["secret"] = u.User.Username()
+		}
+	case "feishu":
+		// feishu://[Secret@]AccessToken
+		channelType = ChannelFeishu
+		cfg["access_token"] = u.Host
+		if u.User != nil {
+			cfg["secret"] = u.User.Username()
+		}
+	case "wecombot":
+		// wecombot://BotKey
+		channelType = ChannelQyWeiXin
+		cfg["access_token"] = u.Host
+	case "json", "jsons", "form", "forms":
+		// jsons://[User:Pass@]Host[:Port]/Path，查询参数中 +Key=Value 作为请求头
+		channelType = ChannelWebhook
+		target := url.URL{Scheme: httpScheme, Host: u.Host, Path: u.Path, User: u.User}
+		query := url.Values{}
+		headers := map[string]string{}
+		for k, vs := range u.Query() {
+			// 查询串中的 + 会被解码为空格
+			if strings.HasPrefix(k, "+") || strings.HasPrefix(k, " ") {
+				headers[k[1:]] = vs[0]
+			} else if k != "method" {
+				query[k] = vs
+			}
+		}
+		target.RawQuery = query.Encode()
+		cfg["url"] = target.String()
+		cfg["format"] = strings.TrimSuffix(scheme, "s")
+		cfg["method"] = u.Query().Get("method")
+		if len(headers) > 0 {
+			cfg["headers"] = appriseHeadersJSON(headers)
+		}
+		return channelType, cfg, nil
+	default:
+		return "", nil, fmt.Errorf("不支持的 Apprise URL 协议: %s", u.Scheme)
+	}
+
+	for k, vs := range u.Query() {
+		if _, exists := cfg[k]; !exists && len(vs) > 0 {
+			cfg[k] = vs[0]
+		}
+	}
+	return channelType, cfg, nil
+}
+
+// parseAppriseTelegram 解析 tgram://BotToken/ChatID[?query]
+func parseAppriseTelegram(raw, rest string) (string, ChannelConfig, error) {
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	botToken, chatID, _ := strings.Cut(strings.Trim(rest, "/"), "/")
+	if botToken == "" || chatID == "" {
+		return "", nil, fmt.Errorf("tgram URL 格式应为 tgram://BotToken/ChatID: %s", raw)
+	}
+	cfg := ChannelConfig{"bot_token": botToken, "chat_id": strings.Trim(chatID, "/")}
+	query, _ := url.ParseQuery(rawQuery)
+	for k, vs := range query {
+		if _, exists := cfg[k]; !exists && len(vs) > 0 {
+			cfg[k] = vs[0]
+		}
+	}
+	return ChannelTelegram, cfg, nil
+}
+
+// appriseSegments 返回 URL 路径中的非空分段（已解码）
+func appriseSegments(u *url.URL) []string {
+	var segs []string
+	for _, s := range strings.Split(u.Path, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	// Slack 频道、Matrix 房间别名中的 # 会被当作 fragment 解析
+	if u.Fragment != "" {
+		segs = append(segs, "#"+u.Fragment)
+	}
+	return segs
+}
+
+func appriseJoinPath(segs []string) string {
+	if len(segs) == 0 {
+		return ""
+	}
+	return "/" + strings.Join(segs, "/")
+}
+
+func appriseHeadersJSON(headers map[string]string) string {
+	data, _ := json.Marshal(headers)
+	return string(data)
+}
@@ -61,6 +61,18 @@ func (c *BaseChannel) FormatContent(msg *Message) (formatType string, content st
 	return FormatTypeText, ""
 }
 
+// AttachmentSender 支持发送附件的渠道实现此接口，未实现的渠道会忽略 Message.Attachments
+type AttachmentSender interface {
+	// SupportsAttachments 在当前配置下能否发送附件
+	SupportsAttachments(config ChannelConfig) bool
+}
+
+// SupportsAttachments 判断渠道在给定配置下能否发送附件
+func SupportsAttachments(ch Channel, config ChannelConfig) bool {
+	s, ok := ch.(AttachmentSender)
+	return ok && s.SupportsAttachments(config)
+}
+
 // SuccessResult 创建成功结果
 func SuccessResult(response string) *Result {
 	return &Result{Success: true, Response: response}
@@ -0,0 +1,225 @@
+package channels
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type capturedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// newStandIn 启动本地 HTTP 服务模拟第三方推送接口，记录收到的请求并返回固定响应
+func newStandIn(t *testing.T, status int, response string) (*httptest.Server, *[]capturedRequest) {
+	t.Helper()
+	var reqs []capturedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, capturedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: string(body)})
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestNewChannels_SendToStandIn(t *testing.T) {
+	msg := &Message{Title: "任务失败", Text: "exit 1", Markdown: "**exit 1**", HTML: "<b>exit 1</b>"}
+
+	cases := []struct {
+		name     string
+		channel  Channel
+		status   int
+		response string
+		config   func(base string) ChannelConfig
+		check    func(t *testing.T, req capturedRequest)
+	}{
+		{
+			name: "slack webhook", channel: NewSlackChannel(), status: 200, response: "ok",
+			config: func(base string) ChannelConfig { return ChannelConfig{"webhook": base + "/hook"} },
+			check: func(t *testing.T, req capturedRequest) {
+				if !strings.Contains(req.Body, `"mrkdwn":true`) || !strings.Contains(req.Body, "*任务失败*") {
+					t.Errorf("unexpected slack body: %s", req.Body)
+				}
+			},
+		},
+		{
+			name: "slack bot", channel: NewSlackChannel(), status: 200, response: `{"ok":true}`,
+			config: func(base string) ChannelConfig {
+				return ChannelConfig{"bot_token": "xoxb-1", "channel": "#ops", "api_host": base}
+			},
+			check: func(t *testing.T, req capturedRequest) {
+				if req.Path != "/chat.postMessage" || req.Header.Get("Authorization") != "Bearer xoxb-1" {
+					t.Errorf("unexpected slack bot request: %s %v", req.Path, req.Header)
+				}
+			},
+		},
+		{
+			name: "discord", channel: NewDiscordChannel(), status: 204,
+			config: func(base string) ChannelConfig { return ChannelConfig{"webhook": base + "/api/webhooks/1/t", "username": "baihu"} },
+			check: func(t *testing.T, req capturedRequest) {
+				if !strings.Contains(req.Body, `"username":"baihu"`) || !strings.Contains(req.Body, "**exit 1**") {
+					t.Errorf("unexpected discord body: %s", req.Body)
+				}
+			},
+		},
+		{
+			name: "matrix", channel: NewMatrixChannel(), status: 200, response: `{"event_id":"$1"}`,
+			config: func(base string) ChannelConfig {
+				return ChannelConfig{"homeserver": base, "access_token": "tk", "room_id": "!room:example.org"}
+			},
+			check: func(t *testing.T, req capturedRequest) {
+				if req.Method != http.MethodPut || !strings.HasPrefix(req.Path, "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/") {
+					t.Errorf("unexpected matrix request: %s %s", req.Method, req.Path)
+				}
+				if !strings.Contains(req.Body, "org.matrix.custom.html") {
+					t.Errorf("matrix should send html body: %s", req.Body)
+				}
+			},
+		},
+		{
+			name: "teams", channel: NewTeamsChannel(), status: 200, response: "1",
+			config: func(base string) ChannelConfig { return ChannelConfig{"webhook": base + "/webhookb2/x"} },
+			check: func(t *testing.T, req capturedRequest) {
+				if !strings.Contains(req.Body, `"@type":"MessageCard"`) {
+					t.Errorf("unexpected teams body: %s", req.Body)
+				}
+			},
+		},
+		{
+			name: "pushover", channel: NewPushoverChannel(), status: 200, response: `{"status":1}`,
+			config: func(base string) ChannelConfig {
+				return ChannelConfig{"token": "app", "user": "u1", "priority": "1", "api_host": base}
+			},
+			check: func(t *testing.T, req capturedRequest) {
+				form, _ := url.ParseQuery(req.Body)
+				if req.Path != "/1/messages.json" || form.Get("html") != "1" || form.Get("priority") != "1" {
+					t.Errorf("unexpected pushover request: %s %s", req.Path, req.Body)
+				}
+			},
+		},
+		{
+			name: "serverchan", channel: NewServerChanChannel(), status: 200, response: `{"code":0}`,
+			config: func(base string) ChannelConfig { return ChannelConfig{"send_key": "SCT1", "api_host": base} },
+			check: func(t *testing.T, req capturedRequest) {
+				form, _ := url.ParseQuery(req.Body)
+				if req.Path != "/SCT1.send" || form.Get("desp") != "**exit 1**" {
+					t.Errorf("unexpected serverchan request: %s %s", req.Path, req.Body)
+				}
+			},
+		},
+		{
+			name: "webhook json", channel: NewWebhookChannel(), status: 200,
+			config: func(base string) ChannelConfig {
+				return ChannelConfig{"url": base + "/notify", "headers": `{"X-Token":"abc"}`}
+			},
+			check: func(t *testing.T, req capturedRequest) {
+				var body map[string]string
+				_ = json.Unmarshal([]byte(req.Body), &body)
+				if body["title"] != "任务失败" || body["type"] != FormatTypeText || req.Header.Get("X-Token") != "abc" {
+					t.Errorf("unexpected webhook request: %v %s", req.Header, req.Body)
+				}
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, reqs := newStandIn(t, tc.status, tc.response)
+			res, err := tc.channel.Send(tc.config(srv.URL), msg)
+			if err != nil || !res.Success {
+				t.Fatalf("send failed: %v %+v", err, res)
+			}
+			if len(*reqs) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(*reqs))
+			}
+			tc.check(t, (*reqs)[0])
+		})
+	}
+}
+
+func TestNewChannels_ErrorResponse(t *testing.T) {
+	srv, _ := newStandIn(t, 200, `{"status":0,"errors":["user identifier is invalid"]}`)
+	res, _ := NewPushoverChannel().Send(ChannelConfig{"token": "a", "user": "b", "api_host": srv.URL}, &Message{Text: "x"})
+	if res.Success || !strings.Contains(res.Error, "user identifier is invalid") {
+		t.Errorf("expected pushover error, got %+v", res)
+	}
+
+	srv, _ = newStandIn(t, 404, "Unknown Webhook")
+	res, _ = NewDiscordChannel().Send(ChannelConfig{"webhook": srv.URL}, &Message{Text: "x"})
+	if res.Success {
+		t.Errorf("expected discord failure on 404, got %+v", res)
+	}
+}
+
+func TestParseAppriseURL(t *testing.T) {
+	cases := []struct {
+		raw  string
+		typ  string
+		want ChannelConfig
+	}{
+		{"slack://T1/B2/C3", ChannelSlack, ChannelConfig{"webhook": "https://hooks.slack.com/services/T1/B2/C3"}},
+		{"slack://xoxb-123/#ops", ChannelSlack, ChannelConfig{"bot_token": "xoxb-123", "channel": "#ops"}},
+		{"discord://111/tok", ChannelDiscord, ChannelConfig{"webhook": "https://discord.com/api/webhooks/111/tok"}},
+		{"tgram://123:abc/-100", ChannelTelegram, ChannelConfig{"bot_token": "123:abc", "chat_id": "-100"}},
+		{"pover://ukey@atoken?priority=2", ChannelPushover, ChannelConfig{"user": "ukey", "token": "atoken", "priority": "2"}},
+		{"schan://SCTkey", ChannelServerChan, ChannelConfig{"send_key": "SCTkey"}},
+		{"matrixs://tk@matrix.org/!r:matrix.org", ChannelMatrix, ChannelConfig{"access_token": "tk", "homeserver": "https://matrix.org", "room_id": "!r:matrix.org"}},
+		{"msteams://team/A/B/C", ChannelTeams, ChannelConfig{"webhook": "https://team.webhook.office.com/webhookb2/A/IncomingWebhook/B/C"}},
+		{"gotifys://push.example.com/sub/tok", ChannelGotify, ChannelConfig{"url": "https://push.example.com/sub", "token": "tok"}},
+		{"ntfy://alerts", ChannelNtfy, ChannelConfig{"topic": "alerts"}},
+		{"ntfys://u:p@ntfy.example.com/alerts", ChannelNtfy, ChannelConfig{"url": "https://ntfy.example.com", "topic": "alerts", "username": "u", "password": "p"}},
+		{"barks://api.day.app/key", ChannelBark, ChannelConfig{"server": "https://api.day.app", "push_key": "key"}},
+		{"jsons://example.com/hook?+X-Token=abc", ChannelWebhook, ChannelConfig{"url": "https://example.com/hook", "format": "json", "method": "", "headers": `{"X-Token":"abc"}`}},
+	}
+
+	for _, tc := range cases {
+		typ, cfg, err := ParseAppriseURL(tc.raw)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.raw, err)
+			continue
+		}
+		if typ != tc.typ {
+			t.Errorf("%s: type = %s, want %s", tc.raw, typ, tc.typ)
+		}
+		if len(cfg) != len(tc.want) {
+			t.Errorf("%s: config = %v, want %v", tc.raw, cfg, tc.want)
+			continue
+		}
+		for k, v := range tc.want {
+			if cfg[k] != v {
+				t.Errorf("%s: config[%s] = %q, want %q", tc.raw, k, cfg[k], v)
+			}
+		}
+	}
+
+	if _, _, err := ParseAppriseURL("mailto://user@example.com"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestAppriseChannel_MultipleTargets(t *testing.T) {
+	srv, reqs := newStandIn(t, 200, "")
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	res, err := NewAppriseChannel().Send(ChannelConfig{
+		"urls": "json://" + host + "/a\nform://" + host + "/b",
+	}, &Message{Title: "t", Text: "x"})
+	if err != nil || !res.Success {
+		t.Fatalf("send failed: %v %+v", err, res)
+	}
+	if len(*reqs) != 2 || (*reqs)[0].Path != "/a" || (*reqs)[1].Path != "/b" {
+		t.Fatalf("unexpected requests: %+v", *reqs)
+	}
+	if ct := (*reqs)[1].Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+		t.Errorf("form target content type = %s", ct)
+	}
+}
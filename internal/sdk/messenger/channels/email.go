@@ -24,6 +24,8 @@ func NewEmailChannel() Channel {
 	return &EmailChannel{NewBaseChannel(ChannelEmail, []string{FormatTypeHTML, FormatTypeText})}
 }
 
+func (c *EmailChannel) SupportsAttachments(config ChannelConfig) bool { return true }
+
 func (c *EmailChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
 	server := config.GetString("server")
 	portStr := config.GetString("port")
@@ -45,12 +47,17 @@ func (c *EmailChannel) Send(config ChannelConfig, msg *Message) (*Result, error)
 	var emailer message.EmailMessage
 	emailer.Init(server, port, account, passwd, fromName)
 
+	attachments := make([]message.EmailAttachment, 0, len(msg.Attachments))
+	for _, a := range msg.Attachments {
+		attachments = append(attachments, message.EmailAttachment{Name: a.Name, Content: a.Content})
+	}
+
 	var errMsg string
 	switch contentType {
 	case FormatTypeText:
-		errMsg = emailer.SendTextMessage(toAccount, msg.Title, formattedContent)
+		errMsg = emailer.SendTextMessage(toAccount, msg.Title, formattedContent, attachments...)
 	case FormatTypeHTML:
-		errMsg = emailer.SendHtmlMessage(toAccount, msg.Title, formattedContent)
+		errMsg = emailer.SendHtmlMessage(toAccount, msg.Title, formattedContent, attachments...)
 	default:
 		errMsg = fmt.Sprintf("未知的邮件发送内容类型：%s", contentType)
 	}
@@ -1,6 +1,10 @@
 package channels
 
-import "github.com/engigu/baihu-panel/internal/sdk/message"
+import (
+	"fmt"
+
+	"github.com/engigu/baihu-panel/internal/sdk/message"
+)
 
 // Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
 // Use of this source code is governed by the Apache License 2.0.
@@ -20,6 +24,11 @@ func NewFeishuChannel() Channel {
 	return &FeishuChannel{NewBaseChannel(ChannelFeishu, []string{FormatTypeMarkdown, FormatTypeText})}
 }
 
+// SupportsAttachments 需配置自建应用的 app_id / app_secret 及接收附件的 chat_id
+func (c *FeishuChannel) SupportsAttachments(config ChannelConfig) bool {
+	return config.GetString("app_id") != "" && config.GetString("app_secret") != "" && config.GetString("chat_id") != ""
+}
+
 func (c *FeishuChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
 	accessToken := config.GetString("access_token")
 	secret := config.GetString("secret")
@@ -52,5 +61,19 @@ func (c *FeishuChannel) Send(config ChannelConfig, msg *Message) (*Result, error
 	if err != nil {
 		return ErrorResult(string(res), err), nil
 	}
+
+	// 自定义机器人不支持文件消息，附件通过自建应用发送到 chat_id 指定的会话
+	if msg.HasAttachments() && c.SupportsAttachments(config) {
+		app := message.FeishuApp{
+			AppID:     config.GetString("app_id"),
+			AppSecret: config.GetString("app_secret"),
+			ApiHost:   config.GetString("api_host"),
+		}
+		for _, a := range msg.Attachments {
+			if fileRes, err := app.SendFile(config.GetString("chat_id"), a.Name, a.Content); err != nil {
+				return ErrorResult(string(fileRes), fmt.Errorf("发送附件 %s 失败: %w", a.Name, err)), nil
+			}
+		}
+	}
 	return SuccessResult(string(res)), nil
 }
@@ -3,6 +3,7 @@ package channels
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"strings"
 
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
@@ -75,3 +76,18 @@ func sendAliyunSMS(client *dysmsapi.Client, phoneNumber, signName, templateCode,
 
 	return fmt.Sprintf("RequestId: %s, BizId: %s", tea.StringValue(response.Body.RequestId), tea.StringValue(response.Body.BizId)), nil
 }
+
+// withTitle 为不支持独立标题字段的渠道，将标题按对应格式拼接到正文前
+func withTitle(title, content, formatType string) string {
+	if title == "" {
+		return content
+	}
+	switch formatType {
+	case FormatTypeMarkdown:
+		return "**" + title + "**\n" + content
+	case FormatTypeHTML:
+		return "<b>" + html.EscapeString(title) + "</b><br/>" + content
+	default:
+		return title + "\n" + content
+	}
+}
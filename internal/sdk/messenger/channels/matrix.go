@@ -0,0 +1,51 @@
+package channels
+
+import "github.com/engigu/baihu-panel/internal/sdk/message"
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+type MatrixChannel struct{ *BaseChannel }
+
+func NewMatrixChannel() Channel {
+	return &MatrixChannel{NewBaseChannel(ChannelMatrix, []string{FormatTypeHTML, FormatTypeText})}
+}
+
+func (c *MatrixChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
+	homeserver := config.GetString("homeserver")
+	accessToken := config.GetString("access_token")
+	roomID := config.GetString("room_id")
+
+	if homeserver == "" || accessToken == "" || roomID == "" {
+		return SendError("matrix config missing: homeserver, access_token, room_id are required"), nil
+	}
+
+	cli := message.Matrix{
+		Homeserver:  homeserver,
+		AccessToken: accessToken,
+		RoomID:      roomID,
+		MsgType:     config.GetString("msg_type"),
+	}
+
+	// body 始终携带纯文本，客户端不支持 HTML 时回退显示
+	body := withTitle(msg.Title, msg.Text, FormatTypeText)
+	formatted := ""
+	if contentType, content := c.FormatContent(msg); contentType == FormatTypeHTML {
+		formatted = withTitle(msg.Title, content, FormatTypeHTML)
+	}
+
+	res, err := cli.Send(body, formatted)
+	if err != nil {
+		return ErrorResult(string(res), err), nil
+	}
+	return SuccessResult(string(res)), nil
+}
@@ -1,6 +1,10 @@
 package channels
 
-import "github.com/engigu/baihu-panel/internal/sdk/message"
+import (
+	"fmt"
+
+	"github.com/engigu/baihu-panel/internal/sdk/message"
+)
 
 // Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
 // Use of this source code is governed by the Apache License 2.0.
@@ -20,6 +24,8 @@ func NewQyWeiXinChannel() Channel {
 	return &QyWeiXinChannel{NewBaseChannel(ChannelQyWeiXin, []string{FormatTypeMarkdown, FormatTypeText})}
 }
 
+func (c *QyWeiXinChannel) SupportsAttachments(config ChannelConfig) bool { return true }
+
 func (c *QyWeiXinChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
 	accessToken := config.GetString("access_token")
 
@@ -51,5 +57,11 @@ func (c *QyWeiXinChannel) Send(config ChannelConfig, msg *Message) (*Result, err
 	if err != nil {
 		return ErrorResult(string(res), err), nil
 	}
+
+	for _, a := range msg.Attachments {
+		if fileRes, err := cli.SendFile(a.Name, a.Content); err != nil {
+			return ErrorResult(string(fileRes), fmt.Errorf("发送附件 %s 失败: %w", a.Name, err)), nil
+		}
+	}
 	return SuccessResult(string(res)), nil
 }
@@ -0,0 +1,55 @@
+package channels
+
+import "github.com/engigu/baihu-panel/internal/sdk/message"
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+type SlackChannel struct{ *BaseChannel }
+
+func NewSlackChannel() Channel {
+	return &SlackChannel{NewBaseChannel(ChannelSlack, []string{FormatTypeMarkdown, FormatTypeText})}
+}
+
+func (c *SlackChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
+	webhook := config.GetString("webhook")
+	botToken := config.GetString("bot_token")
+	channel := config.GetString("channel")
+
+	if webhook == "" && (botToken == "" || channel == "") {
+		return SendError("slack config missing: webhook or bot_token + channel is required"), nil
+	}
+
+	cli := message.Slack{
+		Webhook:  webhook,
+		BotToken: botToken,
+		Channel:  channel,
+		ApiHost:  config.GetString("api_host"),
+	}
+
+	contentType, content := c.FormatContent(msg)
+	isMrkdwn := contentType == FormatTypeMarkdown
+	text := content
+	if msg.Title != "" {
+		// Slack mrkdwn 的加粗语法为单个星号
+		if isMrkdwn {
+			text = "*" + msg.Title + "*\n" + content
+		} else {
+			text = msg.Title + "\n" + content
+		}
+	}
+	res, err := cli.Send(text, isMrkdwn)
+	if err != nil {
+		return ErrorResult(string(res), err), nil
+	}
+	return SuccessResult(string(res)), nil
+}
@@ -1,6 +1,10 @@
 package channels
 
-import "github.com/engigu/baihu-panel/internal/sdk/message"
+import (
+	"fmt"
+
+	"github.com/engigu/baihu-panel/internal/sdk/message"
+)
 
 // Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
 // Use of this source code is governed by the Apache License 2.0.
@@ -20,6 +24,8 @@ func NewTelegramChannel() Channel {
 	return &TelegramChannel{NewBaseChannel(ChannelTelegram, []string{FormatTypeMarkdown, FormatTypeHTML, FormatTypeText})}
 }
 
+func (c *TelegramChannel) SupportsAttachments(config ChannelConfig) bool { return true }
+
 func (c *TelegramChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
 	botToken := config.GetString("bot_token")
 	chatID := config.GetString("chat_id")
@@ -55,5 +61,11 @@ func (c *TelegramChannel) Send(config ChannelConfig, msg *Message) (*Result, err
 	if err != nil {
 		return ErrorResult(string(res), err), nil
 	}
+
+	for _, a := range msg.Attachments {
+		if docRes, err := cli.SendDocument(a.Name, a.Content, ""); err != nil {
+			return ErrorResult(string(docRes), fmt.Errorf("发送附件 %s 失败: %w", a.Name, err)), nil
+		}
+	}
 	return SuccessResult(string(res)), nil
 }
@@ -25,11 +25,21 @@ type Message struct {
 	AtUserIds []string       `json:"at_user_ids"`
 	AtAll     bool           `json:"at_all"`
 	Extra     map[string]any `json:"extra"`
+
+	// Attachments 附件（如完整日志），仅实现了 AttachmentSender 的渠道会发送
+	Attachments []Attachment `json:"-"`
+}
+
+// Attachment 消息附件
+type Attachment struct {
+	Name    string
+	Content []byte
 }
 
-func (m *Message) HasText() bool     { return m.Text != "" }
-func (m *Message) HasHTML() bool     { return m.HTML != "" }
-func (m *Message) HasMarkdown() bool { return m.Markdown != "" }
+func (m *Message) HasText() bool        { return m.Text != "" }
+func (m *Message) HasHTML() bool        { return m.HTML != "" }
+func (m *Message) HasMarkdown() bool    { return m.Markdown != "" }
+func (m *Message) HasAttachments() bool { return len(m.Attachments) > 0 }
 
 func (m *Message) GetAtMobiles() []string {
 	if m.AtMobiles == nil {
@@ -88,4 +98,12 @@ const (
 	ChannelVoceChat        = "VoceChat"
 	ChannelWxPusher        = "WxPusher"
 	ChannelQyWeiXinApp     = "QyWeiXinApp"
+	ChannelSlack           = "Slack"
+	ChannelDiscord         = "Discord"
+	ChannelMatrix          = "Matrix"
+	ChannelTeams           = "Teams"
+	ChannelPushover        = "Pushover"
+	ChannelServerChan      = "ServerChan"
+	ChannelWebhook         = "Webhook"
+	ChannelApprise         = "Apprise"
 )
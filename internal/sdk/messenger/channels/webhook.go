@@ -0,0 +1,54 @@
+package channels
+
+import (
+	"encoding/json"
+
+	"github.com/engigu/baihu-panel/internal/sdk/message"
+)
+
+// Copyright (c) 2026 engigu (Baihu Panel). All rights reserved.
+// Use of this source code is governed by the Apache License 2.0.
+// 
+// 【重要声明 / IMPORTANT NOTICE】
+// 本代码（包括其架构设计与核心实现）属于白虎面板（Baihu Panel）开源项目的一部分。
+// 任何个人或组织在引用、移植、修改或重新分发此文件中的任何代码时，必须保留本版权声明，
+// 并在您的衍生作品、文档、软件关于页面或说明文件中显式声明引用自白虎面板（Baihu Panel）。
+// 
+// Anyone referencing, porting, modifying, or redistributing this code must retain this 
+// copyright notice and explicitly state the source: Baihu Panel (github.com/engigu/baihu-panel).
+
+
+// WebhookChannel 通用 Webhook，与 Custom 渠道的区别在于请求体为固定结构，无需编写模板
+type WebhookChannel struct{ *BaseChannel }
+
+func NewWebhookChannel() Channel {
+	return &WebhookChannel{NewBaseChannel(ChannelWebhook, []string{FormatTypeText, FormatTypeMarkdown, FormatTypeHTML})}
+}
+
+func (c *WebhookChannel) Send(config ChannelConfig, msg *Message) (*Result, error) {
+	webhookURL := config.GetString("url")
+	if webhookURL == "" {
+		return SendError("webhook config missing: url is required"), nil
+	}
+
+	var headers map[string]string
+	if h := config.GetString("headers"); h != "" {
+		if err := json.Unmarshal([]byte(h), &headers); err != nil {
+			return SendError("webhook headers 不是合法的 JSON: %s", err.Error()), nil
+		}
+	}
+
+	cli := message.GenericWebhook{
+		URL:     webhookURL,
+		Format:  config.GetString("format"),
+		Method:  config.GetString("method"),
+		Headers: headers,
+	}
+
+	contentType, content := c.FormatContent(msg)
+	res, err := cli.Request(msg.Title, content, contentType)
+	if err != nil {
+		return ErrorResult(string(res), err), nil
+	}
+	return SuccessResult(string(res)), nil
+}
@@ -39,6 +39,7 @@ type (
 	ChannelConfig = channels.ChannelConfig
 	Result        = channels.Result
 	BaseChannel   = channels.BaseChannel
+	Attachment    = channels.Attachment
 )
 
 // 重导出常量
@@ -63,6 +64,14 @@ const (
 	ChannelVoceChat        = channels.ChannelVoceChat
 	ChannelWxPusher        = channels.ChannelWxPusher
 	ChannelQyWeiXinApp     = channels.ChannelQyWeiXinApp
+	ChannelSlack           = channels.ChannelSlack
+	ChannelDiscord         = channels.ChannelDiscord
+	ChannelMatrix          = channels.ChannelMatrix
+	ChannelTeams           = channels.ChannelTeams
+	ChannelPushover        = channels.ChannelPushover
+	ChannelServerChan      = channels.ChannelServerChan
+	ChannelWebhook         = channels.ChannelWebhook
+	ChannelApprise         = channels.ChannelApprise
 )
 
 // 重导出辅助函数
@@ -98,6 +107,14 @@ func init() {
 	RegisterChannel(ChannelVoceChat, func() Channel { return channels.NewVoceChatChannel() })
 	RegisterChannel(ChannelWxPusher, func() Channel { return channels.NewWxPusherChannel() })
 	RegisterChannel(ChannelQyWeiXinApp, func() Channel { return channels.NewQyWeiXinAppChannel() })
+	RegisterChannel(ChannelSlack, func() Channel { return channels.NewSlackChannel() })
+	RegisterChannel(ChannelDiscord, func() Channel { return channels.NewDiscordChannel() })
+	RegisterChannel(ChannelMatrix, func() Channel { return channels.NewMatrixChannel() })
+	RegisterChannel(ChannelTeams, func() Channel { return channels.NewTeamsChannel() })
+	RegisterChannel(ChannelPushover, func() Channel { return channels.NewPushoverChannel() })
+	RegisterChannel(ChannelServerChan, func() Channel { return channels.NewServerChanChannel() })
+	RegisterChannel(ChannelWebhook, func() Channel { return channels.NewWebhookChannel() })
+	RegisterChannel(ChannelApprise, func() Channel { return channels.NewAppriseChannel() })
 }
 
 // RegisterChannel 注册自定义渠道（可用于扩展）
@@ -129,6 +146,15 @@ func ListChannels() []string {
 	return types
 }
 
+// SupportsAttachments 判断渠道在给定配置下能否发送附件
+func SupportsAttachments(channelType string, config ChannelConfig) bool {
+	ch, err := GetChannel(channelType)
+	if err != nil {
+		return false
+	}
+	return channels.SupportsAttachments(ch, config)
+}
+
 // Send 发送消息的便捷函数
 func Send(channelType string, config ChannelConfig, msg *Message) (*Result, error) {
 	ch, err := GetChannel(channelType)
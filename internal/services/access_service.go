@@ -0,0 +1,443 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+// AccessConfig 访问控制配置（已解析）
+type AccessConfig struct {
+	Allowlists     map[string][]*net.IPNet // 入口 -> 允许的网段，为空表示不限制
+	Rates          map[string]int          // 限流范围 -> 每分钟请求数，0 表示不限制
+	BanThreshold   int
+	BanDuration    time.Duration
+	TrustedProxies []*net.IPNet // 受信反向代理，为空表示不采信任何转发头
+	// LocalBreakGlass 关闭密码登录后是否仍允许本机直连使用本地账号登录
+	LocalBreakGlass bool
+}
+
+// IPBan 被封禁的 IP
+type IPBan struct {
+	IP        string    `json:"ip"`
+	Surface   string    `json:"surface"`
+	Reason    string    `json:"reason"`
+	BannedAt  time.Time `json:"banned_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// tokenBucket 令牌桶，容量与每分钟速率相同
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// violation 一分钟窗口内被限流拒绝的次数
+type violation struct {
+	count int
+	start time.Time
+}
+
+var accessSurfaceKeys = map[string]string{
+	constant.AccessSurfaceUI:           constant.KeyAccessUIAllowlist,
+	constant.AccessSurfaceOpenapi:      constant.KeyAccessOpenapiAllowlist,
+	constant.AccessSurfaceAgent:        constant.KeyAccessAgentAllowlist,
+	constant.AccessSurfaceInterconnect: constant.KeyAccessInterconnectAllowlist,
+}
+
+var rateScopeKeys = map[string]string{
+	constant.RateScopeLogin:        constant.KeyAccessRateLogin,
+	constant.RateScopeOpenapi:      constant.KeyAccessRateOpenapi,
+	constant.RateScopeNotify:       constant.KeyAccessRateNotify,
+	constant.RateScopeAgent:        constant.KeyAccessRateAgent,
+	constant.RateScopeInterconnect: constant.KeyAccessRateInterconnect,
+}
+
+var surfaceLabels = map[string]string{
+	constant.AccessSurfaceUI:           "管理界面",
+	constant.AccessSurfaceOpenapi:      "OpenAPI",
+	constant.AccessSurfaceAgent:        "Agent",
+	constant.AccessSurfaceInterconnect: "面板互联",
+}
+
+// AccessService IP 允许列表、限流与封禁，状态保存在内存中
+type AccessService struct {
+	mu         sync.Mutex
+	config     *AccessConfig
+	buckets    map[string]*tokenBucket
+	violations map[string]*violation
+	bans       map[string]*IPBan
+	now        func() time.Time
+}
+
+var (
+	accessService     *AccessService
+	accessServiceOnce sync.Once
+)
+
+// GetAccessService 获取访问控制服务单例
+func GetAccessService() *AccessService {
+	accessServiceOnce.Do(func() {
+		accessService = newAccessService()
+	})
+	return accessService
+}
+
+func newAccessService() *AccessService {
+	return &AccessService{
+		buckets:    make(map[string]*tokenBucket),
+		violations: make(map[string]*violation),
+		bans:       make(map[string]*IPBan),
+		now:        time.Now,
+	}
+}
+
+// ParseCIDRList 解析 IP / CIDR 列表，支持换行、逗号或空格分隔，# 之后为注释，单个 IP 视为 /32 或 /128
+func ParseCIDRList(text string) ([]*net.IPNet, error) {
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		items = append(items, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r'
+		})...)
+	}
+
+	var nets []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的 IP: %s", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的网段: %s", item)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ValidateAccessSettings 校验访问控制设置中的允许列表，返回第一个错误
+func ValidateAccessSettings(values map[string]string) error {
+	for surface, key := range accessSurfaceKeys {
+		if _, err := ParseCIDRList(values[key]); err != nil {
+			return fmt.Errorf("%s允许列表: %v", surfaceLabels[surface], err)
+		}
+	}
+	if _, err := ParseCIDRList(values[constant.KeyAccessTrustedProxies]); err != nil {
+		return fmt.Errorf("受信代理: %v", err)
+	}
+	return nil
+}
+
+// IPInList 判断 IP 是否属于任一网段
+func IPInList(ip string, nets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// isLoopbackIP 是否为回环地址
+func isLoopbackIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && parsed.IsLoopback()
+}
+
+// loadAccessConfig 从设置中读取访问控制配置，无法解析的条目跳过并记录警告
+func loadAccessConfig() *AccessConfig {
+	values := NewSettingsService().GetSection(constant.SectionAccess)
+	cfg := &AccessConfig{
+		Allowlists:   make(map[string][]*net.IPNet),
+		Rates:        make(map[string]int),
+		BanThreshold: utils.ToInt(values[constant.KeyAccessBanThreshold], 0),
+		BanDuration:  time.Duration(utils.ToInt(values[constant.KeyAccessBanMinutes], 15)) * time.Minute,
+	}
+	for surface, key := range accessSurfaceKeys {
+		nets, err := ParseCIDRList(values[key])
+		if err != nil {
+			logger.Warnf("[Access] %s允许列表配置有误，已忽略: %v", surfaceLabels[surface], err)
+			continue
+		}
+		cfg.Allowlists[surface] = nets
+	}
+	for scope, key := range rateScopeKeys {
+		cfg.Rates[scope] = utils.ToInt(values[key], 0)
+	}
+	proxies, err := ParseCIDRList(values[constant.KeyAccessTrustedProxies])
+	if err != nil {
+		logger.Warnf("[Access] 受信代理配置有误，已忽略: %v", err)
+	}
+	cfg.TrustedProxies = proxies
+	cfg.LocalBreakGlass = values[constant.KeyAccessLocalBreakGlass] == "true"
+	return cfg
+}
+
+// Config 获取当前访问控制配置，首次调用时从设置加载
+func (s *AccessService) Config() *AccessConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config == nil {
+		s.config = loadAccessConfig()
+	}
+	return s.config
+}
+
+// Reload 设置变更后重新加载配置，并清空按旧速率累计的令牌桶
+func (s *AccessService) Reload() {
+	cfg := loadAccessConfig()
+	s.mu.Lock()
+	s.config = cfg
+	s.buckets = make(map[string]*tokenBucket)
+	s.mu.Unlock()
+}
+
+// ClientIP 解析请求的真实客户端 IP
+// 仅当直连对端属于受信代理时才采信转发头：从 X-Forwarded-For 右侧向左跳过受信代理，取第一个不受信的地址，
+// 没有 X-Forwarded-For 时使用 X-Real-IP。local 表示本机直连且未经代理转发
+func (s *AccessService) ClientIP(r *http.Request) (ip string, local bool) {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	forwarded := r.Header.Values("X-Forwarded-For")
+	realIP := strings.TrimSpace(r.Header.Get("X-Real-IP"))
+	if len(forwarded) == 0 && realIP == "" {
+		return peer, isLoopbackIP(peer)
+	}
+
+	trusted := s.Config().TrustedProxies
+	if !IPInList(peer, trusted) {
+		// 未经受信代理转发的转发头一律忽略，本机代理未配置为受信时也不视为本机直连
+		return peer, false
+	}
+
+	var hops []string
+	for _, value := range forwarded {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if net.ParseIP(hops[i]) == nil {
+			break
+		}
+		if !IPInList(hops[i], trusted) {
+			return hops[i], false
+		}
+		ip = hops[i]
+	}
+	if ip == "" && net.ParseIP(realIP) != nil {
+		ip = realIP
+	}
+	if ip == "" {
+		return peer, false
+	}
+	return ip, false
+}
+
+// LocalBreakGlass 请求是否可走本机应急登录：需显式开启，且为本机直连
+func (s *AccessService) LocalBreakGlass(local bool) bool {
+	return local && s.Config().LocalBreakGlass
+}
+
+// IPAllowed 判断 IP 是否在指定入口的允许列表中
+func (s *AccessService) IPAllowed(surface, ip string) bool {
+	nets := s.Config().Allowlists[surface]
+	return len(nets) == 0 || IPInList(ip, nets)
+}
+
+// BannedUntil 返回 IP 的封禁到期时间，未封禁时 ok 为 false
+func (s *AccessService) BannedUntil(ip string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ban, ok := s.bans[ip]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !s.now().Before(ban.ExpiresAt) {
+		delete(s.bans, ip)
+		return time.Time{}, false
+	}
+	return ban.ExpiresAt, true
+}
+
+// Allow 按限流范围消耗一个令牌；被拒绝时累计违规次数，达到阈值后封禁该 IP
+func (s *AccessService) Allow(scope, surface, ip string) bool {
+	cfg := s.Config()
+	rate := cfg.Rates[scope]
+	if rate <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	now := s.now()
+	key := scope + "|" + ip
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(rate), last: now}
+		s.buckets[key] = bucket
+	} else {
+		bucket.tokens += now.Sub(bucket.last).Minutes() * float64(rate)
+		if bucket.tokens > float64(rate) {
+			bucket.tokens = float64(rate)
+		}
+		bucket.last = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		s.mu.Unlock()
+		return true
+	}
+
+	shouldBan := false
+	if cfg.BanThreshold > 0 {
+		v, ok := s.violations[ip]
+		if !ok || now.Sub(v.start) >= time.Minute {
+			v = &violation{start: now}
+			s.violations[ip] = v
+		}
+		v.count++
+		if v.count >= cfg.BanThreshold {
+			delete(s.violations, ip)
+			shouldBan = true
+		}
+	}
+	s.mu.Unlock()
+
+	if shouldBan {
+		s.Ban(ip, surface, fmt.Sprintf("请求过于频繁（%s）", scope), cfg.BanDuration)
+	}
+	return false
+}
+
+// Ban 封禁 IP 一段时间，新封禁时发布 EventIPBanned 事件
+func (s *AccessService) Ban(ip, surface, reason string, duration time.Duration) {
+	if ip == "" || duration <= 0 {
+		return
+	}
+	s.mu.Lock()
+	now := s.now()
+	existing, banned := s.bans[ip]
+	banned = banned && now.Before(existing.ExpiresAt)
+	s.bans[ip] = &IPBan{IP: ip, Surface: surface, Reason: reason, BannedAt: now, ExpiresAt: now.Add(duration)}
+	s.mu.Unlock()
+
+	if banned {
+		return
+	}
+	logger.Warnf("[Access] IP %s 已被封禁 %v: %s", ip, duration, reason)
+	eventbus.DefaultBus.Publish(eventbus.Event{
+		Type: constant.EventIPBanned,
+		Payload: map[string]interface{}{
+			"ip":         ip,
+			"surface":    surface,
+			"reason":     reason,
+			"minutes":    int(duration / time.Minute),
+			"expires_at": now.Add(duration),
+		},
+	})
+}
+
+// Unban 解除封禁
+func (s *AccessService) Unban(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.bans[ip]
+	delete(s.bans, ip)
+	delete(s.violations, ip)
+	return ok
+}
+
+// ListBans 获取当前生效的封禁，按到期时间倒序
+func (s *AccessService) ListBans() []IPBan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	bans := make([]IPBan, 0, len(s.bans))
+	for _, ban := range s.bans {
+		if now.Before(ban.ExpiresAt) {
+			bans = append(bans, *ban)
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].ExpiresAt.After(bans[j].ExpiresAt) })
+	return bans
+}
+
+// CleanUp 清理已回满的令牌桶、过期的违规计数与封禁，防止内存增长
+func (s *AccessService) CleanUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.last) > time.Minute {
+			delete(s.buckets, key)
+		}
+	}
+	for ip, v := range s.violations {
+		if now.Sub(v.start) >= time.Minute {
+			delete(s.violations, ip)
+		}
+	}
+	for ip, ban := range s.bans {
+		if !now.Before(ban.ExpiresAt) {
+			delete(s.bans, ip)
+		}
+	}
+}
+
+// SubscribeEvents 登录暴力破解时封禁来源 IP，封禁时发送系统通知
+func (s *AccessService) SubscribeEvents(bus *eventbus.EventBus) {
+	bus.Subscribe(constant.EventBruteForceLogin, func(e eventbus.Event) {
+		payload, ok := e.Payload.(map[string]interface{})
+		if !ok {
+			return
+		}
+		ip, _ := payload["ip"].(string)
+		if cfg := s.Config(); cfg.BanThreshold > 0 {
+			s.Ban(ip, constant.AccessSurfaceUI, "登录密码多次错误", cfg.BanDuration)
+		}
+	})
+
+	bus.Subscribe(constant.EventIPBanned, func(e eventbus.Event) {
+		payload, ok := e.Payload.(map[string]interface{})
+		if !ok {
+			return
+		}
+		surface, _ := payload["surface"].(string)
+		bus.Publish(eventbus.Event{
+			Type: constant.EventSystemNotice,
+			Payload: map[string]interface{}{
+				"title": "IP 已被封禁",
+				"content": fmt.Sprintf("IP %v 访问%s时%v，已封禁 %v 分钟",
+					payload["ip"], surfaceLabels[surface], payload["reason"], payload["minutes"]),
+				"level": constant.LogLevelError,
+			},
+		})
+	})
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+func TestParseCIDRList(t *testing.T) {
+	nets, err := ParseCIDRList("10.0.0.0/8, 192.168.1.5\n# office\n2001:db8::/32 ::ffff:1.2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nets) != 4 {
+		t.Fatalf("expected 4 networks, got %d", len(nets))
+	}
+	for ip, want := range map[string]bool{
+		"10.1.2.3":    true,
+		"192.168.1.5": true,
+		"192.168.1.6": false,
+		"2001:db8::1": true,
+		"1.2.3.4":     true,
+		"8.8.8.8":     false,
+		"not-an-ip":   false,
+	} {
+		if got := IPInList(ip, nets); got != want {
+			t.Errorf("IPInList(%q) = %v, want %v", ip, got, want)
+		}
+	}
+
+	if _, err := ParseCIDRList("10.0.0.0/33"); err == nil {
+		t.Error("invalid CIDR should fail")
+	}
+	if _, err := ParseCIDRList("example.com"); err == nil {
+		t.Error("hostname should fail")
+	}
+	if nets, err := ParseCIDRList("  \n "); err != nil || len(nets) != 0 {
+		t.Errorf("blank list should be empty, got %v, %v", nets, err)
+	}
+}
+
+func TestAccessRateLimitAndBan(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newAccessService()
+	s.now = func() time.Time { return now }
+	allow, _ := ParseCIDRList("203.0.113.0/24")
+	s.config = &AccessConfig{
+		Allowlists:   map[string][]*net.IPNet{constant.AccessSurfaceAgent: allow},
+		Rates:        map[string]int{constant.RateScopeLogin: 3},
+		BanThreshold: 2,
+		BanDuration:  10 * time.Minute,
+	}
+
+	// 允许列表：未配置的入口不受限制，本机放行由中间件按直连对端判断
+	if s.IPAllowed(constant.AccessSurfaceAgent, "198.51.100.1") || !s.IPAllowed(constant.AccessSurfaceAgent, "203.0.113.9") {
+		t.Error("agent allowlist not applied")
+	}
+	if !s.IPAllowed(constant.AccessSurfaceUI, "198.51.100.1") {
+		t.Error("unrestricted surfaces should be allowed")
+	}
+
+	ip := "198.51.100.7"
+	for i := 0; i < 3; i++ {
+		if !s.Allow(constant.RateScopeLogin, constant.AccessSurfaceUI, ip) {
+			t.Fatalf("request %d should pass within burst", i+1)
+		}
+	}
+	if s.Allow(constant.RateScopeLogin, constant.AccessSurfaceUI, ip) {
+		t.Fatal("burst exhausted, request should be limited")
+	}
+	if !s.Allow(constant.RateScopeLogin, constant.AccessSurfaceUI, "198.51.100.8") {
+		t.Error("other IPs have their own bucket")
+	}
+	if !s.Allow(constant.RateScopeNotify, constant.AccessSurfaceOpenapi, ip) {
+		t.Error("scope without a rate is unlimited")
+	}
+
+	// 20 秒补充 1 个令牌
+	now = now.Add(20 * time.Second)
+	if !s.Allow(constant.RateScopeLogin, constant.AccessSurfaceUI, ip) {
+		t.Error("token should refill over time")
+	}
+	if _, banned := s.BannedUntil(ip); banned {
+		t.Fatal("one violation is below the ban threshold")
+	}
+
+	// 第二次被拒绝达到阈值，封禁该 IP
+	s.Allow(constant.RateScopeLogin, constant.AccessSurfaceUI, ip)
+	until, banned := s.BannedUntil(ip)
+	if !banned || !until.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("ip should be banned until %v, got %v %v", now.Add(10*time.Minute), until, banned)
+	}
+	if bans := s.ListBans(); len(bans) != 1 || bans[0].IP != ip || bans[0].Surface != constant.AccessSurfaceUI {
+		t.Errorf("unexpected bans: %+v", bans)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if _, banned := s.BannedUntil(ip); banned {
+		t.Error("ban should expire")
+	}
+
+	s.Ban(ip, constant.AccessSurfaceUI, "test", time.Minute)
+	if !s.Unban(ip) || s.Unban(ip) {
+		t.Error("unban should succeed once")
+	}
+	s.Ban("::1", constant.AccessSurfaceUI, "test", time.Minute)
+	if _, banned := s.BannedUntil("::1"); !banned {
+		t.Error("loopback should be banned like any other IP")
+	}
+	s.Unban("::1")
+
+	now = now.Add(2 * time.Minute)
+	s.CleanUp()
+	if len(s.buckets) != 0 || len(s.violations) != 0 {
+		t.Errorf("cleanup should drop idle state, buckets=%d violations=%d", len(s.buckets), len(s.violations))
+	}
+}
+
+func TestAccessClientIP(t *testing.T) {
+	s := newAccessService()
+	s.config = &AccessConfig{}
+
+	request := func(remote string, headers map[string]string) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remote
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		return r
+	}
+	check := func(name string, r *http.Request, wantIP string, wantLocal bool) {
+		t.Helper()
+		if ip, local := s.ClientIP(r); ip != wantIP || local != wantLocal {
+			t.Errorf("%s: got %s %v, want %s %v", name, ip, local, wantIP, wantLocal)
+		}
+	}
+
+	// 默认不信任任何代理：伪造的转发头不生效，也不能借此冒充本机
+	check("direct", request("198.51.100.1:5000", nil), "198.51.100.1", false)
+	check("spoofed loopback", request("198.51.100.1:5000", map[string]string{"X-Forwarded-For": "127.0.0.1"}), "198.51.100.1", false)
+	check("local", request("127.0.0.1:5000", nil), "127.0.0.1", true)
+	check("untrusted local proxy", request("127.0.0.1:5000", map[string]string{"X-Forwarded-For": "198.51.100.1"}), "127.0.0.1", false)
+
+	s.config.TrustedProxies, _ = ParseCIDRList("127.0.0.1, 10.0.0.0/8")
+	check("trusted proxy", request("127.0.0.1:5000", map[string]string{"X-Forwarded-For": "127.0.0.1, 198.51.100.1, 10.0.0.2"}), "198.51.100.1", false)
+	check("proxied loopback", request("127.0.0.1:5000", map[string]string{"X-Forwarded-For": "127.0.0.1"}), "127.0.0.1", false)
+	check("real ip header", request("10.1.1.1:5000", map[string]string{"X-Real-IP": "203.0.113.5"}), "203.0.113.5", false)
+	check("untrusted peer", request("198.51.100.9:5000", map[string]string{"X-Forwarded-For": "203.0.113.5"}), "198.51.100.9", false)
+}
+
+func TestAccessLocalBreakGlass(t *testing.T) {
+	s := newAccessService()
+	s.config = &AccessConfig{}
+	if s.LocalBreakGlass(true) {
+		t.Error("break-glass login must be opt-in")
+	}
+	s.config.LocalBreakGlass = true
+	if !s.LocalBreakGlass(true) || s.LocalBreakGlass(false) {
+		t.Error("break-glass login should only apply to direct local requests")
+	}
+}
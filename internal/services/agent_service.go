@@ -352,10 +352,15 @@ func (s *AgentService) GetTasks(agentID string) []models.AgentTask {
 		}
 
 		var secrets []string
+		var envErr error
+		// Agent 自行调度执行，下发时即解析外部机密引用
 		if allEnvs {
-			envVars, secrets = envService.GetAllEnvVarsAndSecrets()
+			envVars, secrets, envErr = envService.GetAllEnvVarsAndSecrets(true)
 		} else if string(task.Envs) != "" {
-			envVars, secrets = envService.GetEnvVarsAndSecretsByIDs(string(task.Envs))
+			envVars, secrets, envErr = envService.GetEnvVarsAndSecretsByIDs(string(task.Envs), true)
+		}
+		if envErr != nil {
+			logger.Warnf("[Agent] 任务 #%s 外部机密解析失败: %v", task.ID, envErr)
 		}
 
 		envVarsStr := executor.FormatEnvVars(envVars)
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// apiTokenPrefix 命名令牌的明文前缀，用于与旧版全局令牌区分
+const apiTokenPrefix = "bhk_"
+
+// apiTokenLogDays 令牌调用记录保留天数
+const apiTokenLogDays = 30
+
+// ApiTokenScopes 可分配给令牌的权限范围
+var ApiTokenScopes = []string{
+	constant.TokenScopeTasksRead,
+	constant.TokenScopeTasksWrite,
+	constant.TokenScopeTasksRun,
+	constant.TokenScopeEnvRead,
+	constant.TokenScopeEnvWrite,
+	constant.TokenScopeScriptsRead,
+	constant.TokenScopeScriptsWrite,
+	constant.TokenScopeLogsRead,
+}
+
+// IsValidTokenScope 判断是否为可分配的权限范围
+func IsValidTokenScope(scope string) bool {
+	for _, s := range ApiTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNamedApiToken 判断明文是否为命名令牌格式
+func IsNamedApiToken(token string) bool {
+	return strings.HasPrefix(token, apiTokenPrefix)
+}
+
+// HashApiToken 计算令牌摘要
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ApiTokenCreate 创建令牌参数
+type ApiTokenCreate struct {
+	Name      string
+	Scopes    []string
+	Tags      string
+	TaskIDs   string
+	ExpiresAt *time.Time
+	CreatedBy string
+}
+
+// ApiTokenService OpenAPI 命名令牌服务
+type ApiTokenService struct{}
+
+// NewApiTokenService 创建令牌服务
+func NewApiTokenService() *ApiTokenService {
+	return &ApiTokenService{}
+}
+
+// CreateToken 创建令牌，返回的明文仅此一次可见
+func (s *ApiTokenService) CreateToken(req ApiTokenCreate) (string, *models.ApiToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", nil, &ServiceError{Message: "令牌名称不能为空"}
+	}
+	if len(req.Scopes) == 0 {
+		return "", nil, &ServiceError{Message: "请至少选择一个权限范围"}
+	}
+	for _, scope := range req.Scopes {
+		if !IsValidTokenScope(scope) {
+			return "", nil, &ServiceError{Message: "无效的权限范围: " + scope}
+		}
+	}
+
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	plain := apiTokenPrefix + hex.EncodeToString(buf)
+
+	var expires *models.LocalTime
+	if req.ExpiresAt != nil {
+		t := models.LocalTime(*req.ExpiresAt)
+		expires = &t
+	}
+
+	token := &models.ApiToken{
+		ID:        utils.GenerateID(),
+		Name:      name,
+		Prefix:    plain[:len(apiTokenPrefix)+6],
+		TokenHash: HashApiToken(plain),
+		Scopes:    strings.Join(req.Scopes, ","),
+		Tags:      strings.Join(SplitScopes(req.Tags), ","),
+		TaskIDs:   strings.Join(SplitScopes(req.TaskIDs), ","),
+		ExpiresAt: expires,
+		Enabled:   utils.BoolPtr(true),
+		CreatedBy: req.CreatedBy,
+	}
+	if err := database.DB.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+
+	logger.Infof("[ApiToken] 创建令牌: %s (%s)", token.Name, token.Prefix+"...")
+	return plain, token, nil
+}
+
+// ListTokens 获取令牌列表
+func (s *ApiTokenService) ListTokens() []models.ApiToken {
+	var tokens []models.ApiToken
+	database.DB.Order("id DESC").Find(&tokens)
+	return tokens
+}
+
+// SetEnabled 启用或吊销令牌
+func (s *ApiTokenService) SetEnabled(id string, enabled bool) error {
+	res := database.DB.Model(&models.ApiToken{}).Where("id = ?", id).Update("enabled", enabled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &ServiceError{Message: "令牌不存在"}
+	}
+	return nil
+}
+
+// DeleteToken 删除令牌及其调用记录
+func (s *ApiTokenService) DeleteToken(id string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token_id = ?", id).Delete(&models.ApiTokenLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.ApiToken{}).Error
+	})
+}
+
+// ValidateToken 校验令牌明文，返回有效的令牌记录
+func (s *ApiTokenService) ValidateToken(plain string) (*models.ApiToken, error) {
+	var token models.ApiToken
+	res := database.DB.Where("token_hash = ?", HashApiToken(plain)).Limit(1).Find(&token)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, &ServiceError{Message: "无效的令牌"}
+	}
+	if !utils.DerefBool(token.Enabled, true) {
+		return nil, &ServiceError{Message: "令牌已吊销"}
+	}
+	if token.ExpiresAt != nil && time.Time(*token.ExpiresAt).Before(time.Now()) {
+		return nil, &ServiceError{Message: "令牌已过期"}
+	}
+	return &token, nil
+}
+
+// RecordUsage 记录一次令牌调用，并更新最近使用信息
+func (s *ApiTokenService) RecordUsage(tokenID, method, path string, status int, ip string) {
+	now := models.LocalTime(time.Now())
+	database.DB.Model(&models.ApiToken{}).Where("id = ?", tokenID).Updates(map[string]interface{}{
+		"last_used_at": &now,
+		"last_used_ip": ip,
+		"use_count":    gorm.Expr("use_count + 1"),
+	})
+	if len(path) > 255 {
+		path = path[:255]
+	}
+	database.DB.Create(&models.ApiTokenLog{
+		ID:      utils.GenerateID(),
+		TokenID: tokenID,
+		Method:  method,
+		Path:    path,
+		Status:  status,
+		IP:      ip,
+	})
+}
+
+// ListUsage 分页获取令牌调用记录
+func (s *ApiTokenService) ListUsage(tokenID string, page, pageSize int) ([]models.ApiTokenLog, int64) {
+	var logs []models.ApiTokenLog
+	var total int64
+	query := database.DB.Model(&models.ApiTokenLog{}).Where("token_id = ?", tokenID)
+	query.Count(&total)
+	query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs)
+	return logs, total
+}
+
+// CleanUp 清理过期的调用记录
+func (s *ApiTokenService) CleanUp() {
+	deadline := time.Now().AddDate(0, 0, -apiTokenLogDays)
+	database.DB.Where("created_at < ?", deadline).Delete(&models.ApiTokenLog{})
+}
+
+// TokenHasScope 判断令牌权限范围中是否包含指定项
+func TokenHasScope(scopes, required string) bool {
+	for _, s := range SplitScopes(scopes) {
+		if s == required {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTokenAccessScope 构建令牌的访问范围
+// 令牌最高等同编辑角色，具体能力由权限范围决定，任务/标签限制与受限账户一致
+func NewTokenAccessScope(token *models.ApiToken) *AccessScope {
+	return &AccessScope{
+		Role:  constant.RoleEditor,
+		Tags:  SplitScopes(token.Tags),
+		Tasks: SplitScopes(token.TaskIDs),
+	}
+}
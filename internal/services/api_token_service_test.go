@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/models"
+)
+
+func TestTokenHasScope(t *testing.T) {
+	scopes := "tasks:read, tasks:run"
+	if !TokenHasScope(scopes, constant.TokenScopeTasksRun) {
+		t.Error("tasks:run should be granted")
+	}
+	if TokenHasScope(scopes, constant.TokenScopeTasksWrite) || TokenHasScope("", constant.TokenScopeTasksRead) {
+		t.Error("scopes not listed must be denied")
+	}
+}
+
+func TestNewTokenAccessScope(t *testing.T) {
+	scope := NewTokenAccessScope(&models.ApiToken{Tags: "ci", TaskIDs: "t1,t2"})
+	if scope.Role != constant.RoleEditor || !scope.Scoped() {
+		t.Fatalf("token scope should be a scoped editor, got %+v", scope)
+	}
+	if len(scope.Tags) != 1 || len(scope.Tasks) != 2 {
+		t.Errorf("unexpected restrictions: %+v", scope)
+	}
+
+	if NewTokenAccessScope(&models.ApiToken{}).Scoped() {
+		t.Error("token without restrictions should not be scoped")
+	}
+}
+
+func TestIsNamedApiToken(t *testing.T) {
+	if !IsNamedApiToken("bhk_0123") || IsNamedApiToken("legacy-token") {
+		t.Error("named tokens are identified by prefix")
+	}
+	if HashApiToken("a") == HashApiToken("b") || len(HashApiToken("a")) != 64 {
+		t.Error("HashApiToken should be a sha256 hex digest")
+	}
+}
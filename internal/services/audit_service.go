@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// auditRedacted 脱敏占位符
+const auditRedacted = "[REDACTED]"
+
+// auditSensitiveKeys 字段名包含以下片段时视为敏感字段
+var auditSensitiveKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "access_key", "private_key", "webhook", "otp"}
+
+// AuditActor 审计日志中的操作者
+type AuditActor struct {
+	UserID     string
+	Username   string
+	AuthMethod string
+	TokenName  string
+	IP         string
+}
+
+// AuditEntry 一条审计记录，Before/After 为变更前后的对象，任一可为 nil
+type AuditEntry struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	ResourceName string
+	Before       interface{}
+	After        interface{}
+	// Redact 额外需要脱敏的字段，如变量的 value
+	Redact []string
+}
+
+// AuditQuery 审计日志查询条件
+type AuditQuery struct {
+	Keyword      string
+	Username     string
+	Action       string
+	ResourceType string
+	AuthMethod   string
+	Start        string // 2006-01-02
+	End          string // 2006-01-02
+}
+
+// AuditService 审计日志服务
+type AuditService struct {
+	settingsService *SettingsService
+}
+
+// NewAuditService 创建审计日志服务
+func NewAuditService() *AuditService {
+	return &AuditService{settingsService: NewSettingsService()}
+}
+
+// Record 追加一条审计日志，写入失败只记录应用日志，不影响业务请求
+func (s *AuditService) Record(actor AuditActor, e AuditEntry) {
+	log := &models.AuditLog{
+		ID:           utils.GenerateID(),
+		UserID:       actor.UserID,
+		Username:     actor.Username,
+		AuthMethod:   actor.AuthMethod,
+		TokenName:    actor.TokenName,
+		IP:           actor.IP,
+		Action:       e.Action,
+		ResourceType: e.ResourceType,
+		ResourceID:   e.ResourceID,
+		ResourceName: e.ResourceName,
+		Diff:         models.BigText(AuditDiff(e.Before, e.After, e.Redact...)),
+	}
+	if err := database.DB.Create(log).Error; err != nil {
+		logger.Warnf("[Audit] 写入审计日志失败 %s: %v", e.Action, err)
+	}
+}
+
+// List 分页查询审计日志
+func (s *AuditService) List(q AuditQuery, page, pageSize int) ([]models.AuditLog, int64, error) {
+	var logs []models.AuditLog
+	var total int64
+	query := s.buildQuery(q)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error
+	return logs, total, err
+}
+
+// Export 按条件导出审计日志，最多 limit 条
+func (s *AuditService) Export(q AuditQuery, limit int) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	err := s.buildQuery(q).Order("created_at DESC").Order("id DESC").Limit(limit).Find(&logs).Error
+	return logs, err
+}
+
+func (s *AuditService) buildQuery(q AuditQuery) *gorm.DB {
+	query := database.DB.Model(&models.AuditLog{})
+	if q.Keyword != "" {
+		like := "%" + q.Keyword + "%"
+		query = query.Where("username LIKE ? OR resource_name LIKE ? OR resource_id LIKE ? OR action LIKE ? OR ip LIKE ?", like, like, like, like, like)
+	}
+	if q.Username != "" {
+		query = query.Where("username = ?", q.Username)
+	}
+	if q.Action != "" {
+		query = query.Where("action LIKE ?", q.Action+"%")
+	}
+	if q.ResourceType != "" {
+		query = query.Where("resource_type = ?", q.ResourceType)
+	}
+	if q.AuthMethod != "" {
+		query = query.Where("auth_method = ?", q.AuthMethod)
+	}
+	if t, err := time.ParseInLocation("2006-01-02", q.Start, time.Local); err == nil {
+		query = query.Where("created_at >= ?", t)
+	}
+	if t, err := time.ParseInLocation("2006-01-02", q.End, time.Local); err == nil {
+		query = query.Where("created_at < ?", t.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+// CleanUp 按保留天数清理审计日志，0 表示永久保留
+func (s *AuditService) CleanUp() {
+	days := utils.ToInt(s.settingsService.Get(constant.SectionSystem, constant.KeyAuditLogDays), 180)
+	if days <= 0 {
+		return
+	}
+	deadline := time.Now().AddDate(0, 0, -days)
+	res := database.DB.Where("created_at < ?", deadline).Delete(&models.AuditLog{})
+	if res.Error == nil && res.RowsAffected > 0 {
+		logger.Infof("[Audit] 已清理 %d 条过期审计日志", res.RowsAffected)
+	}
+}
+
+// AuditDiff 生成变更前后的字段差异 JSON，敏感字段只标记变更不记录内容
+// 格式：{"字段": {"before": 旧值, "after": 新值}}，无差异时返回空字符串
+func AuditDiff(before, after interface{}, redact ...string) string {
+	b, a := auditFields(before), auditFields(after)
+
+	keys := make([]string, 0, len(b)+len(a))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	diff := make(map[string]map[string]interface{})
+	for _, k := range keys {
+		bv, inB := b[k]
+		av, inA := a[k]
+		if inB && inA && reflect.DeepEqual(bv, av) {
+			continue
+		}
+		sensitive := isAuditSensitive(k, redact)
+		change := map[string]interface{}{}
+		if inB {
+			change["before"] = auditValue(bv, sensitive)
+		}
+		if inA {
+			change["after"] = auditValue(av, sensitive)
+		}
+		diff[k] = change
+	}
+	if len(diff) == 0 {
+		return ""
+	}
+	out, _ := json.Marshal(diff)
+	return string(out)
+}
+
+// auditFields 将对象序列化为字段表
+func auditFields(v interface{}) map[string]interface{} {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	fields := map[string]interface{}{}
+	if json.Unmarshal(raw, &fields) != nil {
+		return map[string]interface{}{"value": string(raw)}
+	}
+	// 时间戳每次变更都会变化，不计入差异
+	delete(fields, "created_at")
+	delete(fields, "updated_at")
+	return fields
+}
+
+func isAuditSensitive(key string, redact []string) bool {
+	lower := strings.ToLower(key)
+	for _, r := range redact {
+		if strings.ToLower(r) == lower {
+			return true
+		}
+	}
+	for _, s := range auditSensitiveKeys {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditValue 脱敏字段值，嵌套对象按字段名逐层脱敏
+func auditValue(v interface{}, sensitive bool) interface{} {
+	if sensitive {
+		if v == nil || v == "" {
+			return v
+		}
+		return auditRedacted
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = auditValue(item, isAuditSensitive(k, nil))
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = auditValue(item, false)
+		}
+		return out
+	}
+	return v
+}
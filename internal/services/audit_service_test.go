@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := map[string]interface{}{"name": "a", "value": "old-secret", "remark": "same", "updated_at": "t1"}
+	after := map[string]interface{}{"name": "b", "value": "new-secret", "remark": "same", "updated_at": "t2", "api_token": "xyz"}
+
+	out := AuditDiff(before, after, "value")
+	if strings.Contains(out, "secret") || strings.Contains(out, "xyz") {
+		t.Fatalf("sensitive values leaked: %s", out)
+	}
+
+	var diff map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &diff); err != nil {
+		t.Fatalf("invalid diff json: %v", err)
+	}
+	if _, ok := diff["remark"]; ok {
+		t.Error("unchanged fields should be omitted")
+	}
+	if _, ok := diff["updated_at"]; ok {
+		t.Error("timestamps should be ignored")
+	}
+	if diff["name"]["before"] != "a" || diff["name"]["after"] != "b" {
+		t.Errorf("name diff = %v", diff["name"])
+	}
+	if diff["value"]["after"] != auditRedacted || diff["api_token"]["after"] != auditRedacted {
+		t.Errorf("sensitive fields should be redacted: %v", diff)
+	}
+	if _, ok := diff["api_token"]["before"]; ok {
+		t.Error("added field should have no before value")
+	}
+}
+
+func TestAuditDiff_Nested(t *testing.T) {
+	after := map[string]interface{}{"config": map[string]interface{}{"url": "https://x", "secret": "s"}}
+	out := AuditDiff(nil, after)
+	if strings.Contains(out, `"s"`) || !strings.Contains(out, "https://x") {
+		t.Errorf("nested sensitive keys should be redacted: %s", out)
+	}
+}
+
+func TestAuditDiff_NoChange(t *testing.T) {
+	var nilPtr *struct{ Name string }
+	if got := AuditDiff(nil, nilPtr); got != "" {
+		t.Errorf("nil values should produce no diff, got %q", got)
+	}
+	v := struct {
+		Name string `json:"name"`
+	}{"x"}
+	if got := AuditDiff(v, v); got != "" {
+		t.Errorf("identical values should produce no diff, got %q", got)
+	}
+}
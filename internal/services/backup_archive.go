@@ -0,0 +1,343 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/backupstore"
+	"github.com/engigu/baihu-panel/internal/database"
+)
+
+const (
+	backupManifestName = "__manifest__.json"
+	backupSysInfoName  = "__sys__.json"
+)
+
+// ErrBackupIntegrity 备份包与清单不一致
+var ErrBackupIntegrity = errors.New("备份完整性校验失败")
+
+// backupManifest 备份清单，记录包内每个文件的大小与 SHA-256
+type backupManifest struct {
+	Version int                            `json:"version"`
+	Files   map[string]backupManifestEntry `json:"files"`
+}
+
+type backupManifestEntry struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// backupSysInfo __sys__.json 中的元数据
+type backupSysInfo struct {
+	Version  string   `json:"version"`
+	Ts       string   `json:"ts"`
+	Excluded []string `json:"excluded"`
+}
+
+type manifestHash struct {
+	h    hash.Hash
+	size int64
+}
+
+func (m *manifestHash) Write(p []byte) (int, error) {
+	m.size += int64(len(p))
+	return m.h.Write(p)
+}
+
+// manifestZip 在写入 zip 的同时计算每个文件的 SHA-256，Close 时写入清单
+type manifestZip struct {
+	zw    *zip.Writer
+	files map[string]*manifestHash
+}
+
+func newManifestZip(w io.Writer) *manifestZip {
+	return &manifestZip{zw: zip.NewWriter(w), files: make(map[string]*manifestHash)}
+}
+
+// Create 创建包内文件，目录项（以 / 结尾）不计入清单
+func (m *manifestZip) Create(name string) (io.Writer, error) {
+	w, err := m.zw.Create(name)
+	if err != nil || strings.HasSuffix(name, "/") {
+		return w, err
+	}
+	h := &manifestHash{h: sha256.New()}
+	m.files[name] = h
+	return io.MultiWriter(w, h), nil
+}
+
+func (m *manifestZip) Close() error {
+	manifest := backupManifest{Version: 1, Files: make(map[string]backupManifestEntry, len(m.files))}
+	for name, h := range m.files {
+		manifest.Files[name] = backupManifestEntry{Size: h.size, SHA256: hex.EncodeToString(h.h.Sum(nil))}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := m.zw.Create(backupManifestName)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return m.zw.Close()
+}
+
+// backupArchive 已打开的备份包，加密备份会先解密到临时文件
+type backupArchive struct {
+	Reader    *zip.Reader
+	Encrypted bool
+	file      *os.File
+	tmpPath   string
+}
+
+func (a *backupArchive) Close() error {
+	err := a.file.Close()
+	if a.tmpPath != "" {
+		os.Remove(a.tmpPath)
+	}
+	return err
+}
+
+// openBackupArchive 打开备份文件，按文件头自动识别是否加密
+func openBackupArchive(zipPath, passphrase string) (*backupArchive, error) {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8)
+	n, _ := io.ReadFull(f, header)
+	archive := &backupArchive{file: f, Encrypted: backupstore.IsEncrypted(header[:n])}
+
+	if archive.Encrypted {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+		plain, err := backupstore.Decrypt(bufio.NewReader(f), passphrase)
+		if err == nil {
+			archive.file, archive.tmpPath, err = decryptToTemp(plain)
+		}
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := archive.file.Stat()
+	if err == nil {
+		archive.Reader, err = zip.NewReader(archive.file, info.Size())
+	}
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("无法读取备份包: %v", err)
+	}
+	return archive, nil
+}
+
+func decryptToTemp(r io.Reader) (*os.File, string, error) {
+	tmp, err := os.CreateTemp("", "baihu-backup-*.zip")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, "", err
+	}
+	return tmp, tmp.Name(), nil
+}
+
+// safeArchivePath 判断包内路径是否为不含 .. 的相对路径
+func safeArchivePath(name string) bool {
+	if name == "" || strings.Contains(name, `\`) || path.IsAbs(name) || filepath.IsAbs(name) {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// verifyBackupArchive 按清单校验包内文件，旧版本备份没有清单时返回 nil 清单
+func verifyBackupArchive(r *zip.Reader) (*backupManifest, error) {
+	var manifestFile *zip.File
+	for _, f := range r.File {
+		if !safeArchivePath(f.Name) {
+			return nil, fmt.Errorf("%w: 非法路径 %s", ErrBackupIntegrity, f.Name)
+		}
+		if f.Name == backupManifestName {
+			manifestFile = f
+		}
+	}
+	if manifestFile == nil {
+		return nil, nil
+	}
+
+	rc, err := manifestFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	var manifest backupManifest
+	err = json.NewDecoder(rc).Decode(&manifest)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%w: 清单无法解析", ErrBackupIntegrity)
+	}
+
+	seen := make(map[string]bool, len(manifest.Files))
+	for _, f := range r.File {
+		if f.Name == backupManifestName || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		want, ok := manifest.Files[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s 不在清单中", ErrBackupIntegrity, f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("%w: %s 重复出现", ErrBackupIntegrity, f.Name)
+		}
+		seen[f.Name] = true
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		n, err := io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: 读取 %s 失败: %v", ErrBackupIntegrity, f.Name, err)
+		}
+		if n != want.Size || hex.EncodeToString(h.Sum(nil)) != want.SHA256 {
+			return nil, fmt.Errorf("%w: %s 内容与清单不符", ErrBackupIntegrity, f.Name)
+		}
+	}
+	for name := range manifest.Files {
+		if !seen[name] {
+			return nil, fmt.Errorf("%w: 缺少 %s", ErrBackupIntegrity, name)
+		}
+	}
+	return &manifest, nil
+}
+
+func readBackupSysInfo(fileMap map[string]*zip.File) backupSysInfo {
+	var info backupSysInfo
+	if f, ok := fileMap[backupSysInfoName]; ok {
+		if rc, err := f.Open(); err == nil {
+			json.NewDecoder(rc).Decode(&info)
+			rc.Close()
+		}
+	}
+	return info
+}
+
+// RestoreTableChange 恢复后单个数据表的变化
+type RestoreTableChange struct {
+	File      string `json:"file"`
+	Current   int64  `json:"current"`   // 当前记录数
+	Incoming  int64  `json:"incoming"`  // 备份中按方案选中的记录数
+	Added     int64  `json:"added"`     // 直接写入的新记录
+	Removed   int64  `json:"removed"`   // replace 方式下将被删除且备份中没有的记录
+	Replaced  int64  `json:"replaced"`  // 将被备份覆盖的现有记录
+	Conflicts int64  `json:"conflicts"` // merge 方式下与现有记录冲突的记录
+	Skipped   int64  `json:"skipped"`   // 因冲突保留现有记录而跳过的记录
+	Renamed   int64  `json:"renamed"`   // 因冲突以新 ID 另存的记录
+	Kept      bool   `json:"kept"`      // 备份时排除了该表，恢复时保留现有记录
+}
+
+// RestorePreview 恢复预演结果，不修改任何数据
+type RestorePreview struct {
+	Version        string               `json:"version"`
+	CreatedAt      string               `json:"created_at"`
+	Encrypted      bool                 `json:"encrypted"`
+	Verified       bool                 `json:"verified"` // 已按清单校验全部文件
+	Mode           string               `json:"mode"`
+	Conflict       string               `json:"conflict"`
+	Tables         []RestoreTableChange `json:"tables"`
+	ScriptsAdded   int                  `json:"scripts_added"`
+	ScriptsUpdated int                  `json:"scripts_updated"`
+	ScriptsSkipped int                  `json:"scripts_skipped"`
+	ScriptsRenamed int                  `json:"scripts_renamed"`
+	Tasks          []RestoreTaskItem    `json:"tasks"`       // 备份中的任务，供按任务恢复时选择
+	ScriptDirs     []string             `json:"script_dirs"` // 备份中 scripts 下的顶层目录与文件
+	Warnings       []string             `json:"warnings"`
+}
+
+// PreviewRestore 校验备份包并按恢复方案统计各表与脚本文件的变化，plan 为 nil 时按全量恢复统计
+func (s *BackupService) PreviewRestore(zipPath, passphrase string, plan *RestorePlan) (*RestorePreview, error) {
+	if plan == nil {
+		plan = FullRestorePlan()
+	}
+	if err := plan.normalize(s.RestoreTableNames()); err != nil {
+		return nil, err
+	}
+	archive, err := openBackupArchive(zipPath, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	manifest, err := verifyBackupArchive(archive.Reader)
+	if err != nil {
+		return nil, err
+	}
+	fileMap := make(map[string]*zip.File)
+	for _, f := range archive.Reader.File {
+		fileMap[f.Name] = f
+	}
+	sysInfo := readBackupSysInfo(fileMap)
+
+	preview, err := s.runRestorePlan(database.DB, archive.Reader, sysInfo, plan, false)
+	if err != nil {
+		return nil, err
+	}
+	preview.Version = sysInfo.Version
+	preview.CreatedAt = sysInfo.Ts
+	preview.Encrypted = archive.Encrypted
+	preview.Verified = manifest != nil
+	preview.ScriptDirs = backupScriptDirs(archive.Reader)
+	if manifest == nil {
+		preview.Warnings = append(preview.Warnings, "备份不含完整性清单（旧版本创建），无法校验内容是否被改动")
+	}
+	if !archive.Encrypted {
+		preview.Warnings = append(preview.Warnings, "备份未加密，包含密码哈希、令牌与推送渠道凭据，请妥善保管")
+	}
+	if sysInfo.Version != "" && sysInfo.Version < "v3" {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("备份版本 %s 过旧，无法恢复", sysInfo.Version))
+	}
+	return preview, nil
+}
+
+// backupScriptDirs 列出备份中 scripts 下的顶层条目
+func backupScriptDirs(r *zip.Reader) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range r.File {
+		rel, ok := strings.CutPrefix(f.Name, "scripts/")
+		if !ok || rel == "" || !safeArchivePath(rel) {
+			continue
+		}
+		top, _, _ := strings.Cut(rel, "/")
+		if !seen[top] {
+			seen[top] = true
+			dirs = append(dirs, top)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/backupstore"
+)
+
+func writeTestArchive(t *testing.T, files map[string]string, passphrase string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "backup.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out io.Writer = f
+	var enc io.WriteCloser
+	if passphrase != "" {
+		if enc, err = backupstore.Encrypt(f, passphrase); err != nil {
+			t.Fatal(err)
+		}
+		out = enc
+	}
+	mz := newManifestZip(out)
+	for name, content := range files {
+		w, err := mz.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	if err := mz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if enc != nil {
+		enc.Close()
+	}
+	f.Close()
+	return path
+}
+
+func TestBackupArchiveManifest(t *testing.T) {
+	files := map[string]string{"users.json": "[]", "scripts/": "", "scripts/a.py": "print(1)"}
+	path := writeTestArchive(t, files, "s3cret")
+
+	if _, err := openBackupArchive(path, ""); !errors.Is(err, backupstore.ErrPassphraseRequired) {
+		t.Fatalf("expected passphrase required, got %v", err)
+	}
+	archive, err := openBackupArchive(path, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+	manifest, err := verifyBackupArchive(archive.Reader)
+	if err != nil || manifest == nil || !archive.Encrypted {
+		t.Fatalf("verify = %v, %v", manifest, err)
+	}
+	if len(manifest.Files) != 2 || manifest.Files["scripts/a.py"].Size != 8 {
+		t.Errorf("unexpected manifest %+v", manifest.Files)
+	}
+}
+
+func TestVerifyBackupArchiveRejectsTampering(t *testing.T) {
+	src, err := openBackupArchive(writeTestArchive(t, map[string]string{"users.json": "[]", "envs.json": "[]"}, ""), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	// rebuild 复制原包，按 change 修改或追加文件
+	rebuild := func(change func(zw *zip.Writer, f *zip.File) bool, extra string) *zip.Reader {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for _, f := range src.Reader.File {
+			if !change(zw, f) {
+				zw.Copy(f)
+			}
+		}
+		if extra != "" {
+			w, _ := zw.Create(extra)
+			io.WriteString(w, "x")
+		}
+		zw.Close()
+		r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	keep := func(*zip.Writer, *zip.File) bool { return false }
+
+	if _, err := verifyBackupArchive(rebuild(keep, "")); err != nil {
+		t.Fatalf("untouched archive should verify: %v", err)
+	}
+	modified := rebuild(func(zw *zip.Writer, f *zip.File) bool {
+		if f.Name != "envs.json" {
+			return false
+		}
+		w, _ := zw.Create(f.Name)
+		io.WriteString(w, `[{"id":"x"}]`)
+		return true
+	}, "")
+	dropped := rebuild(func(_ *zip.Writer, f *zip.File) bool { return f.Name == "users.json" }, "")
+	for name, r := range map[string]*zip.Reader{
+		"modified": modified,
+		"dropped":  dropped,
+		"extra":    rebuild(keep, "scripts/evil.sh"),
+		"slip":     rebuild(keep, "scripts/../../etc/cron.d/x"),
+	} {
+		if _, err := verifyBackupArchive(r); !errors.Is(err, ErrBackupIntegrity) {
+			t.Errorf("%s: expected integrity error, got %v", name, err)
+		}
+	}
+	// 没有清单的旧备份可以打开，但不视为已校验
+	legacy := rebuild(func(_ *zip.Writer, f *zip.File) bool { return f.Name == backupManifestName }, "")
+	if m, err := verifyBackupArchive(legacy); err != nil || m != nil {
+		t.Errorf("legacy archive: %v, %v", m, err)
+	}
+}
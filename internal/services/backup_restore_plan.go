@@ -0,0 +1,629 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"gorm.io/gorm"
+)
+
+// 恢复方式
+const (
+	RestoreModeReplace = "replace" // 先清空所选数据再写入备份
+	RestoreModeMerge   = "merge"   // 保留现有数据，按冲突策略处理重复记录
+)
+
+// 与现有记录冲突时的处理策略
+const (
+	RestoreConflictSkip      = "skip"      // 保留现有记录
+	RestoreConflictOverwrite = "overwrite" // 用备份覆盖现有记录
+	RestoreConflictRename    = "rename"    // 以新 ID 和带后缀的名称另存一份
+)
+
+// restoreRenameSuffix rename 策略下追加到名称与文件名上的后缀
+const restoreRenameSuffix = "_restored"
+
+// ErrRestorePlan 恢复方案参数错误
+var ErrRestorePlan = errors.New("恢复方案无效")
+
+// RestorePlan 选择性恢复方案
+type RestorePlan struct {
+	Tables      []string `json:"tables"`       // 要恢复的数据，如 tasks、envs，nil 时恢复全部，空列表时不恢复任何表
+	TaskIDs     []string `json:"task_ids"`     // 只恢复这些任务及其日志、推送统计与关联数据
+	Scripts     bool     `json:"scripts"`      // 是否恢复 scripts 文件夹
+	ScriptPaths []string `json:"script_paths"` // 只恢复 scripts 下的这些子路径，为空时恢复整个文件夹
+	Mode        string   `json:"mode"`         // replace 或 merge
+	Conflict    string   `json:"conflict"`     // skip、overwrite 或 rename
+}
+
+// FullRestorePlan 全量恢复：清空全部数据后写入备份，与旧版本行为一致
+func FullRestorePlan() *RestorePlan {
+	return &RestorePlan{Scripts: true, Mode: RestoreModeReplace, Conflict: RestoreConflictOverwrite}
+}
+
+// restoreTableSpec 选择性恢复时每张表的附加信息
+type restoreTableSpec struct {
+	natural   []string // 主键以外能确定同一条记录的字段
+	rename    string   // rename 策略下追加后缀的字段，为空时无法改名，冲突记录跳过
+	taskField string   // 按任务筛选时比较的字段
+}
+
+var restoreTableSpecs = map[string]restoreTableSpec{
+	"users.json":           {natural: []string{"username"}, rename: "username"},
+	"tasks.json":           {rename: "name", taskField: "id"},
+	"task_logs.json":       {taskField: "task_id"},
+	"envs.json":            {rename: "name"},
+	"scripts.json":         {rename: "name"},
+	"settings.json":        {natural: []string{"section", "key"}},
+	"send_stats.json":      {natural: []string{"task_id", "day", "status"}, taskField: "task_id"},
+	"agents.json":          {natural: []string{"machine_id"}},
+	"tokens.json":          {natural: []string{"token"}},
+	"notify_ways.json":     {rename: "name"},
+	"notify_bindings.json": {taskField: "data_id"},
+	"data_storage.json":    {natural: []string{"type", "name"}},
+	"data_relations.json":  {taskField: "data_id"},
+}
+
+// restoreRefFields 引用其他记录 ID 的字段，改名或跳过产生的新旧 ID 映射会应用到这些字段上
+var restoreRefFields = []string{"task_id", "data_id", "relate_id", "way_id", "agent_id", "user_id", "repo_task_id"}
+
+// RestoreTableNames 可选择恢复的数据名称
+func (s *BackupService) RestoreTableNames() []string {
+	var names []string
+	for _, cfg := range s.getTableConfigs() {
+		names = append(names, strings.TrimSuffix(cfg.filename, ".json"))
+	}
+	return names
+}
+
+// normalize 校验方案并补全默认值，未指定方式时合并并跳过冲突
+func (p *RestorePlan) normalize(tables []string) error {
+	if p.Mode == "" {
+		p.Mode = RestoreModeMerge
+	}
+	if p.Conflict == "" {
+		p.Conflict = RestoreConflictSkip
+	}
+	if p.Mode != RestoreModeReplace && p.Mode != RestoreModeMerge {
+		return fmt.Errorf("%w: 不支持的恢复方式 %s", ErrRestorePlan, p.Mode)
+	}
+	switch p.Conflict {
+	case RestoreConflictSkip, RestoreConflictOverwrite, RestoreConflictRename:
+	default:
+		return fmt.Errorf("%w: 不支持的冲突策略 %s", ErrRestorePlan, p.Conflict)
+	}
+	known := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		known[t] = true
+	}
+	for _, t := range p.Tables {
+		if !known[t] {
+			return fmt.Errorf("%w: 未知的数据 %s", ErrRestorePlan, t)
+		}
+	}
+	for i, sp := range p.ScriptPaths {
+		sp = strings.Trim(path.Clean("/"+filepath.ToSlash(strings.TrimSpace(sp))), "/")
+		if sp == "" || !safeArchivePath(sp) {
+			return fmt.Errorf("%w: 无效的脚本路径 %s", ErrRestorePlan, p.ScriptPaths[i])
+		}
+		p.ScriptPaths[i] = sp
+	}
+	return nil
+}
+
+func (p *RestorePlan) includesTable(filename string) bool {
+	if p.Tables == nil {
+		return true
+	}
+	name := strings.TrimSuffix(filename, ".json")
+	for _, t := range p.Tables {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RestorePlan) includesScript(rel string) bool {
+	if len(p.ScriptPaths) == 0 {
+		return true
+	}
+	for _, sp := range p.ScriptPaths {
+		if rel == sp || strings.HasPrefix(rel, sp+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreRun 一次恢复或预演的状态，预演时 apply 为 false，不写入任何数据
+type restoreRun struct {
+	s        *BackupService
+	tx       *gorm.DB
+	plan     *RestorePlan
+	apply    bool
+	taskIDs  map[string]bool
+	idMap    map[string]string // 备份中的 ID -> 实际写入或沿用的 ID
+	excluded map[string]bool
+}
+
+// runRestorePlan 按方案逐表恢复，返回每张表与脚本文件的变化
+func (s *BackupService) runRestorePlan(tx *gorm.DB, r *zip.Reader, sysInfo backupSysInfo, plan *RestorePlan, apply bool) (*RestorePreview, error) {
+	run := &restoreRun{s: s, tx: tx, plan: plan, apply: apply, idMap: make(map[string]string), excluded: make(map[string]bool)}
+	if len(plan.TaskIDs) > 0 {
+		run.taskIDs = make(map[string]bool, len(plan.TaskIDs))
+		for _, id := range plan.TaskIDs {
+			run.taskIDs[id] = true
+		}
+	}
+	for _, name := range sysInfo.Excluded {
+		if file, ok := backupExcludeFiles[name]; ok {
+			run.excluded[file] = true
+		}
+	}
+	fileMap := make(map[string]*zip.File)
+	for _, f := range r.File {
+		fileMap[f.Name] = f
+	}
+
+	preview := &RestorePreview{Mode: plan.Mode, Conflict: plan.Conflict}
+	for _, cfg := range s.getTableConfigs() {
+		if !plan.includesTable(cfg.filename) {
+			continue
+		}
+		// 按任务恢复且未指定数据时，只处理与任务相关的表
+		if run.taskIDs != nil && plan.Tables == nil && restoreTableSpecs[cfg.filename].taskField == "" {
+			continue
+		}
+		change, err := run.table(cfg, fileMap[cfg.filename])
+		if err != nil {
+			return nil, fmt.Errorf("恢复 %s 失败: %w", cfg.filename, err)
+		}
+		preview.Tables = append(preview.Tables, *change)
+	}
+	if plan.Scripts {
+		if err := run.scripts(r, preview); err != nil {
+			return nil, err
+		}
+	}
+	if f, ok := fileMap["tasks.json"]; ok && !apply {
+		preview.Tasks, _ = listBackupTasks(tx, f)
+	}
+	return preview, nil
+}
+
+// scope 返回 replace 方式下会被清空的现有记录范围
+func (run *restoreRun) scope(cfg tableConfig, spec restoreTableSpec) *gorm.DB {
+	db := run.tx.Model(cfg.model)
+	if cfg.filename == "settings.json" {
+		db = db.Where("section != ?", BackupSection)
+	}
+	if run.taskIDs != nil && spec.taskField != "" {
+		db = db.Where(spec.taskField+" IN ?", run.plan.TaskIDs)
+	}
+	return db
+}
+
+func (run *restoreRun) inScope(spec restoreTableSpec, row map[string]interface{}) bool {
+	if run.plan.Mode != RestoreModeReplace {
+		return false
+	}
+	if run.taskIDs == nil || spec.taskField == "" {
+		return true
+	}
+	return run.taskIDs[columnString(row[spec.taskField])]
+}
+
+func (run *restoreRun) table(cfg tableConfig, f *zip.File) (*RestoreTableChange, error) {
+	spec := restoreTableSpecs[cfg.filename]
+	change := &RestoreTableChange{File: cfg.filename, Kept: run.excluded[cfg.filename]}
+	current := run.tx.Model(cfg.model)
+	if cfg.filename == "settings.json" {
+		current = current.Where("section != ?", BackupSection)
+	}
+	if err := current.Count(&change.Current).Error; err != nil {
+		return nil, err
+	}
+	if change.Kept {
+		return change, nil
+	}
+
+	var scoped int64
+	if run.plan.Mode == RestoreModeReplace {
+		if err := run.scope(cfg, spec).Count(&scoped).Error; err != nil {
+			return nil, err
+		}
+		if run.apply {
+			if err := run.scope(cfg, spec).Where("1=1").Delete(cfg.model).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 自然键 -> 现有记录 ID，replace 方式下范围内的记录即将被清空，不参与冲突判断
+	natural := make(map[string]string)
+	if len(spec.natural) > 0 {
+		var rows []map[string]interface{}
+		if err := run.tx.Model(cfg.model).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if run.inScope(spec, row) {
+				continue
+			}
+			natural[naturalKey(spec.natural, row)] = columnString(row["id"])
+		}
+	}
+
+	if f != nil {
+		if err := run.rows(cfg, spec, f, natural, change); err != nil {
+			return nil, err
+		}
+	}
+	if run.plan.Mode == RestoreModeReplace {
+		change.Removed = scoped - change.Replaced
+	}
+	return change, nil
+}
+
+func (run *restoreRun) rows(cfg tableConfig, spec restoreTableSpec, f *zip.File, natural map[string]string, change *RestoreTableChange) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	decoder := json.NewDecoder(rc)
+	decoder.UseNumber()
+	if t, err := decoder.Token(); err != nil || t != json.Delim('[') {
+		return fmt.Errorf("invalid json format: expected %s", cfg.filename)
+	}
+
+	const batchSize = 1000
+	var batch []map[string]interface{}
+	for decoder.More() {
+		var row map[string]interface{}
+		if err := decoder.Decode(&row); err != nil {
+			return err
+		}
+		if run.taskIDs != nil && spec.taskField != "" && !run.taskIDs[columnString(row[spec.taskField])] {
+			continue
+		}
+		batch = append(batch, row)
+		if len(batch) >= batchSize {
+			if err := run.batch(cfg, spec, batch, natural, change); err != nil {
+				return err
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		return run.batch(cfg, spec, batch, natural, change)
+	}
+	return nil
+}
+
+func (run *restoreRun) batch(cfg tableConfig, spec restoreTableSpec, batch []map[string]interface{}, natural map[string]string, change *RestoreTableChange) error {
+	for _, row := range batch {
+		for _, field := range restoreRefFields {
+			if v, ok := row[field].(string); ok {
+				if mapped, ok := run.idMap[v]; ok {
+					row[field] = mapped
+				}
+			}
+		}
+	}
+
+	// 查询本批次中主键已存在的记录
+	ids := make([]string, 0, len(batch))
+	for _, row := range batch {
+		ids = append(ids, columnString(row["id"]))
+	}
+	cols := []string{"id"}
+	if spec.taskField != "" && spec.taskField != "id" {
+		cols = append(cols, spec.taskField)
+	}
+	var existingRows []map[string]interface{}
+	if err := run.tx.Model(cfg.model).Select(cols).Where("id IN ?", ids).Find(&existingRows).Error; err != nil {
+		return err
+	}
+	existing := make(map[string]map[string]interface{}, len(existingRows))
+	for _, row := range existingRows {
+		existing[columnString(row["id"])] = row
+	}
+
+	var inserts []map[string]interface{}
+	var deletes []string
+	for _, row := range batch {
+		id := columnString(row["id"])
+		change.Incoming++
+
+		conflictIDs := map[string]bool{}
+		naturalConflict := false
+		if ex, ok := existing[id]; ok {
+			if run.inScope(spec, ex) {
+				// replace 方式下范围内的同 ID 记录会被清空后重新写入
+				change.Replaced++
+				deletes = append(deletes, id)
+				inserts = append(inserts, row)
+				continue
+			}
+			conflictIDs[id] = true
+		}
+		var key string
+		if len(spec.natural) > 0 {
+			key = naturalKey(spec.natural, row)
+			if other, ok := natural[key]; ok {
+				conflictIDs[other] = true
+				naturalConflict = true
+			}
+		}
+
+		if len(conflictIDs) == 0 {
+			change.Added++
+			inserts = append(inserts, row)
+			if key != "" {
+				natural[key] = id
+			}
+			continue
+		}
+
+		change.Conflicts++
+		strategy := run.plan.Conflict
+		if strategy == RestoreConflictRename && !canRename(spec, naturalConflict) {
+			strategy = RestoreConflictSkip
+		}
+		switch strategy {
+		case RestoreConflictSkip:
+			change.Skipped++
+			// 自然键相同的记录沿用现有 ID，后续表中的引用随之指向现有记录
+			if other, ok := natural[key]; ok && key != "" && other != id {
+				run.idMap[id] = other
+			}
+		case RestoreConflictOverwrite:
+			change.Replaced++
+			for other := range conflictIDs {
+				deletes = append(deletes, other)
+			}
+			inserts = append(inserts, row)
+			if key != "" {
+				natural[key] = id
+			}
+		case RestoreConflictRename:
+			change.Renamed++
+			newID := utils.GenerateID()
+			run.idMap[id] = newID
+			row["id"] = newID
+			row[spec.rename] = run.renamed(spec, row, natural)
+			inserts = append(inserts, row)
+			if len(spec.natural) > 0 {
+				natural[naturalKey(spec.natural, row)] = newID
+			}
+		}
+	}
+
+	if !run.apply {
+		return nil
+	}
+	if len(deletes) > 0 {
+		if err := run.tx.Where("id IN ?", deletes).Delete(cfg.model).Error; err != nil {
+			return err
+		}
+	}
+	if len(inserts) == 0 {
+		return nil
+	}
+	return run.insert(cfg, inserts)
+}
+
+// canRename 判断另存能否消除冲突：主键冲突换新 ID 即可，自然键冲突需要改名字段属于自然键
+func canRename(spec restoreTableSpec, naturalConflict bool) bool {
+	if spec.rename == "" {
+		return false
+	}
+	return !naturalConflict || containsString(spec.natural, spec.rename)
+}
+
+func (run *restoreRun) renamed(spec restoreTableSpec, row map[string]interface{}, natural map[string]string) string {
+	base := columnString(row[spec.rename]) + restoreRenameSuffix
+	name := base
+	for i := 2; ; i++ {
+		row[spec.rename] = name
+		if len(spec.natural) == 0 {
+			return name
+		}
+		if _, taken := natural[naturalKey(spec.natural, row)]; !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// insert 将 JSON 记录转换为对应模型后批量写入，任务沿用旧版本数据的兼容处理
+func (run *restoreRun) insert(cfg tableConfig, rows []map[string]interface{}) error {
+	data, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	if cfg.filename == "tasks.json" {
+		var tasks []*models.Task
+		if err := json.Unmarshal(data, &tasks); err != nil {
+			return err
+		}
+		return run.s.insertTasksBatch(run.tx, tasks)
+	}
+	slice := reflect.New(reflect.SliceOf(reflect.TypeOf(cfg.model)))
+	if err := json.Unmarshal(data, slice.Interface()); err != nil {
+		return err
+	}
+	return run.tx.Select("*").CreateInBatches(slice.Elem().Interface(), len(rows)).Error
+}
+
+// scripts 恢复 scripts 文件夹，replace 方式下直接覆盖同名文件，不会删除现有文件
+func (run *restoreRun) scripts(r *zip.Reader, preview *RestorePreview) error {
+	scriptsDir := constant.ScriptsWorkDir
+	for _, f := range r.File {
+		rel, ok := strings.CutPrefix(f.Name, "scripts/")
+		if !ok || rel == "" || !safeArchivePath(rel) || !run.plan.includesScript(strings.TrimSuffix(rel, "/")) {
+			continue
+		}
+		dest := filepath.Join(scriptsDir, rel)
+		if f.FileInfo().IsDir() {
+			if run.apply {
+				os.MkdirAll(dest, 0755)
+			}
+			continue
+		}
+
+		if _, err := os.Stat(dest); err != nil {
+			preview.ScriptsAdded++
+		} else {
+			strategy := RestoreConflictOverwrite
+			if run.plan.Mode == RestoreModeMerge {
+				strategy = run.plan.Conflict
+			}
+			switch strategy {
+			case RestoreConflictSkip:
+				preview.ScriptsSkipped++
+				continue
+			case RestoreConflictRename:
+				preview.ScriptsRenamed++
+				dest = renamedPath(dest)
+			default:
+				preview.ScriptsUpdated++
+			}
+		}
+		if !run.apply {
+			continue
+		}
+		if err := extractZipFile(f, dest); err != nil {
+			return fmt.Errorf("恢复脚本 %s 失败: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// renamedPath 在扩展名前追加后缀，已存在时继续追加序号
+func renamedPath(p string) string {
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext) + restoreRenameSuffix
+	candidate := base + ext
+	for i := 2; ; i++ {
+		if _, err := os.Stat(candidate); err != nil {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s%d%s", base, i, ext)
+	}
+}
+
+func extractZipFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// RestoreTaskItem 备份中的任务，供选择性恢复时挑选
+type RestoreTaskItem struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Exists bool   `json:"exists"` // 当前是否存在同 ID 的任务
+}
+
+func listBackupTasks(tx *gorm.DB, f *zip.File) ([]RestoreTaskItem, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var items []RestoreTaskItem
+	decoder := json.NewDecoder(rc)
+	if t, err := decoder.Token(); err != nil || t != json.Delim('[') {
+		return nil, errors.New("不是 JSON 数组")
+	}
+	for decoder.More() {
+		var item RestoreTaskItem
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	var existing []string
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	for start := 0; start < len(ids); start += 1000 {
+		var chunk []string
+		tx.Model(&models.Task{}).Where("id IN ?", ids[start:min(start+1000, len(ids))]).Pluck("id", &chunk)
+		existing = append(existing, chunk...)
+	}
+	set := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		set[id] = true
+	}
+	for i := range items {
+		items[i].Exists = set[items[i].ID]
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+func naturalKey(fields []string, row map[string]interface{}) string {
+	var b bytes.Buffer
+	for i, field := range fields {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		b.WriteString(columnString(row[field]))
+	}
+	return b.String()
+}
+
+// columnString 统一 JSON 与数据库驱动返回的字段值
+func columnString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
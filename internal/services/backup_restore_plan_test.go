@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestorePlanNormalize(t *testing.T) {
+	tables := []string{"tasks", "envs", "task_logs"}
+
+	plan := &RestorePlan{Tables: []string{"envs"}, ScriptPaths: []string{" ql/jd/ ", "./a.js"}}
+	if err := plan.normalize(tables); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if plan.Mode != RestoreModeMerge || plan.Conflict != RestoreConflictSkip {
+		t.Errorf("defaults = %s/%s, want merge/skip", plan.Mode, plan.Conflict)
+	}
+	if plan.ScriptPaths[0] != "ql/jd" || plan.ScriptPaths[1] != "a.js" {
+		t.Errorf("script paths = %v", plan.ScriptPaths)
+	}
+	if !plan.includesTable("envs.json") || plan.includesTable("tasks.json") {
+		t.Error("only envs should be included")
+	}
+	if !plan.includesScript("ql/jd/a.py") || !plan.includesScript("a.js") || plan.includesScript("ql/jdx.py") {
+		t.Error("script path prefix matching is wrong")
+	}
+	if !(&RestorePlan{}).includesTable("tasks.json") || (&RestorePlan{Tables: []string{}}).includesTable("tasks.json") {
+		t.Error("nil tables should mean all, empty tables none")
+	}
+
+	for _, bad := range []*RestorePlan{
+		{Tables: []string{"nope"}},
+		{Mode: "append"},
+		{Conflict: "ignore"},
+		{ScriptPaths: []string{"/"}},
+	} {
+		if err := bad.normalize(tables); !errors.Is(err, ErrRestorePlan) {
+			t.Errorf("plan %+v: expected ErrRestorePlan, got %v", bad, err)
+		}
+	}
+}
+
+func TestCanRename(t *testing.T) {
+	cases := []struct {
+		spec    restoreTableSpec
+		natural bool
+		want    bool
+	}{
+		{restoreTableSpecs["tasks.json"], false, true},
+		{restoreTableSpecs["users.json"], true, true},
+		{restoreTableSpecs["settings.json"], true, false},
+		{restoreTableSpecs["task_logs.json"], false, false},
+		{restoreTableSpec{natural: []string{"token"}, rename: "name"}, true, false},
+		{restoreTableSpec{natural: []string{"token"}, rename: "name"}, false, true},
+	}
+	for i, c := range cases {
+		if got := canRename(c.spec, c.natural); got != c.want {
+			t.Errorf("case %d: canRename = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestRestoreRenamed(t *testing.T) {
+	run := &restoreRun{}
+	spec := restoreTableSpecs["users.json"]
+	natural := map[string]string{
+		naturalKey(spec.natural, map[string]interface{}{"username": "admin"}):          "a",
+		naturalKey(spec.natural, map[string]interface{}{"username": "admin_restored"}): "b",
+	}
+	row := map[string]interface{}{"username": "admin"}
+	if got := run.renamed(spec, row, natural); got != "admin_restored2" {
+		t.Errorf("renamed = %s, want admin_restored2", got)
+	}
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "a.js")
+	if got := renamedPath(dest); got != filepath.Join(dir, "a_restored.js") {
+		t.Errorf("renamedPath = %s", got)
+	}
+	os.WriteFile(filepath.Join(dir, "a_restored.js"), nil, 0644)
+	if got := renamedPath(dest); got != filepath.Join(dir, "a_restored2.js") {
+		t.Errorf("renamedPath = %s", got)
+	}
+}
+
+func TestColumnString(t *testing.T) {
+	if columnString([]byte("x")) != "x" || columnString(nil) != "" || columnString(int64(3)) != "3" {
+		t.Error("columnString should normalize driver values")
+	}
+	a := naturalKey([]string{"type", "name"}, map[string]interface{}{"type": "ab", "name": "c"})
+	b := naturalKey([]string{"type", "name"}, map[string]interface{}{"type": "a", "name": "bc"})
+	if a == b {
+		t.Error("natural keys must not collide across field boundaries")
+	}
+}
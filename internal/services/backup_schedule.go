@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/backupstore"
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/executor"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/systime"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// backupFilePrefix 定时备份的文件名前缀，轮转只处理带此前缀的文件
+	backupFilePrefix = "baihu_backup_"
+	// backupRunTimeout 单次上传或下载备份的超时时间
+	backupRunTimeout = 2 * time.Hour
+	// backupProbeTimeout 保存设置时探测上传目标的超时时间
+	backupProbeTimeout = 30 * time.Second
+)
+
+var (
+	backupScheduleMu    sync.Mutex
+	backupScheduleEntry cron.EntryID
+	backupRunning       atomic.Bool
+)
+
+// BackupRetention 备份轮转策略，三者取并集，全部为 0 时不清理
+type BackupRetention struct {
+	KeepLast   int // 保留最近 N 份
+	KeepDaily  int // 保留最近 N 天每天最新的一份
+	KeepWeekly int // 保留最近 N 周每周最新的一份
+}
+
+// BackupRunResult 一次定时备份的结果
+type BackupRunResult struct {
+	File    string   `json:"file"`
+	Size    int64    `json:"size"`
+	Target  string   `json:"target"`
+	Deleted []string `json:"deleted"` // 轮转删除的旧备份
+}
+
+// BackupTargetTest 备份目标连接测试的结果
+type BackupTargetTest struct {
+	Target  string `json:"target"`
+	HostKey string `json:"host_key,omitempty"` // SFTP 服务器当前的公钥指纹，供核对后填写
+	Files   int    `json:"files"`
+	Error   string `json:"error,omitempty"`
+}
+
+// buildBackupTarget 按设置创建备份上传目标
+func buildBackupTarget(cfg map[string]string) (backupstore.Target, error) {
+	switch cfg[constant.KeyBackupTarget] {
+	case constant.BackupTargetLocal, "":
+		dir := strings.TrimSpace(cfg[constant.KeyBackupLocalDir])
+		if dir == "" {
+			dir = BackupDir
+		}
+		return backupstore.NewLocalTarget(dir), nil
+	case constant.BackupTargetWebDAV:
+		return backupstore.NewWebDAVTarget(cfg[constant.KeyBackupWebDAVURL], strings.TrimSpace(cfg[constant.KeyBackupWebDAVUser]), cfg[constant.KeyBackupWebDAVPassword])
+	case constant.BackupTargetS3:
+		return backupstore.NewS3Target(backupstore.S3Config{
+			Endpoint:  cfg[constant.KeyBackupS3Endpoint],
+			Region:    strings.TrimSpace(cfg[constant.KeyBackupS3Region]),
+			Bucket:    cfg[constant.KeyBackupS3Bucket],
+			Prefix:    cfg[constant.KeyBackupS3Prefix],
+			AccessKey: strings.TrimSpace(cfg[constant.KeyBackupS3AccessKey]),
+			SecretKey: strings.TrimSpace(cfg[constant.KeyBackupS3SecretKey]),
+			PathStyle: cfg[constant.KeyBackupS3PathStyle] == "true",
+		})
+	case constant.BackupTargetSFTP:
+		return backupstore.NewSFTPTarget(backupstore.SFTPConfig{
+			Address:         cfg[constant.KeyBackupSFTPAddress],
+			Username:        strings.TrimSpace(cfg[constant.KeyBackupSFTPUser]),
+			Password:        cfg[constant.KeyBackupSFTPPassword],
+			PrivateKey:      cfg[constant.KeyBackupSFTPKey],
+			HostKey:         cfg[constant.KeyBackupSFTPHostKey],
+			InsecureHostKey: cfg[constant.KeyBackupSFTPInsecure] == "true",
+			Dir:             cfg[constant.KeyBackupSFTPDir],
+		})
+	}
+	return nil, fmt.Errorf("不支持的备份目标: %s", cfg[constant.KeyBackupTarget])
+}
+
+// backupCronSpec 将 5 位 cron 表达式补全为系统定时器使用的 6 位格式
+func backupCronSpec(expr string) (string, error) {
+	return sysCronSpec(expr, "定时备份")
+}
+
+// sysCronSpec 校验系统定时器的 cron 表达式，5 位时补全秒字段；label 用于错误提示
+func sysCronSpec(expr, label string) (string, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return "", fmt.Errorf("请填写%s的 cron 表达式", label)
+	}
+	if !strings.HasPrefix(expr, "@") && len(strings.Fields(expr)) == 5 {
+		expr = "0 " + expr
+	}
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	if _, err := parser.Parse(expr); err != nil {
+		return "", fmt.Errorf("%s的 cron 表达式无效: %v", label, err)
+	}
+	return expr, nil
+}
+
+func backupRetention(cfg map[string]string) BackupRetention {
+	return BackupRetention{
+		KeepLast:   max(utils.ToInt(cfg[constant.KeyBackupKeepLast], 0), 0),
+		KeepDaily:  max(utils.ToInt(cfg[constant.KeyBackupKeepDaily], 0), 0),
+		KeepWeekly: max(utils.ToInt(cfg[constant.KeyBackupKeepWeekly], 0), 0),
+	}
+}
+
+// backupExcludes 解析排除的数据，忽略不支持的名称
+func backupExcludes(cfg map[string]string) []string {
+	var list []string
+	for _, name := range SplitScopes(cfg[constant.KeyBackupExclude]) {
+		if _, ok := backupExcludeFiles[name]; ok {
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// ValidateBackupSettings 校验待保存的备份设置，开启定时备份时上传目标需能正常写入、列出与删除
+func ValidateBackupSettings(values map[string]string) error {
+	cfg := NewSettingsService().GetSection(constant.SectionBackup)
+	for k, v := range values {
+		cfg[k] = v
+	}
+	for _, key := range []string{constant.KeyBackupKeepLast, constant.KeyBackupKeepDaily, constant.KeyBackupKeepWeekly} {
+		if v := strings.TrimSpace(cfg[key]); v != "" && utils.ToInt(v, -1) < 0 {
+			return fmt.Errorf("保留份数必须为非负整数")
+		}
+	}
+	target, err := buildBackupTarget(cfg)
+	if err != nil {
+		return err
+	}
+	// 关闭定时备份时只校验配置格式，避免目标故障时无法保存关闭
+	if cfg[constant.KeyBackupScheduleEnable] != "true" {
+		return nil
+	}
+	if _, err := backupCronSpec(cfg[constant.KeyBackupScheduleCron]); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backupProbeTimeout)
+	defer cancel()
+	if err := backupstore.Probe(ctx, target); err != nil {
+		return fmt.Errorf("备份目标不可用: %v", err)
+	}
+	return nil
+}
+
+// TestBackupTarget 以当前设置叠加未保存的 values 测试备份目标能否列出文件
+// SFTP 目标会先取得服务器公钥指纹一并返回，便于在未填写指纹时核对
+func TestBackupTarget(values map[string]string) *BackupTargetTest {
+	cfg := NewSettingsService().GetSection(constant.SectionBackup)
+	for k, v := range values {
+		cfg[k] = v
+	}
+	result := &BackupTargetTest{Target: cfg[constant.KeyBackupTarget]}
+	ctx, cancel := context.WithTimeout(context.Background(), backupProbeTimeout)
+	defer cancel()
+
+	if result.Target == constant.BackupTargetSFTP {
+		hostKey, err := backupstore.SFTPHostKey(ctx, cfg[constant.KeyBackupSFTPAddress])
+		if err != nil {
+			result.Error = fmt.Sprintf("连接 SFTP 服务器失败: %v", err)
+			return result
+		}
+		result.HostKey = hostKey
+	}
+	target, err := buildBackupTarget(cfg)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	files, err := target.List(ctx)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Files = len(files)
+	return result
+}
+
+// ApplyBackupSchedule 按当前设置重新注册定时备份任务
+func ApplyBackupSchedule() error {
+	backupScheduleMu.Lock()
+	defer backupScheduleMu.Unlock()
+	if backupScheduleEntry != 0 {
+		executor.GetSysCron().RemoveJob(backupScheduleEntry)
+		backupScheduleEntry = 0
+	}
+
+	cfg := NewSettingsService().GetSection(constant.SectionBackup)
+	if cfg[constant.KeyBackupScheduleEnable] != "true" {
+		return nil
+	}
+	spec, err := backupCronSpec(cfg[constant.KeyBackupScheduleCron])
+	if err != nil {
+		return err
+	}
+	id, err := executor.GetSysCron().AddJob(spec, func() {
+		if _, err := NewBackupService().RunScheduledBackup(); err != nil {
+			logger.Errorf("[Backup] 定时备份失败: %v", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	backupScheduleEntry = id
+	return nil
+}
+
+// RunScheduledBackup 按备份设置生成备份、上传到目标并轮转旧备份，同一时间只运行一次
+func (s *BackupService) RunScheduledBackup() (*BackupRunResult, error) {
+	if !backupRunning.CompareAndSwap(false, true) {
+		return nil, errors.New("已有备份正在进行，请稍后")
+	}
+	defer backupRunning.Store(false)
+
+	cfg := s.settingsService.GetSection(constant.SectionBackup)
+	result, err := s.runBackup(cfg)
+
+	s.settingsService.Set(BackupSection, constant.KeyBackupLastRunAt, time.Now().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		s.settingsService.Set(BackupSection, constant.KeyBackupLastError, err.Error())
+		eventbus.DefaultBus.Publish(eventbus.Event{
+			Type: constant.EventSystemNotice,
+			Payload: map[string]interface{}{
+				"title":   "定时备份失败",
+				"content": err.Error(),
+				"level":   constant.LogLevelError,
+			},
+		})
+		return nil, err
+	}
+	s.settingsService.Set(BackupSection, constant.KeyBackupLastError, "")
+	s.settingsService.Set(BackupSection, constant.KeyBackupLastFile, result.File)
+	content := fmt.Sprintf("已上传 %s（%.1f MB）到 %s", result.File, float64(result.Size)/(1<<20), result.Target)
+	if len(result.Deleted) > 0 {
+		content += fmt.Sprintf("，轮转删除 %d 份旧备份", len(result.Deleted))
+	}
+	eventbus.DefaultBus.Publish(eventbus.Event{
+		Type: constant.EventSchedulerLog,
+		Payload: map[string]interface{}{
+			"title":   "定时备份完成",
+			"content": content,
+			"level":   constant.LogLevelInfo,
+		},
+	})
+	return result, nil
+}
+
+func (s *BackupService) runBackup(cfg map[string]string) (*BackupRunResult, error) {
+	target, err := buildBackupTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(BackupDir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := backupFilePrefix + systime.FormatDatetime(time.Now()) + ".zip"
+	tmpPath := filepath.Join(BackupDir, ".tmp-"+name)
+	defer os.Remove(tmpPath)
+	if err := s.writeArchive(tmpPath, backupExcludes(cfg), cfg[constant.KeyBackupPassphrase]); err != nil {
+		return nil, fmt.Errorf("生成备份失败: %v", err)
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backupRunTimeout)
+	defer cancel()
+	if err := target.Upload(ctx, name, f, info.Size()); err != nil {
+		return nil, fmt.Errorf("上传备份失败: %v", err)
+	}
+	result := &BackupRunResult{File: name, Size: info.Size(), Target: target.Name()}
+
+	// 轮转失败不影响本次备份结果，只记录日志
+	files, err := target.List(ctx)
+	if err != nil {
+		logger.Warnf("[Backup] 列出备份失败，跳过轮转: %v", err)
+		return result, nil
+	}
+	for _, old := range selectExpiredBackups(files, backupRetention(cfg), time.Now()) {
+		if err := target.Delete(ctx, old); err != nil {
+			logger.Warnf("[Backup] 删除旧备份 %s 失败: %v", old, err)
+			continue
+		}
+		result.Deleted = append(result.Deleted, old)
+	}
+	return result, nil
+}
+
+// backupFileTime 从文件名中解析备份时间，无法解析时使用文件修改时间
+func backupFileTime(f backupstore.RemoteFile) time.Time {
+	stamp := strings.TrimSuffix(strings.TrimPrefix(f.Name, backupFilePrefix), ".zip")
+	if t, err := time.ParseInLocation("20060102_150405", stamp, systime.CST); err == nil {
+		return t
+	}
+	return f.ModTime
+}
+
+// selectExpiredBackups 按轮转策略返回需要删除的定时备份，只处理带 backupFilePrefix 前缀的文件
+func selectExpiredBackups(files []backupstore.RemoteFile, policy BackupRetention, now time.Time) []string {
+	if policy.KeepLast <= 0 && policy.KeepDaily <= 0 && policy.KeepWeekly <= 0 {
+		return nil
+	}
+	type item struct {
+		name string
+		at   time.Time
+	}
+	var list []item
+	for _, f := range files {
+		if strings.HasPrefix(f.Name, backupFilePrefix) && strings.HasSuffix(f.Name, ".zip") {
+			list = append(list, item{f.Name, systime.InCST(backupFileTime(f))})
+		}
+	}
+	// 从新到旧排列，每个时间段内遇到的第一份即为该时间段最新的一份
+	sort.Slice(list, func(i, j int) bool { return list[i].at.After(list[j].at) })
+
+	keep := make(map[string]bool)
+	for i := 0; i < len(list) && i < policy.KeepLast; i++ {
+		keep[list[i].name] = true
+	}
+	now = systime.InCST(now)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, it := range list {
+		if policy.KeepDaily > 0 && !it.at.Before(today.AddDate(0, 0, -(policy.KeepDaily-1))) {
+			day := it.at.Format("2006-01-02")
+			if !days[day] {
+				days[day] = true
+				keep[it.name] = true
+			}
+		}
+		if policy.KeepWeekly > 0 && !it.at.Before(today.AddDate(0, 0, -7*policy.KeepWeekly)) {
+			year, week := it.at.ISOWeek()
+			key := fmt.Sprintf("%d-%d", year, week)
+			if !weeks[key] && len(weeks) < policy.KeepWeekly {
+				weeks[key] = true
+				keep[it.name] = true
+			}
+		}
+	}
+
+	var expired []string
+	for _, it := range list {
+		if !keep[it.name] {
+			expired = append(expired, it.name)
+		}
+	}
+	return expired
+}
+
+// ListRemoteBackups 列出备份目标中的全部 zip 文件，按时间从新到旧排列
+func (s *BackupService) ListRemoteBackups() ([]backupstore.RemoteFile, error) {
+	target, err := buildBackupTarget(s.settingsService.GetSection(constant.SectionBackup))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backupProbeTimeout)
+	defer cancel()
+	files, err := target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]backupstore.RemoteFile, 0, len(files))
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, ".zip") && !strings.HasPrefix(f.Name, ".") {
+			if f.ModTime.IsZero() {
+				f.ModTime = backupFileTime(f)
+			}
+			list = append(list, f)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return backupFileTime(list[i]).After(backupFileTime(list[j])) })
+	return list, nil
+}
+
+// RestoreRemote 从备份目标下载指定备份并恢复，未提供口令时使用定时备份设置中的口令
+func (s *BackupService) RestoreRemote(name, passphrase string, plan *RestorePlan, dryRun bool) (*RestorePreview, error) {
+	if err := backupstore.ValidName(name); err != nil {
+		return nil, err
+	}
+	cfg := s.settingsService.GetSection(constant.SectionBackup)
+	if passphrase == "" {
+		passphrase = cfg[constant.KeyBackupPassphrase]
+	}
+	target, err := buildBackupTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "baihu-restore-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	ctx, cancel := context.WithTimeout(context.Background(), backupRunTimeout)
+	defer cancel()
+	err = target.Download(ctx, name, tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("下载备份失败: %w", err)
+	}
+	if dryRun {
+		return s.PreviewRestore(tmp.Name(), passphrase, plan)
+	}
+	return nil, s.Restore(tmp.Name(), passphrase, plan)
+}
+
+// DeleteRemote 删除备份目标中的指定备份
+func (s *BackupService) DeleteRemote(name string) error {
+	if err := backupstore.ValidName(name); err != nil {
+		return err
+	}
+	target, err := buildBackupTarget(s.settingsService.GetSection(constant.SectionBackup))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backupProbeTimeout)
+	defer cancel()
+	return target.Delete(ctx, name)
+}
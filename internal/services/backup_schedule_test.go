@@ -0,0 +1,44 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/backupstore"
+	"github.com/engigu/baihu-panel/internal/systime"
+)
+
+func TestSelectExpiredBackups(t *testing.T) {
+	now := time.Date(2026, 3, 18, 12, 0, 0, 0, systime.CST)
+	var files []backupstore.RemoteFile
+	for i := 0; i < 20; i++ {
+		at := time.Date(2026, 3, 18-i, 3, 0, 0, 0, systime.CST)
+		files = append(files, backupstore.RemoteFile{Name: backupFilePrefix + systime.FormatDatetime(at) + ".zip"})
+	}
+	files = append(files,
+		backupstore.RemoteFile{Name: backupFilePrefix + "20260317_150000.zip"},
+		backupstore.RemoteFile{Name: "backup_20200101_000000.zip"},
+	)
+
+	expired := selectExpiredBackups(files, BackupRetention{KeepLast: 2, KeepDaily: 3, KeepWeekly: 2}, now)
+	if len(expired) != 17 {
+		t.Fatalf("expected 17 expired backups, got %d: %v", len(expired), expired)
+	}
+	sort.Strings(expired)
+	for _, kept := range []string{"20260318_030000", "20260317_150000", "20260316_030000", "20260315_030000"} {
+		if i := sort.SearchStrings(expired, backupFilePrefix+kept+".zip"); i < len(expired) && expired[i] == backupFilePrefix+kept+".zip" {
+			t.Errorf("%s should be kept", kept)
+		}
+	}
+	for _, name := range expired {
+		if !strings.HasPrefix(name, backupFilePrefix) {
+			t.Errorf("manual backup %s should never be rotated", name)
+		}
+	}
+
+	if got := selectExpiredBackups(files, BackupRetention{}, now); got != nil {
+		t.Errorf("empty policy should keep everything, got %v", got)
+	}
+}
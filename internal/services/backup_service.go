@@ -11,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/engigu/baihu-panel/internal/backupstore"
 	"github.com/engigu/baihu-panel/internal/cache"
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/database"
@@ -31,37 +32,44 @@ func NewBackupService() *BackupService {
 }
 
 const (
-	BackupSection = "backup"
-	BackupFileKey = "backup_file"
+	BackupSection = constant.SectionBackup
+	BackupFileKey = constant.KeyBackupFile
 	BackupDir     = "./data/backups"
 )
 
+// backupExcludeFiles 可排除的数据对应的备份文件
+var backupExcludeFiles = map[string]string{
+	constant.BackupExcludeTaskLogs: "task_logs.json",
+	constant.BackupExcludeAppLogs:  "app_logs.json",
+}
+
 // tableConfig 表备份配置
 type tableConfig struct {
 	filename string
 	export   func(io.Writer) error
 	restore  func([]byte) error
+	model    any // 恢复时用于查询与写入现有记录
 }
 
 func (s *BackupService) getTableConfigs() []tableConfig {
 	return []tableConfig{
-		{"users.json", s.exportTable(&[]models.User{}), s.restoreTable(&[]models.User{})},
-		{"tasks.json", s.exportTable(&[]models.Task{}), s.restoreTable(&[]models.Task{})},
-		{"task_logs.json", s.exportTable(&[]models.TaskLog{}), s.restoreTable(&[]models.TaskLog{})},
-		{"envs.json", s.exportTable(&[]models.EnvironmentVariable{}), s.restoreTable(&[]models.EnvironmentVariable{})},
-		{"scripts.json", s.exportTable(&[]models.Script{}), s.restoreTable(&[]models.Script{})},
-		{"settings.json", s.exportSettings, s.restoreSettings},
-		{"send_stats.json", s.exportTable(&[]models.SendStats{}), s.restoreTable(&[]models.SendStats{})},
-
-		{"agents.json", s.exportTable(&[]models.Agent{}), s.restoreTable(&[]models.Agent{})},
-		{"tokens.json", s.exportTable(&[]models.AgentToken{}), s.restoreTable(&[]models.AgentToken{})},
-		{"languages.json", s.exportTable(&[]models.Language{}), s.restoreTable(&[]models.Language{})},
-		{"deps.json", s.exportTable(&[]models.Dependency{}), s.restoreTable(&[]models.Dependency{})},
-		{"notify_ways.json", s.exportTable(&[]models.NotifyWay{}), s.restoreTable(&[]models.NotifyWay{})},
-		{"notify_bindings.json", s.exportTable(&[]models.NotifyBinding{}), s.restoreTable(&[]models.NotifyBinding{})},
-		{"app_logs.json", s.exportTable(&[]models.AppLog{}), s.restoreTable(&[]models.AppLog{})},
-		{"data_storage.json", s.exportTable(&[]models.DataStorage{}), s.restoreTable(&[]models.DataStorage{})},
-		{"data_relations.json", s.exportTable(&[]models.DataRelation{}), s.restoreTable(&[]models.DataRelation{})},
+		// 被引用的表排在前面，选择性恢复时先确定它们的 ID 映射
+		{"users.json", s.exportTable(&[]models.User{}), s.restoreTable(&[]models.User{}), &models.User{}},
+		{"agents.json", s.exportTable(&[]models.Agent{}), s.restoreTable(&[]models.Agent{}), &models.Agent{}},
+		{"tokens.json", s.exportTable(&[]models.AgentToken{}), s.restoreTable(&[]models.AgentToken{}), &models.AgentToken{}},
+		{"languages.json", s.exportTable(&[]models.Language{}), s.restoreTable(&[]models.Language{}), &models.Language{}},
+		{"deps.json", s.exportTable(&[]models.Dependency{}), s.restoreTable(&[]models.Dependency{}), &models.Dependency{}},
+		{"notify_ways.json", s.exportTable(&[]models.NotifyWay{}), s.restoreTable(&[]models.NotifyWay{}), &models.NotifyWay{}},
+		{"tasks.json", s.exportTable(&[]models.Task{}), s.restoreTable(&[]models.Task{}), &models.Task{}},
+		{"task_logs.json", s.exportTable(&[]models.TaskLog{}), s.restoreTable(&[]models.TaskLog{}), &models.TaskLog{}},
+		{"envs.json", s.exportTable(&[]models.EnvironmentVariable{}), s.restoreTable(&[]models.EnvironmentVariable{}), &models.EnvironmentVariable{}},
+		{"scripts.json", s.exportTable(&[]models.Script{}), s.restoreTable(&[]models.Script{}), &models.Script{}},
+		{"settings.json", s.exportSettings, s.restoreSettings, &models.Setting{}},
+		{"send_stats.json", s.exportTable(&[]models.SendStats{}), s.restoreTable(&[]models.SendStats{}), &models.SendStats{}},
+		{"notify_bindings.json", s.exportTable(&[]models.NotifyBinding{}), s.restoreTable(&[]models.NotifyBinding{}), &models.NotifyBinding{}},
+		{"app_logs.json", s.exportTable(&[]models.AppLog{}), s.restoreTable(&[]models.AppLog{}), &models.AppLog{}},
+		{"data_storage.json", s.exportTable(&[]models.DataStorage{}), s.restoreTable(&[]models.DataStorage{}), &models.DataStorage{}},
+		{"data_relations.json", s.exportTable(&[]models.DataRelation{}), s.restoreTable(&[]models.DataRelation{}), &models.DataRelation{}},
 	}
 }
 
@@ -132,128 +140,135 @@ func (s *BackupService) restoreSettings(data []byte) error {
 	return json.Unmarshal(data, &settings)
 }
 
-// CreateBackup 创建备份
-func (s *BackupService) CreateBackup() (string, error) {
+// CreateBackup 创建备份，passphrase 非空时加密整个备份包
+func (s *BackupService) CreateBackup(passphrase string) (string, error) {
 	if err := os.MkdirAll(BackupDir, 0755); err != nil {
 		return "", err
 	}
 
 	timestamp := systime.FormatDatetime(time.Now())
 	zipPath := filepath.Join(BackupDir, fmt.Sprintf("backup_%s.zip", timestamp))
+	if err := s.writeArchive(zipPath, nil, passphrase); err != nil {
+		os.Remove(zipPath)
+		return "", err
+	}
+
+	s.settingsService.Set(BackupSection, BackupFileKey, zipPath)
+	return zipPath, nil
+}
+
+// writeArchive 将各表数据与 scripts 文件夹打包到 zipPath，exclude 中的数据不导出，
+// 包内附带记录每个文件 SHA-256 的清单，passphrase 非空时整个备份包加密后写出
+func (s *BackupService) writeArchive(zipPath string, exclude []string, passphrase string) error {
+	skip := make(map[string]bool)
+	for _, name := range exclude {
+		if file, ok := backupExcludeFiles[name]; ok {
+			skip[file] = true
+		}
+	}
 
 	zipFile, err := os.Create(zipPath)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer zipFile.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	var out io.Writer = zipFile
+	var encWriter io.WriteCloser
+	if passphrase != "" {
+		if encWriter, err = backupstore.Encrypt(zipFile, passphrase); err != nil {
+			return err
+		}
+		out = encWriter
+	}
+	zipWriter := newManifestZip(out)
+	defer zipWriter.zw.Close()
 
 	// 导出各表
 	for _, cfg := range s.getTableConfigs() {
+		if skip[cfg.filename] {
+			continue
+		}
 		w, err := zipWriter.Create(cfg.filename)
 		if err != nil {
-			return "", err
+			return err
 		}
 		if err := cfg.export(w); err != nil {
-			return "", err
+			return err
 		}
 	}
 
-	// 写入元数据信息
+	// 写入元数据信息，excluded 记录未导出的数据，恢复时保留现有记录
 	sysInfo := map[string]interface{}{
 		"version": "v3",
 		"ts":      time.Now().Format("2006-01-02 15:04:05"),
 	}
+	if len(exclude) > 0 {
+		sysInfo["excluded"] = exclude
+	}
 	sysFile, err := zipWriter.Create("__sys__.json")
 	if err != nil {
-		return "", err
+		return err
 	}
 	sysData, _ := json.MarshalIndent(sysInfo, "", "  ")
 	if _, err := sysFile.Write(sysData); err != nil {
-		return "", err
+		return err
 	}
 
 	// 打包 scripts 文件夹
 	scriptsDir := constant.ScriptsWorkDir
 	if _, err := os.Stat(scriptsDir); err == nil {
 		if err := s.addDirToZip(zipWriter, scriptsDir, "scripts"); err != nil {
-			return "", err
+			return err
 		}
 	}
 
-	s.settingsService.Set(BackupSection, BackupFileKey, zipPath)
-	return zipPath, nil
+	if err := zipWriter.Close(); err != nil {
+		return err
+	}
+	if encWriter != nil {
+		if err := encWriter.Close(); err != nil {
+			return err
+		}
+	}
+	return zipFile.Close()
 }
 
-// Restore 恢复备份
-func (s *BackupService) Restore(zipPath string) error {
-	r, err := zip.OpenReader(zipPath)
+// Restore 按恢复方案恢复备份，plan 为 nil 时清空全部数据后全量恢复。
+// 加密的备份需提供 passphrase，带清单的备份先校验完整性再写入
+func (s *BackupService) Restore(zipPath, passphrase string, plan *RestorePlan) error {
+	if plan == nil {
+		plan = FullRestorePlan()
+	}
+	if err := plan.normalize(s.RestoreTableNames()); err != nil {
+		return err
+	}
+	archive, err := openBackupArchive(zipPath, passphrase)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
+	defer archive.Close()
+	r := archive.Reader
 
-	// 构建文件名到配置的映射
-	configs := s.getTableConfigs()
 	fileMap := make(map[string]*zip.File)
 	for _, f := range r.File {
 		fileMap[f.Name] = f
 	}
 
+	if _, err := verifyBackupArchive(r); err != nil {
+		return err
+	}
+
 	// 校验版本
-	if f, ok := fileMap["__sys__.json"]; ok {
-		rc, err := f.Open()
-		if err == nil {
-			var sysInfo map[string]interface{}
-			json.NewDecoder(rc).Decode(&sysInfo)
-			rc.Close()
-			if v, ok := sysInfo["version"]; ok {
-				vs, _ := v.(string)
-				if vs < "v3" {
-					return fmt.Errorf("只能数据随版本升级上来，当前备份版本为 %s，限制 v3 以下的不能导入", vs)
-				}
-			}
-		}
-		// } else {
-		// 	return fmt.Errorf("非法备份包：缺失版本标记")
+	sysInfo := readBackupSysInfo(fileMap)
+	if sysInfo.Version != "" && sysInfo.Version < "v3" {
+		return fmt.Errorf("只能数据随版本升级上来，当前备份版本为 %s，限制 v3 以下的不能导入", sysInfo.Version)
 	}
 
-	// 开启全局事务
+	// 开启全局事务，备份时排除的数据保留现有记录
 	err = database.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. 清空现有数据（物理删除）
-		tx.Where("1=1").Delete(&models.User{})
-		tx.Where("1=1").Delete(&models.Task{})
-		tx.Where("1=1").Delete(&models.TaskLog{})
-		tx.Where("1=1").Delete(&models.EnvironmentVariable{})
-		tx.Where("1=1").Delete(&models.Script{})
-		tx.Where("section != ?", BackupSection).Delete(&models.Setting{})
-		tx.Where("1=1").Delete(&models.SendStats{})
-
-		tx.Where("1=1").Delete(&models.Agent{})
-		tx.Where("1=1").Delete(&models.AgentToken{})
-		tx.Where("1=1").Delete(&models.Language{})
-		tx.Where("1=1").Delete(&models.Dependency{})
-		tx.Where("1=1").Delete(&models.NotifyWay{})
-		tx.Where("1=1").Delete(&models.NotifyBinding{})
-		tx.Where("1=1").Delete(&models.AppLog{})
-		tx.Where("1=1").Delete(&models.DataStorage{})
-		tx.Where("1=1").Delete(&models.DataRelation{})
-
-		// 2. 依次恢复每个表
-		for _, cfg := range configs {
-			if f, ok := fileMap[cfg.filename]; ok {
-				if err := s.restoreFromZipFile(tx, f, cfg.filename); err != nil {
-					return err
-				}
-			}
-		}
-
-		// 3. 恢复 scripts 文件夹
-		s.restoreScriptsDir(r)
-
-		return nil
+		_, err := s.runRestorePlan(tx, r, sysInfo, plan, true)
+		return err
 	})
 
 	if err == nil {
@@ -265,122 +280,6 @@ func (s *BackupService) Restore(zipPath string) error {
 	return err
 }
 
-func restoreStreamBatch[T any](tx *gorm.DB, decoder *json.Decoder) error {
-	batchSize := 1000
-	var batch []*T
-
-	for decoder.More() {
-		var m T
-		if err := decoder.Decode(&m); err != nil {
-			return err
-		}
-		batch = append(batch, &m)
-
-		if len(batch) >= batchSize {
-			if err := tx.Select("*").CreateInBatches(batch, batchSize).Error; err != nil {
-				return err
-			}
-			batch = nil // reset batch
-		}
-	}
-
-	if len(batch) > 0 {
-		return tx.Select("*").CreateInBatches(batch, len(batch)).Error
-	}
-
-	return nil
-}
-
-func (s *BackupService) restoreFromZipFile(tx *gorm.DB, f *zip.File, filename string) error {
-	rc, err := f.Open()
-	if err != nil {
-		return err
-	}
-	defer rc.Close()
-
-	// 特殊处理设置表（设置表通常很小，直接反序列化）
-	if filename == "settings.json" {
-		data, _ := io.ReadAll(rc)
-		var settings []models.Setting
-		if err := json.Unmarshal(data, &settings); err == nil {
-			if len(settings) > 0 {
-				return tx.Select("*").Create(&settings).Error
-			}
-		}
-		return nil
-	}
-
-	// 流式解析 JSON 数组
-	decoder := json.NewDecoder(rc)
-
-	// 找到数组开始 [
-	if t, err := decoder.Token(); err != nil || t != json.Delim('[') {
-		return fmt.Errorf("invalid json format: expected %s", filename)
-	}
-
-	switch filename {
-	case "users.json":
-		return restoreStreamBatch[models.User](tx, decoder)
-	case "tasks.json":
-		return s.restoreTasks(tx, decoder)
-	case "task_logs.json":
-		return restoreStreamBatch[models.TaskLog](tx, decoder)
-	case "envs.json":
-		return restoreStreamBatch[models.EnvironmentVariable](tx, decoder)
-	case "scripts.json":
-		return restoreStreamBatch[models.Script](tx, decoder)
-	case "send_stats.json":
-		return restoreStreamBatch[models.SendStats](tx, decoder)
-
-	case "agents.json":
-		return restoreStreamBatch[models.Agent](tx, decoder)
-	case "tokens.json":
-		return restoreStreamBatch[models.AgentToken](tx, decoder)
-	case "languages.json":
-		return restoreStreamBatch[models.Language](tx, decoder)
-	case "deps.json":
-		return restoreStreamBatch[models.Dependency](tx, decoder)
-	case "notify_ways.json":
-		return restoreStreamBatch[models.NotifyWay](tx, decoder)
-	case "notify_bindings.json":
-		return restoreStreamBatch[models.NotifyBinding](tx, decoder)
-	case "app_logs.json":
-		return restoreStreamBatch[models.AppLog](tx, decoder)
-	case "data_storage.json":
-		return restoreStreamBatch[models.DataStorage](tx, decoder)
-	case "data_relations.json":
-		return restoreStreamBatch[models.DataRelation](tx, decoder)
-	default:
-		return nil
-	}
-}
-
-func (s *BackupService) restoreTasks(tx *gorm.DB, decoder *json.Decoder) error {
-	batchSize := 1000
-	var batch []*models.Task
-
-	for decoder.More() {
-		var m models.Task
-		if err := decoder.Decode(&m); err != nil {
-			return err
-		}
-		batch = append(batch, &m)
-
-		if len(batch) >= batchSize {
-			if err := s.insertTasksBatch(tx, batch); err != nil {
-				return err
-			}
-			batch = nil // reset
-		}
-	}
-
-	if len(batch) > 0 {
-		return s.insertTasksBatch(tx, batch)
-	}
-
-	return nil
-}
-
 func (s *BackupService) insertTasksBatch(tx *gorm.DB, batch []*models.Task) error {
 	if err := tx.Select("*").CreateInBatches(batch, len(batch)).Error; err != nil {
 		return err
@@ -453,34 +352,7 @@ func (s *BackupService) insertTasksBatch(tx *gorm.DB, batch []*models.Task) erro
 	return nil
 }
 
-// insertRecords, restoreFromData 方法已合并入 restoreFromZipFile，此处删除冗余方法
-
-func (s *BackupService) restoreScriptsDir(r *zip.ReadCloser) {
-	scriptsDir := constant.ScriptsWorkDir
-	for _, f := range r.File {
-		if len(f.Name) > 8 && f.Name[:8] == "scripts/" {
-			relPath := f.Name[8:]
-			if relPath == "" {
-				continue
-			}
-			fpath := filepath.Join(scriptsDir, relPath)
-			if f.FileInfo().IsDir() {
-				os.MkdirAll(fpath, 0755)
-				continue
-			}
-			os.MkdirAll(filepath.Dir(fpath), 0755)
-			if outFile, err := os.Create(fpath); err == nil {
-				if rc, err := f.Open(); err == nil {
-					io.Copy(outFile, rc)
-					rc.Close()
-				}
-				outFile.Close()
-			}
-		}
-	}
-}
-
-func (s *BackupService) addDirToZip(zipWriter *zip.Writer, srcDir, prefix string) error {
+func (s *BackupService) addDirToZip(zipWriter *manifestZip, srcDir, prefix string) error {
 	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -0,0 +1,615 @@
+package services
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/executor"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/sdk/message"
+	"github.com/engigu/baihu-panel/internal/sdk/messenger"
+)
+
+// 渠道配置中与指令交互相关的键
+const (
+	ChatOpsKeyEnabled           = "chatops_enabled"            // 是否接收指令：true/1
+	ChatOpsKeyAllow             = "chatops_allow"              // 允许下发指令的会话/用户 ID，逗号分隔
+	ChatOpsKeyMode              = "chatops_mode"               // Telegram 接收方式：poll（默认）/ webhook
+	ChatOpsKeySecret            = "chatops_secret"             // Telegram Webhook 的 secret_token，webhook 模式必填
+	ChatOpsKeyTailLines         = "chatops_tail_lines"         // 回复中附带的日志尾部行数
+	ChatOpsKeyVerificationToken = "chatops_verification_token" // 飞书事件订阅的 Verification Token，开启指令时必填
+
+	chatOpsModeWebhook       = "webhook"
+	chatOpsDefaultTailLines  = 20
+	chatOpsMaxReplyRunes     = 3500
+	chatOpsPollTimeout       = 20
+	chatOpsSyncInterval      = 30 * time.Second
+	chatOpsPendingExpiration = 24 * time.Hour
+)
+
+// ChatOpsExecutor 指令需要调用的执行器能力
+type ChatOpsExecutor interface {
+	ExecuteTask(taskID string, extraEnvs []string) *executor.ExecutionResult
+	StopTaskExecution(logID string) error
+	GetRunningCount() int
+}
+
+// chatReply 向发起指令的会话回复消息
+type chatReply func(text string) error
+
+type chatOpsPending struct {
+	reply     chatReply
+	tailLines int
+	createdAt time.Time
+}
+
+type telegramPoller struct {
+	signature string
+	stop      chan struct{}
+}
+
+// ChatOpsService 通过 Telegram / 飞书机器人接收指令，映射为任务的执行与停止
+type ChatOpsService struct {
+	executor      ChatOpsExecutor
+	notifyService *NotificationService
+
+	mu      sync.Mutex
+	pending map[string][]chatOpsPending // logID -> 等待执行结果的会话
+	pollers map[string]*telegramPoller  // channelID -> 长轮询协程
+	events  map[string]time.Time        // 已处理的飞书事件 ID，用于去重
+}
+
+func NewChatOpsService(exec ChatOpsExecutor) *ChatOpsService {
+	return &ChatOpsService{
+		executor:      exec,
+		notifyService: NewNotificationService(),
+		pending:       make(map[string][]chatOpsPending),
+		pollers:       make(map[string]*telegramPoller),
+		events:        make(map[string]time.Time),
+	}
+}
+
+// SubscribeEvents 订阅任务结束事件，将执行结果回复给发起 /run 的会话
+func (s *ChatOpsService) SubscribeEvents(bus *eventbus.EventBus) {
+	for _, evt := range []string{constant.EventTaskSuccess, constant.EventTaskFailed, constant.EventTaskTimeout, constant.EventTaskCancelled} {
+		bus.Subscribe(evt, s.handleTaskFinished)
+	}
+}
+
+// Start 启动 Telegram 长轮询，并定期与渠道配置同步（渠道新增、修改、删除后自动生效）
+func (s *ChatOpsService) Start() {
+	executor.GetSysCron().AddJobWithRun(fmt.Sprintf("@every %s", chatOpsSyncInterval), s.syncPollers)
+}
+
+// chatOpsEnabled 判断渠道是否开启了指令接收
+func chatOpsEnabled(channel NotifyChannel) bool {
+	if !channel.Enabled {
+		return false
+	}
+	v := strings.ToLower(channel.Config[ChatOpsKeyEnabled])
+	return v == "true" || v == "1"
+}
+
+// chatOpsAllowed 判断任一 ID 是否在白名单中；白名单为空时拒绝所有指令
+func chatOpsAllowed(channel NotifyChannel, ids ...string) bool {
+	for _, allowed := range strings.Split(channel.Config[ChatOpsKeyAllow], ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "" {
+			continue
+		}
+		for _, id := range ids {
+			if id != "" && id == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func chatOpsTailLines(channel NotifyChannel) int {
+	if n, err := strconv.Atoi(channel.Config[ChatOpsKeyTailLines]); err == nil && n >= 0 {
+		return n
+	}
+	return chatOpsDefaultTailLines
+}
+
+// ValidateChatOpsConfig 校验渠道的指令接收配置：回调接口公开可达，必须配置用于鉴权的凭据
+func ValidateChatOpsConfig(channel NotifyChannel) error {
+	v := strings.ToLower(channel.Config[ChatOpsKeyEnabled])
+	if v != "true" && v != "1" {
+		return nil
+	}
+	switch channel.Type {
+	case messenger.ChannelTelegram:
+		if channel.Config[ChatOpsKeyMode] == chatOpsModeWebhook && strings.TrimSpace(channel.Config[ChatOpsKeySecret]) == "" {
+			return fmt.Errorf("Webhook 接收方式必须配置 Webhook Secret")
+		}
+	case messenger.ChannelFeishu:
+		if strings.TrimSpace(channel.Config[ChatOpsKeyVerificationToken]) == "" {
+			return fmt.Errorf("接收指令必须配置事件订阅的 Verification Token")
+		}
+	}
+	return nil
+}
+
+// chatOpsTokenEqual 以固定时间比较回调凭据，未配置凭据时一律拒绝
+func chatOpsTokenEqual(expected, actual string) bool {
+	expected = strings.TrimSpace(expected)
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+// getChatOpsChannel 获取已开启指令接收的渠道
+func (s *ChatOpsService) getChatOpsChannel(channelID, channelType string) (NotifyChannel, error) {
+	for _, ch := range s.notifyService.GetChannels() {
+		if ch.ID != channelID {
+			continue
+		}
+		if ch.Type != channelType || !chatOpsEnabled(ch) {
+			break
+		}
+		return ch, nil
+	}
+	return NotifyChannel{}, fmt.Errorf("渠道不存在或未开启指令接收")
+}
+
+// --- 指令处理 ---
+
+// parseChatCommand 解析 /cmd@bot arg1 arg2 形式的指令，非指令消息返回空命令
+func parseChatCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	// 飞书群聊中 @机器人 会以 @_user_N 占位符出现在消息开头
+	for len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+	cmd := strings.ToLower(strings.TrimPrefix(fields[0], "/"))
+	if i := strings.Index(cmd, "@"); i >= 0 {
+		cmd = cmd[:i]
+	}
+	return cmd, fields[1:]
+}
+
+// HandleCommand 执行一条指令并返回即时回复内容，reply 用于任务结束后的异步回复
+func (s *ChatOpsService) HandleCommand(text string, tailLines int, reply chatReply) string {
+	cmd, args := parseChatCommand(text)
+	switch cmd {
+	case "":
+		return ""
+	case "run":
+		if len(args) == 0 {
+			return "用法：/run <任务ID或名称>"
+		}
+		return s.cmdRun(strings.Join(args, " "), tailLines, reply)
+	case "stop":
+		if len(args) == 0 {
+			return "用法：/stop <日志ID>"
+		}
+		if err := s.executor.StopTaskExecution(args[0]); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("已发送停止指令 (LogID: %s)", args[0])
+	case "status":
+		return s.cmdStatus()
+	case "last":
+		if len(args) == 0 {
+			return "用法：/last <任务ID或名称>"
+		}
+		return s.cmdLast(strings.Join(args, " "), tailLines)
+	case "help", "start":
+		return "可用指令：\n/run <任务> 立即执行任务\n/stop <日志ID> 停止执行\n/status 查看运行中的任务\n/last <任务> 查看最近一次执行结果"
+	default:
+		return fmt.Sprintf("未知指令：/%s，发送 /help 查看帮助", cmd)
+	}
+}
+
+// findTask 按 ID 或名称查找任务
+func findTask(key string) (*models.Task, error) {
+	var task models.Task
+	if res := database.DB.Where("id = ?", key).Limit(1).Find(&task); res.Error == nil && res.RowsAffected > 0 {
+		return &task, nil
+	}
+
+	var tasks []models.Task
+	database.DB.Where("name = ?", key).Limit(2).Find(&tasks)
+	switch len(tasks) {
+	case 0:
+		return nil, fmt.Errorf("任务不存在：%s", key)
+	case 1:
+		return &tasks[0], nil
+	default:
+		return nil, fmt.Errorf("存在多个名为 %s 的任务，请使用任务 ID", key)
+	}
+}
+
+func (s *ChatOpsService) cmdRun(key string, tailLines int, reply chatReply) string {
+	task, err := findTask(key)
+	if err != nil {
+		return err.Error()
+	}
+
+	result := s.executor.ExecuteTask(task.ID, nil)
+	if !result.Success {
+		return fmt.Sprintf("任务 %s 执行失败：%s", task.Name, result.Error)
+	}
+
+	if reply != nil && result.LogID != "" {
+		s.mu.Lock()
+		now := time.Now()
+		// 顺带清理长时间未收到结果的等待记录（如任务被删除）
+		for id, list := range s.pending {
+			kept := list[:0]
+			for _, p := range list {
+				if now.Sub(p.createdAt) < chatOpsPendingExpiration {
+					kept = append(kept, p)
+				}
+			}
+			if len(kept) == 0 {
+				delete(s.pending, id)
+			} else {
+				s.pending[id] = kept
+			}
+		}
+		// 按本次执行的日志 ID 等待结果，同一任务的其他执行不会被当作回复
+		s.pending[result.LogID] = append(s.pending[result.LogID], chatOpsPending{reply: reply, tailLines: tailLines, createdAt: now})
+		s.mu.Unlock()
+	}
+	return fmt.Sprintf("任务 %s 已加入执行队列（日志ID：%s），完成后将回复执行结果", task.Name, result.LogID)
+}
+
+func (s *ChatOpsService) cmdStatus() string {
+	var logs []models.TaskLog
+	database.DB.Select("id", "task_id", "start_time").Where("status = ?", constant.TaskStatusRunning).Order("id DESC").Limit(10).Find(&logs)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "运行中的任务：%d", s.executor.GetRunningCount())
+	for _, l := range logs {
+		var name string
+		database.DB.Model(&models.Task{}).Where("id = ?", l.TaskID).Limit(1).Pluck("name", &name)
+		started := ""
+		if l.StartTime != nil {
+			started = l.StartTime.Time().Format(models.TimeFormat)
+		}
+		fmt.Fprintf(&sb, "\n- %s (LogID: %s) 开始于 %s", name, l.ID, started)
+	}
+	return sb.String()
+}
+
+func (s *ChatOpsService) cmdLast(key string, tailLines int) string {
+	task, err := findTask(key)
+	if err != nil {
+		return err.Error()
+	}
+
+	var taskLog models.TaskLog
+	res := database.DB.Where("task_id = ? AND status <> ?", task.ID, constant.TaskStatusRunning).Order("id DESC").Limit(1).Find(&taskLog)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return fmt.Sprintf("任务 %s 暂无执行记录", task.Name)
+	}
+
+	output, _ := logstore.ReadOutput(&taskLog)
+	startTime := ""
+	if taskLog.StartTime != nil {
+		startTime = taskLog.StartTime.Time().Format(models.TimeFormat)
+	}
+	return formatChatResult(task.Name, taskLog.ID, taskLog.Status, startTime, taskLog.Duration, output, string(taskLog.Error), tailLines)
+}
+
+// formatChatResult 格式化执行结果与日志尾部，并限制总长度以适配 IM 的单条消息上限
+func formatChatResult(taskName, logID, status, startTime string, duration int64, output, errMsg string, tailLines int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "任务：%s\n状态：%s\n日志ID：%s", taskName, status, logID)
+	if startTime != "" {
+		fmt.Fprintf(&sb, "\n开始时间：%s", startTime)
+	}
+	fmt.Fprintf(&sb, "\n耗时：%s", tmplDuration(duration))
+	if errMsg != "" {
+		fmt.Fprintf(&sb, "\n错误：%s", errMsg)
+	}
+	if tail := tmplLastLines(tailLines, stripAnsi(output)); tailLines > 0 && tail != "" {
+		sb.WriteString("\n\n日志尾部：\n")
+		sb.WriteString(tail)
+	}
+	text := sb.String()
+	if runes := []rune(text); len(runes) > chatOpsMaxReplyRunes {
+		text = "...\n" + string(runes[len(runes)-chatOpsMaxReplyRunes:])
+	}
+	return text
+}
+
+// handleTaskFinished 任务结束后回复等待结果的会话
+func (s *ChatOpsService) handleTaskFinished(event eventbus.Event) {
+	payload, ok := event.Payload.(map[string]interface{})
+	if !ok {
+		return
+	}
+	logID, _ := payload["log_id"].(string)
+	if logID == "" {
+		return
+	}
+
+	s.mu.Lock()
+	waiting := s.pending[logID]
+	delete(s.pending, logID)
+	s.mu.Unlock()
+
+	if len(waiting) == 0 {
+		return
+	}
+
+	str := func(key string) string {
+		v, _ := payload[key].(string)
+		return v
+	}
+	duration, _ := payload["duration"].(int64)
+	for _, p := range waiting {
+		text := formatChatResult(str("task_name"), str("log_id"), str("status"), str("start_time"), duration, str("output"), str("error"), p.tailLines)
+		if err := p.reply(text); err != nil {
+			logger.Warnf("[ChatOps] 回复执行 %s 的结果失败: %v", logID, err)
+		}
+	}
+}
+
+// --- Telegram ---
+
+func newTelegramClient(channel NotifyChannel, chatID string) *message.Telegram {
+	return &message.Telegram{
+		BotToken: channel.Config["bot_token"],
+		ChatID:   chatID,
+		ApiHost:  channel.Config["api_host"],
+		ProxyURL: channel.Config["proxy_url"],
+	}
+}
+
+// handleTelegramUpdate 处理一条 Telegram 更新
+func (s *ChatOpsService) handleTelegramUpdate(channel NotifyChannel, update message.TelegramUpdate) {
+	msg := update.Message
+	if msg == nil || msg.Text == "" {
+		return
+	}
+
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+	var fromID string
+	if msg.From != nil {
+		fromID = strconv.FormatInt(msg.From.ID, 10)
+	}
+	if cmd, _ := parseChatCommand(msg.Text); cmd == "" {
+		return
+	}
+	if !chatOpsAllowed(channel, chatID, fromID) {
+		logger.Warnf("[ChatOps] 拒绝未授权的 Telegram 指令 (渠道: %s, chat: %s, user: %s)", channel.Name, chatID, fromID)
+		return
+	}
+
+	cli := newTelegramClient(channel, chatID)
+	reply := func(text string) error {
+		_, err := cli.SendMessageText(text)
+		return err
+	}
+	if text := s.HandleCommand(msg.Text, chatOpsTailLines(channel), reply); text != "" {
+		if err := reply(text); err != nil {
+			logger.Warnf("[ChatOps] Telegram 回复失败: %v", err)
+		}
+	}
+}
+
+// HandleTelegramWebhook 处理 Telegram Webhook 推送的更新
+func (s *ChatOpsService) HandleTelegramWebhook(channelID, secretToken string, body []byte) error {
+	channel, err := s.getChatOpsChannel(channelID, messenger.ChannelTelegram)
+	if err != nil {
+		return err
+	}
+	if channel.Config[ChatOpsKeyMode] != chatOpsModeWebhook {
+		return fmt.Errorf("渠道未启用 Webhook 接收方式")
+	}
+	if !chatOpsTokenEqual(channel.Config[ChatOpsKeySecret], secretToken) {
+		return fmt.Errorf("secret token 校验失败")
+	}
+
+	var update message.TelegramUpdate
+	if err := json.Unmarshal(body, &update); err != nil {
+		return err
+	}
+	go s.handleTelegramUpdate(channel, update)
+	return nil
+}
+
+// syncPollers 根据渠道配置启动/停止 Telegram 长轮询
+func (s *ChatOpsService) syncPollers() {
+	wanted := make(map[string]NotifyChannel)
+	for _, ch := range s.notifyService.GetChannels() {
+		if ch.Type == messenger.ChannelTelegram && chatOpsEnabled(ch) && ch.Config[ChatOpsKeyMode] != chatOpsModeWebhook && ch.Config["bot_token"] != "" {
+			wanted[ch.ID] = ch
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, p := range s.pollers {
+		ch, ok := wanted[id]
+		if ok && p.signature == channelSignature(ch) {
+			delete(wanted, id)
+			continue
+		}
+		close(p.stop)
+		delete(s.pollers, id)
+	}
+
+	for id, ch := range wanted {
+		p := &telegramPoller{signature: channelSignature(ch), stop: make(chan struct{})}
+		s.pollers[id] = p
+		go s.pollTelegram(ch, p.stop)
+	}
+}
+
+func channelSignature(ch NotifyChannel) string {
+	data, _ := json.Marshal(ch.Config)
+	return ch.Name + string(data)
+}
+
+// pollTelegram 长轮询拉取 Telegram 指令
+func (s *ChatOpsService) pollTelegram(channel NotifyChannel, stop chan struct{}) {
+	logger.Infof("[ChatOps] 开始接收 Telegram 指令 (渠道: %s)", channel.Name)
+	cli := newTelegramClient(channel, "")
+	// 忽略启动前积压的消息，避免重启后重复执行历史指令
+	startedAt := time.Now().Add(-time.Minute).Unix()
+	var offset int64
+
+	for {
+		select {
+		case <-stop:
+			logger.Infof("[ChatOps] 停止接收 Telegram 指令 (渠道: %s)", channel.Name)
+			return
+		default:
+		}
+
+		updates, err := cli.GetUpdates(offset, chatOpsPollTimeout)
+		if err != nil {
+			logger.Warnf("[ChatOps] Telegram 拉取指令失败 (渠道: %s): %v", channel.Name, err)
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Second):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Date < startedAt {
+				continue
+			}
+			go s.handleTelegramUpdate(channel, u)
+		}
+	}
+}
+
+// --- 飞书 ---
+
+// FeishuEvent 飞书事件订阅回调（2.0 结构），同时兼容 URL 校验请求
+type FeishuEvent struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+	Encrypt   string `json:"encrypt"`
+	Header    struct {
+		EventID   string `json:"event_id"`
+		EventType string `json:"event_type"`
+		Token     string `json:"token"`
+	} `json:"header"`
+	Event struct {
+		Sender struct {
+			SenderID struct {
+				OpenID string `json:"open_id"`
+				UserID string `json:"user_id"`
+			} `json:"sender_id"`
+		} `json:"sender"`
+		Message struct {
+			MessageID   string `json:"message_id"`
+			ChatID      string `json:"chat_id"`
+			MessageType string `json:"message_type"`
+			Content     string `json:"content"`
+		} `json:"message"`
+	} `json:"event"`
+}
+
+// HandleFeishuWebhook 处理飞书事件回调，返回需要直接响应给飞书的内容
+func (s *ChatOpsService) HandleFeishuWebhook(channelID string, body []byte) (map[string]interface{}, error) {
+	channel, err := s.getChatOpsChannel(channelID, messenger.ChannelFeishu)
+	if err != nil {
+		return nil, err
+	}
+
+	var evt FeishuEvent
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return nil, err
+	}
+	if evt.Encrypt != "" {
+		return nil, fmt.Errorf("暂不支持加密的事件推送，请在飞书开放平台清空 Encrypt Key")
+	}
+
+	token := evt.Token
+	if token == "" {
+		token = evt.Header.Token
+	}
+	if !chatOpsTokenEqual(channel.Config[ChatOpsKeyVerificationToken], token) {
+		return nil, fmt.Errorf("verification token 校验失败")
+	}
+
+	if evt.Type == "url_verification" {
+		return map[string]interface{}{"challenge": evt.Challenge}, nil
+	}
+	if evt.Header.EventType != "im.message.receive_v1" || evt.Event.Message.MessageType != "text" {
+		return map[string]interface{}{}, nil
+	}
+
+	// 飞书在 3 秒内未收到响应会重试推送，按事件 ID 去重
+	s.mu.Lock()
+	now := time.Now()
+	for id, t := range s.events {
+		if now.Sub(t) > time.Hour {
+			delete(s.events, id)
+		}
+	}
+	_, seen := s.events[evt.Header.EventID]
+	s.events[evt.Header.EventID] = now
+	s.mu.Unlock()
+	if seen {
+		return map[string]interface{}{}, nil
+	}
+
+	go s.handleFeishuMessage(channel, evt)
+	return map[string]interface{}{}, nil
+}
+
+func (s *ChatOpsService) handleFeishuMessage(channel NotifyChannel, evt FeishuEvent) {
+	var content struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(evt.Event.Message.Content), &content); err != nil {
+		return
+	}
+	if cmd, _ := parseChatCommand(content.Text); cmd == "" {
+		return
+	}
+
+	sender := evt.Event.Sender.SenderID
+	chatID := evt.Event.Message.ChatID
+	if !chatOpsAllowed(channel, chatID, sender.OpenID, sender.UserID) {
+		logger.Warnf("[ChatOps] 拒绝未授权的飞书指令 (渠道: %s, chat: %s, user: %s)", channel.Name, chatID, sender.OpenID)
+		return
+	}
+
+	cli := &message.FeishuApp{
+		AppID:     channel.Config["app_id"],
+		AppSecret: channel.Config["app_secret"],
+		ApiHost:   channel.Config["api_host"],
+	}
+	if cli.AppID == "" || cli.AppSecret == "" {
+		logger.Warnf("[ChatOps] 飞书渠道 %s 未配置 app_id / app_secret，无法回复指令", channel.Name)
+		return
+	}
+
+	messageID := evt.Event.Message.MessageID
+	asyncReply := func(text string) error {
+		_, err := cli.SendText(chatID, text)
+		return err
+	}
+	if text := s.HandleCommand(content.Text, chatOpsTailLines(channel), asyncReply); text != "" {
+		if _, err := cli.ReplyText(messageID, text); err != nil {
+			logger.Warnf("[ChatOps] 飞书回复失败: %v", err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/executor"
+	"github.com/engigu/baihu-panel/internal/sdk/messenger"
+)
+
+type fakeChatOpsExecutor struct {
+	stopped []string
+}
+
+func (f *fakeChatOpsExecutor) ExecuteTask(taskID string, extraEnvs []string) *executor.ExecutionResult {
+	return &executor.ExecutionResult{TaskID: taskID, LogID: "l-" + taskID, Success: true, Status: constant.TaskStatusQueued}
+}
+
+func (f *fakeChatOpsExecutor) StopTaskExecution(logID string) error {
+	if logID == "missing" {
+		return errors.New("停止失败：找不到指定的执行记录")
+	}
+	f.stopped = append(f.stopped, logID)
+	return nil
+}
+
+func (f *fakeChatOpsExecutor) GetRunningCount() int { return 0 }
+
+func TestParseChatCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		cmd  string
+		args []string
+	}{
+		{"/run 签到", "run", []string{"签到"}},
+		{"/stop@baihu_bot 123", "stop", []string{"123"}},
+		{"@_user_1 /STATUS", "status", []string{}},
+		{"hello /run x", "", nil},
+		{"", "", nil},
+	}
+	for _, tc := range cases {
+		cmd, args := parseChatCommand(tc.text)
+		if cmd != tc.cmd || strings.Join(args, ",") != strings.Join(tc.args, ",") {
+			t.Errorf("%q: got %q %v, want %q %v", tc.text, cmd, args, tc.cmd, tc.args)
+		}
+	}
+}
+
+func TestChatOpsAllowed(t *testing.T) {
+	ch := NotifyChannel{Config: map[string]string{ChatOpsKeyAllow: "100, -200"}}
+	if !chatOpsAllowed(ch, "-200", "") || !chatOpsAllowed(ch, "1", "100") {
+		t.Error("ids in allowlist should be allowed")
+	}
+	if chatOpsAllowed(ch, "300", "") {
+		t.Error("id outside allowlist should be rejected")
+	}
+	if chatOpsAllowed(NotifyChannel{Config: map[string]string{}}, "100") {
+		t.Error("empty allowlist should reject everyone")
+	}
+}
+
+func TestValidateChatOpsConfig(t *testing.T) {
+	cases := []struct {
+		channel NotifyChannel
+		ok      bool
+	}{
+		{NotifyChannel{Type: messenger.ChannelTelegram, Config: map[string]string{ChatOpsKeyEnabled: "true"}}, true},
+		{NotifyChannel{Type: messenger.ChannelTelegram, Config: map[string]string{ChatOpsKeyEnabled: "true", ChatOpsKeyMode: "webhook"}}, false},
+		{NotifyChannel{Type: messenger.ChannelTelegram, Config: map[string]string{ChatOpsKeyEnabled: "true", ChatOpsKeyMode: "webhook", ChatOpsKeySecret: "s"}}, true},
+		{NotifyChannel{Type: messenger.ChannelFeishu, Config: map[string]string{ChatOpsKeyEnabled: "1"}}, false},
+		{NotifyChannel{Type: messenger.ChannelFeishu, Config: map[string]string{ChatOpsKeyEnabled: "1", ChatOpsKeyVerificationToken: "v"}}, true},
+		{NotifyChannel{Type: messenger.ChannelFeishu, Config: map[string]string{}}, true},
+	}
+	for i, tc := range cases {
+		if err := ValidateChatOpsConfig(tc.channel); (err == nil) != tc.ok {
+			t.Errorf("case %d: got %v, want ok=%v", i, err, tc.ok)
+		}
+	}
+
+	if chatOpsTokenEqual("", "") || chatOpsTokenEqual(" ", "") || chatOpsTokenEqual("a", "b") || !chatOpsTokenEqual("a", "a") {
+		t.Error("callback token must be configured and match exactly")
+	}
+}
+
+func TestChatOpsHandleCommand_Stop(t *testing.T) {
+	exec := &fakeChatOpsExecutor{}
+	s := NewChatOpsService(exec)
+
+	if got := s.HandleCommand("/stop 42", 20, nil); !strings.Contains(got, "42") || len(exec.stopped) != 1 {
+		t.Errorf("unexpected stop reply %q, stopped=%v", got, exec.stopped)
+	}
+	if got := s.HandleCommand("/stop missing", 20, nil); !strings.Contains(got, "找不到") {
+		t.Errorf("stop error should be replied, got %q", got)
+	}
+	if got := s.HandleCommand("just chatting", 20, nil); got != "" {
+		t.Errorf("non-command should be ignored, got %q", got)
+	}
+}
+
+func TestChatOpsHandleTaskFinished(t *testing.T) {
+	s := NewChatOpsService(&fakeChatOpsExecutor{})
+	replies := make(chan string, 1)
+	s.pending["l1"] = []chatOpsPending{{
+		reply:     func(text string) error { replies <- text; return nil },
+		tailLines: 2,
+		createdAt: time.Now(),
+	}}
+
+	// 同一任务的另一次执行结束，不应作为 /run 的回复
+	s.handleTaskFinished(eventbus.Event{Type: constant.EventTaskSuccess, Payload: map[string]interface{}{
+		"task_id": "t1",
+		"log_id":  "l0",
+		"status":  constant.TaskStatusSuccess,
+	}})
+	if len(replies) != 0 || len(s.pending) != 1 {
+		t.Fatal("another run of the same task must not be replied")
+	}
+
+	s.handleTaskFinished(eventbus.Event{Type: constant.EventTaskFailed, Payload: map[string]interface{}{
+		"task_id":   "t1",
+		"task_name": "签到",
+		"log_id":    "l1",
+		"status":    constant.TaskStatusFailed,
+		"duration":  int64(1500),
+		"output":    "line1\nline2\n\x1b[31mline3\x1b[0m\n",
+	}})
+
+	select {
+	case text := <-replies:
+		if !strings.Contains(text, "签到") || !strings.Contains(text, "line2\nline3") || strings.Contains(text, "line1") {
+			t.Errorf("unexpected result reply: %q", text)
+		}
+	default:
+		t.Fatal("expected a reply for pending run")
+	}
+	if len(s.pending) != 0 {
+		t.Error("pending entry should be removed after reply")
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/systime"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// envVersionKeep 每个变量保留的历史版本数
+const envVersionKeep = 30
+
+// 变量历史版本的来源
+const (
+	EnvVersionCreate = "create"
+	EnvVersionUpdate = "update"
+	EnvVersionRevert = "revert"
+	EnvVersionBulk   = "bulk"
+	EnvVersionGitOps = "gitops" // 由声明式配置同步写入
+)
+
+// 到期提醒阶段
+const (
+	envExpirySoon    = "soon"
+	envExpiryExpired = "expired"
+)
+
+// SetEnvExpiry 设置变量过期时间，nil 表示不过期；同时重置已发送的提醒状态
+func (es *EnvService) SetEnvExpiry(id string, expiresAt *models.LocalTime) {
+	database.DB.Model(&models.EnvironmentVariable{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"expires_at": expiresAt, "expiry_notice": ""})
+}
+
+// sameEnvSnapshot 判断变量当前内容与历史版本是否一致
+func sameEnvSnapshot(env *models.EnvironmentVariable, v *models.EnvVersion) bool {
+	sameExpiry := (env.ExpiresAt == nil) == (v.ExpiresAt == nil) &&
+		(env.ExpiresAt == nil || env.ExpiresAt.Time().Equal(v.ExpiresAt.Time()))
+	return env.Name == v.Name && env.Value == v.Value && env.Remark == v.Remark && env.Type == v.Type &&
+		utils.DerefBool(env.Hidden, true) == utils.DerefBool(v.Hidden, true) &&
+		utils.DerefBool(env.Enabled, true) == utils.DerefBool(v.Enabled, true) && sameExpiry
+}
+
+// RecordEnvVersion 以数据库中的当前内容为变量保存一个历史版本，内容未变化时跳过
+func (es *EnvService) RecordEnvVersion(envID, action string, revertedTo int, userID, username string) *models.EnvVersion {
+	var env models.EnvironmentVariable
+	if res := database.DB.Where("id = ?", envID).Limit(1).Find(&env); res.Error != nil || res.RowsAffected == 0 {
+		return nil
+	}
+
+	var latest models.EnvVersion
+	res := database.DB.Where("env_id = ?", envID).Order("version DESC").Limit(1).Find(&latest)
+	if res.RowsAffected > 0 && action != EnvVersionRevert && sameEnvSnapshot(&env, &latest) {
+		return nil
+	}
+
+	version := &models.EnvVersion{
+		ID:         utils.GenerateID(),
+		EnvID:      envID,
+		Version:    latest.Version + 1,
+		Action:     action,
+		Name:       env.Name,
+		Value:      env.Value,
+		Remark:     env.Remark,
+		Type:       env.Type,
+		Hidden:     env.Hidden,
+		Enabled:    env.Enabled,
+		ExpiresAt:  env.ExpiresAt,
+		RevertedTo: revertedTo,
+		UserID:     userID,
+		Username:   username,
+		CreatedAt:  models.Now(),
+	}
+	if err := database.DB.Create(version).Error; err != nil {
+		logger.Warnf("[Env] 保存变量 %s 历史版本失败: %v", env.Name, err)
+		return nil
+	}
+
+	// 只保留最近的若干版本
+	var staleIDs []string
+	database.DB.Model(&models.EnvVersion{}).Where("env_id = ?", envID).
+		Order("version DESC").Offset(envVersionKeep).Pluck("id", &staleIDs)
+	if len(staleIDs) > 0 {
+		database.DB.Where("id IN ?", staleIDs).Delete(&models.EnvVersion{})
+	}
+	return version
+}
+
+// EnsureEnvBaseline 功能上线前创建的变量没有历史版本，修改前先保存一份原始内容
+func (es *EnvService) EnsureEnvBaseline(envID string) {
+	var count int64
+	database.DB.Model(&models.EnvVersion{}).Where("env_id = ?", envID).Count(&count)
+	if count == 0 {
+		es.RecordEnvVersion(envID, EnvVersionCreate, 0, "", "")
+	}
+}
+
+// ListEnvVersions 获取变量的历史版本，按版本号倒序
+func (es *EnvService) ListEnvVersions(envID string) []models.EnvVersion {
+	var versions []models.EnvVersion
+	database.DB.Where("env_id = ?", envID).Order("version DESC").Find(&versions)
+	return versions
+}
+
+// RevertEnvVersion 将变量恢复为指定历史版本的内容，并记录一个新的回滚版本
+func (es *EnvService) RevertEnvVersion(envID, versionID, userID, username string) (*models.EnvironmentVariable, error) {
+	var version models.EnvVersion
+	res := database.DB.Where("id = ? AND env_id = ?", versionID, envID).Limit(1).Find(&version)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, errors.New("历史版本不存在")
+	}
+
+	updates := map[string]interface{}{
+		"name":          version.Name,
+		"value":         version.Value,
+		"remark":        version.Remark,
+		"type":          version.Type,
+		"hidden":        version.Hidden,
+		"enabled":       version.Enabled,
+		"expires_at":    version.ExpiresAt,
+		"expiry_notice": "",
+	}
+	if err := database.DB.Model(&models.EnvironmentVariable{}).Where("id = ?", envID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	ClearSecretCache()
+	es.RecordEnvVersion(envID, EnvVersionRevert, version.Version, userID, username)
+	return es.GetEnvVarByID(envID), nil
+}
+
+// RecordEnvUsage 记录任务本次执行注入的机密，每个机密与任务组合只保留最近一次
+func (es *EnvService) RecordEnvUsage(task *models.Task) {
+	if task == nil {
+		return
+	}
+
+	query := database.DB.Model(&models.EnvironmentVariable{}).Where("type = ?", constant.EnvTypeSecret)
+	var config models.TaskConfig
+	if task.Config == "" || json.Unmarshal([]byte(task.Config), &config) != nil || !config.AllEnvs {
+		ids := splitEnvIDs(string(task.Envs))
+		if len(ids) == 0 {
+			return
+		}
+		query = query.Where("id IN ?", ids)
+	}
+	var envIDs []string
+	query.Where("enabled IS NULL OR enabled = ?", true).Pluck("id", &envIDs)
+	if len(envIDs) == 0 {
+		return
+	}
+
+	now := models.Now()
+	for _, envID := range envIDs {
+		res := database.DB.Model(&models.EnvUsage{}).Where("env_id = ? AND task_id = ?", envID, task.ID).
+			Updates(map[string]interface{}{"task_name": task.Name, "count": gorm.Expr("count + 1"), "last_used_at": now})
+		if res.Error == nil && res.RowsAffected > 0 {
+			continue
+		}
+		database.DB.Create(&models.EnvUsage{
+			ID:         utils.GenerateID(),
+			EnvID:      envID,
+			TaskID:     task.ID,
+			TaskName:   task.Name,
+			Count:      1,
+			LastUsedAt: now,
+		})
+	}
+}
+
+// ListEnvUsage 获取机密被注入到各任务的最近记录
+func (es *EnvService) ListEnvUsage(envID string) []models.EnvUsage {
+	var usages []models.EnvUsage
+	database.DB.Where("env_id = ?", envID).Order("last_used_at DESC").Find(&usages)
+	return usages
+}
+
+// cleanEnvHistory 删除变量时清理其历史版本与使用记录
+func (es *EnvService) cleanEnvHistory(envID string) {
+	database.DB.Where("env_id = ?", envID).Delete(&models.EnvVersion{})
+	database.DB.Where("env_id = ?", envID).Delete(&models.EnvUsage{})
+}
+
+// envExpiryState 根据过期时间与提前提醒天数计算提醒阶段
+func envExpiryState(expiresAt, now time.Time, remindDays int) string {
+	switch {
+	case !now.Before(expiresAt):
+		return envExpiryExpired
+	case remindDays > 0 && now.AddDate(0, 0, remindDays).After(expiresAt):
+		return envExpirySoon
+	}
+	return ""
+}
+
+// CheckEnvExpiry 巡检设置了过期时间的变量，即将过期与已过期时各发送一次系统通知
+func (es *EnvService) CheckEnvExpiry() {
+	remindDays, err := strconv.Atoi(NewSettingsService().Get(constant.SectionSite, constant.KeyEnvExpiryRemindDays))
+	if err != nil || remindDays < 0 {
+		remindDays = 7
+	}
+
+	var envs []models.EnvironmentVariable
+	database.DB.Where("expires_at IS NOT NULL").Where("enabled IS NULL OR enabled = ?", true).Find(&envs)
+	now := systime.Now()
+	for _, env := range envs {
+		expiresAt := env.ExpiresAt.Time()
+		state := envExpiryState(expiresAt, now, remindDays)
+		if state == env.ExpiryNotice {
+			continue
+		}
+		database.DB.Model(&models.EnvironmentVariable{}).Where("id = ?", env.ID).Update("expiry_notice", state)
+		if state == "" {
+			continue
+		}
+
+		title := fmt.Sprintf("变量 [%s] 即将过期", env.Name)
+		level := constant.LogLevelWarning
+		if state == envExpiryExpired {
+			title = fmt.Sprintf("变量 [%s] 已过期", env.Name)
+			level = constant.LogLevelError
+		}
+		eventbus.DefaultBus.Publish(eventbus.Event{
+			Type: constant.EventSystemNotice,
+			Payload: map[string]interface{}{
+				"title":   title,
+				"content": fmt.Sprintf("变量 %s 的过期时间为 %s，请及时更新凭据并修改过期时间。", env.Name, systime.InCST(expiresAt).Format("2006-01-02 15:04")),
+				"level":   level,
+			},
+		})
+	}
+}
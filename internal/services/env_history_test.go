@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+func TestEnvExpiryState(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.Local)
+	cases := []struct {
+		expiresAt  time.Time
+		remindDays int
+		want       string
+	}{
+		{now.AddDate(0, 0, 30), 7, ""},
+		{now.AddDate(0, 0, 3), 7, envExpirySoon},
+		{now.AddDate(0, 0, 3), 0, ""},
+		{now, 7, envExpiryExpired},
+		{now.Add(-time.Hour), 0, envExpiryExpired},
+	}
+	for _, c := range cases {
+		if got := envExpiryState(c.expiresAt, now, c.remindDays); got != c.want {
+			t.Errorf("envExpiryState(%v, %d) = %q, want %q", c.expiresAt, c.remindDays, got, c.want)
+		}
+	}
+}
+
+// 变量两次修改后回滚到中间版本，内容恢复且回滚本身记录为新版本
+func TestEnvHistoryRevert(t *testing.T) {
+	setupTestDB(t)
+	es := NewEnvService()
+	env := es.CreateEnvVar("API_KEY", "v1", "", constant.EnvTypeNormal, false, true, "u1")
+	es.RecordEnvVersion(env.ID, EnvVersionCreate, 0, "u1", "admin")
+	for _, value := range []string{"v2", "v3"} {
+		es.UpdateEnvVar(env.ID, "API_KEY", value, "", constant.EnvTypeNormal, false, true)
+		es.RecordEnvVersion(env.ID, EnvVersionUpdate, 0, "u1", "admin")
+	}
+	if es.RecordEnvVersion(env.ID, EnvVersionUpdate, 0, "u1", "admin") != nil {
+		t.Error("unchanged content should not create a version")
+	}
+
+	versions := es.ListEnvVersions(env.ID)
+	if len(versions) != 3 || versions[0].Version != 3 || versions[0].Value != "v3" || versions[1].Value != "v2" {
+		t.Fatalf("unexpected history: %+v", versions)
+	}
+
+	restored, err := es.RevertEnvVersion(env.ID, versions[1].ID, "u1", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Value != "v2" || es.GetEnvVarByID(env.ID).Value != "v2" {
+		t.Errorf("value should be restored to v2, got %q", restored.Value)
+	}
+	latest := es.ListEnvVersions(env.ID)[0]
+	if latest.Version != 4 || latest.Action != EnvVersionRevert || latest.RevertedTo != 2 || latest.Value != "v2" || latest.Username != "admin" {
+		t.Errorf("unexpected revert entry: %+v", latest)
+	}
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/engigu/baihu-panel/internal/constant"
@@ -168,6 +170,7 @@ func (es *EnvService) DeleteEnvVar(id string, force bool) (bool, []models.Task)
 		})
 		if err == nil {
 			relation.DataRelation.CleanRelations(id, constant.RelationTypeEnvTag)
+			es.cleanEnvHistory(id)
 			return true, nil
 		}
 		return false, nil
@@ -176,6 +179,7 @@ func (es *EnvService) DeleteEnvVar(id string, force bool) (bool, []models.Task)
 	result := database.DB.Where("id = ?", id).Delete(&models.EnvironmentVariable{})
 	if result.RowsAffected > 0 {
 		relation.DataRelation.CleanRelations(id, constant.RelationTypeEnvTag)
+		es.cleanEnvHistory(id)
 		return true, nil
 	}
 	return false, nil
@@ -201,9 +205,10 @@ func (es *EnvService) GetEnvVarsByIDs(envIDs string) []string {
 }
 
 // GetEnvVarsAndSecretsByIDs 根据逗号分隔的ID字符串获取环境变量列表和安全机密值列表
-func (es *EnvService) GetEnvVarsAndSecretsByIDs(envIDs string) ([]string, []string) {
+// 外部机密引用解析失败时对应变量置空，并通过 error 返回全部失败原因
+func (es *EnvService) GetEnvVarsAndSecretsByIDs(envIDs string, resolveRefs bool) ([]string, []string, error) {
 	if envIDs == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	ids := splitEnvIDs(envIDs)
@@ -215,7 +220,7 @@ func (es *EnvService) GetEnvVarsAndSecretsByIDs(envIDs string) ([]string, []stri
 		}
 	}
 
-	return es.formatEnvVarsAndSecrets(envs)
+	return es.formatEnvVarsAndSecrets(envs, resolveRefs)
 }
 
 // GetAllEnvVars获取系统中所有的环境变量，并按 NAME=VALUE 格式返回
@@ -228,12 +233,12 @@ func (es *EnvService) GetAllEnvVars() []string {
 }
 
 // GetAllEnvVarsAndSecrets 获取系统中所有的环境变量和安全机密值列表
-func (es *EnvService) GetAllEnvVarsAndSecrets() ([]string, []string) {
+func (es *EnvService) GetAllEnvVarsAndSecrets(resolveRefs bool) ([]string, []string, error) {
 	var envs []models.EnvironmentVariable
 	if err := database.DB.Find(&envs).Error; err != nil {
-		return nil, nil
+		return nil, nil, err
 	}
-	return es.formatEnvVarsAndSecrets(envs)
+	return es.formatEnvVarsAndSecrets(envs, resolveRefs)
 }
 
 // formatEnvVars 将环境变量列表格式化为 NAME=VALUE 数组，并处理重名合并 (过滤掉所有的 Secret)
@@ -280,9 +285,10 @@ func (es *EnvService) formatEnvVars(envs []models.EnvironmentVariable) []string
 }
 
 // formatEnvVarsAndSecrets 将环境变量列表格式化为 NAME=VALUE 数组，并提取明文安全机密列表
-func (es *EnvService) formatEnvVarsAndSecrets(envs []models.EnvironmentVariable) ([]string, []string) {
+// 机密值为 vault://、file:// 等外部引用时，仅在 resolveRefs 为 true（即将执行）时解析，否则置空
+func (es *EnvService) formatEnvVarsAndSecrets(envs []models.EnvironmentVariable, resolveRefs bool) ([]string, []string, error) {
 	if len(envs) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	type mergedEnv struct {
@@ -291,16 +297,28 @@ func (es *EnvService) formatEnvVarsAndSecrets(envs []models.EnvironmentVariable)
 	}
 	var mergedList []mergedEnv
 	var secrets []string
+	var errs []error
 	nameToIndex := make(map[string]int)
 
 	for _, env := range envs {
 		value := string(env.Value)
 		if env.Type == constant.EnvTypeSecret {
-			if decValue, err := utils.Decrypt(value); err == nil {
+			decValue, err := utils.Decrypt(value)
+			if err == nil {
 				value = decValue
-				if utils.DerefBool(env.Enabled, true) && value != "" {
+			}
+			if ref, ok := ParseSecretRef(value); ok {
+				if !resolveRefs || !utils.DerefBool(env.Enabled, true) {
+					value = ""
+				} else if resolved, err := ResolveSecretRef(ref); err != nil {
+					errs = append(errs, fmt.Errorf("%s (%s): %w", env.Name, ref, err))
+					value = ""
+				} else {
+					value = resolved
 					secrets = append(secrets, value)
 				}
+			} else if err == nil && utils.DerefBool(env.Enabled, true) && value != "" {
+				secrets = append(secrets, value)
 			}
 		}
 
@@ -325,7 +343,7 @@ func (es *EnvService) formatEnvVarsAndSecrets(envs []models.EnvironmentVariable)
 		val := strings.Join(item.values, "&")
 		result = append(result, item.name+"="+val)
 	}
-	return result, secrets
+	return result, secrets, errors.Join(errs...)
 }
 
 // splitEnvIDs 解析逗号分隔的ID字符串
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/services/tasks"
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+// 外部机密在执行前才解析，其值同样需要在执行日志中脱敏
+func TestExternalSecretMaskedInTaskLog(t *testing.T) {
+	setupTestDB(t)
+	dir := t.TempDir()
+	const secret = "file-secret-8f3a2c"
+	os.WriteFile(filepath.Join(dir, "token"), []byte(secret+"\n"), 0600)
+
+	settings := NewSettingsService()
+	settings.Set(constant.SectionSecretBackend, constant.KeySecretFileRoots, dir)
+	settings.Set(constant.SectionSecretBackend, constant.KeySecretCacheSeconds, "0")
+
+	envService := NewEnvService()
+	env := envService.CreateEnvVar("TOKEN", "file://"+filepath.Join(dir, "token"), "", constant.EnvTypeSecret, true, true, "")
+	taskService := tasks.NewTaskService()
+	task := taskService.CreateTask(&tasks.TaskParam{
+		Name:        "secret-mask",
+		Command:     `echo "token=$TOKEN"; sleep 0.2`,
+		TriggerType: constant.TriggerTypeBaihuStartup,
+		Timeout:     1,
+		WorkDir:     dir,
+		Envs:        env.ID,
+	})
+
+	// 执行器启动时会清理临时目录下残留的日志文件，隔离到测试目录
+	t.Setenv("TMPDIR", t.TempDir())
+	es := tasks.NewExecutorService(taskService, tasks.NewTaskLogService(nil), nil, settings, envService)
+	defer es.Stop()
+	logID := es.ExecuteTask(task.ID, nil).LogID
+
+	var taskLog models.TaskLog
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		res := database.DB.Where("id = ? AND status NOT IN ?", logID, []string{constant.TaskStatusRunning, constant.TaskStatusQueued}).Limit(1).Find(&taskLog)
+		if res.RowsAffected > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("task did not finish in time")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	output, err := utils.DecompressFromBase64(string(taskLog.Output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(output, secret) || !strings.Contains(output, "token=********") {
+		t.Errorf("external secret not masked in log output: %q", output)
+	}
+}
@@ -0,0 +1,974 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/executor"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/services/tasks"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"github.com/robfig/cron/v3"
+)
+
+// 计划中资源的变更动作
+const (
+	GitOpsActionCreate    = "create"
+	GitOpsActionUpdate    = "update"
+	GitOpsActionDelete    = "delete" // 仓库中已移除且开启了清理
+	GitOpsActionOrphan    = "orphan" // 仓库中已移除但未开启清理，保留在面板中
+	GitOpsActionUnchanged = "unchanged"
+	GitOpsActionError     = "error"
+)
+
+const (
+	// gitopsSyncTimeout 拉取配置仓库的超时时间
+	gitopsSyncTimeout = 10 * time.Minute
+	// gitopsActor 写入变量历史的操作人
+	gitopsActor = "gitops"
+)
+
+var (
+	gitopsOnce          sync.Once
+	gitopsInstance      *GitOpsService
+	gitopsScheduleMu    sync.Mutex
+	gitopsScheduleEntry cron.EntryID
+)
+
+// GitOpsScheduler 应用后同步本机调度器，由任务执行服务实现
+type GitOpsScheduler interface {
+	SyncRepoTasks(upsertedIDs []string, deletedIDs []string)
+}
+
+// GitOpsPlanItem 单个资源的计划
+type GitOpsPlanItem struct {
+	Kind       string         `json:"kind"`
+	Name       string         `json:"name"`
+	Action     string         `json:"action"`
+	ResourceID string         `json:"resource_id"`
+	File       string         `json:"file"`
+	Changes    []GitOpsChange `json:"changes"`
+	Drift      bool           `json:"drift"` // 上次应用后面板中被修改或删除
+	Adopt      bool           `json:"adopt"` // 接管面板中已有的同名资源
+	Error      string         `json:"error"`
+
+	task     *GitOpsTask // 已解析 agent 与通知渠道名称
+	env      *GitOpsEnv
+	agentID  string
+	bindings []models.NotifyBinding
+	hash     string
+}
+
+// GitOpsPlan 仓库声明与面板现状的差异
+type GitOpsPlan struct {
+	Commit string           `json:"commit"`
+	Prune  bool             `json:"prune"`
+	Items  []GitOpsPlanItem `json:"items"`
+	Counts map[string]int   `json:"counts"`
+	Drift  int              `json:"drift"`
+
+	stale []string // 资源已不存在且仓库中也已移除的归属记录
+}
+
+// GitOpsApplyResult 应用计划的结果，任务 ID 用于同步调度器与 Agent
+type GitOpsApplyResult struct {
+	Created        int      `json:"created"`
+	Updated        int      `json:"updated"`
+	Deleted        int      `json:"deleted"`
+	Errors         []string `json:"errors"`
+	LocalTaskIDs   []string `json:"local_task_ids"`   // 需加入或刷新本机调度的任务
+	RemovedTaskIDs []string `json:"removed_task_ids"` // 需从本机调度移除的任务
+	AgentIDs       []string `json:"agent_ids"`        // 任务列表有变动的 Agent
+	EnvsChanged    bool     `json:"envs_changed"`
+}
+
+// GitOpsReport 一次同步的结果，仅检测差异时 Result 为空
+type GitOpsReport struct {
+	Plan   *GitOpsPlan        `json:"plan"`
+	Result *GitOpsApplyResult `json:"result,omitempty"`
+}
+
+// GitOpsService 以 Git 仓库中的 YAML 文件声明任务、变量、通知绑定与 Agent 分配，并与面板同步
+type GitOpsService struct {
+	settingsService *SettingsService
+	taskService     *tasks.TaskService
+	envService      *EnvService
+	notifyService   *NotificationService
+
+	mu        sync.Mutex
+	scheduler GitOpsScheduler
+}
+
+// GetGitOpsService 获取声明式配置同步服务单例
+func GetGitOpsService() *GitOpsService {
+	gitopsOnce.Do(func() {
+		gitopsInstance = &GitOpsService{
+			settingsService: NewSettingsService(),
+			taskService:     tasks.NewTaskService(),
+			envService:      NewEnvService(),
+			notifyService:   NewNotificationService(),
+		}
+	})
+	return gitopsInstance
+}
+
+// SetScheduler 注入本机调度器，CLI 进程中为空，由服务端在收到通知后同步
+func (s *GitOpsService) SetScheduler(scheduler GitOpsScheduler) {
+	s.scheduler = scheduler
+}
+
+// ValidateGitOpsSettings 校验待保存的同步设置
+func ValidateGitOpsSettings(values map[string]string) error {
+	cfg := NewSettingsService().GetSection(constant.SectionGitOps)
+	for k, v := range values {
+		cfg[k] = v
+	}
+	if _, err := gitopsSubPath(cfg[constant.KeyGitOpsPath]); err != nil {
+		return err
+	}
+	switch cfg[constant.KeyGitOpsProxy] {
+	case "", "none", "ghproxy", "mirror":
+	case "custom":
+		if strings.TrimSpace(cfg[constant.KeyGitOpsProxyURL]) == "" {
+			return errors.New("请填写自定义代理地址")
+		}
+	default:
+		return fmt.Errorf("不支持的代理类型: %s", cfg[constant.KeyGitOpsProxy])
+	}
+	if cfg[constant.KeyGitOpsEnabled] != "true" {
+		return nil
+	}
+	if strings.TrimSpace(cfg[constant.KeyGitOpsRepoURL]) == "" {
+		return errors.New("请填写配置仓库地址")
+	}
+	_, err := sysCronSpec(cfg[constant.KeyGitOpsSchedule], "定时同步")
+	return err
+}
+
+// ApplyGitOpsSchedule 按当前设置重新注册定时同步任务
+func ApplyGitOpsSchedule() error {
+	gitopsScheduleMu.Lock()
+	defer gitopsScheduleMu.Unlock()
+	if gitopsScheduleEntry != 0 {
+		executor.GetSysCron().RemoveJob(gitopsScheduleEntry)
+		gitopsScheduleEntry = 0
+	}
+
+	cfg := NewSettingsService().GetSection(constant.SectionGitOps)
+	if cfg[constant.KeyGitOpsEnabled] != "true" {
+		return nil
+	}
+	spec, err := sysCronSpec(cfg[constant.KeyGitOpsSchedule], "定时同步")
+	if err != nil {
+		return err
+	}
+	id, err := executor.GetSysCron().AddJob(spec, func() {
+		GetGitOpsService().runScheduled()
+	})
+	if err != nil {
+		return err
+	}
+	gitopsScheduleEntry = id
+	return nil
+}
+
+// runScheduled 定时同步，按设置决定是否自动应用；出现新的漂移或错误时发送系统通知
+func (s *GitOpsService) runScheduled() {
+	prev := s.settingsService.GetSection(constant.SectionGitOps)
+	autoApply := prev[constant.KeyGitOpsAutoApply] == "true"
+	report, err := s.Reconcile(autoApply)
+	if err != nil {
+		logger.Errorf("[GitOps] 定时同步失败: %v", err)
+		if err.Error() != prev[constant.KeyGitOpsLastError] {
+			gitopsNotice("配置同步失败", err.Error(), "error")
+		}
+		return
+	}
+
+	summary := report.Summary()
+	if summary == prev[constant.KeyGitOpsLastSummary] {
+		return
+	}
+	if report.Result != nil && len(report.Result.Errors) > 0 {
+		gitopsNotice("配置同步部分失败", strings.Join(report.Result.Errors, "\n"), "warning")
+	} else if report.Result == nil && (report.Plan.Drift > 0 || report.Plan.pending() > 0) {
+		gitopsNotice("检测到配置差异", summary, "warning")
+	}
+}
+
+func gitopsNotice(title, content, level string) {
+	eventbus.DefaultBus.Publish(eventbus.Event{
+		Type: constant.EventSystemNotice,
+		Payload: map[string]interface{}{
+			"title":   title,
+			"content": content,
+			"level":   level,
+		},
+	})
+}
+
+// Reconcile 拉取配置仓库并计算差异，apply 为 true 时应用并同步调度器；同一时间只运行一次
+func (s *GitOpsService) Reconcile(apply bool) (*GitOpsReport, error) {
+	if !s.mu.TryLock() {
+		return nil, errors.New("已有配置同步正在进行，请稍后")
+	}
+	defer s.mu.Unlock()
+
+	cfg := s.settingsService.GetSection(constant.SectionGitOps)
+	report, err := s.reconcile(cfg, apply)
+	s.RecordRun(report, err)
+	return report, err
+}
+
+func (s *GitOpsService) reconcile(cfg map[string]string, apply bool) (*GitOpsReport, error) {
+	dir, commit, err := s.Checkout(cfg)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.Plan(dir, cfg[constant.KeyGitOpsPrune] == "true")
+	if err != nil {
+		return nil, err
+	}
+	plan.Commit = commit
+	report := &GitOpsReport{Plan: plan}
+	if apply {
+		report.Result = s.Apply(plan)
+		s.Propagate(report.Result)
+	}
+	return report, nil
+}
+
+// RecordRun 记录最近一次同步的状态
+func (s *GitOpsService) RecordRun(report *GitOpsReport, runErr error) {
+	s.settingsService.Set(constant.SectionGitOps, constant.KeyGitOpsLastRunAt, time.Now().Format("2006-01-02 15:04:05"))
+	if runErr != nil {
+		s.settingsService.Set(constant.SectionGitOps, constant.KeyGitOpsLastError, runErr.Error())
+		return
+	}
+	s.settingsService.Set(constant.SectionGitOps, constant.KeyGitOpsLastError, "")
+	s.settingsService.Set(constant.SectionGitOps, constant.KeyGitOpsLastCommit, report.Plan.Commit)
+	s.settingsService.Set(constant.SectionGitOps, constant.KeyGitOpsLastSummary, report.Summary())
+}
+
+// Checkout 通过 reposync 拉取配置仓库，返回 YAML 所在目录与当前提交
+func (s *GitOpsService) Checkout(cfg map[string]string) (string, string, error) {
+	repoURL := strings.TrimSpace(cfg[constant.KeyGitOpsRepoURL])
+	if repoURL == "" {
+		return "", "", errors.New("请先配置配置仓库地址")
+	}
+	sub, err := gitopsSubPath(cfg[constant.KeyGitOpsPath])
+	if err != nil {
+		return "", "", err
+	}
+	branch := strings.TrimSpace(cfg[constant.KeyGitOpsBranch])
+	token := strings.TrimSpace(cfg[constant.KeyGitOpsAuthToken])
+
+	base, err := filepath.Abs(filepath.Join(constant.DataDir, "gitops"))
+	if err != nil {
+		return "", "", err
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return "", "", err
+	}
+	// 仓库地址、分支或凭据变化时使用新的目录重新克隆，并清理旧目录
+	sum := sha256.Sum256([]byte(strings.Join([]string{repoURL, branch, token, cfg[constant.KeyGitOpsProxy], cfg[constant.KeyGitOpsProxyURL]}, "\n")))
+	name := utils.GetRepoIdentifier(repoURL, branch) + "_" + hex.EncodeToString(sum[:4])
+	if entries, err := os.ReadDir(base); err == nil {
+		for _, e := range entries {
+			if e.Name() != name {
+				os.RemoveAll(filepath.Join(base, e.Name()))
+			}
+		}
+	}
+
+	exePath, err := os.Executable()
+	if err != nil {
+		exePath = "baihu"
+	}
+	args := []string{"reposync", "--source-type", "git", "--source-url", repoURL, "--target-path", base, "--repo-name", name}
+	if branch != "" {
+		args = append(args, "--branch", branch)
+	}
+	if proxy := cfg[constant.KeyGitOpsProxy]; proxy != "" && proxy != "none" {
+		args = append(args, "--proxy", proxy)
+		if proxy == "custom" {
+			args = append(args, "--proxy-url", strings.TrimSpace(cfg[constant.KeyGitOpsProxyURL]))
+		}
+	}
+	if token != "" {
+		args = append(args, "--auth-token", token)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitopsSyncTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, exePath, args...)
+	cmd.Env = append(os.Environ(), utils.BuildRuntimeProcessEnv()...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		output := string(out)
+		if token != "" {
+			output = strings.ReplaceAll(output, token, "******")
+		}
+		return "", "", fmt.Errorf("拉取配置仓库失败: %v\n%s", err, gitopsTail(output, 20))
+	}
+
+	repoDir := filepath.Join(base, name)
+	commit := ""
+	if rev, err := exec.Command("git", "-C", repoDir, "rev-parse", "--short", "HEAD").Output(); err == nil {
+		commit = strings.TrimSpace(string(rev))
+	}
+	return filepath.Join(repoDir, sub), commit, nil
+}
+
+// gitopsSubPath 校验仓库内的配置目录，不允许跳出仓库
+func gitopsSubPath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return ".", nil
+	}
+	clean := filepath.Clean(filepath.FromSlash(p))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("配置目录必须是仓库内的相对路径: %s", p)
+	}
+	return clean, nil
+}
+
+// gitopsTail 截取输出的最后几行
+func gitopsTail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// gitopsPanel 计划与应用时所需的面板现状
+type gitopsPanel struct {
+	tasks      map[string]*models.Task
+	taskNames  map[string][]*models.Task
+	envs       map[string]*models.EnvironmentVariable
+	envNames   map[string][]*models.EnvironmentVariable
+	agents     []models.Agent
+	ways       []models.NotifyWay
+	bindings   map[string][]models.NotifyBinding // taskID -> 绑定
+	records    map[string]*models.GitOpsResource // kind + name -> 归属记录
+	owned      map[string]bool                   // 已归属的资源 ID
+	scriptsDir string
+}
+
+func gitopsRecordKey(kind, name string) string {
+	return kind + "\x00" + name
+}
+
+func (s *GitOpsService) loadPanel() *gitopsPanel {
+	p := &gitopsPanel{
+		tasks:     map[string]*models.Task{},
+		taskNames: map[string][]*models.Task{},
+		envs:      map[string]*models.EnvironmentVariable{},
+		envNames:  map[string][]*models.EnvironmentVariable{},
+		bindings:  map[string][]models.NotifyBinding{},
+		records:   map[string]*models.GitOpsResource{},
+		owned:     map[string]bool{},
+	}
+	p.scriptsDir, _ = filepath.Abs(constant.ScriptsWorkDir)
+
+	taskList := s.taskService.GetTasks()
+	for i := range taskList {
+		t := &taskList[i]
+		p.tasks[t.ID] = t
+		p.taskNames[t.Name] = append(p.taskNames[t.Name], t)
+	}
+	var envList []models.EnvironmentVariable
+	database.DB.Find(&envList)
+	for i := range envList {
+		e := &envList[i]
+		p.envs[e.ID] = e
+		p.envNames[e.Name] = append(p.envNames[e.Name], e)
+	}
+	database.DB.Find(&p.agents)
+	database.DB.Find(&p.ways)
+
+	var bindings []models.NotifyBinding
+	database.DB.Where("type = ?", constant.BindingTypeTask).Find(&bindings)
+	for _, b := range bindings {
+		p.bindings[b.DataID] = append(p.bindings[b.DataID], b)
+	}
+
+	var records []models.GitOpsResource
+	database.DB.Find(&records)
+	for i := range records {
+		r := &records[i]
+		p.records[gitopsRecordKey(r.Kind, r.Name)] = r
+		p.owned[r.ResourceID] = true
+	}
+	return p
+}
+
+// resolveAgent 按 ID、机器码或名称查找 Agent
+func (p *gitopsPanel) resolveAgent(ref string) (*models.Agent, error) {
+	var byName []*models.Agent
+	for i := range p.agents {
+		a := &p.agents[i]
+		if a.ID == ref || a.MachineID == ref {
+			return a, nil
+		}
+		if a.Name == ref {
+			byName = append(byName, a)
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("Agent %s 不存在", ref)
+	case 1:
+		return byName[0], nil
+	}
+	return nil, fmt.Errorf("存在多个名为 %s 的 Agent，请使用机器码", ref)
+}
+
+// resolveWay 按 ID 或名称查找通知渠道
+func (p *gitopsPanel) resolveWay(ref string) (*models.NotifyWay, error) {
+	var byName []*models.NotifyWay
+	for i := range p.ways {
+		w := &p.ways[i]
+		if w.ID == ref {
+			return w, nil
+		}
+		if w.Name == ref {
+			byName = append(byName, w)
+		}
+	}
+	switch len(byName) {
+	case 0:
+		return nil, fmt.Errorf("通知渠道 %s 不存在", ref)
+	case 1:
+		return byName[0], nil
+	}
+	return nil, fmt.Errorf("存在多个名为 %s 的通知渠道，请使用渠道 ID", ref)
+}
+
+// matchTask 查找仓库任务对应的面板任务：优先使用归属记录，其次接管唯一的同名普通任务
+func (p *gitopsPanel) matchTask(name string) (task *models.Task, rec *models.GitOpsResource, adopt bool, err error) {
+	rec = p.records[gitopsRecordKey(constant.GitOpsKindTask, name)]
+	if rec != nil {
+		if t, ok := p.tasks[rec.ResourceID]; ok {
+			return t, rec, false, nil
+		}
+	}
+	var candidates []*models.Task
+	for _, t := range p.taskNames[name] {
+		if !p.owned[t.ID] && t.Type == constant.TaskTypeNormal && t.RepoTaskID == "" {
+			candidates = append(candidates, t)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, rec, false, nil
+	case 1:
+		return candidates[0], rec, true, nil
+	}
+	return nil, rec, false, fmt.Errorf("面板中存在 %d 个同名任务，无法确定要接管哪一个", len(candidates))
+}
+
+// matchEnv 查找仓库变量对应的面板变量，规则同 matchTask
+func (p *gitopsPanel) matchEnv(name string) (env *models.EnvironmentVariable, rec *models.GitOpsResource, adopt bool, err error) {
+	rec = p.records[gitopsRecordKey(constant.GitOpsKindEnv, name)]
+	if rec != nil {
+		if e, ok := p.envs[rec.ResourceID]; ok {
+			return e, rec, false, nil
+		}
+	}
+	var candidates []*models.EnvironmentVariable
+	for _, e := range p.envNames[name] {
+		if !p.owned[e.ID] {
+			candidates = append(candidates, e)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, rec, false, nil
+	case 1:
+		return candidates[0], rec, true, nil
+	}
+	return nil, rec, false, fmt.Errorf("面板中存在 %d 个同名变量，无法确定要接管哪一个", len(candidates))
+}
+
+// taskFields 面板任务的规范化字段
+func (p *gitopsPanel) taskFields(t *models.Task) map[string]string {
+	agent := ""
+	if t.AgentID != nil && *t.AgentID != "" {
+		agent = *t.AgentID
+		for _, a := range p.agents {
+			if a.ID == agent {
+				agent = a.Name
+				break
+			}
+		}
+	}
+
+	var envs []string
+	for _, id := range strings.Split(string(t.Envs), ",") {
+		if e, ok := p.envs[strings.TrimSpace(id)]; ok {
+			envs = append(envs, e.Name)
+		}
+	}
+
+	var notify []string
+	for _, b := range p.bindings[t.ID] {
+		channel := b.WayID
+		for _, w := range p.ways {
+			if w.ID == b.WayID {
+				channel = w.Name
+				break
+			}
+		}
+		notify = append(notify, channel+":"+b.Event)
+	}
+
+	trigger := t.TriggerType
+	if trigger == "" {
+		trigger = constant.TriggerTypeCron
+	}
+	schedule := t.Schedule
+	if trigger != constant.TriggerTypeCron {
+		schedule = ""
+	}
+
+	workDir := t.WorkDir
+	if agent == "" {
+		if workDir == "" || workDir == p.scriptsDir {
+			workDir = constant.ScriptsDirPlaceholder
+		} else if rel, err := filepath.Rel(p.scriptsDir, workDir); err == nil && filepath.IsAbs(workDir) && !strings.HasPrefix(rel, "..") {
+			workDir = constant.ScriptsDirPlaceholder + "/" + filepath.ToSlash(rel)
+		}
+	}
+
+	return map[string]string{
+		"remark":         t.Remark,
+		"command":        string(t.Command),
+		"pre_command":    string(t.PreCommand),
+		"post_command":   string(t.PostCommand),
+		"trigger":        trigger,
+		"schedule":       schedule,
+		"enabled":        strconv.FormatBool(utils.DerefBool(t.Enabled, true)),
+		"timeout":        strconv.Itoa(t.Timeout),
+		"work_dir":       workDir,
+		"retry_count":    strconv.Itoa(t.RetryCount),
+		"retry_interval": strconv.Itoa(t.RetryInterval),
+		"random_range":   strconv.Itoa(t.RandomRange),
+		"tags":           strings.Join(gitopsNameList(strings.Split(t.Tags, ",")), ","),
+		"envs":           strings.Join(gitopsNameList(envs), ","),
+		"agent":          agent,
+		"notify":         strings.Join(gitopsNameList(notify), ","),
+	}
+}
+
+// envFields 面板变量的规范化字段
+func envFields(e *models.EnvironmentVariable) map[string]string {
+	return map[string]string{
+		"value":   string(e.Value),
+		"remark":  e.Remark,
+		"enabled": strconv.FormatBool(utils.DerefBool(e.Enabled, true)),
+	}
+}
+
+// Plan 读取配置目录并与面板比对，prune 为 true 时仓库中移除的资源计划删除
+func (s *GitOpsService) Plan(dir string, prune bool) (*GitOpsPlan, error) {
+	spec, err := LoadGitOpsSpec(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := s.loadPanel()
+	plan := &GitOpsPlan{Prune: prune}
+	declared := map[string]bool{}
+
+	repoEnvs := map[string]bool{}
+	for i := range spec.Envs {
+		e := &spec.Envs[i]
+		repoEnvs[e.Name] = true
+		declared[gitopsRecordKey(constant.GitOpsKindEnv, e.Name)] = true
+		item := GitOpsPlanItem{Kind: constant.GitOpsKindEnv, Name: e.Name, File: e.File, env: e}
+		repo := e.fields()
+		item.hash = gitopsHash(repo, gitopsEnvFields)
+
+		cur, rec, adopt, err := p.matchEnv(e.Name)
+		if err == nil && cur != nil && cur.Type == constant.EnvTypeSecret {
+			err = errors.New("面板中的同名变量为密钥变量，不能由仓库管理")
+		}
+		if err != nil {
+			item.Action, item.Error = GitOpsActionError, err.Error()
+			plan.Items = append(plan.Items, item)
+			continue
+		}
+		if cur == nil {
+			item.Action = GitOpsActionCreate
+			item.Drift = rec != nil
+		} else {
+			panel := envFields(cur)
+			item.ResourceID, item.Adopt = cur.ID, adopt
+			item.Changes = gitopsDiff(panel, repo, gitopsEnvFields)
+			item.Drift = rec != nil && rec.SpecHash != "" && gitopsHash(panel, gitopsEnvFields) != rec.SpecHash
+			item.Action = GitOpsActionUnchanged
+			if len(item.Changes) > 0 {
+				item.Action = GitOpsActionUpdate
+			}
+		}
+		plan.Items = append(plan.Items, item)
+	}
+
+	for i := range spec.Tasks {
+		declared[gitopsRecordKey(constant.GitOpsKindTask, spec.Tasks[i].Name)] = true
+		plan.Items = append(plan.Items, s.planTask(p, &spec.Tasks[i], repoEnvs))
+	}
+
+	// 归属于仓库但已从仓库移除的资源
+	var removed []*models.GitOpsResource
+	for key, rec := range p.records {
+		if !declared[key] {
+			removed = append(removed, rec)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].Kind != removed[j].Kind {
+			return removed[i].Kind > removed[j].Kind
+		}
+		return removed[i].Name < removed[j].Name
+	})
+	for _, rec := range removed {
+		exists := false
+		if rec.Kind == constant.GitOpsKindTask {
+			_, exists = p.tasks[rec.ResourceID]
+		} else {
+			_, exists = p.envs[rec.ResourceID]
+		}
+		if !exists {
+			plan.stale = append(plan.stale, rec.ID)
+			continue
+		}
+		item := GitOpsPlanItem{Kind: rec.Kind, Name: rec.Name, ResourceID: rec.ResourceID, Action: GitOpsActionOrphan}
+		if prune {
+			item.Action = GitOpsActionDelete
+		}
+		plan.Items = append(plan.Items, item)
+	}
+
+	plan.Counts = map[string]int{}
+	for _, item := range plan.Items {
+		plan.Counts[item.Action]++
+		if item.Drift {
+			plan.Drift++
+		}
+	}
+	return plan, nil
+}
+
+func (s *GitOpsService) planTask(p *gitopsPanel, t *GitOpsTask, repoEnvs map[string]bool) GitOpsPlanItem {
+	resolved := *t
+	item := GitOpsPlanItem{Kind: constant.GitOpsKindTask, Name: t.Name, File: t.File, task: &resolved}
+	fail := func(err error) GitOpsPlanItem {
+		item.Action, item.Error = GitOpsActionError, err.Error()
+		return item
+	}
+
+	if t.Agent != "" {
+		agent, err := p.resolveAgent(t.Agent)
+		if err != nil {
+			return fail(err)
+		}
+		resolved.Agent, item.agentID = agent.Name, agent.ID
+	}
+	resolved.Notify = make([]GitOpsNotify, len(t.Notify))
+	for i, n := range t.Notify {
+		way, err := p.resolveWay(n.Channel)
+		if err != nil {
+			return fail(err)
+		}
+		resolved.Notify[i] = GitOpsNotify{Channel: way.Name, Events: n.Events}
+		for _, ev := range n.Events {
+			item.bindings = append(item.bindings, models.NotifyBinding{Event: ev, WayID: way.ID})
+		}
+	}
+	for _, name := range t.Envs {
+		if !repoEnvs[name] && len(p.envNames[name]) == 0 {
+			return fail(fmt.Errorf("引用的变量 %s 不存在", name))
+		}
+	}
+
+	repo := resolved.fields()
+	item.hash = gitopsHash(repo, gitopsTaskFields)
+	cur, rec, adopt, err := p.matchTask(t.Name)
+	if err != nil {
+		return fail(err)
+	}
+	if cur == nil {
+		item.Action = GitOpsActionCreate
+		item.Drift = rec != nil
+		return item
+	}
+	panel := p.taskFields(cur)
+	item.ResourceID, item.Adopt = cur.ID, adopt
+	item.Changes = gitopsDiff(panel, repo, gitopsTaskFields)
+	item.Drift = rec != nil && rec.SpecHash != "" && gitopsHash(panel, gitopsTaskFields) != rec.SpecHash
+	item.Action = GitOpsActionUnchanged
+	if len(item.Changes) > 0 {
+		item.Action = GitOpsActionUpdate
+	}
+	return item
+}
+
+// pending 计划中需要变更的资源数
+func (plan *GitOpsPlan) pending() int {
+	return plan.Counts[GitOpsActionCreate] + plan.Counts[GitOpsActionUpdate] + plan.Counts[GitOpsActionDelete]
+}
+
+// Summary 同步结果摘要
+func (r *GitOpsReport) Summary() string {
+	if r == nil || r.Plan == nil {
+		return ""
+	}
+	c := r.Plan.Counts
+	summary := fmt.Sprintf("新增 %d，更新 %d，删除 %d，未纳管 %d，未变更 %d，漂移 %d，错误 %d",
+		c[GitOpsActionCreate], c[GitOpsActionUpdate], c[GitOpsActionDelete], c[GitOpsActionOrphan],
+		c[GitOpsActionUnchanged], r.Plan.Drift, c[GitOpsActionError])
+	if r.Result != nil {
+		summary = "已应用：" + summary
+	} else {
+		summary = "待应用：" + summary
+	}
+	return summary
+}
+
+// Apply 按计划写入面板，跳过有错误的资源；变量先于任务应用以便任务引用新建的变量
+func (s *GitOpsService) Apply(plan *GitOpsPlan) *GitOpsApplyResult {
+	result := &GitOpsApplyResult{}
+	p := s.loadPanel()
+	adminID := ""
+	var admin models.User
+	if res := database.DB.Where("role = ?", constant.AdminRole).Limit(1).Find(&admin); res.Error == nil && res.RowsAffected > 0 {
+		adminID = admin.ID
+	}
+	agents := map[string]bool{}
+
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		if item.Kind != constant.GitOpsKindEnv || item.env == nil || item.Error != "" {
+			continue
+		}
+		e := item.env
+		switch item.Action {
+		case GitOpsActionCreate:
+			env := s.envService.CreateEnvVar(e.Name, e.Value, e.Remark, constant.EnvTypeNormal, false, *e.Enabled, adminID)
+			item.ResourceID = env.ID
+			s.envService.RecordEnvVersion(env.ID, EnvVersionGitOps, 0, adminID, gitopsActor)
+			result.Created++
+			result.EnvsChanged = true
+		case GitOpsActionUpdate:
+			cur := p.envs[item.ResourceID]
+			if cur == nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("变量 %s: 面板中已不存在", e.Name))
+				continue
+			}
+			s.envService.UpdateEnvVar(cur.ID, e.Name, e.Value, e.Remark, cur.Type, utils.DerefBool(cur.Hidden, false), *e.Enabled)
+			s.envService.RecordEnvVersion(cur.ID, EnvVersionGitOps, 0, adminID, gitopsActor)
+			result.Updated++
+			result.EnvsChanged = true
+		}
+		s.saveRecord(constant.GitOpsKindEnv, e.Name, item.ResourceID, item.hash)
+	}
+
+	// 任务引用的变量按名称解析，包括本次新建的变量
+	envIDs := map[string][]string{}
+	var envList []models.EnvironmentVariable
+	database.DB.Select("id", "name").Find(&envList)
+	for _, e := range envList {
+		envIDs[e.Name] = append(envIDs[e.Name], e.ID)
+	}
+
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		if item.Kind != constant.GitOpsKindTask || item.task == nil || item.Error != "" {
+			continue
+		}
+		if item.Action == GitOpsActionCreate || item.Action == GitOpsActionUpdate {
+			if err := s.applyTask(p, item, envIDs, result, agents); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("任务 %s: %v", item.Name, err))
+				continue
+			}
+		}
+		s.saveRecord(constant.GitOpsKindTask, item.Name, item.ResourceID, item.hash)
+	}
+
+	// 清理：先删除任务，再删除不再被引用的变量
+	for _, kind := range []string{constant.GitOpsKindTask, constant.GitOpsKindEnv} {
+		for _, item := range plan.Items {
+			if item.Kind != kind || item.Action != GitOpsActionDelete {
+				continue
+			}
+			if kind == constant.GitOpsKindTask {
+				if t := p.tasks[item.ResourceID]; t != nil && t.AgentID != nil && *t.AgentID != "" {
+					agents[*t.AgentID] = true
+				}
+				s.taskService.DeleteTask(item.ResourceID)
+				result.RemovedTaskIDs = append(result.RemovedTaskIDs, item.ResourceID)
+			} else {
+				if ok, related := s.envService.DeleteEnvVar(item.ResourceID, false); !ok {
+					if len(related) > 0 {
+						result.Errors = append(result.Errors, fmt.Sprintf("变量 %s 仍被 %d 个任务引用，未删除", item.Name, len(related)))
+					}
+					continue
+				}
+				result.EnvsChanged = true
+			}
+			database.DB.Where("kind = ? AND name = ?", kind, item.Name).Delete(&models.GitOpsResource{})
+			result.Deleted++
+		}
+	}
+	if len(plan.stale) > 0 {
+		database.DB.Where("id IN ?", plan.stale).Delete(&models.GitOpsResource{})
+	}
+
+	for id := range agents {
+		result.AgentIDs = append(result.AgentIDs, id)
+	}
+	sort.Strings(result.AgentIDs)
+	return result
+}
+
+// applyTask 创建或更新单个任务及其通知绑定
+func (s *GitOpsService) applyTask(p *gitopsPanel, item *GitOpsPlanItem, envIDs map[string][]string, result *GitOpsApplyResult, agents map[string]bool) error {
+	t := item.task
+	var ids []string
+	for _, name := range t.Envs {
+		ids = append(ids, envIDs[name]...)
+	}
+	fields := t.fields()
+	param := tasks.TaskParam{
+		Name:          t.Name,
+		Remark:        t.Remark,
+		Command:       t.Command,
+		PreCommand:    t.PreCommand,
+		PostCommand:   t.PostCommand,
+		Tags:          strings.Join(t.Tags, ","),
+		Type:          constant.TaskTypeNormal,
+		Schedule:      t.Schedule,
+		Timeout:       t.Timeout,
+		WorkDir:       fields["work_dir"],
+		Envs:          strings.Join(ids, ","),
+		TriggerType:   t.Trigger,
+		RetryCount:    t.RetryCount,
+		RetryInterval: t.RetryInterval,
+		RandomRange:   t.RandomRange,
+		Enabled:       *t.Enabled,
+	}
+	if item.agentID != "" {
+		agentID := item.agentID
+		param.AgentID = &agentID
+		agents[agentID] = true
+	}
+
+	var task *models.Task
+	var oldBindings []models.NotifyBinding
+	if item.Action == GitOpsActionCreate {
+		task = s.taskService.CreateTask(&param)
+		if !param.Enabled {
+			task = s.taskService.UpdateTask(task.ID, &param)
+		}
+		item.ResourceID = task.ID
+		result.Created++
+	} else {
+		cur := p.tasks[item.ResourceID]
+		if cur == nil {
+			return errors.New("面板中已不存在")
+		}
+		// 仓库未声明的字段沿用面板中的配置
+		param.PinType = cur.PinType
+		param.Config = string(cur.Config)
+		param.CleanConfig = cur.CleanConfig
+		param.Languages = cur.Languages
+		if cur.AgentID != nil && *cur.AgentID != "" {
+			agents[*cur.AgentID] = true
+		}
+		task = s.taskService.UpdateTask(cur.ID, &param)
+		if task == nil {
+			return errors.New("面板中已不存在")
+		}
+		oldBindings = p.bindings[cur.ID]
+		result.Updated++
+	}
+
+	// 保留已有绑定的日志推送等额外配置
+	bindings := make([]models.NotifyBinding, len(item.bindings))
+	for i, b := range item.bindings {
+		bindings[i] = b
+		for _, old := range oldBindings {
+			if old.WayID == b.WayID && old.Event == b.Event {
+				bindings[i].Extra = old.Extra
+				break
+			}
+		}
+	}
+	if err := s.notifyService.BatchSaveBindings(constant.BindingTypeTask, task.ID, bindings); err != nil {
+		return fmt.Errorf("保存通知绑定失败: %v", err)
+	}
+
+	if item.agentID == "" {
+		result.LocalTaskIDs = append(result.LocalTaskIDs, task.ID)
+	} else {
+		// Agent 任务不在本机调度
+		result.RemovedTaskIDs = append(result.RemovedTaskIDs, task.ID)
+	}
+	return nil
+}
+
+// saveRecord 写入或更新资源的归属记录
+func (s *GitOpsService) saveRecord(kind, name, resourceID, hash string) {
+	var rec models.GitOpsResource
+	res := database.DB.Where("kind = ? AND name = ?", kind, name).Limit(1).Find(&rec)
+	if res.Error == nil && res.RowsAffected > 0 {
+		database.DB.Model(&rec).Updates(map[string]interface{}{
+			"resource_id": resourceID,
+			"spec_hash":   hash,
+			"applied_at":  models.Now(),
+		})
+		return
+	}
+	database.DB.Create(&models.GitOpsResource{
+		ID:         utils.GenerateID(),
+		Kind:       kind,
+		Name:       name,
+		ResourceID: resourceID,
+		SpecHash:   hash,
+		AppliedAt:  models.Now(),
+	})
+}
+
+// Propagate 将应用结果同步到本机调度器与 Agent
+func (s *GitOpsService) Propagate(result *GitOpsApplyResult) {
+	if result == nil {
+		return
+	}
+	if s.scheduler != nil && (len(result.LocalTaskIDs) > 0 || len(result.RemovedTaskIDs) > 0) {
+		s.scheduler.SyncRepoTasks(result.LocalTaskIDs, result.RemovedTaskIDs)
+	}
+	if result.EnvsChanged {
+		GetAgentWSManager().BroadcastTasksToAll()
+		return
+	}
+	for _, id := range result.AgentIDs {
+		GetAgentWSManager().BroadcastTasks(id)
+	}
+}
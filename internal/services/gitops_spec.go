@@ -0,0 +1,342 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/goccy/go-yaml"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrGitOpsSpec 仓库中的声明式配置无效
+var ErrGitOpsSpec = errors.New("声明式配置无效")
+
+// gitopsDefaultTimeout 未声明超时时间时使用的默认值（分钟），与面板新建任务一致
+const gitopsDefaultTimeout = 30
+
+// gitopsEventAliases 通知事件的简写
+var gitopsEventAliases = map[string]string{
+	"success": constant.EventTaskSuccess,
+	"failed":  constant.EventTaskFailed,
+	"timeout": constant.EventTaskTimeout,
+}
+
+// gitopsTaskFields 任务参与比对的字段，顺序即差异展示顺序
+var gitopsTaskFields = []string{
+	"remark", "command", "pre_command", "post_command", "trigger", "schedule", "enabled", "timeout",
+	"work_dir", "retry_count", "retry_interval", "random_range", "tags", "envs", "agent", "notify",
+}
+
+// gitopsEnvFields 变量参与比对的字段
+var gitopsEnvFields = []string{"value", "remark", "enabled"}
+
+// GitOpsNotify 任务的通知绑定，渠道可填名称或 ID
+type GitOpsNotify struct {
+	Channel string   `yaml:"channel" json:"channel"`
+	Events  []string `yaml:"events" json:"events"` // success、failed、timeout 或完整事件名
+}
+
+// GitOpsTask 仓库中声明的任务
+type GitOpsTask struct {
+	Name          string         `yaml:"name" json:"name"`
+	Remark        string         `yaml:"remark" json:"remark"`
+	Command       string         `yaml:"command" json:"command"`
+	PreCommand    string         `yaml:"pre_command" json:"pre_command"`
+	PostCommand   string         `yaml:"post_command" json:"post_command"`
+	Trigger       string         `yaml:"trigger" json:"trigger"` // cron（默认）或 baihu_startup
+	Schedule      string         `yaml:"schedule" json:"schedule"`
+	Enabled       *bool          `yaml:"enabled" json:"enabled"` // 默认启用
+	Timeout       int            `yaml:"timeout" json:"timeout"` // 分钟，默认 30
+	WorkDir       string         `yaml:"work_dir" json:"work_dir"`
+	RetryCount    int            `yaml:"retry_count" json:"retry_count"`
+	RetryInterval int            `yaml:"retry_interval" json:"retry_interval"`
+	RandomRange   int            `yaml:"random_range" json:"random_range"`
+	Tags          []string       `yaml:"tags" json:"tags"`
+	Envs          []string       `yaml:"envs" json:"envs"`   // 引用的变量名称
+	Agent         string         `yaml:"agent" json:"agent"` // Agent 名称或机器码，留空在本机执行
+	Notify        []GitOpsNotify `yaml:"notify" json:"notify"`
+
+	File string `yaml:"-" json:"file"` // 所在文件，相对于配置目录
+}
+
+// GitOpsEnv 仓库中声明的变量，仅支持普通变量，密钥变量请在面板或外部机密中管理
+type GitOpsEnv struct {
+	Name    string `yaml:"name" json:"name"`
+	Value   string `yaml:"value" json:"value"`
+	Remark  string `yaml:"remark" json:"remark"`
+	Enabled *bool  `yaml:"enabled" json:"enabled"` // 默认启用
+
+	File string `yaml:"-" json:"file"`
+}
+
+// GitOpsSpec 配置目录中所有 YAML 文件合并后的结果
+type GitOpsSpec struct {
+	Tasks []GitOpsTask
+	Envs  []GitOpsEnv
+}
+
+// gitopsFile 单个 YAML 文件的结构
+type gitopsFile struct {
+	Tasks []GitOpsTask `yaml:"tasks"`
+	Envs  []GitOpsEnv  `yaml:"envs"`
+}
+
+// GitOpsChange 单个字段的差异
+type GitOpsChange struct {
+	Field string `json:"field"`
+	Panel string `json:"panel"`
+	Repo  string `json:"repo"`
+}
+
+// LoadGitOpsSpec 读取目录下所有 .yaml / .yml 文件，跳过隐藏目录，同名资源视为错误
+func LoadGitOpsSpec(dir string) (*GitOpsSpec, error) {
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: 配置目录 %s 不存在", ErrGitOpsSpec, dir)
+	}
+
+	spec := &GitOpsSpec{}
+	taskFiles := map[string]string{}
+	envFiles := map[string]string{}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var file gitopsFile
+		if err := yaml.UnmarshalWithOptions(data, &file, yaml.DisallowUnknownField()); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrGitOpsSpec, rel, err)
+		}
+		for i := range file.Tasks {
+			t := &file.Tasks[i]
+			t.File = rel
+			if err := t.normalize(); err != nil {
+				return fmt.Errorf("%w: %s: 任务 %q: %v", ErrGitOpsSpec, rel, t.Name, err)
+			}
+			if prev, ok := taskFiles[t.Name]; ok {
+				return fmt.Errorf("%w: 任务 %q 在 %s 与 %s 中重复声明", ErrGitOpsSpec, t.Name, prev, rel)
+			}
+			taskFiles[t.Name] = rel
+			spec.Tasks = append(spec.Tasks, *t)
+		}
+		for i := range file.Envs {
+			e := &file.Envs[i]
+			e.File = rel
+			if err := e.normalize(); err != nil {
+				return fmt.Errorf("%w: %s: 变量 %q: %v", ErrGitOpsSpec, rel, e.Name, err)
+			}
+			if prev, ok := envFiles[e.Name]; ok {
+				return fmt.Errorf("%w: 变量 %q 在 %s 与 %s 中重复声明", ErrGitOpsSpec, e.Name, prev, rel)
+			}
+			envFiles[e.Name] = rel
+			spec.Envs = append(spec.Envs, *e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// normalize 校验任务声明并补全默认值
+func (t *GitOpsTask) normalize() error {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Agent = strings.TrimSpace(t.Agent)
+	t.WorkDir = strings.TrimSpace(t.WorkDir)
+	t.Schedule = strings.TrimSpace(t.Schedule)
+	if t.Name == "" {
+		return errors.New("缺少 name")
+	}
+	if strings.TrimSpace(t.Command) == "" {
+		return errors.New("缺少 command")
+	}
+
+	switch t.Trigger {
+	case "":
+		t.Trigger = constant.TriggerTypeCron
+	case constant.TriggerTypeCron, constant.TriggerTypeBaihuStartup:
+	default:
+		return fmt.Errorf("不支持的 trigger: %s", t.Trigger)
+	}
+	if t.Trigger == constant.TriggerTypeCron {
+		if t.Schedule == "" {
+			return errors.New("定时任务缺少 schedule")
+		}
+		if !strings.HasPrefix(t.Schedule, "@") && len(strings.Fields(t.Schedule)) != 6 {
+			return errors.New("schedule 必须为 6 位 (秒 分 时 日 月 周)")
+		}
+		parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		if _, err := parser.Parse(t.Schedule); err != nil {
+			return fmt.Errorf("schedule 无效: %v", err)
+		}
+	} else {
+		t.Schedule = ""
+	}
+
+	if t.Timeout == 0 {
+		t.Timeout = gitopsDefaultTimeout
+	}
+	if t.Timeout < 0 || t.RetryCount < 0 || t.RetryInterval < 0 || t.RandomRange < 0 {
+		return errors.New("timeout、retry_count、retry_interval、random_range 不能为负数")
+	}
+	if t.Enabled == nil {
+		enabled := true
+		t.Enabled = &enabled
+	}
+
+	t.Tags = gitopsNameList(t.Tags)
+	t.Envs = gitopsNameList(t.Envs)
+	for i := range t.Notify {
+		n := &t.Notify[i]
+		n.Channel = strings.TrimSpace(n.Channel)
+		if n.Channel == "" {
+			return errors.New("notify 缺少 channel")
+		}
+		if len(n.Events) == 0 {
+			return fmt.Errorf("通知渠道 %s 未指定 events", n.Channel)
+		}
+		for j, ev := range n.Events {
+			ev = strings.TrimSpace(ev)
+			if full, ok := gitopsEventAliases[ev]; ok {
+				ev = full
+			}
+			if ev != constant.EventTaskSuccess && ev != constant.EventTaskFailed && ev != constant.EventTaskTimeout {
+				return fmt.Errorf("不支持的通知事件: %s", n.Events[j])
+			}
+			n.Events[j] = ev
+		}
+	}
+	return nil
+}
+
+// normalize 校验变量声明并补全默认值
+func (e *GitOpsEnv) normalize() error {
+	e.Name = strings.TrimSpace(e.Name)
+	if e.Name == "" {
+		return errors.New("缺少 name")
+	}
+	if strings.ContainsAny(e.Name, "= \t\n") {
+		return errors.New("名称不能包含等号或空白字符")
+	}
+	if e.Enabled == nil {
+		enabled := true
+		e.Enabled = &enabled
+	}
+	return nil
+}
+
+// fields 任务的规范化字段，agent 与通知渠道需事先解析为面板中的名称
+func (t *GitOpsTask) fields() map[string]string {
+	var notify []string
+	for _, n := range t.Notify {
+		for _, ev := range n.Events {
+			notify = append(notify, n.Channel+":"+ev)
+		}
+	}
+	return map[string]string{
+		"remark":         t.Remark,
+		"command":        t.Command,
+		"pre_command":    t.PreCommand,
+		"post_command":   t.PostCommand,
+		"trigger":        t.Trigger,
+		"schedule":       t.Schedule,
+		"enabled":        strconv.FormatBool(*t.Enabled),
+		"timeout":        strconv.Itoa(t.Timeout),
+		"work_dir":       gitopsWorkDir(t.WorkDir, t.Agent != ""),
+		"retry_count":    strconv.Itoa(t.RetryCount),
+		"retry_interval": strconv.Itoa(t.RetryInterval),
+		"random_range":   strconv.Itoa(t.RandomRange),
+		"tags":           strings.Join(gitopsNameList(t.Tags), ","),
+		"envs":           strings.Join(gitopsNameList(t.Envs), ","),
+		"agent":          t.Agent,
+		"notify":         strings.Join(gitopsNameList(notify), ","),
+	}
+}
+
+// fields 变量的规范化字段
+func (e *GitOpsEnv) fields() map[string]string {
+	return map[string]string{
+		"value":   e.Value,
+		"remark":  e.Remark,
+		"enabled": strconv.FormatBool(*e.Enabled),
+	}
+}
+
+// gitopsWorkDir 规范化工作目录：本机任务留空时为脚本目录，相对路径基于脚本目录并使用占位符；Agent 任务保持原样
+func gitopsWorkDir(dir string, agent bool) string {
+	if agent {
+		return dir
+	}
+	if dir == "" {
+		return constant.ScriptsDirPlaceholder
+	}
+	if strings.HasPrefix(dir, constant.ScriptsDirPlaceholder) || filepath.IsAbs(dir) {
+		return dir
+	}
+	return constant.ScriptsDirPlaceholder + "/" + filepath.ToSlash(filepath.Clean(dir))
+}
+
+// gitopsNameList 去除空白与重复项并排序
+func gitopsNameList(list []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// gitopsHash 计算规范化字段的摘要，用于检测面板中的修改
+func gitopsHash(fields map[string]string, names []string) string {
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(fields[name]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// gitopsDiff 按字段顺序列出面板与仓库不一致的字段
+func gitopsDiff(panel, repo map[string]string, names []string) []GitOpsChange {
+	var changes []GitOpsChange
+	for _, name := range names {
+		if panel[name] != repo[name] {
+			changes = append(changes, GitOpsChange{Field: name, Panel: panel[name], Repo: repo[name]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+func writeGitOpsFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadGitOpsSpec(t *testing.T) {
+	dir := t.TempDir()
+	writeGitOpsFile(t, dir, "tasks/daily.yaml", `
+tasks:
+  - name: 签到
+    command: node sign.js
+    schedule: "0 0 8 * * *"
+    tags: [daily, " sign ", daily]
+    envs: [TOKEN]
+    notify:
+      - channel: tg
+        events: [failed, task_timeout]
+  - name: 启动清理
+    command: rm -rf /tmp/cache
+    trigger: baihu_startup
+    schedule: "0 0 8 * * *"
+    enabled: false
+`)
+	writeGitOpsFile(t, dir, "envs.yml", `
+envs:
+  - name: TOKEN
+    value: abc
+`)
+	writeGitOpsFile(t, dir, ".github/ci.yaml", "on: push\n")
+	writeGitOpsFile(t, dir, "README.md", "# not yaml")
+
+	spec, err := LoadGitOpsSpec(dir)
+	if err != nil {
+		t.Fatalf("LoadGitOpsSpec: %v", err)
+	}
+	if len(spec.Tasks) != 2 || len(spec.Envs) != 1 {
+		t.Fatalf("got %d tasks, %d envs", len(spec.Tasks), len(spec.Envs))
+	}
+
+	sign := spec.Tasks[0]
+	if sign.File != "tasks/daily.yaml" || sign.Trigger != constant.TriggerTypeCron || sign.Timeout != gitopsDefaultTimeout || !*sign.Enabled {
+		t.Errorf("defaults not applied: %+v", sign)
+	}
+	if len(sign.Tags) != 2 || sign.Tags[0] != "daily" || sign.Tags[1] != "sign" {
+		t.Errorf("tags = %v", sign.Tags)
+	}
+	if sign.Notify[0].Events[0] != constant.EventTaskFailed || sign.Notify[0].Events[1] != constant.EventTaskTimeout {
+		t.Errorf("events = %v", sign.Notify[0].Events)
+	}
+
+	startup := spec.Tasks[1]
+	if startup.Schedule != "" || *startup.Enabled {
+		t.Errorf("startup task = %+v", startup)
+	}
+	if !*spec.Envs[0].Enabled {
+		t.Error("envs should be enabled by default")
+	}
+}
+
+func TestLoadGitOpsSpecErrors(t *testing.T) {
+	cases := map[string]map[string]string{
+		"duplicate": {
+			"a.yaml": "tasks:\n  - {name: a, command: x, schedule: '0 * * * * *'}\n",
+			"b.yaml": "tasks:\n  - {name: a, command: y, schedule: '0 * * * * *'}\n",
+		},
+		"unknown field": {"a.yaml": "tasks:\n  - {name: a, command: x, schedule: '0 * * * * *', cron: x}\n"},
+		"five fields":   {"a.yaml": "tasks:\n  - {name: a, command: x, schedule: '* * * * *'}\n"},
+		"no command":    {"a.yaml": "tasks:\n  - {name: a, schedule: '0 * * * * *'}\n"},
+		"bad event":     {"a.yaml": "tasks:\n  - {name: a, command: x, schedule: '@daily', notify: [{channel: tg, events: [login]}]}\n"},
+		"bad env name":  {"a.yaml": "envs:\n  - {name: 'A=B', value: x}\n"},
+	}
+	for name, files := range cases {
+		dir := t.TempDir()
+		for file, content := range files {
+			writeGitOpsFile(t, dir, file, content)
+		}
+		if _, err := LoadGitOpsSpec(dir); !errors.Is(err, ErrGitOpsSpec) {
+			t.Errorf("%s: expected ErrGitOpsSpec, got %v", name, err)
+		}
+	}
+	if _, err := LoadGitOpsSpec(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrGitOpsSpec) {
+		t.Errorf("missing dir: expected ErrGitOpsSpec, got %v", err)
+	}
+}
+
+func TestGitOpsWorkDir(t *testing.T) {
+	cases := []struct {
+		dir   string
+		agent bool
+		want  string
+	}{
+		{"", false, constant.ScriptsDirPlaceholder},
+		{"jd/", false, constant.ScriptsDirPlaceholder + "/jd"},
+		{"/opt/app", false, "/opt/app"},
+		{constant.ScriptsDirPlaceholder + "/ql", false, constant.ScriptsDirPlaceholder + "/ql"},
+		{"", true, ""},
+		{"jd", true, "jd"},
+	}
+	for _, c := range cases {
+		if got := gitopsWorkDir(c.dir, c.agent); got != c.want {
+			t.Errorf("gitopsWorkDir(%q, %v) = %q, want %q", c.dir, c.agent, got, c.want)
+		}
+	}
+}
+
+func TestGitOpsDiffAndHash(t *testing.T) {
+	enabled := true
+	env := GitOpsEnv{Name: "A", Value: "1", Enabled: &enabled}
+	repo := env.fields()
+	panel := map[string]string{"value": "2", "remark": "", "enabled": "true"}
+
+	changes := gitopsDiff(panel, repo, gitopsEnvFields)
+	if len(changes) != 1 || changes[0].Field != "value" || changes[0].Panel != "2" || changes[0].Repo != "1" {
+		t.Errorf("changes = %+v", changes)
+	}
+	if gitopsHash(panel, gitopsEnvFields) == gitopsHash(repo, gitopsEnvFields) {
+		t.Error("different fields should hash differently")
+	}
+	panel["value"] = "1"
+	if gitopsHash(panel, gitopsEnvFields) != gitopsHash(repo, gitopsEnvFields) {
+		t.Error("equal fields should hash equally")
+	}
+	if a, b := gitopsHash(map[string]string{"value": "a\x00b"}, gitopsEnvFields), gitopsHash(map[string]string{"value": "a", "remark": "b"}, gitopsEnvFields); a == b {
+		t.Error("hash must not collide across field boundaries")
+	}
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/backupstore"
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// legacyKeyLabel 旧格式密文（不含密钥 ID）在统计中的名称
+const legacyKeyLabel = "legacy"
+
+// RotateKeyOptions 主密钥轮换选项
+type RotateKeyOptions struct {
+	DryRun           bool // 只统计，不写入
+	SkipBackups      bool // 不处理备份目录中的 zip 备份
+	EncryptPlaintext bool // 无法解密的值视为未加密的明文并用新密钥加密
+}
+
+// RotateKeyBackup 单个备份文件的轮换结果
+type RotateKeyBackup struct {
+	Path      string
+	Rotated   int
+	Failed    []string
+	Encrypted bool // 口令加密的备份无法改写，原样保留
+}
+
+// RotateKeyReport 主密钥轮换结果
+type RotateKeyReport struct {
+	KeyID          string
+	Total          int
+	Current        int            // 已使用当前主密钥，无需处理
+	Rotated        int            // 重新加密
+	Plaintext      int            // 按明文重新加密
+	ByKey          map[string]int // 处理前各密钥 ID 下的机密数量
+	Versions       int            // 重新加密的机密历史版本
+	Failed         []string
+	Backups        []RotateKeyBackup
+	BackupsSkipped bool
+}
+
+// HasFailures 是否存在无法解密的机密
+func (r *RotateKeyReport) HasFailures() bool {
+	if len(r.Failed) > 0 {
+		return true
+	}
+	for _, b := range r.Backups {
+		if len(b.Failed) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// reencryptSecret 用当前主密钥重新加密，返回新密文及是否按明文处理
+func reencryptSecret(value string, encryptPlaintext bool) (string, bool, error) {
+	plaintext, err := utils.Decrypt(value)
+	asPlaintext := false
+	if err != nil {
+		if !encryptPlaintext || utils.CiphertextKeyID(value) != "" {
+			return "", false, err
+		}
+		plaintext, asPlaintext = value, true
+	}
+	enc, err := utils.Encrypt(plaintext)
+	return enc, asPlaintext, err
+}
+
+// RotateSecretKey 使用当前主密钥（BAIHU_SECRET_KEY）重新加密全部机密及备份中的机密
+// 旧密钥通过 BAIHU_SECRET_KEY_PREVIOUS 提供；任一机密无法解密时整体放弃，不做部分写入
+func RotateSecretKey(opts RotateKeyOptions) (*RotateKeyReport, error) {
+	if !utils.IsSecretKeySet() {
+		return nil, utils.ErrKeyNotSet
+	}
+	keyID := utils.SecretKeyID()
+	report := &RotateKeyReport{KeyID: keyID, ByKey: map[string]int{}, BackupsSkipped: opts.SkipBackups}
+
+	var envs []models.EnvironmentVariable
+	if err := database.DB.Where("type = ?", constant.EnvTypeSecret).Find(&envs).Error; err != nil {
+		return nil, err
+	}
+	updates := make(map[string]string)
+	for _, env := range envs {
+		value := string(env.Value)
+		if value == "" {
+			continue
+		}
+		report.Total++
+		id := utils.CiphertextKeyID(value)
+		if id == "" {
+			id = legacyKeyLabel
+		}
+		report.ByKey[id]++
+		if id == keyID {
+			report.Current++
+			continue
+		}
+		enc, plaintext, err := reencryptSecret(value, opts.EncryptPlaintext)
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s (#%s): %v", env.Name, env.ID, err))
+			continue
+		}
+		if plaintext {
+			report.Plaintext++
+		} else {
+			report.Rotated++
+		}
+		updates[env.ID] = enc
+	}
+
+	// 机密的历史版本同样保存密文，需一并轮换，否则移除旧密钥后无法回滚
+	var versions []models.EnvVersion
+	if err := database.DB.Where("type = ?", constant.EnvTypeSecret).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	versionUpdates := make(map[string]string)
+	for _, v := range versions {
+		value := string(v.Value)
+		if value == "" || utils.CiphertextKeyID(value) == keyID {
+			continue
+		}
+		enc, _, err := reencryptSecret(value, opts.EncryptPlaintext)
+		if err != nil {
+			report.Failed = append(report.Failed, fmt.Sprintf("%s v%d (#%s): %v", v.Name, v.Version, v.EnvID, err))
+			continue
+		}
+		versionUpdates[v.ID] = enc
+	}
+	report.Versions = len(versionUpdates)
+
+	// 备份先写入临时文件，数据库提交成功后再替换
+	var tempFiles map[string]string
+	if !opts.SkipBackups {
+		paths, _ := filepath.Glob(filepath.Join(BackupDir, "*.zip"))
+		sort.Strings(paths)
+		tempFiles = make(map[string]string)
+		defer func() {
+			for _, tmp := range tempFiles {
+				os.Remove(tmp)
+			}
+		}()
+		for _, path := range paths {
+			result, tmp, err := rotateBackupSecrets(path, opts)
+			if err != nil {
+				result.Failed = append(result.Failed, err.Error())
+			}
+			report.Backups = append(report.Backups, result)
+			if tmp != "" {
+				tempFiles[path] = tmp
+			}
+		}
+	}
+
+	if report.HasFailures() {
+		return report, errors.New("存在无法解密的机密，已放弃轮换，请通过 BAIHU_SECRET_KEY_PREVIOUS 提供全部旧密钥")
+	}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for id, value := range updates {
+			if err := tx.Model(&models.EnvironmentVariable{}).Where("id = ?", id).
+				Update("value", models.BigText(value)).Error; err != nil {
+				return err
+			}
+		}
+		for id, value := range versionUpdates {
+			if err := tx.Model(&models.EnvVersion{}).Where("id = ?", id).
+				Update("value", models.BigText(value)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("写入数据库失败，已回滚: %w", err)
+	}
+
+	for path, tmp := range tempFiles {
+		if err := os.Rename(tmp, path); err != nil {
+			return report, fmt.Errorf("数据库已完成轮换，但替换备份 %s 失败: %w", path, err)
+		}
+		delete(tempFiles, path)
+	}
+	ClearSecretCache()
+	return report, nil
+}
+
+// rotateBackupSecrets 重新加密备份包 envs.json 中的机密，其余文件原样复制
+// 返回的临时文件路径为空表示无需改写
+func rotateBackupSecrets(path string, opts RotateKeyOptions) (RotateKeyBackup, string, error) {
+	result := RotateKeyBackup{Path: path}
+	if f, err := os.Open(path); err == nil {
+		header := make([]byte, 8)
+		n, _ := io.ReadFull(f, header)
+		f.Close()
+		if backupstore.IsEncrypted(header[:n]) {
+			result.Encrypted = true
+			return result, "", nil
+		}
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return result, "", err
+	}
+	defer r.Close()
+
+	var envsFile, manifestFile *zip.File
+	for _, f := range r.File {
+		switch f.Name {
+		case "envs.json":
+			envsFile = f
+		case backupManifestName:
+			manifestFile = f
+		}
+	}
+	if envsFile == nil {
+		return result, "", nil
+	}
+
+	rc, err := envsFile.Open()
+	if err != nil {
+		return result, "", err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return result, "", err
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return result, "", fmt.Errorf("解析 envs.json 失败: %w", err)
+	}
+
+	keyID := utils.SecretKeyID()
+	for _, item := range items {
+		value, _ := item["value"].(string)
+		if item["type"] != constant.EnvTypeSecret || value == "" || utils.CiphertextKeyID(value) == keyID {
+			continue
+		}
+		enc, _, err := reencryptSecret(value, opts.EncryptPlaintext)
+		if err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%v (#%v): %v", item["name"], item["id"], err))
+			continue
+		}
+		item["value"] = enc
+		result.Rotated++
+	}
+	if result.Rotated == 0 || len(result.Failed) > 0 || opts.DryRun {
+		return result, "", nil
+	}
+
+	newEnvs, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return result, "", err
+	}
+	// 清单中同步更新 envs.json 的摘要，否则恢复时无法通过完整性校验
+	var newManifest []byte
+	if manifestFile != nil {
+		if newManifest, err = rewriteManifestEntry(manifestFile, "envs.json", newEnvs); err != nil {
+			return result, "", err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), ".zip")+"-*.zip")
+	if err != nil {
+		return result, "", err
+	}
+	zw := zip.NewWriter(tmp)
+	for _, f := range r.File {
+		var data []byte
+		switch f {
+		case envsFile:
+			data = newEnvs
+		case manifestFile:
+			data = newManifest
+		default:
+			err = zw.Copy(f)
+		}
+		if data != nil {
+			header := f.FileHeader
+			var w io.Writer
+			if w, err = zw.CreateHeader(&header); err == nil {
+				_, err = w.Write(data)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return result, "", err
+	}
+	return result, tmp.Name(), nil
+}
+
+// rewriteManifestEntry 返回将 name 的摘要替换为 data 后的清单内容
+func rewriteManifestEntry(f *zip.File, name string, data []byte) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	var manifest backupManifest
+	err = json.NewDecoder(rc).Decode(&manifest)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("解析备份清单失败: %w", err)
+	}
+	if manifest.Files == nil {
+		return nil, fmt.Errorf("备份清单缺少文件列表")
+	}
+	sum := sha256.Sum256(data)
+	manifest.Files[name] = backupManifestEntry{Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+	return json.MarshalIndent(manifest, "", "  ")
+}
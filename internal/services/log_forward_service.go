@@ -0,0 +1,450 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/eventbus"
+	"github.com/engigu/baihu-panel/internal/logforward"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	logForwardMaxLines  = 2000        // 单次运行最多转发的输出行数，超出时保留末尾
+	logForwardWarnEvery = time.Minute // 队列溢出告警的最小间隔
+)
+
+// LogForwarderInput 创建或修改转发目标的参数
+type LogForwarderInput struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Address  string `json:"address"`
+	Protocol string `json:"protocol"`
+	Headers  string `json:"headers"`
+	Labels   string `json:"labels"`
+	Sources  string `json:"sources"`
+	Enabled  *bool  `json:"enabled"`
+}
+
+type activeForwarder struct {
+	name      string
+	forwarder *logforward.Forwarder
+	sources   map[string]bool
+	labels    map[string]string
+
+	mu       sync.Mutex
+	lastWarn time.Time
+}
+
+// LogForwardService 订阅任务结束与应用日志事件，攒批推送到 syslog、Loki 或 HTTP 接收端
+type LogForwardService struct {
+	mu         sync.RWMutex
+	forwarders []*activeForwarder
+}
+
+func NewLogForwardService() *LogForwardService {
+	return &LogForwardService{}
+}
+
+// SubscribeEvents 订阅任务结束与应用日志事件
+func (s *LogForwardService) SubscribeEvents(bus *eventbus.EventBus) {
+	bus.Subscribe(constant.EventTaskFinished, s.handleTaskFinished)
+	bus.Subscribe(constant.EventAppLogAdded, s.handleAppLog)
+}
+
+// List 返回全部转发目标
+func (s *LogForwardService) List() []models.LogForwarder {
+	var list []models.LogForwarder
+	database.DB.Order("created_at").Find(&list)
+	return list
+}
+
+// Create 创建转发目标
+func (s *LogForwardService) Create(input LogForwarderInput) (*models.LogForwarder, error) {
+	f := &models.LogForwarder{ID: utils.GenerateID(), Enabled: utils.BoolPtr(true)}
+	if err := applyLogForwarderInput(f, input); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(f).Error; err != nil {
+		return nil, err
+	}
+	s.Reload()
+	return f, nil
+}
+
+// Update 修改转发目标
+func (s *LogForwardService) Update(id string, input LogForwarderInput) (*models.LogForwarder, error) {
+	f, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyLogForwarderInput(f, input); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Select("name", "type", "address", "protocol", "headers", "labels", "sources", "enabled").Save(f).Error; err != nil {
+		return nil, err
+	}
+	s.Reload()
+	return f, nil
+}
+
+// Delete 删除转发目标
+func (s *LogForwardService) Delete(id string) error {
+	if err := database.DB.Where("id = ?", id).Delete(&models.LogForwarder{}).Error; err != nil {
+		return err
+	}
+	s.Reload()
+	return nil
+}
+
+// Test 向转发目标同步发送一条测试日志
+func (s *LogForwardService) Test(id string) error {
+	f, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	sink, err := newLogForwardSink(f)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+	labels, _ := parseForwardLabels(f.Labels)
+	ctx, cancel := context.WithTimeout(context.Background(), logforward.DefaultOptions().Timeout)
+	defer cancel()
+	return sink.Send(ctx, []logforward.Record{{
+		Time:    time.Now(),
+		Source:  constant.LogForwardSourceAppLog,
+		Level:   logforward.LevelInfo,
+		Message: fmt.Sprintf("白虎面板日志转发测试：%s", f.Name),
+		Labels:  labels,
+	}})
+}
+
+func (s *LogForwardService) get(id string) (*models.LogForwarder, error) {
+	var f models.LogForwarder
+	res := database.DB.Where("id = ?", id).Limit(1).Find(&f)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, &ServiceError{Message: "转发目标不存在"}
+	}
+	return &f, nil
+}
+
+// Reload 按数据库中的配置重建转发器，旧转发器在后台发送完剩余日志后关闭
+func (s *LogForwardService) Reload() {
+	var list []models.LogForwarder
+	database.DB.Where("enabled = ?", true).Find(&list)
+
+	next := make([]*activeForwarder, 0, len(list))
+	for i := range list {
+		f := &list[i]
+		sink, err := newLogForwardSink(f)
+		if err != nil {
+			logger.Warnf("[LogForward] 转发目标 %s 配置无效: %v", f.Name, err)
+			continue
+		}
+		labels, _ := parseForwardLabels(f.Labels)
+		sources := map[string]bool{}
+		for _, src := range SplitScopes(f.Sources) {
+			sources[src] = true
+		}
+		id, name := f.ID, f.Name
+		next = append(next, &activeForwarder{
+			name:      name,
+			forwarder: logforward.New(sink, logforward.DefaultOptions(), func(sent int, err error) { recordForwardResult(id, name, sent, err) }),
+			sources:   sources,
+			labels:    labels,
+		})
+	}
+
+	s.mu.Lock()
+	old := s.forwarders
+	s.forwarders = next
+	s.mu.Unlock()
+
+	for _, af := range old {
+		go af.forwarder.Close()
+	}
+}
+
+func (s *LogForwardService) dispatch(source string, records []logforward.Record) {
+	s.mu.RLock()
+	list := s.forwarders
+	s.mu.RUnlock()
+
+	for _, af := range list {
+		if !af.sources[source] {
+			continue
+		}
+		batch := records
+		if len(af.labels) > 0 {
+			batch = make([]logforward.Record, len(records))
+			for i, r := range records {
+				// 固定标签不覆盖任务 ID、状态等内置标签
+				labels := maps.Clone(af.labels)
+				maps.Copy(labels, r.Labels)
+				r.Labels = labels
+				batch[i] = r
+			}
+		}
+		if !af.forwarder.Enqueue(batch...) {
+			af.mu.Lock()
+			if time.Since(af.lastWarn) >= logForwardWarnEvery {
+				af.lastWarn = time.Now()
+				logger.Warnf("[LogForward] 转发目标 %s 队列已满，累计丢弃 %d 条日志", af.name, af.forwarder.Dropped())
+			}
+			af.mu.Unlock()
+		}
+	}
+}
+
+func (s *LogForwardService) hasSource(source string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, af := range s.forwarders {
+		if af.sources[source] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *LogForwardService) handleTaskFinished(event eventbus.Event) {
+	taskLog, ok := event.Payload.(*models.TaskLog)
+	if !ok || !s.hasSource(constant.LogForwardSourceTask) {
+		return
+	}
+	s.dispatch(constant.LogForwardSourceTask, taskLogRecords(taskLog))
+}
+
+func (s *LogForwardService) handleAppLog(event eventbus.Event) {
+	appLog, ok := event.Payload.(*models.AppLog)
+	if !ok || !s.hasSource(constant.LogForwardSourceAppLog) {
+		return
+	}
+	message := appLog.Title
+	if content := strings.TrimSpace(string(appLog.Content)); content != "" {
+		message += "\n" + content
+	}
+	labels := map[string]string{"category": appLog.Category}
+	if appLog.Status != "" {
+		labels["status"] = appLog.Status
+	}
+	if appLog.RefID != "" {
+		labels["ref_id"] = appLog.RefID
+	}
+	level := appLog.Level
+	if level == "" {
+		level = logforward.LevelInfo
+	}
+	at := time.Time(appLog.CreatedAt)
+	if at.IsZero() {
+		at = time.Now()
+	}
+	s.dispatch(constant.LogForwardSourceAppLog, []logforward.Record{{
+		Time:    at,
+		Source:  constant.LogForwardSourceAppLog,
+		Level:   level,
+		Message: message,
+		Labels:  labels,
+	}})
+}
+
+// taskLogRecords 将一次运行转换为逐行日志加一条结束汇总，行时间取自逐行时间信息
+func taskLogRecords(taskLog *models.TaskLog) []logforward.Record {
+	base := map[string]string{
+		"task_id": taskLog.TaskID,
+		"log_id":  taskLog.ID,
+		"status":  taskLog.Status,
+		"agent":   "local",
+	}
+	var task models.Task
+	if database.DB.Select("name").Where("id = ?", taskLog.TaskID).Limit(1).Find(&task).RowsAffected > 0 {
+		base["task_name"] = task.Name
+	}
+	if taskLog.AgentID != nil && *taskLog.AgentID != "" {
+		base["agent"] = *taskLog.AgentID
+		var agent models.Agent
+		if database.DB.Select("name").Where("id = ?", *taskLog.AgentID).Limit(1).Find(&agent).RowsAffected > 0 && agent.Name != "" {
+			base["agent"] = agent.Name
+		}
+	}
+
+	start := time.Now()
+	if taskLog.StartTime != nil {
+		start = time.Time(*taskLog.StartTime)
+	}
+	end := start
+	if taskLog.EndTime != nil {
+		end = time.Time(*taskLog.EndTime)
+	}
+
+	output, err := logstore.ReadOutput(taskLog)
+	if err != nil {
+		logger.Warnf("[LogForward] 读取日志 #%s 失败: %v", taskLog.ID, err)
+	}
+	var lines []utils.LogLine
+	if metas, err := utils.DecodeLineMeta(string(taskLog.LineMeta)); err == nil && len(metas) > 0 {
+		lines = utils.SplitLogLines(output, metas)
+	} else if output != "" {
+		// 没有逐行时间信息（如 Agent 任务），统一使用开始时间
+		for _, text := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+			lines = append(lines, utils.LogLine{Stream: utils.LogStreamStdout, Text: strings.TrimSuffix(text, "\r")})
+		}
+	}
+	if len(lines) > logForwardMaxLines {
+		lines = lines[len(lines)-logForwardMaxLines:]
+	}
+
+	streamLabels := map[string]map[string]string{}
+	records := make([]logforward.Record, 0, len(lines)+1)
+	for _, line := range lines {
+		text := utils.StripAnsi(line.Text)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		labels, ok := streamLabels[line.Stream]
+		if !ok {
+			labels = maps.Clone(base)
+			labels["stream"] = line.Stream
+			streamLabels[line.Stream] = labels
+		}
+		level := logforward.LevelInfo
+		if line.Stream == utils.LogStreamStderr {
+			level = logforward.LevelWarning
+		}
+		records = append(records, logforward.Record{
+			Time:    start.Add(time.Duration(line.Offset) * time.Millisecond),
+			Source:  constant.LogForwardSourceTask,
+			Level:   level,
+			Message: text,
+			Labels:  labels,
+		})
+	}
+
+	level := logforward.LevelInfo
+	switch taskLog.Status {
+	case constant.TaskStatusFailed, constant.TaskStatusTimeout:
+		level = logforward.LevelError
+	case constant.TaskStatusCancelled:
+		level = logforward.LevelWarning
+	}
+	summary := fmt.Sprintf("任务 %s 运行结束：状态 %s，耗时 %dms，退出码 %d，日志 #%s", base["task_name"], taskLog.Status, taskLog.Duration, taskLog.ExitCode, taskLog.ID)
+	if taskLog.Error != "" {
+		summary += "，错误: " + string(taskLog.Error)
+	}
+	records = append(records, logforward.Record{
+		Time:    end,
+		Source:  constant.LogForwardSourceTask,
+		Level:   level,
+		Message: summary,
+		Labels:  base,
+	})
+	return records
+}
+
+func recordForwardResult(id, name string, sent int, err error) {
+	if err != nil {
+		logger.Warnf("[LogForward] 转发目标 %s 发送 %d 条日志失败: %v", name, sent, err)
+		msg := []rune(err.Error())
+		if len(msg) > 500 {
+			msg = msg[:500]
+		}
+		database.DB.Model(&models.LogForwarder{}).Where("id = ?", id).UpdateColumn("last_error", string(msg))
+		return
+	}
+	now := models.Now()
+	database.DB.Model(&models.LogForwarder{}).Where("id = ?", id).UpdateColumns(map[string]any{
+		"last_error":   "",
+		"last_sent_at": &now,
+		"sent_count":   gorm.Expr("sent_count + ?", sent),
+	})
+}
+
+// applyLogForwarderInput 校验并写入转发目标配置
+func applyLogForwarderInput(f *models.LogForwarder, input LogForwarderInput) error {
+	f.Name = strings.TrimSpace(input.Name)
+	f.Type = strings.TrimSpace(input.Type)
+	f.Address = strings.TrimSpace(input.Address)
+	f.Protocol = strings.ToLower(strings.TrimSpace(input.Protocol))
+	f.Headers = models.BigText(strings.TrimSpace(input.Headers))
+	f.Labels = strings.TrimSpace(input.Labels)
+	if input.Enabled != nil {
+		f.Enabled = input.Enabled
+	}
+	if f.Name == "" {
+		return &ServiceError{Message: "名称不能为空"}
+	}
+	if f.Type != constant.LogForwardSyslog {
+		f.Protocol = ""
+	} else if f.Protocol == "" {
+		f.Protocol = "udp"
+	}
+
+	var sources []string
+	for _, src := range SplitScopes(input.Sources) {
+		if src != constant.LogForwardSourceTask && src != constant.LogForwardSourceAppLog {
+			return &ServiceError{Message: "无效的转发来源: " + src}
+		}
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		return &ServiceError{Message: "请至少选择一个转发来源"}
+	}
+	f.Sources = strings.Join(sources, ",")
+
+	if _, err := parseForwardLabels(f.Labels); err != nil {
+		return err
+	}
+	sink, err := newLogForwardSink(f)
+	if err != nil {
+		return &ServiceError{Message: err.Error()}
+	}
+	sink.Close()
+	return nil
+}
+
+func newLogForwardSink(f *models.LogForwarder) (logforward.Sink, error) {
+	switch f.Type {
+	case constant.LogForwardSyslog:
+		return logforward.NewSyslogSink(f.Protocol, f.Address)
+	case constant.LogForwardLoki, constant.LogForwardHTTP:
+		headers, err := logforward.ParseHeaders(string(f.Headers))
+		if err != nil {
+			return nil, err
+		}
+		if f.Type == constant.LogForwardLoki {
+			return logforward.NewLokiSink(f.Address, headers)
+		}
+		return logforward.NewHTTPSink(f.Address, headers)
+	default:
+		return nil, fmt.Errorf("不支持的转发类型: %s", f.Type)
+	}
+}
+
+// parseForwardLabels 解析逗号分隔的 key=value 固定标签
+func parseForwardLabels(text string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range SplitScopes(text) {
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || key == "" || value == "" {
+			return nil, &ServiceError{Message: "标签格式错误，应为 key=value: " + pair}
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/models"
+)
+
+func TestApplyLogForwarderInput(t *testing.T) {
+	f := &models.LogForwarder{}
+	err := applyLogForwarderInput(f, LogForwarderInput{
+		Name: " syslog ", Type: constant.LogForwardSyslog, Address: "127.0.0.1:514",
+		Sources: "task, app_log", Labels: "env=prod, team=ops",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "syslog" || f.Protocol != "udp" || f.Sources != "task,app_log" {
+		t.Fatalf("unexpected forwarder: %+v", f)
+	}
+
+	cases := []LogForwarderInput{
+		{Name: "a", Type: constant.LogForwardSyslog, Address: "no-port", Sources: "task"},
+		{Name: "a", Type: constant.LogForwardLoki, Address: "loki:3100", Sources: "task"},
+		{Name: "a", Type: constant.LogForwardHTTP, Address: "http://x", Sources: "task", Headers: "bad header"},
+		{Name: "a", Type: constant.LogForwardHTTP, Address: "http://x", Sources: "login"},
+		{Name: "a", Type: constant.LogForwardHTTP, Address: "http://x", Sources: "task", Labels: "env"},
+		{Name: "a", Type: "kafka", Address: "x", Sources: "task"},
+		{Name: "", Type: constant.LogForwardHTTP, Address: "http://x", Sources: "task"},
+	}
+	for i, input := range cases {
+		if err := applyLogForwarderInput(&models.LogForwarder{}, input); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestParseForwardLabels(t *testing.T) {
+	labels, err := parseForwardLabels("env=prod, region = cn ,")
+	if err != nil || len(labels) != 2 || labels["env"] != "prod" || labels["region"] != "cn" {
+		t.Fatalf("unexpected labels: %v %v", labels, err)
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
+)
+
+const (
+	logStoreMigrateBatch  = 200 // 每批转存的历史日志条数
+	logStoreMigrateRounds = 10  // 每次定时任务最多转存的批数
+)
+
+// buildLogStores 按设置创建日志存储后端，返回写入后端名称与全部可读后端
+// 本地文件后端始终注册，切换到其他后端后仍能读取此前写入的正文
+func buildLogStores(cfg map[string]string) (string, []logstore.Store, error) {
+	backend := strings.TrimSpace(cfg[constant.KeyLogStoreBackend])
+	dir := strings.TrimSpace(cfg[constant.KeyLogStoreFSDir])
+	if dir == "" {
+		dir = filepath.Join(constant.DataDir, "logs")
+	}
+	list := []logstore.Store{logstore.NewFSStore(dir)}
+
+	s3, err := logstore.NewS3Store(logstore.S3Config{
+		Endpoint:  cfg[constant.KeyLogStoreS3Endpoint],
+		Region:    strings.TrimSpace(cfg[constant.KeyLogStoreS3Region]),
+		Bucket:    cfg[constant.KeyLogStoreS3Bucket],
+		Prefix:    cfg[constant.KeyLogStoreS3Prefix],
+		AccessKey: strings.TrimSpace(cfg[constant.KeyLogStoreS3AccessKey]),
+		SecretKey: strings.TrimSpace(cfg[constant.KeyLogStoreS3SecretKey]),
+		PathStyle: cfg[constant.KeyLogStoreS3PathStyle] == "true",
+	})
+	switch {
+	case err == nil:
+		list = append(list, s3)
+	case backend == constant.LogStoreS3:
+		return "", nil, err
+	}
+
+	switch backend {
+	case constant.LogStoreDB, constant.LogStoreFS, constant.LogStoreS3:
+	default:
+		return "", nil, fmt.Errorf("不支持的日志存储后端: %s", backend)
+	}
+	return backend, list, nil
+}
+
+// ApplyLogStoreSettings 按当前设置重新配置日志存储
+func ApplyLogStoreSettings() error {
+	backend, list, err := buildLogStores(NewSettingsService().GetSection(constant.SectionLogStore))
+	if err != nil {
+		// 配置无效时保留本地文件后端用于读取，新日志保留在数据库
+		logstore.Configure(constant.LogStoreDB, logstore.NewFSStore(filepath.Join(constant.DataDir, "logs")))
+		return err
+	}
+	logstore.Configure(backend, list...)
+	return nil
+}
+
+// ValidateLogStoreSettings 校验待保存的日志存储设置，切换到的后端需能正常读写
+func ValidateLogStoreSettings(values map[string]string) error {
+	cfg := NewSettingsService().GetSection(constant.SectionLogStore)
+	for k, v := range values {
+		cfg[k] = v
+	}
+	backend, list, err := buildLogStores(cfg)
+	if err != nil {
+		return err
+	}
+	for _, s := range list {
+		if s.Name() == backend {
+			if err := logstore.Probe(s); err != nil {
+				return fmt.Errorf("日志存储不可用: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// MigrateInlineLogs 将数据库中内联的历史日志正文转存到当前后端
+// 每次最多处理 logStoreMigrateRounds 批，由定时任务反复调用逐步完成迁移，避免升级后长时间占用数据库
+func MigrateInlineLogs() {
+	store := logstore.Active()
+	if store == nil {
+		return
+	}
+	total := 0
+	for i := 0; i < logStoreMigrateRounds; i++ {
+		n, err := logstore.MigrateInline(logStoreMigrateBatch)
+		total += n
+		if err != nil {
+			logger.Warnf("[LogStore] 转存历史日志失败: %v", err)
+			break
+		}
+		if n < logStoreMigrateBatch {
+			break
+		}
+	}
+	if total > 0 {
+		logger.Infof("[LogStore] 已将 %d 条历史日志正文转存到 %s", total, store.Name())
+	}
+}
@@ -0,0 +1,518 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// WebAuthnSessionTTL 注册/登录挑战的有效期
+const WebAuthnSessionTTL = 5 * time.Minute
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+// recoveryCodeAlphabet 恢复码字符集，去掉易混淆的 0/o/1/l
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// WebAuthn 挑战用途
+const (
+	webauthnPurposeRegister = "register"
+	webauthnPurposeLogin    = "login"
+)
+
+// WebAuthnCredentialResponse 浏览器 navigator.credentials 返回值，二进制字段均为 base64url
+type WebAuthnCredentialResponse struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		AuthenticatorData string   `json:"authenticatorData"`
+		Signature         string   `json:"signature"`
+		UserHandle        string   `json:"userHandle"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// webauthnSessionClaims 挑战会话，签名后交给前端在完成阶段回传
+type webauthnSessionClaims struct {
+	Challenge string `json:"challenge"`
+	Purpose   string `json:"purpose"`
+	UserID    string `json:"user_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// webauthnChallenges 已签发且尚未使用的挑战值，完成阶段消费后即删除，防止同一断言被重放
+var webauthnChallenges = struct {
+	sync.Mutex
+	issued map[string]time.Time // challenge -> 过期时间
+}{issued: make(map[string]time.Time)}
+
+// trustedDeviceClaims 受信任设备凭证，用户的 TrustVersion 变化后全部失效
+type trustedDeviceClaims struct {
+	UserID  string `json:"user_id"`
+	Version int    `json:"version"`
+	jwt.RegisteredClaims
+}
+
+// MFAService 两步验证的扩展能力：通行密钥、恢复码与受信任设备
+type MFAService struct {
+	settingsService *SettingsService
+}
+
+func NewMFAService(settingsService *SettingsService) *MFAService {
+	return &MFAService{settingsService: settingsService}
+}
+
+// HasSecondFactor 用户是否需要进行两步验证
+func (s *MFAService) HasSecondFactor(user *models.User) bool {
+	return user.OtpEnabled || s.CountCredentials(user.ID) > 0
+}
+
+// Methods 用户可用的两步验证方式
+func (s *MFAService) Methods(user *models.User) []string {
+	var methods []string
+	if user.OtpEnabled {
+		methods = append(methods, "totp")
+	}
+	if s.CountCredentials(user.ID) > 0 {
+		methods = append(methods, "webauthn")
+	}
+	if s.RemainingRecoveryCodes(user.ID) > 0 {
+		methods = append(methods, "recovery")
+	}
+	return methods
+}
+
+// ResetSecondFactors 清除用户全部两步验证方式，用于所有验证手段丢失后的应急恢复
+func (s *MFAService) ResetSecondFactors(userID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.WebAuthnCredential{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"otp_secret":    "",
+			"otp_enabled":   false,
+			"trust_version": gorm.Expr("trust_version + 1"),
+		}).Error
+	})
+}
+
+// ---------- 通行密钥 ----------
+
+// ListCredentials 用户已注册的通行密钥
+func (s *MFAService) ListCredentials(userID string) []models.WebAuthnCredential {
+	var creds []models.WebAuthnCredential
+	database.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&creds)
+	return creds
+}
+
+// CountCredentials 用户已注册的通行密钥数量
+func (s *MFAService) CountCredentials(userID string) int64 {
+	var count int64
+	database.DB.Model(&models.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count)
+	return count
+}
+
+// DeleteCredential 删除通行密钥，没有剩余的两步验证方式时一并清理恢复码
+func (s *MFAService) DeleteCredential(userID, id string) error {
+	res := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebAuthnCredential{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("通行密钥不存在")
+	}
+	s.CleanupRecoveryCodes(userID)
+	return nil
+}
+
+// BeginRegistration 生成通行密钥注册参数，返回 PublicKeyCredentialCreationOptions 与挑战会话
+func (s *MFAService) BeginRegistration(rp WebAuthnRP, user *models.User) (map[string]interface{}, string, error) {
+	challenge, session, err := newWebAuthnSession(webauthnPurposeRegister, user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	exclude := make([]map[string]interface{}, 0)
+	for _, cred := range s.ListCredentials(user.ID) {
+		exclude = append(exclude, map[string]interface{}{"type": "public-key", "id": cred.CredentialID})
+	}
+	options := map[string]interface{}{
+		"challenge": challenge,
+		"rp":        map[string]string{"id": rp.ID, "name": rp.Name},
+		"user": map[string]string{
+			"id":          b64url([]byte(user.ID)),
+			"name":        user.Username,
+			"displayName": user.Username,
+		},
+		"pubKeyCredParams": []map[string]interface{}{
+			{"type": "public-key", "alg": coseAlgES256},
+			{"type": "public-key", "alg": coseAlgEdDSA},
+			{"type": "public-key", "alg": coseAlgRS256},
+		},
+		"timeout":     int(WebAuthnSessionTTL.Milliseconds()),
+		"attestation": "none",
+		"authenticatorSelection": map[string]string{
+			"residentKey":      "preferred",
+			"userVerification": "preferred",
+		},
+		"excludeCredentials": exclude,
+	}
+	return options, session, nil
+}
+
+// FinishRegistration 校验注册结果并保存通行密钥
+func (s *MFAService) FinishRegistration(rp WebAuthnRP, userID, session, name string, resp *WebAuthnCredentialResponse) (*models.WebAuthnCredential, error) {
+	claims, err := parseWebAuthnSession(session, webauthnPurposeRegister)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID != userID {
+		return nil, errors.New("注册会话与当前用户不匹配")
+	}
+	clientData, err := decodeB64url(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("clientDataJSON 编码错误")
+	}
+	if err := verifyWebAuthnClientData(clientData, "webauthn.create", claims.Challenge, rp.Origin); err != nil {
+		return nil, err
+	}
+	attestation, err := decodeB64url(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, errors.New("attestationObject 编码错误")
+	}
+	ad, err := parseWebAuthnAttestation(attestation, rp.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	credID := b64url(ad.CredentialID)
+	var exists int64
+	database.DB.Model(&models.WebAuthnCredential{}).Where("credential_id = ?", credID).Count(&exists)
+	if exists > 0 {
+		return nil, errors.New("该通行密钥已注册")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "通行密钥 " + time.Now().Format("2006-01-02")
+	}
+	cred := &models.WebAuthnCredential{
+		ID:           utils.GenerateID(),
+		UserID:       userID,
+		Name:         name,
+		CredentialID: credID,
+		PublicKey:    b64url(ad.PublicKey),
+		SignCount:    ad.SignCount,
+		Transports:   strings.Join(resp.Response.Transports, ","),
+	}
+	if err := database.DB.Create(cred).Error; err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+// BeginLogin 生成登录断言参数；userID 为空时为无密码登录，由认证器自行选择可发现凭证
+func (s *MFAService) BeginLogin(rp WebAuthnRP, userID string) (map[string]interface{}, string, error) {
+	challenge, session, err := newWebAuthnSession(webauthnPurposeLogin, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	options := map[string]interface{}{
+		"challenge":        challenge,
+		"rpId":             rp.ID,
+		"timeout":          int(WebAuthnSessionTTL.Milliseconds()),
+		"userVerification": "preferred",
+	}
+	if userID == "" {
+		options["userVerification"] = "required"
+		return options, session, nil
+	}
+
+	creds := s.ListCredentials(userID)
+	if len(creds) == 0 {
+		return nil, "", errors.New("尚未注册通行密钥")
+	}
+	allow := make([]map[string]interface{}, 0, len(creds))
+	for _, cred := range creds {
+		item := map[string]interface{}{"type": "public-key", "id": cred.CredentialID}
+		if cred.Transports != "" {
+			item["transports"] = strings.Split(cred.Transports, ",")
+		}
+		allow = append(allow, item)
+	}
+	options["allowCredentials"] = allow
+	return options, session, nil
+}
+
+// FinishLogin 校验登录断言，返回通过校验的通行密钥
+func (s *MFAService) FinishLogin(rp WebAuthnRP, session string, resp *WebAuthnCredentialResponse) (*models.WebAuthnCredential, error) {
+	claims, err := parseWebAuthnSession(session, webauthnPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	var cred models.WebAuthnCredential
+	res := database.DB.Where("credential_id = ?", resp.ID).Limit(1).Find(&cred)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, errors.New("通行密钥未注册")
+	}
+	if claims.UserID != "" && cred.UserID != claims.UserID {
+		return nil, errors.New("通行密钥不属于当前用户")
+	}
+
+	clientData, err := decodeB64url(resp.Response.ClientDataJSON)
+	if err != nil {
+		return nil, errors.New("clientDataJSON 编码错误")
+	}
+	if err := verifyWebAuthnClientData(clientData, "webauthn.get", claims.Challenge, rp.Origin); err != nil {
+		return nil, err
+	}
+	authData, err := decodeB64url(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, errors.New("authenticatorData 编码错误")
+	}
+	ad, err := parseWebAuthnAuthData(authData, rp.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 无密码登录时通行密钥同时充当两个因素，必须完成用户验证（PIN / 生物识别）
+	if claims.UserID == "" && ad.Flags&webauthnFlagUserVerified == 0 {
+		return nil, errors.New("无密码登录要求认证器完成用户验证")
+	}
+	sig, err := decodeB64url(resp.Response.Signature)
+	if err != nil {
+		return nil, errors.New("signature 编码错误")
+	}
+	publicKey, err := decodeB64url(cred.PublicKey)
+	if err != nil {
+		return nil, errors.New("通行密钥公钥已损坏")
+	}
+	if err := verifyWebAuthnSignature(publicKey, authData, clientData, sig); err != nil {
+		return nil, err
+	}
+	// 计数器回退说明凭证可能被克隆
+	if (ad.SignCount != 0 || cred.SignCount != 0) && ad.SignCount <= cred.SignCount {
+		return nil, errors.New("签名计数器异常，通行密钥可能已被复制")
+	}
+
+	// 以读取时的计数器为条件更新，并发提交的断言只有一个能成功
+	now := models.LocalTime(time.Now())
+	res = database.DB.Model(&models.WebAuthnCredential{}).Where("id = ? AND sign_count = ?", cred.ID, cred.SignCount).
+		Updates(map[string]interface{}{"sign_count": ad.SignCount, "last_used_at": &now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.New("签名计数器异常，通行密钥可能已被复制")
+	}
+	cred.SignCount, cred.LastUsedAt = ad.SignCount, &now
+	return &cred, nil
+}
+
+// newWebAuthnSession 生成随机挑战值并签名为会话凭证
+func newWebAuthnSession(purpose, userID string) (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	challenge := b64url(buf)
+	claims := webauthnSessionClaims{
+		Challenge: challenge,
+		Purpose:   purpose,
+		UserID:    userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{utils.TokenAudienceWebAuthn},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(WebAuthnSessionTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	session, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(constant.Secret))
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	webauthnChallenges.Lock()
+	for c, exp := range webauthnChallenges.issued {
+		if now.After(exp) {
+			delete(webauthnChallenges.issued, c)
+		}
+	}
+	webauthnChallenges.issued[challenge] = now.Add(WebAuthnSessionTTL)
+	webauthnChallenges.Unlock()
+	return challenge, session, nil
+}
+
+// parseWebAuthnSession 校验挑战会话的签名、有效期与用途，并消费其中的挑战，每个会话只能使用一次
+func parseWebAuthnSession(session, purpose string) (*webauthnSessionClaims, error) {
+	claims := &webauthnSessionClaims{}
+	_, err := jwt.ParseWithClaims(session, claims, func(t *jwt.Token) (any, error) {
+		return []byte(constant.Secret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience(utils.TokenAudienceWebAuthn))
+	if err != nil || claims.Purpose != purpose || claims.Challenge == "" {
+		return nil, errors.New("验证会话无效或已过期，请重试")
+	}
+
+	webauthnChallenges.Lock()
+	exp, ok := webauthnChallenges.issued[claims.Challenge]
+	delete(webauthnChallenges.issued, claims.Challenge)
+	webauthnChallenges.Unlock()
+	if !ok || time.Now().After(exp) {
+		return nil, errors.New("验证会话无效或已过期，请重试")
+	}
+	return claims, nil
+}
+
+// ---------- 恢复码 ----------
+
+// GenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废，明文仅返回这一次
+func (s *MFAService) GenerateRecoveryCodes(userID string) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.UserRecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := randomRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.UserRecoveryCode{
+			ID:       utils.GenerateID(),
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&records).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode 校验并消耗一个恢复码
+func (s *MFAService) UseRecoveryCode(userID, code string) bool {
+	hash := hashRecoveryCode(code)
+	now := models.LocalTime(time.Now())
+	res := database.DB.Model(&models.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", &now)
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+// RemainingRecoveryCodes 剩余可用的恢复码数量
+func (s *MFAService) RemainingRecoveryCodes(userID string) int64 {
+	var count int64
+	database.DB.Model(&models.UserRecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// CleanupRecoveryCodes 用户不再有任何两步验证方式时清理恢复码
+func (s *MFAService) CleanupRecoveryCodes(userID string) {
+	var user models.User
+	if res := database.DB.Where("id = ?", userID).Limit(1).Find(&user); res.RowsAffected == 0 {
+		return
+	}
+	if !s.HasSecondFactor(&user) {
+		database.DB.Where("user_id = ?", userID).Delete(&models.UserRecoveryCode{})
+	}
+}
+
+// randomRecoveryCode 生成 xxxxx-xxxxx 格式的恢复码
+func randomRecoveryCode() (string, error) {
+	// 丢弃不小于字符集长度整数倍的字节，避免取模造成部分字符出现概率偏高
+	limit := 256 - 256%len(recoveryCodeAlphabet)
+	var sb strings.Builder
+	buf := make([]byte, 16)
+	for n := 0; n < 10; {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit || n == 10 {
+				continue
+			}
+			if n == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+			n++
+		}
+	}
+	return sb.String(), nil
+}
+
+// hashRecoveryCode 忽略大小写、空格与连字符后计算摘要
+func hashRecoveryCode(code string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// ---------- 受信任设备 ----------
+
+// TrustedDeviceDays 受信任设备免验证天数，0 表示关闭该功能
+func (s *MFAService) TrustedDeviceDays() int {
+	days, err := strconv.Atoi(s.settingsService.Get(constant.SectionSite, constant.KeyTrustedDeviceDays))
+	if err != nil || days < 0 {
+		return 0
+	}
+	return days
+}
+
+// IssueTrustedDevice 为通过两步验证的浏览器签发受信任设备凭证
+func (s *MFAService) IssueTrustedDevice(user *models.User, days int) (string, error) {
+	claims := trustedDeviceClaims{
+		UserID:  user.ID,
+		Version: user.TrustVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{utils.TokenAudienceTrustedDevice},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(days) * 24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(constant.Secret))
+}
+
+// IsTrustedDevice 判断凭证是否为该用户当前有效的受信任设备
+func (s *MFAService) IsTrustedDevice(user *models.User, token string) bool {
+	if token == "" || s.TrustedDeviceDays() == 0 {
+		return false
+	}
+	claims := &trustedDeviceClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return []byte(constant.Secret), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience(utils.TokenAudienceTrustedDevice))
+	return err == nil && claims.UserID == user.ID && claims.Version == user.TrustVersion
+}
+
+// RevokeTrustedDevices 使该用户所有受信任设备失效
+func (s *MFAService) RevokeTrustedDevices(userID string) error {
+	return database.DB.Model(&models.User{}).Where("id = ?", userID).Update("trust_version", gorm.Expr("trust_version + 1")).Error
+}
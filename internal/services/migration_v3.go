@@ -112,7 +112,7 @@ func RunMigrationV3() error {
 	if len(backups) == 0 {
 		logger.Infof("[MigrationV3] 执行关键备份...")
 		backupService := NewBackupService()
-		zipPath, err := backupService.CreateBackup()
+		zipPath, err := backupService.CreateBackup("")
 		if err != nil {
 			return fmt.Errorf("自动备份失败，流程终止: %v", err)
 		}
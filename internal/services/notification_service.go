@@ -3,18 +3,19 @@ package services
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"sync"
 
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/database"
 	"github.com/engigu/baihu-panel/internal/eventbus"
 	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
 	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/utils"
 
 	"github.com/engigu/baihu-panel/internal/sdk/messenger"
 	"gorm.io/gorm"
-	"regexp"
 	"strings"
 )
 
@@ -30,8 +31,12 @@ type NotifyChannel struct {
 
 // NotifyMessage 通知消息
 type NotifyMessage struct {
-	Title string `json:"title"`
-	Text  string `json:"text"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Markdown string `json:"markdown,omitempty"`
+	HTML     string `json:"html,omitempty"`
+
+	Attachments []messenger.Attachment `json:"-"`
 }
 
 // NotifyResult 发送结果
@@ -58,6 +63,14 @@ var SupportedChannelTypes = []map[string]string{
 	{"type": messenger.ChannelPushPlus, "label": "PushPlus"},
 	{"type": messenger.ChannelVoceChat, "label": "VoceChat"},
 	{"type": messenger.ChannelWxPusher, "label": "WxPusher"},
+	{"type": messenger.ChannelSlack, "label": "Slack"},
+	{"type": messenger.ChannelDiscord, "label": "Discord"},
+	{"type": messenger.ChannelMatrix, "label": "Matrix"},
+	{"type": messenger.ChannelTeams, "label": "Microsoft Teams"},
+	{"type": messenger.ChannelPushover, "label": "Pushover"},
+	{"type": messenger.ChannelServerChan, "label": "Server酱"},
+	{"type": messenger.ChannelWebhook, "label": "通用Webhook"},
+	{"type": messenger.ChannelApprise, "label": "Apprise URL"},
 }
 
 // SupportedEvents 支持的事件类型
@@ -233,8 +246,11 @@ func (s *NotificationService) GetBindingsByEvent(bindingType, event, dataID stri
 // SendToChannel 使用 messenger SDK 发送通知到指定渠道
 func (s *NotificationService) SendToChannel(channel NotifyChannel, msg *NotifyMessage) *NotifyResult {
 	result, err := messenger.Send(channel.Type, messenger.ChannelConfig(channel.Config), &messenger.Message{
-		Title: msg.Title,
-		Text:  msg.Text,
+		Title:       msg.Title,
+		Text:        msg.Text,
+		Markdown:    msg.Markdown,
+		HTML:        msg.HTML,
+		Attachments: msg.Attachments,
 	})
 
 	payload := map[string]interface{}{
@@ -321,14 +337,12 @@ func (s *NotificationService) SubscribeEvents(bus *eventbus.EventBus) {
 	bus.Subscribe(constant.EventSystemNotice, s.handleEvent(constant.BindingTypeSystem))
 }
 
-var ansiRegexp = regexp.MustCompile(`[\x1b\x9b][\[()#;?]*([0-9]{1,4}(;[0-9]{0,4})*)?[0-9A-ORZcf-nqry=><]`)
-
 // stripAnsi 移除字符串中的 ANSI 转义码（如颜色代码）
 func stripAnsi(str string) string {
-	return ansiRegexp.ReplaceAllString(str, "")
+	return utils.StripAnsi(str)
 }
 
-// parseTemplate 简单的 {{key}} 模板替换
+// parseTemplate 简单的 {{key}} 模板替换，仅在 text/template 解析失败时作为兜底
 func (s *NotificationService) parseTemplate(tmpl string, payload map[string]interface{}) string {
 	result := tmpl
 	for k, v := range payload {
@@ -429,33 +443,60 @@ func (s *NotificationService) resolveEvent(eventType string, payload map[string]
 	return tmplTitleKey, tmplTextKey, title, text, rawOutput, true
 }
 
-// buildMessage 匹配并解析模板内容，提供兜底消息并拼接全局前缀
-func (s *NotificationService) buildMessage(eventType string, tmplTitleKey, tmplTextKey, defaultTitle, defaultText, prefix string, payload map[string]interface{}) (title, text string) {
-	title = defaultTitle
-	text = defaultText
-
-	if tmplTitleKey != "" {
-		tmplTitle := s.settingsService.Get(constant.SectionNotify, tmplTitleKey)
-		tmplText := s.settingsService.Get(constant.SectionNotify, tmplTextKey)
+// loadTemplate 从 notify 设置中读取事件对应的全局模板（含 Markdown/HTML 变体）
+func (s *NotificationService) loadTemplate(tmplTitleKey, tmplTextKey string) NotifyTemplate {
+	if tmplTitleKey == "" {
+		return NotifyTemplate{}
+	}
+	base := strings.TrimSuffix(tmplTextKey, "_text")
+	return NotifyTemplate{
+		Title:    s.settingsService.Get(constant.SectionNotify, tmplTitleKey),
+		Text:     s.settingsService.Get(constant.SectionNotify, tmplTextKey),
+		Markdown: s.settingsService.Get(constant.SectionNotify, base+constant.NotifyTemplateSuffixMarkdown),
+		HTML:     s.settingsService.Get(constant.SectionNotify, base+constant.NotifyTemplateSuffixHTML),
+	}
+}
 
-		if tmplTitle != "" {
-			title = s.parseTemplate(tmplTitle, payload)
-		}
-		if tmplText != "" {
-			text = s.parseTemplate(tmplText, payload)
+// buildMessage 渲染模板内容，提供兜底消息并拼接全局前缀
+func (s *NotificationService) buildMessage(eventType string, tmpl NotifyTemplate, defaultTitle, defaultText, prefix string, payload map[string]interface{}) *NotifyMessage {
+	msg := &NotifyMessage{Title: defaultTitle, Text: defaultText}
+
+	if !tmpl.IsEmpty() {
+		rendered, err := tmpl.renderAll(buildTemplateData(eventType, payload))
+		if err != nil {
+			logger.Warnf("[Notify] 事件 %s 模板渲染失败，回退到简单替换: %v", eventType, err)
+			rendered = RenderedMessage{
+				Title: s.parseTemplate(tmpl.Title, payload),
+				Text:  s.parseTemplate(tmpl.Text, payload),
+			}
 		}
+		msg.Title, msg.Text = rendered.Title, rendered.Text
+		msg.Markdown, msg.HTML = rendered.Markdown, rendered.HTML
+	}
 
-		// 如果模板为空，使用兜底默认逻辑（保持向上兼容）
-		if title == "" || text == "" {
-			title, text = s.getDefaultMessage(eventType, payload)
+	// 如果模板为空，使用兜底默认逻辑（保持向上兼容）
+	if msg.Title == "" || msg.Text == "" {
+		if dt, dx := s.getDefaultMessage(eventType, payload); dt != "" {
+			msg.Title, msg.Text = dt, dx
 		}
 	}
 
 	// 添加全局前缀
-	if prefix != "" && title != "" {
-		title = fmt.Sprintf("%s %s", prefix, title)
+	if prefix != "" && msg.Title != "" {
+		msg.Title = fmt.Sprintf("%s %s", prefix, msg.Title)
+	}
+	return msg
+}
+
+// appendLog 将执行日志追加到消息的各格式正文中
+func appendLog(msg *NotifyMessage, logText string) {
+	msg.Text += "\n\n[执行日志]\n" + logText
+	if msg.Markdown != "" {
+		msg.Markdown += "\n\n**执行日志**\n```\n" + logText + "\n```"
+	}
+	if msg.HTML != "" {
+		msg.HTML += "<br/><b>执行日志</b><pre>" + html.EscapeString(logText) + "</pre>"
 	}
-	return title, text
 }
 
 // handleEvent 处理事件订阅并发送通知
@@ -478,20 +519,25 @@ func (s *NotificationService) handleEvent(bindingType string) eventbus.Handler {
 			return
 		}
 
-		// 构建最终的通知标题和正文文本
-		title, text = s.buildMessage(e.Type, tmplTitleKey, tmplTextKey, title, text, prefix, payload)
-
 		bindings := s.GetBindingsByEvent(bindingType, e.Type, dataID)
 		if len(bindings) == 0 {
 			return
 		}
 
+		// 构建全局模板下的通知内容，绑定未配置覆盖模板时直接复用
+		globalTmpl := s.loadTemplate(tmplTitleKey, tmplTextKey)
+		baseMsg := s.buildMessage(e.Type, globalTmpl, title, text, prefix, payload)
+
 		var cleanLog string
 		if rawOutput != "" {
 			// cleanLog = stripAnsi(rawOutput)
 			cleanLog = rawOutput
 		}
 
+		// 完整日志与产物附件在首个需要的绑定处读取
+		logID, _ := payload["log_id"].(string)
+		attachment := &logAttachment{logID: logID, taskID: dataID}
+
 		channels := s.GetChannels()
 		channelMap := make(map[string]NotifyChannel)
 		for _, ch := range channels {
@@ -504,9 +550,6 @@ func (s *NotificationService) handleEvent(bindingType string) eventbus.Handler {
 				continue
 			}
 
-			// 克隆文本以便修改
-			currentText := text
-
 			// 解析额外配置
 			var extra models.BindingExtra
 			if binding.Extra != "" {
@@ -517,25 +560,187 @@ func (s *NotificationService) handleEvent(bindingType string) eventbus.Handler {
 				extra.LogLimit = 1000
 			}
 
+			// 克隆消息以便修改，绑定配置了覆盖模板时单独渲染
+			msg := *baseMsg
+			if override := bindingTemplate(extra); !override.IsEmpty() && tmplTitleKey != "" {
+				msg = *s.buildMessage(e.Type, globalTmpl.Merge(override), title, text, prefix, payload)
+			}
+
 			// 如果开启了日志推送
 			if extra.EnableLog {
-				if cleanLog != "" {
-					trimmed := utils.TrimLastRunes(cleanLog, extra.LogLimit)
-					if len(trimmed) < len(cleanLog) {
-						trimmed = "...\n" + trimmed
-					}
-					currentText += "\n\n[执行日志]\n" + trimmed
-				}
+				s.attachLog(&msg, ch, extra, cleanLog, logID, attachment)
 			}
 
-			go func(channel NotifyChannel, msgTitle, msgText string) {
-				result := s.SendToChannel(channel, &NotifyMessage{Title: msgTitle, Text: msgText})
+			go func(channel NotifyChannel, m NotifyMessage) {
+				result := s.SendToChannel(channel, &m)
 				if !result.Success {
 					logger.Warnf("[Notify] 发送事件 %s 到渠道 %s(%s) 失败: %s", e.Type, channel.Name, channel.Type, result.Error)
 				}
-			}(ch, title, currentText)
+			}(ch, msg)
+		}
+	}
+}
+
+// attachLog 按绑定配置的方式附带执行日志：附件 > 分享链接 > 正文内联
+func (s *NotificationService) attachLog(msg *NotifyMessage, ch NotifyChannel, extra models.BindingExtra, cleanLog, logID string, attachment *logAttachment) {
+	mode := extra.LogMode
+	if mode == constant.NotifyLogModeAttach && !messenger.SupportsAttachments(ch.Type, messenger.ChannelConfig(ch.Config)) {
+		mode = constant.NotifyLogModeLink
+	}
+	if mode == constant.NotifyLogModeAttach {
+		if files := attachment.load(extra.Artifacts); len(files) > 0 {
+			msg.Attachments = files
+			return
+		}
+		mode = constant.NotifyLogModeLink
+	}
+	if mode == constant.NotifyLogModeLink {
+		if link := s.buildLogLink(logID, extra.LinkExpireHours); link != "" {
+			appendLogLink(msg, link, extra.LinkExpireHours)
+			return
+		}
+	}
+
+	if cleanLog != "" {
+		trimmed := utils.TrimLastRunes(cleanLog, extra.LogLimit)
+		if len(trimmed) < len(cleanLog) {
+			trimmed = "...\n" + trimmed
+		}
+		appendLog(msg, trimmed)
+	}
+}
+
+// bindingTemplate 从绑定的额外配置中提取覆盖模板
+func bindingTemplate(extra models.BindingExtra) NotifyTemplate {
+	return NotifyTemplate{
+		Title:    extra.TitleTemplate,
+		Text:     extra.TextTemplate,
+		Markdown: extra.MarkdownTemplate,
+		HTML:     extra.HTMLTemplate,
+	}
+}
+
+// TemplatePreviewRequest 模板预览请求
+type TemplatePreviewRequest struct {
+	Event     string         `json:"event"`
+	TaskID    string         `json:"task_id"`
+	LogID     string         `json:"log_id"`
+	BindingID string         `json:"binding_id"`
+	Template  NotifyTemplate `json:"template"`
+}
+
+// TemplatePreviewResult 模板预览结果
+type TemplatePreviewResult struct {
+	RenderedMessage
+	Source string `json:"source"` // log: 基于真实执行记录渲染; sample: 基于示例数据渲染
+	LogID  string `json:"log_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PreviewTemplate 使用最近一次真实执行记录（无记录时使用示例数据）渲染模板
+// 模板优先级：请求中的模板 > 绑定覆盖模板 > 全局模板
+func (s *NotificationService) PreviewTemplate(req TemplatePreviewRequest) (*TemplatePreviewResult, error) {
+	payload, logID := s.previewPayload(req.Event, req.TaskID, req.LogID)
+	tmplTitleKey, tmplTextKey, _, _, _, ok := s.resolveEvent(req.Event, payload)
+	if !ok || tmplTitleKey == "" {
+		return nil, fmt.Errorf("事件 %s 不支持模板", req.Event)
+	}
+
+	tmpl := s.loadTemplate(tmplTitleKey, tmplTextKey)
+	if req.BindingID != "" {
+		var binding models.NotifyBinding
+		res := database.DB.Where("id = ?", req.BindingID).Limit(1).Find(&binding)
+		if res.Error == nil && res.RowsAffected > 0 && binding.Extra != "" {
+			var extra models.BindingExtra
+			if err := json.Unmarshal([]byte(binding.Extra), &extra); err == nil {
+				tmpl = tmpl.Merge(bindingTemplate(extra))
+			}
 		}
 	}
+	tmpl = tmpl.Merge(req.Template)
+
+	result := &TemplatePreviewResult{Source: "sample", LogID: logID}
+	if logID != "" {
+		result.Source = "log"
+	}
+
+	rendered, err := tmpl.renderAll(buildTemplateData(req.Event, payload))
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if prefix := s.settingsService.Get(constant.SectionNotify, constant.KeyNotifyPrefix); prefix != "" && rendered.Title != "" {
+		rendered.Title = fmt.Sprintf("%s %s", prefix, rendered.Title)
+	}
+	result.RenderedMessage = rendered
+	return result, nil
+}
+
+// previewPayload 构造预览用的事件数据，任务事件优先取真实执行记录
+func (s *NotificationService) previewPayload(eventType, taskID, logID string) (map[string]interface{}, string) {
+	switch eventType {
+	case constant.EventUserLogin:
+		return map[string]interface{}{"ip": "127.0.0.1", "username": "admin", "userAgent": "Mozilla/5.0", "status": "success", "message": "登录成功"}, ""
+	case constant.EventBruteForceLogin:
+		return map[string]interface{}{"ip": "127.0.0.1", "username": "admin", "userAgent": "Mozilla/5.0"}, ""
+	case constant.EventPasswordChanged:
+		return map[string]interface{}{"username": "admin"}, ""
+	}
+
+	status := map[string]string{
+		constant.EventTaskSuccess: constant.TaskStatusSuccess,
+		constant.EventTaskFailed:  constant.TaskStatusFailed,
+		constant.EventTaskTimeout: constant.TaskStatusTimeout,
+	}[eventType]
+
+	var taskLog models.TaskLog
+	var found bool
+	if logID != "" {
+		res := database.DB.Where("id = ?", logID).Limit(1).Find(&taskLog)
+		found = res.Error == nil && res.RowsAffected > 0
+	} else {
+		query := database.DB.Model(&models.TaskLog{}).Where("status <> ?", constant.TaskStatusRunning)
+		if taskID != "" {
+			query = query.Where("task_id = ?", taskID)
+		}
+		// 优先选择与事件状态一致的最近一次执行
+		res := query.Session(&gorm.Session{}).Where("status = ?", status).Order("id DESC").Limit(1).Find(&taskLog)
+		found = res.Error == nil && res.RowsAffected > 0
+		if !found {
+			res = query.Order("id DESC").Limit(1).Find(&taskLog)
+			found = res.Error == nil && res.RowsAffected > 0
+		}
+	}
+
+	if !found {
+		return map[string]interface{}{
+			"log_id":     "",
+			"task_id":    taskID,
+			"task_name":  "示例任务",
+			"status":     status,
+			"start_time": models.Now().Time().Format(models.TimeFormat),
+			"duration":   int64(1234),
+			"output":     "hello\nworld",
+			"error":      "",
+		}, ""
+	}
+
+	var taskName string
+	database.DB.Model(&models.Task{}).Where("id = ?", taskLog.TaskID).Limit(1).Pluck("name", &taskName)
+	output, _ := logstore.ReadOutput(&taskLog)
+	var startTime string
+	if taskLog.StartTime != nil {
+		startTime = taskLog.StartTime.Time().Format(models.TimeFormat)
+	}
+	return map[string]interface{}{
+		"log_id":     taskLog.ID,
+		"task_id":    taskLog.TaskID,
+		"task_name":  taskName,
+		"status":     taskLog.Status,
+		"start_time": startTime,
+		"duration":   taskLog.Duration,
+		"output":     output,
+		"error":      string(taskLog.Error),
+	}, taskLog.ID
 }
 
 // --- 内部方法 ---
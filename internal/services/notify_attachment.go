@@ -0,0 +1,179 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/sdk/messenger"
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+const (
+	defaultLogLinkExpireHours = 24
+	maxArtifactFiles          = 5
+	maxArtifactSize           = 20 << 20
+)
+
+// logAttachment 一次任务通知中可复用的附件数据，按需懒加载
+type logAttachment struct {
+	logID  string
+	taskID string
+
+	loaded      bool
+	attachments []messenger.Attachment
+}
+
+// load 读取完整日志并收集产物文件，同一事件的多个绑定只读取一次
+func (a *logAttachment) load(artifacts []string) []messenger.Attachment {
+	if !a.loaded {
+		a.loaded = true
+		var taskLog models.TaskLog
+		res := database.DB.Where("id = ?", a.logID).Limit(1).Find(&taskLog)
+		if res.Error == nil && res.RowsAffected > 0 {
+			output, err := logstore.ReadOutput(&taskLog)
+			if err != nil {
+				logger.Warnf("[Notify] 读取日志 %s 失败: %v", a.logID, err)
+			} else {
+				a.attachments = append(a.attachments, messenger.Attachment{
+					Name:    fmt.Sprintf("task_%s_log_%s.log", a.taskID, a.logID),
+					Content: []byte(stripAnsi(output)),
+				})
+			}
+		}
+	}
+
+	result := append([]messenger.Attachment{}, a.attachments...)
+	if len(artifacts) > 0 {
+		result = append(result, collectArtifacts(a.taskID, artifacts)...)
+	}
+	return result
+}
+
+// collectArtifacts 按 glob 收集任务工作目录下的产物文件，仅限本地任务且不允许越出脚本目录
+// 符号链接解析为真实路径后再判断是否位于脚本目录内，且只发送普通文件
+func collectArtifacts(taskID string, patterns []string) []messenger.Attachment {
+	var task models.Task
+	if res := database.DB.Where("id = ?", taskID).Limit(1).Find(&task); res.Error != nil || res.RowsAffected == 0 {
+		return nil
+	}
+	if task.AgentID != nil && *task.AgentID != "" {
+		return nil
+	}
+
+	scriptsDir := utils.ResolveAbsScriptsDir()
+	baseDir := strings.ReplaceAll(task.WorkDir, constant.ScriptsDirPlaceholder, scriptsDir)
+	if baseDir == "" {
+		baseDir = scriptsDir
+	} else if !filepath.IsAbs(baseDir) {
+		baseDir = filepath.Join(scriptsDir, baseDir)
+	}
+	root, err := filepath.EvalSymlinks(scriptsDir)
+	if err != nil {
+		return nil
+	}
+
+	var result []messenger.Attachment
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(baseDir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			logger.Warnf("[Notify] 产物匹配规则 %s 无效: %v", pattern, err)
+			continue
+		}
+		for _, m := range matches {
+			if len(result) >= maxArtifactFiles {
+				return result
+			}
+			real, err := filepath.EvalSymlinks(m)
+			if err != nil || seen[real] || !isSubPath(root, real) {
+				continue
+			}
+			seen[real] = true
+			info, err := os.Stat(real)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			if info.Size() > maxArtifactSize {
+				logger.Warnf("[Notify] 产物 %s 超过 %dMB，跳过发送", m, maxArtifactSize>>20)
+				continue
+			}
+			data, err := os.ReadFile(real)
+			if err != nil {
+				continue
+			}
+			result = append(result, messenger.Attachment{Name: filepath.Base(m), Content: data})
+		}
+	}
+	return result
+}
+
+func isSubPath(base, target string) bool {
+	rel, err := filepath.Rel(base, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// signLogLink 计算日志分享链接的签名
+func signLogLink(logID string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(constant.Secret))
+	fmt.Fprintf(mac, "log:%s:%d", logID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyLogLink 校验日志分享链接的签名与有效期
+func VerifyLogLink(logID, expires, sig string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(signLogLink(logID, exp)), []byte(sig))
+}
+
+// buildLogLink 生成限时有效的日志分享链接，未配置面板对外地址时返回空
+func (s *NotificationService) buildLogLink(logID string, expireHours int) string {
+	base := s.settingsService.PublicURL()
+	if base == "" || logID == "" {
+		return ""
+	}
+	if expireHours <= 0 {
+		expireHours = defaultLogLinkExpireHours
+	}
+	expires := time.Now().Add(time.Duration(expireHours) * time.Hour).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", signLogLink(logID, expires))
+	return fmt.Sprintf("%s/api/v1/share/logs/%s?%s", base, url.PathEscape(logID), query.Encode())
+}
+
+// appendLogLink 将日志分享链接追加到消息的各格式正文中
+func appendLogLink(msg *NotifyMessage, link string, expireHours int) {
+	if expireHours <= 0 {
+		expireHours = defaultLogLinkExpireHours
+	}
+	label := fmt.Sprintf("完整日志（%d 小时内有效）", expireHours)
+	msg.Text += fmt.Sprintf("\n\n%s: %s", label, link)
+	if msg.Markdown != "" {
+		msg.Markdown += fmt.Sprintf("\n\n[%s](%s)", label, link)
+	}
+	if msg.HTML != "" {
+		msg.HTML += fmt.Sprintf(`<br/><a href="%s">%s</a>`, link, label)
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+)
+
+func TestVerifyLogLink(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+	exp := strconv.FormatInt(expires, 10)
+	sig := signLogLink("log1", expires)
+
+	if !VerifyLogLink("log1", exp, sig) {
+		t.Error("valid signature should pass")
+	}
+	if VerifyLogLink("log2", exp, sig) {
+		t.Error("signature must be bound to the log id")
+	}
+	if VerifyLogLink("log1", strconv.FormatInt(expires+1, 10), sig) {
+		t.Error("tampered expiry should fail")
+	}
+
+	past := time.Now().Add(-time.Minute).Unix()
+	if VerifyLogLink("log1", strconv.FormatInt(past, 10), signLogLink("log1", past)) {
+		t.Error("expired link should fail")
+	}
+}
+
+func TestAppendLogLink(t *testing.T) {
+	msg := NotifyMessage{Text: "done", Markdown: "**done**"}
+	appendLogLink(&msg, "https://x/log", 0)
+
+	if !strings.Contains(msg.Text, "https://x/log") || !strings.Contains(msg.Text, "24 小时") {
+		t.Errorf("text missing link: %q", msg.Text)
+	}
+	if !strings.Contains(msg.Markdown, "](https://x/log)") {
+		t.Errorf("markdown missing link: %q", msg.Markdown)
+	}
+	if msg.HTML != "" {
+		t.Errorf("empty html variant should stay empty, got %q", msg.HTML)
+	}
+}
+
+func TestIsSubPath(t *testing.T) {
+	base := filepath.FromSlash("/data/scripts")
+	cases := map[string]bool{
+		"/data/scripts/a/out.csv": true,
+		"/data/scripts":           true,
+		"/data/scripts2/x":        false,
+		"/data/other/x":           false,
+		"/data/scripts/../etc":    false,
+	}
+	for p, want := range cases {
+		if got := isSubPath(base, filepath.FromSlash(p)); got != want {
+			t.Errorf("isSubPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+// 指向脚本目录之外的符号链接与非普通文件不应作为产物发送
+func TestCollectArtifactsSymlink(t *testing.T) {
+	setupTestDB(t)
+	scripts, outside := t.TempDir(), t.TempDir()
+	t.Setenv("BH_SCRIPTS_DIR", scripts)
+	os.WriteFile(filepath.Join(scripts, "report.csv"), []byte("a,b"), 0644)
+	os.WriteFile(filepath.Join(outside, "secret.csv"), []byte("secret"), 0644)
+	if err := os.Symlink(filepath.Join(outside, "secret.csv"), filepath.Join(scripts, "leak.csv")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	os.Symlink(filepath.Join(scripts, "report.csv"), filepath.Join(scripts, "alias.csv"))
+	os.Mkdir(filepath.Join(scripts, "dir.csv"), 0755)
+	database.DB.Create(&models.Task{ID: "t1", Name: "artifacts"})
+
+	got := collectArtifacts("t1", []string{"*.csv"})
+	if len(got) != 1 || string(got[0].Content) != "a,b" {
+		t.Errorf("expected only the in-tree report once, got %+v", got)
+	}
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode/utf8"
+
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+// NotifyTemplate 一组通知模板，Markdown/HTML 为可选变体，渠道按 GetSupportedFormats 自动择优
+type NotifyTemplate struct {
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+}
+
+// Merge 使用 override 覆盖当前模板：标题单独覆盖；正文只要覆盖了任一格式，
+// 就整体替换三种正文变体，避免渠道择优时选中未被覆盖的全局 Markdown/HTML 模板
+func (t NotifyTemplate) Merge(override NotifyTemplate) NotifyTemplate {
+	if override.Title != "" {
+		t.Title = override.Title
+	}
+	if override.Text != "" || override.Markdown != "" || override.HTML != "" {
+		t.Text = override.Text
+		t.Markdown = override.Markdown
+		t.HTML = override.HTML
+	}
+	return t
+}
+
+// IsEmpty 判断模板是否全部为空
+func (t NotifyTemplate) IsEmpty() bool {
+	return t.Title == "" && t.Text == "" && t.Markdown == "" && t.HTML == ""
+}
+
+// notifyTemplateFuncs 模板内可用的辅助函数
+var notifyTemplateFuncs = template.FuncMap{
+	"truncate":       tmplTruncate,
+	"tail":           tmplTail,
+	"lines":          tmplLines,
+	"lastLines":      tmplLastLines,
+	"escapeHTML":     html.EscapeString,
+	"escapeMarkdown": tmplEscapeMarkdown,
+	"stripAnsi":      stripAnsi,
+	"default":        tmplDefault,
+	"upper":          strings.ToUpper,
+	"lower":          strings.ToLower,
+	"trim":           strings.TrimSpace,
+	"join":           strings.Join,
+	"contains":       strings.Contains,
+	"replace":        strings.ReplaceAll,
+	"formatDuration": tmplDuration,
+}
+
+// templateKeywords text/template 的保留关键字，兼容旧语法时不能改写
+var templateKeywords = map[string]bool{
+	"end": true, "else": true, "break": true, "continue": true,
+	"nil": true, "true": true, "false": true,
+}
+
+var legacyPlaceholderRegexp = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// convertLegacyPlaceholders 将旧版的 {{key}} 写法改写为 {{.key}}，保证已有模板无需修改即可继续使用
+// 与辅助函数同名时，若上下文中存在该键则优先视为取值
+func convertLegacyPlaceholders(tmpl string, data map[string]interface{}) string {
+	return legacyPlaceholderRegexp.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := legacyPlaceholderRegexp.FindStringSubmatch(m)[1]
+		if _, ok := data[name]; ok {
+			return "{{." + name + "}}"
+		}
+		if templateKeywords[name] {
+			return m
+		}
+		if _, ok := notifyTemplateFuncs[name]; ok {
+			return m
+		}
+		if isBuiltinTemplateFunc(name) {
+			return m
+		}
+		return "{{." + name + "}}"
+	})
+}
+
+// isBuiltinTemplateFunc text/template 内建函数
+func isBuiltinTemplateFunc(name string) bool {
+	switch name {
+	case "and", "call", "html", "index", "slice", "js", "len", "not", "or",
+		"print", "printf", "println", "urlquery", "eq", "ge", "gt", "le", "lt", "ne":
+		return true
+	}
+	return false
+}
+
+// renderNotifyTemplate 使用 text/template 渲染通知模板
+func renderNotifyTemplate(tmpl string, data map[string]interface{}) (string, error) {
+	if tmpl == "" {
+		return "", nil
+	}
+	t, err := template.New("notify").Funcs(notifyTemplateFuncs).Option("missingkey=zero").Parse(convertLegacyPlaceholders(tmpl, data))
+	if err != nil {
+		return "", fmt.Errorf("模板解析失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, withTemplateDefaults(t.Tree.Root, data)); err != nil {
+		return "", fmt.Errorf("模板渲染失败: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// withTemplateDefaults 为模板引用但上下文中缺失的键补充空字符串，与旧版行为一致按空值渲染；
+// interface{} 类型的 map 即使开启 missingkey=zero 也会输出 <no value>，因此需要预先补齐
+func withTemplateDefaults(root parse.Node, data map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, c := range n.Nodes {
+					walk(c)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n != nil {
+				for _, c := range n.Cmds {
+					walk(c)
+				}
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.FieldNode:
+			if _, ok := out[n.Ident[0]]; !ok {
+				out[n.Ident[0]] = ""
+			}
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	walk(root)
+	return out
+}
+
+// buildTemplateData 构造模板上下文，在原始 payload 基础上补充便于模板使用的派生字段
+func buildTemplateData(eventType string, payload map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(payload)+2)
+	for k, v := range payload {
+		data[k] = v
+	}
+	data["event"] = eventType
+	if output, ok := payload["output"].(string); ok {
+		data["output_lines"] = tmplLines(output)
+	} else {
+		data["output_lines"] = []string{}
+	}
+	return data
+}
+
+// RenderedMessage 模板渲染结果
+type RenderedMessage struct {
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+}
+
+// renderAll 渲染模板中的所有变体，返回第一个遇到的错误
+func (t NotifyTemplate) renderAll(data map[string]interface{}) (RenderedMessage, error) {
+	var out RenderedMessage
+	var firstErr error
+	render := func(name, src string) string {
+		res, err := renderNotifyTemplate(src, data)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s %w", name, err)
+		}
+		return res
+	}
+	out.Title = render("title", t.Title)
+	out.Text = render("text", t.Text)
+	out.Markdown = render("markdown", t.Markdown)
+	out.HTML = render("html", t.HTML)
+	return out, firstErr
+}
+
+func tmplTruncate(n int, s string) string {
+	if n <= 0 || utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "..."
+}
+
+func tmplTail(n int, s string) string {
+	if n <= 0 {
+		return s
+	}
+	return utils.TrimLastRunes(s, n)
+}
+
+func tmplLines(s string) []string {
+	s = strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
+
+func tmplLastLines(n int, s string) string {
+	lines := tmplLines(s)
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`, "#", `\#`,
+)
+
+func tmplEscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+func tmplDefault(def string, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return def
+	}
+	return v
+}
+
+// tmplDuration 将毫秒数格式化为易读的耗时
+func tmplDuration(v interface{}) string {
+	var ms int64
+	switch val := v.(type) {
+	case int:
+		ms = int64(val)
+	case int64:
+		ms = val
+	case float64:
+		ms = int64(val)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+	return (time.Duration(ms) * time.Millisecond).Round(time.Millisecond).String()
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestRenderNotifyTemplate_LegacyPlaceholders(t *testing.T) {
+	data := map[string]interface{}{
+		"task_id":   "abc",
+		"task_name": "签到",
+		"duration":  int64(1500),
+	}
+
+	got, err := renderNotifyTemplate("任务 #{{task_id}} {{ task_name }} 耗时 {{duration}}ms {{missing}}", data)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := "任务 #abc 签到 耗时 1500ms "
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderNotifyTemplate_Helpers(t *testing.T) {
+	data := buildTemplateData("task_failed", map[string]interface{}{
+		"output": "line1\nline2\nline3\n",
+		"error":  "",
+		"title":  "<b>x</b>",
+	})
+
+	cases := []struct {
+		tmpl string
+		want string
+	}{
+		{`{{range .output_lines}}[{{.}}]{{end}}`, "[line1][line2][line3]"},
+		{`{{lastLines 2 .output}}`, "line2\nline3"},
+		{`{{truncate 3 "你好世界"}}`, "你好世..."},
+		{`{{tail 2 "abcdef"}}`, "ef"},
+		{`{{default "无" .error}}`, "无"},
+		{`{{escapeHTML .title}}`, "&lt;b&gt;x&lt;/b&gt;"},
+		{`{{escapeMarkdown "a_b*c"}}`, `a\_b\*c`},
+		{`{{if eq .event "task_failed"}}失败{{else}}其他{{end}}`, "失败"},
+		{`{{formatDuration 61000}}`, "1m1s"},
+	}
+	for _, tc := range cases {
+		got, err := renderNotifyTemplate(tc.tmpl, data)
+		if err != nil {
+			t.Errorf("%s: render failed: %v", tc.tmpl, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.tmpl, got, tc.want)
+		}
+	}
+}
+
+func TestRenderNotifyTemplate_MissingKeys(t *testing.T) {
+	data := map[string]interface{}{"output": "got <no value> from script"}
+	cases := map[string]string{
+		`{{.output}}`:    "got <no value> from script",
+		`[{{.missing}}]`: "[]",
+		`{{if .missing}}x{{else}}{{.output}}{{end}}`: "got <no value> from script",
+		`{{default "无" .missing}}`:                   "无",
+	}
+	for tmpl, want := range cases {
+		got, err := renderNotifyTemplate(tmpl, data)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", tmpl, got, err, want)
+		}
+	}
+	if _, ok := data["missing"]; ok {
+		t.Error("render must not modify the caller's data")
+	}
+}
+
+func TestRenderNotifyTemplate_ParseError(t *testing.T) {
+	if _, err := renderNotifyTemplate("{{if .x}}unclosed", map[string]interface{}{}); err == nil {
+		t.Error("expected parse error for unclosed action")
+	}
+}
+
+func TestNotifyTemplate_Merge(t *testing.T) {
+	global := NotifyTemplate{Title: "T", Text: "body", Markdown: "**body**"}
+
+	merged := global.Merge(NotifyTemplate{Title: "T2"})
+	if merged.Title != "T2" || merged.Markdown != "**body**" {
+		t.Errorf("title-only override should keep body variants, got %+v", merged)
+	}
+
+	merged = global.Merge(NotifyTemplate{Text: "custom"})
+	if merged.Title != "T" || merged.Text != "custom" || merged.Markdown != "" {
+		t.Errorf("body override should replace all body variants, got %+v", merged)
+	}
+}
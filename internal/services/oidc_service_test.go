@@ -0,0 +1,196 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCProvider 本地模拟的身份提供方，签发 RS256 ID Token
+type mockOIDCProvider struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	nonce     string
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDCProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                m.URL,
+			"authorization_endpoint":                m.URL + "/authorize",
+			"token_endpoint":                        m.URL + "/token",
+			"userinfo_endpoint":                     m.URL + "/userinfo",
+			"jwks_uri":                              m.URL + "/jwks",
+			"token_endpoint_auth_methods_supported": []string{"client_secret_basic"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if id != "panel" || secret != "s3cret" || r.PostFormValue("code") != "good-code" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{"iss": m.URL, "aud": "panel", "sub": "u-1", "nonce": m.nonce,
+			"exp": time.Now().Add(time.Minute).Unix(), "preferred_username": "alice"}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "k1"
+		signed, _ := tok.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": signed})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"sub": "u-1", "groups": []string{"ops", "dev"}, "email": "alice@corp.com"})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func TestOIDCService_Flow(t *testing.T) {
+	constant.Secret = "test-secret"
+	m := newMockOIDCProvider(t)
+	s := NewOIDCService(nil, nil)
+	cfg := ParseOIDCConfig(map[string]string{
+		constant.KeyOidcEnabled: "true", constant.KeyOidcIssuer: m.URL,
+		constant.KeyOidcClientID: "panel", constant.KeyOidcClientSecret: "s3cret", constant.KeyOidcScopes: "profile,email",
+	})
+	redirect := "http://panel.local/api/v1/auth/oidc/callback"
+
+	authURL, stateToken, err := s.AuthURL(cfg, redirect, "")
+	if err != nil {
+		t.Fatalf("AuthURL: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("scope") != "openid profile email" || q.Get("redirect_uri") != redirect || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth params: %v", q)
+	}
+	m.nonce, m.challenge = q.Get("nonce"), q.Get("code_challenge")
+
+	if _, err := s.Exchange(cfg, redirect, "good-code", "forged", stateToken); err == nil {
+		t.Error("mismatched state must be rejected")
+	}
+	if _, err := s.Exchange(cfg, redirect, "bad-code", q.Get("state"), stateToken); err == nil {
+		t.Error("token endpoint errors must be surfaced")
+	}
+
+	identity, err := s.Exchange(cfg, redirect, "good-code", q.Get("state"), stateToken)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if identity.Subject != "u-1" || identity.Username != "alice" || identity.Email != "alice@corp.com" || identity.Issuer != m.URL || identity.LinkUserID != "" {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+	if role, matched := MapOIDCRole(identity.Claims, "groups", "ops=operator\ndev=editor", ""); role != constant.RoleEditor || !matched {
+		t.Errorf("userinfo groups should map to editor, got %q", role)
+	}
+
+	// 已登录用户发起的绑定，签名状态中的用户 ID 随身份返回
+	linkURL, linkState, err := s.AuthURL(cfg, redirect, "user-1")
+	if err != nil {
+		t.Fatalf("AuthURL(link): %v", err)
+	}
+	lu, _ := url.Parse(linkURL)
+	m.nonce, m.challenge = lu.Query().Get("nonce"), lu.Query().Get("code_challenge")
+	if linked, err := s.Exchange(cfg, redirect, "good-code", lu.Query().Get("state"), linkState); err != nil || linked.LinkUserID != "user-1" {
+		t.Errorf("link identity = %+v, %v", linked, err)
+	}
+	m.nonce, m.challenge = q.Get("nonce"), q.Get("code_challenge")
+
+	m.nonce = "replayed"
+	if _, err := s.Exchange(cfg, redirect, "good-code", q.Get("state"), stateToken); err == nil {
+		t.Error("nonce mismatch must be rejected")
+	}
+
+	m.nonce, m.claims = q.Get("nonce"), jwt.MapClaims{"aud": "other-client"}
+	if _, err := s.Exchange(cfg, redirect, "good-code", q.Get("state"), stateToken); err == nil {
+		t.Error("token for another audience must be rejected")
+	}
+}
+
+// 未知 kid 触发 JWKS 刷新时，与其它请求并发读取公钥不应产生数据竞争
+func TestOIDCService_ConcurrentKeyRefresh(t *testing.T) {
+	m := newMockOIDCProvider(t)
+	s := NewOIDCService(nil, nil)
+	p, err := s.Discover(m.URL)
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	sign := func(kid string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": m.URL, "aud": "panel", "sub": "u-1",
+			"exp": time.Now().Add(time.Minute).Unix()})
+		tok.Header["kid"] = kid
+		signed, _ := tok.SignedString(m.key)
+		return signed
+	}
+	known, rotated := sign("k1"), sign("k2")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := s.verifyIDToken(p, "panel", known); err != nil {
+				t.Errorf("known kid: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := s.verifyIDToken(p, "panel", rotated); err == nil {
+				t.Error("unknown kid must be rejected")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMapOIDCRole(t *testing.T) {
+	claims := map[string]interface{}{"groups": []interface{}{"team-a", "admins"}, "email": "bob@corp.com"}
+	mapping := "team-*=operator, admins=admin\nbad-line\nx=superuser"
+
+	if role, matched := MapOIDCRole(claims, "groups", mapping, ""); role != constant.AdminRole || !matched {
+		t.Errorf("highest matching role should win, got %q", role)
+	}
+	if role, _ := MapOIDCRole(claims, "email", "*@corp.com=viewer", ""); role != constant.RoleViewer {
+		t.Errorf("email wildcard should match, got %q", role)
+	}
+	if role, matched := MapOIDCRole(claims, "groups", "nobody=admin", constant.RoleViewer); role != constant.RoleViewer || matched {
+		t.Errorf("unmatched claims should fall back to default role, got %q", role)
+	}
+	if role, _ := MapOIDCRole(claims, "groups", "nobody=admin", ""); role != "" {
+		t.Errorf("no default role should deny login, got %q", role)
+	}
+}
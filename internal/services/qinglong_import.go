@@ -0,0 +1,681 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/services/deps"
+	"github.com/engigu/baihu-panel/internal/services/repo"
+	"github.com/engigu/baihu-panel/internal/services/tasks"
+	"github.com/engigu/baihu-panel/internal/utils"
+	"github.com/robfig/cron/v3"
+)
+
+// 青龙导入报告中的动作
+const (
+	QLImportActionCreate   = "create"   // 已导入，预演时表示将要导入
+	QLImportActionExists   = "exists"   // 面板中已存在相同资源，跳过
+	QLImportActionSkip     = "skip"     // 无需导入
+	QLImportActionUnmapped = "unmapped" // 无法映射，需要手动处理
+)
+
+// 青龙导入报告中的资源类型
+const (
+	QLImportKindTask       = "task"
+	QLImportKindRepo       = "repo"
+	QLImportKindEnv        = "env"
+	QLImportKindDependency = "dependency"
+	QLImportKindScript     = "script"
+	QLImportKindHook       = "hook"
+)
+
+const (
+	// qlDefaultTimeout 青龙任务没有单独的超时时间，使用面板新建任务的默认值（分钟）
+	qlDefaultTimeout = 30
+	// qlActor 写入变量历史的操作人
+	qlActor = "ql-import"
+	// qlCleanConfig 与仓库同步生成的任务一致，按条数保留 30 条日志
+	qlCleanConfig = `{"type":"count","keep":30}`
+)
+
+// qlScriptPrefixes 青龙容器内 scripts 目录的绝对路径
+var qlScriptPrefixes = []string{"/ql/data/scripts/", "/ql/scripts/"}
+
+// QLImportItem 导入报告中的一项
+type QLImportItem struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Notes  []string `json:"notes,omitempty"`
+}
+
+// QLImportReport 导入报告
+type QLImportReport struct {
+	DryRun  bool           `json:"dry_run"`
+	Items   []QLImportItem `json:"items"`
+	Counts  map[string]int `json:"counts"`
+	TaskIDs []string       `json:"task_ids"` // 新建的任务，需通知后台服务加入调度
+}
+
+func (r *QLImportReport) add(item QLImportItem) {
+	r.Items = append(r.Items, item)
+	r.Counts[item.Action]++
+}
+
+// QLImportOptions 导入选项
+type QLImportOptions struct {
+	DryRun      bool
+	CopyScripts bool // 将青龙 scripts 目录中的文件复制到面板 scripts 目录，已存在的文件不覆盖
+}
+
+// QLImportService 将青龙的定时任务、环境变量、依赖与订阅导入面板
+type QLImportService struct {
+	taskService *tasks.TaskService
+	envService  *EnvService
+	depService  *DependencyService
+}
+
+func NewQLImportService() *QLImportService {
+	return &QLImportService{
+		taskService: tasks.NewTaskService(),
+		envService:  NewEnvService(),
+		depService:  NewDependencyService(),
+	}
+}
+
+// qlCommand 青龙任务命令转换后的结果
+type qlCommand struct {
+	Command string
+	WorkDir string
+	Script  string // 相对 scripts 目录的脚本路径，非 task 命令为空
+	Notes   []string
+}
+
+// qlImporter 单次导入过程中的状态
+type qlImporter struct {
+	*QLImportService
+	data      *QLData
+	opts      QLImportOptions
+	report    *QLImportReport
+	scripts   string          // 面板 scripts 目录
+	tasks     map[string]bool // 名称+命令，用于跳过已存在的任务
+	sourceIDs map[string]bool
+	repoDirs  map[string]bool   // 本次导入或已存在的同步任务的仓库目录名
+	subTasks  map[string]string // 开启自动添加任务的订阅 ID -> 同步任务名称
+}
+
+// Import 按青龙数据创建面板资源，已存在的资源跳过，无法映射的资源记入报告
+func (s *QLImportService) Import(data *QLData, opts QLImportOptions) *QLImportReport {
+	im := &qlImporter{
+		QLImportService: s,
+		data:            data,
+		opts:            opts,
+		report:          &QLImportReport{DryRun: opts.DryRun, Counts: map[string]int{}},
+		scripts:         utils.ResolveAbsScriptsDir(),
+		tasks:           map[string]bool{},
+		sourceIDs:       map[string]bool{},
+		repoDirs:        map[string]bool{},
+		subTasks:        map[string]string{},
+	}
+	for _, t := range s.taskService.GetTasks() {
+		im.tasks[t.Name+"\x00"+string(t.Command)] = true
+		if t.SourceID != "" {
+			im.sourceIDs[t.SourceID] = true
+		}
+		if strings.HasPrefix(t.SourceID, "repo_") {
+			im.repoDirs[strings.TrimPrefix(t.SourceID, "repo_")] = true
+		}
+	}
+
+	im.importScripts()
+	for i := range data.Subscriptions {
+		im.importSubscription(&data.Subscriptions[i])
+	}
+	for i := range data.Crons {
+		im.importCron(&data.Crons[i])
+	}
+	im.importEnvs()
+	im.importDependencies()
+	for _, hook := range data.Hooks {
+		im.report.add(QLImportItem{Kind: QLImportKindHook, Name: hook, Action: QLImportActionUnmapped,
+			Notes: []string{"面板没有全局钩子脚本，请将其中的逻辑移到任务的前置/后置命令中"}})
+	}
+	return im.report
+}
+
+// importScripts 复制青龙 scripts 目录中的文件，已存在的文件不覆盖
+func (im *qlImporter) importScripts() {
+	if !im.opts.CopyScripts {
+		return
+	}
+	item := QLImportItem{Kind: QLImportKindScript, Name: "scripts", Action: QLImportActionCreate}
+	if im.data.ScriptsDir == "" {
+		item.Action = QLImportActionUnmapped
+		item.Notes = []string{"未找到青龙 scripts 目录"}
+		im.report.add(item)
+		return
+	}
+
+	copied, skipped := 0, 0
+	err := filepath.WalkDir(im.data.ScriptsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, _ := filepath.Rel(im.data.ScriptsDir, path)
+		dest := filepath.Join(im.scripts, rel)
+		if _, err := os.Lstat(dest); err == nil {
+			skipped++
+			return nil
+		}
+		copied++
+		if im.opts.DryRun {
+			return nil
+		}
+		return utils.CopyFile(path, dest)
+	})
+	item.Notes = []string{fmt.Sprintf("复制 %d 个文件，跳过 %d 个已存在的文件", copied, skipped)}
+	if copied == 0 {
+		item.Action = QLImportActionExists
+	}
+	if err != nil {
+		item.Action = QLImportActionUnmapped
+		item.Notes = append(item.Notes, "复制中断: "+err.Error())
+	}
+	im.report.add(item)
+}
+
+// importSubscription 将订阅转换为仓库同步任务
+func (im *qlImporter) importSubscription(sub *QLSubscription) {
+	item := QLImportItem{Kind: QLImportKindRepo, Name: sub.Name}
+	cfg, notes, err := qlSubscriptionConfig(sub)
+	if item.Name == "" {
+		item.Name = sub.URL
+	}
+	if err != nil {
+		item.Action, item.Notes = QLImportActionUnmapped, []string{err.Error()}
+		im.report.add(item)
+		return
+	}
+	schedule, err := qlSubscriptionSchedule(sub)
+	if err != nil {
+		item.Action, item.Notes = QLImportActionUnmapped, []string{err.Error()}
+		im.report.add(item)
+		return
+	}
+	item.Notes = notes
+	if sub.AutoAddCron {
+		im.subTasks[sub.ID] = item.Name
+	}
+	im.createRepo(&item, cfg, schedule, sub.SubBefore, sub.SubAfter, !sub.Disabled)
+}
+
+// importCron 转换定时任务，ql repo/raw 命令转换为仓库同步任务
+func (im *qlImporter) importCron(c *QLCron) {
+	item := QLImportItem{Kind: QLImportKindTask, Name: c.Name}
+	if item.Name == "" {
+		item.Name = c.Command
+	}
+	if c.System {
+		item.Action, item.Notes = QLImportActionSkip, []string{"青龙系统任务"}
+		im.report.add(item)
+		return
+	}
+	if name, ok := im.subTasks[c.SubID]; ok && c.SubID != "" {
+		item.Action = QLImportActionSkip
+		item.Notes = []string{fmt.Sprintf("由订阅自动添加，同步任务「%s」首次运行后会重新生成", name)}
+		if c.Disabled {
+			item.Notes = append(item.Notes, "青龙中为禁用状态，重新生成后需手动禁用")
+		}
+		im.report.add(item)
+		return
+	}
+
+	schedule, err := qlSchedule(c.Schedule)
+	if err != nil {
+		item.Action, item.Notes = QLImportActionUnmapped, []string{err.Error()}
+		im.report.add(item)
+		return
+	}
+	if len(c.ExtraSchedules) > 0 {
+		item.Notes = append(item.Notes, "面板任务只有一个定时规则，未迁移额外的定时规则: "+strings.Join(c.ExtraSchedules, "、"))
+	}
+
+	args := qlSplitArgs(c.Command)
+	if len(args) >= 3 && args[0] == "ql" && (args[1] == "repo" || args[1] == "raw") {
+		item.Kind = QLImportKindRepo
+		cfg := qlRepoCommandConfig(args)
+		im.createRepo(&item, cfg, schedule, c.TaskBefore, c.TaskAfter, !c.Disabled)
+		return
+	}
+
+	cmd, err := qlTaskCommand(c.Command)
+	if err != nil {
+		item.Action, item.Notes = QLImportActionUnmapped, append(item.Notes, err.Error())
+		im.report.add(item)
+		return
+	}
+	item.Notes = append(item.Notes, cmd.Notes...)
+	if cmd.Script != "" && !im.scriptAvailable(cmd.Script) {
+		item.Notes = append(item.Notes, fmt.Sprintf("面板 scripts 目录中没有脚本 %s，请复制后再运行", cmd.Script))
+	}
+
+	key := item.Name + "\x00" + cmd.Command
+	if im.tasks[key] {
+		item.Action = QLImportActionExists
+		im.report.add(item)
+		return
+	}
+	im.tasks[key] = true
+
+	item.Action = QLImportActionCreate
+	if !im.opts.DryRun {
+		taskCfg, _ := json.Marshal(models.TaskConfig{AllEnvs: true, Concurrency: qlConcurrency(c.MultiInstance)})
+		pinType := constant.PinTypeNone
+		if c.Pinned {
+			pinType = constant.PinTypeTop
+		}
+		param := tasks.TaskParam{
+			Name:        item.Name,
+			Command:     cmd.Command,
+			PreCommand:  c.TaskBefore,
+			PostCommand: c.TaskAfter,
+			Tags:        qlTags(c.Labels),
+			Type:        constant.TaskTypeNormal,
+			Config:      string(taskCfg),
+			Schedule:    schedule,
+			Timeout:     qlDefaultTimeout,
+			WorkDir:     cmd.WorkDir,
+			CleanConfig: qlCleanConfig,
+			PinType:     pinType,
+			Enabled:     !c.Disabled,
+		}
+		im.createTask(&param)
+	}
+	im.report.add(item)
+}
+
+// createRepo 创建仓库同步任务，仓库目录已被其他同步任务使用时跳过
+func (im *qlImporter) createRepo(item *QLImportItem, cfg models.RepoConfig, schedule, pre, post string, enabled bool) {
+	dirName := cfg.RepoDirName
+	if dirName == "" {
+		dirName = utils.GetRepoIdentifier(cfg.SourceURL, cfg.Branch)
+	}
+	sourceID := "repo_" + dirName
+	if im.sourceIDs[sourceID] {
+		item.Action = QLImportActionExists
+		im.report.add(*item)
+		return
+	}
+	im.sourceIDs[sourceID] = true
+	im.repoDirs[dirName] = true
+
+	item.Action = QLImportActionCreate
+	if !im.opts.DryRun {
+		config, _ := json.Marshal(cfg)
+		param := tasks.TaskParam{
+			Name:        item.Name,
+			Command:     fmt.Sprintf("[%s] %s", cfg.SourceType, cfg.SourceURL),
+			PreCommand:  pre,
+			PostCommand: post,
+			Type:        constant.TaskTypeRepo,
+			Config:      string(config),
+			Schedule:    schedule,
+			Timeout:     qlDefaultTimeout,
+			WorkDir:     im.scripts,
+			SourceID:    sourceID,
+			Enabled:     enabled,
+		}
+		im.createTask(&param)
+	}
+	im.report.add(*item)
+}
+
+// createTask 新建任务，新建的任务总是启用，需要禁用时再更新一次
+func (im *qlImporter) createTask(param *tasks.TaskParam) {
+	task := im.taskService.CreateTask(param)
+	if !param.Enabled {
+		im.taskService.UpdateTask(task.ID, param)
+	}
+	im.report.TaskIDs = append(im.report.TaskIDs, task.ID)
+}
+
+// scriptAvailable 判断脚本在面板中是否可用：已存在、将被复制或属于导入的仓库
+func (im *qlImporter) scriptAvailable(script string) bool {
+	if _, err := os.Stat(filepath.Join(im.scripts, script)); err == nil {
+		return true
+	}
+	if im.opts.CopyScripts && im.data.ScriptsDir != "" {
+		if _, err := os.Stat(filepath.Join(im.data.ScriptsDir, script)); err == nil {
+			return true
+		}
+	}
+	first, _, _ := strings.Cut(filepath.ToSlash(script), "/")
+	return first != script && im.repoDirs[first]
+}
+
+// importEnvs 导入环境变量，名称与值都相同的变量视为已存在；青龙允许重名变量，面板同样按重名合并
+func (im *qlImporter) importEnvs() {
+	if len(im.data.Envs) == 0 {
+		return
+	}
+	adminID := ""
+	var admin models.User
+	if res := database.DB.Where("role = ?", constant.AdminRole).Limit(1).Find(&admin); res.Error == nil && res.RowsAffected > 0 {
+		adminID = admin.ID
+	}
+	existing := map[string]bool{}
+	for _, e := range im.envService.GetEnvVarsByUserID(adminID) {
+		existing[e.Name+"\x00"+string(e.Value)] = true
+	}
+
+	for _, e := range im.data.Envs {
+		item := QLImportItem{Kind: QLImportKindEnv, Name: e.Name}
+		if e.Name == "" || strings.ContainsAny(e.Name, "= \t\n") {
+			item.Action, item.Notes = QLImportActionUnmapped, []string{"变量名为空或包含等号、空白字符"}
+			im.report.add(item)
+			continue
+		}
+		key := e.Name + "\x00" + e.Value
+		if existing[key] {
+			item.Action = QLImportActionExists
+			im.report.add(item)
+			continue
+		}
+		existing[key] = true
+		item.Action = QLImportActionCreate
+		if !im.opts.DryRun {
+			env := im.envService.CreateEnvVar(e.Name, e.Value, e.Remarks, constant.EnvTypeNormal, false, !e.Disabled, adminID)
+			im.envService.RecordEnvVersion(env.ID, EnvVersionCreate, 0, adminID, qlActor)
+		}
+		im.report.add(item)
+	}
+}
+
+// importDependencies 导入依赖记录，只写入记录，不执行安装
+func (im *qlImporter) importDependencies() {
+	if len(im.data.Dependencies) == 0 {
+		return
+	}
+	existing := map[string]bool{}
+	if list, err := im.depService.List("", ""); err == nil {
+		for _, d := range list {
+			existing[d.Language+"\x00"+d.Name+"\x00"+d.Version] = true
+		}
+	}
+
+	for _, d := range im.data.Dependencies {
+		item := QLImportItem{Kind: QLImportKindDependency, Name: d.Name}
+		dep, err := qlDependency(d)
+		if err != nil {
+			item.Action, item.Notes = QLImportActionUnmapped, []string{err.Error()}
+			im.report.add(item)
+			continue
+		}
+		key := dep.Language + "\x00" + dep.Name + "\x00" + dep.Version
+		if existing[key] {
+			item.Action = QLImportActionExists
+			im.report.add(item)
+			continue
+		}
+		existing[key] = true
+		item.Action = QLImportActionCreate
+		if !im.opts.DryRun {
+			if err := im.depService.Create(dep); err != nil {
+				item.Action, item.Notes = QLImportActionUnmapped, []string{err.Error()}
+			}
+		}
+		im.report.add(item)
+	}
+}
+
+// qlSchedule 将青龙的 5 位或 6 位 cron 转换为面板使用的 6 位表达式
+func qlSchedule(expr string) (string, error) {
+	expr = strings.Join(strings.Fields(expr), " ")
+	if n := len(strings.Fields(expr)); n != 5 && n != 6 {
+		return "", fmt.Errorf("无法识别的定时规则: %q", expr)
+	}
+	expr = repo.NormalizeCron(expr)
+	parser := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+	if _, err := parser.Parse(expr); err != nil {
+		return "", fmt.Errorf("定时规则 %q 无效: %v", expr, err)
+	}
+	return expr, nil
+}
+
+// qlSubscriptionSchedule 转换订阅的定时规则，间隔执行转换为等效的 cron
+func qlSubscriptionSchedule(sub *QLSubscription) (string, error) {
+	if sub.ScheduleType != "interval" {
+		return qlSchedule(sub.Schedule)
+	}
+	n := sub.IntervalNum
+	limits := map[string]int{"seconds": 59, "minutes": 59, "hours": 23, "days": 31}
+	if limit, ok := limits[sub.IntervalType]; !ok || n <= 0 || n > limit {
+		return "", fmt.Errorf("无法转换的间隔执行规则: 每 %d %s", n, sub.IntervalType)
+	}
+	switch sub.IntervalType {
+	case "seconds":
+		return fmt.Sprintf("*/%d * * * * *", n), nil
+	case "minutes":
+		return fmt.Sprintf("0 */%d * * * *", n), nil
+	case "hours":
+		return fmt.Sprintf("0 0 */%d * * *", n), nil
+	default:
+		return fmt.Sprintf("0 0 0 */%d * *", n), nil
+	}
+}
+
+// qlSubscriptionConfig 将订阅转换为仓库同步配置
+func qlSubscriptionConfig(sub *QLSubscription) (models.RepoConfig, []string, error) {
+	var notes []string
+	if sub.URL == "" {
+		return models.RepoConfig{}, nil, errors.New("订阅缺少地址")
+	}
+	cfg := models.RepoConfig{
+		SourceType:     "git",
+		SourceURL:      sub.URL,
+		Branch:         sub.Branch,
+		Proxy:          "none",
+		WhitelistPaths: sub.Whitelist,
+		Blacklist:      sub.Blacklist,
+		Dependence:     sub.Dependences,
+		Extensions:     sub.Extensions,
+		AutoAddCron:    sub.AutoAddCron,
+		CommentToTask:  "true",
+		RepoSource:     "ql",
+	}
+	if sub.Type == "file" {
+		cfg.SourceType = "url"
+	}
+	switch sub.PullType {
+	case "ssh-key":
+		notes = append(notes, "订阅使用 SSH 私钥拉取，需在运行面板的主机上配置对应私钥")
+	case "user-pwd":
+		cfg.AuthToken = sub.Password
+		notes = append(notes, "已将订阅密码作为访问 Token 使用，如拉取失败请在同步任务中改为 Token")
+	}
+	if sub.Alias != "" {
+		if qlValidDirName(sub.Alias) {
+			cfg.RepoDirName = sub.Alias
+		} else {
+			notes = append(notes, fmt.Sprintf("别名 %s 不能作为目录名，已使用默认目录", sub.Alias))
+		}
+	}
+	if sub.Proxy != "" {
+		notes = append(notes, fmt.Sprintf("未迁移订阅代理 %s，可在同步任务中设置加速代理", sub.Proxy))
+	}
+	return cfg, notes, nil
+}
+
+// qlRepoCommandConfig 转换 ql repo/raw 命令，参数依次为地址、白名单、黑名单、依赖文件、分支、后缀
+func qlRepoCommandConfig(args []string) models.RepoConfig {
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	if args[1] == "raw" {
+		return models.RepoConfig{SourceType: "url", SourceURL: arg(2), Proxy: "none", AutoAddCron: true, CommentToTask: "true", RepoSource: "ql"}
+	}
+	return models.RepoConfig{
+		SourceType:     "git",
+		SourceURL:      arg(2),
+		WhitelistPaths: arg(3),
+		Blacklist:      arg(4),
+		Dependence:     arg(5),
+		Branch:         arg(6),
+		Extensions:     arg(7),
+		Proxy:          "none",
+		AutoAddCron:    true,
+		CommentToTask:  "true",
+		RepoSource:     "ql",
+	}
+}
+
+// qlTaskCommand 转换青龙任务命令；task 命令按脚本后缀生成执行命令，并在脚本所在目录运行
+func qlTaskCommand(command string) (*qlCommand, error) {
+	args := qlSplitArgs(command)
+	if len(args) == 0 {
+		return nil, errors.New("命令为空")
+	}
+	if args[0] == "ql" {
+		return nil, fmt.Errorf("青龙内置命令 %s 在面板中没有对应功能", strings.Join(args[:min(len(args), 2)], " "))
+	}
+	if args[0] != "task" {
+		return &qlCommand{Command: command, WorkDir: constant.ScriptsDirPlaceholder}, nil
+	}
+	if len(args) < 2 {
+		return nil, errors.New("task 命令缺少脚本路径")
+	}
+
+	script := args[1]
+	for _, prefix := range qlScriptPrefixes {
+		script = strings.TrimPrefix(script, prefix)
+	}
+	cmd := &qlCommand{}
+	if filepath.IsAbs(script) {
+		cmd.WorkDir = filepath.Dir(script)
+	} else {
+		script = filepath.ToSlash(filepath.Clean(script))
+		if script == ".." || strings.HasPrefix(script, "../") {
+			return nil, fmt.Errorf("脚本路径 %s 超出 scripts 目录", args[1])
+		}
+		cmd.Script = script
+		cmd.WorkDir = constant.ScriptsDirPlaceholder
+		if dir := filepath.ToSlash(filepath.Dir(script)); dir != "." {
+			cmd.WorkDir += "/" + dir
+		}
+	}
+	cmd.Command = repo.GetCommandByExt(filepath.Ext(script), filepath.Base(script))
+
+	rest := args[2:]
+	if len(rest) > 0 && rest[0] == "now" {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && (rest[0] == "conc" || rest[0] == "desi") {
+		cmd.Notes = append(cmd.Notes, fmt.Sprintf("面板不支持青龙的 %s 多账号执行模式，已改为直接运行脚本", rest[0]))
+		rest = nil
+	}
+	for _, a := range rest {
+		cmd.Command += " " + utils.QuotePath(a)
+	}
+	return cmd, nil
+}
+
+// qlSplitArgs 按空白拆分命令参数，支持单双引号
+func qlSplitArgs(s string) []string {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// qlDependency 转换依赖，青龙将版本写在名称中，如 axios@1.6.0、requests==2.31.0
+func qlDependency(d QLDependency) (*models.Dependency, error) {
+	dep := &models.Dependency{Name: d.Name, Remark: d.Remark}
+	switch d.Type {
+	case 0:
+		dep.Language = "node"
+		// 作用域包以 @ 开头，版本分隔符取最后一个 @
+		if i := strings.LastIndex(d.Name, "@"); i > 0 {
+			dep.Name, dep.Version = d.Name[:i], d.Name[i+1:]
+		}
+	case 1:
+		dep.Language = "python"
+		if parsed := deps.ParseRequirements(d.Name); len(parsed) > 0 {
+			dep.Name, dep.Version = parsed[0].Name, parsed[0].Version
+		}
+	case 2:
+		return nil, errors.New("Linux 系统依赖需在运行面板的主机或容器中手动安装")
+	default:
+		return nil, fmt.Errorf("未知的依赖类型 %d", d.Type)
+	}
+	if dep.Name == "" {
+		return nil, errors.New("依赖名称为空")
+	}
+	return dep, nil
+}
+
+// qlTags 将标签转换为逗号分隔的任务标签
+func qlTags(labels []string) string {
+	tags := make([]string, 0, len(labels))
+	for _, l := range labels {
+		if l = strings.TrimSpace(strings.ReplaceAll(l, ",", " ")); l != "" {
+			tags = append(tags, l)
+		}
+	}
+	return strings.Join(tags, ",")
+}
+
+func qlConcurrency(multi bool) int {
+	if multi {
+		return 1
+	}
+	return 0
+}
+
+// qlValidDirName 与面板创建同步任务时的目录名规则一致
+func qlValidDirName(name string) bool {
+	if strings.Trim(name, ".") == "" || strings.Contains(name, "..") {
+		return false
+	}
+	for _, ch := range name {
+		if !((ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_' || ch == '-' || ch == '.') {
+			return false
+		}
+	}
+	return true
+}
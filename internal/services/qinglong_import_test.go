@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+func TestQLTaskCommand(t *testing.T) {
+	cases := []struct {
+		command string
+		want    qlCommand
+	}{
+		{"task jd_sign.js now", qlCommand{Command: "node 'jd_sign.js'", WorkDir: constant.ScriptsDirPlaceholder, Script: "jd_sign.js"}},
+		{"task owner_repo/a.py", qlCommand{Command: "python 'a.py'", WorkDir: constant.ScriptsDirPlaceholder + "/owner_repo", Script: "owner_repo/a.py"}},
+		{"task /ql/data/scripts/x/b.sh arg1", qlCommand{Command: "bash 'b.sh' 'arg1'", WorkDir: constant.ScriptsDirPlaceholder + "/x", Script: "x/b.sh"}},
+		{"task /opt/c.js", qlCommand{Command: "node 'c.js'", WorkDir: "/opt"}},
+		{"curl -s https://example.com", qlCommand{Command: "curl -s https://example.com", WorkDir: constant.ScriptsDirPlaceholder}},
+	}
+	for _, c := range cases {
+		got, err := qlTaskCommand(c.command)
+		if err != nil {
+			t.Errorf("qlTaskCommand(%q): %v", c.command, err)
+			continue
+		}
+		if got.Command != c.want.Command || got.WorkDir != c.want.WorkDir || got.Script != c.want.Script || len(got.Notes) != 0 {
+			t.Errorf("qlTaskCommand(%q) = %+v, want %+v", c.command, *got, c.want)
+		}
+	}
+
+	conc, err := qlTaskCommand("task y.js conc JD_COOKIE 1-3")
+	if err != nil || conc.Command != "node 'y.js'" || len(conc.Notes) != 1 {
+		t.Errorf("conc mode = %+v, %v", conc, err)
+	}
+	for _, bad := range []string{"ql bot", "task", "task ../etc/passwd", ""} {
+		if _, err := qlTaskCommand(bad); err == nil {
+			t.Errorf("qlTaskCommand(%q) should fail", bad)
+		}
+	}
+}
+
+func TestQLSplitArgsAndRepoCommand(t *testing.T) {
+	args := qlSplitArgs(`ql repo https://github.com/a/b.git "jd_|jx_" 'bak' "" main`)
+	want := []string{"ql", "repo", "https://github.com/a/b.git", "jd_|jx_", "bak", "", "main"}
+	if !reflect.DeepEqual(args, want) {
+		t.Fatalf("qlSplitArgs = %q, want %q", args, want)
+	}
+	cfg := qlRepoCommandConfig(args)
+	if cfg.SourceType != "git" || cfg.WhitelistPaths != "jd_|jx_" || cfg.Blacklist != "bak" || cfg.Dependence != "" || cfg.Branch != "main" || cfg.RepoSource != "ql" {
+		t.Errorf("repo config = %+v", cfg)
+	}
+	if raw := qlRepoCommandConfig(qlSplitArgs("ql raw https://x/y.js")); raw.SourceType != "url" || raw.SourceURL != "https://x/y.js" {
+		t.Errorf("raw config = %+v", raw)
+	}
+}
+
+func TestQLSchedule(t *testing.T) {
+	for in, want := range map[string]string{"0 8 * * *": "0 0 8 * * *", " 30  0 9 * * 1 ": "30 0 9 * * 1"} {
+		if got, err := qlSchedule(in); err != nil || got != want {
+			t.Errorf("qlSchedule(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "@daily", "61 * * * *", "* * *"} {
+		if _, err := qlSchedule(bad); err == nil {
+			t.Errorf("qlSchedule(%q) should fail", bad)
+		}
+	}
+
+	interval := map[string]string{"seconds": "*/5 * * * * *", "minutes": "0 */5 * * * *", "hours": "0 0 */5 * * *", "days": "0 0 0 */5 * *"}
+	for unit, want := range interval {
+		sub := &QLSubscription{ScheduleType: "interval", IntervalType: unit, IntervalNum: 5}
+		if got, err := qlSubscriptionSchedule(sub); err != nil || got != want {
+			t.Errorf("interval %s = %q, %v; want %q", unit, got, err, want)
+		}
+	}
+	for _, sub := range []*QLSubscription{
+		{ScheduleType: "interval", IntervalType: "weeks", IntervalNum: 1},
+		{ScheduleType: "interval", IntervalType: "hours", IntervalNum: 24},
+		{ScheduleType: "interval", IntervalType: "minutes"},
+	} {
+		if _, err := qlSubscriptionSchedule(sub); err == nil {
+			t.Errorf("interval %+v should fail", *sub)
+		}
+	}
+}
+
+func TestQLSubscriptionConfig(t *testing.T) {
+	cfg, notes, err := qlSubscriptionConfig(&QLSubscription{
+		URL: "https://github.com/o/r.git", Type: "private-repo", Alias: "o_r", Branch: "dev",
+		PullType: "user-pwd", Password: "tok", Proxy: "http://127.0.0.1:7890", AutoAddCron: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RepoDirName != "o_r" || cfg.AuthToken != "tok" || cfg.Branch != "dev" || !cfg.AutoAddCron || cfg.CommentToTask != "true" {
+		t.Errorf("config = %+v", cfg)
+	}
+	if len(notes) != 2 {
+		t.Errorf("notes = %v", notes)
+	}
+
+	cfg, notes, _ = qlSubscriptionConfig(&QLSubscription{URL: "https://x/y.js", Type: "file", Alias: "a/b"})
+	if cfg.SourceType != "url" || cfg.RepoDirName != "" || len(notes) != 1 {
+		t.Errorf("file subscription = %+v, %v", cfg, notes)
+	}
+	if _, _, err := qlSubscriptionConfig(&QLSubscription{}); err == nil {
+		t.Error("subscription without url should fail")
+	}
+}
+
+func TestQLDependency(t *testing.T) {
+	cases := []struct {
+		in                      QLDependency
+		name, version, language string
+	}{
+		{QLDependency{Name: "axios", Type: 0}, "axios", "", "node"},
+		{QLDependency{Name: "axios@1.6.0", Type: 0}, "axios", "1.6.0", "node"},
+		{QLDependency{Name: "@types/node", Type: 0}, "@types/node", "", "node"},
+		{QLDependency{Name: "@types/node@20.1.0", Type: 0}, "@types/node", "20.1.0", "node"},
+		{QLDependency{Name: "requests==2.31.0", Type: 1}, "requests", "2.31.0", "python"},
+	}
+	for _, c := range cases {
+		dep, err := qlDependency(c.in)
+		if err != nil || dep.Name != c.name || dep.Version != c.version || dep.Language != c.language {
+			t.Errorf("qlDependency(%+v) = %+v, %v", c.in, dep, err)
+		}
+	}
+	if _, err := qlDependency(QLDependency{Name: "gcc", Type: 2}); err == nil {
+		t.Error("linux dependency should not be mapped")
+	}
+}
+
+func TestLoadQLDataNeDB(t *testing.T) {
+	root := t.TempDir()
+	data := filepath.Join(root, "data")
+	writeGitOpsFile(t, data, "db/crontab.db", `{"name":"a","command":"task a.js","schedule":"0 9 * * *","labels":["x","x"],"_id":"1"}
+{"name":"b","command":"task b.js","schedule":"0 9 * * *","_id":"2"}
+{"$$deleted":true,"_id":"2"}
+{"name":"a2","command":"task a.js","schedule":"0 9 * * *","isDisabled":1,"isPinned":1,"extra_schedules":[{"schedule":"0 20 * * *"}],"_id":"1"}
+{"$$indexCreated":{"fieldName":"command"}}
+`)
+	writeGitOpsFile(t, data, "db/env.db", `{"name":"TOKEN","value":"v","remarks":"r","status":1,"_id":"e"}`+"\n")
+	writeGitOpsFile(t, data, "config/task_before.sh", "#!/bin/bash\n# 只有注释\n")
+	writeGitOpsFile(t, data, "config/extra.sh", "export A=1\n")
+	os.MkdirAll(filepath.Join(data, "scripts"), 0755)
+
+	got, err := LoadQLData(root)
+	if err != nil {
+		t.Fatalf("LoadQLData: %v", err)
+	}
+	if got.Format != "nedb" || got.ScriptsDir != filepath.Join(data, "scripts") {
+		t.Errorf("source = %s %s %s", got.Format, got.Source, got.ScriptsDir)
+	}
+	if len(got.Crons) != 1 {
+		t.Fatalf("crons = %+v", got.Crons)
+	}
+	c := got.Crons[0]
+	if c.Name != "a2" || !c.Disabled || !c.Pinned || !reflect.DeepEqual(c.Labels, []string(nil)) || !reflect.DeepEqual(c.ExtraSchedules, []string{"0 20 * * *"}) {
+		t.Errorf("cron = %+v", c)
+	}
+	if len(got.Envs) != 1 || got.Envs[0].Remarks != "r" || !got.Envs[0].Disabled {
+		t.Errorf("envs = %+v", got.Envs)
+	}
+	if !reflect.DeepEqual(got.Hooks, []string{"extra.sh"}) {
+		t.Errorf("hooks = %v", got.Hooks)
+	}
+
+	if _, err := LoadQLData(t.TempDir()); !errors.Is(err, ErrQLSource) {
+		t.Errorf("empty dir: expected ErrQLSource, got %v", err)
+	}
+}
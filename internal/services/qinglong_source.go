@@ -0,0 +1,406 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrQLSource 无法识别或读取青龙数据
+var ErrQLSource = errors.New("无法读取青龙数据")
+
+// 青龙 SQLite 数据库中的表名（Sequelize 复数形式）
+const (
+	qlTableCrontabs      = "Crontabs"
+	qlTableEnvs          = "Envs"
+	qlTableDependences   = "Dependences"
+	qlTableSubscriptions = "Subscriptions"
+)
+
+// qlNeDBFiles 青龙 2.11 之前使用 NeDB 存储时的数据文件，订阅功能出现时已迁移到 SQLite
+var qlNeDBFiles = map[string]string{
+	qlTableCrontabs:    "crontab.db",
+	qlTableEnvs:        "env.db",
+	qlTableDependences: "dependence.db",
+}
+
+// qlHookFiles 青龙全局钩子脚本，面板中没有对应功能
+var qlHookFiles = []string{"extra.sh", "task_before.sh", "task_after.sh"}
+
+// QLCron 青龙定时任务
+type QLCron struct {
+	ID             string
+	Name           string
+	Command        string
+	Schedule       string
+	ExtraSchedules []string
+	Labels         []string
+	TaskBefore     string
+	TaskAfter      string
+	SubID          string
+	Disabled       bool
+	Pinned         bool
+	System         bool
+	MultiInstance  bool
+}
+
+// QLEnv 青龙环境变量
+type QLEnv struct {
+	Name     string
+	Value    string
+	Remarks  string
+	Disabled bool
+}
+
+// QLDependency 青龙依赖，Type 为 0 nodejs、1 python3、2 linux
+type QLDependency struct {
+	Name   string
+	Type   int
+	Remark string
+}
+
+// QLSubscription 青龙订阅
+type QLSubscription struct {
+	ID           string
+	Name         string
+	URL          string
+	Type         string // public-repo, private-repo, file
+	Branch       string
+	Alias        string
+	Whitelist    string
+	Blacklist    string
+	Dependences  string
+	Extensions   string
+	ScheduleType string // crontab, interval
+	Schedule     string
+	IntervalType string // days, hours, minutes, seconds
+	IntervalNum  int
+	PullType     string // ssh-key, user-pwd
+	Password     string
+	Proxy        string
+	SubBefore    string
+	SubAfter     string
+	Disabled     bool
+	AutoAddCron  bool
+}
+
+// QLData 从青龙数据目录或数据库中读取的全部数据
+type QLData struct {
+	Source        string   // 实际读取的数据库文件或目录
+	Format        string   // sqlite 或 nedb
+	ScriptsDir    string   // 青龙 scripts 目录，未找到时为空
+	Hooks         []string // 有实际内容的全局钩子脚本
+	Crons         []QLCron
+	Envs          []QLEnv
+	Dependencies  []QLDependency
+	Subscriptions []QLSubscription
+}
+
+// LoadQLData 读取青龙数据，path 可以是青龙根目录、data 目录或 database.sqlite 文件
+func LoadQLData(path string) (*QLData, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQLSource, err)
+	}
+
+	root := path
+	if !info.IsDir() {
+		// database.sqlite 位于 data/db 下
+		root = filepath.Dir(filepath.Dir(path))
+	} else if isQLDataDir(filepath.Join(path, "data")) {
+		root = filepath.Join(path, "data")
+	}
+
+	data := &QLData{}
+	var tables map[string][]map[string]any
+	dbFile := path
+	if info.IsDir() {
+		dbFile = filepath.Join(root, "db", "database.sqlite")
+	}
+	if _, err := os.Stat(dbFile); err == nil {
+		data.Source, data.Format = dbFile, "sqlite"
+		tables, err = readQLSQLite(dbFile)
+	} else if info.IsDir() && isQLDataDir(root) {
+		data.Source, data.Format = filepath.Join(root, "db"), "nedb"
+		tables, err = readQLNeDB(data.Source)
+	} else {
+		return nil, fmt.Errorf("%w: %s 下未找到 db/database.sqlite 或 NeDB 数据文件", ErrQLSource, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrQLSource, err)
+	}
+
+	if dir := filepath.Join(root, "scripts"); isDirPath(dir) {
+		data.ScriptsDir = dir
+	}
+	for _, name := range qlHookFiles {
+		if content, err := os.ReadFile(filepath.Join(root, "config", name)); err == nil && hasShellContent(string(content)) {
+			data.Hooks = append(data.Hooks, name)
+		}
+	}
+
+	for _, row := range tables[qlTableSubscriptions] {
+		data.Subscriptions = append(data.Subscriptions, qlSubscriptionFromRow(row))
+	}
+	for _, row := range tables[qlTableCrontabs] {
+		data.Crons = append(data.Crons, qlCronFromRow(row))
+	}
+	for _, row := range tables[qlTableEnvs] {
+		data.Envs = append(data.Envs, QLEnv{
+			Name:     qlString(row, "name"),
+			Value:    qlString(row, "value"),
+			Remarks:  qlString(row, "remarks"),
+			Disabled: qlInt(row, "status") == 1,
+		})
+	}
+	for _, row := range tables[qlTableDependences] {
+		data.Dependencies = append(data.Dependencies, QLDependency{
+			Name:   qlString(row, "name"),
+			Type:   qlInt(row, "type"),
+			Remark: qlString(row, "remark"),
+		})
+	}
+	return data, nil
+}
+
+// isQLDataDir 判断目录是否为青龙 data 目录
+func isQLDataDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "db", "database.sqlite")); err == nil {
+		return true
+	}
+	for _, file := range qlNeDBFiles {
+		if _, err := os.Stat(filepath.Join(dir, "db", file)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func isDirPath(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// hasShellContent 判断脚本中是否有注释以外的内容
+func hasShellContent(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// readQLSQLite 以只读方式读取青龙 SQLite 数据库，缺少的表视为空
+func readQLSQLite(file string) (map[string][]map[string]any, error) {
+	db, err := gorm.Open(sqlite.Open(file+"?_pragma=query_only(1)"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	tables := map[string][]map[string]any{}
+	for _, table := range []string{qlTableCrontabs, qlTableEnvs, qlTableDependences, qlTableSubscriptions} {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		var rows []map[string]any
+		if err := db.Table(table).Order("id").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", table, err)
+		}
+		tables[table] = rows
+	}
+	return tables, nil
+}
+
+// readQLNeDB 读取 NeDB 数据文件，文件按行追加写入，同一 _id 以最后一行为准
+func readQLNeDB(dir string) (map[string][]map[string]any, error) {
+	tables := map[string][]map[string]any{}
+	for table, name := range qlNeDBFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs := map[string]map[string]any{}
+		var order []string
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var doc map[string]any
+			if json.Unmarshal(scanner.Bytes(), &doc) != nil {
+				continue
+			}
+			id := qlString(doc, "_id")
+			if id == "" {
+				continue
+			}
+			if deleted, _ := doc["$$deleted"].(bool); deleted {
+				delete(docs, id)
+				continue
+			}
+			if _, ok := docs[id]; !ok {
+				order = append(order, id)
+			}
+			docs[id] = doc
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", name, err)
+		}
+		for _, id := range order {
+			if doc, ok := docs[id]; ok {
+				doc["id"] = id
+				tables[table] = append(tables[table], doc)
+			}
+		}
+	}
+	return tables, nil
+}
+
+func qlCronFromRow(row map[string]any) QLCron {
+	c := QLCron{
+		ID:            qlString(row, "id"),
+		Name:          qlString(row, "name"),
+		Command:       qlString(row, "command"),
+		Schedule:      qlString(row, "schedule"),
+		Labels:        qlStrings(row, "labels"),
+		TaskBefore:    qlString(row, "task_before"),
+		TaskAfter:     qlString(row, "task_after"),
+		SubID:         qlString(row, "sub_id"),
+		Disabled:      qlInt(row, "isDisabled") == 1,
+		Pinned:        qlInt(row, "isPinned") == 1,
+		System:        qlInt(row, "isSystem") == 1,
+		MultiInstance: qlInt(row, "allow_multiple_instances") == 1,
+	}
+	for _, extra := range qlObjects(row, "extra_schedules") {
+		if s := qlString(extra, "schedule"); s != "" {
+			c.ExtraSchedules = append(c.ExtraSchedules, s)
+		}
+	}
+	return c
+}
+
+func qlSubscriptionFromRow(row map[string]any) QLSubscription {
+	s := QLSubscription{
+		ID:           qlString(row, "id"),
+		Name:         qlString(row, "name"),
+		URL:          qlString(row, "url"),
+		Type:         qlString(row, "type"),
+		Branch:       qlString(row, "branch"),
+		Alias:        qlString(row, "alias"),
+		Whitelist:    qlString(row, "whitelist"),
+		Blacklist:    qlString(row, "blacklist"),
+		Dependences:  qlString(row, "dependences"),
+		Extensions:   qlString(row, "extensions"),
+		ScheduleType: qlString(row, "schedule_type"),
+		Schedule:     qlString(row, "schedule"),
+		PullType:     qlString(row, "pull_type"),
+		Proxy:        qlString(row, "proxy"),
+		SubBefore:    qlString(row, "sub_before"),
+		SubAfter:     qlString(row, "sub_after"),
+		Disabled:     qlInt(row, "is_disabled") == 1,
+		AutoAddCron:  qlInt(row, "autoAddCron") == 1,
+	}
+	if interval := qlObject(row, "interval_schedule"); interval != nil {
+		s.IntervalType = qlString(interval, "type")
+		s.IntervalNum = qlInt(interval, "value")
+	}
+	if option := qlObject(row, "pull_option"); option != nil {
+		s.Password = qlString(option, "password")
+	}
+	return s
+}
+
+// qlString 读取文本字段，SQLite 中的文本可能以 []byte 返回
+func qlString(row map[string]any, key string) string {
+	switch v := row[key].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// qlInt 读取数值或布尔字段
+func qlInt(row map[string]any, key string) int {
+	switch v := row[key].(type) {
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case bool:
+		if v {
+			return 1
+		}
+	case string, []byte:
+		n, _ := strconv.Atoi(qlString(row, key))
+		return n
+	}
+	return 0
+}
+
+// qlJSON 读取 JSON 字段，SQLite 中以文本存储，NeDB 中为原始结构
+func qlJSON(row map[string]any, key string) any {
+	switch row[key].(type) {
+	case string, []byte:
+		var v any
+		if json.Unmarshal([]byte(qlString(row, key)), &v) == nil {
+			return v
+		}
+		return nil
+	}
+	return row[key]
+}
+
+func qlObject(row map[string]any, key string) map[string]any {
+	obj, _ := qlJSON(row, key).(map[string]any)
+	return obj
+}
+
+func qlObjects(row map[string]any, key string) []map[string]any {
+	list, _ := qlJSON(row, key).([]any)
+	var objs []map[string]any
+	for _, item := range list {
+		if obj, ok := item.(map[string]any); ok {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// qlStrings 读取字符串数组字段，去除空值和重复值
+func qlStrings(row map[string]any, key string) []string {
+	list, _ := qlJSON(row, key).([]any)
+	seen := map[string]bool{}
+	var out []string
+	for _, item := range list {
+		s, _ := item.(string)
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/services/relation"
+)
+
+// roleLevels 角色等级，数值越大权限越高
+var roleLevels = map[string]int{
+	constant.RoleViewer:     1,
+	constant.RoleLegacyUser: 1,
+	constant.RoleOperator:   2,
+	constant.RoleEditor:     3,
+	constant.AdminRole:      4,
+}
+
+// AssignableRoles 可分配给用户的角色
+var AssignableRoles = []string{constant.RoleViewer, constant.RoleOperator, constant.RoleEditor, constant.AdminRole}
+
+// IsValidRole 判断是否为可分配的角色
+func IsValidRole(role string) bool {
+	for _, r := range AssignableRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole 判断 role 是否具备 required 角色的权限
+func HasRole(role, required string) bool {
+	return roleLevels[role] > 0 && roleLevels[role] >= roleLevels[required]
+}
+
+// SplitScopes 解析逗号分隔的标签范围
+func SplitScopes(scopes string) []string {
+	var tags []string
+	for _, t := range strings.Split(scopes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// AccessScope 请求者的角色及可访问的任务范围
+// nil 表示内部调用，不做任何限制
+type AccessScope struct {
+	Role  string
+	Tags  []string // 可访问的任务标签，为空表示不限制
+	Tasks []string // 可访问的任务 ID，为空表示不限制（仅 API 令牌使用）
+}
+
+// NewAccessScope 根据用户角色与标签范围构建访问范围
+func NewAccessScope(role, scopes string) *AccessScope {
+	return &AccessScope{Role: role, Tags: SplitScopes(scopes)}
+}
+
+// Can 判断是否具备指定角色的权限
+func (s *AccessScope) Can(role string) bool {
+	return s == nil || HasRole(s.Role, role)
+}
+
+// Scoped 是否受标签或任务范围限制（管理员不受限制）
+func (s *AccessScope) Scoped() bool {
+	return s != nil && (len(s.Tags) > 0 || len(s.Tasks) > 0) && s.Role != constant.AdminRole
+}
+
+// TaskLimited 是否限定了具体任务，此时不允许创建新任务
+func (s *AccessScope) TaskLimited() bool {
+	return s.Scoped() && len(s.Tasks) > 0
+}
+
+// LimitedTaskIDs 返回限定的任务 ID，未限定具体任务时返回 nil
+func (s *AccessScope) LimitedTaskIDs() []string {
+	if !s.TaskLimited() {
+		return nil
+	}
+	return s.Tasks
+}
+
+// AllowTags 判断逗号分隔的标签中是否至少有一个在范围内
+func (s *AccessScope) AllowTags(tags string) bool {
+	if !s.Scoped() || len(s.Tags) == 0 {
+		return true
+	}
+	for _, t := range SplitScopes(tags) {
+		if s.hasTag(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowTask 判断任务是否在可访问范围内
+func (s *AccessScope) AllowTask(taskID string) bool {
+	if !s.Scoped() {
+		return true
+	}
+	if !s.hasTask(taskID) {
+		return false
+	}
+	if len(s.Tags) == 0 {
+		return true
+	}
+	tagsMap := relation.DataRelation.LoadTags([]string{taskID}, constant.RelationTypeTaskTag)
+	return s.AllowTags(strings.Join(tagsMap[taskID], ","))
+}
+
+// AllowTaskTags 判断已加载标签的任务是否在可访问范围内
+func (s *AccessScope) AllowTaskTags(taskID, tags string) bool {
+	return (!s.Scoped() || s.hasTask(taskID)) && s.AllowTags(tags)
+}
+
+// TaskIDs 获取范围内的全部任务 ID，仅在 Scoped 时有意义
+func (s *AccessScope) TaskIDs() []string {
+	if len(s.Tags) == 0 {
+		return s.Tasks
+	}
+	ids := relation.DataRelation.DataIDsByTags(constant.RelationTypeTaskTag, s.Tags)
+	if len(s.Tasks) == 0 {
+		return ids
+	}
+	visible := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if s.hasTask(id) {
+			visible = append(visible, id)
+		}
+	}
+	return visible
+}
+
+// FilterTags 将用户传入的标签筛选条件限制在范围内
+// 返回收敛后的筛选条件，ok 为 false 表示筛选结果必然为空
+func (s *AccessScope) FilterTags(tags string) (string, bool) {
+	if !s.Scoped() || len(s.Tags) == 0 {
+		return tags, true
+	}
+	requested := SplitScopes(tags)
+	if len(requested) == 0 {
+		return strings.Join(s.Tags, ","), true
+	}
+	var allowed []string
+	for _, t := range requested {
+		if s.hasTag(t) {
+			allowed = append(allowed, t)
+		}
+	}
+	return strings.Join(allowed, ","), len(allowed) > 0
+}
+
+// LimitTo 将范围收敛到 limit 之内：角色取较低者，标签与任务取交集（任一方不限制时取另一方）
+// ok 为 false 表示两者的标签或任务没有交集，收敛后的范围为空
+func (s *AccessScope) LimitTo(limit *AccessScope) (*AccessScope, bool) {
+	scope := &AccessScope{Role: s.Role, Tags: s.Tags, Tasks: s.Tasks}
+	if limit == nil {
+		return scope, true
+	}
+	if !HasRole(limit.Role, scope.Role) {
+		scope.Role = limit.Role
+	}
+	if !limit.Scoped() {
+		return scope, true
+	}
+	var tagsOK, tasksOK bool
+	scope.Tags, tagsOK = intersectScopes(scope.Tags, limit.Tags)
+	scope.Tasks, tasksOK = intersectScopes(scope.Tasks, limit.Tasks)
+	return scope, tagsOK && tasksOK
+}
+
+// Covers 判断标签与任务限制是否都在范围内，用于校验新建令牌不超出创建者的权限
+func (s *AccessScope) Covers(tags, taskIDs string) bool {
+	if !s.Scoped() {
+		return true
+	}
+	for _, t := range SplitScopes(tags) {
+		if len(s.Tags) > 0 && !s.hasTag(t) {
+			return false
+		}
+	}
+	for _, id := range SplitScopes(taskIDs) {
+		if !s.AllowTask(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersectScopes 求两个限制列表的交集，为空的一方表示不限制
+func intersectScopes(a, b []string) ([]string, bool) {
+	if len(b) == 0 {
+		return a, true
+	}
+	if len(a) == 0 {
+		return b, true
+	}
+	var result []string
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				result = append(result, x)
+				break
+			}
+		}
+	}
+	return result, len(result) > 0
+}
+
+func (s *AccessScope) hasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AccessScope) hasTask(taskID string) bool {
+	if len(s.Tasks) == 0 {
+		return true
+	}
+	for _, id := range s.Tasks {
+		if id == taskID {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowLog 判断执行日志所属任务是否在可访问范围内
+func (s *AccessScope) AllowLog(logID string) bool {
+	if !s.Scoped() {
+		return true
+	}
+	var taskID string
+	database.DB.Model(&models.TaskLog{}).Where("id = ?", logID).Limit(1).Pluck("task_id", &taskID)
+	return taskID != "" && s.AllowTask(taskID)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+func TestHasRole(t *testing.T) {
+	cases := []struct {
+		role, required string
+		want           bool
+	}{
+		{constant.AdminRole, constant.RoleEditor, true},
+		{constant.RoleEditor, constant.RoleOperator, true},
+		{constant.RoleOperator, constant.RoleEditor, false},
+		{constant.RoleViewer, constant.RoleOperator, false},
+		{constant.RoleLegacyUser, constant.RoleViewer, true},
+		{"", constant.RoleViewer, false},
+		{"unknown", constant.RoleViewer, false},
+	}
+	for _, tc := range cases {
+		if got := HasRole(tc.role, tc.required); got != tc.want {
+			t.Errorf("HasRole(%q, %q) = %v, want %v", tc.role, tc.required, got, tc.want)
+		}
+	}
+}
+
+func TestAccessScope_Tags(t *testing.T) {
+	scope := NewAccessScope(constant.RoleOperator, " team-a, ,team-b")
+
+	if !scope.Scoped() {
+		t.Fatal("operator with tags should be scoped")
+	}
+	if !scope.AllowTags("x,team-b") || scope.AllowTags("x,y") || scope.AllowTags("") {
+		t.Error("AllowTags should require at least one overlapping tag")
+	}
+
+	if got, ok := scope.FilterTags(""); !ok || got != "team-a,team-b" {
+		t.Errorf("empty filter should fall back to scope tags, got %q %v", got, ok)
+	}
+	if got, ok := scope.FilterTags("team-b,other"); !ok || got != "team-b" {
+		t.Errorf("filter should drop tags outside scope, got %q %v", got, ok)
+	}
+	if _, ok := scope.FilterTags("other"); ok {
+		t.Error("filter with only foreign tags should match nothing")
+	}
+}
+
+func TestAccessScope_Unrestricted(t *testing.T) {
+	var internal *AccessScope
+	if internal.Scoped() || !internal.Can(constant.AdminRole) || !internal.AllowTags("") {
+		t.Error("nil scope (internal call) must be unrestricted")
+	}
+
+	admin := NewAccessScope(constant.AdminRole, "team-a")
+	if admin.Scoped() || !admin.AllowTags("other") {
+		t.Error("admin should ignore tag scopes")
+	}
+	if got, _ := admin.FilterTags("x"); got != "x" {
+		t.Errorf("admin filter should pass through, got %q", got)
+	}
+
+	viewer := NewAccessScope(constant.RoleViewer, "")
+	if viewer.Scoped() || viewer.Can(constant.RoleOperator) {
+		t.Error("viewer without tags is unscoped but cannot operate")
+	}
+}
+
+func TestAccessScope_Tasks(t *testing.T) {
+	scope := &AccessScope{Role: constant.RoleEditor, Tasks: []string{"t1", "t2"}}
+
+	if !scope.Scoped() || !scope.TaskLimited() {
+		t.Fatal("task restriction should scope the access")
+	}
+	if !scope.AllowTask("t1") || scope.AllowTask("t3") {
+		t.Error("AllowTask should only accept listed tasks")
+	}
+	if !scope.AllowTaskTags("t2", "any") || scope.AllowTaskTags("t3", "any") {
+		t.Error("AllowTaskTags should ignore tags when only tasks are limited")
+	}
+	if got, ok := scope.FilterTags("x"); !ok || got != "x" {
+		t.Errorf("tag filter should pass through without tag scope, got %q %v", got, ok)
+	}
+	if ids := scope.LimitedTaskIDs(); len(ids) != 2 {
+		t.Errorf("LimitedTaskIDs = %v", ids)
+	}
+
+	if NewAccessScope(constant.RoleEditor, "team-a").LimitedTaskIDs() != nil {
+		t.Error("tag-only scope should not limit task ids")
+	}
+}
+
+func TestAccessScope_LimitTo(t *testing.T) {
+	token := &AccessScope{Role: constant.RoleEditor, Tags: []string{"team-a", "team-b"}, Tasks: []string{"t1"}}
+
+	// 创建者为管理员时令牌范围不变
+	if got, ok := token.LimitTo(NewAccessScope(constant.AdminRole, "")); !ok || got.Role != constant.RoleEditor || len(got.Tags) != 2 {
+		t.Errorf("admin creator: %+v %v", got, ok)
+	}
+	// 令牌标签与创建者标签取交集，角色取较低者
+	got, ok := token.LimitTo(NewAccessScope(constant.RoleViewer, "team-b,team-c"))
+	if !ok || got.Role != constant.RoleViewer || len(got.Tags) != 1 || got.Tags[0] != "team-b" || len(got.Tasks) != 1 {
+		t.Errorf("intersect: %+v %v", got, ok)
+	}
+	// 令牌未限定标签时继承创建者的标签
+	if got, ok := (&AccessScope{Role: constant.RoleEditor}).LimitTo(NewAccessScope(constant.RoleEditor, "team-c")); !ok || len(got.Tags) != 1 || got.Tags[0] != "team-c" {
+		t.Errorf("inherit: %+v %v", got, ok)
+	}
+	// 没有交集时范围为空
+	if _, ok := token.LimitTo(NewAccessScope(constant.RoleEditor, "team-c")); ok {
+		t.Error("disjoint tags must not widen the token to the creator's scope")
+	}
+	if len(token.Tags) != 2 {
+		t.Error("LimitTo must not modify the original scope")
+	}
+}
+
+func TestAccessScope_Covers(t *testing.T) {
+	if !NewAccessScope(constant.AdminRole, "").Covers("any", "t9") {
+		t.Error("admin covers everything")
+	}
+	creator := &AccessScope{Role: constant.RoleEditor, Tasks: []string{"t1", "t2"}}
+	if !creator.Covers("", "t1,t2") || !creator.Covers("", "") {
+		t.Error("tasks within scope should be covered")
+	}
+	if creator.Covers("", "t1,t3") {
+		t.Error("task outside scope must not be covered")
+	}
+	tagged := NewAccessScope(constant.RoleEditor, "team-a")
+	if !tagged.Covers("team-a", "") || tagged.Covers("team-a,team-b", "") {
+		t.Error("tags outside scope must not be covered")
+	}
+}
@@ -139,3 +139,18 @@ func (s *DataRelationService) GetAllTags(relType string) ([]string, error) {
 	}
 	return tags, nil
 }
+
+// DataIDsByTags 获取关联了任一指定标签的数据 ID
+func (s *DataRelationService) DataIDsByTags(relType string, tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	var storageIDs []string
+	database.DB.Model(&models.DataStorage{}).Where("type = ? AND name IN ?", relType, tags).Pluck("id", &storageIDs)
+	if len(storageIDs) == 0 {
+		return nil
+	}
+	var dataIDs []string
+	database.DB.Model(&models.DataRelation{}).Where("type = ? AND relate_id IN ?", relType, storageIDs).Distinct().Pluck("data_id", &dataIDs)
+	return dataIDs
+}
@@ -142,7 +142,7 @@ func ParseRepoScriptsAndAddCron(taskID string, logWriter io.Writer, forceComment
 			}
 
 			// 找到任务，进行保存
-			command := getCommandByExt(ext, displayPath)
+			command := GetCommandByExt(ext, displayPath)
 			taskID, isNew := upsertRepoTask(&repoTask, sourceID, taskName, command, taskCron, displayWorkDir, tag)
 
 			if isNew {
@@ -193,7 +193,7 @@ func upsertRepoTask(parentTask *models.Task, sourceID, name, command, cron, work
 		// 更新操作
 		existing.Name = name
 		existing.Command = models.BigText(command)
-		existing.Schedule = normalizeCron(cron)
+		existing.Schedule = NormalizeCron(cron)
 		existing.Languages = parentTask.Languages
 		existing.SourceID = sourceID
 		existing.RepoTaskID = parentTask.ID
@@ -216,7 +216,7 @@ func upsertRepoTask(parentTask *models.Task, sourceID, name, command, cron, work
 		newTask := &models.Task{
 			Name:        name,
 			Command:     models.BigText(command),
-			Schedule:    normalizeCron(cron),
+			Schedule:    NormalizeCron(cron),
 			Type:        "task",
 			TriggerType: constant.TriggerTypeCron,
 			Tags:        tag,
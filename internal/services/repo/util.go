@@ -208,8 +208,8 @@ func sanitizeIdentifier(s string) string {
 	return strings.ToLower(strings.Trim(res, "_"))
 }
 
-// normalizeCron 确保 cron 表达式具有 6 个字段
-func normalizeCron(cron string) string {
+// NormalizeCron 确保 cron 表达式具有 6 个字段
+func NormalizeCron(cron string) string {
 	fields := strings.Fields(cron)
 	if len(fields) == 5 {
 		return "0 " + cron
@@ -232,8 +232,8 @@ func isDir(path string) bool {
 	return info.IsDir()
 }
 
-// getCommandByExt 根据文件扩展名返回默认执行命令
-func getCommandByExt(ext, path string) string {
+// GetCommandByExt 根据文件扩展名返回默认执行命令
+func GetCommandByExt(ext, path string) string {
 	quotedPath := utils.QuotePath(path)
 	switch ext {
 	case ".js", ".ts":
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+// secretResolveTimeout 单个外部机密引用的解析超时时间
+const secretResolveTimeout = 15 * time.Second
+
+// SecretRef 外部机密引用，形如 scheme://path#field
+type SecretRef struct {
+	Raw    string
+	Scheme string
+	Path   string
+	Field  string
+}
+
+func (r SecretRef) String() string {
+	return r.Raw
+}
+
+// SecretProvider 外部机密后端，按引用的 scheme 注册
+// cfg 为 secret_backend 设置分组的当前值
+type SecretProvider interface {
+	Scheme() string
+	Resolve(ctx context.Context, ref SecretRef, cfg map[string]string) (string, error)
+}
+
+type cachedSecret struct {
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{}
+
+	secretCacheMu sync.Mutex
+	secretCache   = map[string]cachedSecret{}
+)
+
+func init() {
+	RegisterSecretProvider(fileSecretProvider{})
+	RegisterSecretProvider(envSecretProvider{})
+	RegisterSecretProvider(vaultSecretProvider{})
+	RegisterSecretProvider(execSecretProvider{})
+}
+
+// RegisterSecretProvider 注册外部机密后端，同名 scheme 后注册的覆盖先注册的
+func RegisterSecretProvider(p SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[strings.ToLower(p.Scheme())] = p
+}
+
+// SecretSchemes 已注册的引用前缀
+func SecretSchemes() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	schemes := make([]string, 0, len(secretProviders))
+	for s := range secretProviders {
+		schemes = append(schemes, s)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+func getSecretProvider(scheme string) SecretProvider {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	return secretProviders[scheme]
+}
+
+// ParseSecretRef 判断机密值是否为外部引用，仅识别已注册的 scheme，其余按普通机密原样使用
+func ParseSecretRef(value string) (SecretRef, bool) {
+	value = strings.TrimSpace(value)
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok || strings.ContainsAny(scheme, " \t\r\n") || strings.Contains(rest, "\n") {
+		return SecretRef{}, false
+	}
+	scheme = strings.ToLower(scheme)
+	if getSecretProvider(scheme) == nil {
+		return SecretRef{}, false
+	}
+	path, field, _ := strings.Cut(rest, "#")
+	return SecretRef{Raw: value, Scheme: scheme, Path: path, Field: field}, true
+}
+
+// ResolveSecretRef 解析外部机密引用，成功结果按设置的秒数缓存，失败不缓存
+func ResolveSecretRef(ref SecretRef) (string, error) {
+	cfg := NewSettingsService().GetSection(constant.SectionSecretBackend)
+	ttl := time.Duration(utils.ToInt(cfg[constant.KeySecretCacheSeconds], 0)) * time.Second
+
+	if ttl > 0 {
+		secretCacheMu.Lock()
+		item, ok := secretCache[ref.Raw]
+		secretCacheMu.Unlock()
+		if ok && time.Now().Before(item.expiresAt) {
+			return item.value, nil
+		}
+	}
+
+	value, err := resolveSecretRef(ref, cfg)
+	if err != nil {
+		return "", err
+	}
+	if ttl > 0 {
+		secretCacheMu.Lock()
+		secretCache[ref.Raw] = cachedSecret{value: value, expiresAt: time.Now().Add(ttl)}
+		secretCacheMu.Unlock()
+	}
+	return value, nil
+}
+
+// TestSecretRef 跳过缓存解析引用，用于设置页检测
+func TestSecretRef(raw string) (string, error) {
+	ref, ok := ParseSecretRef(raw)
+	if !ok {
+		return "", fmt.Errorf("不是有效的外部机密引用，支持的前缀: %s", strings.Join(SecretSchemes(), "://, ")+"://")
+	}
+	return resolveSecretRef(ref, NewSettingsService().GetSection(constant.SectionSecretBackend))
+}
+
+// ClearSecretCache 清空解析缓存，后端配置变更后调用
+func ClearSecretCache() {
+	secretCacheMu.Lock()
+	secretCache = map[string]cachedSecret{}
+	secretCacheMu.Unlock()
+}
+
+func resolveSecretRef(ref SecretRef, cfg map[string]string) (string, error) {
+	provider := getSecretProvider(ref.Scheme)
+	if provider == nil {
+		return "", fmt.Errorf("未注册的机密后端: %s", ref.Scheme)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretResolveTimeout)
+	defer cancel()
+	value, err := provider.Resolve(ctx, ref, cfg)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", errors.New("解析结果为空")
+	}
+	return value, nil
+}
+
+// pickSecretField 从键值结果中取出指定字段，未指定字段且只有一个字段时直接使用
+func pickSecretField(data map[string]interface{}, field string) (string, error) {
+	if field == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("包含 %d 个字段，请用 #字段名 指定", len(data))
+		}
+		for k := range data {
+			field = k
+		}
+	}
+	v, ok := data[field]
+	if !ok || v == nil {
+		return "", fmt.Errorf("字段 %s 不存在", field)
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, _ := json.Marshal(v)
+	return string(b), nil
+}
+
+// parseDotEnv 解析 env 文件，忽略空行与注释，支持 export 前缀和引号
+func parseDotEnv(content string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		result[strings.TrimSpace(key)] = value
+	}
+	return result
+}
+
+// fileSecretProvider file:///run/secrets/token 读取整个文件；
+// file:///etc/app.env#TOKEN 按 env 文件（或 JSON 对象）取字段
+type fileSecretProvider struct{}
+
+func (fileSecretProvider) Scheme() string { return "file" }
+
+func (fileSecretProvider) Resolve(ctx context.Context, ref SecretRef, cfg map[string]string) (string, error) {
+	path := filepath.Clean(ref.Path)
+	if !filepath.IsAbs(path) {
+		return "", errors.New("请使用绝对路径，如 file:///run/secrets/token")
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	if !secretPathAllowed(path, cfg[constant.KeySecretFileRoots]) {
+		return "", fmt.Errorf("%s 不在允许读取的目录中", path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	text := string(content)
+	if ref.Field == "" {
+		return strings.TrimRight(text, "\r\n"), nil
+	}
+	var obj map[string]interface{}
+	if strings.HasPrefix(strings.TrimSpace(text), "{") && json.Unmarshal(content, &obj) == nil {
+		return pickSecretField(obj, ref.Field)
+	}
+	return pickSecretField(parseDotEnv(text), ref.Field)
+}
+
+// secretPathAllowed 路径是否位于允许的目录内
+func secretPathAllowed(path, roots string) bool {
+	for _, root := range strings.FieldsFunc(roots, func(r rune) bool { return r == '\n' || r == ',' }) {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		root = filepath.Clean(root)
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			root = real
+		}
+		if rel, err := filepath.Rel(root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// envSecretDenied 面板自身使用的环境变量，即使在允许列表中也不能通过 env:// 读取
+var envSecretDenied = []string{"BAIHU_*", "BH_*", "VAULT_TOKEN"}
+
+// envSecretProvider env://NAME 读取面板进程的环境变量，适合由容器编排注入的机密
+// 只能读取设置中允许的变量，面板自身的密钥等变量始终拒绝
+type envSecretProvider struct{}
+
+func (envSecretProvider) Scheme() string { return "env" }
+
+func (envSecretProvider) Resolve(ctx context.Context, ref SecretRef, cfg map[string]string) (string, error) {
+	name := ref.Path
+	if envNameMatches(name, strings.Join(envSecretDenied, "\n")) || !envNameMatches(name, cfg[constant.KeySecretEnvAllow]) {
+		return "", fmt.Errorf("环境变量 %s 不在允许读取的列表中", name)
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("面板进程中不存在环境变量 %s", ref.Path)
+	}
+	return value, nil
+}
+
+// envNameMatches 变量名是否匹配列表中的任一项，末尾 * 表示前缀匹配
+func envNameMatches(name, patterns string) bool {
+	if name == "" {
+		return false
+	}
+	for _, p := range strings.FieldsFunc(patterns, func(r rune) bool { return r == '\n' || r == ',' }) {
+		p = strings.TrimSpace(p)
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// vaultSecretProvider vault://kv/data/app#token 读取 HashiCorp Vault，兼容 KV v1 与 v2
+type vaultSecretProvider struct{}
+
+func (vaultSecretProvider) Scheme() string { return "vault" }
+
+func (vaultSecretProvider) Resolve(ctx context.Context, ref SecretRef, cfg map[string]string) (string, error) {
+	addr := strings.TrimSpace(cfg[constant.KeySecretVaultAddr])
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	token := strings.TrimSpace(cfg[constant.KeySecretVaultToken])
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if addr == "" || token == "" {
+		return "", errors.New("未配置 Vault 地址或 Token")
+	}
+
+	url := strings.TrimSuffix(addr, "/") + "/v1/" + strings.TrimPrefix(ref.Path, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := strings.TrimSpace(cfg[constant.KeySecretVaultNamespace]); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("请求 Vault 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var payload struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}
+	json.Unmarshal(body, &payload)
+	if resp.StatusCode != http.StatusOK {
+		if len(payload.Errors) > 0 {
+			return "", fmt.Errorf("Vault 返回 %d: %s", resp.StatusCode, strings.Join(payload.Errors, "; "))
+		}
+		return "", fmt.Errorf("Vault 返回 %d", resp.StatusCode)
+	}
+
+	// KV v2 的实际数据位于 data.data
+	data := payload.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = inner
+		}
+	}
+	return pickSecretField(data, ref.Field)
+}
+
+// execSecretProvider exec://aws-kms#<参数> 调用设置中登记的解密命令，取标准输出作为机密值
+// 参数通过 BAIHU_SECRET_ARG 环境变量传入，不拼接进命令行，可对接 KMS、sops、pass 等工具
+type execSecretProvider struct{}
+
+func (execSecretProvider) Scheme() string { return "exec" }
+
+func (execSecretProvider) Resolve(ctx context.Context, ref SecretRef, cfg map[string]string) (string, error) {
+	command := ""
+	for _, line := range strings.Split(cfg[constant.KeySecretExecHelpers], "\n") {
+		name, cmd, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(name) == ref.Path {
+			command = strings.TrimSpace(cmd)
+			break
+		}
+	}
+	if command == "" {
+		return "", fmt.Errorf("未登记名为 %s 的解密命令", ref.Path)
+	}
+
+	shell, args := utils.GetShellCommand(command)
+	cmd := exec.CommandContext(ctx, shell, args...)
+	cmd.Env = append(os.Environ(), "BAIHU_SECRET_ARG="+ref.Field)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		if msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
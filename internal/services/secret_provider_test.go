@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	ref, ok := ParseSecretRef(" vault://kv/data/app#token ")
+	if !ok || ref.Scheme != "vault" || ref.Path != "kv/data/app" || ref.Field != "token" {
+		t.Fatalf("unexpected ref: %+v, %v", ref, ok)
+	}
+	ref, ok = ParseSecretRef("FILE:///run/secrets/db")
+	if !ok || ref.Scheme != "file" || ref.Path != "/run/secrets/db" || ref.Field != "" {
+		t.Fatalf("unexpected ref: %+v, %v", ref, ok)
+	}
+	for _, plain := range []string{"hunter2", "https://example.com/token", "a b://c", "vault://x\ny"} {
+		if _, ok := ParseSecretRef(plain); ok {
+			t.Errorf("%q should be treated as a plain secret", plain)
+		}
+	}
+}
+
+func TestFileSecretProvider(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "token"), []byte("s3cr3t\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "app.env"), []byte("# comment\nexport API_KEY=\"abc\"\nOTHER=1\n"), 0600)
+	os.WriteFile(filepath.Join(dir, "app.json"), []byte(`{"password":"p@ss","port":5432}`), 0600)
+	cfg := map[string]string{constant.KeySecretFileRoots: dir}
+	p := fileSecretProvider{}
+
+	cases := map[string]string{
+		"file://" + filepath.Join(dir, "token"):                  "s3cr3t",
+		"file://" + filepath.Join(dir, "app.env") + "#API_KEY":   "abc",
+		"file://" + filepath.Join(dir, "app.json") + "#password": "p@ss",
+		"file://" + filepath.Join(dir, "app.json") + "#port":     "5432",
+	}
+	for raw, want := range cases {
+		ref, _ := ParseSecretRef(raw)
+		got, err := p.Resolve(context.Background(), ref, cfg)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", raw, got, err, want)
+		}
+	}
+
+	for _, raw := range []string{
+		"file://" + filepath.Join(dir, "app.env") + "#MISSING",
+		"file://" + filepath.Join(dir, "..", "outside"),
+		"file://relative/path",
+	} {
+		ref, _ := ParseSecretRef(raw)
+		if _, err := p.Resolve(context.Background(), ref, cfg); err == nil {
+			t.Errorf("%s should fail", raw)
+		}
+	}
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/kv/data/app":
+			w.Write([]byte(`{"data":{"data":{"token":"v2-token","user":"bot"},"metadata":{"version":3}}}`))
+		case "/v1/secret/app":
+			w.Write([]byte(`{"data":{"token":"v1-token"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	cfg := map[string]string{constant.KeySecretVaultAddr: srv.URL, constant.KeySecretVaultToken: "root"}
+	p := vaultSecretProvider{}
+	cases := map[string]string{
+		"vault://kv/data/app#token": "v2-token",
+		"vault://secret/app":        "v1-token",
+	}
+	for raw, want := range cases {
+		ref, _ := ParseSecretRef(raw)
+		got, err := p.Resolve(context.Background(), ref, cfg)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", raw, got, err, want)
+		}
+	}
+
+	ref, _ := ParseSecretRef("vault://kv/data/app")
+	if _, err := p.Resolve(context.Background(), ref, cfg); err == nil {
+		t.Error("ambiguous field should fail")
+	}
+	ref, _ = ParseSecretRef("vault://kv/data/missing#token")
+	if _, err := p.Resolve(context.Background(), ref, cfg); err == nil {
+		t.Error("missing path should fail")
+	}
+	cfg[constant.KeySecretVaultToken] = "wrong"
+	ref, _ = ParseSecretRef("vault://kv/data/app#token")
+	if _, err := p.Resolve(context.Background(), ref, cfg); err == nil {
+		t.Error("forbidden request should fail")
+	}
+}
+
+func TestEnvSecretProvider(t *testing.T) {
+	t.Setenv("APP_SECRET_DB", "db-pass")
+	t.Setenv("OTHER_TOKEN", "other")
+	t.Setenv("BAIHU_SECRET_KEY", "panel-key")
+	t.Setenv("BH_DB_PASSWORD", "panel-db")
+	p := envSecretProvider{}
+	resolve := func(name, allow string) (string, error) {
+		ref, _ := ParseSecretRef("env://" + name)
+		return p.Resolve(context.Background(), ref, map[string]string{constant.KeySecretEnvAllow: allow})
+	}
+
+	// 默认不允许读取任何变量
+	if _, err := resolve("APP_SECRET_DB", ""); err == nil {
+		t.Error("empty allowlist should refuse every variable")
+	}
+	if got, err := resolve("APP_SECRET_DB", "APP_SECRET_*"); err != nil || got != "db-pass" {
+		t.Errorf("prefix allow: got %q, %v", got, err)
+	}
+	if got, err := resolve("OTHER_TOKEN", "APP_SECRET_*\nOTHER_TOKEN"); err != nil || got != "other" {
+		t.Errorf("name allow: got %q, %v", got, err)
+	}
+	if _, err := resolve("OTHER_TOKEN", "OTHER"); err == nil {
+		t.Error("names without * must match exactly")
+	}
+	// 面板自身的变量始终拒绝
+	for _, name := range []string{"BAIHU_SECRET_KEY", "BH_DB_PASSWORD"} {
+		if _, err := resolve(name, "*\n"+name); err == nil {
+			t.Errorf("%s must never be readable", name)
+		}
+	}
+}
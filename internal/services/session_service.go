@@ -0,0 +1,194 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// sessionTouchInterval 最近活跃时间的写入间隔，避免每个请求都更新数据库
+const sessionTouchInterval = time.Minute
+
+// ErrSessionInvalid 会话不存在、已吊销或已过期
+var ErrSessionInvalid = errors.New("会话已失效，请重新登录")
+
+// SessionService 服务端登录会话
+type SessionService struct {
+	settingsService *SettingsService
+}
+
+func NewSessionService(settingsService *SettingsService) *SessionService {
+	return &SessionService{settingsService: settingsService}
+}
+
+// IdleTimeout 会话空闲超时时间，0 表示仅按登录有效期过期
+func (s *SessionService) IdleTimeout() time.Duration {
+	minutes := utils.ToInt(s.settingsService.Get(constant.SectionSite, constant.KeySessionIdleMinutes), 0)
+	if minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// Create 登录成功后创建会话
+func (s *SessionService) Create(userID, method, ip, userAgent string, expireDays int) (*models.UserSession, error) {
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	now := time.Now()
+	session := &models.UserSession{
+		ID:         utils.GenerateID(),
+		UserID:     userID,
+		Method:     method,
+		IP:         ip,
+		UserAgent:  userAgent,
+		Device:     DescribeUserAgent(userAgent),
+		LastSeenAt: models.LocalTime(now),
+		ExpiresAt:  models.LocalTime(now.Add(time.Duration(expireDays) * 24 * time.Hour)),
+	}
+	if err := database.DB.Create(session).Error; err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Validate 校验会话是否有效，并按间隔刷新最近活跃时间与 IP
+func (s *SessionService) Validate(sessionID, userID, ip string) error {
+	if sessionID == "" {
+		return ErrSessionInvalid
+	}
+	var session models.UserSession
+	res := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).Limit(1).Find(&session)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return ErrSessionInvalid
+	}
+
+	now := time.Now()
+	if now.After(session.ExpiresAt.Time()) {
+		database.DB.Delete(&session)
+		return ErrSessionInvalid
+	}
+	if idle := s.IdleTimeout(); idle > 0 && now.Sub(session.LastSeenAt.Time()) > idle {
+		database.DB.Delete(&session)
+		return errors.New("长时间未操作，请重新登录")
+	}
+
+	if now.Sub(session.LastSeenAt.Time()) >= sessionTouchInterval || session.IP != ip {
+		database.DB.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": models.LocalTime(now),
+			"ip":           ip,
+		})
+	}
+	return nil
+}
+
+// ListByUser 用户的全部有效会话，按最近活跃倒序
+func (s *SessionService) ListByUser(userID string) []models.UserSession {
+	var sessions []models.UserSession
+	database.DB.Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("last_seen_at DESC").Find(&sessions)
+	return sessions
+}
+
+// Revoke 吊销用户的指定会话
+func (s *SessionService) Revoke(userID, sessionID string) error {
+	res := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).Delete(&models.UserSession{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("会话不存在")
+	}
+	return nil
+}
+
+// RevokeAll 吊销用户的全部会话，exceptID 非空时保留该会话
+func (s *SessionService) RevokeAll(userID, exceptID string) int64 {
+	query := database.DB.Where("user_id = ?", userID)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	return query.Delete(&models.UserSession{}).RowsAffected
+}
+
+// CleanUp 清理已过期与空闲超时的会话
+func (s *SessionService) CleanUp() {
+	query := database.DB.Where("expires_at < ?", time.Now())
+	if idle := s.IdleTimeout(); idle > 0 {
+		query = query.Or("last_seen_at < ?", time.Now().Add(-idle))
+	}
+	res := query.Delete(&models.UserSession{})
+	if res.Error == nil && res.RowsAffected > 0 {
+		logger.Infof("[Session] 已清理 %d 个过期会话", res.RowsAffected)
+	}
+}
+
+// deleteUserSessions 删除用户全部会话，用于改密、禁用等使登录凭证整体失效的场景
+func deleteUserSessions(db *gorm.DB, userID string) error {
+	return db.Where("user_id = ?", userID).Delete(&models.UserSession{}).Error
+}
+
+var (
+	uaBrowserPatterns = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+)`)},
+		{"Opera", regexp.MustCompile(`OPR/(\d+)`)},
+		{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`)},
+		{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`)},
+		{"Safari", regexp.MustCompile(`Version/(\d+).*Safari/`)},
+		{"curl", regexp.MustCompile(`curl/(\d+)`)},
+	}
+	uaOSPatterns = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"iOS", regexp.MustCompile(`iPhone|iPad|iPod`)},
+		{"Android", regexp.MustCompile(`Android`)},
+		{"Windows", regexp.MustCompile(`Windows`)},
+		{"macOS", regexp.MustCompile(`Mac OS X|Macintosh`)},
+		{"Linux", regexp.MustCompile(`Linux|X11`)},
+	}
+)
+
+// DescribeUserAgent 将 User-Agent 归纳为「浏览器 版本 / 系统」的简短描述
+func DescribeUserAgent(ua string) string {
+	if ua == "" {
+		return "未知设备"
+	}
+	browser, system := "", ""
+	for _, p := range uaBrowserPatterns {
+		if m := p.re.FindStringSubmatch(ua); m != nil {
+			browser = p.name + " " + m[1]
+			break
+		}
+	}
+	for _, p := range uaOSPatterns {
+		if p.re.MatchString(ua) {
+			system = p.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " / " + system
+	case browser != "" || system != "":
+		return browser + system
+	}
+	if i := strings.IndexAny(ua, " /"); i > 0 {
+		return ua[:i]
+	}
+	if len(ua) > 50 {
+		return ua[:50]
+	}
+	return ua
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+)
+
+func TestDescribeUserAgent(t *testing.T) {
+	cases := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36":           "Chrome 126 / Windows",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15":     "Safari 17 / macOS",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/126.0 Mobile/15E148": "Chrome 126 / iOS",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0":                                                    "Firefox 127 / Linux",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0":     "Edge 126 / Windows",
+		"curl/8.5.0": "curl 8",
+		"":           "未知设备",
+	}
+	for ua, want := range cases {
+		if got := DescribeUserAgent(ua); got != want {
+			t.Errorf("DescribeUserAgent(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestSessionValidate(t *testing.T) {
+	setupTestDB(t)
+	settings := NewSettingsService()
+	s := NewSessionService(settings)
+
+	session, err := s.Create("u1", "password", "10.0.0.1", "curl/8.5.0", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(session.ID, "u1", "10.0.0.1"); err != nil {
+		t.Fatalf("fresh session rejected: %v", err)
+	}
+	if err := s.Validate(session.ID, "u2", "10.0.0.1"); err == nil {
+		t.Error("session must be bound to its user")
+	}
+
+	if err := s.Revoke("u1", session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(session.ID, "u1", "10.0.0.1"); err == nil {
+		t.Error("revoked session must be rejected")
+	}
+
+	// 空闲超过设定时长的会话失效并被删除
+	settings.Set(constant.SectionSite, constant.KeySessionIdleMinutes, "30")
+	t.Cleanup(func() { settings.Set(constant.SectionSite, constant.KeySessionIdleMinutes, "0") })
+	idle, _ := s.Create("u1", "password", "10.0.0.1", "", 7)
+	database.DB.Model(idle).Update("last_seen_at", models.LocalTime(time.Now().Add(-time.Hour)))
+	if err := s.Validate(idle.ID, "u1", "10.0.0.1"); err == nil {
+		t.Error("idle-expired session must be rejected")
+	}
+	var count int64
+	database.DB.Model(&models.UserSession{}).Where("id = ?", idle.ID).Count(&count)
+	if count != 0 {
+		t.Error("idle-expired session should be deleted")
+	}
+}
+
+func TestSessionCleanUp(t *testing.T) {
+	setupTestDB(t)
+	s := NewSessionService(NewSettingsService())
+
+	active, _ := s.Create("u1", "password", "10.0.0.1", "", 7)
+	expired, _ := s.Create("u1", "password", "10.0.0.1", "", 7)
+	database.DB.Model(expired).Update("expires_at", models.LocalTime(time.Now().Add(-time.Minute)))
+
+	s.CleanUp()
+	var ids []string
+	database.DB.Model(&models.UserSession{}).Pluck("id", &ids)
+	if len(ids) != 1 || ids[0] != active.ID {
+		t.Errorf("cleanup should keep only the active session, got %v", ids)
+	}
+}
@@ -2,7 +2,10 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/engigu/baihu-panel/internal/cache"
 	"github.com/engigu/baihu-panel/internal/constant"
@@ -79,6 +82,7 @@ func (s *SettingsService) InitSettings() error {
 		constant.KeyLoginLogMaxCount:     "1000",
 		constant.KeySchedulerLogDays:     "30",
 		constant.KeySchedulerLogMaxCount: "10000",
+		constant.KeyAuditLogDays:         "180",
 	}
 
 	for k, v := range defaultRetention {
@@ -144,6 +148,23 @@ func (s *SettingsService) Get(section, key string) string {
 	return string(setting.Value)
 }
 
+// PublicURL 站点设置中的面板对外地址（去掉末尾斜杠），未配置时返回空字符串
+func (s *SettingsService) PublicURL() string {
+	return strings.TrimSuffix(strings.TrimSpace(s.Get(constant.SectionSite, constant.KeyPublicURL)), "/")
+}
+
+// ValidatePublicURL 校验面板对外地址，需为带主机名的 http(s) 地址，允许包含 URL 前缀
+func ValidatePublicURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return errors.New("面板对外地址格式错误，应形如 https://panel.example.com")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("面板对外地址不能包含查询参数")
+	}
+	return nil
+}
+
 // Set 设置单个值
 func (s *SettingsService) Set(section, key, value string) error {
 	var setting models.Setting
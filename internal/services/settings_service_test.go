@@ -0,0 +1,17 @@
+package services
+
+import "testing"
+
+func TestValidatePublicURL(t *testing.T) {
+	for raw, ok := range map[string]bool{
+		"https://panel.example.com":         true,
+		"http://192.168.1.10:8052/baihu":    true,
+		"panel.example.com":                 false,
+		"ftp://panel.example.com":           false,
+		"https://panel.example.com/?next=/": false,
+	} {
+		if err := ValidatePublicURL(raw); (err == nil) != ok {
+			t.Errorf("ValidatePublicURL(%q) = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
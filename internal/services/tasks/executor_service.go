@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -39,8 +40,9 @@ type SettingsService interface {
 type EnvService interface {
 	GetEnvVarsByIDs(ids string) []string
 	GetAllEnvVars() []string
-	GetEnvVarsAndSecretsByIDs(ids string) ([]string, []string)
-	GetAllEnvVarsAndSecrets() ([]string, []string)
+	GetEnvVarsAndSecretsByIDs(ids string, resolveRefs bool) ([]string, []string, error)
+	GetAllEnvVarsAndSecrets(resolveRefs bool) ([]string, []string, error)
+	RecordEnvUsage(task *models.Task)
 }
 
 type ExecutorService struct {
@@ -158,8 +160,9 @@ func (h *ServerSchedulerHandler) OnTaskExecuting(req *executor.ExecutionRequest)
 		return nil, nil, nil
 	}
 
-	// 1. 使用预先准备好的脱敏指令创建初始日志记录
-	taskLog, err := h.es.taskLogService.CreateEmptyLog(task.ID, req.MaskedCommand)
+	// 1. 使用预先准备好的脱敏指令创建初始日志记录，同时记录本次运行的环境快照
+	runEnv := buildRunEnv(req, task.AgentID == nil || *task.AgentID == "")
+	taskLog, err := h.es.taskLogService.CreateEmptyLog(req.Metadata.ExecutionID, task.ID, req.MaskedCommand, runEnv)
 	if err != nil {
 		return nil, nil, fmt.Errorf("创建初始日志失败: %v", err)
 	}
@@ -204,11 +207,15 @@ func (h *ServerSchedulerHandler) OnTaskExecuting(req *executor.ExecutionRequest)
 	})
 
 	if req.Metadata.RetryIndex > 0 {
-		tl.Write([]byte(fmt.Sprintf("\n[System] 此为任务失败后的第 %d 次重试执行...\n\n", req.Metadata.RetryIndex)))
+		tl.WriteSystem(fmt.Sprintf("\n[System] 此为任务失败后的第 %d 次重试执行...\n\n", req.Metadata.RetryIndex))
 	}
 
 	// 对于本地任务，Scheduler 会通过返回的 Writer 写入日志
 	// 对于远程任务，Scheduler 不会写入任何内容（由 Agent 推送至此 TL）
+	// 开启分离标准错误输出后返回不同的写入端，执行器会改用管道模式以区分 stdout 与 stderr
+	if h.es.settingsService.Get(constant.SectionScheduler, constant.KeySeparateStderr) == "true" {
+		return tl, tl.Stderr(), nil
+	}
 	return tl, tl, nil
 }
 
@@ -242,7 +249,7 @@ func (h *ServerSchedulerHandler) OnTaskCompleted(req *executor.ExecutionRequest,
 
 	// 无论本地还是远程，都在此处处理日志压缩和落库
 	tl := GetActiveLog(req.LogID)
-	var output string
+	var output, lineMeta string
 	if tl != nil {
 		// 压缩并清理实时日志
 		var err error
@@ -250,6 +257,8 @@ func (h *ServerSchedulerHandler) OnTaskCompleted(req *executor.ExecutionRequest,
 		if err != nil {
 			logger.Errorf("[Executor] 压缩任务 #%s 日志失败: %v", task.ID, err)
 			output = "[System Error] 日志处理失败: " + err.Error()
+		} else {
+			lineMeta = tl.LineMeta()
 		}
 	} else {
 		// 如果 TinyLog 已经丢失，尝试从 result.Output 中恢复一次（主要针对本地任务）
@@ -265,6 +274,7 @@ func (h *ServerSchedulerHandler) OnTaskCompleted(req *executor.ExecutionRequest,
 		TaskID:    task.ID,
 		Command:   models.BigText(req.MaskedCommand),
 		Output:    models.BigText(output),
+		LineMeta:  models.BigText(lineMeta),
 		Error:     models.BigText(result.Error),
 		Status:    result.Status,
 		Duration:  result.Duration,
@@ -339,10 +349,11 @@ func (h *ServerSchedulerHandler) OnTaskFailed(req *executor.ExecutionRequest, er
 
 	// 构造错误日志
 	tl := GetActiveLog(req.LogID)
-	var output string
+	var output, lineMeta string
 	if tl != nil {
-		tl.Write([]byte(fmt.Sprintf("\n[System Error] %v", err)))
+		tl.WriteSystem(fmt.Sprintf("\n[System Error] %v", err))
 		output, _ = tl.CompressAndCleanup()
+		lineMeta = tl.LineMeta()
 	} else {
 		output, _ = utils.CompressToBase64(fmt.Sprintf("任务执行失败: %v", err))
 	}
@@ -353,6 +364,7 @@ func (h *ServerSchedulerHandler) OnTaskFailed(req *executor.ExecutionRequest, er
 		TaskID:    taskID,
 		Command:   models.BigText(req.MaskedCommand),
 		Output:    models.BigText(output),
+		LineMeta:  models.BigText(lineMeta),
 		Error:     models.BigText(err.Error()),
 		Status:    constant.TaskStatusFailed,
 		Duration:  0,
@@ -403,6 +415,40 @@ func (h *ServerSchedulerHandler) OnTaskFailed(req *executor.ExecutionRequest, er
 	}()
 }
 
+// buildRunEnv 生成运行环境快照：变量名、语言版本与工作目录
+// resolveVersions 为 true 时解析本机 mise 中 latest 等版本别名对应的实际版本，Agent 任务在远端执行，不做解析
+func buildRunEnv(req *executor.ExecutionRequest, resolveVersions bool) string {
+	env := models.RunEnv{WorkDir: req.WorkDir, EnvNames: []string{}, Languages: []models.RunLanguage{}}
+	seen := make(map[string]bool)
+	for _, kv := range req.Envs {
+		name, _, _ := strings.Cut(kv, "=")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			env.EnvNames = append(env.EnvNames, name)
+		}
+	}
+	sort.Strings(env.EnvNames)
+	if req.UseMise {
+		for _, lang := range req.Languages {
+			if lang["name"] == "" {
+				continue
+			}
+			l := models.RunLanguage{Name: lang["name"], Version: lang["version"]}
+			if l.Version == "" {
+				l.Version = "latest"
+			}
+			if resolveVersions {
+				if resolved := utils.ResolveMiseVersion(l.Name, l.Version); resolved != l.Version {
+					l.Resolved = resolved
+				}
+			}
+			env.Languages = append(env.Languages, l)
+		}
+	}
+	data, _ := json.Marshal(env)
+	return string(data)
+}
+
 // HandleTaskRetry 处理任务失败重试逻辑
 func (es *ExecutorService) HandleTaskRetry(task *models.Task, req *executor.ExecutionRequest, isSuccess bool, status string, exitCode int) {
 	if task == nil {
@@ -480,8 +526,15 @@ func (es *ExecutorService) ExecuteDispatcher(ctx context.Context, req *executor.
 		}, stdout, stderr)
 	}
 
-	// 重新加载最新的环境变量，满足即时生效的需求
-	es.refreshExecutionRequestEnvs(req, task)
+	// 重新加载最新的环境变量并解析外部机密，满足即时生效的需求；解析失败时本次执行失败
+	if err := es.refreshExecutionRequestEnvs(req, task); err != nil {
+		logger.Errorf("[Executor] 任务 #%s 外部机密解析失败: %v", taskID, err)
+		return nil, fmt.Errorf("外部机密解析失败: %w", err)
+	}
+	// 实时日志在解析前已创建，按解析后的机密更新脱敏内容
+	if tl := GetActiveLog(req.LogID); tl != nil {
+		tl.SetMasks(req.Secrets)
+	}
 
 	// 在控制台打印最终执行的脱敏命令
 	logger.Infof("[Executor] 任务最终执行命令: %s", req.MaskedCommand)
@@ -548,7 +601,7 @@ func (es *ExecutorService) AddCronTask(task *models.Task) error {
 		return nil
 	}
 	// 在加入调度器前，预先加载好环境信息
-	task.RuntimeEnvs, task.RuntimeSecrets = es.loadEnvVars(task.ID, string(task.Envs))
+	task.RuntimeEnvs, task.RuntimeSecrets, _ = es.loadEnvVars(task.ID, string(task.Envs), false)
 
 	return es.cronManager.AddTask(task)
 }
@@ -635,7 +688,7 @@ func (es *ExecutorService) CreateExecutionRequest(task *models.Task, triggerType
 	}
 
 	// 1. 加载环境变量和机密
-	envs, secrets := es.loadEnvVars(task.ID, string(task.Envs))
+	envs, secrets, _ := es.loadEnvVars(task.ID, string(task.Envs), false)
 	if len(extraEnvs) > 0 {
 		envs = append(envs, extraEnvs...)
 	}
@@ -720,10 +773,13 @@ func (es *ExecutorService) ExecuteTask(taskID string, extraEnvs []string) *execu
 	}
 
 	req := es.CreateExecutionRequest(task, executor.TaskTypeManual, extraEnvs)
+	// 预先分配日志 ID，调用方可据此追踪本次执行
+	req.Metadata.ExecutionID = utils.GenerateID()
 	es.scheduler.EnqueueOrExecute(req)
 
 	return &executor.ExecutionResult{
 		TaskID:    task.ID,
+		LogID:     req.Metadata.ExecutionID,
 		Success:   true,
 		Status:    constant.TaskStatusQueued,
 		StartTime: time.Now(),
@@ -1150,11 +1206,15 @@ func (es *ExecutorService) ExecuteRemoteForScheduler(ctx context.Context, task *
 
 // HandleAgentResult 处理来自 Agent 的异步结果
 func (es *ExecutorService) HandleAgentResult(result *models.AgentTaskResult) error {
-	// 加载机密以进行脱敏处理
+	// 加载机密（含外部机密引用的解析结果）以进行脱敏处理
 	var secrets []string
 	task := es.taskService.GetTaskByID(result.TaskID)
 	if task != nil {
-		_, secrets = es.loadEnvVars(task.ID, string(task.Envs))
+		var err error
+		_, secrets, err = es.loadEnvVars(task.ID, string(task.Envs), true)
+		if err != nil {
+			logger.Warnf("[Executor] 任务 #%s 外部机密解析失败，Agent 结果中的对应值无法脱敏: %v", task.ID, err)
+		}
 
 		// 如果是仓库同步任务，补充 AuthToken
 		if task.Type == constant.TaskTypeRepo {
@@ -1168,6 +1228,8 @@ func (es *ExecutorService) HandleAgentResult(result *models.AgentTaskResult) err
 	masks := append([]string{}, secrets...)
 	masks = append(masks, utils.GetSystemSecrets()...)
 	result.Command = utils.MaskSecrets(result.Command, masks)
+	result.Output = utils.MaskSecrets(result.Output, masks)
+	result.Error = utils.MaskSecrets(result.Error, masks)
 
 	taskLog, err := es.taskLogService.CreateTaskLogFromAgentResult(result)
 	if err != nil {
@@ -1297,7 +1359,8 @@ func BuildRepoCommand(task *models.Task) (string, string) {
 }
 
 // loadEnvVars 加载环境变量和掩码信息，支持全局注入及重名合并
-func (es *ExecutorService) loadEnvVars(taskID string, envIDs string) ([]string, []string) {
+// resolveRefs 为 true 时解析外部机密引用，仅应在即将执行时使用
+func (es *ExecutorService) loadEnvVars(taskID string, envIDs string, resolveRefs bool) ([]string, []string, error) {
 	// 1. 检查是否开启了注入全部环境变量
 	if taskID != "" && es.taskService != nil {
 		task := es.taskService.GetTaskByID(taskID)
@@ -1306,7 +1369,7 @@ func (es *ExecutorService) loadEnvVars(taskID string, envIDs string) ([]string,
 			if err := json.Unmarshal([]byte(task.Config), &config); err == nil {
 				if config.AllEnvs {
 					if es.envService != nil {
-						return es.envService.GetAllEnvVarsAndSecrets()
+						return es.envService.GetAllEnvVarsAndSecrets(resolveRefs)
 					}
 				}
 			}
@@ -1315,29 +1378,35 @@ func (es *ExecutorService) loadEnvVars(taskID string, envIDs string) ([]string,
 
 	// 2. 否则按 ID 列表进行加载（支持合并逻辑在 envService 中处理）
 	if envIDs == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	if es.envService != nil {
-		return es.envService.GetEnvVarsAndSecretsByIDs(envIDs)
+		return es.envService.GetEnvVarsAndSecretsByIDs(envIDs, resolveRefs)
 	}
 
-	return nil, nil
+	return nil, nil, nil
 }
 
 // refreshExecutionRequestEnvs 重新加载最新的环境变量，并与原请求中的变量合并（保留额外变量）
-func (es *ExecutorService) refreshExecutionRequestEnvs(req *executor.ExecutionRequest, task *models.Task) {
+func (es *ExecutorService) refreshExecutionRequestEnvs(req *executor.ExecutionRequest, task *models.Task) error {
 	if task == nil || (req.Type != executor.TaskTypeCron && req.Type != executor.TaskTypeManual) {
-		return
+		return nil
 	}
 
 	// 1. 备份原请求中的环境变量（用于后续保留手动指定的额外变量）
 	currentEnvs := req.Envs
 
-	// 2. 从数据库加载最新的环境变量设置
-	envs, secrets := es.loadEnvVars(task.ID, string(task.Envs))
+	// 2. 从数据库加载最新的环境变量设置，并解析外部机密引用
+	envs, secrets, err := es.loadEnvVars(task.ID, string(task.Envs), true)
+	if err != nil {
+		return err
+	}
 	req.Envs = envs
 	req.Secrets = secrets
+	if es.envService != nil {
+		es.envService.RecordEnvUsage(task)
+	}
 
 	// 3. 将原请求中存在但数据库中不存在的“额外变量”合并回来（如 API 注入、手动执行参数等）
 	for _, ce := range currentEnvs {
@@ -1357,6 +1426,7 @@ func (es *ExecutorService) refreshExecutionRequestEnvs(req *executor.ExecutionRe
 			req.Envs = append(req.Envs, ce)
 		}
 	}
+	return nil
 }
 
 func (es *ExecutorService) ResolvePath(path string) string {
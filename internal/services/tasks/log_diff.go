@@ -0,0 +1,232 @@
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+const (
+	logDiffMaxLines    = 20000 // 每侧参与比对的最大行数，超出时只比对末尾
+	logDiffMaxEdits    = 4000  // 编辑距离上限，超出时不再细分差异
+	logDiffMaxPatterns = 20
+	logDiffContext     = 3
+	logDiffMaxContext  = 50
+)
+
+// ErrLogDiffPattern 归一化规则无效
+var ErrLogDiffPattern = errors.New("无效的归一化规则")
+
+// DefaultLogDiffIgnore 默认的归一化规则：比对前从每行删除日期时间、时刻与耗时
+var DefaultLogDiffIgnore = []string{
+	`\d{4}[-/]\d{1,2}[-/]\d{1,2}[T ]\d{1,2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`,
+	`\b\d{1,2}:\d{2}:\d{2}(\.\d+)?\b`,
+	`\b\d+(\.\d+)?\s?(ms|s|毫秒|秒)\b`,
+}
+
+// LogDiffOptions 日志比对参数
+type LogDiffOptions struct {
+	Ignore   []string // 比对前从每行删除的正则，为 nil 时使用 DefaultLogDiffIgnore
+	KeepANSI bool     // 是否保留 ANSI 颜色码参与比对
+	Context  int      // 差异块的上下文行数
+}
+
+// LogRunInfo 参与比对的一次运行
+type LogRunInfo struct {
+	ID        string            `json:"id"`
+	Status    string            `json:"status"`
+	ExitCode  int               `json:"exit_code"`
+	Duration  int64             `json:"duration"`
+	StartTime *models.LocalTime `json:"start_time"`
+	AgentID   *string           `json:"agent_id"`
+	Command   string            `json:"command"`
+	Env       *models.RunEnv    `json:"env"` // 升级前的日志或 Agent 自行触发的运行没有环境快照
+	Lines     int               `json:"lines"`
+	Truncated bool              `json:"truncated"` // 是否只比对了末尾部分
+}
+
+// LanguageChange 两次运行的语言版本变化，不存在时为空字符串
+type LanguageChange struct {
+	Name   string `json:"name"`
+	Base   string `json:"base"`
+	Target string `json:"target"`
+}
+
+// LogDiffResult 两次运行的比对结果
+type LogDiffResult struct {
+	Base       LogRunInfo       `json:"base"`
+	Target     LogRunInfo       `json:"target"`
+	Ignore     []string         `json:"ignore"`
+	Hunks      []utils.DiffHunk `json:"hunks"`
+	Added      int              `json:"added"`
+	Removed    int              `json:"removed"`
+	Complete   bool             `json:"complete"` // 为 false 表示差异过大，部分内容按整体替换展示
+	Command    []utils.DiffLine `json:"command"`  // 命令的逐行差异
+	EnvAdded   []string         `json:"env_added"`
+	EnvRemoved []string         `json:"env_removed"`
+	Languages  []LanguageChange `json:"languages"`
+}
+
+// DiffTaskLogs 比对同一任务的两次运行：输出按行归一化后求差异，并比对命令、环境变量名与语言版本
+func DiffTaskLogs(base, target *models.TaskLog, opts LogDiffOptions) (*LogDiffResult, error) {
+	if base.TaskID != target.TaskID {
+		return nil, fmt.Errorf("只能比对同一任务的两次运行")
+	}
+	ignore := opts.Ignore
+	if ignore == nil {
+		ignore = DefaultLogDiffIgnore
+	}
+	if len(ignore) > logDiffMaxPatterns {
+		return nil, fmt.Errorf("%w: 最多 %d 条", ErrLogDiffPattern, logDiffMaxPatterns)
+	}
+	patterns := make([]*regexp.Regexp, 0, len(ignore))
+	for _, expr := range ignore {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrLogDiffPattern, expr, err)
+		}
+		patterns = append(patterns, re)
+	}
+	context := opts.Context
+	if context <= 0 {
+		context = logDiffContext
+	}
+	context = min(context, logDiffMaxContext)
+
+	result := &LogDiffResult{Ignore: ignore, Languages: []LanguageChange{}}
+	baseLines, err := loadDiffLines(base, &result.Base)
+	if err != nil {
+		return nil, err
+	}
+	targetLines, err := loadDiffLines(target, &result.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	normalize := func(lines []string) []string {
+		out := make([]string, len(lines))
+		for i, line := range lines {
+			if !opts.KeepANSI {
+				line = utils.StripAnsi(line)
+			}
+			for _, re := range patterns {
+				line = re.ReplaceAllString(line, "")
+			}
+			out[i] = strings.TrimSpace(line)
+		}
+		return out
+	}
+	diff, complete := utils.DiffLines(normalize(baseLines), normalize(targetLines), logDiffMaxEdits)
+	// 比对使用归一化后的内容，展示时还原为原文
+	for i, l := range diff {
+		if l.Op == utils.DiffInsert {
+			diff[i].Text = targetLines[l.B-1]
+		} else {
+			diff[i].Text = baseLines[l.A-1]
+		}
+		switch l.Op {
+		case utils.DiffInsert:
+			result.Added++
+		case utils.DiffDelete:
+			result.Removed++
+		}
+	}
+	result.Complete = complete
+	result.Hunks = utils.DiffHunks(diff, context)
+	if result.Hunks == nil {
+		result.Hunks = []utils.DiffHunk{}
+	}
+
+	result.Command, _ = utils.DiffLines(strings.Split(string(base.Command), "\n"), strings.Split(string(target.Command), "\n"), 0)
+	diffRunEnv(result)
+	return result, nil
+}
+
+func loadDiffLines(log *models.TaskLog, info *LogRunInfo) ([]string, error) {
+	*info = LogRunInfo{
+		ID:        log.ID,
+		Status:    log.Status,
+		ExitCode:  log.ExitCode,
+		Duration:  log.Duration,
+		StartTime: log.StartTime,
+		AgentID:   log.AgentID,
+		Command:   string(log.Command),
+	}
+	if log.RunEnv != "" {
+		var env models.RunEnv
+		if json.Unmarshal([]byte(log.RunEnv), &env) == nil {
+			info.Env = &env
+		}
+	}
+
+	output, err := logstore.ReadOutput(log)
+	if err != nil {
+		return nil, fmt.Errorf("读取日志 #%s 失败: %v", log.ID, err)
+	}
+	output = strings.TrimSuffix(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	var lines []string
+	if output != "" {
+		lines = strings.Split(output, "\n")
+	}
+	if len(lines) > logDiffMaxLines {
+		lines = lines[len(lines)-logDiffMaxLines:]
+		info.Truncated = true
+	}
+	info.Lines = len(lines)
+	return lines, nil
+}
+
+// diffRunEnv 比对两次运行的环境变量名与语言版本，任一侧没有快照时跳过
+func diffRunEnv(result *LogDiffResult) {
+	result.EnvAdded, result.EnvRemoved = []string{}, []string{}
+	base, target := result.Base.Env, result.Target.Env
+	if base == nil || target == nil {
+		return
+	}
+
+	inBase := make(map[string]bool, len(base.EnvNames))
+	for _, name := range base.EnvNames {
+		inBase[name] = true
+	}
+	inTarget := make(map[string]bool, len(target.EnvNames))
+	for _, name := range target.EnvNames {
+		inTarget[name] = true
+		if !inBase[name] {
+			result.EnvAdded = append(result.EnvAdded, name)
+		}
+	}
+	for _, name := range base.EnvNames {
+		if !inTarget[name] {
+			result.EnvRemoved = append(result.EnvRemoved, name)
+		}
+	}
+
+	version := func(l models.RunLanguage) string {
+		if l.Resolved != "" {
+			return l.Version + " (" + l.Resolved + ")"
+		}
+		return l.Version
+	}
+	baseLangs := make(map[string]string)
+	for _, l := range base.Languages {
+		baseLangs[l.Name] = version(l)
+	}
+	seen := make(map[string]bool)
+	for _, l := range target.Languages {
+		seen[l.Name] = true
+		if v := version(l); baseLangs[l.Name] != v {
+			result.Languages = append(result.Languages, LanguageChange{Name: l.Name, Base: baseLangs[l.Name], Target: v})
+		}
+	}
+	for _, l := range base.Languages {
+		if !seen[l.Name] {
+			result.Languages = append(result.Languages, LanguageChange{Name: l.Name, Base: version(l)})
+		}
+	}
+}
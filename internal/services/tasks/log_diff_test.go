@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+func diffTestLog(t *testing.T, id, output, runEnv string) *models.TaskLog {
+	compressed, err := utils.CompressToBase64(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.TaskLog{ID: id, TaskID: "task", Command: "python main.py", Output: models.BigText(compressed), RunEnv: models.BigText(runEnv)}
+}
+
+func TestDiffTaskLogs_IgnoresTimestamps(t *testing.T) {
+	base := diffTestLog(t, "a", "2026-01-01 08:00:00 start\n\x1b[32mok\x1b[0m\n[08:00:01] fetched 3 items\ndone in 120ms\n", "")
+	target := diffTestLog(t, "b", "2026-01-02 09:30:12 start\nok\n[09:30:15] fetched 2 items\ndone in 3.5s\n", "")
+
+	result, err := DiffTaskLogs(base, target, LogDiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 1 || result.Removed != 1 || len(result.Hunks) != 1 {
+		t.Fatalf("expected one changed line, got +%d -%d in %d hunks", result.Added, result.Removed, len(result.Hunks))
+	}
+	for _, l := range result.Hunks[0].Lines {
+		switch l.Op {
+		case utils.DiffDelete:
+			if l.Text != "[08:00:01] fetched 3 items" {
+				t.Errorf("deleted line should keep original text, got %q", l.Text)
+			}
+		case utils.DiffInsert:
+			if l.Text != "[09:30:15] fetched 2 items" {
+				t.Errorf("inserted line should keep original text, got %q", l.Text)
+			}
+		}
+	}
+
+	result, err = DiffTaskLogs(base, target, LogDiffOptions{Ignore: []string{}, KeepANSI: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 4 || result.Removed != 4 {
+		t.Errorf("without normalization every line should differ, got +%d -%d", result.Added, result.Removed)
+	}
+
+	if _, err = DiffTaskLogs(base, target, LogDiffOptions{Ignore: []string{"("}}); err == nil {
+		t.Error("invalid pattern should be rejected")
+	}
+}
+
+func TestDiffTaskLogs_RunEnv(t *testing.T) {
+	base := diffTestLog(t, "a", "ok\n", `{"env_names":["A","B"],"languages":[{"name":"python","version":"3.11","resolved":"3.11.4"},{"name":"node","version":"20"}]}`)
+	target := diffTestLog(t, "b", "ok\n", `{"env_names":["B","C"],"languages":[{"name":"python","version":"3.11","resolved":"3.11.9"}]}`)
+
+	result, err := DiffTaskLogs(base, target, LogDiffOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hunks) != 0 {
+		t.Errorf("identical output should have no hunks, got %d", len(result.Hunks))
+	}
+	if len(result.EnvAdded) != 1 || result.EnvAdded[0] != "C" || len(result.EnvRemoved) != 1 || result.EnvRemoved[0] != "A" {
+		t.Errorf("unexpected env diff: +%v -%v", result.EnvAdded, result.EnvRemoved)
+	}
+	if len(result.Languages) != 2 || result.Languages[0].Target != "3.11 (3.11.9)" || result.Languages[1].Name != "node" || result.Languages[1].Target != "" {
+		t.Errorf("unexpected language diff: %+v", result.Languages)
+	}
+}
@@ -0,0 +1,403 @@
+package tasks
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
+	"github.com/engigu/baihu-panel/internal/models"
+	"github.com/engigu/baihu-panel/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	logTermMinLen      = 2    // 字母数字词的最小长度，更短的词不入索引
+	logTermMaxLen      = 64   // 超长的词（如 base64 内容）不入索引
+	logTermsPerLog     = 5000 // 单条日志最多索引的词数
+	logSearchBatch     = 50   // 每批校验的候选日志数
+	logSearchScanLimit = 500  // 单次请求最多校验的候选日志数，超过后返回游标由调用方继续
+	logSnippetsPerHit  = 3
+	logSnippetWidth    = 160 // 摘要行的最大字符数
+)
+
+// ErrLogSearchKeyword 关键词中没有可检索的词
+var ErrLogSearchKeyword = errors.New("关键词过短，需包含至少 2 个字母或数字组成的词，或 2 个连续汉字")
+
+// isCJK 中日韩文字没有空格分词，按相邻两字切分
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// tokenizeLog 将文本切分为去重后的索引词：字母数字下划线组成的词转为小写，中日韩文字按二元组切分
+func tokenizeLog(text string, limit int) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) bool {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+		return limit <= 0 || len(terms) < limit
+	}
+
+	var word []rune
+	var prevCJK rune
+	flushWord := func() bool {
+		ok := true
+		if len(word) >= logTermMinLen && len(word) <= logTermMaxLen {
+			ok = add(string(word))
+		}
+		word = word[:0]
+		return ok
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if !flushWord() {
+				return terms
+			}
+			if prevCJK != 0 && !add(string([]rune{prevCJK, r})) {
+				return terms
+			}
+			prevCJK = r
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			prevCJK = 0
+			word = append(word, unicode.ToLower(r))
+		default:
+			prevCJK = 0
+			if !flushWord() {
+				return terms
+			}
+		}
+	}
+	flushWord()
+	return terms
+}
+
+// LogSearchService 任务日志全文检索
+// 日志输出压缩存储无法直接查询，任务完成时将输出切词写入倒排表，检索时先按词求交集得到候选日志，
+// 再解压候选日志逐行匹配原始关键词并生成摘要
+type LogSearchService struct{}
+
+// NewLogSearchService 创建日志检索服务
+func NewLogSearchService() *LogSearchService {
+	return &LogSearchService{}
+}
+
+var logReindexing atomic.Bool
+
+// IndexLog 为已完成的任务日志建立索引，重复调用会先清除旧索引
+func (s *LogSearchService) IndexLog(taskLog *models.TaskLog) error {
+	if taskLog == nil || taskLog.ID == "" || taskLog.Status == constant.TaskStatusRunning {
+		return nil
+	}
+	output, err := logstore.ReadOutput(taskLog)
+	if err != nil {
+		return err
+	}
+	terms := tokenizeLog(utils.StripAnsi(output), logTermsPerLog)
+
+	rows := make([]models.TaskLogTerm, len(terms))
+	for i, term := range terms {
+		rows[i] = models.TaskLogTerm{Term: term, LogID: taskLog.ID, TaskID: taskLog.TaskID, CreatedAt: taskLog.CreatedAt}
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("log_id = ?", taskLog.ID).Delete(&models.TaskLogTerm{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 200).Error
+	})
+}
+
+// DeleteIndex 删除指定日志的索引
+func (s *LogSearchService) DeleteIndex(logIDs ...string) {
+	if len(logIDs) > 0 {
+		database.DB.Where("log_id IN ?", logIDs).Delete(&models.TaskLogTerm{})
+	}
+}
+
+// CleanIndex 清理日志已被删除的索引，taskID 为空时清理全部任务
+func (s *LogSearchService) CleanIndex(taskID string) int64 {
+	logs := database.DB.Model(&models.TaskLog{}).Select("id")
+	query := database.DB.Where("log_id NOT IN (?)", logs)
+	if taskID != "" {
+		query = database.DB.Where("task_id = ? AND log_id NOT IN (?)", taskID, logs.Where("task_id = ?", taskID))
+	}
+	res := query.Delete(&models.TaskLogTerm{})
+	if res.Error != nil {
+		logger.Warnf("[LogSearch] 清理日志索引失败: %v", res.Error)
+	}
+	return res.RowsAffected
+}
+
+// Reindex 在后台为全部已完成的日志重建索引，用于升级后补建历史日志的索引
+func (s *LogSearchService) Reindex() bool {
+	if !logReindexing.CompareAndSwap(false, true) {
+		return false
+	}
+	go func() {
+		defer logReindexing.Store(false)
+		var indexed, failed int
+		lastID := ""
+		for {
+			var logs []models.TaskLog
+			database.DB.Where("id > ? AND status <> ?", lastID, constant.TaskStatusRunning).
+				Order("id ASC").Limit(100).Find(&logs)
+			if len(logs) == 0 {
+				break
+			}
+			for i := range logs {
+				if err := s.IndexLog(&logs[i]); err != nil {
+					failed++
+					continue
+				}
+				indexed++
+			}
+			lastID = logs[len(logs)-1].ID
+		}
+		s.CleanIndex("")
+		logger.Infof("[LogSearch] 日志索引重建完成: 成功 %d 条，失败 %d 条", indexed, failed)
+	}()
+	return true
+}
+
+// Reindexing 是否正在重建索引
+func (s *LogSearchService) Reindexing() bool {
+	return logReindexing.Load()
+}
+
+// LogSearchQuery 日志检索条件
+type LogSearchQuery struct {
+	Keyword string
+	TaskID  string
+	TaskIDs []string // 受标签范围限制的账户可访问的任务，nil 表示不限制
+	Status  string
+	Since   *time.Time
+	Until   *time.Time
+	Before  string // 游标：只检索 ID 小于该值的日志（更早的日志）
+	Limit   int
+}
+
+// LogSnippet 命中行摘要
+type LogSnippet struct {
+	Line      int    `json:"line"`      // 行号，从 1 开始
+	Text      string `json:"text"`      // 截取后的行内容
+	Highlight string `json:"highlight"` // 已转义的 HTML，命中部分以 <mark> 包裹
+}
+
+// LogSearchHit 命中的日志
+type LogSearchHit struct {
+	LogID     string            `json:"log_id"`
+	TaskID    string            `json:"task_id"`
+	TaskName  string            `json:"task_name"`
+	Status    string            `json:"status"`
+	CreatedAt models.LocalTime  `json:"created_at"`
+	Matches   int               `json:"matches"` // 命中的行数
+	Snippets  []LogSnippet      `json:"snippets"`
+	StartTime *models.LocalTime `json:"start_time"`
+}
+
+// LogSearchResult 检索结果，NextCursor 非空时可作为 Before 继续检索更早的日志
+type LogSearchResult struct {
+	Items      []LogSearchHit `json:"items"`
+	NextCursor string         `json:"next_cursor"`
+	Scanned    int            `json:"scanned"`
+}
+
+// Search 检索日志输出，结果按日志时间倒序
+func (s *LogSearchService) Search(q LogSearchQuery) (*LogSearchResult, error) {
+	keyword := strings.TrimSpace(q.Keyword)
+	terms := tokenizeLog(keyword, 0)
+	if len(terms) == 0 {
+		return nil, ErrLogSearchKeyword
+	}
+	if q.Limit <= 0 || q.Limit > 100 {
+		q.Limit = 20
+	}
+	if q.TaskIDs != nil && len(q.TaskIDs) == 0 {
+		return &LogSearchResult{Items: []LogSearchHit{}}, nil
+	}
+
+	result := &LogSearchResult{Items: []LogSearchHit{}}
+	needle := []rune(strings.Map(unicode.ToLower, keyword))
+	cursor := q.Before
+	for len(result.Items) < q.Limit && result.Scanned < logSearchScanLimit {
+		query := database.DB.Model(&models.TaskLogTerm{}).Where("term IN ?", terms)
+		if cursor != "" {
+			query = query.Where("log_id < ?", cursor)
+		}
+		if q.TaskID != "" {
+			query = query.Where("task_id = ?", q.TaskID)
+		}
+		if q.TaskIDs != nil {
+			query = query.Where("task_id IN ?", q.TaskIDs)
+		}
+		if q.Since != nil {
+			query = query.Where("created_at >= ?", *q.Since)
+		}
+		if q.Until != nil {
+			query = query.Where("created_at < ?", *q.Until)
+		}
+		var ids []string
+		if err := query.Group("log_id").Having("COUNT(*) = ?", len(terms)).
+			Order("log_id DESC").Limit(logSearchBatch).Pluck("log_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			cursor = ""
+			break
+		}
+
+		logQuery := database.DB.Where("id IN ?", ids)
+		if q.Status != "" {
+			logQuery = logQuery.Where("status = ?", q.Status)
+		}
+		var logs []models.TaskLog
+		logQuery.Find(&logs)
+		logMap := make(map[string]*models.TaskLog, len(logs))
+		for i := range logs {
+			logMap[logs[i].ID] = &logs[i]
+		}
+
+		for _, id := range ids {
+			result.Scanned++
+			cursor = id
+			if log, ok := logMap[id]; ok {
+				if hit := matchLog(log, needle); hit != nil {
+					result.Items = append(result.Items, *hit)
+				}
+			}
+			if len(result.Items) >= q.Limit || result.Scanned >= logSearchScanLimit {
+				break
+			}
+		}
+		if len(ids) < logSearchBatch && cursor == ids[len(ids)-1] {
+			cursor = ""
+			break
+		}
+	}
+	result.NextCursor = cursor
+
+	fillTaskNames(result.Items)
+	return result, nil
+}
+
+// matchLog 解压日志并逐行匹配关键词（不区分大小写），未命中返回 nil
+func matchLog(log *models.TaskLog, needle []rune) *LogSearchHit {
+	output, err := logstore.ReadOutput(log)
+	if err != nil || output == "" {
+		return nil
+	}
+	output = utils.StripAnsi(output)
+	if !strings.Contains(strings.Map(unicode.ToLower, output), string(needle)) {
+		return nil
+	}
+
+	hit := &LogSearchHit{LogID: log.ID, TaskID: log.TaskID, Status: log.Status, CreatedAt: log.CreatedAt, StartTime: log.StartTime}
+	for i, line := range strings.Split(output, "\n") {
+		positions := indexAllFold([]rune(strings.TrimRight(line, "\r")), needle)
+		if len(positions) == 0 {
+			continue
+		}
+		hit.Matches++
+		if len(hit.Snippets) < logSnippetsPerHit {
+			hit.Snippets = append(hit.Snippets, buildSnippet(i+1, []rune(strings.TrimRight(line, "\r")), positions, len(needle)))
+		}
+	}
+	if hit.Matches == 0 {
+		return nil
+	}
+	return hit
+}
+
+// indexAllFold 返回 needle 在 line 中所有不重叠出现位置（按字符计），needle 需已转为小写
+func indexAllFold(line, needle []rune) []int {
+	var positions []int
+	n := len(needle)
+	for i := 0; n > 0 && i+n <= len(line); {
+		matched := true
+		for j := 0; j < n; j++ {
+			if unicode.ToLower(line[i+j]) != needle[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			positions = append(positions, i)
+			i += n
+		} else {
+			i++
+		}
+	}
+	return positions
+}
+
+// buildSnippet 以第一个命中位置为中心截取行内容，并生成高亮 HTML
+func buildSnippet(lineNo int, line []rune, positions []int, size int) LogSnippet {
+	start, end := 0, len(line)
+	if len(line) > logSnippetWidth {
+		start = positions[0] - (logSnippetWidth-size)/2
+		if start < 0 {
+			start = 0
+		}
+		end = start + logSnippetWidth
+		if end > len(line) {
+			end = len(line)
+			start = end - logSnippetWidth
+		}
+	}
+
+	var text, highlight strings.Builder
+	if start > 0 {
+		text.WriteString("…")
+		highlight.WriteString("…")
+	}
+	text.WriteString(string(line[start:end]))
+	cur := start
+	for _, pos := range positions {
+		if pos < start || pos+size > end {
+			continue
+		}
+		highlight.WriteString(html.EscapeString(string(line[cur:pos])))
+		highlight.WriteString("<mark>" + html.EscapeString(string(line[pos:pos+size])) + "</mark>")
+		cur = pos + size
+	}
+	highlight.WriteString(html.EscapeString(string(line[cur:end])))
+	if end < len(line) {
+		text.WriteString("…")
+		highlight.WriteString("…")
+	}
+	return LogSnippet{Line: lineNo, Text: text.String(), Highlight: highlight.String()}
+}
+
+// fillTaskNames 补充命中日志的任务名称
+func fillTaskNames(hits []LogSearchHit) {
+	if len(hits) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.TaskID)
+	}
+	var tasks []models.Task
+	database.DB.Select("id", "name").Where("id IN ?", ids).Find(&tasks)
+	names := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		names[t.ID] = t.Name
+	}
+	for i := range hits {
+		hits[i].TaskName = names[hits[i].TaskID]
+	}
+}
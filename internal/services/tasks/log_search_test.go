@@ -0,0 +1,45 @@
+package tasks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeLog(t *testing.T) {
+	got := tokenizeLog("[WARN] Cookie expired: user_id=42, 登录状态失效 a Cookie", 0)
+	want := []string{"warn", "cookie", "expired", "user_id", "42", "登录", "录状", "状态", "态失", "失效"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokenizeLog = %v, want %v", got, want)
+	}
+	if got := tokenizeLog("a b 中 x", 0); len(got) != 0 {
+		t.Errorf("single characters should not be indexed, got %v", got)
+	}
+	if got := tokenizeLog("one two three four", 2); len(got) != 2 {
+		t.Errorf("limit not applied, got %v", got)
+	}
+}
+
+func TestIndexAllFoldAndSnippet(t *testing.T) {
+	needle := []rune("cookie expired")
+	line := []rune("<b>Cookie Expired</b> and cookie expired again")
+	positions := indexAllFold(line, needle)
+	if !reflect.DeepEqual(positions, []int{3, 26}) {
+		t.Fatalf("positions = %v", positions)
+	}
+	snippet := buildSnippet(7, line, positions, len(needle))
+	if snippet.Line != 7 || snippet.Text != string(line) {
+		t.Errorf("unexpected snippet %+v", snippet)
+	}
+	wantHTML := "&lt;b&gt;<mark>Cookie Expired</mark>&lt;/b&gt; and <mark>cookie expired</mark> again"
+	if snippet.Highlight != wantHTML {
+		t.Errorf("highlight = %q, want %q", snippet.Highlight, wantHTML)
+	}
+
+	// 超长行以命中位置为中心截取
+	long := []rune(strings.Repeat("x", 300) + "TOKEN" + strings.Repeat("y", 300))
+	snippet = buildSnippet(1, long, indexAllFold(long, []rune("token")), 5)
+	if len([]rune(snippet.Text)) != logSnippetWidth+2 || snippet.Highlight[:3] != "…" {
+		t.Errorf("long line should be trimmed around the match, got %d runes", len([]rune(snippet.Text)))
+	}
+}
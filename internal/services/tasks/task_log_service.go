@@ -6,7 +6,9 @@ import (
 
 	"github.com/engigu/baihu-panel/internal/constant"
 	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/eventbus"
 	"github.com/engigu/baihu-panel/internal/logger"
+	"github.com/engigu/baihu-panel/internal/logstore"
 	"github.com/engigu/baihu-panel/internal/models"
 	"github.com/engigu/baihu-panel/internal/systime"
 	"github.com/engigu/baihu-panel/internal/utils"
@@ -20,12 +22,14 @@ type SendStatsService interface {
 // TaskLogService 任务日志服务
 type TaskLogService struct {
 	sendStatsService SendStatsService
+	logSearch        *LogSearchService
 }
 
 // NewTaskLogService 创建任务日志服务
 func NewTaskLogService(sendStatsService SendStatsService) *TaskLogService {
 	return &TaskLogService{
 		sendStatsService: sendStatsService,
+		logSearch:        NewLogSearchService(),
 	}
 }
 
@@ -35,13 +39,17 @@ type CleanConfig struct {
 	Keep int    `json:"keep"` // 保留天数或条数
 }
 
-// CreateEmptyLog 创建一个空的日志记录（任务开始时调用）
-func (s *TaskLogService) CreateEmptyLog(taskID string, command string) (*models.TaskLog, error) {
+// CreateEmptyLog 创建一个空的日志记录（任务开始时调用），runEnv 为运行环境快照 JSON
+func (s *TaskLogService) CreateEmptyLog(logID string, taskID string, command string, runEnv string) (*models.TaskLog, error) {
+	if logID == "" {
+		logID = utils.GenerateID()
+	}
 	startTime := models.Now()
 	taskLog := &models.TaskLog{
-		ID:        utils.GenerateID(),
+		ID:        logID,
 		TaskID:    taskID,
 		Command:   models.BigText(command),
+		RunEnv:    models.BigText(runEnv),
 		Status:    "running",
 		StartTime: &startTime,
 		CreatedAt: models.Now(),
@@ -80,6 +88,13 @@ func (s *TaskLogService) SaveTaskLog(taskLog *models.TaskLog) error {
 		return err
 	}
 
+	// 已结束的日志将正文转存到日志存储，失败时保留在数据库
+	if taskLog.Status != "running" {
+		if err := logstore.Offload(taskLog); err != nil {
+			logger.Warnf("[TaskLog] 转存日志正文失败 #%s: %v", taskLog.ID, err)
+		}
+	}
+
 	// 更新任务的 last_run
 	// 更新任务的 last_run，优先使用日志记录的启动时间
 	lastRun := models.Now()
@@ -137,21 +152,28 @@ func (s *TaskLogService) CleanTaskLogs(taskID string) {
 	}
 
 	var deleted int64
+	var refs []string
 	switch config.Type {
 	case "day":
 		cutoff := systime.InCST(time.Now()).AddDate(0, 0, -config.Keep)
-		result := database.DB.Where("task_id = ? AND created_at < ?", taskID, cutoff).Delete(&models.TaskLog{})
+		query := database.DB.Where("task_id = ? AND created_at < ?", taskID, cutoff)
+		refs = logstore.CollectRefs(query)
+		result := query.Delete(&models.TaskLog{})
 		deleted = result.RowsAffected
 	case "count":
 		var boundaryLog models.TaskLog
 		res := database.DB.Where("task_id = ?", taskID).Order("id DESC").Offset(config.Keep - 1).Limit(1).Find(&boundaryLog)
 		if res.Error == nil && res.RowsAffected > 0 {
-			result := database.DB.Where("task_id = ? AND id < ?", taskID, boundaryLog.ID).Delete(&models.TaskLog{})
+			query := database.DB.Where("task_id = ? AND id < ?", taskID, boundaryLog.ID)
+			refs = logstore.CollectRefs(query)
+			result := query.Delete(&models.TaskLog{})
 			deleted = result.RowsAffected
 		}
 	}
 
 	if deleted > 0 {
+		s.logSearch.CleanIndex(taskID)
+		logstore.Release(refs)
 		logger.Infof("[TaskLog] 清理旧日志: #%s 共 %d 条", taskID, deleted)
 	}
 }
@@ -166,8 +188,19 @@ func (s *TaskLogService) ProcessTaskCompletion(taskLog *models.TaskLog) error {
 	// 2. 更新统计
 	s.UpdateTaskStats(taskLog.TaskID, taskLog.Status)
 
-	// 3. 异步清理旧日志
-	go s.CleanTaskLogs(taskLog.TaskID)
+	// 3. 通知日志转发等关注任务结束的订阅者，本地与 Agent 任务均经过此处
+	eventbus.DefaultBus.Publish(eventbus.Event{
+		Type:    constant.EventTaskFinished,
+		Payload: taskLog,
+	})
+
+	// 4. 异步建立全文索引并清理旧日志
+	go func() {
+		if err := s.logSearch.IndexLog(taskLog); err != nil {
+			logger.Warnf("[TaskLog] 建立日志索引失败 #%s: %v", taskLog.ID, err)
+		}
+		s.CleanTaskLogs(taskLog.TaskID)
+	}()
 
 	return nil
 }
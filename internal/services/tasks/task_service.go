@@ -111,7 +111,8 @@ func (ts *TaskService) GetTasks() []models.Task {
 }
 
 // GetTasksWithPagination 分页获取任务列表
-func (ts *TaskService) GetTasksWithPagination(page, pageSize int, name string, agentID *string, tags string, taskType string, sortBy string, order string) ([]models.Task, int64) {
+// ids 不为 nil 时仅在指定任务中查询
+func (ts *TaskService) GetTasksWithPagination(page, pageSize int, name string, agentID *string, tags string, ids []string, taskType string, sortBy string, order string) ([]models.Task, int64) {
 	var tasks []models.Task
 	var total int64
 
@@ -131,14 +132,7 @@ func (ts *TaskService) GetTasksWithPagination(page, pageSize int, name string, a
 			}
 		}
 		if len(validTags) > 0 {
-			var storageIDs []string
-			database.DB.Model(&models.DataStorage{}).Where("type = ? AND name IN ?", constant.RelationTypeTaskTag, validTags).Pluck("id", &storageIDs)
-
-			var taskIDs []string
-			if len(storageIDs) > 0 {
-				database.DB.Model(&models.DataRelation{}).Where("type = ? AND relate_id IN ?", constant.RelationTypeTaskTag, storageIDs).Pluck("data_id", &taskIDs)
-			}
-
+			taskIDs := relation.DataRelation.DataIDsByTags(constant.RelationTypeTaskTag, validTags)
 			if len(taskIDs) > 0 {
 				query = query.Where("id IN ?", taskIDs)
 			} else {
@@ -147,6 +141,10 @@ func (ts *TaskService) GetTasksWithPagination(page, pageSize int, name string, a
 		}
 	}
 
+	if ids != nil {
+		query = query.Where("id IN ?", append(ids, ""))
+	}
+
 	if taskType != "" && taskType != "all" {
 		query = query.Where("type = ?", taskType)
 	}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/engigu/baihu-panel/internal/constant"
@@ -19,6 +20,8 @@ import (
 const (
 	// maxLogBufferLen 定义了没有换行符时的最大缓冲长度 (4KB)
 	maxLogBufferLen = 4096
+	// maxLineMetas 最多保留的行元数据条数，超出后丢弃较早的一半（正文同样只保留末尾）
+	maxLineMetas = 200000
 )
 
 var (
@@ -64,9 +67,34 @@ type TinyLog struct {
 	path        string
 	writer      *bufio.Writer
 	subscribers []chan []byte
-	remainder   []byte   // Leftover bytes from previous write (partial lines)
-	masks       []string // Secrets to mask
+	chunkSubs   []chan LogChunk
+	remainder   []byte            // Leftover bytes from previous write (partial lines)
+	masker      *utils.MaskStream // 流式脱敏，机密跨越多次写入时同样生效
 	closed      bool
+
+	start    time.Time
+	stderr   *tinyLogStderr
+	metas    []utils.LineMeta // 已完成行的时间与来源
+	openLine *utils.LineMeta  // 尚未遇到换行符的当前行
+}
+
+// LogChunk 一次写入产生的日志片段，Offset 为相对运行开始的毫秒数
+type LogChunk struct {
+	Offset int64
+	Stream string
+	Data   []byte
+}
+
+// tinyLogStderr 标准错误输出的写入端，与标准输出分别缓冲不完整的行
+type tinyLogStderr struct {
+	log       *TinyLog
+	remainder []byte
+}
+
+func (w *tinyLogStderr) Write(p []byte) (int, error) {
+	w.log.mu.Lock()
+	defer w.log.mu.Unlock()
+	return w.log.write(utils.LogStreamStderr, &w.remainder, p)
 }
 
 // NewTinyLog 创建一个新的 TinyLog 实例（基于临时文件存储）并注册它，支持将配置的 masks 替换为 ********
@@ -82,29 +110,57 @@ func NewTinyLog(logID string, masks []string) (*TinyLog, error) {
 		path:        f.Name(),
 		writer:      bufio.NewWriter(f),
 		subscribers: make([]chan []byte, 0),
-		masks:       masks,
+		masker:      utils.NewMaskStream(utils.NewSecretMasker(masks)),
+		start:       time.Now(),
 	}
+	tl.stderr = &tinyLogStderr{log: tl}
 	globalTinyLogManager.Register(tl)
 	return tl, nil
 }
 
-// Write 实现 io.Writer 接口
+// SetMasks 替换需要脱敏的内容，用于执行前才解析出的外部机密
+func (l *TinyLog) SetMasks(masks []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.masker.SetMasker(utils.NewSecretMasker(masks))
+}
+
+// Write 实现 io.Writer 接口，写入的内容记为标准输出
 func (l *TinyLog) Write(p []byte) (n int, err error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	return l.write(utils.LogStreamStdout, &l.remainder, p)
+}
+
+// Stderr 返回标准错误输出的写入端，与 Write 写入同一份日志但记录为 stderr
+func (l *TinyLog) Stderr() io.Writer {
+	return l.stderr
+}
+
+// WriteSystem 写入面板自身的提示信息，记录为 system 来源
+func (l *TinyLog) WriteSystem(msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var rest []byte
+	if _, err := l.write(utils.LogStreamSystem, &rest, []byte(msg)); err == nil && len(rest) > 0 {
+		l.emit(utils.LogStreamSystem, l.masker.Write([]byte(utils.ToUTF8(rest))))
+	}
+}
 
+// write 按行切分并输出 p，不完整的行暂存在 remainder 中，调用方需持有锁
+func (l *TinyLog) write(stream string, remainderPtr *[]byte, p []byte) (n int, err error) {
 	if l.closed {
 		return 0, os.ErrClosed
 	}
 
 	originalInputLen := len(p)
 	var payload []byte
-	if len(l.remainder) > 0 {
-		// 为了防止 p 和 l.remainder 底层数组有重叠或不可预期的修改，这里分配新内存
-		payload = make([]byte, len(l.remainder)+len(p))
-		copy(payload, l.remainder)
-		copy(payload[len(l.remainder):], p)
-		l.remainder = nil
+	if len(*remainderPtr) > 0 {
+		// 为了防止 p 和 remainder 底层数组有重叠或不可预期的修改，这里分配新内存
+		payload = make([]byte, len(*remainderPtr)+len(p))
+		copy(payload, *remainderPtr)
+		copy(payload[len(*remainderPtr):], p)
+		*remainderPtr = nil
 	} else {
 		payload = p
 	}
@@ -135,42 +191,101 @@ func (l *TinyLog) Write(p []byte) (n int, err error) {
 			remainder = payload[lastSafe:]
 		} else {
 			// 保留当前所有内容到下一轮 (必须 copy，因为 payload 底层可能是 io.Copy 的复用 buf)
-			l.remainder = make([]byte, len(payload))
-			copy(l.remainder, payload)
+			*remainderPtr = make([]byte, len(payload))
+			copy(*remainderPtr, payload)
 			return originalInputLen, nil
 		}
 	}
 
 	// 4. 将剩余部分保存 (必须 copy，防止后续 Read 覆盖底层数组)
 	if len(remainder) > 0 {
-		l.remainder = make([]byte, len(remainder))
-		copy(l.remainder, remainder)
+		*remainderPtr = make([]byte, len(remainder))
+		copy(*remainderPtr, remainder)
 	} else {
-		l.remainder = nil
+		*remainderPtr = nil
 	}
 
-	// 5. 将完整行转换为 UTF-8 并脱敏
-	text := utils.MaskSecrets(utils.ToUTF8(completeBytes), l.masks)
-	outData := []byte(text)
+	// 5. 将完整行转换为 UTF-8 并脱敏（可能是机密开头的末尾部分由 masker 暂存）
+	outData := l.masker.Write([]byte(utils.ToUTF8(completeBytes)))
 
-	// 6. 输出安全部分
-	_, err = l.writer.Write(outData)
-	if err != nil {
+	// 6. 输出安全部分并广播
+	if err := l.emit(stream, outData); err != nil {
 		return 0, err
 	}
+	return originalInputLen, nil
+}
+
+// emit 将已脱敏的内容写入文件、记录行元数据并广播给订阅者，调用方需持有锁
+func (l *TinyLog) emit(stream string, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if _, err := l.writer.Write(data); err != nil {
+		return err
+	}
+	offset := time.Since(l.start).Milliseconds()
+	l.recordLines(stream, offset, data)
 
-	// 6. 广播给所有订阅者
-	if len(l.subscribers) > 0 {
-		for _, ch := range l.subscribers {
+	for _, ch := range l.subscribers {
+		select {
+		case ch <- data:
+		default:
+			// 如果订阅者处理太慢，丢弃消息以避免阻塞写入
+		}
+	}
+	if len(l.chunkSubs) > 0 {
+		chunk := LogChunk{Offset: offset, Stream: stream, Data: data}
+		for _, ch := range l.chunkSubs {
 			select {
-			case ch <- outData:
+			case ch <- chunk:
 			default:
-				// 如果订阅者处理太慢，丢弃消息以避免阻塞写入
 			}
 		}
 	}
+	return nil
+}
 
-	return originalInputLen, nil
+// recordLines 记录 data 中每一行的时间与来源，跨多次写入的行以第一次写入为准
+func (l *TinyLog) recordLines(stream string, offset int64, data []byte) {
+	for len(data) > 0 {
+		if l.openLine == nil {
+			l.openLine = &utils.LineMeta{Offset: offset, Stream: stream}
+		}
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			l.openLine.Size += len(data)
+			return
+		}
+		l.openLine.Size += i + 1
+		l.metas = append(l.metas, *l.openLine)
+		l.openLine = nil
+		data = data[i+1:]
+	}
+	if len(l.metas) > maxLineMetas {
+		l.metas = append([]utils.LineMeta(nil), l.metas[len(l.metas)-maxLineMetas/2:]...)
+	}
+}
+
+// lineMetas 返回当前全部行元数据（含未结束的行），调用方需持有锁
+func (l *TinyLog) lineMetas() []utils.LineMeta {
+	metas := append([]utils.LineMeta(nil), l.metas...)
+	if l.openLine != nil {
+		metas = append(metas, *l.openLine)
+	}
+	return metas
+}
+
+// LineMeta 返回编码后的行元数据，与 CompressAndCleanup 的正文配合使用
+func (l *TinyLog) LineMeta() string {
+	l.mu.RLock()
+	metas := l.lineMetas()
+	l.mu.RUnlock()
+	encoded, err := utils.EncodeLineMeta(metas)
+	if err != nil {
+		logger.Warnf("[TinyLog] 编码行元数据失败 #%s: %v", l.LogID, err)
+		return ""
+	}
+	return encoded
 }
 
 // WriteString 方便地写入字符串
@@ -188,6 +303,30 @@ func (l *TinyLog) Subscribe() chan []byte {
 	return ch
 }
 
+// SubscribeChunks 返回一个实时接收带时间与来源的日志片段的通道
+func (l *TinyLog) SubscribeChunks() chan LogChunk {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := make(chan LogChunk, 100)
+	l.chunkSubs = append(l.chunkSubs, ch)
+	return ch
+}
+
+// UnsubscribeChunks 移除片段订阅者
+func (l *TinyLog) UnsubscribeChunks(ch chan LogChunk) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, sub := range l.chunkSubs {
+		if sub == ch {
+			l.chunkSubs = append(l.chunkSubs[:i], l.chunkSubs[i+1:]...)
+			close(ch)
+			break
+		}
+	}
+}
+
 // Unsubscribe 移除订阅者
 func (l *TinyLog) Unsubscribe(ch chan []byte) {
 	l.mu.Lock()
@@ -211,21 +350,18 @@ func (l *TinyLog) Close() error {
 		return nil
 	}
 
-	// 处理剩余的字节
+	// 处理剩余的字节及脱敏器中暂存的内容，并通知订阅者最后一部分内容
+	if len(l.stderr.remainder) > 0 {
+		_ = l.emit(utils.LogStreamStderr, l.masker.Write([]byte(utils.ToUTF8(l.stderr.remainder))))
+		l.stderr.remainder = nil
+	}
+	var data []byte
 	if len(l.remainder) > 0 {
-		text := utils.MaskSecrets(utils.ToUTF8(l.remainder), l.masks)
-		data := []byte(text)
-		_, _ = l.writer.Write(data)
-
-		// 通知订阅者最后一部分内容
-		for _, ch := range l.subscribers {
-			select {
-			case ch <- data:
-			default:
-			}
-		}
+		data = l.masker.Write([]byte(utils.ToUTF8(l.remainder)))
 		l.remainder = nil
 	}
+	data = append(data, l.masker.Flush()...)
+	_ = l.emit(utils.LogStreamStdout, data)
 
 	// 将缓冲区刷新到文件
 	if err := l.writer.Flush(); err != nil {
@@ -237,6 +373,10 @@ func (l *TinyLog) Close() error {
 		close(ch)
 	}
 	l.subscribers = nil
+	for _, ch := range l.chunkSubs {
+		close(ch)
+	}
+	l.chunkSubs = nil
 
 	l.closed = true
 	globalTinyLogManager.Unregister(l.LogID)
@@ -325,7 +465,21 @@ func (l *TinyLog) CompressAndCleanup() (string, error) {
 func (l *TinyLog) ReadLastLines(n int) ([]byte, error) {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
+	return l.readLastLines(n)
+}
+
+// LastLines 返回日志的最后 n 行及每行的时间与来源
+func (l *TinyLog) LastLines(n int) ([]utils.LogLine, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	data, err := l.readLastLines(n)
+	if err != nil {
+		return nil, err
+	}
+	return utils.SplitLogLines(string(data), l.lineMetas()), nil
+}
 
+func (l *TinyLog) readLastLines(n int) ([]byte, error) {
 	// 刷新写入器以确保磁盘上的文件是最新的
 	_ = l.writer.Flush()
 
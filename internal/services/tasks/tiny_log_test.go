@@ -2,7 +2,15 @@ package tasks
 
 import (
 	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
+
+	"github.com/engigu/baihu-panel/internal/utils"
 )
 
 func TestTinyLog_UTF8Splitting(t *testing.T) {
@@ -102,3 +110,99 @@ func TestTinyLog_LongLineCut(t *testing.T) {
 		t.Errorf("Expected remainder len 3 (the char '你'), got %d", len(tl.remainder))
 	}
 }
+
+func TestTinyLog_MaskSplitSecrets(t *testing.T) {
+	secrets := []string{"ghp_0123456789abcdef", "-----BEGIN KEY-----\nMIIBOgIBAAJBAK\n-----END KEY-----"}
+	var input bytes.Buffer
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&input, "step %d token=%s url=%s\n", i, secrets[0], url.QueryEscape(secrets[0]))
+		fmt.Fprintf(&input, "key:\n%s\nb64=%s\n", secrets[1], base64.StdEncoding.EncodeToString([]byte(secrets[0])))
+	}
+	input.WriteString(strings.Repeat("x", maxLogBufferLen-5) + secrets[0]) // 跨越强制截断边界
+	data := input.Bytes()
+
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 20; round++ {
+		tl, err := NewTinyLog(fmt.Sprintf("test-mask-%d", round), secrets)
+		if err != nil {
+			t.Fatalf("Failed to create TinyLog: %v", err)
+		}
+		ch := tl.Subscribe()
+		streamed := make(chan []byte)
+		go func() {
+			var all []byte
+			for chunk := range ch {
+				all = append(all, chunk...)
+			}
+			streamed <- all
+		}()
+
+		for i := 0; i < len(data); {
+			end := min(i+1+rng.Intn(64), len(data))
+			tl.Write(data[i:end])
+			i = end
+		}
+		tl.Close()
+		stored, _ := os.ReadFile(tl.GetPath())
+		os.Remove(tl.GetPath())
+		live := <-streamed
+
+		for name, out := range map[string][]byte{"stored": stored, "streamed": live} {
+			for _, secret := range secrets {
+				for _, v := range utils.SecretVariants(secret) {
+					if bytes.Contains(out, []byte(v)) {
+						t.Fatalf("round %d: %s output contains %q", round, name, v)
+					}
+				}
+			}
+		}
+		if !bytes.Contains(stored, []byte("step 49 token=********")) {
+			t.Fatalf("round %d: unexpected stored output tail: %q", round, stored[len(stored)-200:])
+		}
+	}
+}
+
+func TestTinyLog_LineStreams(t *testing.T) {
+	tl, err := NewTinyLog("test-streams", nil)
+	if err != nil {
+		t.Fatalf("Failed to create TinyLog: %v", err)
+	}
+	chunks := tl.SubscribeChunks()
+
+	tl.WriteSystem("[System] retry")
+	tl.Write([]byte("out 1\nout "))
+	tl.Stderr().Write([]byte("err 1\n"))
+	tl.Write([]byte("2\n"))
+	tl.Stderr().Write([]byte("tail without newline"))
+
+	first := <-chunks
+	if first.Stream != utils.LogStreamSystem || string(first.Data) != "[System] retry" {
+		t.Errorf("unexpected first chunk: %+v", first)
+	}
+
+	lines, err := tl.LastLines(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, len(lines))
+	for i, line := range lines {
+		got[i] = line.Stream + ":" + line.Text
+	}
+	// 系统提示没有换行，与下一次标准输出拼成同一行，以先写入的来源为准；不完整的 "out " 等到换行才输出
+	want := "system:[System] retryout 1|stderr:err 1|stdout:out 2"
+	if strings.Join(got, "|") != want {
+		t.Errorf("lines = %q, want %q", strings.Join(got, "|"), want)
+	}
+
+	tl.Close()
+	stored, _ := os.ReadFile(tl.GetPath())
+	os.Remove(tl.GetPath())
+	metas, err := utils.DecodeLineMeta(tl.LineMeta())
+	if err != nil {
+		t.Fatal(err)
+	}
+	split := utils.SplitLogLines(string(stored), metas)
+	if len(split) != 4 || split[3].Stream != utils.LogStreamStderr || split[3].Text != "tail without newline" {
+		t.Errorf("unexpected lines after close: %+v", split)
+	}
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用内存 SQLite 替换全局数据库并建好全部表，测试结束后恢复
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存库每个连接各自独立，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+
+	old := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = old
+		sqlDB.Close()
+	})
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -111,15 +111,22 @@ func (us *UserService) UpdatePassword(userID string, newPassword string) error {
 	if err != nil {
 		return err
 	}
-	// 修改密码时同时失效旧 Token
-	return database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+	// 修改密码时同时失效旧 Token 与全部会话
+	err = database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
 		"password":      hashedPassword,
 		"token_version": gorm.Expr("token_version + 1"),
 	}).Error
+	if err != nil {
+		return err
+	}
+	return deleteUserSessions(database.DB, userID)
 }
 
 func (us *UserService) InvalidateUserTokens(userID string) error {
-	return database.DB.Model(&models.User{}).Where("id = ?", userID).Update("token_version", gorm.Expr("token_version + 1")).Error
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+	return deleteUserSessions(database.DB, userID)
 }
 
 func (us *UserService) UpdateAccount(userID string, newUsername string) error {
@@ -155,3 +162,68 @@ func (us *UserService) UpdateOTP(userID string, secret string, enabled bool) err
 		"otp_enabled": enabled,
 	}).Error
 }
+
+// ListUsers 获取全部用户
+func (us *UserService) ListUsers() []models.User {
+	var users []models.User
+	database.DB.Order("created_at ASC").Find(&users)
+	return users
+}
+
+// UserUpdate 管理员可修改的用户属性，nil 表示不修改
+type UserUpdate struct {
+	Email    *string
+	Role     *string
+	Scopes   *string
+	Disabled *bool
+}
+
+// UpdateUser 更新用户的角色、标签范围及启用状态，禁用或降级时保证至少保留一个可用的管理员
+func (us *UserService) UpdateUser(userID string, u UserUpdate) (*models.User, error) {
+	user, _ := us.GetUserByID(userID)
+	if user == nil {
+		return nil, fmt.Errorf("未找到对应的用户")
+	}
+
+	updates := make(map[string]interface{})
+	if u.Email != nil {
+		updates["email"] = strings.TrimSpace(*u.Email)
+	}
+	if u.Role != nil && *u.Role != user.Role {
+		updates["role"] = *u.Role
+	}
+	if u.Scopes != nil {
+		updates["scopes"] = strings.Join(SplitScopes(*u.Scopes), ",")
+	}
+	if u.Disabled != nil && *u.Disabled != user.Disabled {
+		updates["disabled"] = *u.Disabled
+		if *u.Disabled {
+			// 禁用后立即失效已签发的登录凭证
+			updates["token_version"] = gorm.Expr("token_version + 1")
+		}
+	}
+	if len(updates) == 0 {
+		return user, nil
+	}
+
+	losingAdmin := user.Role == constant.AdminRole && !user.Disabled &&
+		((u.Role != nil && *u.Role != constant.AdminRole) || (u.Disabled != nil && *u.Disabled))
+	if losingAdmin && us.CountActiveAdmins() <= 1 {
+		return nil, fmt.Errorf("至少需要保留一个可用的管理员账户")
+	}
+
+	if err := database.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if u.Disabled != nil && *u.Disabled {
+		deleteUserSessions(database.DB, userID)
+	}
+	return us.GetUserByID(userID)
+}
+
+// CountActiveAdmins 统计未禁用的管理员数量
+func (us *UserService) CountActiveAdmins() int64 {
+	var count int64
+	database.DB.Model(&models.User{}).Where("role = ? AND disabled = ?", constant.AdminRole, false).Count(&count)
+	return count
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/engigu/baihu-panel/internal/utils"
+)
+
+// WebAuthn 认证器数据标志位
+const (
+	webauthnFlagUserPresent  = 0x01
+	webauthnFlagUserVerified = 0x04
+	webauthnFlagAttested     = 0x40
+)
+
+// COSE 算法标识
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+// WebAuthnRP 依赖方信息，ID 为域名，Origin 为浏览器地址栏的源
+type WebAuthnRP struct {
+	ID     string
+	Origin string
+	Name   string
+}
+
+// webauthnClientData 浏览器生成的 clientDataJSON
+type webauthnClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// webauthnAuthData 解析后的认证器数据
+type webauthnAuthData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte // COSE 编码的公钥，仅注册时存在
+}
+
+// verifyWebAuthnClientData 校验 clientDataJSON 的类型、挑战值与来源
+func verifyWebAuthnClientData(raw []byte, ceremony, challenge, origin string) error {
+	var cd webauthnClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return errors.New("clientDataJSON 格式错误")
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("类型不匹配: %s", cd.Type)
+	}
+	if subtle.ConstantTimeCompare([]byte(cd.Challenge), []byte(challenge)) != 1 {
+		return errors.New("挑战值不匹配")
+	}
+	if cd.Origin != origin {
+		return fmt.Errorf("来源不匹配: %s", cd.Origin)
+	}
+	return nil
+}
+
+// parseWebAuthnAuthData 解析认证器数据，并校验 RP ID 摘要与用户在场标志
+func parseWebAuthnAuthData(raw []byte, rpID string) (*webauthnAuthData, error) {
+	if len(raw) < 37 {
+		return nil, errors.New("认证器数据过短")
+	}
+	ad := &webauthnAuthData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rpHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(ad.RPIDHash, rpHash[:]) {
+		return nil, errors.New("RP ID 不匹配")
+	}
+	if ad.Flags&webauthnFlagUserPresent == 0 {
+		return nil, errors.New("未检测到用户在场")
+	}
+	if ad.Flags&webauthnFlagAttested == 0 {
+		return ad, nil
+	}
+
+	// aaguid(16) + 凭证 ID 长度(2) + 凭证 ID + COSE 公钥
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("凭证数据过短")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLen {
+		return nil, errors.New("凭证 ID 不完整")
+	}
+	ad.CredentialID, rest = rest[:idLen], rest[idLen:]
+	_, after, err := utils.DecodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("公钥解析失败: %v", err)
+	}
+	ad.PublicKey = rest[:len(rest)-len(after)]
+	return ad, nil
+}
+
+// parseWebAuthnAttestation 解析注册返回的 attestationObject，取出认证器数据
+// 注册时请求 attestation=none，不校验认证器证明，仅信任浏览器完成的用户验证
+func parseWebAuthnAttestation(raw []byte, rpID string) (*webauthnAuthData, error) {
+	obj, _, err := utils.DecodeCBOR(raw)
+	if err != nil {
+		return nil, fmt.Errorf("attestationObject 解析失败: %v", err)
+	}
+	m, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("attestationObject 格式错误")
+	}
+	authData, ok := m["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("缺少 authData")
+	}
+	ad, err := parseWebAuthnAuthData(authData, rpID)
+	if err != nil {
+		return nil, err
+	}
+	if ad.PublicKey == nil {
+		return nil, errors.New("注册数据中缺少凭证公钥")
+	}
+	if _, _, err := parseCOSEKey(ad.PublicKey); err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+// parseCOSEKey 解析 COSE 公钥，支持 ES256、EdDSA(Ed25519) 与 RS256
+func parseCOSEKey(raw []byte) (int64, crypto.PublicKey, error) {
+	obj, _, err := utils.DecodeCBOR(raw)
+	if err != nil {
+		return 0, nil, err
+	}
+	m, ok := obj.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, errors.New("COSE 公钥格式错误")
+	}
+	alg, _ := m[int64(3)].(int64)
+	x, _ := m[int64(-2)].([]byte)
+
+	switch alg {
+	case coseAlgES256:
+		y, _ := m[int64(-3)].([]byte)
+		if crv, _ := m[int64(-1)].(int64); crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("不支持的 EC 公钥")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, errors.New("EC 公钥无效")
+		}
+		return alg, pub, nil
+	case coseAlgEdDSA:
+		if crv, _ := m[int64(-1)].(int64); crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("不支持的 OKP 公钥")
+		}
+		return alg, ed25519.PublicKey(x), nil
+	case coseAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, errors.New("不支持的 RSA 公钥")
+		}
+		return alg, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return 0, nil, fmt.Errorf("不支持的签名算法 %d", alg)
+}
+
+// verifyWebAuthnSignature 校验断言签名，签名内容为 authenticatorData || SHA256(clientDataJSON)
+func verifyWebAuthnSignature(coseKey, authData, clientDataJSON, sig []byte) error {
+	alg, pub, err := parseCOSEKey(coseKey)
+	if err != nil {
+		return err
+	}
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte{}, authData...), clientHash[:]...)
+	digest := sha256.Sum256(signed)
+
+	ok := false
+	switch alg {
+	case coseAlgES256:
+		ok = ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], sig)
+	case coseAlgEdDSA:
+		ok = ed25519.Verify(pub.(ed25519.PublicKey), signed, sig)
+	case coseAlgRS256:
+		ok = rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil
+	}
+	if !ok {
+		return errors.New("签名校验失败")
+	}
+	return nil
+}
+
+// b64url 无填充的 base64url 编码，与浏览器端 WebAuthn 数据保持一致
+func b64url(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeB64url 兼容有无填充的 base64url 解码
+func decodeB64url(s string) ([]byte, error) {
+	if b, err := base64.RawURLEncoding.DecodeString(s); err == nil {
+		return b, nil
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/engigu/baihu-panel/internal/constant"
+	"github.com/engigu/baihu-panel/internal/database"
+	"github.com/engigu/baihu-panel/internal/models"
+)
+
+// cborHead 编码 CBOR 类型头，测试中仅用于构造认证器数据
+func cborHead(major byte, n int) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 256:
+		return []byte{major<<5 | 24, byte(n)}
+	}
+	return []byte{major<<5 | 25, byte(n >> 8), byte(n)}
+}
+
+func cborInt(v int) []byte {
+	if v < 0 {
+		return cborHead(1, -1-v)
+	}
+	return cborHead(0, v)
+}
+
+func cborBytes(b []byte) []byte { return append(cborHead(2, len(b)), b...) }
+
+func cborText(s string) []byte { return append(cborHead(3, len(s)), s...) }
+
+// softAuthenticator 软件实现的 ES256 认证器
+type softAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	credID []byte
+	count  uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credID: []byte("cred-0001")}
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	out := cborHead(5, 5)
+	out = append(out, cborInt(1)...)
+	out = append(out, cborInt(2)...)
+	out = append(out, cborInt(3)...)
+	out = append(out, cborInt(coseAlgES256)...)
+	out = append(out, cborInt(-1)...)
+	out = append(out, cborInt(1)...)
+	out = append(out, cborInt(-2)...)
+	out = append(out, cborBytes(x)...)
+	out = append(out, cborInt(-3)...)
+	return append(out, cborBytes(y)...)
+}
+
+func (a *softAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	out := append([]byte{}, rpHash[:]...)
+	out = append(out, flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(out[33:], a.count)
+	if attested {
+		out = append(out, make([]byte, 16)...)
+		out = append(out, byte(len(a.credID)>>8), byte(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func clientDataJSON(typ, challenge, origin string) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+	return b
+}
+
+func TestWebAuthnRegistrationAndAssertion(t *testing.T) {
+	constant.Secret = "test-secret"
+	rp := WebAuthnRP{ID: "panel.example.com", Origin: "https://panel.example.com"}
+	auth := newSoftAuthenticator(t)
+
+	challenge, session, err := newWebAuthnSession(webauthnPurposeRegister, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := parseWebAuthnSession(session, webauthnPurposeRegister)
+	if err != nil || claims.Challenge != challenge || claims.UserID != "u1" {
+		t.Fatalf("session should round-trip, got %+v, %v", claims, err)
+	}
+	if _, err := parseWebAuthnSession(session, webauthnPurposeLogin); err == nil {
+		t.Error("register session must not be accepted for login")
+	}
+
+	// 注册：attestationObject = {fmt: "none", attStmt: {}, authData: ...}
+	attObj := cborHead(5, 3)
+	attObj = append(attObj, cborText("fmt")...)
+	attObj = append(attObj, cborText("none")...)
+	attObj = append(attObj, cborText("attStmt")...)
+	attObj = append(attObj, cborHead(5, 0)...)
+	attObj = append(attObj, cborText("authData")...)
+	attObj = append(attObj, cborBytes(auth.authData(rp.ID, webauthnFlagUserPresent|webauthnFlagAttested, true))...)
+
+	if err := verifyWebAuthnClientData(clientDataJSON("webauthn.create", challenge, rp.Origin), "webauthn.create", challenge, rp.Origin); err != nil {
+		t.Fatalf("client data: %v", err)
+	}
+	if err := verifyWebAuthnClientData(clientDataJSON("webauthn.create", challenge, "https://evil.com"), "webauthn.create", challenge, rp.Origin); err == nil {
+		t.Error("foreign origin must be rejected")
+	}
+	ad, err := parseWebAuthnAttestation(attObj, rp.ID)
+	if err != nil {
+		t.Fatalf("attestation: %v", err)
+	}
+	if string(ad.CredentialID) != "cred-0001" || len(ad.PublicKey) == 0 {
+		t.Fatalf("unexpected credential data: %+v", ad)
+	}
+	if _, err := parseWebAuthnAttestation(attObj, "other.example.com"); err == nil {
+		t.Error("rp id mismatch must be rejected")
+	}
+
+	// 断言：签名覆盖 authenticatorData || SHA256(clientDataJSON)
+	auth.count = 1
+	authData := auth.authData(rp.ID, webauthnFlagUserPresent|webauthnFlagUserVerified, false)
+	cd := clientDataJSON("webauthn.get", "c2", rp.Origin)
+	clientHash := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, auth.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyWebAuthnSignature(ad.PublicKey, authData, cd, sig); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := verifyWebAuthnSignature(ad.PublicKey, authData, clientDataJSON("webauthn.get", "c3", rp.Origin), sig); err == nil {
+		t.Error("signature over different client data must be rejected")
+	}
+	if parsed, err := parseWebAuthnAuthData(authData, rp.ID); err != nil || parsed.SignCount != 1 || parsed.Flags&webauthnFlagUserVerified == 0 {
+		t.Errorf("unexpected auth data: %+v, %v", parsed, err)
+	}
+	if _, err := parseWebAuthnAuthData(auth.authData(rp.ID, 0, false), rp.ID); err == nil {
+		t.Error("user presence is required")
+	}
+}
+
+// 完成登录后挑战即被消费，同一断言重放时应被拒绝（不带计数器的认证器无法靠计数器识别重放）
+func TestWebAuthnLoginReplay(t *testing.T) {
+	setupTestDB(t)
+	constant.Secret = "test-secret"
+	rp := WebAuthnRP{ID: "panel.example.com", Origin: "https://panel.example.com"}
+	auth := newSoftAuthenticator(t)
+	cred := &models.WebAuthnCredential{ID: "c1", UserID: "u1", Name: "key", CredentialID: b64url(auth.credID), PublicKey: b64url(auth.coseKey())}
+	if err := database.DB.Create(cred).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewMFAService(nil)
+	options, session, err := s.BeginLogin(rp, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authData := auth.authData(rp.ID, webauthnFlagUserPresent|webauthnFlagUserVerified, false)
+	cd := clientDataJSON("webauthn.get", options["challenge"].(string), rp.Origin)
+	clientHash := sha256.Sum256(cd)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, auth.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &WebAuthnCredentialResponse{ID: cred.CredentialID, Type: "public-key"}
+	resp.Response.ClientDataJSON = b64url(cd)
+	resp.Response.AuthenticatorData = b64url(authData)
+	resp.Response.Signature = b64url(sig)
+
+	if got, err := s.FinishLogin(rp, session, resp); err != nil || got.LastUsedAt == nil {
+		t.Fatalf("FinishLogin = %+v, %v", got, err)
+	}
+	if _, err := s.FinishLogin(rp, session, resp); err == nil {
+		t.Error("replayed assertion must be rejected")
+	}
+}
+
+func TestRecoveryCode(t *testing.T) {
+	code, err := randomRecoveryCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 11 || code[5] != '-' {
+		t.Fatalf("unexpected recovery code format: %q", code)
+	}
+	if strings.Trim(strings.Replace(code, "-", "", 1), recoveryCodeAlphabet) != "" {
+		t.Errorf("recovery code uses symbols outside the alphabet: %q", code)
+	}
+	if hashRecoveryCode(code) != hashRecoveryCode(" "+strings.ToUpper(strings.Replace(code, "-", "", 1))+" ") {
+		t.Error("recovery codes should ignore case, spaces and dashes")
+	}
+	other, _ := randomRecoveryCode()
+	if other == code {
+		t.Error("recovery codes should be random")
+	}
+}
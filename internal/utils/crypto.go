@@ -6,31 +6,100 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"io"
 	"os"
 	"strings"
 )
 
+// ciphertextPrefix 带密钥 ID 的密文格式：bh1:<密钥ID>:<base64(nonce||密文)>
+// 旧版本生成的密文没有前缀，解密时依次尝试所有已配置的密钥
+const ciphertextPrefix = "bh1:"
+
+// secretKey 一把派生后的 AES-256 密钥及其 ID
+type secretKey struct {
+	id  string
+	key []byte
+}
+
 var (
-	masterSecretKey []byte
-	ErrKeyNotSet    = errors.New("加密秘钥未配置，请按照文档使用 BAIHU_SECRET_KEY 环境变量启动服务配置秘钥")
+	// secretKeys 第一把为当前主密钥，用于加密；其余为仅用于解密的旧密钥
+	secretKeys   []secretKey
+	ErrKeyNotSet = errors.New("加密秘钥未配置，请按照文档使用 BAIHU_SECRET_KEY 环境变量启动服务配置秘钥")
+	// ErrKeyNotFound 密文所用的密钥未配置
+	ErrKeyNotFound = errors.New("找不到该密文对应的密钥，请通过 BAIHU_SECRET_KEY_PREVIOUS 提供旧密钥")
 )
 
 // InitSecretKey initialized the master secret key from the environment and unsets it
+// BAIHU_SECRET_KEY_PREVIOUS 可提供一个或多个（逗号分隔）旧密钥，仅用于解密，便于轮换
 func InitSecretKey() {
 	key := os.Getenv("BAIHU_SECRET_KEY")
-	if key != "" {
-		hash := sha256.Sum256([]byte(key))
-		masterSecretKey = hash[:]
-		// Ensure it's only in memory by unsetting the environment variable
-		os.Unsetenv("BAIHU_SECRET_KEY")
+	previous := os.Getenv("BAIHU_SECRET_KEY_PREVIOUS")
+	// Ensure it's only in memory by unsetting the environment variable
+	os.Unsetenv("BAIHU_SECRET_KEY")
+	os.Unsetenv("BAIHU_SECRET_KEY_PREVIOUS")
+
+	var oldKeys []string
+	for _, k := range strings.Split(previous, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			oldKeys = append(oldKeys, k)
+		}
+	}
+	SetSecretKeys(key, oldKeys...)
+}
+
+// SetSecretKeys 设置主密钥与旧密钥，主密钥为空时不可加密，但旧密钥仍可用于解密
+func SetSecretKeys(primary string, previous ...string) {
+	secretKeys = nil
+	seen := make(map[string]bool)
+	for i, raw := range append([]string{primary}, previous...) {
+		if raw == "" {
+			if i == 0 {
+				// 占位，保证 secretKeys[0] 始终是主密钥
+				secretKeys = append(secretKeys, secretKey{})
+			}
+			continue
+		}
+		k := deriveSecretKey(raw)
+		if seen[k.id] {
+			continue
+		}
+		seen[k.id] = true
+		secretKeys = append(secretKeys, k)
 	}
 }
 
+// deriveSecretKey 由用户提供的密钥派生 AES 密钥，密钥 ID 取派生结果再哈希的前 8 位，不泄露密钥本身
+func deriveSecretKey(raw string) secretKey {
+	hash := sha256.Sum256([]byte(raw))
+	idHash := sha256.Sum256(append([]byte("baihu-key-id:"), hash[:]...))
+	return secretKey{id: hex.EncodeToString(idHash[:4]), key: hash[:]}
+}
+
 // IsSecretKeySet returns true if the master secret key is configured
 func IsSecretKeySet() bool {
-	return len(masterSecretKey) > 0
+	return len(secretKeys) > 0 && len(secretKeys[0].key) > 0
+}
+
+// SecretKeyID 当前主密钥的 ID，未配置时为空
+func SecretKeyID() string {
+	if !IsSecretKeySet() {
+		return ""
+	}
+	return secretKeys[0].id
+}
+
+// CiphertextKeyID 密文中记录的密钥 ID，旧格式密文返回空
+func CiphertextKeyID(ciphertext string) string {
+	if !strings.HasPrefix(ciphertext, ciphertextPrefix) {
+		return ""
+	}
+	id, _, ok := strings.Cut(strings.TrimPrefix(ciphertext, ciphertextPrefix), ":")
+	if !ok {
+		return ""
+	}
+	return id
 }
 
 // Encrypt encrypts a plaintext string using AES-GCM
@@ -42,12 +111,7 @@ func Encrypt(plaintext string) (string, error) {
 		return "", ErrKeyNotSet
 	}
 
-	block, err := aes.NewCipher(masterSecretKey)
-	if err != nil {
-		return "", err
-	}
-
-	aesGCM, err := cipher.NewGCM(block)
+	aesGCM, err := newGCM(secretKeys[0].key)
 	if err != nil {
 		return "", err
 	}
@@ -58,7 +122,7 @@ func Encrypt(plaintext string) (string, error) {
 	}
 
 	ciphertext := aesGCM.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return ciphertextPrefix + secretKeys[0].id + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
 // Decrypt decrypts a ciphertext string using AES-GCM
@@ -67,52 +131,70 @@ func Decrypt(ciphertext string) (string, error) {
 	if ciphertext == "" {
 		return "", nil
 	}
-	if !IsSecretKeySet() {
+	if len(secretKeys) == 0 {
 		return ciphertext, ErrKeyNotSet
 	}
 
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if id := CiphertextKeyID(ciphertext); id != "" {
+		payload := strings.TrimPrefix(ciphertext, ciphertextPrefix+id+":")
+		for _, k := range secretKeys {
+			if k.id == id {
+				plaintext, err := decryptWithKey(payload, k.key)
+				if err != nil {
+					return ciphertext, err
+				}
+				return plaintext, nil
+			}
+		}
+		return ciphertext, ErrKeyNotFound
+	}
+
+	// 旧格式密文没有密钥 ID，逐个尝试
+	err := ErrKeyNotSet
+	for _, k := range secretKeys {
+		if len(k.key) == 0 {
+			continue
+		}
+		var plaintext string
+		if plaintext, err = decryptWithKey(ciphertext, k.key); err == nil {
+			return plaintext, nil
+		}
+	}
+	return ciphertext, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return ciphertext, err
+		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
 
-	block, err := aes.NewCipher(masterSecretKey)
+func decryptWithKey(payload string, key []byte) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
-		return ciphertext, err
+		return "", err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	aesGCM, err := newGCM(key)
 	if err != nil {
-		return ciphertext, err
+		return "", err
 	}
 
 	nonceSize := aesGCM.NonceSize()
 	if len(data) < nonceSize {
-		return ciphertext, errors.New("ciphertext too short")
+		return "", errors.New("ciphertext too short")
 	}
 
 	nonce, ciphertextBytes := data[:nonceSize], data[nonceSize:]
 	plaintext, err := aesGCM.Open(nil, nonce, ciphertextBytes, nil)
 	if err != nil {
-		return ciphertext, err
+		return "", err
 	}
-
 	return string(plaintext), nil
 }
 
-// MaskSecrets 将文本中的所有敏感机密值替换为脱敏字符串 "********"
-func MaskSecrets(text string, secrets []string) string {
-	if len(secrets) == 0 || text == "" {
-		return text
-	}
-	for _, mask := range secrets {
-		if mask != "" {
-			text = strings.ReplaceAll(text, mask, "********")
-		}
-	}
-	return text
-}
-
 // MaskString 对字符串进行脱敏处理，保留首尾，中间用星号遮掩
 func MaskString(s string) string {
 	if s == "" {
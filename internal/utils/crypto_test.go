@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// legacyEncrypt 按旧版本格式（无密钥 ID 前缀）加密
+func legacyEncrypt(t *testing.T, raw, plaintext string) string {
+	gcm, err := newGCM(deriveSecretKey(raw).key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	rand.Read(nonce)
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plaintext), nil))
+}
+
+func TestEncryptWithKeyID(t *testing.T) {
+	defer SetSecretKeys("")
+
+	SetSecretKeys("old-key")
+	oldID := SecretKeyID()
+	oldCipher, err := Encrypt("token-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(oldCipher, "bh1:"+oldID+":") || CiphertextKeyID(oldCipher) != oldID {
+		t.Fatalf("ciphertext should carry key id %s, got %q", oldID, oldCipher)
+	}
+	legacy := legacyEncrypt(t, "old-key", "token-0")
+
+	// 轮换后：新密钥加密，旧密钥仍可解密
+	SetSecretKeys("new-key", "old-key")
+	if SecretKeyID() == oldID {
+		t.Fatal("different keys must have different ids")
+	}
+	for cipherText, want := range map[string]string{oldCipher: "token-1", legacy: "token-0"} {
+		if got, err := Decrypt(cipherText); err != nil || got != want {
+			t.Errorf("Decrypt(%q) = %q, %v; want %q", cipherText, got, err, want)
+		}
+	}
+	newCipher, _ := Encrypt("token-2")
+	if CiphertextKeyID(newCipher) != SecretKeyID() {
+		t.Errorf("new ciphertext should use the primary key, got %q", newCipher)
+	}
+
+	// 移除旧密钥后，旧密文报告找不到密钥
+	SetSecretKeys("new-key")
+	if _, err := Decrypt(oldCipher); err != ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+	if _, err := Decrypt(legacy); err == nil {
+		t.Error("legacy ciphertext must not decrypt with a different key")
+	}
+	if got, err := Decrypt(newCipher); err != nil || got != "token-2" {
+		t.Errorf("Decrypt(new) = %q, %v", got, err)
+	}
+
+	// 仅配置旧密钥时不可加密，但可以解密
+	SetSecretKeys("", "new-key")
+	if IsSecretKeySet() {
+		t.Error("primary key should be unset")
+	}
+	if _, err := Encrypt("x"); err != ErrKeyNotSet {
+		t.Errorf("expected ErrKeyNotSet, got %v", err)
+	}
+	if got, err := Decrypt(newCipher); err != nil || got != "token-2" {
+		t.Errorf("previous keys should still decrypt, got %q, %v", got, err)
+	}
+}
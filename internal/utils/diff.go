@@ -0,0 +1,187 @@
+package utils
+
+// 行级差异类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffLine 差异中的一行，A、B 为该行在两侧的行号（从 1 开始），不存在于某侧时为 0
+type DiffLine struct {
+	Op   string `json:"op"`
+	A    int    `json:"a,omitempty"`
+	B    int    `json:"b,omitempty"`
+	Text string `json:"text"`
+}
+
+// DiffHunk 带上下文的差异块，与 unified diff 的 @@ 块含义相同
+type DiffHunk struct {
+	AStart int        `json:"a_start"`
+	ALines int        `json:"a_lines"`
+	BStart int        `json:"b_start"`
+	BLines int        `json:"b_lines"`
+	Lines  []DiffLine `json:"lines"`
+}
+
+// DiffLines 使用 Myers 算法计算从 a 到 b 的最短编辑序列
+// maxEdits 为编辑距离上限（<=0 表示不限制），超出时中间部分按整体删除再插入处理，并返回 false
+func DiffLines(a, b []string, maxEdits int) ([]DiffLine, bool) {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	out := make([]DiffLine, 0, max(len(a), len(b)))
+	for i := 0; i < pre; i++ {
+		out = append(out, DiffLine{Op: DiffEqual, A: i + 1, B: i + 1, Text: a[i]})
+	}
+	mid, complete := myersDiff(a[pre:len(a)-suf], b[pre:len(b)-suf], maxEdits)
+	for _, l := range mid {
+		if l.A > 0 {
+			l.A += pre
+		}
+		if l.B > 0 {
+			l.B += pre
+		}
+		out = append(out, l)
+	}
+	for i := 0; i < suf; i++ {
+		ai, bi := len(a)-suf+i, len(b)-suf+i
+		out = append(out, DiffLine{Op: DiffEqual, A: ai + 1, B: bi + 1, Text: a[ai]})
+	}
+	return out, complete
+}
+
+func myersDiff(a, b []string, maxEdits int) ([]DiffLine, bool) {
+	n, m := len(a), len(b)
+	limit := n + m
+	if maxEdits > 0 && maxEdits < limit {
+		limit = maxEdits
+	}
+	offset := limit + 1
+	v := make([]int, 2*limit+3) // v[offset+k] 为对角线 k 上能到达的最远 x
+	var trace [][]int           // trace[d] 为第 d 步开始前 v 在 [-d, d] 范围内的快照
+
+	for d := 0; d <= limit; d++ {
+		snap := make([]int, 2*d+1)
+		copy(snap, v[offset-d:offset+d+1])
+		trace = append(trace, snap)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return myersBacktrack(a, b, trace, d), true
+			}
+		}
+	}
+
+	// 差异过大，整体替换
+	out := make([]DiffLine, 0, n+m)
+	for i, s := range a {
+		out = append(out, DiffLine{Op: DiffDelete, A: i + 1, Text: s})
+	}
+	for i, s := range b {
+		out = append(out, DiffLine{Op: DiffInsert, B: i + 1, Text: s})
+	}
+	return out, false
+}
+
+func myersBacktrack(a, b []string, trace [][]int, steps int) []DiffLine {
+	var reversed []DiffLine
+	x, y := len(a), len(b)
+	for d := steps; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Op: DiffEqual, A: x, B: y, Text: a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffLine{Op: DiffInsert, B: y, Text: b[y-1]})
+		} else {
+			reversed = append(reversed, DiffLine{Op: DiffDelete, A: x, Text: a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffLine{Op: DiffEqual, A: x, B: y, Text: a[x-1]})
+		x--
+		y--
+	}
+
+	out := make([]DiffLine, len(reversed))
+	for i, l := range reversed {
+		out[len(reversed)-1-i] = l
+	}
+	return out
+}
+
+// DiffHunks 将差异按上下文行数合并为块，相邻块之间的相同行不超过 2*context 时合并
+func DiffHunks(lines []DiffLine, context int) []DiffHunk {
+	var hunks []DiffHunk
+	start, end := -1, -1 // 当前块覆盖的 lines 下标范围 [start, end)
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		h := DiffHunk{Lines: lines[start:end]}
+		for _, l := range h.Lines {
+			if l.Op != DiffInsert {
+				if h.AStart == 0 {
+					h.AStart = l.A
+				}
+				h.ALines++
+			}
+			if l.Op != DiffDelete {
+				if h.BStart == 0 {
+					h.BStart = l.B
+				}
+				h.BLines++
+			}
+		}
+		hunks = append(hunks, h)
+		start, end = -1, -1
+	}
+
+	for i, l := range lines {
+		if l.Op == DiffEqual {
+			continue
+		}
+		from, to := max(i-context, 0), min(i+context+1, len(lines))
+		if start >= 0 && from > end {
+			flush()
+		}
+		if start < 0 {
+			start = from
+		}
+		end = max(end, to)
+	}
+	flush()
+	return hunks
+}
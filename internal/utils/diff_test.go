@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// rebuild 从差异中还原两侧内容
+func rebuild(lines []DiffLine) (a, b []string) {
+	for _, l := range lines {
+		if l.Op != DiffInsert {
+			a = append(a, l.Text)
+		}
+		if l.Op != DiffDelete {
+			b = append(b, l.Text)
+		}
+	}
+	return
+}
+
+func countEdits(lines []DiffLine) int {
+	n := 0
+	for _, l := range lines {
+		if l.Op != DiffEqual {
+			n++
+		}
+	}
+	return n
+}
+
+// lcsLen 动态规划计算最长公共子序列长度，用于校验编辑序列最短
+func lcsLen(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				dp[i][j] = dp[i-1][j-1] + 1
+			} else {
+				dp[i][j] = max(dp[i-1][j], dp[i][j-1])
+			}
+		}
+	}
+	return dp[len(a)][len(b)]
+}
+
+func TestDiffLines_Minimal(t *testing.T) {
+	a := strings.Split("A B C A B B A", " ")
+	b := strings.Split("C B A B A C", " ")
+	lines, complete := DiffLines(a, b, 0)
+	if !complete || countEdits(lines) != 5 {
+		t.Fatalf("expected 5 edits, got %d: %+v", countEdits(lines), lines)
+	}
+	gotA, gotB := rebuild(lines)
+	if strings.Join(gotA, " ") != strings.Join(a, " ") || strings.Join(gotB, " ") != strings.Join(b, " ") {
+		t.Fatalf("rebuild mismatch: %v / %v", gotA, gotB)
+	}
+}
+
+func TestDiffLines_Random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	gen := func() []string {
+		out := make([]string, r.Intn(30))
+		for i := range out {
+			out[i] = string(rune('a' + r.Intn(4)))
+		}
+		return out
+	}
+	for i := 0; i < 500; i++ {
+		a, b := gen(), gen()
+		lines, _ := DiffLines(a, b, 0)
+		gotA, gotB := rebuild(lines)
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("rebuild mismatch for %v -> %v", a, b)
+		}
+		if countEdits(lines) != len(a)+len(b)-2*lcsLen(a, b) {
+			t.Fatalf("edit script not minimal for %v -> %v", a, b)
+		}
+		for _, l := range lines {
+			if (l.Op != DiffInsert && a[l.A-1] != l.Text) || (l.Op != DiffDelete && b[l.B-1] != l.Text) {
+				t.Fatalf("wrong line number in %+v", l)
+			}
+		}
+	}
+}
+
+func TestDiffLines_MaxEdits(t *testing.T) {
+	a := []string{"head", "1", "2", "3", "tail"}
+	b := []string{"head", "x", "y", "z", "tail"}
+	lines, complete := DiffLines(a, b, 2)
+	if complete || countEdits(lines) != 6 || lines[0].Op != DiffEqual || lines[len(lines)-1].Text != "tail" {
+		t.Fatalf("expected fallback replacement, got %+v", lines)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		a = append(a, string(rune('a'+i)))
+	}
+	b = append(b, a...)
+	b[2] = "X"                    // 第 3 行修改
+	b = append(b[:15], b[16:]...) // 删除第 16 行
+	lines, _ := DiffLines(a, b, 0)
+	hunks := DiffHunks(lines, 2)
+	if len(hunks) != 2 {
+		t.Fatalf("expected 2 hunks, got %d", len(hunks))
+	}
+	if h := hunks[0]; h.AStart != 1 || h.ALines != 5 || h.BStart != 1 || h.BLines != 5 {
+		t.Fatalf("unexpected first hunk: %+v", h)
+	}
+	if h := hunks[1]; h.AStart != 14 || h.ALines != 5 || h.BStart != 14 || h.BLines != 4 {
+		t.Fatalf("unexpected second hunk: %+v", h)
+	}
+	if len(DiffHunks(lines, 10)) != 1 {
+		t.Fatal("hunks with overlapping context should merge")
+	}
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"bufio"
 	"io"
+	"regexp"
 	"unicode/utf8"
 
 	"golang.org/x/text/encoding/simplifiedchinese"
@@ -60,3 +61,10 @@ func TrimLastRunes(s string, maxRunes int) string {
 	}
 	return s
 }
+
+var ansiRegexp = regexp.MustCompile(`[\x1b\x9b][\[()#;?]*([0-9]{1,4}(;[0-9]{0,4})*)?[0-9A-ORZcf-nqry=><]`)
+
+// StripAnsi 移除字符串中的 ANSI 转义码（如颜色代码）
+func StripAnsi(str string) string {
+	return ansiRegexp.ReplaceAllString(str, "")
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 日志行来源
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+	LogStreamSystem = "system" // 面板自身写入的提示，如重试、截断说明
+)
+
+// LogLine 带相对时间与来源的一行日志
+type LogLine struct {
+	Offset int64  `json:"t"` // 相对运行开始的毫秒数
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// LineMeta 一行日志的元数据，Size 为该行在正文中的字节数（含换行符）
+type LineMeta struct {
+	Offset int64
+	Stream string
+	Size   int
+}
+
+var lineStreamCodes = map[string]byte{LogStreamStdout: 'o', LogStreamStderr: 'e', LogStreamSystem: 's'}
+
+func lineStreamName(code byte) string {
+	for name, c := range lineStreamCodes {
+		if c == code {
+			return name
+		}
+	}
+	return ""
+}
+
+// EncodeLineMeta 将行元数据编码为紧凑的文本帧后压缩，每帧一行「距上一行的毫秒增量 + 来源代码 + 字节数」，如 "0o12\n350e40\n"
+func EncodeLineMeta(metas []LineMeta) (string, error) {
+	if len(metas) == 0 {
+		return "", nil
+	}
+	var b strings.Builder
+	var last int64
+	for _, m := range metas {
+		code, ok := lineStreamCodes[m.Stream]
+		if !ok {
+			code = lineStreamCodes[LogStreamStdout]
+		}
+		b.WriteString(strconv.FormatInt(m.Offset-last, 10))
+		b.WriteByte(code)
+		b.WriteString(strconv.Itoa(m.Size))
+		b.WriteByte('\n')
+		last = m.Offset
+	}
+	return CompressToBase64(b.String())
+}
+
+// DecodeLineMeta 解码 EncodeLineMeta 的结果
+func DecodeLineMeta(data string) ([]LineMeta, error) {
+	if data == "" {
+		return nil, nil
+	}
+	text, err := DecompressFromBase64(data)
+	if err != nil {
+		return nil, err
+	}
+	var metas []LineMeta
+	var offset int64
+	for i, frame := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		pos := strings.IndexAny(frame, "oes")
+		if pos <= 0 {
+			return nil, fmt.Errorf("行元数据第 %d 帧格式错误", i+1)
+		}
+		delta, err1 := strconv.ParseInt(frame[:pos], 10, 64)
+		size, err2 := strconv.Atoi(frame[pos+1:])
+		if err1 != nil || err2 != nil || size < 0 {
+			return nil, fmt.Errorf("行元数据第 %d 帧格式错误", i+1)
+		}
+		offset += delta
+		metas = append(metas, LineMeta{Offset: offset, Stream: lineStreamName(frame[pos]), Size: size})
+	}
+	return metas, nil
+}
+
+// SplitLogLines 按行元数据将日志正文拆分为带时间与来源的行
+// 正文可能在开头被截断，因此从末尾开始对齐；元数据未覆盖的开头部分视为面板提示
+func SplitLogLines(output string, metas []LineMeta) []LogLine {
+	if output == "" || len(metas) == 0 {
+		return nil
+	}
+	var reversed []LogLine
+	end := len(output)
+	i := len(metas) - 1
+	for ; i >= 0 && end > 0; i-- {
+		start := max(end-metas[i].Size, 0)
+		// 一条元数据只对应一行，被截断的首行不能越过前面的换行符
+		if k := strings.LastIndexByte(strings.TrimSuffix(output[start:end], "\n"), '\n'); k >= 0 {
+			start += k + 1
+		}
+		reversed = append(reversed, LogLine{Offset: metas[i].Offset, Stream: metas[i].Stream, Text: trimLineBreak(output[start:end])})
+		end = start
+	}
+
+	var lines []LogLine
+	if end > 0 {
+		var first int64
+		if i+1 < len(metas) {
+			first = metas[i+1].Offset
+		}
+		for _, text := range strings.SplitAfter(output[:end], "\n") {
+			if text != "" {
+				lines = append(lines, LogLine{Offset: first, Stream: LogStreamSystem, Text: trimLineBreak(text)})
+			}
+		}
+	}
+	for j := len(reversed) - 1; j >= 0; j-- {
+		lines = append(lines, reversed[j])
+	}
+	return lines
+}
+
+func trimLineBreak(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineMetaRoundTrip(t *testing.T) {
+	metas := []LineMeta{
+		{Offset: 0, Stream: LogStreamSystem, Size: 10},
+		{Offset: 15, Stream: LogStreamStdout, Size: 6},
+		{Offset: 3200, Stream: LogStreamStderr, Size: 1},
+		{Offset: 3200, Stream: LogStreamStdout, Size: 0},
+	}
+	encoded, err := EncodeLineMeta(metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeLineMeta(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, metas) {
+		t.Errorf("decoded = %+v, want %+v", decoded, metas)
+	}
+
+	// 大量行使用 zstd 压缩
+	many := make([]LineMeta, 5000)
+	for i := range many {
+		many[i] = LineMeta{Offset: int64(i * 20), Stream: LogStreamStdout, Size: 30}
+	}
+	encoded, _ = EncodeLineMeta(many)
+	if !strings.HasPrefix(encoded, "zstd:") || len(encoded) > 5000 {
+		t.Errorf("expected compact zstd frames, got %d bytes", len(encoded))
+	}
+	if decoded, _ := DecodeLineMeta(encoded); !reflect.DeepEqual(decoded, many) {
+		t.Error("large round trip mismatch")
+	}
+
+	if _, err := DecodeLineMeta("raw:12x3\n"); err == nil {
+		t.Error("malformed frames should fail")
+	}
+}
+
+func TestSplitLogLines(t *testing.T) {
+	output := "start\nwarn: disk\r\nprogress 50%\rprogress 100%\ndone"
+	metas := []LineMeta{
+		{Offset: 0, Stream: LogStreamStdout, Size: 6},
+		{Offset: 120, Stream: LogStreamStderr, Size: 12},
+		{Offset: 5000, Stream: LogStreamStdout, Size: 27},
+		{Offset: 9000, Stream: LogStreamStdout, Size: 4},
+	}
+	want := []LogLine{
+		{0, LogStreamStdout, "start"},
+		{120, LogStreamStderr, "warn: disk"},
+		{5000, LogStreamStdout, "progress 50%\rprogress 100%"},
+		{9000, LogStreamStdout, "done"},
+	}
+	if got := SplitLogLines(output, metas); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitLogLines =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// 正文开头被截断并插入提示时，从末尾对齐
+	truncated := "[System] 已截断\nrt\nwarn: disk\r\n"
+	got := SplitLogLines(truncated, metas[:2])
+	want = []LogLine{
+		{0, LogStreamSystem, "[System] 已截断"},
+		{0, LogStreamStdout, "rt"},
+		{120, LogStreamStderr, "warn: disk"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("truncated SplitLogLines =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if SplitLogLines(output, nil) != nil {
+		t.Error("logs without metadata should return nil")
+	}
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// MaskText 机密在日志中的替换文本
+const MaskText = "********"
+
+// minVariantLen 编码变体的最小长度，过短的变体容易误伤正常输出，不参与脱敏
+const minVariantLen = 6
+
+// SecretVariants 返回机密本身及其常见编码形式：URL 编码、JSON 转义、十六进制与 base64
+// base64 额外按 0~2 字节偏移计算稳定片段，以覆盖机密出现在更长内容中再整体编码的情况（如 Basic 认证头）
+func SecretVariants(secret string) []string {
+	if secret == "" {
+		return nil
+	}
+	seen := map[string]bool{secret: true}
+	variants := []string{secret}
+	add := func(v string) {
+		if len(v) >= minVariantLen && !seen[v] {
+			seen[v] = true
+			variants = append(variants, v)
+		}
+	}
+
+	add(url.QueryEscape(secret))
+	add(url.PathEscape(secret))
+	if b, err := json.Marshal(secret); err == nil {
+		escaped := string(b[1 : len(b)-1])
+		add(escaped)
+		add(strings.ReplaceAll(escaped, "/", `\/`)) // 部分 JSON 编码器会转义斜杠
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if enc.Encode(secret) == nil {
+		quoted := strings.TrimSuffix(buf.String(), "\n")
+		add(quoted[1 : len(quoted)-1])
+	}
+	add(hex.EncodeToString([]byte(secret)))
+	add(strings.ToUpper(hex.EncodeToString([]byte(secret))))
+
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+		for offset := 0; offset < 3; offset++ {
+			data := append(make([]byte, offset), secret...)
+			encoded := encoding.EncodeToString(data)
+			// 前面 offset 个字节影响的字符与末尾不完整的字符取决于上下文，只保留稳定的中间片段
+			start := (offset*8 + 5) / 6
+			end := len(data) * 8 / 6
+			if end > start {
+				add(encoded[start:end])
+			}
+		}
+		add(encoding.EncodeToString([]byte(secret)))
+	}
+	return variants
+}
+
+// SecretMasker 将机密及其编码变体替换为 MaskText，按最左最长原则匹配
+type SecretMasker struct {
+	variants []string
+	index    [256][]int // 首字节 -> 以该字节开头的变体下标（已按长度倒序）
+}
+
+// NewSecretMasker 根据机密列表构建脱敏器，空机密会被忽略
+func NewSecretMasker(secrets []string) *SecretMasker {
+	m := &SecretMasker{}
+	seen := make(map[string]bool)
+	for _, secret := range secrets {
+		for _, v := range SecretVariants(secret) {
+			if !seen[v] {
+				seen[v] = true
+				m.variants = append(m.variants, v)
+			}
+		}
+	}
+	sort.SliceStable(m.variants, func(i, j int) bool { return len(m.variants[i]) > len(m.variants[j]) })
+	for i, v := range m.variants {
+		m.index[v[0]] = append(m.index[v[0]], i)
+	}
+	return m
+}
+
+// Empty 是否没有任何需要脱敏的内容
+func (m *SecretMasker) Empty() bool {
+	return m == nil || len(m.variants) == 0
+}
+
+// Mask 对完整文本脱敏
+func (m *SecretMasker) Mask(text string) string {
+	if m.Empty() || text == "" {
+		return text
+	}
+	out, _ := m.scan([]byte(text), true)
+	return string(out)
+}
+
+// scan 扫描 data 并替换其中的机密；final 为 false 时，末尾可能是某个机密开头的部分不输出，
+// 通过返回值 rest 交给调用方留到下次与新数据拼接后再判断
+func (m *SecretMasker) scan(data []byte, final bool) (out []byte, rest []byte) {
+	out = make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		candidates := m.index[data[i]]
+		if len(candidates) == 0 {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+		remaining := data[i:]
+		matched := 0
+		for _, idx := range candidates {
+			v := m.variants[idx]
+			if len(v) > len(remaining) {
+				// 剩余数据不足以判断，可能是机密的前半段
+				if !final && strings.HasPrefix(v, string(remaining)) {
+					return out, remaining
+				}
+				continue
+			}
+			if string(remaining[:len(v)]) == v {
+				matched = len(v)
+				break
+			}
+		}
+		if matched > 0 {
+			out = append(out, MaskText...)
+			i += matched
+		} else {
+			out = append(out, data[i])
+			i++
+		}
+	}
+	return out, nil
+}
+
+// MaskStream 流式脱敏，机密被拆分到多次写入时仍能识别
+type MaskStream struct {
+	masker  *SecretMasker
+	pending []byte
+}
+
+// NewMaskStream 创建流式脱敏器
+func NewMaskStream(masker *SecretMasker) *MaskStream {
+	return &MaskStream{masker: masker}
+}
+
+// Write 写入一段数据，返回可以安全输出的脱敏内容；可能构成机密开头的末尾部分暂存到下次写入
+func (s *MaskStream) Write(p []byte) []byte {
+	if s.masker.Empty() {
+		return p
+	}
+	data := p
+	if len(s.pending) > 0 {
+		data = append(s.pending, p...)
+	}
+	out, rest := s.masker.scan(data, false)
+	s.pending = append([]byte(nil), rest...)
+	return out
+}
+
+// Flush 输出暂存的剩余数据，在数据流结束时调用
+func (s *MaskStream) Flush() []byte {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	out, _ := s.masker.scan(s.pending, true)
+	s.pending = nil
+	return out
+}
+
+// SetMasker 替换脱敏规则，已暂存的数据按新规则继续处理
+func (s *MaskStream) SetMasker(masker *SecretMasker) {
+	s.masker = masker
+}
+
+// Pending 暂存未输出的字节数
+func (s *MaskStream) Pending() int {
+	return len(s.pending)
+}
+
+// MaskSecrets 将文本中的所有敏感机密值及其常见编码形式替换为脱敏字符串 "********"
+func MaskSecrets(text string, secrets []string) string {
+	if len(secrets) == 0 || text == "" {
+		return text
+	}
+	return NewSecretMasker(secrets).Mask(text)
+}
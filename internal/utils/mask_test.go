@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMaskSecretsEncodings(t *testing.T) {
+	secret := "p@ss/w0rd+key"
+	basic := base64.StdEncoding.EncodeToString([]byte("admin:" + secret))
+	cases := []string{
+		"plain " + secret,
+		"query ?token=" + url.QueryEscape(secret) + "&x=1",
+		"path /" + url.PathEscape(secret) + "/",
+		"hex " + hex.EncodeToString([]byte(secret)),
+		"b64 " + base64.StdEncoding.EncodeToString([]byte(secret)),
+		"b64url " + base64.URLEncoding.EncodeToString([]byte(secret)),
+		"Authorization: Basic " + basic,
+		`json {"password":"` + strings.ReplaceAll(secret, "/", `\/`) + `"}`,
+	}
+	for _, text := range cases {
+		got := MaskSecrets(text, []string{secret})
+		if !strings.Contains(got, MaskText) {
+			t.Errorf("%q was not masked: %q", text, got)
+		}
+		for _, v := range SecretVariants(secret) {
+			if strings.Contains(got, v) {
+				t.Errorf("%q still contains variant %q: %q", text, v, got)
+			}
+		}
+	}
+	// 机密解码出的 Basic 认证头不应保留可还原机密的片段
+	if got := MaskSecrets(basic, []string{secret}); len(strings.ReplaceAll(got, MaskText, "")) > 12 {
+		t.Errorf("basic auth header leaks too much: %q", got)
+	}
+	// 短变体不参与脱敏，避免误伤
+	if got := MaskSecrets("ab 6162 YWI=", []string{"ab"}); got != "******** 6162 YWI=" {
+		t.Errorf("unexpected short secret masking: %q", got)
+	}
+}
+
+func TestMaskStreamSplitWrites(t *testing.T) {
+	secret := "line1-secret\nline2-secret"
+	stream := NewMaskStream(NewSecretMasker([]string{secret}))
+	var out []byte
+	for _, chunk := range []string{"before line1-sec", "ret\nline2", "-secret after\n", "line1"} {
+		out = append(out, stream.Write([]byte(chunk))...)
+	}
+	if stream.Pending() != len("line1") {
+		t.Errorf("expected trailing prefix to be held back, pending=%d", stream.Pending())
+	}
+	out = append(out, stream.Flush()...)
+	if want := "before ******** after\nline1"; string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+// maskByChunks 按固定大小分块流式脱敏
+func maskByChunks(masker *SecretMasker, input string, size int) string {
+	stream := NewMaskStream(masker)
+	var out []byte
+	for i := 0; i < len(input); i += size {
+		end := min(i+size, len(input))
+		out = append(out, stream.Write([]byte(input[i:end]))...)
+	}
+	return string(append(out, stream.Flush()...))
+}
+
+func FuzzMaskStream(f *testing.F) {
+	f.Add("s3cr3t-token", "log line\n", uint8(3), uint8(0))
+	f.Add("p@ss word/+", "Authorization: Basic ", uint8(1), uint8(9))
+	f.Add("多字节机密", "输出：", uint8(2), uint8(4))
+	f.Add("aaaa", "aaa", uint8(5), uint8(1))
+	f.Fuzz(func(t *testing.T, secret, text string, chunk, variant uint8) {
+		// 机密含 * 时会与替换文本本身混淆，不在性质测试范围内
+		if secret == "" || strings.Contains(secret, "*") {
+			t.Skip()
+		}
+		variants := SecretVariants(secret)
+		v := variants[int(variant)%len(variants)]
+		input := text + v + text + secret + text
+
+		masker := NewSecretMasker([]string{secret})
+		want := masker.Mask(input)
+		for _, v := range variants {
+			if strings.Contains(want, v) {
+				t.Fatalf("masked output %q contains %q", want, v)
+			}
+		}
+		if got := maskByChunks(masker, input, int(chunk)%17+1); got != want {
+			t.Fatalf("streaming output differs:\n got %q\nwant %q", got, want)
+		}
+	})
+}
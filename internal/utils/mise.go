@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os/exec"
@@ -8,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 var nodePathCache sync.Map
@@ -155,3 +157,21 @@ func ListMiseInstalledVersions(language string) ([]string, error) {
 	}
 	return versions, nil
 }
+
+// ResolveMiseVersion 解析语言版本别名（如 latest、3.11）对应的实际安装版本，无法解析时返回空字符串
+func ResolveMiseVersion(language, version string) string {
+	if version == "" {
+		version = "latest"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "mise", "where", language+"@"+version).Output()
+	if err != nil {
+		return ""
+	}
+	dir := strings.TrimSpace(string(out))
+	if dir == "" {
+		return ""
+	}
+	return filepath.Base(dir)
+}
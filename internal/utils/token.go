@@ -7,20 +7,32 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// 各类凭证的用途，写入 aud 声明并在解析时校验，防止同一密钥签发的凭证被挪作他用
+const (
+	TokenAudienceLogin         = "baihu:login"          // 登录凭证
+	TokenAudienceOtpPending    = "baihu:otp_pending"    // 两步验证临时凭证
+	TokenAudienceTrustedDevice = "baihu:trusted_device" // 受信任设备凭证
+	TokenAudienceWebAuthn      = "baihu:webauthn"       // 通行密钥挑战会话
+	TokenAudienceOIDCState     = "baihu:oidc_state"     // OIDC 跳转状态
+)
+
 type Claims struct {
 	UserID       string `json:"user_id"`
 	Username     string `json:"username"`
 	TokenVersion int    `json:"version"`
+	SessionID    string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成 JWT token
-func GenerateToken(userID string, username string, version int, expireDays int, secret string) (string, error) {
+// GenerateToken 生成 JWT token，sessionID 对应服务端登录会话
+func GenerateToken(userID string, username string, version int, sessionID string, expireDays int, secret string) (string, error) {
 	claims := Claims{
 		UserID:       userID,
 		Username:     username,
 		TokenVersion: version,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{TokenAudienceLogin},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expireDays) * 24 * time.Hour)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -31,24 +43,24 @@ func GenerateToken(userID string, username string, version int, expireDays int,
 }
 
 // ParseToken 解析 JWT token
-func ParseToken(tokenString string, secret string) (string, string, int, error) {
+func ParseToken(tokenString string, secret string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
 		// 校验算法
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(secret), nil
-	})
+	}, jwt.WithAudience(TokenAudienceLogin))
 
 	if err != nil {
-		return "", "", 0, err
+		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, claims.Username, claims.TokenVersion, nil
+		return claims, nil
 	}
 
-	return "", "", 0, errors.New("invalid token")
+	return nil, errors.New("invalid token")
 }
 
 // OtpPendingClaims 用于两步验证第一阶段和第二阶段之间传递的临时状态
@@ -62,6 +74,7 @@ func GenerateOtpPendingToken(userID string, secret string) (string, error) {
 	claims := OtpPendingClaims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{TokenAudienceOtpPending},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -77,7 +90,7 @@ func ParseOtpPendingToken(tokenString string, secret string) (string, error) {
 			return nil, errors.New("unexpected signing method")
 		}
 		return []byte(secret), nil
-	})
+	}, jwt.WithAudience(TokenAudienceOtpPending))
 	if err != nil {
 		return "", err
 	}
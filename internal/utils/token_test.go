@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestTokenAudience(t *testing.T) {
+	const secret = "test-secret"
+	login, err := GenerateToken("u1", "alice", 1, "s1", 1, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := GenerateOtpPendingToken("u1", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims, err := ParseToken(login, secret); err != nil || claims.UserID != "u1" || claims.SessionID != "s1" {
+		t.Errorf("ParseToken(login) = %+v, %v", claims, err)
+	}
+	if userID, err := ParseOtpPendingToken(pending, secret); err != nil || userID != "u1" {
+		t.Errorf("ParseOtpPendingToken(pending) = %q, %v", userID, err)
+	}
+
+	// 不同用途的凭证不能互相冒用
+	if _, err := ParseToken(pending, secret); err == nil {
+		t.Error("otp pending token must not be accepted as a login token")
+	}
+	if _, err := ParseOtpPendingToken(login, secret); err == nil {
+		t.Error("login token must not be accepted as an otp pending token")
+	}
+}
@@ -133,19 +133,39 @@ export async function checkAuth(): Promise<boolean> {
 export const api = {
   auth: {
     login: (data: { username: string; password: string }) =>
-      request<{ user: string; require_otp?: boolean; otp_pending_token?: string }>('/auth/login', { method: 'POST', body: JSON.stringify(data) }),
-    loginOtp: (data: { otp_pending_token: string; code: string }) =>
+      request<{ user: string; require_otp?: boolean; otp_pending_token?: string; methods?: string[]; trust_device_days?: number }>('/auth/login', { method: 'POST', body: JSON.stringify(data) }),
+    loginOtp: (data: { otp_pending_token: string; code?: string; recovery_code?: string; trust_device?: boolean }) =>
       request<{ user: string }>('/auth/login/otp', { method: 'POST', body: JSON.stringify(data) }),
+    webauthnLoginBegin: (data: { otp_pending_token?: string }) =>
+      request<{ options: any; session: string }>('/auth/webauthn/login/begin', { method: 'POST', body: JSON.stringify(data) }),
+    webauthnLoginFinish: (data: { otp_pending_token?: string; session: string; credential: any; trust_device?: boolean }) =>
+      request<{ user: string }>('/auth/webauthn/login/finish', { method: 'POST', body: JSON.stringify(data) }),
     logout: () => request('/auth/logout', { method: 'POST' }),
-    me: () => request<{ username: string; role: string }>('/auth/me'),
+    oidcLoginUrl: () => `${API_BASE_URL}/auth/oidc/login`,
+    me: () => request<{ username: string; role: string; scopes?: string }>('/auth/me'),
     register: (data: { username: string; password: string; email: string }) =>
       request('/auth/register', { method: 'POST', body: JSON.stringify(data) }),
-    getOtpStatus: () => request<{ otp_enabled: boolean }>('/auth/otp/status'),
+    getOtpStatus: () => request<{ otp_enabled: boolean; webauthn_count: number; recovery_codes_remaining: number; trusted_device_days: number }>('/auth/otp/status'),
     generateOtp: () => request<{ secret: string; url: string }>('/auth/otp/generate', { method: 'POST' }),
     enableOtp: (data: { secret: string; code: string }) =>
-      request<void>('/auth/otp/enable', { method: 'POST', body: JSON.stringify(data) }),
-    disableOtp: (data: { code: string }) =>
-      request<void>('/auth/otp/disable', { method: 'POST', body: JSON.stringify(data) })
+      request<{ recovery_codes: string[] }>('/auth/otp/enable', { method: 'POST', body: JSON.stringify(data) }),
+    disableOtp: (data: { code?: string; recovery_code?: string }) =>
+      request<void>('/auth/otp/disable', { method: 'POST', body: JSON.stringify(data) }),
+    regenerateRecoveryCodes: () =>
+      request<{ recovery_codes: string[] }>('/auth/otp/recovery-codes', { method: 'POST' }),
+    listPasskeys: () => request<PasskeyItem[]>('/auth/webauthn/credentials'),
+    deletePasskey: (id: string) => request<void>(`/auth/webauthn/credentials/${id}`, { method: 'DELETE' }),
+    passkeyRegisterBegin: () =>
+      request<{ options: any; session: string }>('/auth/webauthn/register/begin', { method: 'POST' }),
+    passkeyRegisterFinish: (data: { session: string; name: string; credential: any }) =>
+      request<{ credential: PasskeyItem; recovery_codes?: string[] | null }>('/auth/webauthn/register/finish', { method: 'POST', body: JSON.stringify(data) }),
+    revokeTrustedDevices: () => request<void>('/auth/trusted-devices/revoke', { method: 'POST' }),
+    listSessions: () => request<SessionItem[]>('/auth/sessions'),
+    revokeSession: (id: string) => request<void>(`/auth/sessions/${id}`, { method: 'DELETE' }),
+    revokeOtherSessions: () => request<{ count: number }>('/auth/sessions/revoke-others', { method: 'POST' }),
+    getSsoLink: () => request<{ enabled: boolean; linked: boolean }>('/auth/oidc/link'),
+    beginSsoLink: () => request<{ url: string }>('/auth/oidc/link', { method: 'POST' }),
+    unlinkSso: () => request<void>('/auth/oidc/link', { method: 'DELETE' })
   },
   tasks: {
     list: (params?: { page?: number; page_size?: number; name?: string; agent_id?: string; tags?: string; type?: string; sort_by?: string; order?: string }) => {
@@ -201,6 +221,9 @@ export const api = {
     secretStatus: () => request<boolean>('/env/secret-status'),
     all: () => request<EnvVar[]>('/env/all'),
     tasks: (id: string) => request<Task[]>(`/env/${id}/tasks`),
+    versions: (id: string) => request<EnvVersion[]>(`/env/${id}/versions`),
+    revert: (id: string, versionId: string) => request<EnvVar>(`/env/${id}/versions/${versionId}/revert`, { method: 'POST' }),
+    usage: (id: string) => request<EnvUsage[]>(`/env/${id}/usage`),
     create: (data: Partial<EnvVar>) => request<EnvVar>('/env', { method: 'POST', body: JSON.stringify(data) }),
     update: (id: string, data: Partial<EnvVar>) => request<EnvVar>(`/env/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
     delete: (id: string, force?: boolean) => {
@@ -240,8 +263,27 @@ export const api = {
     },
     get: (id: string) => request<LogDetail>(`/logs/${id}`),
     detail: (id: string) => request<LogDetail>(`/logs/${id}`),
+    lines: (id: string) => request<LogDetail>(`/logs/${id}?lines=1`),
+    diff: (params: { base: string; target: string; ignore?: string[]; ansi?: boolean; context?: number }) => {
+      const query = new URLSearchParams({ base: params.base, target: params.target })
+      if (params.ignore) {
+        if (params.ignore.length) params.ignore.forEach(expr => query.append('ignore', expr))
+        else query.set('ignore', '')
+      }
+      if (params.ansi) query.set('ansi', '1')
+      if (params.context) query.set('context', String(params.context))
+      return request<LogDiffResult>(`/logs/diff?${query}`)
+    },
     delete: (id: string) => request(`/logs/${id}`, { method: 'DELETE' }),
-    clear: (taskId?: string) => request('/logs/clear', { method: 'POST', body: JSON.stringify({ task_id: taskId }) })
+    clear: (taskId?: string) => request('/logs/clear', { method: 'POST', body: JSON.stringify({ task_id: taskId }) }),
+    search: (params: { q: string; task_id?: string; status?: string; since?: string; until?: string; before?: string; limit?: number }) => {
+      const query = new URLSearchParams()
+      for (const [key, value] of Object.entries(params)) {
+        if (value !== undefined && value !== '') query.set(key, String(value))
+      }
+      return request<LogSearchResult>(`/logs/search?${query}`)
+    },
+    reindex: () => request('/logs/search/reindex', { method: 'POST' })
   },
   dashboard: {
     stats: () => request<Stats>('/stats'),
@@ -254,7 +296,10 @@ export const api = {
     changePassword: (data: { old_username?: string; username?: string; old_password: string; new_password?: string }) =>
       request('/settings/password', { method: 'POST', body: JSON.stringify(data) }),
     getSite: () => request<SiteSettings>('/settings/site'),
-    getPublicSite: () => request<{ title: string; subtitle: string; icon: string; demo_mode: boolean }>('/settings/public'),
+    getPublicSite: () => request<{
+      title: string; subtitle: string; icon: string; demo_mode: boolean
+      sso_enabled?: boolean; sso_button_text?: string; password_login_disabled?: boolean
+    }>('/settings/public'),
     updateSite: (data: SiteSettings) =>
       request('/settings/site', { method: 'PUT', body: JSON.stringify(data) }),
     generateOpenapiToken: () => request<{ token: string }>('/settings/site/openapi-token/generate', { method: 'POST' }),
@@ -270,6 +315,11 @@ export const api = {
       request(`/settings/${section}`, { method: 'PUT', body: JSON.stringify(values) }),
     generateToken: (section: string, key: string) =>
       request<string>(`/settings/${section}/${key}/generate`, { method: 'POST' }),
+    testOidc: () => request<{ redirect_url: string }>('/settings/oidc/test', { method: 'POST' }),
+    testSecretRef: (ref: string) =>
+      request<{ length: number }>('/settings/secret_backend/test', { method: 'POST', body: JSON.stringify({ ref }) }),
+    getBannedIPs: () => request<IPBan[]>('/settings/access/bans'),
+    unbanIP: (ip: string) => request<void>(`/settings/access/bans/${encodeURIComponent(ip)}`, { method: 'DELETE' }),
     getLoginLogs: (params?: { page?: number; page_size?: number; username?: string }) => {
       const query = new URLSearchParams()
       if (params?.page) query.set('page', String(params.page))
@@ -277,23 +327,41 @@ export const api = {
       if (params?.username) query.set('username', params.username)
       return request<LoginLogListResponse>(`/settings/loginlogs?${query}`)
     },
-    createBackup: () => request('/settings/backup', { method: 'POST' }),
+    createBackup: (passphrase = '') =>
+      request('/settings/backup', { method: 'POST', body: JSON.stringify({ passphrase }) }),
     getBackupStatus: () => request<{ has_backup: boolean; backup_time: string }>('/settings/backup/status'),
     downloadBackup: () => `${API_BASE_URL}/settings/backup/download`,
-    restoreBackup: async (file: File) => {
+    runBackup: () => request<BackupRunResult>('/settings/backup/run', { method: 'POST' }),
+    testBackupTarget: (values: Record<string, string>) =>
+      request<BackupTargetTest>('/settings/backup/test', { method: 'POST', body: JSON.stringify(values) }),
+    listRemoteBackups: () => request<RemoteBackup[]>('/settings/backup/remote'),
+    restoreRemoteBackup: (name: string, passphrase = '', dryRun = false, plan?: RestorePlan) =>
+      request<RestorePreview | null>('/settings/backup/remote/restore', {
+        method: 'POST',
+        body: JSON.stringify({ name, passphrase, plan, dry_run: dryRun })
+      }),
+    deleteRemoteBackup: (name: string) =>
+      request(`/settings/backup/remote/${encodeURIComponent(name)}`, { method: 'DELETE' }),
+    gitopsPlan: () => request<GitOpsReport>('/settings/gitops/plan', { method: 'POST' }),
+    gitopsApply: () => request<GitOpsReport>('/settings/gitops/apply', { method: 'POST' }),
+    restoreBackup: async (file: File, passphrase = '', dryRun = false, plan?: RestorePlan) => {
       const formData = new FormData()
       formData.append('file', file)
+      formData.append('passphrase', passphrase)
+      if (plan) formData.append('plan', JSON.stringify(plan))
+      if (dryRun) formData.append('dry_run', '1')
       const res = await fetch(`${API_BASE_URL}/settings/restore`, {
         method: 'POST',
         credentials: 'include',
         body: formData
       })
-      const json: ApiResponse<null> = await res.json()
+      const json: ApiResponse<RestorePreview | null> = await res.json()
       if (json.code === 401) {
         window.location.href = BASE_URL + '/login'
         throw new Error('请先登录')
       }
       if (json.code !== 200) throw new Error(json.msg || '恢复失败')
+      return json.data
     }
   },
   files: {
@@ -472,7 +540,269 @@ export const api = {
   },
   system: {
     export: (data: { task_ids?: string[], env_ids?: string[] }) => request<any>('/system/export', { method: 'POST', body: JSON.stringify(data) })
+  },
+  users: {
+    list: () => request<{ users: UserItem[]; roles: string[] }>('/users'),
+    create: (data: { username: string; password: string; email?: string; role: string; scopes?: string }) =>
+      request<UserItem>('/users', { method: 'POST', body: JSON.stringify(data) }),
+    update: (id: string, data: { email?: string; role?: string; scopes?: string; disabled?: boolean }) =>
+      request<UserItem>(`/users/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+    resetPassword: (id: string, password?: string) =>
+      request<{ password: string }>(`/users/${id}/reset-password`, { method: 'POST', body: JSON.stringify({ password }) }),
+    sessions: (id: string) => request<SessionItem[]>(`/users/${id}/sessions`),
+    revokeSessions: (id: string, sessionId?: string) =>
+      request<void>(`/users/${id}/sessions${sessionId ? `?session_id=${sessionId}` : ''}`, { method: 'DELETE' })
+  },
+  apiTokens: {
+    list: () => request<{ tokens: ApiTokenItem[]; scopes: string[] }>('/api-tokens'),
+    create: (data: { name: string; scopes: string[]; tags?: string; task_ids?: string; expires_at?: string }) =>
+      request<{ token: string; item: ApiTokenItem }>('/api-tokens', { method: 'POST', body: JSON.stringify(data) }),
+    update: (id: string, enabled: boolean) =>
+      request<{ message: string }>(`/api-tokens/${id}`, { method: 'PUT', body: JSON.stringify({ enabled }) }),
+    delete: (id: string) => request<{ message: string }>(`/api-tokens/${id}`, { method: 'DELETE' }),
+    usage: (id: string, params?: { page?: number; page_size?: number }) => {
+      const query = new URLSearchParams()
+      if (params?.page) query.set('page', String(params.page))
+      if (params?.page_size) query.set('page_size', String(params.page_size))
+      return request<{ data: ApiTokenLogItem[]; total: number; page: number; page_size: number }>(`/api-tokens/${id}/usage?${query}`)
+    }
+  },
+
+  logForwarders: {
+    list: () => request<LogForwarderItem[]>('/log-forwarders'),
+    create: (data: LogForwarderInput) =>
+      request<LogForwarderItem>('/log-forwarders', { method: 'POST', body: JSON.stringify(data) }),
+    update: (id: string, data: LogForwarderInput) =>
+      request<LogForwarderItem>(`/log-forwarders/${id}`, { method: 'PUT', body: JSON.stringify(data) }),
+    delete: (id: string) => request<{ message: string }>(`/log-forwarders/${id}`, { method: 'DELETE' }),
+    test: (id: string) => request<{ message: string }>(`/log-forwarders/${id}/test`, { method: 'POST' })
+  },
+
+  auditLogs: {
+    list: (params?: AuditLogQuery & { page?: number; page_size?: number }) => {
+      const query = new URLSearchParams()
+      Object.entries(params || {}).forEach(([k, v]) => { if (v) query.set(k, String(v)) })
+      return request<{ data: AuditLogItem[]; total: number; page: number; page_size: number }>(`/audit-logs?${query}`)
+    },
+    exportUrl: (params: AuditLogQuery & { format?: 'csv' | 'json' }) => {
+      const query = new URLSearchParams()
+      Object.entries(params).forEach(([k, v]) => { if (v) query.set(k, String(v)) })
+      return `${API_BASE_URL}/audit-logs/export?${query}`
+    }
+  }
+}
+
+export interface UserItem {
+  id: string
+  username: string
+  email: string
+  role: string
+  scopes: string
+  disabled: boolean
+  otp_enabled: boolean
+  sso: boolean
+  created_at: string
+  updated_at: string
+}
+
+export interface PasskeyItem {
+  id: string
+  name: string
+  credential_id: string
+  sign_count: number
+  transports: string
+  last_used_at: string | null
+  created_at: string
+}
+
+export interface RemoteBackup {
+  name: string
+  size: number
+  mod_time: string
+}
+
+export interface RestoreTableChange {
+  file: string
+  current: number
+  incoming: number
+  added: number
+  removed: number
+  replaced: number
+  conflicts: number
+  skipped: number
+  renamed: number
+  kept: boolean
+}
+
+export interface RestorePlan {
+  tables?: string[] | null
+  task_ids?: string[]
+  scripts: boolean
+  script_paths?: string[]
+  mode: 'replace' | 'merge'
+  conflict: 'skip' | 'overwrite' | 'rename'
+}
+
+export interface RestoreTaskItem {
+  id: string
+  name: string
+  exists: boolean
+}
+
+export interface RestorePreview {
+  version: string
+  created_at: string
+  encrypted: boolean
+  verified: boolean
+  mode: string
+  conflict: string
+  tables: RestoreTableChange[]
+  scripts_added: number
+  scripts_updated: number
+  scripts_skipped: number
+  scripts_renamed: number
+  tasks: RestoreTaskItem[] | null
+  script_dirs: string[] | null
+  warnings: string[] | null
+}
+
+export interface BackupTargetTest {
+  target: string
+  host_key?: string
+  files: number
+  error?: string
+}
+
+export interface BackupRunResult {
+  file: string
+  size: number
+  target: string
+  deleted: string[] | null
+}
+
+export interface GitOpsChange {
+  field: string
+  panel: string
+  repo: string
+}
+
+export interface GitOpsPlanItem {
+  kind: 'task' | 'env'
+  name: string
+  action: 'create' | 'update' | 'delete' | 'orphan' | 'unchanged' | 'error'
+  resource_id: string
+  file: string
+  changes: GitOpsChange[] | null
+  drift: boolean
+  adopt: boolean
+  error: string
+}
+
+export interface GitOpsReport {
+  plan: {
+    commit: string
+    prune: boolean
+    items: GitOpsPlanItem[] | null
+    counts: Record<string, number>
+    drift: number
   }
+  result?: {
+    created: number
+    updated: number
+    deleted: number
+    errors: string[] | null
+  }
+}
+
+export interface IPBan {
+  ip: string
+  surface: string
+  reason: string
+  banned_at: string
+  expires_at: string
+}
+
+export interface SessionItem {
+  id: string
+  user_id: string
+  method: string
+  ip: string
+  user_agent: string
+  device: string
+  last_seen_at: string
+  expires_at: string
+  created_at: string
+  current: boolean
+}
+
+export interface ApiTokenItem {
+  id: string
+  name: string
+  prefix: string
+  scopes: string
+  tags: string
+  task_ids: string
+  expires_at: string | null
+  enabled: boolean
+  last_used_at: string | null
+  last_used_ip: string
+  use_count: number
+  created_by: string
+  created_at: string
+}
+
+export interface ApiTokenLogItem {
+  id: string
+  token_id: string
+  method: string
+  path: string
+  status: number
+  ip: string
+  created_at: string
+}
+
+export interface LogForwarderInput {
+  name: string
+  type: 'syslog' | 'loki' | 'http'
+  address: string
+  protocol: string
+  headers: string
+  labels: string
+  sources: string
+  enabled: boolean
+}
+
+export interface LogForwarderItem extends LogForwarderInput {
+  id: string
+  last_error: string
+  last_sent_at: string | null
+  sent_count: number
+  created_at: string
+}
+
+export interface AuditLogQuery {
+  keyword?: string
+  username?: string
+  action?: string
+  resource_type?: string
+  auth_method?: string
+  start?: string
+  end?: string
+}
+
+export interface AuditLogItem {
+  id: string
+  user_id: string
+  username: string
+  auth_method: string
+  token_name: string
+  ip: string
+  action: string
+  resource_type: string
+  resource_id: string
+  resource_name: string
+  diff: string
+  created_at: string
 }
 
 export interface WebUI {
@@ -578,10 +908,36 @@ export interface EnvVar {
   hidden: boolean
   enabled: boolean
   tags: string
+  expires_at?: string | null
   created_at?: string
   updated_at?: string
 }
 
+export interface EnvVersion {
+  id: string
+  env_id: string
+  version: number
+  action: 'create' | 'update' | 'revert' | 'bulk' | 'gitops'
+  name: string
+  value: string
+  remark: string
+  type: string
+  hidden: boolean
+  enabled: boolean
+  expires_at?: string | null
+  reverted_to: number
+  username: string
+  created_at: string
+}
+
+export interface EnvUsage {
+  id: string
+  task_id: string
+  task_name: string
+  count: number
+  last_used_at: string
+}
+
 export interface EnvListResponse {
   data: EnvVar[]
   total: number
@@ -631,6 +987,84 @@ export interface LogDetail {
   start_time: string | null
   end_time: string | null
   created_at: string
+  lines?: LogLine[]
+}
+
+export interface LogLine {
+  t: number
+  stream: 'stdout' | 'stderr' | 'system'
+  text: string
+}
+
+export interface DiffLine {
+  op: 'equal' | 'insert' | 'delete'
+  a?: number
+  b?: number
+  text: string
+}
+
+export interface DiffHunk {
+  a_start: number
+  a_lines: number
+  b_start: number
+  b_lines: number
+  lines: DiffLine[]
+}
+
+export interface LogRunEnv {
+  env_names: string[]
+  languages: { name: string; version: string; resolved?: string }[]
+  work_dir: string
+}
+
+export interface LogRunInfo {
+  id: string
+  status: string
+  exit_code: number
+  duration: number
+  start_time: string | null
+  agent_id: string | null
+  command: string
+  env: LogRunEnv | null
+  lines: number
+  truncated: boolean
+}
+
+export interface LogDiffResult {
+  base: LogRunInfo
+  target: LogRunInfo
+  ignore: string[]
+  hunks: DiffHunk[]
+  added: number
+  removed: number
+  complete: boolean
+  command: DiffLine[]
+  env_added: string[]
+  env_removed: string[]
+  languages: { name: string; base: string; target: string }[]
+}
+
+export interface LogSearchSnippet {
+  line: number
+  text: string
+  highlight: string
+}
+
+export interface LogSearchHit {
+  log_id: string
+  task_id: string
+  task_name: string
+  status: string
+  created_at: string
+  start_time: string | null
+  matches: number
+  snippets: LogSearchSnippet[]
+}
+
+export interface LogSearchResult {
+  items: LogSearchHit[]
+  next_cursor: string
+  scanned: number
 }
 
 export interface AboutInfo {
@@ -662,6 +1096,11 @@ export interface SiteSettings {
   login_log_max_count?: string
   scheduler_log_days?: string
   scheduler_log_max_count?: string
+  audit_log_days?: string
+  trusted_device_days?: string
+  session_idle_minutes?: string
+  env_expiry_remind_days?: string
+  public_url?: string
   active_webui?: string
 }
 
@@ -669,6 +1108,7 @@ export interface SchedulerSettings {
   worker_count: string
   queue_size: string
   rate_interval: string
+  separate_stderr?: string
 }
 
 
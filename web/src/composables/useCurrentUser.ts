@@ -0,0 +1,41 @@
+import { ref, computed } from 'vue'
+import { api } from '@/api'
+
+// 角色等级，与后端 services.roleLevels 保持一致
+const ROLE_LEVELS: Record<string, number> = {
+  viewer: 1,
+  user: 1,
+  operator: 2,
+  editor: 3,
+  admin: 4
+}
+
+const username = ref('')
+// 未加载前按管理员处理，避免单用户场景下菜单闪烁
+const role = ref('admin')
+const scopes = ref('')
+
+let loading: Promise<void> | null = null
+
+export function useCurrentUser() {
+  function loadCurrentUser(force = false) {
+    if (!loading || force) {
+      loading = api.auth.me().then(res => {
+        username.value = res.username
+        role.value = res.role
+        scopes.value = res.scopes || ''
+      }).catch(() => {
+        loading = null
+      })
+    }
+    return loading
+  }
+
+  function hasRole(required: string) {
+    return (ROLE_LEVELS[role.value] || 0) >= (ROLE_LEVELS[required] || 0)
+  }
+
+  const isAdmin = computed(() => role.value === 'admin')
+
+  return { username, role, scopes, isAdmin, hasRole, loadCurrentUser }
+}
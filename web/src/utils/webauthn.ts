@@ -0,0 +1,76 @@
+/**
+ * WebAuthn 浏览器端工具
+ * 后端以 base64url 字符串下发挑战与凭证 ID，这里负责与 ArrayBuffer 互转
+ */
+
+function toBuffer(value: string): ArrayBuffer {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/')
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4)
+  const binary = atob(padded)
+  const bytes = new Uint8Array(binary.length)
+  for (let i = 0; i < binary.length; i++) bytes[i] = binary.charCodeAt(i)
+  return bytes.buffer
+}
+
+function toBase64url(buffer: ArrayBuffer | null): string {
+  if (!buffer) return ''
+  const bytes = new Uint8Array(buffer)
+  let binary = ''
+  for (let i = 0; i < bytes.length; i++) binary += String.fromCharCode(bytes[i]!)
+  return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '')
+}
+
+function convertDescriptors(list?: any[]) {
+  return (list || []).map((item) => ({ ...item, id: toBuffer(item.id) }))
+}
+
+/** 当前浏览器是否支持通行密钥（需 HTTPS 或 localhost） */
+export function isWebAuthnSupported(): boolean {
+  return typeof window !== 'undefined' && !!window.PublicKeyCredential && window.isSecureContext
+}
+
+/** 注册通行密钥，返回可直接提交给后端的凭证 */
+export async function createPasskey(options: any) {
+  const cred = (await navigator.credentials.create({
+    publicKey: {
+      ...options,
+      challenge: toBuffer(options.challenge),
+      user: { ...options.user, id: toBuffer(options.user.id) },
+      excludeCredentials: convertDescriptors(options.excludeCredentials),
+    },
+  })) as PublicKeyCredential | null
+  if (!cred) throw new Error('已取消注册')
+  const response = cred.response as AuthenticatorAttestationResponse
+  return {
+    id: cred.id,
+    type: cred.type,
+    response: {
+      clientDataJSON: toBase64url(response.clientDataJSON),
+      attestationObject: toBase64url(response.attestationObject),
+      transports: typeof response.getTransports === 'function' ? response.getTransports() : [],
+    },
+  }
+}
+
+/** 使用通行密钥签名登录挑战，返回可直接提交给后端的断言 */
+export async function getPasskey(options: any) {
+  const cred = (await navigator.credentials.get({
+    publicKey: {
+      ...options,
+      challenge: toBuffer(options.challenge),
+      allowCredentials: convertDescriptors(options.allowCredentials),
+    },
+  })) as PublicKeyCredential | null
+  if (!cred) throw new Error('已取消验证')
+  const response = cred.response as AuthenticatorAssertionResponse
+  return {
+    id: cred.id,
+    type: cred.type,
+    response: {
+      clientDataJSON: toBase64url(response.clientDataJSON),
+      authenticatorData: toBase64url(response.authenticatorData),
+      signature: toBase64url(response.signature),
+      userHandle: toBase64url(response.userHandle),
+    },
+  }
+}